@@ -1,13 +1,22 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
 
 	"github.com/kobzarvs/qedit/internal/app"
 	"github.com/kobzarvs/qedit/internal/logger"
+	"github.com/kobzarvs/qedit/internal/remote"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
 	// Initialize logger (debug mode if QEDIT_DEBUG is set)
 	debug := os.Getenv("QEDIT_DEBUG") != ""
@@ -22,10 +31,122 @@ func main() {
 	if len(args) > 0 && args[0] == "--" {
 		args = args[1:]
 	}
-	if err := app.New(args).Run(); err != nil {
+	opts, args, err := parseFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(app.ExitUsage)
+	}
+	if opts.version {
+		fmt.Println("qedit", version)
+		return
+	}
+	if opts.remote {
+		if err := remote.Attach(opts.socket, opts.token, nil); err != nil {
+			logger.Error("qedit --remote failed", "error", err)
+			fmt.Fprintln(os.Stderr, "qedit:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	run := func() error { return app.New(args, opts.app).Run() }
+	if opts.server {
+		run = func() error { return serve(args, opts) }
+	}
+	if err := run(); err != nil {
 		logger.Error("qedit exited with error", "error", err)
-		fmt.Fprintln(os.Stderr, "qedit:", err)
-		os.Exit(1)
+		if !errors.Is(err, app.ErrAborted) {
+			fmt.Fprintln(os.Stderr, "qedit:", err)
+		}
+		os.Exit(app.ExitCode(err))
 	}
 	logger.Info("qedit exited normally")
 }
+
+type cliOptions struct {
+	app     app.Options
+	version bool
+	server  bool   // run headless for clients to attach to
+	remote  bool   // attach to a headless qedit
+	socket  string // where the server listens and the client attaches
+	token   string // token the client attaches with, "" reads the server's token file
+	public  bool   // let the server listen on a TCP address other machines reach
+}
+
+// parseFlags parses command-line flags and returns the remaining arguments.
+// On --help it prints usage and returns flag.ErrHelp.
+func parseFlags(args []string) (cliOptions, []string, error) {
+	var opts cliOptions
+	args, opts.app.Commands = splitStartupCommands(args)
+	fs := flag.NewFlagSet("qedit", flag.ContinueOnError)
+	fs.StringVar(&opts.app.ConfigPath, "config", "", "use `file` instead of ~/.config/qedit/config.toml")
+	fs.StringVar(&opts.app.Theme, "theme", "", "use theme `name` from ~/.config/qedit/theme")
+	fs.BoolVar(&opts.app.ReadOnly, "readonly", false, "open files read-only")
+	fs.BoolVar(&opts.app.Clean, "clean", false, "ignore user config and themes")
+	fs.BoolVar(&opts.app.NoState, "no-state", false, "don't read or write history, undo and session files")
+	fs.BoolVar(&opts.app.Resume, "resume", false, "reopen the last session's buffers where they were left")
+	fs.BoolVar(&opts.server, "server", false, "run headless; attach to it with --remote")
+	fs.BoolVar(&opts.remote, "remote", false, "attach to a qedit started with --server (Ctrl-\\ detaches)")
+	fs.StringVar(&opts.socket, "socket", remote.DefaultAddr(), "Unix socket `path` or host:port of --server and --remote")
+	fs.StringVar(&opts.token, "token", "", "`token` --server printed, for --remote from another machine")
+	fs.BoolVar(&opts.public, "public", false, "let --server listen on a host:port other machines can reach")
+	fs.BoolVar(&opts.version, "version", false, "print version and exit")
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "Usage: qedit [flags] [+cmd ...] [file|dir]")
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "  +cmd    run ex command cmd after the file loads (+42 goes to line 42,")
+		fmt.Fprintln(out, "          + to the last line, +/pat searches for pat)")
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Flags:")
+		fs.PrintDefaults()
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Exit status: 0 ok, 1 error, 2 bad flags, 3 file can't be opened,")
+		fmt.Fprintln(out, "4 invalid config, 5 quit with :cq")
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, nil, err
+	}
+	if opts.server && opts.remote {
+		err := errors.New("--server and --remote don't go together")
+		fmt.Fprintln(fs.Output(), err)
+		return opts, nil, err
+	}
+	return opts, fs.Args(), nil
+}
+
+// serve runs qedit headless on the socket until the editor quits
+func serve(args []string, opts cliOptions) error {
+	srv, err := remote.Listen(opts.socket, opts.public)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "qedit: serving on", srv.Addr(), "(attach with qedit --remote)")
+	if opts.public {
+		fmt.Fprintln(os.Stderr, "qedit: token", srv.Token(), "(--token for clients on other machines)")
+	}
+	return srv.Serve(func(s tcell.Screen) error {
+		o := opts.app
+		o.Screen, o.Live = s, true
+		return app.New(args, o).Run()
+	})
+}
+
+// splitStartupCommands removes vi-style "+cmd" arguments, which may appear
+// anywhere before "--", and returns the remaining arguments and the commands.
+func splitStartupCommands(args []string) ([]string, []string) {
+	var rest, cmds []string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if strings.HasPrefix(arg, "+") {
+			cmds = append(cmds, arg[1:])
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, cmds
+}
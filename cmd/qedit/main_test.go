@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFlagsStartupCommands(t *testing.T) {
+	opts, args, err := parseFlags([]string{"+set wrap", "--readonly", "+42", "file.go", "--", "+literal"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if want := []string{"set wrap", "42"}; !reflect.DeepEqual(opts.app.Commands, want) {
+		t.Fatalf("commands = %q, want %q", opts.app.Commands, want)
+	}
+	if !opts.app.ReadOnly {
+		t.Fatalf("--readonly after +cmd was not parsed")
+	}
+	if want := []string{"file.go", "--", "+literal"}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("args = %q, want %q", args, want)
+	}
+}
+
+func TestParseFlagsNoState(t *testing.T) {
+	opts, _, err := parseFlags([]string{"--no-state", "file.go"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !opts.app.NoState {
+		t.Fatalf("--no-state was not parsed")
+	}
+}
+
+func TestParseFlagsResume(t *testing.T) {
+	opts, args, err := parseFlags([]string{"--resume"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !opts.app.Resume || len(args) != 0 {
+		t.Fatalf("--resume = %v, args %q", opts.app.Resume, args)
+	}
+}
+
+func TestParseFlagsServerRemote(t *testing.T) {
+	opts, _, err := parseFlags([]string{"--server", "--socket", "localhost:7000", "file.go"})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !opts.server || opts.socket != "localhost:7000" {
+		t.Fatalf("--server = %v, --socket = %q", opts.server, opts.socket)
+	}
+	if _, _, err := parseFlags([]string{"--server", "--remote"}); err == nil {
+		t.Fatal("--server with --remote was accepted")
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,44 +14,105 @@ import (
 	"github.com/kobzarvs/qedit/internal/config"
 	"github.com/kobzarvs/qedit/internal/editor"
 	"github.com/kobzarvs/qedit/internal/gitinfo"
+	"github.com/kobzarvs/qedit/internal/instance"
 	"github.com/kobzarvs/qedit/internal/logger"
 	"github.com/kobzarvs/qedit/internal/lsp"
 	"github.com/kobzarvs/qedit/internal/platform/keyboard"
+	"github.com/kobzarvs/qedit/internal/tasks"
 	"github.com/kobzarvs/qedit/internal/treesitter"
 )
 
 // App is the top-level runtime for qedit.
 type App struct {
 	args []string
+	opts Options
 }
 
-func New(args []string) *App {
-	return &App{args: args}
+// Options are command-line overrides for a single run.
+type Options struct {
+	ConfigPath string   // config file to use instead of the default one
+	Theme      string   // theme name overriding the configured theme
+	ReadOnly   bool     // refuse to overwrite opened files
+	Clean      bool     // ignore user config and themes, use defaults
+	NoState    bool     // don't read or write history, undo and session files
+	Resume     bool     // reopen the last session's buffers
+	Commands   []string // "+cmd" arguments run after the file is loaded
+
+	// Screen replaces the terminal, e.g. with a tcell.SimulationScreen in
+	// tests. The caller initializes it and Run finalizes it.
+	Screen tcell.Screen
+	// Live marks Screen as one a user looks at (the screen of a remote
+	// server), so timers wake the loop up as they do for the terminal
+	Live bool
+}
+
+// RenderedEvent is an interrupt whose channel Run closes after drawing the
+// screen for all events posted before it. Tests use it to wait for a frame.
+type RenderedEvent chan struct{}
+
+// searchScanEvent is an interrupt Run posts to itself to scan more of
+// the buffer for an unfinished search
+type searchScanEvent struct{}
+
+// handoffEvent is an interrupt carrying a file another qedit handed over
+type handoffEvent string
+
+func New(args []string, opts Options) *App {
+	return &App{args: args, opts: opts}
 }
 
+// Run starts the editor and blocks until it quits. Errors are *ConfigError,
+// *FileError or ErrAborted where that applies; see ExitCode.
 func (a *App) Run() error {
 	runtime.LockOSThread()
 	logger.Debug("app.Run started")
 
-	cfg, err := config.Load()
+	cfg, err := a.loadConfig()
 	if err != nil {
 		logger.Error("failed to load config", "error", err)
-		return err
+		return &ConfigError{Err: err}
+	}
+	if err := editor.CheckLangmap(cfg.Editor.Langmap); err != nil {
+		return &ConfigError{Err: err}
 	}
 	logger.Debug("config loaded")
 	langs, err := config.LoadLanguages()
 	if err != nil {
-		return err
+		return &ConfigError{Err: err}
 	}
 
-	s, err := tcell.NewScreen()
-	if err != nil {
-		return err
+	// With single-instance, a file goes to the qedit running in this
+	// project if there is one
+	singleInstance := cfg.Editor.SingleInstance && a.opts.Screen == nil
+	if singleInstance && a.handoff(instance.Addr(projectRoot())) {
+		return nil
 	}
-	if err := s.Init(); err != nil {
-		return err
+
+	s := a.opts.Screen
+	if s == nil {
+		if s, err = tcell.NewScreen(); err != nil {
+			return err
+		}
+		if err := s.Init(); err != nil {
+			return err
+		}
+	}
+	term := config.ResolveTerminal(cfg.Terminal, os.Getenv)
+	logger.Debug("terminal profile", "profile", term.Profile, "mouse", term.Mouse, "paste", term.BracketedPaste, "undercurl", term.Undercurl, "meta-esc", term.MetaSendsEscape)
+	switch term.Mouse {
+	case "off":
+	case "click":
+		s.EnableMouse(tcell.MouseButtonEvents)
+	case "drag":
+		s.EnableMouse(tcell.MouseButtonEvents | tcell.MouseDragEvents)
+	default:
+		s.EnableMouse()
 	}
-	s.EnableMouse()
+	if term.BracketedPaste {
+		s.EnablePaste()
+	}
+	// Focus reports let the editor check the file for outside changes
+	s.EnableFocus()
 	defer s.Fini()
 
 	ls := lsp.NewManager(langs)
@@ -67,7 +129,12 @@ func (a *App) Run() error {
 
 	stopLayout := make(chan struct{})
 	defer close(stopLayout)
+	// A supplied screen gets events only from its owner, so frames are deterministic
+	timers := a.opts.Screen == nil || a.opts.Live
 	go func() {
+		if !timers {
+			return
+		}
 		ticker := time.NewTicker(250 * time.Millisecond)
 		defer ticker.Stop()
 		for {
@@ -83,6 +150,29 @@ func (a *App) Run() error {
 	const maxHighlightBytes = 8 << 20
 	ed := editor.New(cfg)
 	defer ed.Shutdown()
+	if a.opts.NoState {
+		ed.DisableState()
+	}
+	ed.SetReadOnly(a.opts.ReadOnly)
+	// A finished task wakes the loop up so its result is shown
+	ed.SetTaskRegistry(tasks.New(func() {
+		_ = s.PostEvent(tcell.NewEventInterrupt(nil))
+	}))
+	// So do edits from the other side of a shared buffer
+	ed.SetWake(func() {
+		_ = s.PostEvent(tcell.NewEventInterrupt(nil))
+	})
+	ed.SetTerminalFeatures(term)
+	if a.opts.Screen == nil {
+		// sudo asks for the password of :wsudo on the terminal
+		ed.SetSuspend(func(run func() error) error {
+			if err := s.Suspend(); err != nil {
+				return err
+			}
+			defer s.Resume()
+			return run()
+		})
+	}
 	ed.LoadCmdHistory()
 	ed.LoadSearchHistory()
 	gitPath := ""
@@ -90,29 +180,96 @@ func (a *App) Run() error {
 	var langName string
 	highlightEnabled := true
 	highlightExpected := false
-	if len(a.args) > 0 {
-		openPath = a.args[0]
-		if err := ed.OpenFile(openPath); err != nil {
+	// Buffer edits are sent to the language server once per loop iteration
+	// and reparsed, incrementally when there was one since the last parse
+	lspChanged := false
+	syntaxChanges := 0
+	var syntaxChange editor.TextChange
+	ed.OnChange(func(c editor.TextChange) {
+		lspChanged = true
+		syntaxChanges++
+		syntaxChange = c
+	})
+	openFile := func(path string) error {
+		if err := ed.OpenFile(path); err != nil {
 			return err
 		}
-		gitPath = openPath
-		if info, err := os.Stat(openPath); err == nil && info.Size() > maxHighlightBytes {
+		openPath = path
+		lspChanged = false
+		syntaxChanges = 0
+		langName = ""
+		highlightEnabled = true
+		if info, err := os.Stat(path); err == nil && info.Size() > maxHighlightBytes {
 			highlightEnabled = false
 		}
+		if ed.IsPreview() {
+			// Binary or huge file: the buffer holds a summary, not the file
+			highlightEnabled = false
+			highlightExpected = false
+			return nil
+		}
 		content := ed.Content()
-		ls.OpenFile(openPath, content)
+		ls.OpenFile(path, content)
 		if highlightEnabled {
-			if lang := langs.Match(openPath); lang != nil {
+			if lang := langs.Match(path); lang != nil {
 				langName = lang.Name
 			}
 		}
 		highlightExpected = highlightEnabled && langName != ""
+		return nil
+	}
+	projectDir := ""
+	if len(a.args) > 0 {
+		if info, err := os.Stat(a.args[0]); err == nil && info.IsDir() {
+			// `qedit <dir>`: work inside the directory and show the file tree
+			if err := os.Chdir(a.args[0]); err != nil {
+				return &FileError{Path: a.args[0], Err: err}
+			}
+			if projectDir, err = os.Getwd(); err != nil {
+				return err
+			}
+			gitPath = projectDir
+		} else {
+			if err := openFile(a.args[0]); err != nil {
+				return &FileError{Path: a.args[0], Err: err}
+			}
+			gitPath = openPath
+		}
+	}
+	lastRunUnclean := false
+	if sm := ed.GetSessionManager(); sm != nil {
+		lastRunUnclean = sm.MarkRunning()
+	}
+	if a.opts.Resume {
+		path, err := ed.ResumeSession()
+		if err != nil {
+			return &FileError{Path: "session", Err: err}
+		}
+		if openPath == "" && projectDir == "" {
+			if err := openFile(path); err != nil {
+				return &FileError{Path: path, Err: err}
+			}
+			gitPath = openPath
+		}
+	} else if lastRunUnclean && openPath == "" {
+		ed.OfferResume()
 	}
 	if gitPath == "" {
 		if cwd, err := os.Getwd(); err == nil {
 			gitPath = cwd
 		}
 	}
+	// Take the files later qedits in this project hand over
+	if singleInstance {
+		ln, err := instance.Listen(instance.Addr(projectRoot()), func(path string) {
+			_ = s.PostEvent(tcell.NewEventInterrupt(handoffEvent(path)))
+		})
+		if err != nil {
+			logger.Error("single-instance listen failed", "error", err)
+		} else {
+			defer ln.Close()
+		}
+	}
 
 	lastLayoutRaw := keyboard.CurrentLayoutRaw()
 	ed.SetKeyboardLayout(keyboard.CurrentLayout())
@@ -142,6 +299,12 @@ func (a *App) Run() error {
 		}
 	}
 
+	if projectDir != "" {
+		if err := ed.OpenProject(projectDir); err != nil {
+			return &FileError{Path: projectDir, Err: err}
+		}
+	}
+
 	// Wire up tree-sitter node stack callback for expand/shrink selection
 	ed.SetNodeStackFunc(func(path string, row, col int) []editor.NodeRange {
 		stack := ts.GetNodeStackAt(path, row, col)
@@ -160,8 +323,34 @@ func (a *App) Run() error {
 		return result
 	})
 
+	// Wire up tree-sitter bracket pairs for mm
+	ed.SetBracketPairFunc(func(path string, row, col int) (editor.BracketPair, bool) {
+		pair, ok := ts.BracketPairAt(path, row, col)
+		return editor.BracketPair(pair), ok
+	})
+
+	// Wire up tree-sitter task list markers for :todo
+	ed.SetTaskMarkersFunc(func(path string, startRow, endRow int) ([]editor.TaskMarker, bool) {
+		markers, ok := ts.TaskMarkers(path, startRow, endRow)
+		result := make([]editor.TaskMarker, len(markers))
+		for i, m := range markers {
+			result[i] = editor.TaskMarker(m)
+		}
+		return result, ok
+	})
+
+	// Wire up tree-sitter identifiers for word completion
+	ed.SetScopeWordsFunc(func(path string, row, col int) []editor.ScopeWord {
+		words := ts.ScopeWordsAt(path, row, col)
+		result := make([]editor.ScopeWord, len(words))
+		for i, w := range words {
+			result[i] = editor.ScopeWord(w)
+		}
+		return result
+	})
+
 	// Wire up LSP goto callback for definition, references, etc.
-	ed.SetLSPGotoFunc(func(method, path string, line, col int) ([]editor.LSPLocation, error) {
+	ed.SetLSPGotoFunc(func(ctx context.Context, method, path string, line, col int) ([]editor.LSPLocation, error) {
 		// Ensure we use absolute path (same as LSP OpenFile)
 		absPath, err := filepath.Abs(path)
 		if err != nil {
@@ -170,15 +359,15 @@ func (a *App) Run() error {
 		var locs []lsp.Location
 		switch method {
 		case "definition":
-			locs, err = ls.GotoDefinition(absPath, line, col)
+			locs, err = ls.GotoDefinition(ctx, absPath, line, col)
 		case "declaration":
-			locs, err = ls.GotoDeclaration(absPath, line, col)
+			locs, err = ls.GotoDeclaration(ctx, absPath, line, col)
 		case "typeDefinition":
-			locs, err = ls.GotoTypeDefinition(absPath, line, col)
+			locs, err = ls.GotoTypeDefinition(ctx, absPath, line, col)
 		case "references":
-			locs, err = ls.FindReferences(absPath, line, col)
+			locs, err = ls.FindReferences(ctx, absPath, line, col)
 		case "implementation":
-			locs, err = ls.GotoImplementation(absPath, line, col)
+			locs, err = ls.GotoImplementation(ctx, absPath, line, col)
 		default:
 			return nil, fmt.Errorf("unknown LSP method: %s", method)
 		}
@@ -197,8 +386,28 @@ func (a *App) Run() error {
 		}
 		return result, nil
 	})
+	// checkout switches branches as a task, since git can take a while
+	checkout := func(branch string) {
+		if gitPath == "" {
+			ed.SetStatusMessage("not a git repository")
+			return
+		}
+		path := gitPath
+		ed.RunTask("git checkout "+branch, func(ctx context.Context) func() {
+			err := gitinfo.CheckoutContext(ctx, path, branch)
+			return func() {
+				if err != nil {
+					logger.Error("failed to checkout branch", "branch", branch, "error", err)
+					ed.SetStatusMessage(err.Error())
+					return
+				}
+				ed.SetGitBranch(branch)
+				ed.SetStatusMessage("checked out " + branch)
+			}
+		})
+	}
 	lastGitCheck := time.Now()
-	lastChangeTick := ed.ChangeTick()
+	lastSessionSync := time.Now()
 	lastHighlightStart := -1
 	lastHighlightEnd := -1
 	if openPath != "" && highlightEnabled && langName != "" {
@@ -238,26 +447,76 @@ func (a *App) Run() error {
 			highlightExpected = false
 		}
 	}
+	ed.StartPlugins(cfg.Plugins)
+	for _, cmd := range a.opts.Commands {
+		if ed.RunStartupCommand(cmd) {
+			return quitError(ed)
+		}
+	}
 	ed.Render(s)
+	var rendered RenderedEvent
+	searchPosted := false
+	var menuWake time.Time
+	var queued tcell.Event // read behind an input method burst, handled next
 	for {
-		ev := s.PollEvent()
+		ev := queued
+		if ev == nil {
+			ev = s.PollEvent()
+		}
+		queued = nil
 		isMouseScroll := false
 		switch ev := ev.(type) {
 		case *tcell.EventKey:
-			if ed.HandleKey(ev) {
-				return nil
+			if !ed.AcceptsComposition() {
+				if ed.HandleKey(ev) {
+					return quitError(ed)
+				}
+				break
+			}
+			// Text committed by an input method arrives as a burst of runes
+			keys, composed, next := editor.ReadComposedBurst(s, ev)
+			queued = next
+			if composed != "" {
+				ed.CommitComposition(composed)
+			}
+			for _, key := range keys {
+				if ed.HandleKey(key) {
+					return quitError(ed)
+				}
 			}
 		case *tcell.EventMouse:
 			ed.HandleMouse(ev)
 			isMouseScroll = true
+		case *tcell.EventPaste:
+			ed.HandlePaste(ev.Start())
 		case *tcell.EventResize:
 			s.Sync()
+		case *tcell.EventFocus:
+			if ev.Focused {
+				ed.CheckDisk()
+			}
 		case *tcell.EventInterrupt:
 			// Layout updates are handled below.
+			if _, ok := ev.Data().(searchScanEvent); ok {
+				searchPosted = false
+				ed.ContinueSearch()
+			}
+			if path, ok := ev.Data().(handoffEvent); ok {
+				ed.RequestOpen(string(path))
+			}
+			if done, ok := ev.Data().(RenderedEvent); ok {
+				if rendered != nil {
+					close(rendered)
+				}
+				rendered = done
+			}
 		}
 		if !isMouseScroll {
 			ed.UpdateScroll()
 		}
+		ed.PollTasks()
+		ed.PollCollab()
+		ed.PollPlugins()
 		if ed.ConsumeBranchPickerRequest() {
 			logger.Debug("branch picker requested")
 			if gitPath == "" {
@@ -277,31 +536,61 @@ func (a *App) Run() error {
 		// Handle sidebar branch selection (and legacy branch picker selection)
 		if branch := ed.ConsumeSidebarBranchSelection(); branch != "" {
 			logger.Debug("sidebar branch selected", "branch", branch)
-			if gitPath == "" {
-				ed.SetStatusMessage("not a git repository")
-			} else if err := gitinfo.Checkout(gitPath, branch); err != nil {
-				logger.Error("failed to checkout branch", "branch", branch, "error", err)
-				ed.SetStatusMessage(err.Error())
-			} else {
-				ed.SetGitBranch(branch)
-				ed.SetStatusMessage("checked out " + branch)
-			}
+			checkout(branch)
 		} else if branch, ok := ed.ConsumeBranchSelection(); ok {
-			if gitPath == "" {
-				ed.SetStatusMessage("not a git repository")
-			} else if err := gitinfo.Checkout(gitPath, branch); err != nil {
+			checkout(branch)
+		}
+		// Forget a file closed with :bd
+		if path := ed.ConsumeClosedBuffer(); path != "" && path == openPath {
+			ls.DidClose(openPath)
+			openPath = ""
+			langName = ""
+			highlightExpected = false
+			lspChanged = false
+			syntaxChanges = 0
+			lastHighlightStart = -1
+			lastHighlightEnd = -1
+		}
+		// Handle file opened from the sidebar file tree or gf
+		if path := ed.ConsumeOpenFileRequest(); path != "" {
+			logger.Debug("open file requested", "path", path)
+			if err := openFile(path); err != nil {
 				ed.SetStatusMessage(err.Error())
 			} else {
-				ed.SetGitBranch(branch)
-				ed.SetStatusMessage("checked out " + branch)
+				if highlightExpected && !ts.ParseSync(openPath, langName, ed.Content()) {
+					highlightExpected = false
+				}
+				syntaxChanges = 0
+				lastHighlightStart = -1
+				lastHighlightEnd = -1
 			}
 		}
+		// Keep the file tree in sync with changes made outside qedit
+		ed.PollSidebarFiles(time.Now())
+		// Drop a key sequence (g, Space, f...) left unfinished too long
+		ed.ExpirePendingKeys(time.Now())
+		// A menu held back by which-key-delay is drawn when the delay is up
+		if at := ed.MenuShowsAt(); !at.IsZero() && !at.Equal(menuWake) && timers {
+			menuWake = at
+			time.AfterFunc(time.Until(at), func() {
+				_ = s.PostEvent(tcell.NewEventInterrupt(nil))
+			})
+		}
+		// A search cut short by its time budget goes on after the events
+		// queued meanwhile, so a keystroke restarts it instead of waiting
+		if ed.SearchPending() && !searchPosted {
+			searchPosted = true
+			_ = s.PostEvent(tcell.NewEventInterrupt(searchScanEvent{}))
+		}
+		if lspChanged && openPath != "" && !ed.IsPreview() {
+			lspChanged = false
+			ls.DidChange(openPath, ed.Content())
+		}
 		if openPath != "" && highlightEnabled && langName != "" {
-			tick := ed.ChangeTick()
-			changed := tick != lastChangeTick
+			changed := syntaxChanges > 0
 			if changed {
-				lastChangeTick = tick
-				if edit, ok := ed.ConsumeLastEdit(); ok {
+				if syntaxChanges == 1 {
+					edit := ed.ByteEdit(syntaxChange)
 					tsEdit := sitter.EditInput{
 						StartIndex:  uint32(edit.StartByte),
 						OldEndIndex: uint32(edit.OldEndByte),
@@ -323,6 +612,7 @@ func (a *App) Run() error {
 				} else {
 					ts.ParseSync(openPath, langName, ed.Content())
 				}
+				syntaxChanges = 0
 			}
 			start, end := ed.VisibleRange()
 			if changed || start != lastHighlightStart || end != lastHighlightEnd {
@@ -361,9 +651,89 @@ func (a *App) Run() error {
 			lastGitCheck = time.Now()
 			ed.SetGitBranch(gitinfo.Branch(gitPath))
 		}
+		// Keep the session current for --resume after an unclean exit
+		if time.Since(lastSessionSync) > 5*time.Second {
+			lastSessionSync = time.Now()
+			ed.SyncSession()
+		}
+		ed.AutosaveUndoHistory(time.Now())
 		if highlightExpected && !ed.HasHighlights() {
 			continue
 		}
 		ed.Render(s)
+		if rendered != nil {
+			close(rendered)
+			rendered = nil
+		}
+	}
+}
+
+// handoff hands the file of `qedit file` to the qedit listening on addr
+// and reports whether it took it. Startup commands, --resume and
+// --readonly need an editor of their own.
+func (a *App) handoff(addr string) bool {
+	if len(a.args) != 1 || len(a.opts.Commands) > 0 || a.opts.Resume || a.opts.ReadOnly {
+		return false
+	}
+	info, err := os.Stat(a.args[0])
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	path, err := filepath.Abs(a.args[0])
+	if err != nil {
+		return false
+	}
+	if err := instance.Send(addr, path); err != nil {
+		logger.Debug("no qedit to hand the file to", "error", err)
+		return false
+	}
+	logger.Info("file handed to the running qedit", "path", path)
+	return true
+}
+
+// projectRoot returns the project of the working directory: its git
+// repository, or else the directory itself
+func projectRoot() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	if root := gitinfo.Root(cwd); root != "" {
+		return root
+	}
+	return cwd
+}
+
+// quitError is the result of Run when the editor asks to quit
+func quitError(ed *editor.Editor) error {
+	if ed.Aborted() {
+		return ErrAborted
+	}
+	return nil
+}
+
+// loadConfig loads the config honoring --config, --theme and --clean.
+func (a *App) loadConfig() (config.Config, error) {
+	if a.opts.Clean {
+		return config.Default(), nil
+	}
+	var cfg config.Config
+	var err error
+	if a.opts.ConfigPath != "" {
+		if _, err := os.Stat(a.opts.ConfigPath); err != nil {
+			return config.Default(), err
+		}
+		cfg, err = config.LoadFile(a.opts.ConfigPath)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if a.opts.Theme != "" {
+		if err := config.ApplyTheme(&cfg, a.opts.Theme); err != nil {
+			return cfg, fmt.Errorf("theme %s: %w", a.opts.Theme, err)
+		}
 	}
+	return cfg, nil
 }
@@ -0,0 +1,53 @@
+package app
+
+import "errors"
+
+// Exit codes of the qedit binary, so scripts can tell failures apart
+const (
+	ExitOK           = 0
+	ExitError        = 1 // any other error
+	ExitUsage        = 2 // bad command-line flags
+	ExitFileNotFound = 3 // the file or directory to open can't be read
+	ExitConfig       = 4 // config, theme or languages file is invalid
+	ExitAborted      = 5 // the user quit with :cq
+)
+
+// ErrAborted is returned by Run when the user quits with :cq, e.g. to make
+// git abort a commit whose message is being edited
+var ErrAborted = errors.New("aborted")
+
+// ConfigError is a failure to load the config, theme or languages file
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return "config: " + e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// FileError is a failure to open the file or directory given on the command
+// line. Err is usually an *fs.PathError, which already names the path.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string { return e.Err.Error() }
+func (e *FileError) Unwrap() error { return e.Err }
+
+// ExitCode maps an error returned by Run to the process exit code
+func ExitCode(err error) int {
+	var cfgErr *ConfigError
+	var fileErr *FileError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrAborted):
+		return ExitAborted
+	case errors.As(err, &cfgErr):
+		return ExitConfig
+	case errors.As(err, &fileErr):
+		return ExitFileNotFound
+	default:
+		return ExitError
+	}
+}
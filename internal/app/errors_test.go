@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	_, statErr := os.Stat("/nonexistent/qedit-test")
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ExitOK},
+		{errors.New("boom"), ExitError},
+		{ErrAborted, ExitAborted},
+		{fmt.Errorf("run: %w", ErrAborted), ExitAborted},
+		{&ConfigError{Err: errors.New("bad toml")}, ExitConfig},
+		{&FileError{Path: "/nonexistent/qedit-test", Err: statErr}, ExitFileNotFound},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunReportsMissingConfig(t *testing.T) {
+	err := New(nil, Options{ConfigPath: "/nonexistent/qedit.toml"}).Run()
+	if ExitCode(err) != ExitConfig {
+		t.Fatalf("Run() = %v, want a config error", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Run() = %v, want it to wrap os.ErrNotExist", err)
+	}
+}
+
+func TestRunReportsBadLangmap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[editor]\nlangmap = \"abc\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := New(nil, Options{ConfigPath: path}).Run()
+	if ExitCode(err) != ExitConfig || !strings.Contains(err.Error(), "langmap") {
+		t.Fatalf("Run() = %v, want a langmap config error", err)
+	}
+}
@@ -0,0 +1,320 @@
+// Package apptest runs the whole qedit app loop on a tcell simulation screen
+// for end-to-end tests: inject keys, wait for the frame, inspect cells.
+//
+//	h := apptest.Start(t, []string{apptest.File(t, "a.txt", "hello\n")})
+//	h.Send(":ln off<CR>")
+//	h.ExpectLine(0, "hello")
+//
+// Runs use the default config (--clean) and no persisted state (--no-state),
+// with config and state directories pointed at a temporary directory.
+package apptest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/app"
+)
+
+// Timeout bounds every wait for the app loop
+var Timeout = 5 * time.Second
+
+// Harness is a running app on a simulation screen
+type Harness struct {
+	t      testing.TB
+	screen tcell.SimulationScreen
+	done   chan error
+	err    error
+	exited bool
+}
+
+// Option changes the app options (ReadOnly, Commands, ...) before the run starts
+type Option func(*app.Options)
+
+// File writes a file in a temporary directory and returns its path
+func File(t testing.TB, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("apptest: write %s: %v", name, err)
+	}
+	return path
+}
+
+// Start runs the app with args on an 80x25 screen and waits for the first frame
+func Start(t testing.TB, args []string, opts ...Option) *Harness {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("apptest: init screen: %v", err)
+	}
+	options := app.Options{Clean: true, NoState: true, Screen: screen}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.Screen = screen
+
+	h := &Harness{t: t, screen: screen, done: make(chan error, 1)}
+	go func() {
+		h.done <- app.New(args, options).Run()
+	}()
+	t.Cleanup(func() {
+		if !h.exited {
+			_ = h.Quit()
+		}
+	})
+	h.Sync()
+	return h
+}
+
+// Screen returns the simulation screen for checks the helpers don't cover
+func (h *Harness) Screen() tcell.SimulationScreen {
+	return h.screen
+}
+
+// Resize changes the screen size and waits for the redraw
+func (h *Harness) Resize(w, hgt int) {
+	h.t.Helper()
+	h.screen.SetSize(w, hgt)
+	h.post(tcell.NewEventResize(w, hgt))
+	h.Sync()
+}
+
+// Sync waits until every event sent so far has been handled and drawn
+func (h *Harness) Sync() {
+	h.t.Helper()
+	if h.exited {
+		return
+	}
+	done := make(app.RenderedEvent)
+	h.post(tcell.NewEventInterrupt(done))
+	select {
+	case <-done:
+	case err := <-h.done:
+		h.exit(err)
+	case <-time.After(Timeout):
+		h.t.Fatalf("apptest: no frame within %v", Timeout)
+	}
+}
+
+// Send injects keys in vim notation and waits for the frame: plain runes,
+// <Esc>, <CR>/<Enter>, <Tab>, <S-Tab>, <BS>, <Del>, <Space>, <lt>,
+// <Up>/<Down>/<Left>/<Right>, <Home>/<End>, <PageUp>/<PageDown>, <C-x> and <A-x>
+func (h *Harness) Send(keys string) {
+	h.t.Helper()
+	events, err := ParseKeys(keys)
+	if err != nil {
+		h.t.Fatalf("apptest: %v", err)
+	}
+	for _, ev := range events {
+		if h.exited {
+			h.t.Fatalf("apptest: app exited before %q", keys)
+		}
+		h.post(ev)
+	}
+	h.Sync()
+}
+
+// Type injects text as plain runes and waits for the frame
+func (h *Harness) Type(text string) {
+	h.t.Helper()
+	for _, r := range text {
+		h.post(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	h.Sync()
+}
+
+// Click presses and releases the left mouse button at x, y and waits for
+// the frame
+func (h *Harness) Click(x, y int) {
+	h.t.Helper()
+	h.post(tcell.NewEventMouse(x, y, tcell.Button1, tcell.ModNone))
+	h.post(tcell.NewEventMouse(x, y, tcell.ButtonNone, tcell.ModNone))
+	h.Sync()
+}
+
+func (h *Harness) post(ev tcell.Event) {
+	if h.exited {
+		return
+	}
+	deadline := time.Now().Add(Timeout)
+	for h.screen.PostEvent(ev) != nil {
+		// The queue is full until the app loop catches up
+		select {
+		case err := <-h.done:
+			h.exit(err)
+			return
+		case <-time.After(time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("apptest: event queue blocked for %v", Timeout)
+		}
+	}
+}
+
+// Quit sends :q! and returns the error Run returned
+func (h *Harness) Quit() error {
+	h.t.Helper()
+	if !h.exited {
+		h.Send("<Esc>:q!<CR>")
+	}
+	return h.Wait()
+}
+
+// Wait waits for Run to return, e.g. after a quit command sent with Send
+func (h *Harness) Wait() error {
+	h.t.Helper()
+	if !h.exited {
+		h.wait()
+	}
+	return h.err
+}
+
+func (h *Harness) wait() {
+	select {
+	case err := <-h.done:
+		h.exit(err)
+	case <-time.After(Timeout):
+		h.t.Errorf("apptest: app did not exit within %v", Timeout)
+		h.exited = true
+	}
+}
+
+func (h *Harness) exit(err error) {
+	h.exited = true
+	h.err = err
+}
+
+// Exited reports whether Run has returned
+func (h *Harness) Exited() bool {
+	return h.exited
+}
+
+// Size returns the screen size
+func (h *Harness) Size() (int, int) {
+	_, w, hgt := h.screen.GetContents()
+	return w, hgt
+}
+
+// Cell returns the rune and style at x, y. Empty cells read as a space.
+func (h *Harness) Cell(x, y int) (rune, tcell.Style) {
+	cells, w, hgt := h.screen.GetContents()
+	if x < 0 || y < 0 || x >= w || y >= hgt {
+		h.t.Fatalf("apptest: cell %d,%d outside %dx%d screen", x, y, w, hgt)
+	}
+	cell := cells[y*w+x]
+	if len(cell.Runes) == 0 {
+		return ' ', cell.Style
+	}
+	return cell.Runes[0], cell.Style
+}
+
+// Line returns row y of the screen with trailing spaces trimmed. The second
+// cell of a wide character reads as a space.
+func (h *Harness) Line(y int) string {
+	cells, w, hgt := h.screen.GetContents()
+	if y < 0 || y >= hgt {
+		h.t.Fatalf("apptest: row %d outside %d rows", y, hgt)
+	}
+	var sb strings.Builder
+	for x := 0; x < w; x++ {
+		cell := cells[y*w+x]
+		if len(cell.Runes) == 0 {
+			sb.WriteByte(' ')
+			continue
+		}
+		sb.WriteString(string(cell.Runes))
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+// Lines returns all rows of the screen
+func (h *Harness) Lines() []string {
+	_, hgt := h.Size()
+	lines := make([]string, hgt)
+	for y := range lines {
+		lines[y] = h.Line(y)
+	}
+	return lines
+}
+
+// Dump returns the screen as text, for failure messages
+func (h *Harness) Dump() string {
+	return strings.Join(h.Lines(), "\n")
+}
+
+// Statusline returns the statusline row (second to last)
+func (h *Harness) Statusline() string {
+	_, hgt := h.Size()
+	return h.Line(hgt - 2)
+}
+
+// Cmdline returns the command/message row (last)
+func (h *Harness) Cmdline() string {
+	_, hgt := h.Size()
+	return h.Line(hgt - 1)
+}
+
+// Find returns the position of the first occurrence of text on the screen
+func (h *Harness) Find(text string) (x, y int, ok bool) {
+	for y, line := range h.Lines() {
+		if i := strings.Index(line, text); i >= 0 {
+			return len([]rune(line[:i])), y, true
+		}
+	}
+	return -1, -1, false
+}
+
+// Cursor returns the terminal cursor position and whether it's shown
+func (h *Harness) Cursor() (x, y int, visible bool) {
+	return h.screen.GetCursor()
+}
+
+// Expect fails the test unless text is somewhere on the screen
+func (h *Harness) Expect(text string) {
+	h.t.Helper()
+	if _, _, ok := h.Find(text); !ok {
+		h.t.Fatalf("apptest: %q not on screen:\n%s", text, h.Dump())
+	}
+}
+
+// ExpectNot fails the test if text is on the screen
+func (h *Harness) ExpectNot(text string) {
+	h.t.Helper()
+	if _, y, ok := h.Find(text); ok {
+		h.t.Fatalf("apptest: %q unexpectedly on row %d:\n%s", text, y, h.Dump())
+	}
+}
+
+// ExpectLine fails the test unless row y contains text
+func (h *Harness) ExpectLine(y int, text string) {
+	h.t.Helper()
+	if line := h.Line(y); !strings.Contains(line, text) {
+		h.t.Fatalf("apptest: row %d = %q, want it to contain %q", y, line, text)
+	}
+}
+
+// ExpectStatus fails the test unless the statusline contains text
+func (h *Harness) ExpectStatus(text string) {
+	h.t.Helper()
+	if line := h.Statusline(); !strings.Contains(line, text) {
+		h.t.Fatalf("apptest: statusline = %q, want it to contain %q", line, text)
+	}
+}
+
+// ExpectCmdline fails the test unless the last row contains text
+func (h *Harness) ExpectCmdline(text string) {
+	h.t.Helper()
+	if line := h.Cmdline(); !strings.Contains(line, text) {
+		h.t.Fatalf("apptest: command line = %q, want it to contain %q", line, text)
+	}
+}
@@ -0,0 +1,180 @@
+package apptest
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/app"
+)
+
+func TestParseKeys(t *testing.T) {
+	events, err := ParseKeys("a<lt><C-s><Esc><A-x><space>ж<")
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	want := []struct {
+		key tcell.Key
+		r   rune
+		mod tcell.ModMask
+	}{
+		{tcell.KeyRune, 'a', 0},
+		{tcell.KeyRune, '<', 0},
+		{tcell.KeyCtrlS, 0, 0},
+		{tcell.KeyEscape, 0, 0},
+		{tcell.KeyRune, 'x', tcell.ModAlt},
+		{tcell.KeyRune, ' ', 0},
+		{tcell.KeyRune, 'ж', 0},
+		{tcell.KeyRune, '<', 0},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, ev := range events {
+		w := want[i]
+		if ev.Key() != w.key || (w.key == tcell.KeyRune && ev.Rune() != w.r) || ev.Modifiers()&tcell.ModAlt != w.mod {
+			t.Errorf("event %d = %v %q %v, want %v %q %v", i, ev.Key(), ev.Rune(), ev.Modifiers(), w.key, w.r, w.mod)
+		}
+	}
+	if _, err := ParseKeys("<Nope>"); err == nil {
+		t.Fatalf("unknown key name should fail")
+	}
+}
+
+func TestStatuslineAndEdit(t *testing.T) {
+	path := File(t, "notes.txt", "hello world\nsecond line\n")
+	h := Start(t, []string{path})
+	h.ExpectLine(0, "hello world")
+	h.ExpectStatus("NORMAL")
+	h.ExpectStatus("notes.txt")
+	h.ExpectStatus("Ln 1, Col 1")
+
+	h.Send("jw")
+	h.ExpectStatus("Ln 2, Col 8")
+	h.Send("iX<Esc>")
+	h.ExpectLine(1, "second Xline")
+
+	h.Send(":w<CR>")
+	h.ExpectStatus("written")
+	if err := h.Quit(); err != nil {
+		t.Fatalf("Quit() = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "hello world\nsecond Xline\n" {
+		t.Fatalf("file = %q, %v", data, err)
+	}
+}
+
+func TestCommandAutocompletePopup(t *testing.T) {
+	h := Start(t, []string{File(t, "a.txt", "text\n")})
+	h.Send(":js")
+	h.ExpectNot("pretty-print JSON")
+	h.Send("<Tab>")
+	h.Expect("json fmt - pretty-print JSON")
+	h.Expect("json path - copy JSON path at cursor")
+	h.ExpectCmdline(":json fmt")
+	h.Send("<Esc><Esc>")
+	h.ExpectNot("pretty-print JSON")
+	h.ExpectStatus("NORMAL")
+}
+
+func TestSelectionRendering(t *testing.T) {
+	h := Start(t, []string{File(t, "a.txt", "hello world\n")})
+	x, y, ok := h.Find("hello")
+	if !ok {
+		t.Fatalf("text not rendered:\n%s", h.Dump())
+	}
+	_, plain := h.Cell(x, y)
+	h.Send("vll")
+	_, selected := h.Cell(x, y)
+	_, plainBg, _ := plain.Decompose()
+	_, selectedBg, _ := selected.Decompose()
+	if selectedBg == plainBg {
+		t.Fatalf("selected cell background = %v, same as unselected", selectedBg)
+	}
+	if _, after := h.Cell(x+3, y); after != plain {
+		t.Fatalf("cell after the selection is styled %v, want %v", after, plain)
+	}
+	h.Send(";")
+	if _, st := h.Cell(x, y); st != plain {
+		t.Fatalf("selection still drawn after collapsing it")
+	}
+}
+
+func TestQuitWithErrorCode(t *testing.T) {
+	h := Start(t, []string{File(t, "a.txt", "text\n")})
+	h.Send(":cq<CR>")
+	if err := h.Wait(); !errors.Is(err, app.ErrAborted) {
+		t.Fatalf("Wait() = %v, want ErrAborted", err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	h := Start(t, []string{File(t, "a.txt", "text\n")})
+	h.Resize(40, 10)
+	if w, hgt := h.Size(); w != 40 || hgt != 10 {
+		t.Fatalf("size = %dx%d", w, hgt)
+	}
+	h.ExpectLine(8, "NORMAL")
+}
+
+func TestCloseAndReopenBuffer(t *testing.T) {
+	path := File(t, "notes.txt", "first\nsecond\nthird\n")
+	h := Start(t, []string{path})
+	h.Send("jjll")
+	h.ExpectStatus("Ln 3, Col 3")
+
+	h.Send(":bd<CR>")
+	h.ExpectStatus("[No Name]")
+	h.ExpectNot("third")
+
+	h.Send(":bundo<CR>")
+	h.ExpectLine(2, "third")
+	h.ExpectStatus("notes.txt")
+	h.ExpectStatus("Ln 3, Col 3")
+}
+
+func TestBufferlineSwitch(t *testing.T) {
+	other := File(t, "other.txt", "bravo\n")
+	path := File(t, "main.txt", other+"\n")
+	h := Start(t, []string{path})
+	h.Send(":bufferline on<CR>")
+	h.ExpectLine(0, " main.txt")
+
+	h.Send("gf")
+	h.ExpectLine(0, " main.txt  other.txt")
+	h.ExpectLine(1, "bravo")
+	h.ExpectStatus("other.txt")
+
+	x, _, ok := h.Find("main.txt")
+	if !ok {
+		t.Fatalf("main.txt tab not found:\n%s", h.Dump())
+	}
+	h.Click(x, 0)
+	h.ExpectStatus("main.txt")
+	h.ExpectLine(1, other)
+
+	h.Send("gn")
+	h.ExpectStatus("other.txt")
+}
+
+func TestTabPagesLine(t *testing.T) {
+	path := File(t, "main.txt", "alpha\n")
+	h := Start(t, []string{path})
+	h.ExpectLine(0, "alpha")
+
+	h.Send(":tabnew<CR>")
+	h.ExpectLine(0, "1:main.txt  2:[No Name]")
+	h.ExpectNot("alpha")
+
+	h.Send("gt")
+	h.ExpectLine(1, "alpha")
+	h.ExpectStatus("main.txt")
+
+	// Closing the first page leaves the empty one and hides the tab line
+	h.Send(":tabclose<CR>")
+	h.ExpectStatus("[No Name]")
+	h.ExpectNot("1:main.txt")
+}
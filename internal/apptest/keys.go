@@ -0,0 +1,72 @@
+package apptest
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+var namedKeys = map[string]tcell.Key{
+	"esc":      tcell.KeyEscape,
+	"cr":       tcell.KeyEnter,
+	"enter":    tcell.KeyEnter,
+	"tab":      tcell.KeyTab,
+	"s-tab":    tcell.KeyBacktab,
+	"bs":       tcell.KeyBackspace2,
+	"del":      tcell.KeyDelete,
+	"up":       tcell.KeyUp,
+	"down":     tcell.KeyDown,
+	"left":     tcell.KeyLeft,
+	"right":    tcell.KeyRight,
+	"home":     tcell.KeyHome,
+	"end":      tcell.KeyEnd,
+	"pageup":   tcell.KeyPgUp,
+	"pagedown": tcell.KeyPgDn,
+}
+
+// ParseKeys converts vim key notation ("ihello<Esc>:w<CR>") to key events.
+// Names in <> are case-insensitive; a "<" that doesn't start a name is literal.
+func ParseKeys(keys string) ([]*tcell.EventKey, error) {
+	var events []*tcell.EventKey
+	for len(keys) > 0 {
+		if keys[0] == '<' {
+			if end := strings.IndexByte(keys, '>'); end > 1 {
+				ev, err := namedKey(keys[1:end])
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, ev)
+				keys = keys[end+1:]
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(keys)
+		events = append(events, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+		keys = keys[size:]
+	}
+	return events, nil
+}
+
+func namedKey(name string) (*tcell.EventKey, error) {
+	lower := strings.ToLower(name)
+	switch lower {
+	case "space":
+		return tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone), nil
+	case "lt":
+		return tcell.NewEventKey(tcell.KeyRune, '<', tcell.ModNone), nil
+	}
+	if key, ok := namedKeys[lower]; ok {
+		return tcell.NewEventKey(key, 0, tcell.ModNone), nil
+	}
+	if len(lower) == 3 && strings.HasPrefix(lower, "c-") && lower[2] >= 'a' && lower[2] <= 'z' {
+		// Terminals report Ctrl+letter as a control character
+		return tcell.NewEventKey(tcell.KeyCtrlA+tcell.Key(lower[2]-'a'), 0, tcell.ModNone), nil
+	}
+	if strings.HasPrefix(lower, "a-") && utf8.RuneCountInString(name) == 3 {
+		r, _ := utf8.DecodeRuneInString(name[2:])
+		return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModAlt), nil
+	}
+	return nil, fmt.Errorf("unknown key <%s>", name)
+}
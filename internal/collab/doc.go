@@ -0,0 +1,287 @@
+// Package collab lets two qedit instances edit one buffer together over a
+// socket. The text is kept as a sequence CRDT (RGA): every character has
+// an ID that is unique across both sides and is inserted after the
+// character that preceded it where it was typed, so edits made at the
+// same time merge into the same text on both sides, whatever order they
+// arrive in. Deleted characters stay as tombstones that later edits can
+// still refer to. An op arriving before the character it refers to waits
+// until that character comes.
+package collab
+
+import "strings"
+
+// ID names a character: the site that typed it and its Lamport clock.
+// The zero ID is the start of the document.
+type ID struct {
+	Site  uint32 `json:"s"`
+	Clock uint64 `json:"c"`
+}
+
+// follows reports whether a sorts after b among inserts at one position:
+// newer clocks first, the higher site on a tie
+func (a ID) follows(b ID) bool {
+	if a.Clock != b.Clock {
+		return a.Clock > b.Clock
+	}
+	return a.Site > b.Site
+}
+
+// Char is a character of the document, deleted ones included
+type Char struct {
+	ID      ID   `json:"id"`
+	R       rune `json:"r"`
+	Deleted bool `json:"d,omitempty"`
+}
+
+// Op is one change to the document: inserting R after the character
+// After, or with Delete, deleting the character ID
+type Op struct {
+	ID     ID   `json:"id"`
+	After  ID   `json:"after,omitempty"`
+	R      rune `json:"r,omitempty"`
+	Delete bool `json:"del,omitempty"`
+}
+
+// Edit is the change an applied op makes to the visible text: Delete
+// runes removed at Offset, then Text inserted there. Offsets count runes,
+// a line break being one.
+type Edit struct {
+	Offset int
+	Delete int
+	Text   string
+}
+
+// Doc is the document as one site sees it
+type Doc struct {
+	site    uint32
+	clock   uint64
+	chars   []Char
+	pending []Op // ops waiting for the character they refer to
+}
+
+// NewDoc returns a document holding text, its characters typed by site
+func NewDoc(site uint32, text string) *Doc {
+	d := &Doc{site: site}
+	for _, r := range text {
+		d.clock++
+		d.chars = append(d.chars, Char{ID: ID{Site: site, Clock: d.clock}, R: r})
+	}
+	return d
+}
+
+// docFromChars returns the document of another site's snapshot, as seen
+// by site
+func docFromChars(site uint32, chars []Char) *Doc {
+	d := &Doc{site: site, chars: chars}
+	for _, c := range chars {
+		d.clock = max(d.clock, c.ID.Clock)
+	}
+	return d
+}
+
+// Text returns the visible text
+func (d *Doc) Text() string {
+	var sb strings.Builder
+	for _, c := range d.chars {
+		if !c.Deleted {
+			sb.WriteRune(c.R)
+		}
+	}
+	return sb.String()
+}
+
+// Chars returns a copy of all characters, for a peer joining
+func (d *Doc) Chars() []Char {
+	return append([]Char(nil), d.chars...)
+}
+
+// Change records a local edit: oldLen runes at row, col replaced by text.
+// It returns the ops that make the same change on the other side.
+func (d *Doc) Change(row, col, oldLen int, text string) []Op {
+	var ops []Op
+	i := d.indexAt(row, col)
+	for n := 0; n < oldLen && i < len(d.chars); i++ {
+		if d.chars[i].Deleted {
+			continue
+		}
+		d.chars[i].Deleted = true
+		ops = append(ops, Op{ID: d.chars[i].ID, Delete: true})
+		n++
+	}
+	i = d.indexAt(row, col)
+	var after ID
+	if i > 0 {
+		after = d.chars[i-1].ID
+	}
+	for _, r := range text {
+		d.clock++
+		op := Op{ID: ID{Site: d.site, Clock: d.clock}, After: after, R: r}
+		d.insert(op)
+		ops = append(ops, op)
+		after = op.ID
+	}
+	return ops
+}
+
+// Apply applies ops from the other side and returns the edits they make
+// to the visible text, in order. Ops already applied are skipped, and ops
+// referring to a character not seen yet wait for it.
+func (d *Doc) Apply(ops []Op) []Edit {
+	var edits []Edit
+	for _, op := range ops {
+		d.clock = max(d.clock, op.ID.Clock)
+		var ok bool
+		if edits, ok = d.apply(op, edits); !ok {
+			d.pending = append(d.pending, op)
+		}
+	}
+	// Each op applied can let waiting ones through
+	for progress := true; progress && len(d.pending) > 0; {
+		progress = false
+		waiting := d.pending[:0]
+		for _, op := range d.pending {
+			var ok bool
+			if edits, ok = d.apply(op, edits); ok {
+				progress = true
+			} else {
+				waiting = append(waiting, op)
+			}
+		}
+		d.pending = waiting
+	}
+	return edits
+}
+
+// apply applies op, adding its edit to edits. It fails when the character
+// op refers to isn't known yet.
+func (d *Doc) apply(op Op, edits []Edit) ([]Edit, bool) {
+	if op.Delete {
+		i := d.index(op.ID)
+		if i < 0 {
+			return edits, false
+		}
+		if d.chars[i].Deleted {
+			return edits, true
+		}
+		d.chars[i].Deleted = true
+		return addEdit(edits, Edit{Offset: d.offset(i), Delete: 1}), true
+	}
+	if d.index(op.ID) >= 0 {
+		return edits, true
+	}
+	i, ok := d.insert(op)
+	if !ok {
+		return edits, false
+	}
+	return addEdit(edits, Edit{Offset: d.offset(i), Text: string(op.R)}), true
+}
+
+// addEdit appends e to edits, merging it into the last edit when it
+// continues the same insert or delete
+func addEdit(edits []Edit, e Edit) []Edit {
+	if n := len(edits); n > 0 {
+		last := &edits[n-1]
+		if e.Delete > 0 && last.Text == "" && e.Offset == last.Offset {
+			last.Delete += e.Delete
+			return edits
+		}
+		if e.Text != "" && last.Delete == 0 && e.Offset == last.Offset+len([]rune(last.Text)) {
+			last.Text += e.Text
+			return edits
+		}
+	}
+	return append(edits, e)
+}
+
+// insert places the character of op right after op.After, past the
+// characters inserted there with a later ID, and returns its index. It
+// fails when After isn't known.
+func (d *Doc) insert(op Op) (int, bool) {
+	i := 0
+	if op.After != (ID{}) {
+		i = d.index(op.After) + 1
+		if i == 0 {
+			return 0, false
+		}
+	}
+	for i < len(d.chars) && d.chars[i].ID.follows(op.ID) {
+		i++
+	}
+	d.chars = append(d.chars, Char{})
+	copy(d.chars[i+1:], d.chars[i:])
+	d.chars[i] = Char{ID: op.ID, R: op.R}
+	return i, true
+}
+
+// index returns the index of the character id, or -1
+func (d *Doc) index(id ID) int {
+	for i, c := range d.chars {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// offset returns the visible offset of the character at index i
+func (d *Doc) offset(i int) int {
+	n := 0
+	for _, c := range d.chars[:i] {
+		if !c.Deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// indexAt returns the index of the first visible character at or after
+// row, col of the visible text, or len(chars) at its end
+func (d *Doc) indexAt(row, col int) int {
+	r, c := 0, 0
+	for i, ch := range d.chars {
+		if ch.Deleted {
+			continue
+		}
+		if r > row || (r == row && c >= col) {
+			return i
+		}
+		if ch.R == '\n' {
+			r, c = r+1, 0
+		} else {
+			c++
+		}
+	}
+	return len(d.chars)
+}
+
+// Anchor returns the ID of the visible character at row, col, zero at the
+// end of the text. It keeps a place through the other side's edits.
+func (d *Doc) Anchor(row, col int) ID {
+	if i := d.indexAt(row, col); i < len(d.chars) {
+		return d.chars[i].ID
+	}
+	return ID{}
+}
+
+// Position returns the row and column of an anchor. A deleted character
+// stands for the first visible one after it.
+func (d *Doc) Position(anchor ID) (int, int) {
+	end := len(d.chars)
+	if anchor != (ID{}) {
+		if i := d.index(anchor); i >= 0 {
+			end = i
+		}
+	}
+	row, col := 0, 0
+	for _, c := range d.chars[:end] {
+		if c.Deleted {
+			continue
+		}
+		if c.R == '\n' {
+			row, col = row+1, 0
+		} else {
+			col++
+		}
+	}
+	return row, col
+}
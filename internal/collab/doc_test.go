@@ -0,0 +1,171 @@
+package collab
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apply replays edits on text, as the editor does on its buffer
+func apply(text string, edits []Edit) string {
+	r := []rune(text)
+	for _, e := range edits {
+		r = append(r[:e.Offset:e.Offset], append([]rune(e.Text), r[e.Offset+e.Delete:]...)...)
+	}
+	return string(r)
+}
+
+func TestDocConcurrentEditsConverge(t *testing.T) {
+	host := NewDoc(hostSite, "ab\ncd")
+	peer := docFromChars(peerSite, host.Chars())
+
+	// Both type at the start of line 2 and delete around it at once
+	hostOps := append(host.Change(1, 0, 0, "XY"), host.Change(0, 1, 1, "")...)
+	peerOps := append(peer.Change(1, 0, 0, "12"), peer.Change(1, 3, 1, "")...)
+
+	hostText, peerText := host.Text(), peer.Text()
+	hostText = apply(hostText, host.Apply(peerOps))
+	peerText = apply(peerText, peer.Apply(hostOps))
+
+	if host.Text() != peer.Text() {
+		t.Fatalf("documents differ: %q vs %q", host.Text(), peer.Text())
+	}
+	if hostText != host.Text() || peerText != peer.Text() {
+		t.Fatalf("edits don't replay the documents: %q, %q, want %q", hostText, peerText, host.Text())
+	}
+	// The peer's insert has the higher site on an equal clock and goes first
+	if want := "a\n12XYc"; host.Text() != want {
+		t.Fatalf("text = %q, want %q", host.Text(), want)
+	}
+
+	// Applying the same ops twice changes nothing
+	if edits := host.Apply(peerOps); len(edits) != 0 {
+		t.Fatalf("duplicate ops made edits %+v", edits)
+	}
+}
+
+func TestDocEditsMerge(t *testing.T) {
+	host := NewDoc(hostSite, "hello")
+	peer := docFromChars(peerSite, host.Chars())
+	ops := append(peer.Change(0, 5, 0, " world"), peer.Change(0, 0, 2, "")...)
+	want := []Edit{{Offset: 5, Text: " world"}, {Offset: 0, Delete: 2}}
+	if got := host.Apply(ops); !reflect.DeepEqual(got, want) {
+		t.Fatalf("edits = %+v, want %+v", got, want)
+	}
+}
+
+func TestDocOpsOutOfOrder(t *testing.T) {
+	host := NewDoc(hostSite, "")
+	peer := docFromChars(peerSite, nil)
+	ops := append(host.Change(0, 0, 0, "ab"), host.Change(0, 0, 1, "")...)
+
+	// The delete of a and b's insert after it come before a does
+	if edits := peer.Apply([]Op{ops[2], ops[1]}); len(edits) != 0 || len(peer.pending) != 2 {
+		t.Fatalf("edits %+v, %d ops waiting", edits, len(peer.pending))
+	}
+	edits := peer.Apply(ops[:1])
+	if peer.Text() != "b" || apply("", edits) != "b" || len(peer.pending) != 0 {
+		t.Fatalf("text %q, edits %+v, %d ops waiting", peer.Text(), edits, len(peer.pending))
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		"7411":           "127.0.0.1:7411",
+		":7411":          "127.0.0.1:7411",
+		"localhost:7411": "localhost:7411",
+		"0.0.0.0:7411":   "0.0.0.0:7411",
+	} {
+		if got, err := listenAddr(addr); err != nil || got != want {
+			t.Errorf("listenAddr(%q) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+}
+
+func TestDocAnchor(t *testing.T) {
+	d := NewDoc(hostSite, "one\ntwo")
+	anchor := d.Anchor(1, 1) // the w
+	d.Change(0, 0, 0, "zero\n")
+	if row, col := d.Position(anchor); row != 2 || col != 1 {
+		t.Fatalf("anchor at %d:%d, want 2:1", row, col)
+	}
+	d.Change(2, 0, 2, "")
+	if row, col := d.Position(anchor); row != 2 || col != 0 {
+		t.Fatalf("deleted anchor at %d:%d, want 2:0", row, col)
+	}
+	if row, col := d.Position(ID{}); row != 2 || col != 1 {
+		t.Fatalf("end anchor at %d:%d, want 2:1", row, col)
+	}
+}
+
+// poll polls s until cond holds or a second passed
+func poll(t *testing.T, s *Session, cond func(Update) bool) Update {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if u := s.Poll(); cond(u) {
+			return u
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timed out")
+	return Update{}
+}
+
+func TestSession(t *testing.T) {
+	host, err := Host("127.0.0.1:0", "main.go", "package main\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	peer, err := Join(host.Addr(), host.Token(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	poll(t, host, func(u Update) bool { return u.Status != "" })
+	u := poll(t, peer, func(u Update) bool { return u.Reset != nil })
+	if *u.Reset != "package main\n" || peer.Name() != "main.go" {
+		t.Fatalf("joined with %q of %q", *u.Reset, peer.Name())
+	}
+
+	host.Change(1, 0, 0, "func main() {}")
+	host.SetCursor(1, 5)
+	u = poll(t, peer, func(u Update) bool { return len(u.Edits) > 0 })
+	if want := []Edit{{Offset: 13, Text: "func main() {}"}}; !reflect.DeepEqual(u.Edits, want) {
+		t.Fatalf("edits = %+v, want %+v", u.Edits, want)
+	}
+	poll(t, peer, func(Update) bool { _, _, ok := peer.PeerCursor(); return ok })
+	if row, col, _ := peer.PeerCursor(); row != 1 || col != 5 {
+		t.Fatalf("host cursor at %d:%d, want 1:5", row, col)
+	}
+
+	peer.Close()
+	if u := poll(t, host, func(u Update) bool { return u.Status != "" }); u.Status != "peer left" {
+		t.Fatalf("status = %q", u.Status)
+	}
+}
+
+func TestSessionWrongToken(t *testing.T) {
+	host, err := Host("127.0.0.1:0", "main.go", "secret\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+	peer, err := Join(host.Addr(), "guess", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	u := poll(t, host, func(u Update) bool { return u.Status != "" })
+	if !strings.HasSuffix(u.Status, ": wrong token") || host.Connected() {
+		t.Fatalf("status = %q, connected %v", u.Status, host.Connected())
+	}
+	u = poll(t, peer, func(u Update) bool { return u.Status != "" })
+	if u.Reset != nil || u.Status != "disconnected from host" {
+		t.Fatalf("peer got %+v", u)
+	}
+}
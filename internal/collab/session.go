@@ -0,0 +1,341 @@
+package collab
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Sites of the two sides: the host types as hostSite, the peer that
+// joins as peerSite
+const (
+	hostSite uint32 = 1
+	peerSite uint32 = 2
+)
+
+// helloTimeout is how long the host waits for a peer that connected to
+// send the token before hanging up
+const helloTimeout = 10 * time.Second
+
+// message is one line of the protocol, JSON encoded
+type message struct {
+	Type   string `json:"type"` // hello, welcome, ops or cursor
+	Token  string `json:"token,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Chars  []Char `json:"chars,omitempty"`
+	Ops    []Op   `json:"ops,omitempty"`
+	Cursor ID     `json:"cursor,omitempty"`
+}
+
+// event is what the network goroutines hand to the UI goroutine: a
+// message or the end of connection from, or a new connection
+type event struct {
+	from net.Conn
+	msg  *message
+	conn net.Conn
+	err  error
+}
+
+// Update is what Poll found: the text to start from after joining,
+// edits from the other side and a status to show
+type Update struct {
+	Reset  *string
+	Edits  []Edit
+	Status string
+}
+
+// Session is one side of a shared buffer. Its methods are called from the
+// editor's goroutine; the network is read and written in the background,
+// and wake is called when Poll has something to do. A peer joins with the
+// token the host made up, the first thing it sends.
+type Session struct {
+	host       bool
+	token      string
+	name       string // file name the host shares
+	doc        *Doc   // nil while joining
+	ln         net.Listener
+	conn       net.Conn
+	admitted   bool // the peer on conn sent the token
+	out        chan message
+	wake       func()
+	mu         sync.Mutex
+	inbox      []event
+	closed     bool
+	peer       ID   // the other side's cursor
+	hasPeer    bool // the other side has sent its cursor
+	cursor     ID   // our cursor as last sent
+	cursorSent bool
+}
+
+// Host shares text, the buffer of file name, on addr and waits for the
+// other side to join with Token. An address without a host listens on
+// the loopback interface only.
+func Host(addr, name, text string, wake func()) (*Session, error) {
+	addr, err := listenAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{host: true, token: token, name: name, doc: NewDoc(hostSite, text), ln: ln, wake: wake}
+	go s.accept()
+	return s, nil
+}
+
+// listenAddr puts the loopback address in an address given as a port
+// alone, so sharing on every interface has to be asked for
+func listenAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		if _, perr := net.LookupPort("tcp", addr); perr != nil {
+			return "", err
+		}
+		host, port = "", addr
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// newToken makes up the secret a peer joins with
+func newToken() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Join connects to the session hosted on addr, with the token the host
+// showed. The buffer to edit comes with the first Poll update.
+func Join(addr, token string, wake func()) (*Session, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{wake: wake}
+	s.start(conn)
+	s.admitted = true
+	s.send(message{Type: "hello", Token: token})
+	return s, nil
+}
+
+// Token returns the secret a peer joins the hosted session with
+func (s *Session) Token() string {
+	return s.token
+}
+
+// Addr returns the address the host listens on
+func (s *Session) Addr() string {
+	if s.ln == nil {
+		return ""
+	}
+	return s.ln.Addr().String()
+}
+
+// Name returns the file name the host shares
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Connected reports whether the other side is there
+func (s *Session) Connected() bool {
+	return s.conn != nil && s.admitted && s.doc != nil
+}
+
+// Close ends the session
+func (s *Session) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	s.drop()
+}
+
+// Change sends a local edit: oldLen runes at row, col replaced by text,
+// in the text before the edit
+func (s *Session) Change(row, col, oldLen int, text string) {
+	if s.doc == nil {
+		return
+	}
+	ops := s.doc.Change(row, col, oldLen, text)
+	if s.Connected() && len(ops) > 0 {
+		s.send(message{Type: "ops", Ops: ops})
+	}
+}
+
+// SetCursor sends the local cursor when it moved
+func (s *Session) SetCursor(row, col int) {
+	if !s.Connected() {
+		return
+	}
+	anchor := s.doc.Anchor(row, col)
+	if s.cursorSent && anchor == s.cursor {
+		return
+	}
+	s.cursor, s.cursorSent = anchor, true
+	s.send(message{Type: "cursor", Cursor: anchor})
+}
+
+// PeerCursor returns where the other side's cursor is
+func (s *Session) PeerCursor() (row, col int, ok bool) {
+	if !s.Connected() || !s.hasPeer {
+		return 0, 0, false
+	}
+	row, col = s.doc.Position(s.peer)
+	return row, col, true
+}
+
+// Poll handles what arrived from the network since the last call
+func (s *Session) Poll() Update {
+	s.mu.Lock()
+	events := s.inbox
+	s.inbox = nil
+	s.mu.Unlock()
+
+	var u Update
+	for _, ev := range events {
+		if ev.conn == nil && ev.from != s.conn {
+			continue // left over from a dropped connection
+		}
+		switch {
+		case ev.conn != nil:
+			if s.conn != nil {
+				_ = ev.conn.Close() // one peer at a time
+				continue
+			}
+			_ = ev.conn.SetReadDeadline(time.Now().Add(helloTimeout))
+			s.start(ev.conn)
+		case ev.err != nil:
+			admitted := s.admitted
+			s.drop()
+			if s.host {
+				if admitted {
+					u.Status = "peer left"
+				}
+			} else {
+				s.Close()
+				u.Status = "disconnected from host"
+			}
+		case s.host && !s.admitted:
+			if ev.msg.Type != "hello" || subtle.ConstantTimeCompare([]byte(ev.msg.Token), []byte(s.token)) != 1 {
+				u.Status = "refused " + s.conn.RemoteAddr().String() + ": wrong token"
+				s.drop()
+				continue
+			}
+			_ = s.conn.SetReadDeadline(time.Time{})
+			s.admitted = true
+			s.cursorSent = false
+			s.send(message{Type: "welcome", Name: s.name, Chars: s.doc.Chars()})
+			u.Status = "peer joined from " + s.conn.RemoteAddr().String()
+		case ev.msg.Type == "welcome" && !s.host && s.doc == nil:
+			s.doc = docFromChars(peerSite, ev.msg.Chars)
+			s.name = ev.msg.Name
+			text := s.doc.Text()
+			u.Reset, u.Edits = &text, nil
+			u.Status = "joined " + s.name
+		case ev.msg.Type == "ops" && s.doc != nil:
+			u.Edits = append(u.Edits, s.doc.Apply(ev.msg.Ops)...)
+		case ev.msg.Type == "cursor":
+			s.peer, s.hasPeer = ev.msg.Cursor, true
+		}
+	}
+	return u
+}
+
+// Closed reports whether the session ended
+func (s *Session) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// start reads and writes conn in the background
+func (s *Session) start(conn net.Conn) {
+	s.conn = conn
+	s.out = make(chan message, 256)
+	go s.read(conn)
+	go write(conn, s.out)
+}
+
+// drop forgets the connection to the other side
+func (s *Session) drop() {
+	if s.conn == nil {
+		return
+	}
+	_ = s.conn.Close()
+	close(s.out)
+	s.conn, s.out = nil, nil
+	s.admitted, s.hasPeer = false, false
+}
+
+// send queues msg for the writer, dropping the connection when the other
+// side doesn't keep up
+func (s *Session) send(msg message) {
+	select {
+	case s.out <- msg:
+	default:
+		s.post(event{from: s.conn, err: errors.New("peer too slow")})
+	}
+}
+
+func (s *Session) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.post(event{conn: conn})
+	}
+}
+
+func (s *Session) read(conn net.Conn) {
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			s.post(event{from: conn, err: err})
+			return
+		}
+		s.post(event{from: conn, msg: &msg})
+	}
+}
+
+func write(conn net.Conn, out <-chan message) {
+	enc := json.NewEncoder(conn)
+	for msg := range out {
+		if err := enc.Encode(msg); err != nil {
+			_ = conn.Close() // the reader sees it and reports the end
+			return
+		}
+	}
+}
+
+// post hands ev to Poll and wakes the editor up
+func (s *Session) post(ev event) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.inbox = append(s.inbox, ev)
+	s.mu.Unlock()
+	if s.wake != nil {
+		s.wake()
+	}
+}
@@ -13,13 +13,30 @@ type Keymap struct {
 }
 
 type EditorOptions struct {
-	TabWidth             int    `toml:"tab-width"`
-	LineNumbers          string `toml:"line-numbers"`
-	GitBranchSymbol      string `toml:"git-branch-symbol"`
-	SidebarWidth         string `toml:"sidebar-width"`
-	SidebarMinWidth      int    `toml:"sidebar-min-width"`
-	SidebarMaxWidth      string `toml:"sidebar-max-width"`
-	SidebarCloseOnSelect bool   `toml:"sidebar-close-on-select"`
+	TabWidth             int      `toml:"tab-width"`
+	IndentWidth          int      `toml:"indent-width"` // columns per indent level with spaces; 0 uses tab-width
+	ExpandTab            bool     `toml:"expand-tab"`   // indent with spaces instead of tabs
+	LineNumbers          string   `toml:"line-numbers"`
+	GitBranchSymbol      string   `toml:"git-branch-symbol"`
+	SidebarWidth         string   `toml:"sidebar-width"`
+	SidebarMinWidth      int      `toml:"sidebar-min-width"`
+	SidebarMaxWidth      string   `toml:"sidebar-max-width"`
+	SidebarCloseOnSelect bool     `toml:"sidebar-close-on-select"`
+	FileTreeShowHidden   bool     `toml:"file-tree-show-hidden"`
+	FileTreeShowIgnored  bool     `toml:"file-tree-show-ignored"`
+	Ignore               []string `toml:"ignore"` // extra gitignore-style patterns
+	AnsiColors           bool     `toml:"ansi-colors"` // render ANSI color codes in files that contain them
+	StatusColumn         string   `toml:"status-column"` // "visual", "char", "byte" or "all"
+	KeyTimeout           int      `toml:"key-timeout"`   // ms a key sequence (g, Space, f...) waits for its next key; 0 waits forever
+	WhichKeyDelay        int      `toml:"which-key-delay"` // ms before the menu of a pending key sequence is shown; 0 shows it at once
+	Bufferline           bool     `toml:"bufferline"`    // show open buffers as tabs above the text
+	Subword              bool     `toml:"subword"`       // w/b/e stop at camelCase and snake_case parts
+	FindLines            bool     `toml:"find-lines"`    // f/t continue onto other lines for every char, not just brackets
+	Autocorrect          bool     `toml:"autocorrect"`   // expand [abbreviations] as words are typed in markdown and text files
+	Langmap              string   `toml:"langmap"`       // layout characters read as QWERTY keys in normal mode: "ru,uk", "FROM;TO"; "" or "off" reads none
+	UndoHistoryAutosave  int      `toml:"undo-history-autosave"` // seconds between writes of the undo history while editing; 0 writes it only on save
+	SingleInstance       bool     `toml:"single-instance"`       // `qedit file` hands the file to a qedit running in the same project
+	DropBOM              bool     `toml:"drop-bom"`              // save files without the UTF-8 byte order mark they were read with
 }
 
 type Theme struct {
@@ -71,16 +88,25 @@ type Theme struct {
 	SidebarIndicatorForeground     string `toml:"sidebar-indicator-foreground"`
 	SidebarHotkeyForeground        string `toml:"sidebar-hotkey-foreground"`
 	SidebarUnavailableForeground   string `toml:"sidebar-unavailable-foreground"`
+	DiagnosticErrorForeground      string `toml:"diagnostic-error-foreground"`
+	DiagnosticWarningForeground    string `toml:"diagnostic-warning-foreground"`
+	DiagnosticInfoForeground       string `toml:"diagnostic-info-foreground"`
+	DiagnosticHintForeground       string `toml:"diagnostic-hint-foreground"`
 }
 
 type Config struct {
-	Editor EditorOptions `toml:"editor"`
-	Theme  Theme         `toml:"theme"`
-	Keymap Keymap        `toml:"keymap"`
+	Editor   EditorOptions   `toml:"editor"`
+	Theme    Theme           `toml:"theme"`
+	Keymap   Keymap          `toml:"keymap"`
+	Terminal TerminalOptions `toml:"terminal"`
+	// Abbreviations maps typed words to their replacement (see autocorrect)
+	Abbreviations map[string]string `toml:"abbreviations"`
+	// Plugins maps plugin names to the command that runs them (pkg/plugin)
+	Plugins map[string][]string `toml:"plugins"`
 }
 
 func Default() Config {
-	return Config{
+	cfg := Config{
 		Editor: EditorOptions{
 			TabWidth:             4,
 			LineNumbers:          "absolute",
@@ -131,6 +157,24 @@ func Default() Config {
 			SidebarIndicatorForeground:   "#E6B450",
 			SidebarHotkeyForeground:      "#59C2FF",
 			SidebarUnavailableForeground: "#3E4B59",
+			DiagnosticErrorForeground:    "#FF3333",
+			DiagnosticWarningForeground:  "#FFB454",
+			DiagnosticInfoForeground:     "#59C2FF",
+			DiagnosticHintForeground:     "#95E6CB",
+		},
+		Abbreviations: map[string]string{
+			"teh":        "the",
+			"adn":        "and",
+			"taht":       "that",
+			"wich":       "which",
+			"thier":      "their",
+			"becuase":    "because",
+			"recieve":    "receive",
+			"seperate":   "separate",
+			"definately": "definitely",
+			"occured":    "occurred",
+			"untill":     "until",
+			"alot":       "a lot",
 		},
 		Keymap: Keymap{
 			Normal: map[string]string{
@@ -142,8 +186,8 @@ func Default() Config {
 				"down":           "move_down",
 				"up":             "move_up",
 				"right":          "move_right",
-				"home":           "line_start",
-				"end":            "line_end",
+				"home":           "smart_home",
+				"end":            "smart_end",
 				"cmd+home":       "file_start",
 				"cmd+end":        "file_end",
 				"cmd+left":       "word_left",
@@ -173,6 +217,8 @@ func Default() Config {
 				"shift+tab":      "unindent",
 				"cmd+a":          "select_all",
 				"cmd+g":          "goto_line_prompt",
+				"ctrl+k":         "diagnostic_float",
+				"alt+z":          "toggle_fold",
 
 				// Helix-style motions
 				"w":              "word_forward",
@@ -184,6 +230,7 @@ func Default() Config {
 				"F":              "find_char_backward",
 				"t":              "till_char",
 				"T":              "till_char_backward",
+				"alt+.":          "repeat_find_char",
 
 				// Helix-style editing
 				"d":              "delete",
@@ -198,15 +245,30 @@ func Default() Config {
 				"I":              "insert_line_start",
 				"r":              "replace_char",
 				"J":              "join_lines",
+				"Q":              "record_macro",
+				"q":              "replay_macro",
 
 				// Helix-style selection
 				"v":              "toggle_select",
 				"x":              "extend_line",
 				";":              "collapse_selection",
+				"_":              "trim_selection",
+				"X":              "extend_to_line_bounds",
+				"alt+x":          "shrink_to_line_bounds",
 				"%":              "select_all",
 				">":              "indent",
 				"<":              "unindent",
 
+				// Multiple selections
+				"s":              "select_regex",
+				"K":              "keep_selections",
+				"alt+K":          "remove_selections",
+				"alt+s":          "split_selection_on_newline",
+				",":              "keep_primary_selection",
+				"alt+,":          "remove_primary_selection",
+				"(":              "rotate_selections_backward",
+				")":              "rotate_selections_forward",
+
 				// Space mode
 				"space":          "space_mode",
 
@@ -216,6 +278,10 @@ func Default() Config {
 				// View mode
 				"z":              "view_mode",
 
+				// Next/previous mode
+				"]":              "next_mode",
+				"[":              "prev_mode",
+
 				// Search
 				"/":              "search_forward",
 				"?":              "search_backward",
@@ -236,6 +302,7 @@ func Default() Config {
 
 				// File operations
 				"cmd+s":          "save",
+				"cmd+shift+t":    "reopen_buffer",
 			},
 			Insert: map[string]string{
 				"esc":            "enter_normal",
@@ -243,8 +310,8 @@ func Default() Config {
 				"down":           "move_down",
 				"up":             "move_up",
 				"right":          "move_right",
-				"home":           "line_start",
-				"end":            "line_end",
+				"home":           "smart_home",
+				"end":            "smart_end",
 				"cmd+home":       "file_start",
 				"cmd+end":        "file_end",
 				"cmd+left":       "word_left",
@@ -270,20 +337,28 @@ func Default() Config {
 				"shift+tab":      "unindent",
 				"cmd+a":          "select_all",
 				"shift+enter":    "insert_line_above",
+				"ctrl+x":         "completion",
 
 				// File operations
 				"cmd+s":          "save",
 			},
 		},
 	}
+	addPlatformKeys(cfg.Keymap)
+	return cfg
 }
 
 func Load() (Config, error) {
-	cfg := Default()
 	path, err := ConfigPath()
 	if err != nil {
-		return cfg, err
+		return Default(), err
 	}
+	return LoadFile(path)
+}
+
+// LoadFile loads config from path on top of defaults. A missing file is not an error.
+func LoadFile(path string) (Config, error) {
+	cfg := Default()
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -300,6 +375,12 @@ func Load() (Config, error) {
 	if userCfg.Editor.TabWidth > 0 {
 		cfg.Editor.TabWidth = userCfg.Editor.TabWidth
 	}
+	if userCfg.Editor.IndentWidth > 0 {
+		cfg.Editor.IndentWidth = userCfg.Editor.IndentWidth
+	}
+	if userCfg.Editor.ExpandTab {
+		cfg.Editor.ExpandTab = userCfg.Editor.ExpandTab
+	}
 	if userCfg.Editor.LineNumbers != "" {
 		cfg.Editor.LineNumbers = userCfg.Editor.LineNumbers
 	}
@@ -318,6 +399,51 @@ func Load() (Config, error) {
 	if userCfg.Editor.SidebarCloseOnSelect {
 		cfg.Editor.SidebarCloseOnSelect = userCfg.Editor.SidebarCloseOnSelect
 	}
+	if userCfg.Editor.FileTreeShowHidden {
+		cfg.Editor.FileTreeShowHidden = userCfg.Editor.FileTreeShowHidden
+	}
+	if userCfg.Editor.FileTreeShowIgnored {
+		cfg.Editor.FileTreeShowIgnored = userCfg.Editor.FileTreeShowIgnored
+	}
+	if userCfg.Editor.Ignore != nil {
+		cfg.Editor.Ignore = userCfg.Editor.Ignore
+	}
+	if userCfg.Editor.AnsiColors {
+		cfg.Editor.AnsiColors = userCfg.Editor.AnsiColors
+	}
+	if userCfg.Editor.StatusColumn != "" {
+		cfg.Editor.StatusColumn = userCfg.Editor.StatusColumn
+	}
+	if userCfg.Editor.KeyTimeout > 0 {
+		cfg.Editor.KeyTimeout = userCfg.Editor.KeyTimeout
+	}
+	if userCfg.Editor.WhichKeyDelay > 0 {
+		cfg.Editor.WhichKeyDelay = userCfg.Editor.WhichKeyDelay
+	}
+	if userCfg.Editor.Bufferline {
+		cfg.Editor.Bufferline = userCfg.Editor.Bufferline
+	}
+	if userCfg.Editor.Subword {
+		cfg.Editor.Subword = userCfg.Editor.Subword
+	}
+	if userCfg.Editor.FindLines {
+		cfg.Editor.FindLines = userCfg.Editor.FindLines
+	}
+	if userCfg.Editor.Autocorrect {
+		cfg.Editor.Autocorrect = userCfg.Editor.Autocorrect
+	}
+	if userCfg.Editor.Langmap != "" {
+		cfg.Editor.Langmap = userCfg.Editor.Langmap
+	}
+	if userCfg.Editor.UndoHistoryAutosave > 0 {
+		cfg.Editor.UndoHistoryAutosave = userCfg.Editor.UndoHistoryAutosave
+	}
+	if userCfg.Editor.SingleInstance {
+		cfg.Editor.SingleInstance = userCfg.Editor.SingleInstance
+	}
+	if userCfg.Editor.DropBOM {
+		cfg.Editor.DropBOM = userCfg.Editor.DropBOM
+	}
 	if userCfg.Theme.Theme != "" {
 		cfg.Theme.Theme = userCfg.Theme.Theme
 	}
@@ -326,7 +452,7 @@ func Load() (Config, error) {
 		if err != nil {
 			return cfg, err
 		}
-		mergeTheme(&cfg.Theme, theme)
+		MergeTheme(&cfg.Theme, theme)
 	}
 	if userCfg.Theme.Foreground != "" {
 		cfg.Theme.Foreground = userCfg.Theme.Foreground
@@ -445,6 +571,19 @@ func Load() (Config, error) {
 	if userCfg.Theme.SidebarUnavailableForeground != "" {
 		cfg.Theme.SidebarUnavailableForeground = userCfg.Theme.SidebarUnavailableForeground
 	}
+	if userCfg.Theme.DiagnosticErrorForeground != "" {
+		cfg.Theme.DiagnosticErrorForeground = userCfg.Theme.DiagnosticErrorForeground
+	}
+	if userCfg.Theme.DiagnosticWarningForeground != "" {
+		cfg.Theme.DiagnosticWarningForeground = userCfg.Theme.DiagnosticWarningForeground
+	}
+	if userCfg.Theme.DiagnosticInfoForeground != "" {
+		cfg.Theme.DiagnosticInfoForeground = userCfg.Theme.DiagnosticInfoForeground
+	}
+	if userCfg.Theme.DiagnosticHintForeground != "" {
+		cfg.Theme.DiagnosticHintForeground = userCfg.Theme.DiagnosticHintForeground
+	}
+	cfg.Terminal = userCfg.Terminal
 	if userCfg.Keymap.Normal != nil {
 		for k, v := range userCfg.Keymap.Normal {
 			cfg.Keymap.Normal[k] = v
@@ -455,11 +594,21 @@ func Load() (Config, error) {
 			cfg.Keymap.Insert[k] = v
 		}
 	}
+	// An empty replacement drops a default abbreviation
+	for k, v := range userCfg.Abbreviations {
+		if v == "" {
+			delete(cfg.Abbreviations, k)
+		} else {
+			cfg.Abbreviations[k] = v
+		}
+	}
+	cfg.Plugins = userCfg.Plugins
 
 	return cfg, nil
 }
 
-func mergeTheme(dst *Theme, src Theme) {
+// MergeTheme sets the colors of dst that src sets
+func MergeTheme(dst *Theme, src Theme) {
 	if src.Foreground != "" {
 		dst.Foreground = src.Foreground
 	}
@@ -601,6 +750,29 @@ func mergeTheme(dst *Theme, src Theme) {
 	if src.SidebarUnavailableForeground != "" {
 		dst.SidebarUnavailableForeground = src.SidebarUnavailableForeground
 	}
+	if src.DiagnosticErrorForeground != "" {
+		dst.DiagnosticErrorForeground = src.DiagnosticErrorForeground
+	}
+	if src.DiagnosticWarningForeground != "" {
+		dst.DiagnosticWarningForeground = src.DiagnosticWarningForeground
+	}
+	if src.DiagnosticInfoForeground != "" {
+		dst.DiagnosticInfoForeground = src.DiagnosticInfoForeground
+	}
+	if src.DiagnosticHintForeground != "" {
+		dst.DiagnosticHintForeground = src.DiagnosticHintForeground
+	}
+}
+
+// ApplyTheme loads the named theme and merges it over cfg's colors
+func ApplyTheme(cfg *Config, name string) error {
+	theme, err := LoadTheme(name)
+	if err != nil {
+		return err
+	}
+	cfg.Theme.Theme = name
+	MergeTheme(&cfg.Theme, theme)
+	return nil
 }
 
 func ThemePath(name string) (string, error) {
@@ -620,14 +792,20 @@ func LoadTheme(name string) (Theme, error) {
 	if err != nil {
 		return Theme{}, err
 	}
+	return ParseTheme(string(data))
+}
+
+// ParseTheme decodes a theme file, with the colors at the top level or
+// under [theme]
+func ParseTheme(data string) (Theme, error) {
 	var t Theme
-	if _, err := toml.Decode(string(data), &t); err == nil {
+	if _, err := toml.Decode(data, &t); err == nil {
 		return t, nil
 	}
 	var wrap struct {
 		Theme Theme `toml:"theme"`
 	}
-	if _, err := toml.Decode(string(data), &wrap); err != nil {
+	if _, err := toml.Decode(data, &wrap); err != nil {
 		return Theme{}, err
 	}
 	return wrap.Theme, nil
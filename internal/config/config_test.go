@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -112,3 +113,111 @@ background = "#bbbbbb"
 		t.Fatalf("Background = %q, want %q", theme.Background, "#bbbbbb")
 	}
 }
+
+func TestLoadFileAndApplyTheme(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+
+	writeFile(t, filepath.Join(dir, "theme", "dark.toml"), `
+foreground = "#010101"
+`)
+	custom := filepath.Join(dir, "custom.toml")
+	writeFile(t, custom, `
+[editor]
+tab-width = 2
+key-timeout = 750
+which-key-delay = 300
+`)
+
+	cfg, err := LoadFile(custom)
+	if err != nil {
+		t.Fatalf("LoadFile error: %v", err)
+	}
+	if cfg.Editor.TabWidth != 2 {
+		t.Fatalf("TabWidth = %d, want 2", cfg.Editor.TabWidth)
+	}
+	if cfg.Editor.KeyTimeout != 750 {
+		t.Fatalf("KeyTimeout = %d, want 750", cfg.Editor.KeyTimeout)
+	}
+	if cfg.Editor.WhichKeyDelay != 300 {
+		t.Fatalf("WhichKeyDelay = %d, want 300", cfg.Editor.WhichKeyDelay)
+	}
+	if err := ApplyTheme(&cfg, "dark"); err != nil {
+		t.Fatalf("ApplyTheme error: %v", err)
+	}
+	if cfg.Theme.Foreground != "#010101" || cfg.Theme.Theme != "dark" {
+		t.Fatalf("theme = %q/%q, want dark/#010101", cfg.Theme.Theme, cfg.Theme.Foreground)
+	}
+	if err := ApplyTheme(&cfg, "missing"); err == nil {
+		t.Fatalf("ApplyTheme with missing theme should fail")
+	}
+}
+
+func TestLoadFileAbbreviations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, path, `
+[editor]
+autocorrect = true
+
+[abbreviations]
+brb = "be right back"
+alot = ""
+`)
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile error: %v", err)
+	}
+	if !cfg.Editor.Autocorrect {
+		t.Fatal("autocorrect not set")
+	}
+	if cfg.Abbreviations["brb"] != "be right back" || cfg.Abbreviations["teh"] != "the" {
+		t.Fatalf("abbreviations = %v", cfg.Abbreviations)
+	}
+	if _, ok := cfg.Abbreviations["alot"]; ok {
+		t.Fatal("an empty replacement should drop the default")
+	}
+}
+
+func TestDefaultKeymapPlatformKeys(t *testing.T) {
+	cfg := Default()
+	if cfg.Keymap.Normal["cmd+s"] != "save" {
+		t.Fatalf("cmd+s = %q, want save", cfg.Keymap.Normal["cmd+s"])
+	}
+	if cfg.Keymap.Normal["ctrl+c"] != "quit" || cfg.Keymap.Normal["ctrl+y"] != "scroll_up" {
+		t.Fatalf("platform keys must not override existing ctrl bindings")
+	}
+	want := "save"
+	if runtime.GOOS == "darwin" {
+		want = ""
+	}
+	if got := cfg.Keymap.Normal["ctrl+s"]; got != want {
+		t.Fatalf("normal ctrl+s = %q, want %q", got, want)
+	}
+	if got := cfg.Keymap.Insert["ctrl+s"]; got != want {
+		t.Fatalf("insert ctrl+s = %q, want %q", got, want)
+	}
+}
+
+func TestOptionsDocumented(t *testing.T) {
+	seen := map[string]bool{}
+	for _, opt := range Options() {
+		name := opt.Section + "." + opt.Key
+		if opt.Key == "" || seen[name] {
+			t.Errorf("option %q is empty or listed twice", name)
+		}
+		seen[name] = true
+		if opt.Doc == "" {
+			t.Errorf("option %q has no doc", name)
+		}
+	}
+	for _, name := range []string{"editor.tab-width", "theme.foreground", "terminal.mouse"} {
+		if !seen[name] {
+			t.Errorf("option %q missing", name)
+		}
+	}
+	for name := range optionDocs {
+		if !seen[name] {
+			t.Errorf("doc for unknown option %q", name)
+		}
+	}
+}
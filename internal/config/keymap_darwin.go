@@ -0,0 +1,6 @@
+//go:build darwin
+
+package config
+
+// addPlatformKeys is a no-op on macOS: the default keymap uses cmd+ bindings.
+func addPlatformKeys(km Keymap) {}
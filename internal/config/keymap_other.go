@@ -0,0 +1,51 @@
+//go:build !darwin
+
+package config
+
+// platformNormalKeys are ctrl+ equivalents of the cmd+ bindings for terminals
+// on Linux and Windows, where Cmd does not exist. ctrl+c (quit), ctrl+y and
+// ctrl+e (scroll) keep their terminal meaning.
+var platformNormalKeys = map[string]string{
+	"ctrl+s":     "save",
+	"ctrl+a":     "select_all",
+	"ctrl+f":     "search_fuzzy",
+	"ctrl+g":     "goto_line_prompt",
+	"ctrl+b":     "branch_picker",
+	"ctrl+l":     "toggle_line_numbers",
+	"ctrl+left":  "word_left",
+	"ctrl+right": "word_right",
+	"ctrl+up":    "move_line_up",
+	"ctrl+down":  "move_line_down",
+	"ctrl+del":   "delete_word_right",
+}
+
+// platformInsertKeys are the insert mode ctrl+ equivalents. ctrl+w deletes the
+// previous word as in shells, since ctrl+backspace is rarely reported.
+var platformInsertKeys = map[string]string{
+	"ctrl+s":         "save",
+	"ctrl+a":         "select_all",
+	"ctrl+b":         "branch_picker",
+	"ctrl+l":         "toggle_line_numbers",
+	"ctrl+left":      "word_left",
+	"ctrl+right":     "word_right",
+	"ctrl+up":        "move_line_up",
+	"ctrl+down":      "move_line_down",
+	"ctrl+backspace": "delete_word_left",
+	"ctrl+w":         "delete_word_left",
+	"ctrl+del":       "delete_word_right",
+	"ctrl+enter":     "insert_line_below",
+}
+
+// addPlatformKeys adds ctrl+ bindings without overriding existing ones.
+func addPlatformKeys(km Keymap) {
+	for k, v := range platformNormalKeys {
+		if _, ok := km.Normal[k]; !ok {
+			km.Normal[k] = v
+		}
+	}
+	for k, v := range platformInsertKeys {
+		if _, ok := km.Insert[k]; !ok {
+			km.Insert[k] = v
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// The macro library is macros.toml in the config dir: a [macros] table of
+// names and keys in <> notation ("gg/import<CR>dd"). It is plain text so it
+// can be shared and synced with the rest of the config.
+
+// MacrosPath returns the path of the macro library
+func MacrosPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "macros.toml"), nil
+}
+
+// LoadMacros reads the macro library. A missing file is an empty library.
+func LoadMacros() (map[string]string, error) {
+	path, err := MacrosPath()
+	if err != nil {
+		return nil, err
+	}
+	var lib struct {
+		Macros map[string]string `toml:"macros"`
+	}
+	if _, err := toml.DecodeFile(path, &lib); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	if lib.Macros == nil {
+		lib.Macros = map[string]string{}
+	}
+	return lib.Macros, nil
+}
+
+// SaveMacros writes the macro library, creating the config dir if needed
+func SaveMacros(macros map[string]string) error {
+	path, err := MacrosPath()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	lib := struct {
+		Macros map[string]string `toml:"macros"`
+	}{macros}
+	if err := toml.NewEncoder(&buf).Encode(lib); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Option is a key of config.toml, for completion and hover docs when
+// editing the config file
+type Option struct {
+	Section string // table the key belongs to, e.g. "editor"
+	Key     string
+	Type    string // "integer", "boolean", "string" or "string array"
+	Doc     string
+}
+
+// optionDocs describes the keys of config.toml by "section.key". Theme
+// colors that aren't listed get a description made from their key.
+var optionDocs = map[string]string{
+	"editor.tab-width":               "Columns a tab is drawn as. :tabwidth changes it for one buffer.",
+	"editor.indent-width":            "Columns per indent level when indenting with spaces; 0 uses tab-width.",
+	"editor.expand-tab":              "Tab and > indent with spaces instead of tabs. :retab converts existing indentation.",
+	"editor.line-numbers":            `Line numbers in the gutter: "absolute", "relative" or "off".`,
+	"editor.git-branch-symbol":       "Text shown before the git branch in the statusline.",
+	"editor.sidebar-width":           `Width of the sidebar: columns ("30"), a fraction ("1/4") or a percentage ("25%").`,
+	"editor.sidebar-min-width":       "Narrowest the sidebar gets, in columns.",
+	"editor.sidebar-max-width":       `Widest the sidebar gets: columns, a fraction or a percentage.`,
+	"editor.sidebar-close-on-select": "Close the sidebar when an item is opened from it.",
+	"editor.file-tree-show-hidden":   `Show dotfiles in the file tree ("." toggles it in the tree).`,
+	"editor.file-tree-show-ignored":  `Show ignored files in the file tree ("i" toggles it in the tree).`,
+	"editor.ignore":                  "Extra gitignore-style patterns hidden from the file tree, on top of .gitignore and .ignore.",
+	"editor.ansi-colors":             "Show ANSI color codes in files that contain them (CI logs) as colors; :ansi toggles it.",
+	"editor.status-column":           `Col in the statusline: "visual", "char", "byte" or "all" (:col).`,
+	"editor.key-timeout":             "Milliseconds an unfinished key sequence (g, m, z, Space, f) waits for its next key; 0 waits forever.",
+	"editor.which-key-delay":         "Milliseconds before the menu of an unfinished key sequence is shown; 0 shows it at once.",
+	"editor.bufferline":              "Show open buffers as tabs on the top row; :bufferline toggles it.",
+	"editor.subword":                 "w/b/e and word deletion stop at camelCase and snake_case parts; :subword toggles it.",
+	"editor.find-lines":              "f/t continue onto the following lines for every character, not just brackets and quotes; :findlines toggles it.",
+	"editor.autocorrect":             "Replace words from [abbreviations] (teh -> the) as they are typed in markdown and text files; :autocorrect toggles it.",
+	"editor.langmap":                 `Characters of other keyboard layouts read as the QWERTY keys in the same places in normal mode, so commands work without switching layouts: built-in "ru" and "uk", "FROM;TO" strings, character pairs ("шi"), comma-separated; unset or "off" reads none. A spec that can't be read is a config error.`,
+	"editor.undo-history-autosave":   "Seconds between writes of the undo history while editing. The text of unsaved edits goes with it, so after a crash :recover restores them; 0 writes it only when the file is saved.",
+	"editor.single-instance":         "Running qedit on a file while another qedit runs in the same project (git repository, or else directory) opens the file as a buffer there instead of starting a second editor.",
+	"editor.drop-bom":                "Save files that start with a UTF-8 byte order mark without it. Otherwise the mark is kept out of the buffer, shown as [BOM] in the statusline and written back on save; :bom on|off sets it per buffer.",
+
+	"theme.theme": "Theme file to load from the themes directory; colors set here override it.",
+
+	"terminal.profile":           `Terminal profile for the workarounds below: "auto" detects it, or ghostty, kitty, wezterm, iterm2, apple-terminal, vscode, tmux, windows-terminal, generic.`,
+	"terminal.mouse":             `Mouse support: "all", "drag", "click" or "off".`,
+	"terminal.bracketed-paste":   "Paste text literally in insert mode.",
+	"terminal.undercurl":         "Curly underlines under the text diagnostics point at; off draws straight ones.",
+	"terminal.alt-as-cmd":        "Alt+key triggers cmd+key bindings, for terminals without a Cmd key.",
+	"terminal.meta-sends-escape": "The Alt/Option key sends Esc before the key. Off, Esc typed just before a key is taken as Esc and then the key, not as Alt+key.",
+}
+
+// Options lists the keys of config.toml read from the toml tags of Config,
+// section by section. Keymap tables are left out: their keys are key names.
+func Options() []Option {
+	var opts []Option
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		section := tomlName(t.Field(i))
+		st := t.Field(i).Type
+		if st.Kind() != reflect.Struct || section == "keymap" {
+			continue
+		}
+		for j := 0; j < st.NumField(); j++ {
+			f := st.Field(j)
+			key := tomlName(f)
+			opt := Option{Section: section, Key: key, Type: optionType(f.Type), Doc: optionDocs[section+"."+key]}
+			if opt.Doc == "" && section == "theme" {
+				opt.Doc = "Theme color for " + strings.ReplaceAll(key, "-", " ") + `: a color name or "#rrggbb".`
+			}
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// Sections returns the tables of config.toml
+func Sections() []string {
+	var sections []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := tomlName(t.Field(i)); name == "keymap" {
+			sections = append(sections, "keymap.normal", "keymap.insert")
+		} else {
+			sections = append(sections, name)
+		}
+	}
+	return sections
+}
+
+func tomlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	return name
+}
+
+func optionType(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int:
+		return "integer"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice:
+		return "string array"
+	}
+	return "string"
+}
@@ -0,0 +1,108 @@
+package config
+
+import "strings"
+
+// TerminalOptions is the [terminal] config section. Unset values come from
+// the detected (or configured) terminal profile.
+type TerminalOptions struct {
+	Profile         string `toml:"profile"`           // "auto" or a profile name
+	Mouse           string `toml:"mouse"`             // "all", "drag", "click", "off"
+	BracketedPaste  *bool  `toml:"bracketed-paste"`   // enable bracketed paste mode
+	Undercurl       *bool  `toml:"undercurl"`         // curly underlines for diagnostics
+	AltAsCmd        *bool  `toml:"alt-as-cmd"`        // accept alt+key for cmd+key bindings
+	MetaSendsEscape *bool  `toml:"meta-sends-escape"` // the Alt/Option key sends Esc before the key
+}
+
+// TerminalFeatures are the resolved terminal workarounds for this session.
+type TerminalFeatures struct {
+	Profile         string
+	Mouse           string
+	BracketedPaste  bool
+	Undercurl       bool
+	AltAsCmd        bool
+	MetaSendsEscape bool
+}
+
+// terminalProfiles holds defaults for known terminals. Terminals that cannot
+// report the Cmd modifier get AltAsCmd so that cmd+ bindings stay reachable.
+// Terminal.app's Option key types characters unless "Use Option as Meta
+// key" is set, so there Esc followed quickly by a key isn't Alt+key.
+var terminalProfiles = map[string]TerminalFeatures{
+	"ghostty":          {Mouse: "all", BracketedPaste: true, Undercurl: true, AltAsCmd: false, MetaSendsEscape: true},
+	"kitty":            {Mouse: "all", BracketedPaste: true, Undercurl: true, AltAsCmd: false, MetaSendsEscape: true},
+	"wezterm":          {Mouse: "all", BracketedPaste: true, Undercurl: true, AltAsCmd: true, MetaSendsEscape: true},
+	"iterm2":           {Mouse: "all", BracketedPaste: true, Undercurl: true, AltAsCmd: true, MetaSendsEscape: true},
+	"apple-terminal":   {Mouse: "all", BracketedPaste: true, Undercurl: false, AltAsCmd: true, MetaSendsEscape: false},
+	"vscode":           {Mouse: "all", BracketedPaste: true, Undercurl: true, AltAsCmd: true, MetaSendsEscape: true},
+	"tmux":             {Mouse: "all", BracketedPaste: true, Undercurl: false, AltAsCmd: true, MetaSendsEscape: true},
+	"windows-terminal": {Mouse: "all", BracketedPaste: true, Undercurl: true, AltAsCmd: true, MetaSendsEscape: true},
+	"generic":          {Mouse: "all", BracketedPaste: false, Undercurl: false, AltAsCmd: false, MetaSendsEscape: true},
+}
+
+// DetectTerminalProfile guesses the terminal from environment variables
+func DetectTerminalProfile(getenv func(string) string) string {
+	// Multiplexers hide the outer terminal and define the key handling
+	if getenv("TMUX") != "" {
+		return "tmux"
+	}
+	if getenv("WT_SESSION") != "" {
+		return "windows-terminal"
+	}
+	switch strings.ToLower(getenv("TERM_PROGRAM")) {
+	case "ghostty":
+		return "ghostty"
+	case "iterm.app":
+		return "iterm2"
+	case "apple_terminal":
+		return "apple-terminal"
+	case "wezterm":
+		return "wezterm"
+	case "vscode":
+		return "vscode"
+	case "tmux":
+		return "tmux"
+	}
+	if strings.Contains(getenv("TERM"), "kitty") {
+		return "kitty"
+	}
+	return "generic"
+}
+
+// ResolveTerminal merges the profile defaults with explicit [terminal] options
+func ResolveTerminal(opts TerminalOptions, getenv func(string) string) TerminalFeatures {
+	name := strings.ToLower(strings.TrimSpace(opts.Profile))
+	if name == "" || name == "auto" {
+		name = DetectTerminalProfile(getenv)
+	}
+	features, ok := terminalProfiles[name]
+	if !ok {
+		name = "generic"
+		features = terminalProfiles[name]
+	}
+	features.Profile = name
+	switch strings.ToLower(opts.Mouse) {
+	case "all", "drag", "click", "off":
+		features.Mouse = strings.ToLower(opts.Mouse)
+	}
+	if opts.BracketedPaste != nil {
+		features.BracketedPaste = *opts.BracketedPaste
+	}
+	if opts.Undercurl != nil {
+		features.Undercurl = *opts.Undercurl
+	}
+	if opts.AltAsCmd != nil {
+		features.AltAsCmd = *opts.AltAsCmd
+	}
+	if opts.MetaSendsEscape != nil {
+		features.MetaSendsEscape = *opts.MetaSendsEscape
+	}
+	return features
+}
+
+// GenericTerminal returns the features of the generic profile, for an
+// editor not told which terminal it runs in
+func GenericTerminal() TerminalFeatures {
+	features := terminalProfiles["generic"]
+	features.Profile = "generic"
+	return features
+}
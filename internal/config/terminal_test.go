@@ -0,0 +1,70 @@
+package config
+
+import "testing"
+
+func envMap(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func TestDetectTerminalProfile(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"TERM_PROGRAM": "ghostty"}, "ghostty"},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, "iterm2"},
+		{map[string]string{"TERM_PROGRAM": "Apple_Terminal"}, "apple-terminal"},
+		{map[string]string{"TERM_PROGRAM": "ghostty", "TMUX": "/tmp/tmux-1/default"}, "tmux"},
+		{map[string]string{"WT_SESSION": "abc"}, "windows-terminal"},
+		{map[string]string{"TERM": "xterm-kitty"}, "kitty"},
+		{map[string]string{}, "generic"},
+	}
+	for _, tt := range tests {
+		if got := DetectTerminalProfile(envMap(tt.env)); got != tt.want {
+			t.Fatalf("DetectTerminalProfile(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTerminalOverrides(t *testing.T) {
+	env := envMap(map[string]string{"TERM_PROGRAM": "iTerm.app"})
+	features := ResolveTerminal(TerminalOptions{}, env)
+	if features.Profile != "iterm2" || !features.AltAsCmd || features.Mouse != "all" {
+		t.Fatalf("auto features = %+v", features)
+	}
+
+	off := false
+	features = ResolveTerminal(TerminalOptions{
+		Profile:         "tmux",
+		Mouse:           "off",
+		AltAsCmd:        &off,
+		MetaSendsEscape: &off,
+	}, env)
+	if features.Profile != "tmux" || features.AltAsCmd || features.MetaSendsEscape || features.Mouse != "off" || !features.BracketedPaste {
+		t.Fatalf("override features = %+v", features)
+	}
+
+	features = ResolveTerminal(TerminalOptions{Profile: "unknown", Mouse: "bogus"}, env)
+	if features.Profile != "generic" || features.Mouse != "all" {
+		t.Fatalf("fallback features = %+v", features)
+	}
+}
+
+func TestLoadTerminalSection(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/config.toml"
+	writeFile(t, path, `
+[terminal]
+profile = "ghostty"
+undercurl = false
+`)
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile error: %v", err)
+	}
+	if cfg.Terminal.Profile != "ghostty" || cfg.Terminal.Undercurl == nil || *cfg.Terminal.Undercurl {
+		t.Fatalf("terminal = %+v", cfg.Terminal)
+	}
+}
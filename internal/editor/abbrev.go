@@ -0,0 +1,99 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const actionToggleAutocorrect = "toggle_autocorrect" // :autocorrect - expand abbreviations in prose files
+
+// isProseFile reports whether name is a file autocorrect works in:
+// markdown, plain text and git commit messages
+func isProseFile(name string) bool {
+	if isMarkdownFile(name) || filepath.Base(name) == "COMMIT_EDITMSG" {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".text":
+		return true
+	}
+	return false
+}
+
+// isAbbrevRune reports whether r is part of a word an abbreviation can
+// replace; an apostrophe is, so "dont" and "don't" are different words
+func isAbbrevRune(r rune) bool {
+	return isWordChar(r) || r == '\''
+}
+
+// expandAbbreviation replaces the abbreviation before the cursor when
+// boundary, typed after it, ends the word: it inserts the replacement and
+// boundary as one undo step and reports true. A boundary of 0 (a line
+// break, inserted by the caller) replaces the word on its own.
+func (e *Editor) expandAbbreviation(boundary rune) bool {
+	if !e.autocorrect || len(e.abbreviations) == 0 || !isProseFile(e.filename) || isAbbrevRune(boundary) {
+		return false
+	}
+	line := e.lines[e.cursor.Row]
+	end := min(e.cursor.Col, len(line))
+	start := end
+	for start > 0 && isAbbrevRune(line[start-1]) {
+		start--
+	}
+	repl, ok := e.abbreviationFor(string(line[start:end]))
+	if !ok {
+		return false
+	}
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	pos, err := e.ReplaceRange(Cursor{Row: e.cursor.Row, Col: start}, Cursor{Row: e.cursor.Row, Col: end}, repl)
+	if err != nil {
+		return false
+	}
+	e.cursor = pos
+	if boundary == 0 {
+		return true
+	}
+	if pos, err = e.InsertAt(pos, string(boundary)); err == nil {
+		e.cursor = pos
+	}
+	return true
+}
+
+// abbreviationFor returns the replacement of word. A capitalized word
+// matches its lowercase abbreviation and gets a capitalized replacement.
+func (e *Editor) abbreviationFor(word string) (string, bool) {
+	if word == "" {
+		return "", false
+	}
+	if repl, ok := e.abbreviations[word]; ok {
+		return repl, true
+	}
+	first, size := utf8.DecodeRuneInString(word)
+	if !unicode.IsUpper(first) {
+		return "", false
+	}
+	repl, ok := e.abbreviations[string(unicode.ToLower(first))+word[size:]]
+	if !ok || repl == "" {
+		return "", false
+	}
+	r, size := utf8.DecodeRuneInString(repl)
+	return string(unicode.ToUpper(r)) + repl[size:], true
+}
+
+// toggleAutocorrect switches abbreviation expansion in prose files
+// (:autocorrect)
+func (e *Editor) toggleAutocorrect() {
+	e.setAutocorrect(!e.autocorrect)
+}
+
+func (e *Editor) setAutocorrect(on bool) {
+	e.autocorrect = on
+	if on {
+		e.setStatus("autocorrect on")
+	} else {
+		e.setStatus("autocorrect off")
+	}
+}
@@ -0,0 +1,80 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// typeText types s in insert mode, Enter for \n
+func typeText(e *Editor, s string) {
+	for _, r := range s {
+		if r == '\n' {
+			e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+			continue
+		}
+		e.HandleKey(keyRune(r))
+	}
+}
+
+func TestAutocorrect(t *testing.T) {
+	e := newTestEditor("")
+	e.filename = "notes.md"
+	e.mode = ModeInsert
+	e.autocorrect = true
+	typeText(e, "Teh cat adn teh dog, alot\nteh")
+	if got := e.Content(); got != "The cat and the dog, a lot\nteh" {
+		t.Fatalf("content %q", got)
+	}
+	if e.cursor != (Cursor{Row: 1, Col: 3}) {
+		t.Fatalf("cursor %+v", e.cursor)
+	}
+
+	// A line break corrects the word in a step of its own
+	e.mode = ModeNormal
+	for range 4 {
+		e.Undo()
+	}
+	if got := e.Content(); got != "The cat and the dog, a lot" {
+		t.Fatalf("after undoing the line: %q", got)
+	}
+	e.Undo()
+	if got := e.Content(); got != "The cat and the dog, alot" {
+		t.Fatalf("after undoing the correction: %q", got)
+	}
+
+	// Other boundaries are undone together with the correction
+	e = newTestEditor("")
+	e.filename = "notes.md"
+	e.mode = ModeInsert
+	e.autocorrect = true
+	typeText(e, "teh.")
+	e.Undo()
+	if got := e.Content(); got != "teh" {
+		t.Fatalf("after undo: %q", got)
+	}
+}
+
+func TestAutocorrectOnlyInProse(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		on   bool
+		want string
+	}{
+		{"main.go", true, "teh "},
+		{"notes.txt", false, "teh "},
+		{"notes.txt", true, "the "},
+		{"COMMIT_EDITMSG", true, "the "},
+	} {
+		e := newTestEditor("")
+		e.filename = tc.name
+		e.mode = ModeInsert
+		if tc.on {
+			e.execCommand("autocorrect on")
+		}
+		typeText(e, "teh ")
+		if got := e.Content(); got != tc.want {
+			t.Errorf("%s, autocorrect %v: %q", tc.name, tc.on, got)
+		}
+	}
+}
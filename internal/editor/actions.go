@@ -0,0 +1,394 @@
+package editor
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/kobzarvs/qedit/internal/platform/zoom"
+)
+
+// actionClass classifies an action for selection handling
+type actionClass int
+
+const (
+	classOther  actionClass = iota
+	classMotion             // moves the cursor; extends the selection in select mode
+	classEdit               // changes the text
+	classMode               // switches mode or waits for more keys
+)
+
+// actionModes is the set of places an action can be bound
+type actionModes int
+
+const (
+	inNormal actionModes = 1 << iota
+	inInsert
+	inSpaceMenu // entries of the space menu (SpaceMenuItems)
+)
+
+// actionDef is a named editor action. The keymap, the space menu, the
+// keybindings help and :action all dispatch through the registry, so an
+// action added here is available everywhere at once.
+type actionDef struct {
+	name  string
+	desc  string
+	group string // heading in the keybindings help
+	modes actionModes
+	class actionClass
+	// repeatable actions can be run several times in a row (:action NAME COUNT)
+	repeatable bool
+	// selects marks Helix motions that select the text they jumped over
+	selects bool
+	// keepSelection skips clearing the selection after the action runs
+	keepSelection bool
+	// everySelection actions run at each of several selections, as one
+	// undo step; keepSelections ones leave the other selections alone.
+	// Other actions drop them.
+	everySelection bool
+	keepSelections bool
+	quit           bool
+	run            func(e *Editor)
+}
+
+var (
+	actionRegistry = map[string]*actionDef{}
+	actionList     []*actionDef // registration order
+)
+
+// registerAction adds a to the registry; names must be unique
+func registerAction(a *actionDef) {
+	if _, ok := actionRegistry[a.name]; ok {
+		panic("editor: duplicate action " + a.name)
+	}
+	actionRegistry[a.name] = a
+	actionList = append(actionList, a)
+}
+
+// lookupAction returns the action registered under name, or nil
+func lookupAction(name string) *actionDef {
+	return actionRegistry[name]
+}
+
+// actionNames returns the sorted names of actions bindable in any of modes
+func actionNames(modes actionModes) []string {
+	var names []string
+	for _, a := range actionList {
+		if a.modes&modes != 0 {
+			names = append(names, a.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// actionCommands lists every action as an ":action NAME" autocomplete entry
+func actionCommands() []CommandInfo {
+	names := actionNames(inNormal | inInsert | inSpaceMenu)
+	commands := make([]CommandInfo, len(names))
+	for i, name := range names {
+		commands[i] = CommandInfo{"action " + name, actionRegistry[name].desc, CmdGroupAction}
+	}
+	return commands
+}
+
+// execActionCommand runs :action NAME [COUNT]. Only repeatable actions
+// take a count.
+func (e *Editor) execActionCommand(args []string) bool {
+	if len(args) == 0 || len(args) > 2 {
+		e.setStatus("usage: :action NAME [COUNT]")
+		return false
+	}
+	a := lookupAction(args[0])
+	if a == nil {
+		e.setStatus("unknown action: " + args[0])
+		return false
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			e.setStatus("invalid count: " + args[1])
+			return false
+		}
+		if n > 1 && !a.repeatable {
+			e.setStatus("action " + a.name + " does not take a count")
+			return false
+		}
+		count = n
+	}
+	for i := 0; i < count; i++ {
+		if e.execAction(a.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// The registry is filled in init rather than a variable initializer so
+// the run functions can call back into execAction without an
+// initialization cycle.
+func init() {
+	const (
+		normal = inNormal
+		both   = inNormal | inInsert
+	)
+	for _, a := range []*actionDef{
+		// Navigation
+		{name: actionMoveLeft, desc: "Move cursor left", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).moveLeft},
+		{name: actionMoveRight, desc: "Move cursor right", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).moveRight},
+		{name: actionMoveUp, desc: "Move cursor up", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).moveUp},
+		{name: actionMoveDown, desc: "Move cursor down", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).moveDown},
+		{name: actionWordLeft, desc: "Move to previous word", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).moveWordLeft},
+		{name: actionWordRight, desc: "Move to next word", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).moveWordRight},
+		{name: actionLineStart, desc: "Move to line start", group: "Navigation", modes: both, class: classMotion, run: (*Editor).moveLineStart},
+		{name: actionLineEnd, desc: "Move to line end", group: "Navigation", modes: both, class: classMotion, run: (*Editor).moveLineEnd},
+		{name: actionLineEndNonBlank, desc: "Move to last non-blank", group: "Navigation", modes: both, class: classMotion, run: (*Editor).moveLineEndNonBlank},
+		{name: actionSmartHome, desc: "Move to first non-blank, then line start", group: "Navigation", modes: both, class: classMotion, run: (*Editor).smartHome},
+		{name: actionSmartEnd, desc: "Move to last non-blank, then line end", group: "Navigation", modes: both, class: classMotion, run: (*Editor).smartEnd},
+		{name: actionFileStart, desc: "Move to file start", group: "Navigation", modes: both, class: classMotion, run: (*Editor).moveFileStart},
+		{name: actionFileEnd, desc: "Move to file end", group: "Navigation", modes: both, class: classMotion, run: (*Editor).moveFileEnd},
+		{name: actionPageUp, desc: "Page up", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).pageUp},
+		{name: actionPageDown, desc: "Page down", group: "Navigation", modes: both, class: classMotion, repeatable: true, run: (*Editor).pageDown},
+		{name: actionScrollUp, desc: "Scroll up", group: "Navigation", modes: both, repeatable: true, run: (*Editor).scrollViewUp},
+		{name: actionScrollDown, desc: "Scroll down", group: "Navigation", modes: both, repeatable: true, run: (*Editor).scrollViewDown},
+		{name: actionWordForward, desc: "Move to next word", group: "Navigation", modes: normal, class: classMotion, repeatable: true, selects: true, run: (*Editor).wordForward},
+		{name: actionWordBackward, desc: "Move to previous word", group: "Navigation", modes: normal, class: classMotion, repeatable: true, selects: true, run: (*Editor).wordBackward},
+		{name: actionWordEnd, desc: "Move to word end", group: "Navigation", modes: normal, class: classMotion, repeatable: true, selects: true, run: (*Editor).wordEnd},
+		{name: actionGotoLine, desc: "Go to last line", group: "Navigation", modes: normal, class: classMotion, run: (*Editor).gotoLastLine},
+		{name: actionGotoFirstLine, desc: "Go to file start", group: "Navigation", modes: normal, class: classMotion, run: (*Editor).gotoFirstLine},
+		{name: actionHeadingNext, desc: "Go to next markdown heading (]])", group: "Navigation", modes: normal, class: classMotion, repeatable: true, run: func(e *Editor) { e.gotoHeading(1) }},
+		{name: actionHeadingPrev, desc: "Go to previous markdown heading ([[)", group: "Navigation", modes: normal, class: classMotion, repeatable: true, run: func(e *Editor) { e.gotoHeading(-1) }},
+		{name: actionGotoFileEnd, desc: "Go to file end", group: "Navigation", modes: normal, class: classMotion, run: (*Editor).gotoFileEnd},
+		{name: actionGotoLinePrompt, desc: "Go to line number", group: "Navigation", modes: both, run: func(e *Editor) {
+			e.mode = ModeCommand
+			e.cmd = []rune{}
+			e.cmdCursor = 0
+			e.setStatus("goto line:")
+		}},
+
+		// Editing
+		{name: actionBackspace, desc: "Delete char before cursor", group: "Editing", modes: both, class: classEdit, repeatable: true, everySelection: true, run: (*Editor).backspace},
+		{name: actionNewline, desc: "Insert newline", group: "Editing", modes: both, class: classEdit, repeatable: true, everySelection: true, run: (*Editor).insertNewline},
+		{name: actionInsertTab, desc: "Insert tab", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).insertTab},
+		{name: actionDeleteLine, desc: "Delete line", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).deleteLine},
+		{name: actionDeleteChar, desc: "Delete char under cursor", group: "Editing", modes: both, class: classEdit, repeatable: true, everySelection: true, run: (*Editor).deleteChar},
+		{name: actionDeleteWordLeft, desc: "Delete previous word", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).deleteWordLeft},
+		{name: actionDeleteWordRight, desc: "Delete next word", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).deleteWordRight},
+		{name: actionInsertLineBelow, desc: "Insert line below", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).insertLineBelow},
+		{name: actionInsertLineAbove, desc: "Insert line above", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).insertLineAboveCursor},
+		{name: actionMoveLineUp, desc: "Move line up", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).moveLineUp},
+		{name: actionMoveLineDown, desc: "Move line down", group: "Editing", modes: both, class: classEdit, repeatable: true, run: (*Editor).moveLineDown},
+		{name: actionTodoToggle, desc: "Check/uncheck markdown task (:todo)", group: "Editing", modes: both, class: classEdit, run: (*Editor).toggleTodo},
+		{name: actionTodoMoveUp, desc: "Move markdown task up (:todo up)", group: "Editing", modes: both, class: classEdit, repeatable: true, run: func(e *Editor) { e.moveTodo(-1) }},
+		{name: actionTodoMoveDown, desc: "Move markdown task down (:todo down)", group: "Editing", modes: both, class: classEdit, repeatable: true, run: func(e *Editor) { e.moveTodo(1) }},
+		{name: actionIndent, desc: "Indent", group: "Editing", modes: both, class: classEdit, repeatable: true, keepSelection: true, run: (*Editor).indentSelection},
+		{name: actionUnindent, desc: "Unindent", group: "Editing", modes: both, class: classEdit, repeatable: true, keepSelection: true, run: (*Editor).unindentSelection},
+		{name: actionDelete, desc: "Delete selection", group: "Editing", modes: normal, class: classEdit, repeatable: true, everySelection: true, run: (*Editor).helixDelete},
+		{name: actionChange, desc: "Change (delete + insert)", group: "Editing", modes: normal, class: classEdit, keepSelection: true, everySelection: true, run: (*Editor).helixChange},
+		{name: actionYank, desc: "Yank (copy)", group: "Editing", modes: normal, keepSelection: true, run: (*Editor).yankSelection},
+		{name: actionPaste, desc: "Paste after", group: "Editing", modes: normal, class: classEdit, repeatable: true, run: (*Editor).pasteAfter},
+		{name: actionPasteBefore, desc: "Paste before", group: "Editing", modes: normal, class: classEdit, repeatable: true, run: (*Editor).pasteBefore},
+		{name: actionOpenBelow, desc: "Open line below", group: "Editing", modes: normal, class: classEdit, keepSelection: true, run: (*Editor).openBelow},
+		{name: actionOpenAbove, desc: "Open line above", group: "Editing", modes: normal, class: classEdit, keepSelection: true, run: (*Editor).openAbove},
+		{name: actionAppend, desc: "Append after cursor", group: "Editing", modes: normal, class: classMode, keepSelection: true, everySelection: true, run: (*Editor).appendMode},
+		{name: actionAppendLineEnd, desc: "Append at line end", group: "Editing", modes: normal, class: classMode, keepSelection: true, everySelection: true, run: (*Editor).appendLineEnd},
+		{name: actionInsertLineStart, desc: "Insert at line start", group: "Editing", modes: normal, class: classMode, keepSelection: true, everySelection: true, run: (*Editor).insertLineStart},
+		{name: actionReplaceChar, desc: "Replace char (r)", group: "Editing", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginCharSequence(actionReplaceChar, "r")
+		}},
+		{name: actionJoinLines, desc: "Join lines", group: "Editing", modes: normal, class: classEdit, repeatable: true, run: (*Editor).joinLinesCmd},
+
+		// Selection
+		{name: actionSelectAll, desc: "Select all", group: "Selection", modes: both, keepSelection: true, run: (*Editor).selectAll},
+		{name: actionToggleSelect, desc: "Toggle select mode", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).toggleSelectMode},
+		{name: actionExtendLine, desc: "Extend to full line", group: "Selection", modes: normal, repeatable: true, keepSelection: true, run: (*Editor).extendLine},
+		{name: actionCollapseSelection, desc: "Collapse selection", group: "Selection", modes: normal, everySelection: true, run: (*Editor).collapseSelection},
+		{name: actionFlipSelection, desc: "Flip selection anchor", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).flipSelection},
+		{name: actionTrimSelection, desc: "Trim whitespace from selection", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).trimSelection},
+		{name: actionExtendLineBounds, desc: "Extend selection to line bounds", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).extendToLineBounds},
+		{name: actionShrinkLineBounds, desc: "Shrink selection to line bounds", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).shrinkToLineBounds},
+		{name: actionExtendWordBounds, desc: "Extend selection to word bounds", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).extendToWordBounds},
+		{name: actionShrinkWordBounds, desc: "Shrink selection to word bounds", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).shrinkToWordBounds},
+		{name: actionExpandSelection, desc: "Expand selection to parent node", group: "Selection", modes: both, repeatable: true, keepSelection: true, run: (*Editor).expandSelection},
+		{name: actionShrinkSelection, desc: "Shrink selection to child node", group: "Selection", modes: both, repeatable: true, keepSelection: true, run: (*Editor).shrinkSelection},
+		{name: actionSelectRegex, desc: "Select regex matches in the selections (:select)", group: "Selection", modes: normal, class: classMode, keepSelection: true, keepSelections: true, run: func(e *Editor) {
+			e.promptCommand("select ")
+		}},
+		{name: actionKeepSelections, desc: "Keep selections matching a regex (:keep)", group: "Selection", modes: normal, class: classMode, keepSelection: true, keepSelections: true, run: func(e *Editor) {
+			e.promptCommand("keep ")
+		}},
+		{name: actionRemoveSelections, desc: "Remove selections matching a regex (:remove)", group: "Selection", modes: normal, class: classMode, keepSelection: true, keepSelections: true, run: func(e *Editor) {
+			e.promptCommand("remove ")
+		}},
+		{name: actionSplitSelection, desc: "Split selection into lines", group: "Selection", modes: normal, keepSelection: true, keepSelections: true, run: (*Editor).splitSelectionLines},
+		{name: actionKeepPrimary, desc: "Keep only the primary selection", group: "Selection", modes: normal, keepSelection: true, run: (*Editor).keepPrimarySelection},
+		{name: actionRemovePrimary, desc: "Remove the primary selection", group: "Selection", modes: normal, keepSelection: true, keepSelections: true, run: (*Editor).removePrimarySelection},
+		{name: actionRotateForward, desc: "Rotate the primary selection forward", group: "Selection", modes: normal, keepSelection: true, keepSelections: true, run: func(e *Editor) {
+			e.rotateSelections(1)
+		}},
+		{name: actionRotateBackward, desc: "Rotate the primary selection backward", group: "Selection", modes: normal, keepSelection: true, keepSelections: true, run: func(e *Editor) {
+			e.rotateSelections(-1)
+		}},
+
+		// Search
+		{name: actionSearchForward, desc: "Search /", group: "Search", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.enterSearchMode(true, false, false) // exact search
+		}},
+		{name: actionSearchBackward, desc: "Search ?", group: "Search", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.enterSearchMode(false, false, false) // exact search
+		}},
+		{name: actionSearchFuzzy, desc: "Fuzzy search", group: "Search", modes: both, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.enterSearchMode(true, true, false)
+		}},
+		{name: actionSearchRegex, desc: "Regex search", group: "Search", modes: both, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.enterSearchMode(true, false, true)
+		}},
+		{name: actionSearchNext, desc: "Next match (n)", group: "Search", modes: normal, repeatable: true, run: (*Editor).searchNext},
+		{name: actionSearchPrev, desc: "Prev match (N)", group: "Search", modes: normal, repeatable: true, run: (*Editor).searchPrev},
+		{name: actionFindChar, desc: "Find char (f)", group: "Search", modes: normal, class: classMotion, selects: true, keepSelection: true, run: func(e *Editor) {
+			e.beginCharSequence(actionFindChar, "f")
+		}},
+		{name: actionFindCharBackward, desc: "Find char back (F)", group: "Search", modes: normal, class: classMotion, selects: true, keepSelection: true, run: func(e *Editor) {
+			e.beginCharSequence(actionFindCharBackward, "F")
+		}},
+		{name: actionTillChar, desc: "Till char (t)", group: "Search", modes: normal, class: classMotion, selects: true, keepSelection: true, run: func(e *Editor) {
+			e.beginCharSequence(actionTillChar, "t")
+		}},
+		{name: actionTillCharBackward, desc: "Till char back (T)", group: "Search", modes: normal, class: classMotion, selects: true, keepSelection: true, run: func(e *Editor) {
+			e.beginCharSequence(actionTillCharBackward, "T")
+		}},
+		{name: actionRepeatFind, desc: "Repeat last find (Alt+.)", group: "Search", modes: normal, keepSelection: true, run: func(e *Editor) {
+			e.repeatFindChar(false, e.count)
+		}},
+		{name: actionReverseFind, desc: "Repeat last find reversed (Alt+,)", group: "Search", modes: normal, keepSelection: true, run: func(e *Editor) {
+			e.repeatFindChar(true, e.count)
+		}},
+
+		// Modes
+		{name: actionEnterInsert, desc: "Enter insert mode", group: "Modes", modes: both, class: classMode, everySelection: true, run: func(e *Editor) {
+			e.mode = ModeInsert
+			e.saveLineState()
+		}},
+		{name: actionEnterNormal, desc: "Enter normal mode", group: "Modes", modes: both, class: classMode, keepSelections: true, run: func(e *Editor) {
+			e.mode = ModeNormal
+		}},
+		{name: actionEnterCommand, desc: "Enter command mode", group: "Modes", modes: both, class: classMode, keepSelection: true, keepSelections: true, run: func(e *Editor) {
+			e.mode = ModeCommand
+			e.cmd = e.cmd[:0]
+			e.cmdCursor = 0
+			e.cmdHistoryIndex = -1
+		}},
+		{name: actionGotoMode, desc: "Goto mode (g)", group: "Modes", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginSequence(seqGoto, "g")
+		}},
+		{name: actionMatchMode, desc: "Match mode (m)", group: "Modes", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginSequence(seqMatch, "m")
+		}},
+		{name: actionViewMode, desc: "View mode (z)", group: "Modes", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginSequence(seqView, "z")
+		}},
+		{name: actionNextMode, desc: "Next mode (])", group: "Modes", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginSequence(seqNext, "]")
+		}},
+		{name: actionPrevMode, desc: "Previous mode ([)", group: "Modes", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginSequence(seqPrev, "[")
+		}},
+		{name: actionSpaceMode, desc: "Space menu", group: "Modes", modes: normal, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginSequence(seqSpace, "SPC")
+		}},
+
+		// History
+		{name: actionUndo, desc: "Undo", group: "History", modes: both, repeatable: true, keepSelection: true, run: func(e *Editor) { e.Undo() }},
+		{name: actionRedo, desc: "Redo", group: "History", modes: both, repeatable: true, keepSelection: true, run: func(e *Editor) { e.Redo() }},
+		{name: actionRecordMacro, desc: "Record macro / stop recording (Q)", group: "History", modes: normal, keepSelection: true, run: (*Editor).toggleMacroRecording},
+		{name: actionReplayMacro, desc: "Replay recorded macro (q)", group: "History", modes: normal, keepSelection: true, run: (*Editor).replayMacro},
+		{name: actionUndoLine, desc: "Undo changes on line", group: "History", modes: both, class: classEdit, run: (*Editor).undoLine},
+
+		// Other
+		{name: actionQuit, desc: "Quit editor", group: "Other", modes: both, quit: true, run: func(*Editor) {}},
+		{name: actionSave, desc: "Save file", group: "Other", modes: both, keepSelection: true, run: func(e *Editor) {
+			if err := e.Save(""); err != nil {
+				e.setStatus(err.Error())
+			} else {
+				e.setStatus(e.problemsStatus("saved " + e.filename))
+			}
+		}},
+		{name: actionCloseBuffer, desc: "Close buffer (:bd)", group: "Other", modes: normal, run: func(e *Editor) { e.closeBuffer(false) }},
+		{name: actionReopenBuffer, desc: "Reopen last closed buffer (:bundo)", group: "Other", modes: both, run: (*Editor).reopenBuffer},
+		{name: actionBufferNext, desc: "Next buffer (:bn)", group: "Other", modes: both, run: func(e *Editor) { e.cycleBuffer(1) }},
+		{name: actionBufferPrev, desc: "Previous buffer (:bp)", group: "Other", modes: both, run: func(e *Editor) { e.cycleBuffer(-1) }},
+		{name: actionBufferPin, desc: "Pin/unpin buffer (:bpin)", group: "Other", modes: both, run: (*Editor).togglePinBuffer},
+		{name: actionBufferMoveLeft, desc: "Move buffer left", group: "Other", modes: both, repeatable: true, run: func(e *Editor) { e.moveBuffer(-1) }},
+		{name: actionBufferMoveRight, desc: "Move buffer right", group: "Other", modes: both, repeatable: true, run: func(e *Editor) { e.moveBuffer(1) }},
+		{name: actionToggleBufferline, desc: "Toggle bufferline", group: "Other", modes: both, run: (*Editor).toggleBufferline},
+		{name: actionToggleSubword, desc: "Toggle subword motions (:subword)", group: "Navigation", modes: both, run: (*Editor).toggleSubword},
+		{name: actionToggleFindLines, desc: "Toggle f/t past the line (:findlines)", group: "Search", modes: both, run: (*Editor).toggleFindLines},
+		{name: actionToggleConceal, desc: "Toggle concealed markup (:conceal)", group: "Other", modes: both, run: (*Editor).toggleConceal},
+		{name: actionToggleAutocorrect, desc: "Toggle autocorrect in prose files (:autocorrect)", group: "Other", modes: both, run: (*Editor).toggleAutocorrect},
+		{name: actionTabNew, desc: "New tab page (:tabnew)", group: "Other", modes: both, run: func(e *Editor) { e.newTab("") }},
+		{name: actionTabClose, desc: "Close tab page (:tabclose)", group: "Other", modes: both, run: (*Editor).closeTab},
+		{name: actionTabNext, desc: "Next tab page (gt)", group: "Other", modes: both, run: func(e *Editor) { e.cycleTab(1) }},
+		{name: actionTabPrev, desc: "Previous tab page (gT)", group: "Other", modes: both, run: func(e *Editor) { e.cycleTab(-1) }},
+		{name: actionToggleLineNumbers, desc: "Toggle line numbers", group: "Other", modes: both, run: (*Editor).toggleLineNumbers},
+		{name: actionCharInfo, desc: "Show character under cursor", group: "Other", modes: both, run: func(e *Editor) {
+			e.setStatus(e.describeCharUnderCursor())
+		}},
+		{name: actionBranchPicker, desc: "Branch picker", group: "Other", modes: both, run: (*Editor).openSidebarBranches},
+		{name: actionToggleSidebar, desc: "Toggle sidebar", group: "Other", modes: both, run: (*Editor).toggleSidebar},
+		{name: actionTerminalZoomIn, desc: "Zoom terminal in", group: "Other", modes: both, keepSelection: true, run: func(e *Editor) {
+			if e.zoomPendingRestore {
+				return // already zoomed, ignore
+			}
+			if !zoom.Supported {
+				e.setStatus("terminal zoom is only supported on macOS")
+				return
+			}
+			// Save current scroll positions for restore
+			e.zoomSavedScroll = e.scroll
+			e.zoomSavedScrollX = e.scrollX
+			e.zoomWithAnimation(true, 20) // zoom in with scroll animation
+			e.zoomPendingRestore = true
+		}},
+
+		// Space menu
+		{name: "yank_clipboard", desc: "Yank to clipboard", group: "Clipboard", modes: inSpaceMenu, keepSelection: true, run: (*Editor).yankToSystemClipboard},
+		{name: "yank_main_clipboard", desc: "Yank main to clipboard", group: "Clipboard", modes: inSpaceMenu, keepSelection: true, run: (*Editor).yankToSystemClipboard},
+		{name: "paste_clipboard", desc: "Paste from clipboard", group: "Clipboard", modes: inSpaceMenu, class: classEdit, keepSelection: true, run: func(e *Editor) {
+			e.pasteFromSystemClipboard(false)
+		}},
+		{name: "paste_clipboard_before", desc: "Paste before from clipboard", group: "Clipboard", modes: inSpaceMenu, class: classEdit, keepSelection: true, run: func(e *Editor) {
+			e.pasteFromSystemClipboard(true)
+		}},
+		{name: actionPasteTable, desc: "Paste clipboard table as aligned columns (:pastetable)", group: "Clipboard", modes: both, class: classEdit, run: func(e *Editor) {
+			e.pasteTableFromClipboard("")
+		}},
+		{name: "window_mode", desc: "Window mode", group: "Modes", modes: inSpaceMenu, class: classMode, keepSelection: true, run: func(e *Editor) {
+			e.beginSequence(seqWindow, "SPC w")
+		}},
+		{name: "toggle_comment", desc: "Comment/uncomment", group: "Editing", modes: inSpaceMenu, class: classEdit, keepSelection: true, run: (*Editor).toggleLineComment},
+		{name: "file_picker", desc: "Open file picker", group: "Other", modes: inSpaceMenu, keepSelection: true, run: func(e *Editor) {
+			e.openFilePicker(false)
+		}},
+		{name: "file_picker_cwd", desc: "Open file picker at cwd", group: "Other", modes: inSpaceMenu, keepSelection: true, run: func(e *Editor) {
+			e.openFilePicker(true)
+		}},
+		{name: "global_search", desc: "Global search (:grep)", group: "Other", modes: inSpaceMenu, keepSelection: true, run: func(e *Editor) {
+			e.mode = ModeCommand
+			e.cmd = []rune("grep ")
+			e.cmdCursor = len(e.cmd)
+			e.cmdHistoryIndex = -1
+		}},
+		{name: "file_explorer", desc: "Open file explorer", group: "Other", modes: inSpaceMenu, keepSelection: true, run: func(e *Editor) {
+			e.openSidebarFiles("")
+		}},
+		{name: "file_explorer_buffer", desc: "Open file explorer at buffer dir", group: "Other", modes: inSpaceMenu, keepSelection: true, run: (*Editor).openSidebarFilesAtBuffer},
+		{name: actionToggleFold, desc: "Fold/unfold comment block or string", group: "View", modes: normal, run: (*Editor).toggleQuickFold},
+		{name: actionDiagnosticFloat, desc: "Show diagnostics of the line", group: "Other", modes: normal, keepSelection: true, run: (*Editor).toggleDiagnosticFloat},
+		{name: "diagnostic_picker", desc: "Open diagnostic picker", group: "Other", modes: inSpaceMenu, keepSelection: true, run: (*Editor).openSidebarProblems},
+		{name: actionCompletion, desc: "Complete word, config key or action", group: "Editing", modes: inInsert, class: classEdit, run: (*Editor).openCompletion},
+		{name: "show_docs", desc: "Show docs for item", group: "Other", modes: inSpaceMenu, keepSelection: true, run: (*Editor).showDocs},
+		{name: "show_keybindings", desc: "Show all keybindings", group: "Other", modes: inSpaceMenu, keepSelection: true, run: (*Editor).openKeybindingsHelp},
+	} {
+		registerAction(a)
+	}
+}
@@ -0,0 +1,120 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/kobzarvs/qedit/internal/config"
+)
+
+func TestDefaultKeymapActionsRegistered(t *testing.T) {
+	cfg := config.Default()
+	for _, km := range []struct {
+		name  string
+		keys  map[string]string
+		modes actionModes
+	}{
+		{"normal", cfg.Keymap.Normal, inNormal},
+		{"insert", cfg.Keymap.Insert, inInsert},
+	} {
+		for key, name := range km.keys {
+			a := lookupAction(name)
+			if a == nil {
+				t.Errorf("%s %q: action %q not registered", km.name, key, name)
+				continue
+			}
+			if a.modes&km.modes == 0 {
+				t.Errorf("%s %q: action %q not bindable in %s mode", km.name, key, name, km.name)
+			}
+		}
+	}
+}
+
+func TestSpaceMenuActionsRegistered(t *testing.T) {
+	for _, item := range SpaceMenuItems {
+		if !item.Implemented {
+			continue
+		}
+		if a := lookupAction(item.Action); a == nil || a.modes&inSpaceMenu == 0 {
+			t.Errorf("space menu %q: action %q not registered for the menu", item.Key, item.Action)
+		}
+	}
+}
+
+func TestActionRegistryMetadata(t *testing.T) {
+	for _, a := range actionList {
+		if a.desc == "" || a.group == "" || a.modes == 0 || a.run == nil {
+			t.Errorf("action %q: incomplete definition", a.name)
+		}
+		if a.selects && a.class != classMotion {
+			t.Errorf("action %q selects but is not a motion", a.name)
+		}
+	}
+	if !isMotionAction(actionMoveDown) || isMotionAction(actionDelete) {
+		t.Fatalf("isMotionAction misclassifies move_down/delete")
+	}
+	if !isHelixSelectingMotion(actionWordForward) || isHelixSelectingMotion(actionMoveRight) {
+		t.Fatalf("isHelixSelectingMotion misclassifies word_forward/move_right")
+	}
+}
+
+func TestExecActionUnknown(t *testing.T) {
+	e := newTestEditor("a")
+	if quit := e.execAction("no_such_action"); quit {
+		t.Fatalf("unknown action quit")
+	}
+	if e.statusMessage != "unknown action: no_such_action" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestActionCommand(t *testing.T) {
+	e := newTestEditor("one", "two", "three", "four")
+	if quit := e.execCommand("action move_down 2"); quit {
+		t.Fatalf("action move_down quit")
+	}
+	if e.cursor.Row != 2 {
+		t.Fatalf("cursor row = %d, want 2", e.cursor.Row)
+	}
+
+	e.execCommand("action delete_line 2")
+	if got := e.Content(); got != "one\ntwo" {
+		t.Fatalf("content = %q, want %q", got, "one\ntwo")
+	}
+
+	e.execCommand("action line_end 3")
+	if e.statusMessage != "action line_end does not take a count" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.execCommand("action move_up x")
+	if e.statusMessage != "invalid count: x" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.execCommand("action")
+	if e.statusMessage != "usage: :action NAME [COUNT]" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	if quit := e.execCommand("action quit"); !quit {
+		t.Fatalf("action quit did not quit")
+	}
+}
+
+func TestFilterCommandsActions(t *testing.T) {
+	got := filterCommands("action move_l")
+	want := []string{"action move_left", "action move_line_down", "action move_line_up"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d commands, want %d: %v", len(got), len(want), got)
+	}
+	for i, cmd := range got {
+		if cmd.Name != want[i] || cmd.Group != CmdGroupAction {
+			t.Fatalf("command %d = %+v, want %q", i, cmd, want[i])
+		}
+	}
+	if n := len(filterCommands("action ")); n != len(actionList) {
+		t.Fatalf("action completions = %d, want %d", n, len(actionList))
+	}
+	for _, cmd := range filterCommands("") {
+		if cmd.Group == CmdGroupAction && cmd.Name != "action" {
+			t.Fatalf("action %q listed without the action prefix", cmd.Name)
+		}
+	}
+}
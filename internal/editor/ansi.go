@@ -0,0 +1,275 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ansiStyle is the SGR state of an ANSI-colored text (colored CI logs)
+type ansiStyle struct {
+	fg    tcell.Color
+	bg    tcell.Color
+	attrs tcell.AttrMask
+}
+
+// ansiCell describes how one rune of a line is shown in ANSI view:
+// escape sequences are hidden, other runes get the SGR style in effect
+type ansiCell struct {
+	hidden bool
+	style  ansiStyle
+}
+
+// apply returns base with the SGR colors and attributes applied
+func (st ansiStyle) apply(base tcell.Style) tcell.Style {
+	if st.fg != tcell.ColorDefault {
+		base = base.Foreground(st.fg)
+	}
+	if st.bg != tcell.ColorDefault {
+		base = base.Background(st.bg)
+	}
+	return base.
+		Bold(st.attrs&tcell.AttrBold != 0).
+		Dim(st.attrs&tcell.AttrDim != 0).
+		Italic(st.attrs&tcell.AttrItalic != 0).
+		Underline(st.attrs&tcell.AttrUnderline != 0).
+		Blink(st.attrs&tcell.AttrBlink != 0).
+		Reverse(st.attrs&tcell.AttrReverse != 0).
+		StrikeThrough(st.attrs&tcell.AttrStrikeThrough != 0)
+}
+
+// hasANSIEscapes reports whether any line contains a CSI escape sequence
+func hasANSIEscapes(lines [][]rune) bool {
+	for _, line := range lines {
+		for i := 0; i+1 < len(line); i++ {
+			if line[i] == 0x1b && line[i+1] == '[' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseANSILine splits line into hidden escape sequences and styled text,
+// starting from SGR state st. Returns the cells and the state at line end.
+func parseANSILine(line []rune, st ansiStyle) ([]ansiCell, ansiStyle) {
+	cells := make([]ansiCell, len(line))
+	for i := 0; i < len(line); i++ {
+		if line[i] != 0x1b {
+			cells[i] = ansiCell{style: st}
+			continue
+		}
+		end := ansiSequenceEnd(line, i)
+		if i+1 < len(line) && line[i+1] == '[' && end > i+2 && line[end-1] == 'm' {
+			st = st.applySGR(string(line[i+2 : end-1]))
+		}
+		for j := i; j < end; j++ {
+			cells[j] = ansiCell{hidden: true, style: st}
+		}
+		i = end - 1
+	}
+	return cells, st
+}
+
+// ansiSequenceEnd returns the index after the escape sequence starting at i.
+// Handles CSI (ESC [ ... final), OSC (ESC ] ... BEL or ESC \) and short escapes.
+func ansiSequenceEnd(line []rune, i int) int {
+	if i+1 >= len(line) {
+		return len(line)
+	}
+	switch line[i+1] {
+	case '[':
+		for j := i + 2; j < len(line); j++ {
+			if line[j] >= 0x40 && line[j] <= 0x7e {
+				return j + 1
+			}
+		}
+		return len(line)
+	case ']':
+		for j := i + 2; j < len(line); j++ {
+			if line[j] == 0x07 {
+				return j + 1
+			}
+			if line[j] == 0x1b && j+1 < len(line) && line[j+1] == '\\' {
+				return j + 2
+			}
+		}
+		return len(line)
+	}
+	// ESC, intermediate bytes (e.g. "(" in ESC ( B), final byte
+	j := i + 1
+	for j < len(line) && line[j] >= 0x20 && line[j] <= 0x2f {
+		j++
+	}
+	return min(j+1, len(line))
+}
+
+// applySGR applies the parameters of an SGR sequence (ESC [ params m)
+func (st ansiStyle) applySGR(params string) ansiStyle {
+	if params == "" {
+		return ansiStyle{}
+	}
+	fields := strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' })
+	codes := make([]int, len(fields))
+	for i, f := range fields {
+		codes[i], _ = strconv.Atoi(f)
+	}
+	for i := 0; i < len(codes); i++ {
+		switch c := codes[i]; {
+		case c == 0:
+			st = ansiStyle{}
+		case c == 1:
+			st.attrs |= tcell.AttrBold
+		case c == 2:
+			st.attrs |= tcell.AttrDim
+		case c == 3:
+			st.attrs |= tcell.AttrItalic
+		case c == 4:
+			st.attrs |= tcell.AttrUnderline
+		case c == 5:
+			st.attrs |= tcell.AttrBlink
+		case c == 7:
+			st.attrs |= tcell.AttrReverse
+		case c == 9:
+			st.attrs |= tcell.AttrStrikeThrough
+		case c == 21 || c == 22:
+			st.attrs &^= tcell.AttrBold | tcell.AttrDim
+		case c == 23:
+			st.attrs &^= tcell.AttrItalic
+		case c == 24:
+			st.attrs &^= tcell.AttrUnderline
+		case c == 25:
+			st.attrs &^= tcell.AttrBlink
+		case c == 27:
+			st.attrs &^= tcell.AttrReverse
+		case c == 29:
+			st.attrs &^= tcell.AttrStrikeThrough
+		case c >= 30 && c <= 37:
+			st.fg = tcell.PaletteColor(c - 30)
+		case c >= 90 && c <= 97:
+			st.fg = tcell.PaletteColor(c - 90 + 8)
+		case c == 39:
+			st.fg = tcell.ColorDefault
+		case c >= 40 && c <= 47:
+			st.bg = tcell.PaletteColor(c - 40)
+		case c >= 100 && c <= 107:
+			st.bg = tcell.PaletteColor(c - 100 + 8)
+		case c == 49:
+			st.bg = tcell.ColorDefault
+		case c == 38 || c == 48:
+			color, n := sgrExtendedColor(codes[i+1:])
+			if n == 0 {
+				return st
+			}
+			if c == 38 {
+				st.fg = color
+			} else {
+				st.bg = color
+			}
+			i += n
+		}
+	}
+	return st
+}
+
+// sgrExtendedColor parses "5;n" (256 colors) or "2;r;g;b" (true color).
+// Returns the color and the number of parameters consumed.
+func sgrExtendedColor(codes []int) (tcell.Color, int) {
+	if len(codes) >= 2 && codes[0] == 5 {
+		return tcell.PaletteColor(codes[1] & 0xff), 2
+	}
+	if len(codes) >= 4 && codes[0] == 2 {
+		return tcell.NewRGBColor(int32(codes[1]&0xff), int32(codes[2]&0xff), int32(codes[3]&0xff)), 4
+	}
+	return tcell.ColorDefault, 0
+}
+
+// ansiVisualCol is visualCol with escape sequences taking no space
+func ansiVisualCol(line []rune, logicalCol int, tabWidth int) int {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	cells, _ := parseANSILine(line, ansiStyle{})
+	logicalCol = clampRange(logicalCol, 0, len(line))
+	col := 0
+	for i := 0; i < logicalCol; i++ {
+		switch {
+		case cells[i].hidden:
+		case line[i] == '\t':
+			col += tabWidth - (col % tabWidth)
+		default:
+			col++
+		}
+	}
+	return col
+}
+
+// ansiVisualToLogicalCol is visualToLogicalCol with escape sequences taking no space
+func ansiVisualToLogicalCol(line []rune, visualX int, tabWidth int) int {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	cells, _ := parseANSILine(line, ansiStyle{})
+	col := 0
+	for i, r := range line {
+		if cells[i].hidden {
+			continue
+		}
+		advance := 1
+		if r == '\t' {
+			advance = tabWidth - (col % tabWidth)
+		}
+		if col+advance > visualX {
+			return i
+		}
+		col += advance
+	}
+	return len(line)
+}
+
+// ansiCells returns the ANSI rendering of row, or nil outside ANSI view.
+// SGR state carries over from previous lines like in a terminal.
+func (e *Editor) ansiCells(row int) []ansiCell {
+	if !e.ansiView || row < 0 || row >= len(e.lines) {
+		return nil
+	}
+	if e.ansiStates == nil || e.ansiStatesTick != e.changeTick || len(e.ansiStates) != len(e.lines) {
+		e.ansiStates = make([]ansiStyle, len(e.lines))
+		var st ansiStyle
+		for i, line := range e.lines {
+			e.ansiStates[i] = st
+			_, st = parseANSILine(line, st)
+		}
+		e.ansiStatesTick = e.changeTick
+	}
+	cells, _ := parseANSILine(e.lines[row], e.ansiStates[row])
+	return cells
+}
+
+// displayCol returns the screen column of logical col in line
+func (e *Editor) displayCol(line []rune, col int) int {
+	if e.ansiView {
+		return ansiVisualCol(line, col, e.tabWidth)
+	}
+	return visualCol(line, col, e.tabWidth)
+}
+
+// logicalColAt returns the logical column shown at screen column visualX
+func (e *Editor) logicalColAt(line []rune, visualX int) int {
+	if e.ansiView {
+		return ansiVisualToLogicalCol(line, visualX, e.tabWidth)
+	}
+	return visualToLogicalCol(line, visualX, e.tabWidth)
+}
+
+// setANSIView switches between colored ANSI view and literal text
+func (e *Editor) setANSIView(on bool) {
+	e.ansiView = on
+	e.ansiStates = nil
+	if on {
+		e.setStatus("ANSI colors on (:ansi off to edit literal text)")
+	} else {
+		e.setStatus("ANSI colors off (literal text)")
+	}
+}
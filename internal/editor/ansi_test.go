@@ -0,0 +1,125 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseANSILineSGR(t *testing.T) {
+	line := []rune("\x1b[1;31mFAIL\x1b[0m ok \x1b[38;5;208mx\x1b[48;2;1;2;3my")
+	cells, end := parseANSILine(line, ansiStyle{})
+
+	var visible []rune
+	for i, c := range cells {
+		if !c.hidden {
+			visible = append(visible, line[i])
+		}
+	}
+	if string(visible) != "FAIL ok xy" {
+		t.Fatalf("visible = %q, want %q", string(visible), "FAIL ok xy")
+	}
+	fail := cells[7].style
+	if fail.fg != tcell.PaletteColor(1) || fail.attrs&tcell.AttrBold == 0 {
+		t.Fatalf("FAIL style = %+v, want bold red", fail)
+	}
+	if ok := cells[16].style; ok != (ansiStyle{}) {
+		t.Fatalf("style after reset = %+v, want default", ok)
+	}
+	x := cells[29].style
+	if x.fg != tcell.PaletteColor(208) {
+		t.Fatalf("256-color fg = %v, want palette 208", x.fg)
+	}
+	if end.fg != tcell.PaletteColor(208) || end.bg != tcell.NewRGBColor(1, 2, 3) {
+		t.Fatalf("end state = %+v, want 256-color fg and rgb bg", end)
+	}
+}
+
+func TestParseANSILineHidesOtherEscapes(t *testing.T) {
+	line := []rune("a\x1b[2Kb\x1b]0;title\x07c\x1b(Bd\x1b[3")
+	cells, _ := parseANSILine(line, ansiStyle{})
+	var visible []rune
+	for i, c := range cells {
+		if !c.hidden {
+			visible = append(visible, line[i])
+		}
+	}
+	if string(visible) != "abcd" {
+		t.Fatalf("visible = %q, want %q", string(visible), "abcd")
+	}
+}
+
+func TestANSIVisualColSkipsEscapes(t *testing.T) {
+	line := []rune("\x1b[32mok\x1b[0m\tx")
+	if got := ansiVisualCol(line, len(line), 4); got != 5 {
+		t.Fatalf("ansiVisualCol = %d, want 5", got)
+	}
+	if got := ansiVisualCol(line, 6, 4); got != 1 {
+		t.Fatalf("ansiVisualCol(6) = %d, want 1", got)
+	}
+	if got := ansiVisualToLogicalCol(line, 1, 4); got != 6 {
+		t.Fatalf("ansiVisualToLogicalCol(1) = %d, want 6", got)
+	}
+	if got := ansiVisualToLogicalCol(line, 4, 4); got != 12 {
+		t.Fatalf("ansiVisualToLogicalCol(4) = %d, want 12", got)
+	}
+}
+
+func TestANSIStateCarriesAcrossLines(t *testing.T) {
+	e := newTestEditor("\x1b[31mred", "still red\x1b[0m", "plain")
+	e.ansiView = true
+	if got := e.ansiCells(1)[0].style.fg; got != tcell.PaletteColor(1) {
+		t.Fatalf("line 2 fg = %v, want red", got)
+	}
+	if got := e.ansiCells(2)[0].style; got != (ansiStyle{}) {
+		t.Fatalf("line 3 style = %+v, want default", got)
+	}
+}
+
+func TestOpenFileANSIViewToggle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.log")
+	content := "\x1b[32mPASS\x1b[0m test\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	e := newTestEditor("")
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if e.ansiView {
+		t.Fatalf("ANSI view enabled without ansi-colors")
+	}
+	if e.statusMessage != "ANSI escape codes found (:ansi to show colors)" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+
+	e.ansiColors = true
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if !e.ansiView {
+		t.Fatalf("ANSI view disabled with ansi-colors")
+	}
+	e.cursor.Col = len(e.lines[0])
+	if got := e.displayCol(e.lines[0], e.cursor.Col); got != len("PASS test") {
+		t.Fatalf("displayCol = %d, want %d", got, len("PASS test"))
+	}
+
+	e.execCommand("ansi")
+	if e.ansiView || e.ansiCells(0) != nil {
+		t.Fatalf(":ansi should switch to literal text")
+	}
+	if got := e.displayCol(e.lines[0], e.cursor.Col); got != len(e.lines[0]) {
+		t.Fatalf("literal displayCol = %d, want %d", got, len(e.lines[0]))
+	}
+	e.execCommand("ansi on")
+	if !e.ansiView {
+		t.Fatalf(":ansi on should enable colors")
+	}
+	if e.Content() != content {
+		t.Fatalf("buffer changed: %q", e.Content())
+	}
+}
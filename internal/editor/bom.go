@@ -0,0 +1,42 @@
+package editor
+
+import "bytes"
+
+// A UTF-8 byte order mark is kept out of the buffer: it is cut off when the
+// file is read, shown as [BOM] in the statusline and written back on save,
+// unless drop-bom is set or :bom off turned it off for the buffer.
+
+// utf8BOM is the byte order mark UTF-8 files may start with
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// cutBOM returns data without a leading byte order mark and whether it
+// had one
+func cutBOM(data []byte) ([]byte, bool) {
+	if rest, ok := bytes.CutPrefix(data, utf8BOM); ok {
+		return rest, true
+	}
+	return data, false
+}
+
+// fileBytes returns the bytes a save writes for text: with the byte order
+// mark when the buffer keeps one
+func (e *Editor) fileBytes(text string) []byte {
+	if !e.bom {
+		return []byte(text)
+	}
+	return append(append([]byte{}, utf8BOM...), text...)
+}
+
+// execBOMCommand runs :bom [on|off], whether saving writes a byte order mark
+func (e *Editor) execBOMCommand(args []string) {
+	on := e.bom
+	if !e.boolOption(args, &on, "bom") {
+		return
+	}
+	e.bom = on
+	if on {
+		e.setStatus("bom on (saving writes a byte order mark)")
+	} else {
+		e.setStatus("bom off (saving drops the byte order mark)")
+	}
+}
@@ -0,0 +1,41 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestByteOrderMarkKeptOutOfBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bom.txt")
+	if err := os.WriteFile(path, []byte("\xEF\xBB\xBFhello\nworld"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor("")
+	e.DisableState()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if got := e.Content(); got != "hello\nworld" || !e.bom {
+		t.Fatalf("content %q, bom %v", got, e.bom)
+	}
+	if _, rows := renderRows(t, e, 60, 6); !strings.Contains(strings.Join(rows, "\n"), "[BOM]") {
+		t.Fatalf("no [BOM] in %q", rows)
+	}
+	e.insertRune('>')
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "\xEF\xBB\xBF>hello\nworld" {
+		t.Fatalf("saved %q", data)
+	}
+
+	e.execCommand("bom off")
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != ">hello\nworld" {
+		t.Fatalf("saved without bom %q", data)
+	}
+}
@@ -0,0 +1,50 @@
+package editor
+
+import (
+	"github.com/kobzarvs/qedit/pkg/core"
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// treeMatchingBracket returns where mm goes from the cursor, ch being the
+// character under it, according to the syntax tree: the other bracket of
+// the pair the cursor is on, or the closing bracket of the pair around the
+// cursor. A bracket or quote the tree doesn't pair, such as one inside a
+// string, is left to the text scan.
+func (e *Editor) treeMatchingBracket(ch rune) (Cursor, bool) {
+	if e.bracketPairFunc == nil || e.filename == "" {
+		return Cursor{}, false
+	}
+	col := textpos.RuneToByte(e.lineAt(e.cursor.Row), e.cursor.Col)
+	pair, ok := e.bracketPairFunc(e.filename, e.cursor.Row, col)
+	if !ok {
+		return Cursor{}, false
+	}
+	open := core.ClampPos(e, Cursor{Row: pair.OpenRow, Col: textpos.ByteToRune(e.lineAt(pair.OpenRow), pair.OpenCol)})
+	closing := core.ClampPos(e, Cursor{Row: pair.CloseRow, Col: textpos.ByteToRune(e.lineAt(pair.CloseRow), pair.CloseCol)})
+	switch {
+	case e.cursor == open:
+		return closing, true
+	case e.cursor == closing:
+		return open, true
+	case isBracketOrQuote(ch):
+		return Cursor{}, false
+	}
+	return closing, true
+}
+
+// enclosingCloseBracket finds by scanning the text the closing bracket of
+// the innermost (), [] or {} pair around the cursor
+func (e *Editor) enclosingCloseBracket() (Cursor, bool) {
+	var best, bestOpen Cursor
+	found := false
+	for _, pair := range [][2]rune{{'(', ')'}, {'[', ']'}, {'{', '}'}} {
+		open, ok := e.scanBracket(e.cursor, pair[1], pair[0], false)
+		if !ok || (found && open.Before(bestOpen)) {
+			continue
+		}
+		if closing, ok := e.scanBracket(open, pair[0], pair[1], true); ok {
+			best, bestOpen, found = closing, open, true
+		}
+	}
+	return best, found
+}
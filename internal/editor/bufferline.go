@@ -0,0 +1,184 @@
+package editor
+
+import (
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// bufferTab is the screen span of one bufferline tab, for mouse clicks
+type bufferTab struct {
+	start, end int  // columns, end exclusive
+	index      int  // index in e.buffers, or in e.tabs for a tab page
+	page       bool // the tab is a tab page
+}
+
+// bufferlineShown reports whether Render draws the bufferline: it is on
+// or there are several tab pages, and there is room for it above the
+// text, statusline and command line
+func (e *Editor) bufferlineShown(h int) bool {
+	return (e.bufferline || e.tabCount() > 1) && h > 3
+}
+
+// toggleBufferline shows or hides the bufferline (:bufferline)
+func (e *Editor) toggleBufferline() {
+	e.setBufferline(!e.bufferline)
+}
+
+func (e *Editor) setBufferline(on bool) {
+	e.bufferline = on
+	if on {
+		e.setStatus("bufferline on")
+	} else {
+		e.setStatus("bufferline off")
+	}
+}
+
+// bufferLabel is the text of tab i: the file name, a pin for pinned
+// buffers and a dot when the open file has unsaved changes
+func (e *Editor) bufferLabel(i int, current bool) string {
+	label := " "
+	if e.buffers[i].pinned {
+		label += "⚑ "
+	}
+	label += filepath.Base(e.buffers[i].path)
+	if current && e.dirty {
+		label += " ●"
+	}
+	return label + " "
+}
+
+// renderBufferline draws the open buffers as tabs on row 0, and the tab
+// pages at its right end when there are several
+func (e *Editor) renderBufferline(s tcell.Screen, w int) {
+	clearLineAt(s, 0, 0, w, e.styleStatus)
+	e.bufferTabs = e.bufferTabs[:0]
+	if n := e.tabCount(); n > 1 {
+		pages := make([][]rune, n)
+		width := 0
+		for i := range pages {
+			pages[i] = []rune(e.tabLabel(i))
+			width += len(pages[i])
+		}
+		x := max(w-width, 0)
+		w = x
+		for i, label := range pages {
+			style := e.styleStatus
+			if i == e.tabIndex {
+				style = e.styleSelection
+			}
+			start := x
+			for _, r := range label {
+				s.SetContent(x, 0, r, nil, style)
+				x++
+			}
+			e.bufferTabs = append(e.bufferTabs, bufferTab{start: start, end: x, index: i, page: true})
+		}
+	}
+	if e.bufferline {
+		e.renderBufferTabs(s, w)
+	}
+}
+
+// renderBufferTabs draws the buffer tabs in the first w columns. When they
+// don't fit, tabs are dropped from the left until the current one is
+// visible.
+func (e *Editor) renderBufferTabs(s tcell.Screen, w int) {
+	cur := e.currentBuffer()
+	labels := make([][]rune, len(e.buffers))
+	for i := range e.buffers {
+		labels[i] = []rune(e.bufferLabel(i, i == cur))
+	}
+	if cur < 0 {
+		name := " [No Name] "
+		if e.dirty {
+			name = " [No Name] ● "
+		}
+		labels = append(labels, []rune(name))
+		cur = len(labels) - 1
+	}
+
+	first, width := 0, 0
+	for i := 0; i <= cur; i++ {
+		width += len(labels[i])
+	}
+	for first < cur && width > w {
+		width -= len(labels[first])
+		first++
+	}
+
+	x := 0
+	for i := first; i < len(labels) && x < w; i++ {
+		style := e.styleStatus
+		if i == cur {
+			style = e.styleSelection
+		}
+		start := x
+		for _, r := range labels[i] {
+			if x >= w {
+				break
+			}
+			s.SetContent(x, 0, r, nil, style)
+			x++
+		}
+		if i < len(e.buffers) {
+			e.bufferTabs = append(e.bufferTabs, bufferTab{start: start, end: x, index: i})
+		}
+	}
+}
+
+// clickBufferline switches to the buffer or tab page at column x
+func (e *Editor) clickBufferline(x int) {
+	for _, tab := range e.bufferTabs {
+		if x < tab.start || x >= tab.end {
+			continue
+		}
+		if tab.page {
+			e.switchTab(tab.index)
+		} else {
+			e.switchBuffer(tab.index)
+		}
+		return
+	}
+}
+
+// bufferlineScreen is the screen below the bufferline: row 0 of the
+// wrapped screen is taken, so everything drawn through it moves down a row
+type bufferlineScreen struct {
+	tcell.Screen
+	style tcell.Style
+}
+
+func (s *bufferlineScreen) Size() (int, int) {
+	w, h := s.Screen.Size()
+	return w, h - 1
+}
+
+func (s *bufferlineScreen) SetContent(x, y int, primary rune, combining []rune, style tcell.Style) {
+	if y >= 0 {
+		s.Screen.SetContent(x, y+1, primary, combining, style)
+	}
+}
+
+func (s *bufferlineScreen) GetContent(x, y int) (rune, []rune, tcell.Style, int) {
+	return s.Screen.GetContent(x, y+1)
+}
+
+func (s *bufferlineScreen) ShowCursor(x, y int) {
+	s.Screen.ShowCursor(x, y+1)
+}
+
+func (s *bufferlineScreen) SetStyle(style tcell.Style) {
+	s.style = style
+	s.Screen.SetStyle(style)
+}
+
+// Clear blanks everything but the bufferline
+func (s *bufferlineScreen) Clear() {
+	w, h := s.Size()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			s.SetContent(x, y, ' ', nil, s.style)
+		}
+	}
+}
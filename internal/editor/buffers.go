@@ -0,0 +1,248 @@
+package editor
+
+import (
+	"path/filepath"
+
+	"github.com/kobzarvs/qedit/pkg/core"
+)
+
+// maxClosedBuffers bounds the closed-buffer history kept for :bundo
+const maxClosedBuffers = 20
+
+// bufferView is where a file was left: the cursor and scroll to restore
+// when it is opened again from :bundo or the bufferline
+type bufferView struct {
+	path    string
+	cursor  Cursor
+	scroll  int
+	scrollX int
+}
+
+// closeBuffer closes the current file (:bd) and leaves an empty unnamed
+// buffer. Unsaved changes are only dropped with force (:bd!).
+func (e *Editor) closeBuffer(force bool) {
+	if e.filename == "" && !e.dirty {
+		e.setStatus("no buffer to close")
+		return
+	}
+	if e.dirty && !force {
+		e.setStatus("unsaved changes (use :bd!)")
+		return
+	}
+	name := e.filename
+	if name != "" {
+		e.saveSessionState()
+		e.forgetBuffer(name)
+		e.closedBuffers = append(e.closedBuffers, bufferView{
+			path:    name,
+			cursor:  e.cursor,
+			scroll:  e.scroll,
+			scrollX: e.scrollX,
+		})
+		if len(e.closedBuffers) > maxClosedBuffers {
+			e.closedBuffers = e.closedBuffers[len(e.closedBuffers)-maxClosedBuffers:]
+		}
+		e.closedBufferPath = name
+	}
+	e.clearBuffer()
+	if name == "" {
+		e.setStatus("buffer discarded")
+	} else {
+		e.setStatus("closed " + name + " (:bundo reopens)")
+	}
+}
+
+// clearBuffer leaves an empty unnamed buffer
+func (e *Editor) clearBuffer() {
+	e.cancelBufferTasks()
+	e.stopCollab()
+	e.lines = [][]rune{{}}
+	e.preview = false
+	e.ansiView = false
+	e.filename = ""
+	e.resetBufferState()
+	e.applyBufferSettings()
+}
+
+// reopenBuffer reopens the most recently closed file (:bundo). The app
+// opens it through the open-file request; OpenFile then restores the
+// cursor and drops the history entry.
+func (e *Editor) reopenBuffer() {
+	if len(e.closedBuffers) == 0 {
+		e.setStatus("no closed buffers")
+		return
+	}
+	if e.dirty {
+		e.setStatus("unsaved changes (use :w first)")
+		return
+	}
+	last := e.closedBuffers[len(e.closedBuffers)-1]
+	e.reopening = &last
+	e.openFileRequest = last.path
+}
+
+// restoreBufferView puts the cursor back where the file was left, if
+// OpenFile is completing a :bundo or a buffer switch
+func (e *Editor) restoreBufferView() {
+	r := e.reopening
+	e.reopening = nil
+	if r == nil || r.path != e.filename {
+		return
+	}
+	// A :bundo leaves the history once the file opened, so a failed open
+	// can be tried again
+	if n := len(e.closedBuffers); n > 0 && e.closedBuffers[n-1] == *r {
+		e.closedBuffers = e.closedBuffers[:n-1]
+	}
+	e.cursor = core.ClampPos(e, r.cursor)
+	e.scroll = min(max(r.scroll, 0), len(e.lines)-1)
+	e.scrollX = max(r.scrollX, 0)
+	e.selectionActive = false
+}
+
+// ConsumeClosedBuffer returns the path of the file closed with :bd (or left
+// for an empty tab page) since the last call, or "", so the app can drop
+// its language server and highlighting state for it
+func (e *Editor) ConsumeClosedBuffer() string {
+	path := e.closedBufferPath
+	e.closedBufferPath = ""
+	return path
+}
+
+// bufferEntry is a file opened in this session, as listed in the
+// bufferline. Pinned entries always come before unpinned ones.
+type bufferEntry struct {
+	bufferView
+	pinned   bool
+	tabWidth int  // :tabwidth for this buffer, 0 for the configured width
+	conceal  bool // :conceal for this buffer
+}
+
+// bufferKey returns the path buffers are tracked by, so the same file
+// opened by relative and absolute path is one buffer
+func bufferKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// bufferIndex returns the index of path in the buffer list, or -1
+func (e *Editor) bufferIndex(path string) int {
+	if path == "" {
+		return -1
+	}
+	key := bufferKey(path)
+	for i, b := range e.buffers {
+		if b.path == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// currentBuffer returns the index of the open file in the buffer list, or -1
+func (e *Editor) currentBuffer() int {
+	return e.bufferIndex(e.filename)
+}
+
+// trackBuffer adds the file OpenFile just loaded to the end of the buffer
+// list unless it is already there
+func (e *Editor) trackBuffer() {
+	if e.filename == "" || e.currentBuffer() >= 0 {
+		return
+	}
+	e.buffers = append(e.buffers, bufferEntry{bufferView: bufferView{path: bufferKey(e.filename)}})
+}
+
+// rememberBufferView records where the cursor is in the open file, so
+// switching back to it later lands in the same place
+func (e *Editor) rememberBufferView() {
+	if i := e.currentBuffer(); i >= 0 {
+		e.buffers[i].cursor = e.cursor
+		e.buffers[i].scroll = e.scroll
+		e.buffers[i].scrollX = e.scrollX
+	}
+}
+
+// forgetBuffer drops path from the buffer list
+func (e *Editor) forgetBuffer(path string) {
+	if i := e.bufferIndex(path); i >= 0 {
+		e.buffers = append(e.buffers[:i], e.buffers[i+1:]...)
+	}
+}
+
+// switchBuffer opens buffer i through the open-file request. The editor
+// holds one file at a time, so unsaved changes have to be written first.
+func (e *Editor) switchBuffer(i int) {
+	if i < 0 || i >= len(e.buffers) || i == e.currentBuffer() {
+		return
+	}
+	if e.dirty {
+		e.setStatus("unsaved changes (use :w first)")
+		return
+	}
+	e.rememberBufferView()
+	view := e.buffers[i].bufferView
+	e.reopening = &view
+	e.openFileRequest = view.path
+}
+
+// cycleBuffer switches to the next (delta 1) or previous (delta -1) buffer,
+// wrapping around the ends of the list
+func (e *Editor) cycleBuffer(delta int) {
+	n := len(e.buffers)
+	cur := e.currentBuffer()
+	if n == 0 || (n == 1 && cur == 0) {
+		e.setStatus("no other buffers")
+		return
+	}
+	if cur < 0 && delta < 0 {
+		// From an unnamed buffer bp goes to the last buffer, bn to the first
+		cur = n
+	}
+	e.switchBuffer(((cur+delta)%n + n) % n)
+}
+
+// togglePinBuffer pins or unpins the open file. A pinned buffer moves to
+// the end of the pinned group; an unpinned one to the start of the rest.
+func (e *Editor) togglePinBuffer() {
+	i := e.currentBuffer()
+	if i < 0 {
+		e.setStatus("no buffer to pin")
+		return
+	}
+	b := e.buffers[i]
+	b.pinned = !b.pinned
+	e.buffers = append(e.buffers[:i], e.buffers[i+1:]...)
+	at := 0
+	for at < len(e.buffers) && e.buffers[at].pinned {
+		at++
+	}
+	e.buffers = append(e.buffers[:at], append([]bufferEntry{b}, e.buffers[at:]...)...)
+	name := filepath.Base(b.path)
+	if b.pinned {
+		e.setStatus("pinned " + name)
+	} else {
+		e.setStatus("unpinned " + name)
+	}
+}
+
+// moveBuffer moves the open file delta places along the bufferline. It
+// stops at the ends and never crosses between pinned and unpinned buffers.
+func (e *Editor) moveBuffer(delta int) {
+	i := e.currentBuffer()
+	if i < 0 {
+		e.setStatus("no buffer to move")
+		return
+	}
+	j := i + delta
+	if j < 0 || j >= len(e.buffers) {
+		return
+	}
+	if e.buffers[j].pinned != e.buffers[i].pinned {
+		e.setStatus("pinned buffers stay before unpinned ones")
+		return
+	}
+	e.buffers[i], e.buffers[j] = e.buffers[j], e.buffers[i]
+}
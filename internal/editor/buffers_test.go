@@ -0,0 +1,256 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloseBufferDirty(t *testing.T) {
+	e := newTestEditor("one")
+	e.filename = "a.txt"
+	e.insertRune('x')
+
+	e.execCommand("bd")
+	if e.filename != "a.txt" || e.statusMessage != "unsaved changes (use :bd!)" {
+		t.Fatalf("filename = %q status = %q", e.filename, e.statusMessage)
+	}
+
+	e.execCommand("bd!")
+	if e.filename != "" || e.Content() != "" || e.dirty || len(e.undo) != 0 {
+		t.Fatalf("buffer not reset: name %q content %q dirty %v", e.filename, e.Content(), e.dirty)
+	}
+	if got := e.ConsumeClosedBuffer(); got != "a.txt" {
+		t.Fatalf("ConsumeClosedBuffer() = %q, want a.txt", got)
+	}
+	if got := e.ConsumeClosedBuffer(); got != "" {
+		t.Fatalf("second ConsumeClosedBuffer() = %q, want empty", got)
+	}
+
+	e.execCommand("bd")
+	if e.statusMessage != "no buffer to close" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestReopenBufferRestoresCursor(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor()
+	e.DisableState()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e.cursor = Cursor{Row: 2, Col: 3}
+
+	e.execCommand("bd")
+	if e.cursor != (Cursor{}) {
+		t.Fatalf("cursor = %v after close", e.cursor)
+	}
+	e.execCommand("bundo")
+	if got := e.ConsumeOpenFileRequest(); got != path {
+		t.Fatalf("open request = %q, want %q", got, path)
+	}
+	// The app answers the request with OpenFile
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if e.cursor != (Cursor{Row: 2, Col: 3}) {
+		t.Fatalf("cursor = %v, want {2 3}", e.cursor)
+	}
+
+	e.execCommand("bundo")
+	if e.statusMessage != "no closed buffers" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestReopenBufferKeptWhenOpenFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor()
+	e.DisableState()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e.cursor = Cursor{Row: 1, Col: 2}
+	e.execCommand("bd")
+	// A directory in its place can't be opened
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e.execCommand("bundo")
+	if err := e.OpenFile(e.ConsumeOpenFileRequest()); err == nil {
+		t.Fatalf("opened a directory")
+	}
+	if len(e.closedBuffers) != 1 {
+		t.Fatalf("history = %d entries after a failed open, want 1", len(e.closedBuffers))
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e.execCommand("bundo")
+	if err := e.OpenFile(e.ConsumeOpenFileRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.closedBuffers) != 0 || e.cursor != (Cursor{Row: 1, Col: 2}) {
+		t.Fatalf("history = %d entries, cursor %v after reopening", len(e.closedBuffers), e.cursor)
+	}
+}
+
+func TestClosedBufferHistory(t *testing.T) {
+	e := newTestEditor("x")
+	for i := 0; i < maxClosedBuffers+5; i++ {
+		e.filename = filepath.Join("dir", string(rune('a'+i)))
+		e.closeBuffer(false)
+	}
+	if len(e.closedBuffers) != maxClosedBuffers {
+		t.Fatalf("history = %d entries, want %d", len(e.closedBuffers), maxClosedBuffers)
+	}
+	// Most recently closed first
+	e.reopenBuffer()
+	if want := filepath.Join("dir", string(rune('a'+maxClosedBuffers+4))); e.openFileRequest != want {
+		t.Fatalf("open request = %q, want %q", e.openFileRequest, want)
+	}
+}
+
+// testBuffers returns an editor with files a, b, c, d in the buffer list
+// and c open
+func testBuffers() *Editor {
+	e := newTestEditor("x")
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.filename = name
+		e.trackBuffer()
+	}
+	e.filename = "c"
+	return e
+}
+
+func bufferOrder(e *Editor) string {
+	var names string
+	for _, b := range e.buffers {
+		names += filepath.Base(b.path)
+	}
+	return names
+}
+
+func TestPinAndMoveBuffers(t *testing.T) {
+	e := testBuffers()
+	e.execCommand("bpin")
+	if got := bufferOrder(e); got != "cabd" || !e.buffers[0].pinned {
+		t.Fatalf("after pin order = %q pinned = %v", got, e.buffers[0].pinned)
+	}
+	e.execCommand("bmove right")
+	if got := bufferOrder(e); got != "cabd" || e.statusMessage != "pinned buffers stay before unpinned ones" {
+		t.Fatalf("order = %q status = %q", got, e.statusMessage)
+	}
+
+	e.filename = "b"
+	e.execCommand("bmove left")
+	if got := bufferOrder(e); got != "cbad" {
+		t.Fatalf("after move left order = %q", got)
+	}
+	e.execCommand("bpin")
+	if got := bufferOrder(e); got != "cbad" || !e.buffers[1].pinned {
+		t.Fatalf("after second pin order = %q", got)
+	}
+
+	e.filename = "c"
+	e.execCommand("bpin")
+	if got := bufferOrder(e); got != "bcad" || e.buffers[1].pinned {
+		t.Fatalf("after unpin order = %q", got)
+	}
+	e.execCommand("bmove up")
+	if e.statusMessage != "usage: :bmove left|right" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestCycleBuffers(t *testing.T) {
+	e := testBuffers()
+	e.execCommand("bn")
+	if want := bufferKey("d"); e.openFileRequest != want {
+		t.Fatalf("bn request = %q, want %q", e.openFileRequest, want)
+	}
+	e.filename = "d"
+	e.execCommand("bn")
+	if want := bufferKey("a"); e.openFileRequest != want {
+		t.Fatalf("bn wrap request = %q, want %q", e.openFileRequest, want)
+	}
+	e.filename = "a"
+	e.openFileRequest = ""
+	e.handleGotoKey('p')
+	if want := bufferKey("d"); e.openFileRequest != want {
+		t.Fatalf("gp request = %q, want %q", e.openFileRequest, want)
+	}
+
+	e.openFileRequest = ""
+	e.dirty = true
+	e.execCommand("bp")
+	if e.openFileRequest != "" || e.statusMessage != "unsaved changes (use :w first)" {
+		t.Fatalf("dirty switch: request %q status %q", e.openFileRequest, e.statusMessage)
+	}
+}
+
+func TestBufferSwitchRestoresCursor(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := newTestEditor()
+	e.DisableState()
+	if err := e.OpenFile(a); err != nil {
+		t.Fatal(err)
+	}
+	e.cursor = Cursor{Row: 1, Col: 2}
+	if err := e.OpenFile(b); err != nil {
+		t.Fatal(err)
+	}
+	e.execCommand("bp")
+	if err := e.OpenFile(e.ConsumeOpenFileRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if e.filename != a || e.cursor != (Cursor{Row: 1, Col: 2}) {
+		t.Fatalf("filename = %q cursor = %v", e.filename, e.cursor)
+	}
+
+	e.execCommand("bd")
+	if got := bufferOrder(e); got != "b.txt" {
+		t.Fatalf("after :bd buffers = %q", got)
+	}
+}
+
+func TestRequestOpenHandedOverFile(t *testing.T) {
+	e := newTestEditor("one")
+	e.filename = "/src/a.txt"
+	e.RequestOpen("/src/a.txt")
+	if e.openFileRequest != "" {
+		t.Fatalf("open file requested again: %q", e.openFileRequest)
+	}
+	e.RequestOpen("/src/b.txt")
+	if got := e.ConsumeOpenFileRequest(); got != "/src/b.txt" {
+		t.Fatalf("request = %q", got)
+	}
+	e.dirty = true
+	e.RequestOpen("/src/c.txt")
+	if e.openFileRequest != "" || e.statusMessage != "c.txt handed over: unsaved changes (use :w first)" {
+		t.Fatalf("dirty: request %q, status %q", e.openFileRequest, e.statusMessage)
+	}
+}
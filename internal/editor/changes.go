@@ -0,0 +1,156 @@
+package editor
+
+import "strings"
+
+// Range is a span of buffer positions, End exclusive
+type Range struct {
+	Start Cursor
+	End   Cursor
+}
+
+// TextChange describes one edit: the text at Range (in coordinates before the
+// edit) was replaced by NewText. Changes of one undo step share a ChangeTick
+// and must be applied in order.
+type TextChange struct {
+	Range      Range
+	OldText    string
+	NewText    string
+	ChangeTick uint64
+}
+
+// ChangeListener is called after every buffer edit, including undo and redo
+type ChangeListener func(TextChange)
+
+type changeSubscription struct {
+	id uint64
+	fn ChangeListener
+}
+
+// OnChange subscribes fn to buffer edits and returns a function that removes
+// the subscription. Opening a file is not reported as a change.
+func (e *Editor) OnChange(fn ChangeListener) func() {
+	e.nextChangeListenerID++
+	id := e.nextChangeListenerID
+	e.changeListeners = append(e.changeListeners, changeSubscription{id: id, fn: fn})
+	return func() {
+		for i, sub := range e.changeListeners {
+			if sub.id == id {
+				e.changeListeners = append(e.changeListeners[:i:i], e.changeListeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// queueChange records the change made by an edit, given the undo action that reverts it
+func (e *Editor) queueChange(undo action) {
+	if len(e.changeListeners) == 0 {
+		return
+	}
+	var change TextChange
+	switch undo.kind {
+	case actionDeleteRune:
+		change.Range = Range{Start: undo.pos, End: undo.pos}
+		change.NewText = string(undo.r)
+	case actionInsertRune:
+		change.Range = Range{Start: undo.pos, End: Cursor{Row: undo.pos.Row, Col: undo.pos.Col + 1}}
+		change.OldText = string(undo.r)
+	case actionJoinLine:
+		change.Range = Range{Start: undo.pos, End: undo.pos}
+		change.NewText = "\n"
+	case actionSplitLine:
+		change.Range = Range{Start: undo.pos, End: Cursor{Row: undo.pos.Row + 1}}
+		change.OldText = "\n"
+	case actionDeleteText:
+		change.Range = Range{Start: undo.pos, End: undo.pos}
+		change.NewText = joinLines(undo.text)
+	case actionInsertText:
+		change.Range = Range{Start: undo.pos, End: textEnd(undo.pos, undo.text)}
+		change.OldText = joinLines(undo.text)
+	case actionMoveLine:
+		// The lines are already swapped: report both rows as replaced
+		lo, hi := undo.rowFrom, undo.rowTo
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo < 0 || hi >= len(e.lines) {
+			return
+		}
+		change.Range = Range{Start: Cursor{Row: lo}, End: Cursor{Row: hi, Col: len(e.lines[lo])}}
+		change.OldText = joinLines([][]rune{e.lines[hi], e.lines[lo]})
+		change.NewText = joinLines([][]rune{e.lines[lo], e.lines[hi]})
+	default:
+		return
+	}
+	e.pendingChanges = append(e.pendingChanges, change)
+}
+
+// queueBufferReplace records a change replacing the whole buffer with text
+func (e *Editor) queueBufferReplace(text string) {
+	if len(e.changeListeners) == 0 {
+		return
+	}
+	last := len(e.lines) - 1
+	e.pendingChanges = append(e.pendingChanges, TextChange{
+		Range:   Range{End: Cursor{Row: last, Col: len(e.lines[last])}},
+		OldText: joinLines(e.lines),
+		NewText: text,
+	})
+}
+
+// flushChanges delivers queued changes stamped with the current change tick
+func (e *Editor) flushChanges() {
+	if len(e.pendingChanges) == 0 {
+		return
+	}
+	changes := e.pendingChanges
+	e.pendingChanges = nil
+	listeners := append([]changeSubscription(nil), e.changeListeners...)
+	for _, change := range changes {
+		change.ChangeTick = e.changeTick
+		for _, sub := range listeners {
+			sub.fn(change)
+		}
+	}
+}
+
+// textEnd returns the position after text inserted at pos
+func textEnd(pos Cursor, text [][]rune) Cursor {
+	if len(text) == 0 {
+		return pos
+	}
+	if len(text) == 1 {
+		return Cursor{Row: pos.Row, Col: pos.Col + len(text[0])}
+	}
+	return Cursor{Row: pos.Row + len(text) - 1, Col: len(text[len(text)-1])}
+}
+
+// ByteEdit returns c in the byte terms tree-sitter takes. It reads the
+// text before c.Range.Start from the buffer, so the buffer must not have
+// changed since c.
+func (e *Editor) ByteEdit(c TextChange) TextEdit {
+	startByte, startColBytes := e.byteOffset(c.Range.Start)
+	oldEndRow, oldEndColBytes := textEndBytes(c.Range.Start.Row, startColBytes, c.OldText)
+	newEndRow, newEndColBytes := textEndBytes(c.Range.Start.Row, startColBytes, c.NewText)
+	return TextEdit{
+		Valid:          true,
+		StartByte:      startByte,
+		OldEndByte:     startByte + len(c.OldText),
+		NewEndByte:     startByte + len(c.NewText),
+		StartRow:       c.Range.Start.Row,
+		StartColBytes:  startColBytes,
+		OldEndRow:      oldEndRow,
+		OldEndColBytes: oldEndColBytes,
+		NewEndRow:      newEndRow,
+		NewEndColBytes: newEndColBytes,
+	}
+}
+
+// textEndBytes returns the row and byte column after text put at row and
+// byte column col
+func textEndBytes(row, col int, text string) (int, int) {
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return row + strings.Count(text, "\n"), len(text) - i - 1
+	}
+	return row, col + len(text)
+}
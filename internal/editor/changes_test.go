@@ -0,0 +1,129 @@
+package editor
+
+import "testing"
+
+// applyTextChange applies change to lines, mirroring what a subscriber would do
+func applyTextChange(t *testing.T, lines [][]rune, change TextChange) [][]rune {
+	t.Helper()
+	start, end := change.Range.Start, change.Range.End
+	if start.Row < 0 || end.Row >= len(lines) || end.Col > len(lines[end.Row]) {
+		t.Fatalf("change range %+v outside buffer", change.Range)
+	}
+	old := collectRange(lines, start, end)
+	if old != change.OldText {
+		t.Fatalf("old text = %q, want %q", change.OldText, old)
+	}
+	prefix := string(lines[start.Row][:start.Col])
+	suffix := string(lines[end.Row][end.Col:])
+	replaced := splitLines([]byte(prefix + change.NewText + suffix))
+	result := append([][]rune(nil), lines[:start.Row]...)
+	result = append(result, replaced...)
+	return append(result, lines[end.Row+1:]...)
+}
+
+func collectRange(lines [][]rune, start, end Cursor) string {
+	if start.Row == end.Row {
+		return string(lines[start.Row][start.Col:end.Col])
+	}
+	text := string(lines[start.Row][start.Col:])
+	for row := start.Row + 1; row < end.Row; row++ {
+		text += "\n" + string(lines[row])
+	}
+	return text + "\n" + string(lines[end.Row][:end.Col])
+}
+
+func TestOnChangeStreamReproducesBuffer(t *testing.T) {
+	e := newTestEditor("first line", "second", "third")
+	shadow := splitLines([]byte(e.Content()))
+	var ticks []uint64
+	unsubscribe := e.OnChange(func(change TextChange) {
+		shadow = applyTextChange(t, shadow, change)
+		ticks = append(ticks, change.ChangeTick)
+	})
+
+	check := func(step string) {
+		t.Helper()
+		if got := joinLines(shadow); got != e.Content() {
+			t.Fatalf("%s: shadow = %q, buffer = %q", step, got, e.Content())
+		}
+	}
+
+	e.cursor = Cursor{Row: 0, Col: 5}
+	e.insertRune('X')
+	check("insert rune")
+	e.insertNewline()
+	check("newline")
+	e.backspace()
+	check("backspace join")
+	e.cursor = Cursor{Row: 0, Col: 11}
+	e.deleteWordLeft()
+	check("delete word left")
+	e.cursor = Cursor{Row: 1, Col: 0}
+	e.moveLineDown()
+	check("move line down")
+	e.deleteSelection(Cursor{Row: 0, Col: 2}, Cursor{Row: 1, Col: 3}, false)
+	check("delete selection")
+	if _, err := e.ReplaceRange(Cursor{Row: 0, Col: 0}, Cursor{Row: 0, Col: 2}, "a\nb"); err != nil {
+		t.Fatalf("ReplaceRange: %v", err)
+	}
+	check("replace range")
+	for i := 0; i < 3; i++ {
+		e.Undo()
+		check("undo")
+	}
+	e.Redo()
+	check("redo")
+	e.replaceBuffer("reloaded\ntext", false)
+	check("replace buffer")
+
+	for i := 1; i < len(ticks); i++ {
+		if ticks[i] < ticks[i-1] {
+			t.Fatalf("change ticks not monotonic: %v", ticks)
+		}
+	}
+	if last := ticks[len(ticks)-1]; last != e.ChangeTick() {
+		t.Fatalf("last change tick = %d, want %d", last, e.ChangeTick())
+	}
+
+	unsubscribe()
+	count := len(ticks)
+	e.insertRune('Z')
+	if len(ticks) != count {
+		t.Fatalf("listener called after unsubscribe")
+	}
+}
+
+func TestToggleCommentEmitsChanges(t *testing.T) {
+	e := newTestEditor("\ta := 1", "\tb := 2")
+	e.filename = "main.go"
+	shadow := splitLines([]byte(e.Content()))
+	var changes []TextChange
+	e.OnChange(func(change TextChange) {
+		shadow = applyTextChange(t, shadow, change)
+		changes = append(changes, change)
+	})
+
+	e.toggleLineComment()
+	if got := e.Content(); got != "\t// a := 1\n\tb := 2" {
+		t.Fatalf("commented: %q", got)
+	}
+	want := TextChange{Range: Range{Start: Cursor{Row: 0, Col: 1}, End: Cursor{Row: 0, Col: 1}}, NewText: "// "}
+	if len(changes) != 1 || changes[0].Range != want.Range || changes[0].OldText != "" || changes[0].NewText != want.NewText {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+
+	// Commenting is one undo step
+	e.Undo()
+	if got := joinLines(shadow); got != e.Content() || got != "\ta := 1\n\tb := 2" {
+		t.Fatalf("after undo: shadow = %q, buffer = %q", got, e.Content())
+	}
+
+	e.selectLines(0, 1)
+	e.toggleLineComment()
+	e.clearSelection()
+	e.cursor = Cursor{Row: 0}
+	e.toggleLineComment()
+	if got := joinLines(shadow); got != e.Content() || got != "\ta := 1\n\t// b := 2" {
+		t.Fatalf("uncommented: shadow = %q, buffer = %q", got, e.Content())
+	}
+}
@@ -0,0 +1,182 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// ColumnMode selects what the statusline Col segment counts
+type ColumnMode int
+
+const (
+	ColumnVisual ColumnMode = iota // screen column, tabs expanded
+	ColumnChar                     // characters (runes) from line start
+	ColumnByte                     // UTF-8 bytes from line start
+	ColumnAll                      // visual column with char and byte offsets
+)
+
+func parseColumnMode(value string) (ColumnMode, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "visual":
+		return ColumnVisual, true
+	case "char", "rune":
+		return ColumnChar, true
+	case "byte":
+		return ColumnByte, true
+	case "all":
+		return ColumnAll, true
+	default:
+		return ColumnVisual, false
+	}
+}
+
+func (m ColumnMode) String() string {
+	switch m {
+	case ColumnChar:
+		return "char"
+	case ColumnByte:
+		return "byte"
+	case ColumnAll:
+		return "all"
+	default:
+		return "visual"
+	}
+}
+
+// statusColumn formats the Col segment of the statusline (1-based)
+func (e *Editor) statusColumn() string {
+	var line []rune
+	if e.cursor.Row >= 0 && e.cursor.Row < len(e.lines) {
+		line = e.lines[e.cursor.Row]
+	}
+	col := clampRange(e.cursor.Col, 0, len(line))
+	visual := e.displayCol(line, col) + 1
+	switch e.columnMode {
+	case ColumnChar:
+		return fmt.Sprintf("Char %d", col+1)
+	case ColumnByte:
+		return fmt.Sprintf("Byte %d", textpos.RuneToByte(line, col)+1)
+	case ColumnAll:
+		return fmt.Sprintf("Col %d (char %d, byte %d)", visual, col+1, textpos.RuneToByte(line, col)+1)
+	default:
+		return fmt.Sprintf("Col %d", visual)
+	}
+}
+
+// describeCharUnderCursor reports the character under the cursor like vim's ga
+func (e *Editor) describeCharUnderCursor() string {
+	line := e.lineAt(e.cursor.Row)
+	if e.cursor.Col < 0 || e.cursor.Col >= len(line) {
+		if e.cursor.Row < len(e.lines)-1 {
+			return "<LF> U+000A dec 10, utf-8 0a, LINE FEED (end of line)"
+		}
+		return "end of buffer"
+	}
+	return describeRune(line[e.cursor.Col])
+}
+
+// describeRune returns the glyph, code point, UTF-8 bytes and a name or
+// Unicode category of r
+func describeRune(r rune) string {
+	buf := utf8.AppendRune(nil, r)
+	hex := make([]string, len(buf))
+	for i, b := range buf {
+		hex[i] = fmt.Sprintf("%02x", b)
+	}
+	glyph := string(r)
+	if !unicode.IsGraphic(r) || unicode.IsSpace(r) {
+		glyph = fmt.Sprintf("<%s>", controlGlyph(r))
+	} else if unicode.Is(unicode.Mn, r) {
+		// Combining marks need a base character to be visible
+		glyph = "◌" + glyph
+	}
+	return fmt.Sprintf("%s U+%04X dec %d, utf-8 %s, %s", glyph, r, r, strings.Join(hex, " "), runeName(r))
+}
+
+func controlGlyph(r rune) string {
+	switch {
+	case r < 0x20:
+		return "^" + string(r+'@')
+	case r == 0x7f:
+		return "^?"
+	}
+	return fmt.Sprintf("U+%04X", r)
+}
+
+// runeName returns a name for ASCII and commonly confused invisible or
+// typographic characters, and script plus category for everything else
+func runeName(r rune) string {
+	if name, ok := runeNames[r]; ok {
+		return name
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return "LATIN CAPITAL LETTER " + string(r)
+	case r >= 'a' && r <= 'z':
+		return "LATIN SMALL LETTER " + strings.ToUpper(string(r))
+	case r >= '0' && r <= '9':
+		return "DIGIT " + digitNames[r-'0']
+	}
+	desc := runeCategory(r)
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+			return name + " " + desc
+		}
+	}
+	return desc
+}
+
+func runeCategory(r rune) string {
+	categories := []struct {
+		name  string
+		table *unicode.RangeTable
+	}{
+		{"Lu", unicode.Lu}, {"Ll", unicode.Ll}, {"Lt", unicode.Lt}, {"Lm", unicode.Lm}, {"Lo", unicode.Lo},
+		{"Mn", unicode.Mn}, {"Mc", unicode.Mc}, {"Me", unicode.Me},
+		{"Nd", unicode.Nd}, {"Nl", unicode.Nl}, {"No", unicode.No},
+		{"P", unicode.P}, {"Sm", unicode.Sm}, {"Sc", unicode.Sc}, {"Sk", unicode.Sk}, {"So", unicode.So},
+		{"Zs", unicode.Zs}, {"Cc", unicode.Cc}, {"Cf", unicode.Cf}, {"Co", unicode.Co},
+	}
+	descs := map[byte]string{'L': "letter", 'M': "mark", 'N': "number", 'P': "punctuation", 'S': "symbol", 'Z': "space", 'C': "control"}
+	for _, c := range categories {
+		if unicode.Is(c.table, r) {
+			return fmt.Sprintf("%s (%s)", descs[c.name[0]], c.name)
+		}
+	}
+	return "unassigned"
+}
+
+var digitNames = [...]string{"ZERO", "ONE", "TWO", "THREE", "FOUR", "FIVE", "SIX", "SEVEN", "EIGHT", "NINE"}
+
+var runeNames = map[rune]string{
+	0x00: "NULL", 0x07: "BELL", 0x08: "BACKSPACE", 0x09: "CHARACTER TABULATION",
+	0x0A: "LINE FEED", 0x0B: "LINE TABULATION", 0x0C: "FORM FEED", 0x0D: "CARRIAGE RETURN",
+	0x1B: "ESCAPE", 0x7F: "DELETE",
+	' ': "SPACE", '!': "EXCLAMATION MARK", '"': "QUOTATION MARK", '#': "NUMBER SIGN",
+	'$': "DOLLAR SIGN", '%': "PERCENT SIGN", '&': "AMPERSAND", '\'': "APOSTROPHE",
+	'(': "LEFT PARENTHESIS", ')': "RIGHT PARENTHESIS", '*': "ASTERISK", '+': "PLUS SIGN",
+	',': "COMMA", '-': "HYPHEN-MINUS", '.': "FULL STOP", '/': "SOLIDUS",
+	':': "COLON", ';': "SEMICOLON", '<': "LESS-THAN SIGN", '=': "EQUALS SIGN",
+	'>': "GREATER-THAN SIGN", '?': "QUESTION MARK", '@': "COMMERCIAL AT",
+	'[': "LEFT SQUARE BRACKET", '\\': "REVERSE SOLIDUS", ']': "RIGHT SQUARE BRACKET",
+	'^': "CIRCUMFLEX ACCENT", '_': "LOW LINE", '`': "GRAVE ACCENT",
+	'{': "LEFT CURLY BRACKET", '|': "VERTICAL LINE", '}': "RIGHT CURLY BRACKET", '~': "TILDE",
+	0x00A0: "NO-BREAK SPACE", 0x00AD: "SOFT HYPHEN", 0x034F: "COMBINING GRAPHEME JOINER",
+	0x2002: "EN SPACE", 0x2003: "EM SPACE", 0x2009: "THIN SPACE", 0x200A: "HAIR SPACE",
+	0x200B: "ZERO WIDTH SPACE", 0x200C: "ZERO WIDTH NON-JOINER", 0x200D: "ZERO WIDTH JOINER",
+	0x200E: "LEFT-TO-RIGHT MARK", 0x200F: "RIGHT-TO-LEFT MARK",
+	0x2010: "HYPHEN", 0x2011: "NON-BREAKING HYPHEN", 0x2013: "EN DASH", 0x2014: "EM DASH",
+	0x2018: "LEFT SINGLE QUOTATION MARK", 0x2019: "RIGHT SINGLE QUOTATION MARK",
+	0x201C: "LEFT DOUBLE QUOTATION MARK", 0x201D: "RIGHT DOUBLE QUOTATION MARK",
+	0x2026: "HORIZONTAL ELLIPSIS", 0x2028: "LINE SEPARATOR", 0x2029: "PARAGRAPH SEPARATOR",
+	0x202A: "LEFT-TO-RIGHT EMBEDDING", 0x202B: "RIGHT-TO-LEFT EMBEDDING", 0x202C: "POP DIRECTIONAL FORMATTING",
+	0x202D: "LEFT-TO-RIGHT OVERRIDE", 0x202E: "RIGHT-TO-LEFT OVERRIDE", 0x202F: "NARROW NO-BREAK SPACE",
+	0x2060: "WORD JOINER", 0x2066: "LEFT-TO-RIGHT ISOLATE", 0x2067: "RIGHT-TO-LEFT ISOLATE",
+	0x2068: "FIRST STRONG ISOLATE", 0x2069: "POP DIRECTIONAL ISOLATE",
+	0x3000: "IDEOGRAPHIC SPACE", 0xFE0F: "VARIATION SELECTOR-16",
+	0xFEFF: "ZERO WIDTH NO-BREAK SPACE (BOM)", 0xFFFD: "REPLACEMENT CHARACTER",
+}
@@ -0,0 +1,57 @@
+package editor
+
+import "testing"
+
+func TestDescribeRune(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want string
+	}{
+		{'a', "a U+0061 dec 97, utf-8 61, LATIN SMALL LETTER A"},
+		{'\t', "<^I> U+0009 dec 9, utf-8 09, CHARACTER TABULATION"},
+		{'é', "é U+00E9 dec 233, utf-8 c3 a9, Latin letter (Ll)"},
+		{'ж', "ж U+0436 dec 1078, utf-8 d0 b6, Cyrillic letter (Ll)"},
+		{0x200B, "<U+200B> U+200B dec 8203, utf-8 e2 80 8b, ZERO WIDTH SPACE"},
+		{0x0301, "◌́ U+0301 dec 769, utf-8 cc 81, mark (Mn)"},
+		{'😀', "😀 U+1F600 dec 128512, utf-8 f0 9f 98 80, symbol (So)"},
+	}
+	for _, tt := range tests {
+		if got := describeRune(tt.r); got != tt.want {
+			t.Fatalf("describeRune(%U) = %q, want %q", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestCharCommandAndColumnModes(t *testing.T) {
+	e := newTestEditor("\tжa", "")
+	e.cursor = Cursor{Row: 0, Col: 2}
+	e.execCommand("char")
+	if e.statusMessage != "a U+0061 dec 97, utf-8 61, LATIN SMALL LETTER A" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"visual", "Col 6"},
+		{"char", "Char 3"},
+		{"byte", "Byte 4"},
+		{"all", "Col 6 (char 3, byte 4)"},
+	}
+	for _, tt := range tests {
+		e.execCommand("col " + tt.mode)
+		if got := e.statusColumn(); got != tt.want {
+			t.Fatalf(":col %s -> %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+	e.execCommand("col nope")
+	if e.statusMessage != "usage: :col visual|char|byte|all" || e.columnMode != ColumnAll {
+		t.Fatalf("invalid mode: status = %q, mode = %v", e.statusMessage, e.columnMode)
+	}
+
+	e.cursor = Cursor{Row: 0, Col: 3}
+	if got := e.describeCharUnderCursor(); got != "<LF> U+000A dec 10, utf-8 0a, LINE FEED (end of line)" {
+		t.Fatalf("end of line = %q", got)
+	}
+}
@@ -0,0 +1,253 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// maxCmdHistory is how many commands the history file keeps
+const maxCmdHistory = 1000
+
+// historySearchPrompt starts the command line during a Ctrl+R search
+const historySearchPrompt = "(history) "
+
+// LoadCmdHistory loads command history from file
+func (e *Editor) LoadCmdHistory() {
+	if e.noState {
+		return
+	}
+	if history, ok := readCmdHistory(); ok {
+		e.cmdHistory = history
+	}
+}
+
+// readCmdHistory returns the commands in the history file, oldest first,
+// each only once. ok is false when the file can't be read.
+func readCmdHistory() (history []string, ok bool) {
+	path, err := historyFilePath()
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false // File doesn't exist yet, that's ok
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			history = append(history, line)
+		}
+	}
+	return dedupeHistory(history), true
+}
+
+// dedupeHistory keeps only the newest copy of each command
+func dedupeHistory(history []string) []string {
+	seen := make(map[string]bool, len(history))
+	kept := make([]string, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		if !seen[history[i]] {
+			seen[history[i]] = true
+			kept = append(kept, history[i])
+		}
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	return kept
+}
+
+// addCmdHistory makes cmd the newest history entry, dropping older
+// copies of it. Other instances may have written the file since it was
+// loaded, so their commands are read back first and kept.
+func (e *Editor) addCmdHistory(cmd string) {
+	e.reloadCmdHistory()
+	e.cmdHistory = dedupeHistory(append(e.cmdHistory, cmd))
+	e.saveCmdHistory()
+}
+
+// deleteCmdHistory removes cmd from the history and the history file
+func (e *Editor) deleteCmdHistory(cmd string) {
+	e.reloadCmdHistory()
+	kept := e.cmdHistory[:0]
+	for _, entry := range e.cmdHistory {
+		if entry != cmd {
+			kept = append(kept, entry)
+		}
+	}
+	e.cmdHistory = kept
+	e.saveCmdHistory()
+	e.setStatus("removed from history: " + cmd)
+}
+
+// reloadCmdHistory replaces the history with the file's, if it has one
+func (e *Editor) reloadCmdHistory() {
+	if e.noState {
+		return
+	}
+	if history, ok := readCmdHistory(); ok {
+		e.cmdHistory = history
+	}
+}
+
+// saveCmdHistory saves command history to file. The file is replaced
+// with a rename, so another instance never reads it half written.
+func (e *Editor) saveCmdHistory() {
+	if e.noState {
+		return
+	}
+	path, err := historyFilePath()
+	if err != nil {
+		return
+	}
+	// Ensure directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	history := e.cmdHistory
+	if len(history) > maxCmdHistory {
+		history = history[len(history)-maxCmdHistory:]
+	}
+	tmp, err := os.CreateTemp(dir, ".history-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.WriteString(strings.Join(history, "\n"))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// startHistorySearch begins a readline-style reverse search (Ctrl+R):
+// typing narrows to the newest command containing the text, Ctrl+R
+// again steps to older ones
+func (e *Editor) startHistorySearch() {
+	e.cmdHistorySearch = true
+	e.cmdHistoryQuery = nil
+	e.cmdHistorySaved = append([]rune(nil), e.cmd...)
+	e.cmdHistoryIndex = -1
+}
+
+// handleHistorySearch handles a key during the reverse search. Enter runs
+// the match, Esc or Ctrl+G restores the command line, Shift+Del forgets
+// the match and any other key takes the match for editing.
+func (e *Editor) handleHistorySearch(ev *tcell.EventKey) bool {
+	switch ev.Key() {
+	case tcell.KeyCtrlR:
+		from := e.cmdHistoryIndex - 1
+		if e.cmdHistoryIndex < 0 {
+			from = len(e.cmdHistory) - 1
+		}
+		if i := e.findCmdHistory(from); i >= 0 {
+			e.cmdHistoryIndex = i
+		}
+		return false
+	case tcell.KeyRune:
+		e.cmdHistoryQuery = append(e.cmdHistoryQuery, ev.Rune())
+		e.researchCmdHistory()
+		return false
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(e.cmdHistoryQuery) > 0 {
+			e.cmdHistoryQuery = e.cmdHistoryQuery[:len(e.cmdHistoryQuery)-1]
+			e.cmdHistoryIndex = -1
+			e.researchCmdHistory()
+		}
+		return false
+	case tcell.KeyEscape, tcell.KeyCtrlG:
+		e.cmd = e.cmdHistorySaved
+		e.cmdCursor = len(e.cmd)
+		e.endHistorySearch()
+		return false
+	case tcell.KeyDelete:
+		if ev.Modifiers()&tcell.ModShift != 0 {
+			if match, ok := e.historySearchMatch(); ok {
+				e.deleteCmdHistory(match)
+				e.cmdHistoryIndex = -1
+				e.researchCmdHistory()
+			}
+			return false
+		}
+	}
+
+	// Any other key takes the match to the command line and acts there
+	if match, ok := e.historySearchMatch(); ok {
+		e.cmd = []rune(match)
+	} else {
+		e.cmd = e.cmdHistorySaved
+	}
+	e.cmdCursor = len(e.cmd)
+	e.endHistorySearch()
+	return e.handleCommand(ev)
+}
+
+func (e *Editor) endHistorySearch() {
+	e.cmdHistorySearch = false
+	e.cmdHistoryQuery = nil
+	e.cmdHistorySaved = nil
+	e.cmdHistoryIndex = -1
+}
+
+// researchCmdHistory looks for the query again from the current match,
+// so typing more keeps the match while it still contains the text
+func (e *Editor) researchCmdHistory() {
+	from := e.cmdHistoryIndex
+	if from < 0 {
+		from = len(e.cmdHistory) - 1
+	}
+	if i := e.findCmdHistory(from); i >= 0 {
+		e.cmdHistoryIndex = i
+	}
+}
+
+// findCmdHistory returns the newest entry at or before from containing the
+// query, or -1
+func (e *Editor) findCmdHistory(from int) int {
+	query := string(e.cmdHistoryQuery)
+	for i := min(from, len(e.cmdHistory)-1); i >= 0; i-- {
+		if strings.Contains(e.cmdHistory[i], query) {
+			return i
+		}
+	}
+	return -1
+}
+
+// historySearchMatch returns the entry the search is on, if it contains
+// the query
+func (e *Editor) historySearchMatch() (string, bool) {
+	i := e.cmdHistoryIndex
+	if i < 0 || i >= len(e.cmdHistory) || !strings.Contains(e.cmdHistory[i], string(e.cmdHistoryQuery)) {
+		return "", false
+	}
+	return e.cmdHistory[i], true
+}
+
+// historySearchLine is the command line shown during the search:
+// the prompt, the query and the match
+func (e *Editor) historySearchLine() []rune {
+	line := []rune(historySearchPrompt)
+	line = append(line, e.cmdHistoryQuery...)
+	if match, ok := e.historySearchMatch(); ok {
+		line = append(line, []rune(" → :"+match)...)
+	} else if len(e.cmdHistoryQuery) > 0 {
+		line = append(line, []rune(" (no match)")...)
+	}
+	return line
+}
+
+// historySearchCursor is the cursor column during the search, after the
+// query
+func (e *Editor) historySearchCursor() int {
+	return len([]rune(historySearchPrompt)) + len(e.cmdHistoryQuery)
+}
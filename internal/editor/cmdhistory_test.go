@@ -0,0 +1,112 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// typeCommand types :text and presses Enter
+func typeCommand(e *Editor, text string) {
+	e.mode = ModeCommand
+	for _, r := range text {
+		e.HandleKey(keyRune(r))
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+}
+
+func readHistoryFile(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(string(data), "\n")
+}
+
+func TestCmdHistoryDedupeAndSync(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+	if err := os.WriteFile(filepath.Join(dir, "history"), []byte("ln off\nchar\nln off\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, b := newTestEditor("one"), newTestEditor("one")
+	a.LoadCmdHistory()
+	b.LoadCmdHistory()
+	if want := []string{"char", "ln off"}; !reflect.DeepEqual(a.cmdHistory, want) {
+		t.Fatalf("loaded %q, want %q", a.cmdHistory, want)
+	}
+
+	// Both instances add commands; neither loses the other's
+	typeCommand(a, "col char")
+	typeCommand(b, "char")
+	if want := []string{"ln off", "col char", "char"}; !reflect.DeepEqual(readHistoryFile(t, dir), want) {
+		t.Fatalf("history file = %q, want %q", readHistoryFile(t, dir), want)
+	}
+
+	// Shift+Del on a history entry forgets it everywhere
+	a.mode = ModeCommand
+	a.HandleKey(tcell.NewEventKey(tcell.KeyUp, 0, 0))
+	if string(a.cmd) != "col char" {
+		t.Fatalf("Up = %q", string(a.cmd))
+	}
+	a.HandleKey(tcell.NewEventKey(tcell.KeyDelete, 0, tcell.ModShift))
+	if want := []string{"ln off", "char"}; !reflect.DeepEqual(readHistoryFile(t, dir), want) {
+		t.Fatalf("history file = %q, want %q", readHistoryFile(t, dir), want)
+	}
+	if len(a.cmd) != 0 || a.statusMessage != "removed from history: col char" {
+		t.Fatalf("cmd = %q status = %q", string(a.cmd), a.statusMessage)
+	}
+}
+
+func TestCmdHistorySearch(t *testing.T) {
+	t.Setenv("QEDIT_CONFIG_HOME", t.TempDir())
+	e := newTestEditor("one")
+	e.cmdHistory = []string{"ln relative", "col char", "ln off", "char"}
+	ctrlR := tcell.NewEventKey(tcell.KeyCtrlR, 0, 0)
+
+	e.mode = ModeCommand
+	e.HandleKey(keyRune('c'))
+	e.HandleKey(ctrlR)
+	for _, r := range "ln" {
+		e.HandleKey(keyRune(r))
+	}
+	if got := string(e.historySearchLine()); got != "(history) ln → :ln off" {
+		t.Fatalf("search line = %q", got)
+	}
+	e.HandleKey(ctrlR)
+	if got, _ := e.historySearchMatch(); got != "ln relative" {
+		t.Fatalf("Ctrl+R again = %q", got)
+	}
+	e.HandleKey(keyRune('x'))
+	if got := string(e.historySearchLine()); got != "(history) lnx (no match)" {
+		t.Fatalf("search line = %q", got)
+	}
+
+	// Esc restores what was typed before the search
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+	if e.cmdHistorySearch || string(e.cmd) != "c" || e.mode != ModeCommand {
+		t.Fatalf("after Esc: search=%v cmd=%q mode=%v", e.cmdHistorySearch, string(e.cmd), e.mode)
+	}
+
+	// Other keys take the match for editing; Enter runs it
+	e.HandleKey(ctrlR)
+	e.HandleKey(keyRune('o'))
+	e.HandleKey(keyRune('l'))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnd, 0, 0))
+	if e.cmdHistorySearch || string(e.cmd) != "col char" {
+		t.Fatalf("End took %q", string(e.cmd))
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyCtrlR, 0, 0))
+	for _, r := range "off" {
+		e.HandleKey(keyRune(r))
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if e.mode != ModeNormal || e.cmdHistory[len(e.cmdHistory)-1] != "ln off" {
+		t.Fatalf("Enter: mode=%v history=%q", e.mode, e.cmdHistory)
+	}
+}
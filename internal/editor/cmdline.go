@@ -0,0 +1,153 @@
+package editor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Command lines are split into words the way a shell does, so a path with
+// spaces or quotes works in every command: :w "my notes.txt" and
+// :w my\ notes.txt both write "my notes.txt". Path completion inserts
+// names escaped the same way.
+
+// splitCommand splits a command line into words. Whitespace separates
+// words, a backslash escapes the character after it, '...' is taken
+// literally and "..." allows \" and \\ inside.
+func splitCommand(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	quote := rune(0)
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if escaped {
+		word.WriteRune('\\')
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// quoteArg escapes s so splitCommand reads it back as one word
+func quoteArg(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case ' ', '\t', '\\', '\'', '"':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// boolOption reads the argument of :name [on|off] into on, or flips on
+// without one. Anything else shows the usage and returns false.
+func (e *Editor) boolOption(args []string, on *bool, name string) bool {
+	if len(args) == 0 {
+		*on = !*on
+		return true
+	}
+	switch strings.ToLower(args[0]) {
+	case "on":
+		*on = true
+	case "off":
+		*on = false
+	default:
+		e.setStatus("usage: :" + name + " [on|off]")
+		return false
+	}
+	return true
+}
+
+// pathCommands are the commands whose argument is a file path
+var pathCommands = map[string]bool{
+	"w": true, "w!": true, "wlink": true, "wq": true, "x": true, "tabnew": true,
+}
+
+// maxPathCompletions bounds the entries listed for a large directory
+const maxPathCompletions = 200
+
+// completePath lists the files and directories that complete the path
+// argument of :name, escaped for the command line. Directories end in
+// a slash so completing again descends into them. Entries come sorted by
+// name, as os.ReadDir returns them.
+func completePath(name, arg string) []CommandInfo {
+	words, err := splitCommand(arg)
+	if err != nil {
+		return nil
+	}
+	// Unquoted words are one path, as :w reads them
+	dir, base := filepath.Split(strings.Join(words, " "))
+	entries, err := os.ReadDir(dirOrDot(dir))
+	if err != nil {
+		return nil
+	}
+	var result []CommandInfo
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if !strings.HasPrefix(entryName, base) {
+			continue
+		}
+		if strings.HasPrefix(entryName, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		desc := "file"
+		if entry.IsDir() {
+			entryName += "/"
+			desc = "directory"
+		}
+		result = append(result, CommandInfo{name + " " + quoteArg(dir+entryName), desc, CmdGroupFile})
+		if len(result) == maxPathCompletions {
+			break
+		}
+	}
+	return result
+}
+
+func dirOrDot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
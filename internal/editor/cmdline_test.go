@@ -0,0 +1,98 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{"w a.txt", []string{"w", "a.txt"}},
+		{"  w   a  b ", []string{"w", "a", "b"}},
+		{`w my\ notes.txt`, []string{"w", "my notes.txt"}},
+		{`w "my notes.txt"`, []string{"w", "my notes.txt"}},
+		{`w 'it''s'`, []string{"w", "its"}},
+		{`w "say \"hi\"" 'a\b'`, []string{"w", `say "hi"`, `a\b`}},
+		{`w ""`, []string{"w", ""}},
+		{`w a\`, []string{"w", `a\`}},
+	}
+	for _, c := range cases {
+		got, err := splitCommand(c.line)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitCommand(%q) = %q, %v, want %q", c.line, got, err, c.want)
+		}
+	}
+	if _, err := splitCommand(`w "open`); err == nil {
+		t.Fatalf("unterminated quote accepted")
+	}
+
+	for _, s := range []string{"plain", "a b", `q"uo'te`, `back\slash`} {
+		if got, _ := splitCommand(quoteArg(s)); len(got) != 1 || got[0] != s {
+			t.Errorf("quoteArg(%q) reads back as %q", s, got)
+		}
+	}
+}
+
+func TestWriteQuotedPath(t *testing.T) {
+	dir := t.TempDir()
+	e := newTestEditor("hello")
+	path := filepath.Join(dir, `my "notes".txt`)
+	e.execCommand("w " + quoteArg(path))
+	if data, err := os.ReadFile(path); err != nil || string(data) != "hello" {
+		t.Fatalf("read %q: %q, %v (status %q)", path, data, err, e.statusMessage)
+	}
+	e.execCommand(`w "` + dir + `/unterminated`)
+	if e.statusMessage != "unterminated quote" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestBoolOptionCommands(t *testing.T) {
+	e := newTestEditor("hello")
+	for _, cmd := range []string{"subword on", "findlines", "bufferline OFF", "bom on"} {
+		e.execCommand(cmd)
+	}
+	if !e.subword || !e.findLines || e.bufferline || !e.bom {
+		t.Fatalf("subword %v, findlines %v, bufferline %v, bom %v", e.subword, e.findLines, e.bufferline, e.bom)
+	}
+	e.execCommand("subword")
+	if e.subword {
+		t.Fatalf(":subword without an argument didn't toggle it off")
+	}
+	e.execCommand("autocorrect yes")
+	if e.autocorrect || e.statusMessage != "usage: :autocorrect [on|off]" {
+		t.Fatalf("autocorrect %v, status %q", e.autocorrect, e.statusMessage)
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"my notes.txt", "mine", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "my dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, c := range filterCommands("w " + quoteArg(dir+"/my")) {
+		names = append(names, c.Name)
+	}
+	want := []string{
+		"w " + quoteArg(dir+"/my dir/"),
+		"w " + quoteArg(dir+"/my notes.txt"),
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("completions = %q, want %q", names, want)
+	}
+	if got := filterCommands("tabnew " + quoteArg(dir+"/.h")); len(got) != 1 || got[0].Name != "tabnew "+quoteArg(dir+"/.hidden") {
+		t.Fatalf("hidden completions = %+v", got)
+	}
+}
@@ -0,0 +1,86 @@
+package editor
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// execCodecCommand runs :encode/:decode base64|url on the selection, replacing
+// it in place as a single undo step and selecting the result
+func (e *Editor) execCodecCommand(name string, args []string) {
+	if len(args) != 1 {
+		e.setStatus("usage: :" + name + " base64|url")
+		return
+	}
+	start, end, ok := e.selectionRange()
+	if !ok {
+		e.setStatus("no selection")
+		return
+	}
+	if e.refuseEdit() {
+		return
+	}
+	text := e.textInRange(start, end)
+	var out string
+	var err error
+	switch strings.ToLower(args[0]) {
+	case "base64", "b64":
+		if name == "encode" {
+			out = base64.StdEncoding.EncodeToString([]byte(text))
+		} else {
+			out, err = decodeBase64(text)
+		}
+	case "url":
+		if name == "encode" {
+			out = url.QueryEscape(text)
+		} else {
+			out, err = url.QueryUnescape(text)
+		}
+	default:
+		e.setStatus("unknown encoding: " + args[0] + " (use base64 or url)")
+		return
+	}
+	if err != nil {
+		e.setStatus(name + " " + args[0] + ": " + err.Error())
+		return
+	}
+	newEnd, err := e.ReplaceRange(start, end, out)
+	if err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	e.selectionActive = true
+	e.selectionStart = start
+	e.selectionEnd = newEnd
+	e.cursor = newEnd
+	e.clampCursorCol()
+	e.setStatus(name + "d " + strings.ToLower(args[0]))
+}
+
+// decodeBase64 accepts standard and URL-safe alphabets with or without
+// padding and ignores line breaks (wrapped PEM-style input)
+func decodeBase64(text string) (string, error) {
+	text = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, text)
+	var data []byte
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err = enc.DecodeString(text); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", errors.New("invalid base64 input")
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("decoded data is not valid UTF-8")
+	}
+	return string(data), nil
+}
@@ -0,0 +1,64 @@
+package editor
+
+import "testing"
+
+func TestEncodeDecodeSelection(t *testing.T) {
+	e := newTestEditor(`token = "hello world?"`)
+	e.SetReadOnly(true) // --readonly only refuses the write
+	e.selectionActive = true
+	e.selectionStart = Cursor{Row: 0, Col: 9}
+	e.selectionEnd = Cursor{Row: 0, Col: 21}
+
+	e.execCommand("encode base64")
+	if got := e.Content(); got != `token = "aGVsbG8gd29ybGQ/"` {
+		t.Fatalf("encode base64 = %q", got)
+	}
+	e.execCommand("decode base64")
+	if got := e.Content(); got != `token = "hello world?"` {
+		t.Fatalf("decode base64 = %q", got)
+	}
+	e.execCommand("encode url")
+	if got := e.Content(); got != `token = "hello+world%3F"` {
+		t.Fatalf("encode url = %q", got)
+	}
+	if start, end, _ := e.selectionRange(); start.Col != 9 || end.Col != 23 {
+		t.Fatalf("selection = %v..%v, want the encoded text", start, end)
+	}
+	e.execCommand("decode url")
+	if got := e.Content(); got != `token = "hello world?"` {
+		t.Fatalf("decode url = %q", got)
+	}
+
+	// Each command is a single undo step
+	e.Undo()
+	if got := e.Content(); got != `token = "hello+world%3F"` {
+		t.Fatalf("undo = %q", got)
+	}
+}
+
+func TestDecodeBase64Variants(t *testing.T) {
+	for _, in := range []string{"aGk/Pz4+", "aGk_Pz4-", "aGk/\nPz4+", "YQ", "YQ=="} {
+		if _, err := decodeBase64(in); err != nil {
+			t.Fatalf("decodeBase64(%q): %v", in, err)
+		}
+	}
+	if _, err := decodeBase64("not base64!"); err == nil {
+		t.Fatalf("decodeBase64 accepted invalid input")
+	}
+	if _, err := decodeBase64("/w=="); err == nil || err.Error() != "decoded data is not valid UTF-8" {
+		t.Fatalf("decodeBase64(binary) err = %v", err)
+	}
+
+	e := newTestEditor("%zz")
+	e.selectionActive = true
+	e.selectionEnd = Cursor{Row: 0, Col: 3}
+	e.execCommand("decode url")
+	if e.Content() != "%zz" || e.statusMessage != `decode url: invalid URL escape "%zz"` {
+		t.Fatalf("content = %q, status = %q", e.Content(), e.statusMessage)
+	}
+	e.clearSelection()
+	e.execCommand("encode url")
+	if e.statusMessage != "no selection" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
@@ -0,0 +1,301 @@
+package editor
+
+import (
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kobzarvs/qedit/internal/collab"
+	"github.com/kobzarvs/qedit/pkg/core"
+)
+
+// defaultCollabAddr is where :collab host listens without an address
+const defaultCollabAddr = "localhost:7411"
+
+// SetWake sets the function that wakes the event loop up, for work
+// finished off the UI goroutine such as edits from a shared session
+func (e *Editor) SetWake(fn func()) {
+	e.wake = fn
+}
+
+// execCollabCommand shares the buffer with another qedit, joins a buffer
+// shared by one with the token it shows or leaves (:collab host [ADDR],
+// :collab join ADDR TOKEN, :collab stop). Without arguments it shows the
+// session.
+func (e *Editor) execCollabCommand(args []string) {
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch {
+	case sub == "" && len(args) == 0:
+		e.setStatus(e.collabStatus())
+	case (sub == "host" && len(args) <= 2) || (sub == "join" && len(args) == 3):
+		if e.collab != nil {
+			e.setStatus("collab: already sharing (:collab stop first)")
+			return
+		}
+		if e.preview {
+			e.setStatus("collab: buffer is a preview")
+			return
+		}
+		var s *collab.Session
+		var err error
+		if sub == "host" {
+			addr := defaultCollabAddr
+			if len(args) == 2 {
+				addr = args[1]
+			}
+			name := "untitled"
+			if e.filename != "" {
+				name = filepath.Base(e.filename)
+			}
+			s, err = collab.Host(addr, name, joinLines(e.lines), e.wake)
+		} else {
+			if e.filename != "" || e.dirty {
+				e.setStatus("collab: join from an empty buffer (:bd first)")
+				return
+			}
+			s, err = collab.Join(args[1], args[2], e.wake)
+		}
+		if err != nil {
+			e.setStatus("collab: " + err.Error())
+			return
+		}
+		e.collab = s
+		e.collabStop = e.OnChange(func(c TextChange) {
+			if !e.collabApplying {
+				s.Change(c.Range.Start.Row, c.Range.Start.Col, runeLen(c.OldText), c.NewText)
+			}
+		})
+		e.setStatus(e.collabStatus())
+	case sub == "stop" && len(args) == 1:
+		if e.collab == nil {
+			e.setStatus("collab: not sharing")
+			return
+		}
+		e.stopCollab()
+		e.setStatus("collab: stopped")
+	default:
+		e.setStatus("usage: :collab [host [ADDR]|join ADDR TOKEN|stop]")
+	}
+}
+
+// collabStatus describes the shared session
+func (e *Editor) collabStatus() string {
+	switch {
+	case e.collab == nil:
+		return "collab: not sharing"
+	case e.collab.Connected():
+		return "collab: editing " + e.collab.Name() + " together"
+	case e.collab.Addr() != "":
+		return "collab: hosting on " + e.collab.Addr() + ", waiting for a peer (:collab join " + e.collab.Addr() + " " + e.collab.Token() + ")"
+	}
+	return "collab: joining"
+}
+
+// stopCollab ends the shared session, if any
+func (e *Editor) stopCollab() {
+	if e.collab == nil {
+		return
+	}
+	e.collab.Close()
+	e.collabStop()
+	e.collab, e.collabStop = nil, nil
+}
+
+// PollCollab applies the edits of the other side of a shared session and
+// sends it the cursor. It reports whether the buffer or status changed.
+func (e *Editor) PollCollab() bool {
+	if e.collab == nil {
+		return false
+	}
+	u := e.collab.Poll()
+	e.collabApplying = true
+	if u.Reset != nil {
+		// The shared text is where history starts
+		e.replaceBuffer(*u.Reset, false)
+		e.cursor = Cursor{}
+	}
+	for _, edit := range u.Edits {
+		start := e.posAtOffset(edit.Offset)
+		end := e.posAtOffset(edit.Offset + edit.Delete)
+		e.applyRemoteEdit(start, end, edit.Text)
+	}
+	e.collabApplying = false
+	if u.Status != "" {
+		e.setStatus("collab: " + u.Status)
+	}
+	if e.collab.Closed() {
+		e.stopCollab()
+	} else {
+		e.collab.SetCursor(e.cursor.Row, e.cursor.Col)
+	}
+	return u.Reset != nil || len(u.Edits) > 0 || u.Status != ""
+}
+
+// applyRemoteEdit replaces [start, end) with text for the other side of a
+// shared session. It isn't an undo step here: undo and redo take back only
+// this side's edits, so their history moves past it, losing the steps it
+// overlaps.
+func (e *Editor) applyRemoteEdit(start, end Cursor, text string) {
+	if !e.validPos(start) || !e.validPos(end) {
+		return
+	}
+	start, end = core.Order(start, end)
+	if start == end && text == "" {
+		return
+	}
+	lines := splitLines([]byte(text))
+	cursor := e.cursor
+	oldText := e.textInRange(start, end)
+	e.deleteTextRange(start, end)
+	newEnd := e.insertTextAt(start, lines)
+	if len(e.changeListeners) > 0 {
+		e.pendingChanges = append(e.pendingChanges, TextChange{Range: Range{Start: start, End: end}, OldText: oldText, NewText: text})
+	}
+	e.undo = shiftActions(e.undo, start, end, newEnd)
+	e.redo = shiftActions(e.redo, start, end, newEnd)
+	e.cursor = shiftPosForEdit(cursor, start, end, newEnd)
+	if e.selectionActive {
+		e.selectionStart = shiftPosForEdit(e.selectionStart, start, end, newEnd)
+		e.selectionEnd = shiftPosForEdit(e.selectionEnd, start, end, newEnd)
+	}
+	e.clampCursorCol()
+	// No revision in the history has this text, the one on disk included
+	e.savedRevision = unsavedRevision
+	e.changeTick++
+	e.updateDirty()
+	e.flushChanges()
+}
+
+// shiftActions moves the actions of an undo or redo stack, applied from
+// its top, through an edit made before the top one: each action moves
+// through the edit, and the edit through the action, to be where the
+// action below sees it. An action the edit overlaps can't be applied
+// anymore, so it goes with its group and everything below.
+func shiftActions(stack []action, start, oldEnd, newEnd Cursor) []action {
+	for i := len(stack) - 1; i >= 0; i-- {
+		act := &stack[i]
+		a, b, c := act.span()
+		if spansOverlap(start, oldEnd, a, b) {
+			j := i + 1
+			for j < len(stack) && stack[j].group == act.group {
+				j++
+			}
+			return append(stack[:0], stack[j:]...)
+		}
+		act.shift(start, oldEnd, newEnd)
+		// The edit as the action below sees it; text at the start of the
+		// action's span stays before it
+		at := func(p Cursor) Cursor {
+			if p == a {
+				return p
+			}
+			return shiftPosForEdit(p, a, b, c)
+		}
+		s := at(start)
+		if newEnd.Row == start.Row {
+			newEnd = Cursor{Row: s.Row, Col: s.Col + newEnd.Col - start.Col}
+		} else {
+			newEnd = Cursor{Row: s.Row + newEnd.Row - start.Row, Col: newEnd.Col}
+		}
+		start, oldEnd = s, at(oldEnd)
+	}
+	return stack
+}
+
+// span returns the text applying act replaces: [start, oldEnd), with text
+// ending at newEnd
+func (act *action) span() (start, oldEnd, newEnd Cursor) {
+	p := act.pos
+	switch act.kind {
+	case actionInsertRune:
+		return p, p, Cursor{Row: p.Row, Col: p.Col + 1}
+	case actionDeleteRune:
+		return p, Cursor{Row: p.Row, Col: p.Col + 1}, p
+	case actionSplitLine:
+		return p, p, Cursor{Row: p.Row + 1}
+	case actionJoinLine:
+		return p, Cursor{Row: p.Row + 1}, p
+	case actionInsertText:
+		return p, p, textEnd(p, act.text)
+	case actionDeleteText:
+		return p, act.endPos, p
+	case actionMoveLine:
+		lo, hi := min(act.rowFrom, act.rowTo), max(act.rowFrom, act.rowTo)
+		return Cursor{Row: lo}, Cursor{Row: hi + 1}, Cursor{Row: hi + 1}
+	}
+	return p, p, p
+}
+
+// shift moves act through an edit that didn't overlap its span. Text
+// inserted where it starts goes before it, where it ends after it.
+func (act *action) shift(start, oldEnd, newEnd Cursor) {
+	through := func(p Cursor) Cursor {
+		return shiftPosForEdit(p, start, oldEnd, newEnd)
+	}
+	if act.kind == actionDeleteText && act.endPos != start {
+		act.endPos = through(act.endPos)
+	}
+	act.pos = through(act.pos)
+	if act.kind == actionMoveLine {
+		act.rowFrom = through(Cursor{Row: act.rowFrom}).Row
+		act.rowTo = through(Cursor{Row: act.rowTo}).Row
+	}
+	act.cursorBefore, act.cursorAfter = through(act.cursorBefore), through(act.cursorAfter)
+	act.selectionStart, act.selectionEnd = through(act.selectionStart), through(act.selectionEnd)
+}
+
+// spansOverlap reports whether [s1, e1) and [s2, e2) share text, or one
+// is empty and lies inside the other
+func spansOverlap(s1, e1, s2, e2 Cursor) bool {
+	lo, hi := s1, e1
+	if cursorLess(lo, s2) {
+		lo = s2
+	}
+	if cursorLess(e2, hi) {
+		hi = e2
+	}
+	switch {
+	case cursorLess(lo, hi):
+		return true
+	case s1 == e1:
+		return cursorLess(s2, s1) && cursorLess(s1, e2)
+	case s2 == e2:
+		return cursorLess(s1, s2) && cursorLess(s2, e1)
+	}
+	return false
+}
+
+// posAtOffset returns the position offset runes into the buffer, line
+// breaks counting as one
+func (e *Editor) posAtOffset(offset int) Cursor {
+	for row, line := range e.lines {
+		if offset <= len(line) {
+			return Cursor{Row: row, Col: offset}
+		}
+		offset -= len(line) + 1
+	}
+	last := len(e.lines) - 1
+	return Cursor{Row: last, Col: len(e.lines[last])}
+}
+
+// drawPeerCursor marks the cell of the other side's cursor
+func (e *Editor) drawPeerCursor(s tcell.Screen, w, viewHeight int) {
+	if e.collab == nil {
+		return
+	}
+	row, col, ok := e.collab.PeerCursor()
+	if !ok || row >= len(e.lines) || e.foldHiding(row) >= 0 {
+		return
+	}
+	y := e.visibleIndex(row) - e.visibleIndex(e.scroll)
+	x := e.editorX + e.gutterWidth() + e.rowDisplayCol(row, col) - e.scrollX
+	if y < 0 || y >= viewHeight || x < e.editorX+e.gutterWidth() || x >= w {
+		return
+	}
+	r, combining, _, _ := s.GetContent(x, y)
+	peer, _, _ := e.styleDiagnosticInfo.Decompose()
+	_, bg, _ := e.styleMain.Decompose()
+	s.SetContent(x, y, r, combining, tcell.StyleDefault.Foreground(bg).Background(peer))
+}
@@ -0,0 +1,131 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// pollCollab polls e until cond holds or a second passed
+func pollCollab(t *testing.T, e *Editor, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out; content %q, status %q", e.Content(), e.statusMessage)
+		}
+		e.PollCollab()
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCollabEditTogether(t *testing.T) {
+	host := newTestEditor("package main", "")
+	host.filename = "main.go"
+	host.execCommand("collab host 127.0.0.1:0")
+	if host.collab == nil {
+		t.Fatalf("not hosting: %q", host.statusMessage)
+	}
+	defer host.stopCollab()
+	addr := host.collab.Addr()
+
+	peer := newTestEditor("")
+	if !strings.Contains(host.statusMessage, "collab join "+addr+" "+host.collab.Token()) {
+		t.Fatalf("host status = %q, want how to join", host.statusMessage)
+	}
+	peer.execCommand("collab join " + addr + " " + host.collab.Token())
+	if peer.collab == nil {
+		t.Fatalf("not joined: %q", peer.statusMessage)
+	}
+	defer peer.stopCollab()
+
+	pollCollab(t, host, func() bool { return host.collab.Connected() })
+	pollCollab(t, peer, func() bool { return peer.Content() == "package main\n" })
+	if peer.statusMessage != "collab: joined main.go" || peer.dirty {
+		t.Fatalf("status %q, dirty %v", peer.statusMessage, peer.dirty)
+	}
+
+	// Typing on both sides at once
+	peer.cursor = Cursor{Row: 1}
+	peer.mode = ModeInsert
+	for _, r := range "// hi" {
+		peer.HandleKey(keyRune(r))
+	}
+	if _, err := host.InsertAt(Cursor{Row: 0, Col: 0}, "// x\n"); err != nil {
+		t.Fatal(err)
+	}
+	want := "// x\npackage main\n// hi"
+	pollCollab(t, host, func() bool { return host.Content() == want })
+	pollCollab(t, peer, func() bool { return peer.Content() == want })
+
+	// The host's cursor shows on the peer's screen
+	host.cursor = Cursor{Row: 1, Col: 3}
+	host.PollCollab()
+	pollCollab(t, peer, func() bool {
+		row, col, ok := peer.collab.PeerCursor()
+		return ok && row == 1 && col == 3
+	})
+	s, rows := renderRows(t, peer, 40, 10)
+	x := strings.Index(rows[1], "package") + 3
+	_, _, style, _ := s.GetContent(x, 1)
+	peerColor, _, _ := peer.styleDiagnosticInfo.Decompose()
+	if _, bg, _ := style.Decompose(); bg != peerColor {
+		t.Fatalf("peer cursor not drawn at %d:1", x)
+	}
+
+	// Undo takes back the host's own edit, not the peer's typing
+	if len(host.undo) != 1 {
+		t.Fatalf("host undo has %d actions, want its own edit only", len(host.undo))
+	}
+	host.HandleKey(keyRune('u'))
+	want = "package main\n// hi"
+	if host.Content() != want {
+		t.Fatalf("host after u = %q, want %q", host.Content(), want)
+	}
+	pollCollab(t, peer, func() bool { return peer.Content() == want })
+	peer.HandleKey(keyEsc())
+	for range "// hi" {
+		peer.HandleKey(keyRune('u'))
+	}
+	want = "package main\n"
+	if peer.Content() != want {
+		t.Fatalf("peer after u = %q, want %q", peer.Content(), want)
+	}
+	pollCollab(t, host, func() bool { return host.Content() == want })
+	peer.HandleKey(keyRune('U'))
+	want = "package main\n/"
+	pollCollab(t, host, func() bool { return host.Content() == want })
+
+	peer.execCommand("collab stop")
+	pollCollab(t, host, func() bool { return host.statusMessage == "collab: peer left" })
+}
+
+func TestCollabJoinNeedsEmptyBuffer(t *testing.T) {
+	e := newTestEditor("x")
+	e.filename = "x.txt"
+	e.execCommand("collab join 127.0.0.1:1 token")
+	if e.collab != nil || !strings.Contains(e.statusMessage, "empty buffer") {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestRemoteEditMovesUndo(t *testing.T) {
+	e := newTestEditor("abc")
+	e.cursor = Cursor{Row: 0, Col: 3}
+	e.insertRune('d')
+	e.applyRemoteEdit(Cursor{Row: 0, Col: 0}, Cursor{Row: 0, Col: 1}, "x\ny")
+	if got := e.Content(); got != "x\nybcd" || !e.dirty {
+		t.Fatalf("content %q, dirty %v", got, e.dirty)
+	}
+	e.HandleKey(keyRune('u'))
+	if got := e.Content(); got != "x\nybc" {
+		t.Fatalf("after u = %q, want the remote edit kept", got)
+	}
+	e.HandleKey(keyRune('U'))
+
+	// Deleting what the local edit typed leaves it nothing to undo
+	e.applyRemoteEdit(Cursor{Row: 1, Col: 2}, Cursor{Row: 1, Col: 4}, "")
+	if len(e.undo) != 0 || e.Content() != "x\nyb" {
+		t.Fatalf("undo %+v, content %q", e.undo, e.Content())
+	}
+}
@@ -0,0 +1,273 @@
+package editor
+
+import "unicode"
+
+const actionToggleConceal = "toggle_conceal" // :conceal - hide markup on lines other than the cursor's
+
+// concealCell is how one rune of a concealed line is drawn: hidden, or
+// replaced by repl when it isn't 0
+type concealCell struct {
+	hidden bool
+	repl   rune
+}
+
+// concealRules are the file types with markup to conceal. A rule returns
+// the cells of line, or nil when nothing in it is concealed.
+var concealRules = []struct {
+	match func(name string) bool
+	cells func(line []rune) []concealCell
+}{
+	{isMarkdownFile, concealMarkdown},
+}
+
+// concealRule returns the rule for the open file, nil without one
+func (e *Editor) concealRule() func(line []rune) []concealCell {
+	for _, rule := range concealRules {
+		if rule.match(e.filename) {
+			return rule.cells
+		}
+	}
+	return nil
+}
+
+// concealCells returns how row is drawn with conceal on, or nil when it is
+// drawn as it is. The cursor line always shows its raw text, and so does
+// everything in code blocks.
+func (e *Editor) concealCells(row int) []concealCell {
+	if !e.conceal || e.ansiView || row == e.cursor.Row || row < 0 || row >= len(e.lines) {
+		return nil
+	}
+	rule := e.concealRule()
+	if rule == nil {
+		return nil
+	}
+	if e.concealFenced == nil || e.concealTick != e.changeTick || len(e.concealFenced) != len(e.lines) {
+		e.concealFenced = make([]bool, len(e.lines))
+		in := false
+		for i, line := range e.lines {
+			if isFenceLine(string(line)) {
+				in = !in
+				e.concealFenced[i] = true
+				continue
+			}
+			e.concealFenced[i] = in
+		}
+		e.concealTick = e.changeTick
+	}
+	if e.concealFenced[row] {
+		return nil
+	}
+	return rule(e.lines[row])
+}
+
+// concealMarkdown hides emphasis and strikethrough markers, code span
+// backticks, escaping backslashes and link targets ("[text](url)" shows
+// "text"), and draws list bullets as •
+func concealMarkdown(line []rune) []concealCell {
+	cells := make([]concealCell, len(line))
+	start := 0
+	if indent, text, ok := listItem(line); ok {
+		if r := line[indent]; r == '-' || r == '*' || r == '+' {
+			cells[indent].repl = '•'
+		}
+		start = text
+	} else if level, ok := atxHeading(line); ok {
+		start = firstNonBlank(line) + level
+	}
+	concealInline(line, cells, start, len(line))
+	for _, c := range cells {
+		if c.hidden || c.repl != 0 {
+			return cells
+		}
+	}
+	return nil
+}
+
+// concealInline marks the markdown markup in line[from:to]
+func concealInline(line []rune, cells []concealCell, from, to int) {
+	isWord := func(i int) bool {
+		return i >= from && i < to && (unicode.IsLetter(line[i]) || unicode.IsDigit(line[i]))
+	}
+	runLen := func(i int) int {
+		n := 0
+		for i+n < to && line[i+n] == line[i] {
+			n++
+		}
+		return n
+	}
+	hide := func(i, j int) {
+		for ; i < j; i++ {
+			cells[i].hidden = true
+		}
+	}
+	for i := from; i < to; {
+		r := line[i]
+		switch {
+		case r == '\\' && i+1 < to && (unicode.IsPunct(line[i+1]) || unicode.IsSymbol(line[i+1])):
+			cells[i].hidden = true
+			i += 2
+			continue
+
+		case r == '`':
+			n := runLen(i)
+			closing := -1
+			for j := i + n; j < to && closing < 0; {
+				m := runLen(j)
+				if line[j] == '`' && m == n {
+					closing = j
+				}
+				j += m
+			}
+			if closing < 0 {
+				i += n // literal backticks
+				continue
+			}
+			// Nothing inside a code span is markup
+			hide(i, i+n)
+			hide(closing, closing+n)
+			i = closing + n
+			continue
+
+		case r == '[' || r == '!' && i+1 < to && line[i+1] == '[':
+			open := i
+			if r == '!' {
+				open++
+			}
+			if end, target, ok := markdownLink(line, open, to); ok {
+				hide(i, open+1)
+				hide(end, target+1)
+				concealInline(line, cells, open+1, end)
+				i = target + 1
+				continue
+			}
+
+		case r == '*' || r == '_' || r == '~':
+			n := runLen(i)
+			if (r == '~' && n != 2) || n > 3 || i+n >= to || unicode.IsSpace(line[i+n]) || (r == '_' && isWord(i-1)) {
+				i += n
+				continue
+			}
+			closed := false
+			for j := i + n; j < to; {
+				if line[j] != r {
+					j++
+					continue
+				}
+				m := runLen(j)
+				if m == n && !unicode.IsSpace(line[j-1]) && !(r == '_' && isWord(j+m)) {
+					hide(i, i+n)
+					hide(j, j+n)
+					concealInline(line, cells, i+n, j)
+					i = j + n
+					closed = true
+					break
+				}
+				j += m
+			}
+			if !closed {
+				i += n
+			}
+			continue
+		}
+		i++
+	}
+}
+
+// markdownLink parses the link "[text](target)" at line[open] ('['): the
+// columns of its ']' and of the ')' closing the target
+func markdownLink(line []rune, open, to int) (end, target int, ok bool) {
+	end = -1
+	for j := open + 1; j < to; j++ {
+		if line[j] == ']' {
+			end = j
+			break
+		}
+	}
+	if end < 0 || end+1 >= to || line[end+1] != '(' {
+		return 0, 0, false
+	}
+	for j := end + 2; j < to; j++ {
+		if line[j] == ')' {
+			return end, j, true
+		}
+	}
+	return 0, 0, false
+}
+
+// concealedCol is visualCol for a line drawn with cells
+func concealedCol(line []rune, cells []concealCell, col, tabWidth int) int {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	col = clampRange(col, 0, len(line))
+	x := 0
+	for i := 0; i < col; i++ {
+		switch {
+		case cells[i].hidden:
+		case line[i] == '\t' && cells[i].repl == 0:
+			x += tabWidth - (x % tabWidth)
+		default:
+			x++
+		}
+	}
+	return x
+}
+
+// concealedLogicalCol is visualToLogicalCol for a line drawn with cells
+func concealedLogicalCol(line []rune, cells []concealCell, visualX, tabWidth int) int {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	x := 0
+	for i, r := range line {
+		if cells[i].hidden {
+			continue
+		}
+		advance := 1
+		if r == '\t' && cells[i].repl == 0 {
+			advance = tabWidth - (x % tabWidth)
+		}
+		if x+advance > visualX {
+			return i
+		}
+		x += advance
+	}
+	return len(line)
+}
+
+// rowDisplayCol is displayCol for col on row as drawn, concealed or not
+func (e *Editor) rowDisplayCol(row, col int) int {
+	if cells := e.concealCells(row); cells != nil {
+		return concealedCol(e.lines[row], cells, col, e.tabWidth)
+	}
+	return e.displayCol(e.lines[row], col)
+}
+
+// rowLogicalColAt is logicalColAt for row as drawn, concealed or not
+func (e *Editor) rowLogicalColAt(row, visualX int) int {
+	if cells := e.concealCells(row); cells != nil {
+		return concealedLogicalCol(e.lines[row], cells, visualX, e.tabWidth)
+	}
+	return e.logicalColAt(e.lines[row], visualX)
+}
+
+func (e *Editor) toggleConceal() {
+	e.setConceal(!e.conceal)
+}
+
+// setConceal turns conceal on or off for the current buffer
+func (e *Editor) setConceal(on bool) {
+	if on && e.concealRule() == nil {
+		e.setStatus("conceal: nothing to conceal in this file type")
+		return
+	}
+	e.conceal = on
+	if i := e.currentBuffer(); i >= 0 {
+		e.buffers[i].conceal = on
+	}
+	if on {
+		e.setStatus("conceal on")
+	} else {
+		e.setStatus("conceal off")
+	}
+}
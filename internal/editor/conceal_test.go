@@ -0,0 +1,74 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConcealMarkdown(t *testing.T) {
+	for _, tc := range []struct{ line, want string }{
+		{"some **bold** and _it_ text", "some bold and it text"},
+		{"see [the docs](https://x.io/a_b) now", "see the docs now"},
+		{"![logo](logo.png)", "logo"},
+		{"run `go **test**` and ~~not~~ this", "run go **test** and not this"},
+		{"snake_case_name and 2 * 3 * 4", "snake_case_name and 2 * 3 * 4"},
+		{"- [x] **done**", "• [x] done"},
+		{"## A *heading*", "## A heading"},
+		{`a \*literal\* star`, "a *literal* star"},
+		{"plain text", "plain text"},
+	} {
+		line := []rune(tc.line)
+		cells := concealMarkdown(line)
+		var b strings.Builder
+		for i, r := range line {
+			switch {
+			case cells == nil:
+				b.WriteRune(r)
+			case cells[i].hidden:
+			case cells[i].repl != 0:
+				b.WriteRune(cells[i].repl)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("%q shown as %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestConcealRendering(t *testing.T) {
+	e := newTestEditor("**one**", "**two**", "```", "**code**", "```")
+	e.filename = "doc.md"
+	e.execCommand("conceal")
+	if !e.conceal || e.statusMessage != "conceal on" {
+		t.Fatalf("conceal %v, status %q", e.conceal, e.statusMessage)
+	}
+	_, rows := renderRows(t, e, 40, 8)
+	for i, want := range []string{"**one**", "two", "```", "**code**"} {
+		if got := strings.TrimSpace(rows[i]); got != fmt.Sprintf("%d %s", i+1, want) {
+			t.Errorf("row %d drawn as %q, want %q", i, got, want)
+		}
+	}
+	if got := string(e.lines[1]); got != "**two**" {
+		t.Fatalf("text changed: %q", got)
+	}
+
+	// A click lands on the character drawn there
+	if got := e.rowLogicalColAt(1, 1); got != 3 {
+		t.Fatalf("column 1 of a concealed row is %d", got)
+	}
+
+	e.execCommand("conceal off")
+	_, rows = renderRows(t, e, 40, 8)
+	if !strings.Contains(rows[1], "**two**") {
+		t.Fatalf("row drawn as %q", rows[1])
+	}
+
+	e.filename = "main.go"
+	e.execCommand("conceal on")
+	if e.conceal || !strings.Contains(e.statusMessage, "nothing to conceal") {
+		t.Fatalf("conceal %v in a Go file, status %q", e.conceal, e.statusMessage)
+	}
+}
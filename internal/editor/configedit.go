@@ -0,0 +1,323 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/config"
+)
+
+// Editing qedit's own config.toml gets completion (Ctrl+X in insert mode)
+// and docs at the cursor (Space k) for the option keys, the section names
+// and the actions bound in the keymap tables. The option list comes from
+// the Config struct, so new options show up without changes here.
+
+// maxCompletionRows bounds the rows of the completion list shown at once
+const maxCompletionRows = 10
+
+// completionItem is an entry of the completion list
+type completionItem struct {
+	label  string // what is listed and matched
+	detail string // type or description shown after the label
+	insert string // text that replaces the typed prefix
+}
+
+// configCompletion is the state of the completion list
+type configCompletion struct {
+	start int // column where the completed word starts
+	items []completionItem
+	index int
+	words []string // ranked buffer words for word completion, nil in the config file
+}
+
+// isConfigFile reports whether the buffer is qedit's config.toml
+func (e *Editor) isConfigFile() bool {
+	if e.filename == "" {
+		return false
+	}
+	path, err := config.ConfigPath()
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(e.filename)
+	return err == nil && abs == filepath.Clean(path)
+}
+
+// configSectionAt returns the table row belongs to, from the nearest
+// [section] header above it, or "" before the first header
+func (e *Editor) configSectionAt(row int) string {
+	for r := min(row, len(e.lines)-1); r >= 0; r-- {
+		text := strings.TrimSpace(string(e.lines[r]))
+		if strings.HasPrefix(text, "[") {
+			name, _, _ := strings.Cut(strings.Trim(text, "[ "), "]")
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// configOptionAt returns the option whose key is set on row
+func (e *Editor) configOptionAt(row int) (config.Option, bool) {
+	key, _, ok := strings.Cut(string(e.lines[row]), "=")
+	if !ok {
+		return config.Option{}, false
+	}
+	section := e.configSectionAt(row)
+	key = strings.Trim(strings.TrimSpace(key), `"`)
+	for _, opt := range config.Options() {
+		if opt.Section == section && opt.Key == key {
+			return opt, true
+		}
+	}
+	return config.Option{}, false
+}
+
+// configCompletions returns the completions for the text before the cursor
+// and the column the completed word starts at: section names in a header,
+// actions after = in a keymap table, and the section's keys otherwise
+func (e *Editor) configCompletions() (int, []completionItem) {
+	line := e.lines[e.cursor.Row]
+	col := min(e.cursor.Col, len(line))
+	before := string(line[:col])
+	after := string(line[col:])
+	section := e.configSectionAt(e.cursor.Row)
+
+	var items []completionItem
+	start := wordStart(line, col, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.'
+	})
+	prefix := string(line[start:col])
+	switch text := strings.TrimSpace(before); {
+	case strings.HasPrefix(text, "["):
+		if strings.TrimSpace(string(line[:start])) != "[" {
+			return 0, nil
+		}
+		for _, name := range config.Sections() {
+			insert := name
+			if !strings.HasPrefix(strings.TrimSpace(after), "]") {
+				insert += "]"
+			}
+			items = append(items, completionItem{label: name, insert: insert})
+		}
+	case strings.Contains(before, "="):
+		if !strings.HasPrefix(section, "keymap.") {
+			return 0, nil
+		}
+		for _, name := range actionNames(inNormal | inInsert) {
+			insert := name
+			if start > 0 && line[start-1] == '"' && !strings.HasPrefix(after, `"`) {
+				insert += `"`
+			}
+			items = append(items, completionItem{label: name, detail: lookupAction(name).desc, insert: insert})
+		}
+	default:
+		if strings.TrimSpace(string(line[:start])) != "" {
+			return 0, nil
+		}
+		for _, opt := range config.Options() {
+			if opt.Section != section {
+				continue
+			}
+			insert := opt.Key
+			if !strings.Contains(after, "=") {
+				insert += " = "
+			}
+			items = append(items, completionItem{label: opt.Key, detail: opt.Type, insert: insert})
+		}
+	}
+
+	var matches []completionItem
+	for _, item := range items {
+		if strings.HasPrefix(item.label, prefix) {
+			matches = append(matches, item)
+		}
+	}
+	return start, matches
+}
+
+// wordStart returns the column where the run of word runes ending at col
+// starts
+func wordStart(line []rune, col int, word func(rune) bool) int {
+	for col > 0 && word(line[col-1]) {
+		col--
+	}
+	return col
+}
+
+// openCompletion opens the completion list at the cursor: config keys
+// and actions in the config file, buffer words elsewhere
+func (e *Editor) openCompletion() {
+	e.completion = configCompletion{}
+	if !e.isConfigFile() {
+		e.completion.words = append([]string{}, e.bufferWords()...)
+	}
+	if !e.refreshCompletion() {
+		e.setStatus("no completions")
+		return
+	}
+	e.openPopup(completionPopup{})
+}
+
+// refreshCompletion recomputes the list for the text typed so far and
+// reports whether anything matches
+func (e *Editor) refreshCompletion() bool {
+	words := e.completion.words
+	var start int
+	var items []completionItem
+	if words != nil {
+		start, items = e.wordCompletions(words)
+	} else {
+		start, items = e.configCompletions()
+	}
+	e.completion = configCompletion{start: start, items: items, words: words}
+	return len(items) > 0
+}
+
+// acceptCompletion replaces the typed prefix with the selected item
+func (e *Editor) acceptCompletion() {
+	c := e.completion
+	if c.index >= len(c.items) {
+		return
+	}
+	start := Cursor{Row: e.cursor.Row, Col: c.start}
+	end, err := e.ReplaceRange(start, e.cursor, c.items[c.index].insert)
+	if err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	e.cursor = end
+}
+
+// completionPopup is the completion list under the cursor. Typed text
+// narrows it, Tab/Down and Shift+Tab/Up select, Enter inserts the item and
+// other keys close it.
+type completionPopup struct{}
+
+func (completionPopup) handleKey(e *Editor, ev *tcell.EventKey) bool {
+	c := &e.completion
+	switch ev.Key() {
+	case tcell.KeyDown, tcell.KeyTab, tcell.KeyCtrlN:
+		c.index = (c.index + 1) % len(c.items)
+	case tcell.KeyUp, tcell.KeyBacktab, tcell.KeyCtrlP:
+		c.index = (c.index + len(c.items) - 1) % len(c.items)
+	case tcell.KeyEnter:
+		e.acceptCompletion()
+		e.closePopup(completionPopup{})
+	case tcell.KeyRune:
+		e.insertRune(ev.Rune())
+		if !e.refreshCompletion() {
+			e.closePopup(completionPopup{})
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		e.backspace()
+		if e.cursor.Col < e.completion.start || !e.refreshCompletion() {
+			e.closePopup(completionPopup{})
+		}
+	default:
+		e.closePopup(completionPopup{})
+	}
+	return true
+}
+
+func (completionPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	c := e.completion
+	if w < 6 || len(c.items) == 0 {
+		return
+	}
+	first := max(0, min(c.index-maxCompletionRows/2, len(c.items)-maxCompletionRows))
+	shown := c.items[first:min(first+maxCompletionRows, len(c.items))]
+	labelWidth := 0
+	for _, item := range shown {
+		labelWidth = max(labelWidth, len([]rune(item.label)))
+	}
+	width := min(maxFloatWidth, w-4)
+	lines := make([][]rune, len(shown))
+	styles := make([]tcell.Style, len(shown))
+	for i, item := range shown {
+		text := item.label
+		if item.detail != "" {
+			text += strings.Repeat(" ", labelWidth-len([]rune(item.label))+2) + item.detail
+		}
+		lines[i] = truncateRunes([]rune(text), width)
+		styles[i] = e.styleAutoComplete
+		if first+i == c.index {
+			styles[i] = e.styleSelection
+		}
+	}
+	e.drawCursorFloat(s, w, viewHeight, lines, styles)
+}
+
+func (completionPopup) dismissed(e *Editor) {
+	e.completion = configCompletion{}
+}
+
+func (completionPopup) modal() bool { return true }
+
+// showDocs opens the docs of the config option or keymap action on the
+// cursor line, or closes them
+func (e *Editor) showDocs() {
+	if e.popupOpen(docsFloatPopup{}) {
+		e.closePopup(docsFloatPopup{})
+		return
+	}
+	if !e.isConfigFile() {
+		e.setStatus("no docs for this file")
+		return
+	}
+	if len(e.docsLines(maxFloatWidth)) == 0 {
+		e.setStatus("no docs on this line")
+		return
+	}
+	e.openPopup(docsFloatPopup{})
+}
+
+// docsLines returns the wrapped docs for the cursor line of config.toml:
+// the option's name, type and description, or the description of the
+// action a keymap table binds
+func (e *Editor) docsLines(width int) [][]rune {
+	if e.cursor.Row >= len(e.lines) {
+		return nil
+	}
+	var title, doc string
+	if opt, ok := e.configOptionAt(e.cursor.Row); ok {
+		title, doc = opt.Section+"."+opt.Key+" ("+opt.Type+")", opt.Doc
+	} else if strings.HasPrefix(e.configSectionAt(e.cursor.Row), "keymap.") {
+		key, value, ok := strings.Cut(string(e.lines[e.cursor.Row]), "=")
+		a := lookupAction(strings.Trim(strings.TrimSpace(value), `"`))
+		if !ok || a == nil {
+			return nil
+		}
+		title, doc = strings.Trim(strings.TrimSpace(key), `"`)+" → "+a.name, a.desc
+	} else {
+		return nil
+	}
+	return append([][]rune{truncateRunes([]rune(title), width)}, wrapRunes([]rune(doc), width)...)
+}
+
+// docsFloatPopup shows the docs of the cursor line under it. Like the
+// diagnostics float, keys fall through to the editor and it follows the
+// cursor.
+type docsFloatPopup struct{}
+
+func (docsFloatPopup) handleKey(*Editor, *tcell.EventKey) bool { return false }
+
+func (docsFloatPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	if w < 6 {
+		return
+	}
+	lines := e.docsLines(min(maxFloatWidth, w-4))
+	styles := make([]tcell.Style, len(lines))
+	for i := range styles {
+		styles[i] = e.styleStatus
+	}
+	if len(styles) > 0 {
+		styles[0] = e.styleStatus.Bold(true)
+	}
+	e.drawCursorFloat(s, w, viewHeight, lines, styles)
+}
+
+func (docsFloatPopup) dismissed(*Editor) {}
+
+func (docsFloatPopup) modal() bool { return false }
@@ -0,0 +1,109 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func newConfigTestEditor(t *testing.T, lines ...string) *Editor {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+	e := newTestEditor(lines...)
+	e.filename = filepath.Join(dir, "config.toml")
+	last := len(lines) - 1
+	e.cursor = Cursor{Row: last, Col: len(e.lines[last])}
+	e.mode = ModeInsert
+	return e
+}
+
+func TestConfigCompletion(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		typed string
+		want  string
+	}{
+		{"key", []string{"[editor]", "tab"}, "", "tab-width = "},
+		{"narrowed by typing", []string{"[editor]", ""}, "which", "which-key-delay = "},
+		{"section", []string{"[edi"}, "", "[editor]"},
+		{"action", []string{"[keymap.normal]", `h = "move_l`}, "", `h = "move_left"`},
+		{"terminal key", []string{"[terminal]", "mo"}, "", "mouse = "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newConfigTestEditor(t, tt.lines...)
+			e.HandleKey(eventForKeyString(t, "ctrl+x"))
+			if !e.popupOpen(completionPopup{}) {
+				t.Fatalf("completion not open, status %q", e.statusMessage)
+			}
+			for _, r := range tt.typed {
+				e.HandleKey(keyRune(r))
+			}
+			e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+			if got := string(e.lines[e.cursor.Row]); got != tt.want {
+				t.Fatalf("line = %q, want %q", got, tt.want)
+			}
+			if e.popupOpen(completionPopup{}) || e.cursor.Col != len(e.lines[e.cursor.Row]) {
+				t.Fatalf("popup open %v, cursor %v after accepting", e.popupOpen(completionPopup{}), e.cursor)
+			}
+		})
+	}
+}
+
+func TestConfigCompletionSelectAndRender(t *testing.T) {
+	e := newConfigTestEditor(t, "[editor]", "sidebar-")
+	e.HandleKey(eventForKeyString(t, "ctrl+x"))
+	_, rows := renderRows(t, e, 80, 12)
+	screen := strings.Join(rows, "\n")
+	if !strings.Contains(screen, "sidebar-width") || !strings.Contains(screen, "integer") {
+		t.Fatalf("completion list not drawn:\n%s", screen)
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if got := string(e.lines[1]); got != "sidebar-min-width = " {
+		t.Fatalf("line = %q, want the second item", got)
+	}
+}
+
+func TestConfigCompletionOtherFile(t *testing.T) {
+	e := newConfigTestEditor(t, "[editor]", "tab")
+	e.filename = filepath.Join(t.TempDir(), "other.toml")
+	e.HandleKey(eventForKeyString(t, "ctrl+x"))
+	// Other files complete buffer words, and no word starts with "tab"
+	if e.popupOpen(completionPopup{}) || e.statusMessage != "no completions" {
+		t.Fatalf("completion in another file: open %v, status %q", e.popupOpen(completionPopup{}), e.statusMessage)
+	}
+}
+
+func TestConfigDocs(t *testing.T) {
+	e := newConfigTestEditor(t, "[editor]", "tab-width = 4", "", "[keymap.normal]", `j = "move_down"`)
+	e.mode = ModeNormal
+	e.cursor = Cursor{Row: 1}
+	e.HandleKey(keyRune(' '))
+	e.HandleKey(keyRune('k'))
+	if !e.popupOpen(docsFloatPopup{}) {
+		t.Fatalf("docs not open, status %q", e.statusMessage)
+	}
+	_, rows := renderRows(t, e, 80, 12)
+	if screen := strings.Join(rows, "\n"); !strings.Contains(screen, "editor.tab-width (integer)") {
+		t.Fatalf("option docs not drawn:\n%s", screen)
+	}
+
+	e.cursor = Cursor{Row: 4}
+	_, rows = renderRows(t, e, 80, 12)
+	if screen := strings.Join(rows, "\n"); !strings.Contains(screen, "j → move_down") {
+		t.Fatalf("action docs not drawn:\n%s", screen)
+	}
+
+	e.cursor = Cursor{Row: 2}
+	e.closePopup(docsFloatPopup{})
+	e.HandleKey(keyRune(' '))
+	e.HandleKey(keyRune('k'))
+	if e.popupOpen(docsFloatPopup{}) || e.statusMessage != "no docs on this line" {
+		t.Fatalf("docs on an empty line: open %v, status %q", e.popupOpen(docsFloatPopup{}), e.statusMessage)
+	}
+}
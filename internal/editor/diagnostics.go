@@ -0,0 +1,250 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/uniseg"
+
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// inlineDiagnosticGap is the space left between a line's text and its
+// inline diagnostic
+const inlineDiagnosticGap = 2
+
+// maxFloatWidth bounds the text width of the floats at the cursor
+// (diagnostics, config docs), longer text wraps
+const maxFloatWidth = 60
+
+// drawInlineDiagnostic draws the first problem of row as dimmed text after
+// the line's content, cut to the text area with an ellipsis. Nothing is
+// drawn when fewer than two columns are left.
+func (e *Editor) drawInlineDiagnostic(s tcell.Screen, y, w, startX, row int) {
+	p, ok := e.problemAt(row)
+	if !ok || e.ansiView {
+		return
+	}
+	line := e.lines[row]
+	x := max(startX+visualCol(line, len(line), e.tabWidth)-e.scrollX, startX) + inlineDiagnosticGap
+	msg := []rune(strings.Join(strings.Fields(p.Message), " "))
+	if w-x < 2 {
+		return
+	}
+	style := e.diagnosticStyle(p.Severity).Dim(true)
+	drawRunes(s, x, y, truncateRunes(msg, w-x), style)
+}
+
+// drawProblemUnderlines underlines the word each problem of row points
+// at, or its one character, in the problem's color: curly on terminals
+// with undercurl, straight on others
+func (e *Editor) drawProblemUnderlines(s tcell.Screen, y, w, startX, row int) {
+	if e.ansiView {
+		return
+	}
+	underline := tcell.UnderlineStyleSolid
+	if e.terminal.Undercurl {
+		underline = tcell.UnderlineStyleCurly
+	}
+	line := e.lines[row]
+	for _, p := range e.problemsAt(row) {
+		from := textpos.ByteToRune(line, max(p.Col-1, 0))
+		to := from
+		for to < len(line) && isWordRune(line[to]) {
+			to++
+		}
+		if to == from {
+			to = min(from+1, len(line))
+		}
+		color, _, _ := e.diagnosticStyle(p.Severity).Decompose()
+		x0 := max(startX+e.rowDisplayCol(row, from)-e.scrollX, startX)
+		x1 := min(startX+e.rowDisplayCol(row, to)-e.scrollX, w)
+		for x := x0; x < x1; x++ {
+			r, combining, style, _ := s.GetContent(x, y)
+			s.SetContent(x, y, r, combining, style.Underline(underline, color))
+		}
+	}
+}
+
+// runeWidth returns the cells r takes on the screen: two for wide (CJK,
+// emoji) characters, none for combining marks
+func runeWidth(r rune) int {
+	return uniseg.StringWidth(string(r))
+}
+
+// runesWidth returns the cells text takes on the screen
+func runesWidth(text []rune) int {
+	return uniseg.StringWidth(string(text))
+}
+
+// fitRunes returns how many runes of text fit in width cells
+func fitRunes(text []rune, width int) int {
+	used := 0
+	for i, r := range text {
+		used += runeWidth(r)
+		if used > width {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// drawRunes draws text from x on, a grapheme cluster per cell or two
+func drawRunes(s tcell.Screen, x, y int, text []rune, style tcell.Style) {
+	rest, state := string(text), -1
+	for rest != "" {
+		var cluster string
+		var width int
+		cluster, rest, width, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		runes := []rune(cluster)
+		s.SetContent(x, y, runes[0], runes[1:], style)
+		x += width
+	}
+}
+
+// truncateRunes cuts text to width cells, ending in an ellipsis when
+// something was cut
+func truncateRunes(text []rune, width int) []rune {
+	if runesWidth(text) <= width {
+		return text
+	}
+	if width < 1 {
+		return nil
+	}
+	n := fitRunes(text, width-1)
+	return append(text[:n:n], '…')
+}
+
+// wrapRunes breaks text into lines of at most width cells, at spaces where
+// it can and inside words longer than a line
+func wrapRunes(text []rune, width int) [][]rune {
+	var lines [][]rune
+	for runesWidth(text) > width {
+		fit := max(fitRunes(text, width), 1)
+		cut := fit
+		for i := fit; i > 0; i-- {
+			if text[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, text[:cut])
+		text = text[cut:]
+		for len(text) > 0 && text[0] == ' ' {
+			text = text[1:]
+		}
+	}
+	return append(lines, text)
+}
+
+// toggleDiagnosticFloat opens the float with the full diagnostics of the
+// cursor line, or closes it
+func (e *Editor) toggleDiagnosticFloat() {
+	if e.popupOpen(diagnosticFloatPopup{}) {
+		e.closePopup(diagnosticFloatPopup{})
+		return
+	}
+	if len(e.problemsAt(e.cursor.Row)) == 0 {
+		e.setStatus("no diagnostics on this line")
+		return
+	}
+	e.openPopup(diagnosticFloatPopup{})
+}
+
+// diagnosticFloatLines returns the wrapped lines of the float for the
+// cursor line, each problem starting with its severity, and the style of
+// each line
+func (e *Editor) diagnosticFloatLines(width int) ([][]rune, []tcell.Style) {
+	var lines [][]rune
+	var styles []tcell.Style
+	_, bg, _ := e.styleStatus.Decompose()
+	for _, p := range e.problemsAt(e.cursor.Row) {
+		fg, _, _ := e.diagnosticStyle(p.Severity).Decompose()
+		style := tcell.StyleDefault.Foreground(fg).Background(bg)
+		text := p.Severity.String() + ": " + p.Message
+		for _, para := range strings.Split(text, "\n") {
+			for _, l := range wrapRunes([]rune(strings.TrimRight(para, " \t\r")), width) {
+				lines = append(lines, l)
+				styles = append(styles, style)
+			}
+		}
+	}
+	return lines, styles
+}
+
+// diagnosticFloatPopup shows the problems of the cursor line in full
+// under it. Keys fall through to the editor, so it follows the cursor and
+// draws nothing on lines without problems.
+type diagnosticFloatPopup struct{}
+
+func (diagnosticFloatPopup) handleKey(*Editor, *tcell.EventKey) bool { return false }
+
+func (diagnosticFloatPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	e.renderDiagnosticFloat(s, w, viewHeight)
+}
+
+func (diagnosticFloatPopup) dismissed(*Editor) {}
+
+func (diagnosticFloatPopup) modal() bool { return false }
+
+// renderDiagnosticFloat draws the float at the cursor
+func (e *Editor) renderDiagnosticFloat(s tcell.Screen, w, viewHeight int) {
+	if w < 6 {
+		return
+	}
+	lines, styles := e.diagnosticFloatLines(min(maxFloatWidth, w-4))
+	e.drawCursorFloat(s, w, viewHeight, lines, styles)
+}
+
+// drawCursorFloat draws lines in a box below the cursor, or above it when
+// there is no room below, each line in its style
+func (e *Editor) drawCursorFloat(s tcell.Screen, w, viewHeight int, lines [][]rune, styles []tcell.Style) {
+	cy := e.visibleIndex(e.cursor.Row) - e.visibleIndex(e.scroll)
+	if cy < 0 || cy >= viewHeight || len(lines) == 0 {
+		return
+	}
+	inner := 0
+	for _, l := range lines {
+		inner = max(inner, runesWidth(l))
+	}
+	boxWidth := inner + 4 // border and a space each side
+	boxHeight := min(len(lines)+2, viewHeight)
+	y0 := cy + 1
+	if y0+boxHeight > viewHeight {
+		y0 = max(cy-boxHeight, 0)
+	}
+	x0 := e.editorX + e.gutterWidth()
+	if e.cursor.Row < len(e.lines) {
+		x0 += e.displayCol(e.lines[e.cursor.Row], e.cursor.Col) - e.scrollX
+	}
+	x0 = max(min(x0, w-boxWidth), 0)
+
+	border := e.styleStatus
+	bottom, right := boxHeight-1, boxWidth-1
+	for y := 0; y <= bottom; y++ {
+		for x := 0; x <= right; x++ {
+			r := ' '
+			switch {
+			case y == 0 && x == 0:
+				r = '┌'
+			case y == 0 && x == right:
+				r = '┐'
+			case y == bottom && x == 0:
+				r = '└'
+			case y == bottom && x == right:
+				r = '┘'
+			case y == 0 || y == bottom:
+				r = '─'
+			case x == 0 || x == right:
+				r = '│'
+			}
+			s.SetContent(x0+x, y0+y, r, nil, border)
+		}
+	}
+	for i := 0; i < boxHeight-2; i++ {
+		for x := 1; x < right; x++ {
+			s.SetContent(x0+x, y0+1+i, ' ', nil, styles[i])
+		}
+		drawRunes(s, x0+2, y0+1+i, lines[i], styles[i])
+	}
+}
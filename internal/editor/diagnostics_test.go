@@ -0,0 +1,157 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/config"
+	"github.com/kobzarvs/qedit/internal/validate"
+)
+
+// renderRows renders e on a w×h screen and returns each row's text
+func renderRows(t *testing.T, e *Editor, w, h int) (tcell.SimulationScreen, []string) {
+	t.Helper()
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init screen: %v", err)
+	}
+	t.Cleanup(s.Fini)
+	s.SetSize(w, h)
+	e.Render(s)
+	cells, _, _ := s.GetContents()
+	rows := make([]string, h)
+	for y := range rows {
+		var b strings.Builder
+		for x := 0; x < w; x++ {
+			if r := cells[y*w+x].Runes; len(r) > 0 {
+				b.WriteRune(r[0])
+			}
+		}
+		rows[y] = b.String()
+	}
+	return s, rows
+}
+
+func TestInlineDiagnosticTruncated(t *testing.T) {
+	e := newTestEditor("key = 1", "ok")
+	e.problems = []validate.Problem{
+		{Line: 1, Col: 1, Message: "duplicate key \"key\" defined earlier"},
+		{Line: 1, Col: 1, Message: "second problem", Severity: validate.SeverityWarning},
+	}
+	s, rows := renderRows(t, e, 32, 6)
+	text := []rune(rows[0])[e.gutterWidth():]
+	if want := "key = 1  duplicate key \"key…"; string(text) != want {
+		t.Fatalf("line 1 = %q, want %q", string(text), want)
+	}
+	if strings.Contains(rows[1], "problem") {
+		t.Fatalf("line without problems shows %q", rows[1])
+	}
+	_, _, style, _ := s.GetContent(e.gutterWidth()+9, 0)
+	fg, _, attrs := style.Decompose()
+	if attrs&tcell.AttrDim == 0 {
+		t.Fatalf("inline diagnostic is not dimmed")
+	}
+	if errFg, _, _ := e.styleDiagnosticError.Decompose(); fg != errFg {
+		t.Fatalf("inline diagnostic color = %v, want the error color", fg)
+	}
+}
+
+func TestInlineDiagnosticWideText(t *testing.T) {
+	e := newTestEditor("k = 1")
+	e.problems = []validate.Problem{{Line: 1, Col: 1, Message: "重複したキーがあります"}}
+	s, _ := renderRows(t, e, 24, 4)
+	x := e.gutterWidth() + len("k = 1") + inlineDiagnosticGap
+	// Two cells a character, and one left for the ellipsis
+	want := string([]rune("重複したキーがあります")[:(24-x-1)/2]) + "…"
+	var got []rune
+	for x < 24 {
+		r, _, _, width := s.GetContent(x, 0)
+		got = append(got, r)
+		x += max(width, 1)
+	}
+	if string(got) != want {
+		t.Fatalf("inline diagnostic = %q, want %q", string(got), want)
+	}
+}
+
+func TestDiagnosticUnderline(t *testing.T) {
+	e := newTestEditor("a = undefined + 1")
+	e.problems = []validate.Problem{{Line: 1, Col: 5, Message: "undefined: undefined"}}
+	underlines := func() []tcell.UnderlineStyle {
+		s, _ := renderRows(t, e, 40, 4)
+		var got []tcell.UnderlineStyle
+		for x := e.gutterWidth() + 3; x < e.gutterWidth()+14; x++ {
+			_, _, style, _ := s.GetContent(x, 0)
+			got = append(got, style.GetUnderlineStyle())
+		}
+		return got
+	}
+	// The word under the problem, not the space around it
+	got := underlines()
+	if got[0] != tcell.UnderlineStyleNone || got[1] != tcell.UnderlineStyleSolid || got[9] != tcell.UnderlineStyleSolid || got[10] != tcell.UnderlineStyleNone {
+		t.Fatalf("underlines = %v", got)
+	}
+	e.SetTerminalFeatures(config.TerminalFeatures{Undercurl: true, MetaSendsEscape: true})
+	if got := underlines(); got[1] != tcell.UnderlineStyleCurly {
+		t.Fatalf("underline with undercurl = %v", got[1])
+	}
+}
+
+func TestDiagnosticFloat(t *testing.T) {
+	e := newTestEditor("key = 1", "ok", "", "")
+	e.problems = []validate.Problem{
+		{Line: 1, Col: 1, Message: "duplicate key \"key\" defined earlier"},
+		{Line: 1, Col: 1, Message: "second problem", Severity: validate.SeverityWarning},
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyCtrlK, 0, tcell.ModCtrl))
+	if !e.popupOpen(diagnosticFloatPopup{}) {
+		t.Fatalf("Ctrl+K did not open the diagnostics float")
+	}
+	_, rows := renderRows(t, e, 40, 12)
+	if !strings.Contains(rows[0], "key = 1") {
+		t.Fatalf("float covers the cursor line: %q", rows[0])
+	}
+	got := strings.Join(rows, "\n")
+	for _, want := range []string{"│ error: duplicate key \"key\" defined │", "│ earlier", "│ warning: second problem"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("float is missing %q:\n%s", want, got)
+		}
+	}
+
+	e.HandleKey(keyRune('j'))
+	_, rows = renderRows(t, e, 40, 12)
+	if got := strings.Join(rows, "\n"); strings.Contains(got, "warning:") {
+		t.Fatalf("float shown on a line without problems:\n%s", got)
+	}
+	e.HandleKey(keyEsc())
+	if e.popupOpen(diagnosticFloatPopup{}) {
+		t.Fatalf("Esc did not close the float")
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyCtrlK, 0, tcell.ModCtrl))
+	if e.statusMessage != "no diagnostics on this line" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestWrapRunes(t *testing.T) {
+	for _, c := range []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"one two threefourfive six", 8, []string{"one two", "threefou", "rfive", "six"}},
+		{"キー key 重複した", 6, []string{"キー", "key", "重複し", "た"}},
+	} {
+		got := wrapRunes([]rune(c.text), c.width)
+		if len(got) != len(c.want) {
+			t.Fatalf("wrap %q = %q, want %q", c.text, got, c.want)
+		}
+		for i := range c.want {
+			if string(got[i]) != c.want[i] {
+				t.Fatalf("wrap %q line %d = %q, want %q", c.text, i, string(got[i]), c.want[i])
+			}
+		}
+	}
+}
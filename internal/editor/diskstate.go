@@ -0,0 +1,66 @@
+package editor
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// diskState is what the open file looked like on disk when it was last
+// read or written, to tell whether something else changed it since. Size
+// and mtime are compared first; the file is only read and hashed when they
+// differ, so a touch that leaves the content alone isn't a change. The
+// content itself is kept as the base :merge merges changes against.
+type diskState struct {
+	valid bool
+	size  int64
+	mtime time.Time
+	hash  [sha256.Size]byte
+	data  []byte
+}
+
+// rememberDisk records data as the content of the open file on disk
+func (e *Editor) rememberDisk(data []byte) {
+	info, err := os.Stat(e.filename)
+	if err != nil {
+		e.disk = diskState{}
+		return
+	}
+	e.disk = diskState{valid: true, size: info.Size(), mtime: info.ModTime(), hash: sha256.Sum256(data), data: data}
+}
+
+// changedOnDisk reports whether the open file's content on disk differs
+// from what was last read or written. A file that is gone or can't be read
+// counts as unchanged: writing it back loses nothing.
+func (e *Editor) changedOnDisk() bool {
+	if !e.disk.valid || e.filename == "" {
+		return false
+	}
+	info, err := os.Stat(e.filename)
+	if err != nil {
+		return false
+	}
+	if info.Size() == e.disk.size && info.ModTime().Equal(e.disk.mtime) {
+		return false
+	}
+	data, err := os.ReadFile(e.filename)
+	if err != nil {
+		return false
+	}
+	if sha256.Sum256(data) == e.disk.hash {
+		// Touched but not changed: don't read it again next time
+		e.disk.size, e.disk.mtime = info.Size(), info.ModTime()
+		return false
+	}
+	return true
+}
+
+// CheckDisk warns in the statusline when the open file was changed by
+// something else since it was read or written. The app calls it when the
+// terminal gets the focus back.
+func (e *Editor) CheckDisk() {
+	if e.changedOnDisk() {
+		e.setStatus(filepath.Base(e.filename) + " changed on disk (:merge takes its changes, :w! overwrites it)")
+	}
+}
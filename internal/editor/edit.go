@@ -0,0 +1,115 @@
+package editor
+
+import "github.com/kobzarvs/qedit/pkg/core"
+
+// errPosOutOfRange is returned by the public edit API for positions outside the buffer
+var errPosOutOfRange = core.ErrOutOfRange
+
+// The editor exposes its lines to the helpers of the editing core
+var _ core.Text = (*Editor)(nil)
+
+// BeginUndoGroup starts an atomic edit: all edits until the matching
+// EndUndoGroup are undone and redone as one step. Calls may be nested;
+// only the outermost pair opens and closes the group.
+func (e *Editor) BeginUndoGroup() {
+	if e.undoGroupDepth == 0 {
+		e.startUndoGroup()
+		e.undoGroupEdits = 0
+	}
+	e.undoGroupDepth++
+}
+
+// EndUndoGroup closes the group opened by BeginUndoGroup.
+func (e *Editor) EndUndoGroup() {
+	if e.undoGroupDepth == 0 {
+		return
+	}
+	e.undoGroupDepth--
+	if e.undoGroupDepth > 0 || e.undoGroupEdits == 0 {
+		return
+	}
+	e.finishUndoGroup()
+}
+
+// InsertAt inserts text (lines separated by \n) at pos and returns the end
+// of the inserted text. The cursor keeps its place relative to the text.
+func (e *Editor) InsertAt(pos Cursor, text string) (Cursor, error) {
+	if !e.validPos(pos) {
+		return pos, errPosOutOfRange
+	}
+	if text == "" {
+		return pos, nil
+	}
+	lines := splitLines([]byte(text))
+	cursor := e.cursor
+
+	end := e.insertTextAt(pos, lines)
+	e.recordEdit(action{kind: actionDeleteText, pos: pos, endPos: end, text: lines, hasCursor: true, cursorBefore: cursor})
+	e.cursor = shiftPosForEdit(cursor, pos, pos, end)
+	e.clampCursorCol()
+	e.stampCursorAfter()
+	return end, nil
+}
+
+// DeleteRange deletes the text between start (inclusive) and end (exclusive)
+// and returns it with lines joined by \n.
+func (e *Editor) DeleteRange(start, end Cursor) (string, error) {
+	if !e.validPos(start) || !e.validPos(end) {
+		return "", errPosOutOfRange
+	}
+	start, end = core.Order(start, end)
+	if start == end {
+		return "", nil
+	}
+	cursor := e.cursor
+
+	deleted := e.deleteTextRange(start, end)
+	e.recordEdit(action{kind: actionInsertText, pos: start, text: deleted, hasCursor: true, cursorBefore: cursor})
+	e.cursor = shiftPosForEdit(cursor, start, end, start)
+	e.clampCursorCol()
+	e.stampCursorAfter()
+	return joinLines(deleted), nil
+}
+
+// ReplaceRange replaces the text between start and end with text as a single
+// undo step and returns the end of the new text.
+func (e *Editor) ReplaceRange(start, end Cursor, text string) (Cursor, error) {
+	if !e.validPos(start) || !e.validPos(end) {
+		return start, errPosOutOfRange
+	}
+	start, end = core.Order(start, end)
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	if _, err := e.DeleteRange(start, end); err != nil {
+		return start, err
+	}
+	return e.InsertAt(start, text)
+}
+
+// recordEdit records an undo action for a public edit, either into the open
+// undo group or as its own undo step
+func (e *Editor) recordEdit(act action) {
+	if e.undoGroupDepth > 0 {
+		e.undoGroupEdits++
+		e.appendUndo(act)
+		return
+	}
+	e.recordUndo(act)
+}
+
+// validPos reports whether pos is inside the buffer (col may equal line length)
+func (e *Editor) validPos(pos Cursor) bool {
+	return core.ValidPos(e, pos)
+}
+
+// shiftPosForEdit maps p through an edit that replaced [start, oldEnd) with
+// text ending at newEnd. Positions inside the replaced text move to start.
+func shiftPosForEdit(p, start, oldEnd, newEnd Cursor) Cursor {
+	return core.ShiftPos(p, start, oldEnd, newEnd)
+}
+
+// textInRange returns the text between start (inclusive) and end (exclusive)
+// with lines joined by \n
+func (e *Editor) textInRange(start, end Cursor) string {
+	return core.TextInRange(e, start, end)
+}
@@ -0,0 +1,228 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInsertAtAndDeleteRangeUndo(t *testing.T) {
+	e := newTestEditor("hello world", "second")
+	e.cursor = Cursor{Row: 1, Col: 3}
+
+	end, err := e.InsertAt(Cursor{Row: 0, Col: 5}, ",\nnew")
+	if err != nil {
+		t.Fatalf("InsertAt: %v", err)
+	}
+	if end != (Cursor{Row: 1, Col: 3}) {
+		t.Fatalf("end = %+v, want {1 3}", end)
+	}
+	if got := e.Content(); got != "hello,\nnew world\nsecond" {
+		t.Fatalf("text = %q", got)
+	}
+	if e.cursor != (Cursor{Row: 2, Col: 3}) {
+		t.Fatalf("cursor = %+v, want shifted to {2 3}", e.cursor)
+	}
+	if !e.dirty {
+		t.Fatalf("buffer should be dirty after InsertAt")
+	}
+
+	deleted, err := e.DeleteRange(Cursor{Row: 1, Col: 3}, Cursor{Row: 0, Col: 5})
+	if err != nil {
+		t.Fatalf("DeleteRange: %v", err)
+	}
+	if deleted != ",\nnew" {
+		t.Fatalf("deleted = %q", deleted)
+	}
+	if got := e.Content(); got != "hello world\nsecond" {
+		t.Fatalf("text = %q", got)
+	}
+
+	e.Undo()
+	if got := e.Content(); got != "hello,\nnew world\nsecond" {
+		t.Fatalf("after undo text = %q", got)
+	}
+	e.Undo()
+	if got := e.Content(); got != "hello world\nsecond" {
+		t.Fatalf("after second undo text = %q", got)
+	}
+	if e.dirty {
+		t.Fatalf("buffer should be clean after undoing all edits")
+	}
+
+	if _, err := e.InsertAt(Cursor{Row: 5, Col: 0}, "x"); err == nil {
+		t.Fatalf("InsertAt out of range should fail")
+	}
+}
+
+func TestUndoGroupMakesEditsAtomic(t *testing.T) {
+	e := newTestEditor("alpha beta gamma")
+	var ticks []uint64
+	e.OnChange(func(c TextChange) {
+		ticks = append(ticks, c.ChangeTick)
+	})
+	e.BeginUndoGroup()
+	if _, err := e.ReplaceRange(Cursor{Row: 0, Col: 0}, Cursor{Row: 0, Col: 5}, "ALPHA"); err != nil {
+		t.Fatalf("ReplaceRange: %v", err)
+	}
+	if _, err := e.ReplaceRange(Cursor{Row: 0, Col: 11}, Cursor{Row: 0, Col: 16}, "GAMMA"); err != nil {
+		t.Fatalf("ReplaceRange: %v", err)
+	}
+	e.EndUndoGroup()
+	if got := e.Content(); got != "ALPHA beta GAMMA" {
+		t.Fatalf("text = %q", got)
+	}
+	if len(ticks) != 4 || ticks[0] != ticks[3] {
+		t.Fatalf("change ticks = %v, want the group's four changes in one step", ticks)
+	}
+
+	e.Undo()
+	if got := e.Content(); got != "alpha beta gamma" {
+		t.Fatalf("after undo text = %q, want all edits undone at once", got)
+	}
+	e.Redo()
+	if got := e.Content(); got != "ALPHA beta GAMMA" {
+		t.Fatalf("after redo text = %q", got)
+	}
+}
+
+func TestReplaceBufferIsOneUndoStep(t *testing.T) {
+	e := newTestEditor("package main", "func  f( ) {", "x := 1", "}", "// end")
+	e.insertRune('y')
+	e.markSaved() // as if saved here
+	e.cursor = Cursor{Row: 4, Col: 3}
+	before := e.Content()
+
+	e.replaceBuffer("ypackage main\nfunc f() {\n\tx := 1\n}\n// end", true)
+	if got := e.Content(); got != "ypackage main\nfunc f() {\n\tx := 1\n}\n// end" {
+		t.Fatalf("content = %q", got)
+	}
+	if e.cursor != (Cursor{Row: 4, Col: 3}) {
+		t.Fatalf("cursor = %+v, want it left on the unchanged line", e.cursor)
+	}
+	if !e.dirty {
+		t.Fatal("dirty = false after formatting")
+	}
+
+	e.Undo()
+	if got := e.Content(); got != before {
+		t.Fatalf("content after undo = %q, want %q", got, before)
+	}
+	if e.dirty {
+		t.Fatal("dirty = true after undoing back to the save point")
+	}
+	e.Undo()
+	if got := e.Content(); got != "package main\nfunc  f( ) {\nx := 1\n}\n// end" {
+		t.Fatalf("edit before formatting not undone: %q", got)
+	}
+	e.Redo()
+	e.Redo()
+	if got := e.Content(); got != "ypackage main\nfunc f() {\n\tx := 1\n}\n// end" {
+		t.Fatalf("content after redo = %q", got)
+	}
+}
+
+func TestReplaceBufferEnds(t *testing.T) {
+	cases := []struct{ from, to string }{
+		{"a\nb", "a\nb\nc"},
+		{"a\nb\nc", "a"},
+		{"a\nb", "x\ny\nz"},
+		{"a\nb", "b"},
+		{"a", ""},
+	}
+	for _, c := range cases {
+		e := newTestEditor(strings.Split(c.from, "\n")...)
+		e.replaceBuffer(c.to, true)
+		if got := e.Content(); got != c.to {
+			t.Errorf("%q -> %q: content = %q", c.from, c.to, got)
+		}
+		e.Undo()
+		if got := e.Content(); got != c.from {
+			t.Errorf("%q -> %q: undo = %q", c.from, c.to, got)
+		}
+	}
+}
+
+// editState is the cursor and selection undo and redo should restore
+type editState struct {
+	cursor     Cursor
+	selection  bool
+	start, end Cursor
+}
+
+func stateOf(e *Editor) editState {
+	st := editState{cursor: e.cursor, selection: e.selectionActive}
+	if st.selection {
+		st.start, st.end = e.selectionStart, e.selectionEnd
+	}
+	return st
+}
+
+func TestUndoRedoRestoreCursorAndSelection(t *testing.T) {
+	selectRange := func(start, end Cursor) func(e *Editor) {
+		return func(e *Editor) {
+			e.selectionActive = true
+			e.selectionStart, e.selectionEnd = start, end
+			e.cursor = end
+		}
+	}
+	cases := []struct {
+		name  string
+		setup func(e *Editor)
+		edit  func(e *Editor)
+	}{
+		{"delete selection", selectRange(Cursor{Row: 0, Col: 1}, Cursor{Row: 1, Col: 2}), (*Editor).helixDelete},
+		{"delete backward selection", selectRange(Cursor{Row: 1, Col: 2}, Cursor{Row: 0, Col: 1}), (*Editor).helixDelete},
+		{"change selection", selectRange(Cursor{Row: 0, Col: 0}, Cursor{Row: 0, Col: 3}), (*Editor).helixChange},
+		{"delete char in selection", selectRange(Cursor{Row: 2, Col: 0}, Cursor{Row: 2, Col: 2}), (*Editor).deleteChar},
+		{"delete line", func(e *Editor) { e.cursor = Cursor{Row: 1, Col: 3} }, (*Editor).deleteLine},
+		{"delete last line", func(e *Editor) { e.cursor = Cursor{Row: 2, Col: 1} }, (*Editor).deleteLine},
+		{"paste after", func(e *Editor) { e.clipboard = [][]rune{[]rune("XY")}; e.cursor = Cursor{Row: 1, Col: 1} }, (*Editor).pasteAfter},
+		{"paste lines after", func(e *Editor) { e.clipboard = [][]rune{[]rune("X"), []rune("Y")}; e.cursor = Cursor{Row: 0, Col: 2} }, (*Editor).pasteAfter},
+		{"paste before", func(e *Editor) { e.clipboard = [][]rune{[]rune("XY")}; e.cursor = Cursor{Row: 2, Col: 2} }, (*Editor).pasteBefore},
+		{"paste lines before", func(e *Editor) { e.clipboard = [][]rune{[]rune("X"), []rune("Y")}; e.cursor = Cursor{Row: 1, Col: 2} }, (*Editor).pasteBefore},
+		{"insert at", func(e *Editor) { e.cursor = Cursor{Row: 1, Col: 4} }, func(e *Editor) { _, _ = e.InsertAt(Cursor{Row: 1, Col: 0}, "new\n") }},
+		{"delete range", func(e *Editor) { e.cursor = Cursor{Row: 2, Col: 1} }, func(e *Editor) { _, _ = e.DeleteRange(Cursor{Row: 0, Col: 2}, Cursor{Row: 1, Col: 1}) }},
+		{"replace range", selectRange(Cursor{Row: 0, Col: 0}, Cursor{Row: 0, Col: 5}), func(e *Editor) { _, _ = e.ReplaceRange(Cursor{}, Cursor{Row: 0, Col: 5}, "ONE") }},
+		{"replace buffer", func(e *Editor) { e.cursor = Cursor{Row: 2, Col: 3} }, func(e *Editor) { e.replaceBuffer("alpha\nBETA\ngamma", true) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEditor("alpha", "beta", "gamma")
+			c.setup(e)
+			beforeText, before := e.Content(), stateOf(e)
+			c.edit(e)
+			afterText, after := e.Content(), stateOf(e)
+			if afterText == beforeText {
+				t.Fatal("edit changed nothing")
+			}
+			after.selection, after.start, after.end = false, Cursor{}, Cursor{}
+
+			// Move away, so only undo and redo can put the cursor back
+			e.clearSelection()
+			e.cursor = Cursor{}
+			e.Undo()
+			if e.Content() != beforeText {
+				t.Fatalf("undo: content = %q, want %q", e.Content(), beforeText)
+			}
+			if got := stateOf(e); got != before {
+				t.Fatalf("undo: state = %+v, want %+v", got, before)
+			}
+			e.cursor = Cursor{}
+			e.Redo()
+			if e.Content() != afterText {
+				t.Fatalf("redo: content = %q, want %q", e.Content(), afterText)
+			}
+			if got := stateOf(e); got != after {
+				t.Fatalf("redo: state = %+v, want %+v", got, after)
+			}
+		})
+	}
+}
+
+func TestEditStateSurvivesChangelog(t *testing.T) {
+	act := action{kind: actionInsertText, pos: Cursor{Row: 1}, text: [][]rune{[]rune("x")},
+		hasCursor: true, cursorBefore: Cursor{Row: 1, Col: 2}, cursorAfter: Cursor{Row: 1}}
+	if got := jsonToAction(actionToJSON(act)); got.cursorBefore != act.cursorBefore || got.cursorAfter != act.cursorAfter || !got.hasCursor {
+		t.Fatalf("round trip = %+v", got)
+	}
+}
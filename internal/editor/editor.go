@@ -3,6 +3,7 @@ package editor
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,9 +19,17 @@ import (
 	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/kobzarvs/qedit/internal/collab"
 	"github.com/kobzarvs/qedit/internal/config"
 	"github.com/kobzarvs/qedit/internal/logger"
+	"github.com/kobzarvs/qedit/internal/platform/clipboard"
+	"github.com/kobzarvs/qedit/internal/platform/zoom"
 	"github.com/kobzarvs/qedit/internal/session"
+	"github.com/kobzarvs/qedit/internal/tasks"
+	"github.com/kobzarvs/qedit/internal/validate"
+	"github.com/kobzarvs/qedit/pkg/core"
+	"github.com/kobzarvs/qedit/pkg/plugin"
+	"github.com/kobzarvs/qedit/pkg/textpos"
 )
 
 type Mode int
@@ -42,6 +51,12 @@ const (
 	actionWordRight         = "word_right"
 	actionLineStart         = "line_start"
 	actionLineEnd           = "line_end"
+	actionLineEndNonBlank   = "line_end_nonblank" // gl - before trailing whitespace
+	actionSmartHome         = "smart_home"        // Home - first non-blank, then line start
+	actionSmartEnd          = "smart_end"         // End - last non-blank, then line end
+	actionDiagnosticFloat   = "diagnostic_float"  // Ctrl+K - full diagnostics of the line
+	actionToggleFold        = "toggle_fold"       // Alt+Z - fold a comment block or long string
+	actionCompletion        = "completion"        // Ctrl+X - complete words or config keys (insert mode)
 	actionFileStart         = "file_start"
 	actionFileEnd           = "file_end"
 	actionPageUp            = "page_up"
@@ -49,6 +64,7 @@ const (
 	actionMoveLineUp        = "move_line_up"
 	actionMoveLineDown      = "move_line_down"
 	actionToggleLineNumbers = "toggle_line_numbers"
+	actionCharInfo          = "char_info"
 	actionBranchPicker      = "branch_picker"
 	actionToggleSidebar     = "toggle_sidebar"
 	actionEnterInsert       = "enter_insert"
@@ -85,6 +101,8 @@ const (
 	actionFindCharBackward = "find_char_backward" // F - find char backward
 	actionTillChar         = "till_char"          // t - till char forward
 	actionTillCharBackward = "till_char_backward" // T - till char backward
+	actionRepeatFind       = "repeat_find_char"   // Alt+. - repeat the last f/F/t/T
+	actionReverseFind      = "reverse_find_char"  // Alt+, - repeat the last f/F/t/T the other way
 
 	// Helix-style editing
 	actionDelete          = "delete"            // d - delete selection
@@ -98,13 +116,29 @@ const (
 	actionAppendLineEnd   = "append_line_end"   // A - insert at line end
 	actionInsertLineStart = "insert_line_start" // I - insert at first non-whitespace
 	actionReplaceChar     = "replace_char"      // r - replace with single char
+	actionReplacePair     = "surround_replace"  // mr - replace bracket pair under cursor
 	actionJoinLines       = "join_lines"        // J - join lines
 
 	// Helix-style selection
-	actionToggleSelect      = "toggle_select"      // v - toggle selection mode
-	actionExtendLine        = "extend_line"        // x - extend to full line
-	actionCollapseSelection = "collapse_selection" // ; - collapse selection to cursor
-	actionFlipSelection     = "flip_selection"     // Alt+; - flip selection anchor
+	actionToggleSelect      = "toggle_select"         // v - toggle selection mode
+	actionExtendLine        = "extend_line"           // x - extend to full line
+	actionCollapseSelection = "collapse_selection"    // ; - collapse selection to cursor
+	actionFlipSelection     = "flip_selection"        // Alt+; - flip selection anchor
+	actionTrimSelection     = "trim_selection"        // _ - trim whitespace from the selection ends
+	actionExtendLineBounds  = "extend_to_line_bounds" // X - extend selection to whole lines
+	actionShrinkLineBounds  = "shrink_to_line_bounds" // Alt+x - shrink selection to whole lines
+	actionExtendWordBounds  = "extend_to_word_bounds" // extend selection to whole words
+	actionShrinkWordBounds  = "shrink_to_word_bounds" // shrink selection to whole words
+
+	// Multiple selections
+	actionSelectRegex      = "select_regex"               // s - select the regex matches inside the selections
+	actionKeepSelections   = "keep_selections"            // K - keep the selections matching a regex
+	actionRemoveSelections = "remove_selections"          // Alt+K - remove the selections matching a regex
+	actionSplitSelection   = "split_selection_on_newline" // Alt+s - one selection per line
+	actionKeepPrimary      = "keep_primary_selection"     // , - drop all selections but the primary one
+	actionRemovePrimary    = "remove_primary_selection"   // Alt+, - drop the primary selection, the next one takes its place
+	actionRotateForward    = "rotate_selections_forward"  // ) - make the next selection the primary one
+	actionRotateBackward   = "rotate_selections_backward" // ( - make the previous selection the primary one
 
 	// Space mode
 	actionSpaceMode = "space_mode" // Space - open space menu
@@ -115,6 +149,10 @@ const (
 	// View mode
 	actionViewMode = "view_mode" // z - enter view mode
 
+	// Next/previous mode
+	actionNextMode = "next_mode" // ] - enter next mode
+	actionPrevMode = "prev_mode" // [ - enter previous mode
+
 	// Search
 	actionSearchForward  = "search_forward"  // / - exact search forward
 	actionSearchBackward = "search_backward" // ? - exact search backward
@@ -134,7 +172,21 @@ const (
 	actionShrinkSelection = "shrink_selection" // Alt+Shift+Down - shrink selection to child node
 
 	// File operations
-	actionSave = "save" // Cmd+S - save file
+	actionSave             = "save"              // Cmd+S - save file
+	actionCloseBuffer      = "close_buffer"      // :bd - close the current file
+	actionReopenBuffer     = "reopen_buffer"     // Cmd+Shift+T - reopen the last closed file
+	actionBufferNext       = "buffer_next"       // gn - next buffer in the bufferline
+	actionBufferPrev       = "buffer_prev"       // gp - previous buffer in the bufferline
+	actionBufferPin        = "buffer_pin"        // pin/unpin the buffer
+	actionBufferMoveLeft   = "buffer_move_left"  // move the buffer left in the bufferline
+	actionBufferMoveRight  = "buffer_move_right" // move the buffer right in the bufferline
+	actionToggleBufferline = "toggle_bufferline" // :bufferline - show/hide the bufferline
+	actionToggleSubword    = "toggle_subword"    // :subword - word motions by camelCase/snake_case parts
+	actionToggleFindLines  = "toggle_find_lines" // :findlines - f/t search past the current line
+	actionTabNew           = "tab_new"           // :tabnew - open an empty tab page
+	actionTabClose         = "tab_close"         // :tabclose - close the tab page
+	actionTabNext          = "tab_next"          // gt - next tab page
+	actionTabPrev          = "tab_prev"          // gT - previous tab page
 )
 
 // CommandInfo describes an available command with description
@@ -146,29 +198,114 @@ type CommandInfo struct {
 
 // Command groups for autocomplete
 const (
-	CmdGroupFile = "File"
-	CmdGroupEdit = "Edit"
-	CmdGroupView = "View"
+	CmdGroupFile   = "File"
+	CmdGroupEdit   = "Edit"
+	CmdGroupView   = "View"
+	CmdGroupAction = "Action"
 )
 
 // AvailableCommands lists all commands for autocomplete
 var AvailableCommands = []CommandInfo{
 	// File
 	{"w", "write file", CmdGroupFile},
+	{"w!", "write through a symlink to its target", CmdGroupFile},
+	{"wsudo", "write a file you can't write as root with sudo, after asking", CmdGroupFile},
+	{"wlink", "write, replacing a symlink with a file", CmdGroupFile},
+	{"merge", "merge changes made to the file on disk into the buffer, marking conflicts", CmdGroupFile},
 	{"q", "quit", CmdGroupFile},
 	{"q!", "force quit", CmdGroupFile},
+	{"cq", "quit with an error exit code", CmdGroupFile},
 	{"wq", "write and quit", CmdGroupFile},
 	{"x", "write and quit", CmdGroupFile},
+	{"readonly", "toggle refusing edits to a file you can't write", CmdGroupFile},
+	{"readonly off", "edit a file you can't write (:wsudo saves it with sudo)", CmdGroupFile},
+	{"readonly on", "refuse edits to this buffer", CmdGroupFile},
+	{"bom", "toggle writing a byte order mark on save", CmdGroupFile},
+	{"bom on", "save the file with a UTF-8 byte order mark", CmdGroupFile},
+	{"bom off", "save the file without a byte order mark", CmdGroupFile},
+	{"bd", "close buffer", CmdGroupFile},
+	{"bd!", "close buffer, discarding changes", CmdGroupFile},
+	{"bundo", "reopen last closed buffer", CmdGroupFile},
+	{"resume", "resume last session: reopen its buffers", CmdGroupFile},
+	{"recover", "restore unsaved edits an autosaved undo history kept", CmdGroupFile},
+	{"bn", "next buffer", CmdGroupFile},
+	{"bp", "previous buffer", CmdGroupFile},
+	{"bpin", "pin/unpin buffer", CmdGroupFile},
+	{"bmove", "move buffer left|right", CmdGroupFile},
+	{"tabnew", "new tab page [file]", CmdGroupFile},
+	{"tabclose", "close tab page", CmdGroupFile},
+	{"tabn", "next tab page", CmdGroupFile},
+	{"tabp", "previous tab page", CmdGroupFile},
+	{"export html", "copy selection or buffer as highlighted HTML [FILE]", CmdGroupFile},
+	{"export ansi", "copy selection or buffer with ANSI colors [FILE]", CmdGroupFile},
+	{"export!", "export html|ansi, overwriting FILE", CmdGroupFile},
+	{"hardcopy", "print selection or buffer with colors to a PDF [FILE]", CmdGroupFile},
+	{"hardcopy!", "print to a PDF, overwriting FILE", CmdGroupFile},
+	{"collab host", "share the buffer for editing together [ADDR] (experimental)", CmdGroupFile},
+	{"collab join", "edit a buffer shared from ADDR (experimental)", CmdGroupFile},
+	{"collab stop", "stop sharing or leave the shared buffer", CmdGroupFile},
 	// View
 	{"ln", "line numbers", CmdGroupView},
 	{"ln off", "disable line numbers", CmdGroupView},
 	{"ln abs", "absolute line numbers", CmdGroupView},
 	{"ln rel", "relative line numbers", CmdGroupView},
+	{"bufferline", "toggle bufferline", CmdGroupView},
+	{"bufferline on", "show open buffers as tabs", CmdGroupView},
+	{"bufferline off", "hide the bufferline", CmdGroupView},
+	{"ansi", "toggle ANSI colors", CmdGroupView},
+	{"char", "show character under cursor", CmdGroupView},
+	{"col", "statusline column: visual|char|byte|all", CmdGroupView},
+	{"tasks", "list running tasks", CmdGroupView},
+	{"tasks cancel", "cancel a running task [ID]", CmdGroupView},
+	{"grep", "search the project's files for TEXT, skipping ignored ones", CmdGroupFile},
+	{"theme-edit", "open the theme file with a live preview pane", CmdGroupView},
 	// Edit
 	{"fmt", "format code", CmdGroupEdit},
+	{"subword", "toggle camelCase/snake_case word motions", CmdGroupEdit},
+	{"subword on", "w/b/e stop inside camelCase and snake_case", CmdGroupEdit},
+	{"subword off", "w/b/e move by whole words", CmdGroupEdit},
+	{"retab", "convert indentation to tabs or spaces as expand-tab says", CmdGroupEdit},
+	{"retab tabs", "convert indentation (buffer or selected lines) to tabs", CmdGroupEdit},
+	{"retab spaces", "convert indentation (buffer or selected lines) to spaces", CmdGroupEdit},
+	{"tabwidth", "show or set how wide tabs are drawn in this buffer", CmdGroupView},
+	{"count", "count the matches of a regex in the selection (or the buffer)", CmdGroupEdit},
+	{"select", "select the matches of a regex in the selections (or the buffer)", CmdGroupEdit},
+	{"keep", "keep the selections matching a regex", CmdGroupEdit},
+	{"remove", "remove the selections matching a regex", CmdGroupEdit},
+	{"s/", "s/pattern/replacement/[gis] on the selected lines or the cursor line; s: selected text only", CmdGroupEdit},
+	{"%s/", "%s/pattern/replacement/[gi] on the whole buffer", CmdGroupEdit},
+	{"macro save", "save the last recorded macro (Q) to the library under a name", CmdGroupEdit},
+	{"macro run", "replay a macro from the library", CmdGroupEdit},
+	{"macro list", "pick a macro from the library and replay it", CmdGroupEdit},
+	{"macro delete", "remove a macro from the library", CmdGroupEdit},
+	{"pastetable", "paste tab-separated clipboard data as aligned columns at the cursor", CmdGroupEdit},
+	{"conceal", "toggle hiding markdown markup in this buffer", CmdGroupView},
+	{"conceal on", "hide **, `, link targets... except on the cursor line", CmdGroupView},
+	{"conceal off", "show the markup as it is", CmdGroupView},
+	{"findlines", "toggle f/t searching past the current line", CmdGroupEdit},
+	{"findlines on", "f/t continue onto the following lines", CmdGroupEdit},
+	{"findlines off", "f/t stay on the line (brackets and quotes still cross)", CmdGroupEdit},
+	{"renumber", "renumber the ordered lists of the markdown file (or selection)", CmdGroupEdit},
+	{"todo toggle", "check/uncheck the markdown task (a list item becomes one)", CmdGroupEdit},
+	{"todo up", "move the task and its subtasks above the previous one", CmdGroupEdit},
+	{"todo down", "move the task and its subtasks below the next one", CmdGroupEdit},
+	{"autocorrect", "toggle abbreviation expansion in prose files", CmdGroupEdit},
+	{"autocorrect on", "replace abbreviations (teh -> the) as words are typed", CmdGroupEdit},
+	{"autocorrect off", "leave typed words alone", CmdGroupEdit},
+	{"json fmt", "pretty-print JSON", CmdGroupEdit},
+	{"json min", "minify JSON", CmdGroupEdit},
+	{"json path", "copy JSON path at cursor", CmdGroupEdit},
+	{"encode base64", "base64-encode selection", CmdGroupEdit},
+	{"decode base64", "base64-decode selection", CmdGroupEdit},
+	{"encode url", "URL-encode selection", CmdGroupEdit},
+	{"decode url", "URL-decode selection", CmdGroupEdit},
+	{"uuid", "insert UUIDv4", CmdGroupEdit},
+	{"random", "insert random string [length]", CmdGroupEdit},
 	// Sidebar
 	{"sidebar", "toggle sidebar", CmdGroupView},
 	{"sidew", "set sidebar width", CmdGroupView},
+	// Actions
+	{"action", "run an editor action [count]", CmdGroupAction},
 }
 
 // SpaceMenuItem represents an item in the space menu
@@ -181,15 +318,15 @@ type SpaceMenuItem struct {
 
 // SpaceMenuItems defines the space menu structure
 var SpaceMenuItems = []SpaceMenuItem{
-	{'f', "Open file picker", "file_picker", false},
-	{'F', "Open file picker at cwd", "file_picker_cwd", false},
-	{'e', "Open file explorer", "file_explorer", false},
-	{'E', "Open file explorer at buffer dir", "file_explorer_buffer", false},
+	{'f', "Open file picker", "file_picker", true},
+	{'F', "Open file picker at cwd", "file_picker_cwd", true},
+	{'e', "Open file explorer", "file_explorer", true},
+	{'E', "Open file explorer at buffer dir", "file_explorer_buffer", true},
 	{'b', "Open buffer picker", "buffer_picker", false},
 	{'j', "Open jumplist picker", "jumplist_picker", false},
 	{'s', "Open symbol picker", "symbol_picker", false},
 	{'S', "Open workspace symbol picker", "workspace_symbol_picker", false},
-	{'d', "Open diagnostic picker", "diagnostic_picker", false},
+	{'d', "Open diagnostic picker", "diagnostic_picker", true},
 	{'D', "Open workspace diagnostic picker", "workspace_diagnostic_picker", false},
 	{'g', "Open changed file picker", "changed_file_picker", false},
 	{'a', "Perform code action", "code_action", false},
@@ -201,8 +338,8 @@ var SpaceMenuItems = []SpaceMenuItem{
 	{'p', "Paste from clipboard", "paste_clipboard", true},
 	{'P', "Paste before from clipboard", "paste_clipboard_before", true},
 	{'R', "Replace with clipboard", "replace_clipboard", false},
-	{'/', "Global search", "global_search", false},
-	{'k', "Show docs for item", "show_docs", false},
+	{'/', "Global search", "global_search", true},
+	{'k', "Show docs for item", "show_docs", true},
 	{'r', "Rename symbol", "rename_symbol", false},
 	{'h', "Select symbol references", "select_references", false},
 	{'c', "Comment/uncomment", "toggle_comment", true},
@@ -214,21 +351,23 @@ var SpaceMenuItems = []SpaceMenuItem{
 var GotoMenuItems = []SpaceMenuItem{
 	{'g', "Go to file start", "goto_first_line", true},
 	{'e', "Go to file end", "goto_file_end", true},
+	{'f', "Go to file under cursor", "goto_file", true},
 	{'h', "Go to line start", "line_start", true},
-	{'l', "Go to line end", "line_end", true},
+	{'l', "Go to line end", "line_end_nonblank", true},
 	{'s', "Go to first non-whitespace", "goto_first_nonblank", true},
 	{'d', "Go to definition", "goto_definition", true},
 	{'D', "Go to declaration", "goto_declaration", true},
 	{'y', "Go to type definition", "goto_type_definition", true},
 	{'r', "Go to references", "goto_references", true},
 	{'i', "Go to implementation", "goto_implementation", true},
-	{'t', "Go to window top", "goto_window_top", true},
+	{'t', "Go to next tab page", "tab_next", true},
+	{'T', "Go to previous tab page", "tab_prev", true},
 	{'c', "Go to window center", "goto_window_center", true},
 	{'b', "Go to window bottom", "goto_window_bottom", true},
 	{'a', "Go to last accessed file", "goto_last_accessed", false},
 	{'m', "Go to last modified file", "goto_last_modified", false},
-	{'n', "Go to next buffer", "goto_next_buffer", false},
-	{'p', "Go to previous buffer", "goto_prev_buffer", false},
+	{'n', "Go to next buffer", "goto_next_buffer", true},
+	{'p', "Go to previous buffer", "goto_prev_buffer", true},
 	{'.', "Go to last change", "goto_last_change", false},
 }
 
@@ -236,12 +375,22 @@ var GotoMenuItems = []SpaceMenuItem{
 var MatchMenuItems = []SpaceMenuItem{
 	{'m', "Go to matching bracket", "match_bracket", true},
 	{'s', "Surround add", "surround_add", false},
-	{'r', "Surround replace", "surround_replace", false},
+	{'r', "Replace pair under cursor", "surround_replace", true},
 	{'d', "Surround delete", "surround_delete", false},
 	{'a', "Select around object", "select_around", false},
 	{'i', "Select inside object", "select_inside", false},
 }
 
+// NextMenuItems defines the next mode menu (] prefix)
+var NextMenuItems = []SpaceMenuItem{
+	{']', "Go to next heading", "heading_next", true},
+}
+
+// PrevMenuItems defines the previous mode menu ([ prefix)
+var PrevMenuItems = []SpaceMenuItem{
+	{'[', "Go to previous heading", "heading_prev", true},
+}
+
 // ViewMenuItems defines the view/scroll mode menu (z prefix)
 var ViewMenuItems = []SpaceMenuItem{
 	{'c', "Center cursor line", "view_center", true},
@@ -288,6 +437,9 @@ type action struct {
 	selectionStart Cursor   // Selection to restore on undo
 	selectionEnd   Cursor   // Selection to restore on undo
 	hasSelection   bool     // Whether to restore selection on undo
+	cursorBefore   Cursor   // Cursor to restore on undo
+	cursorAfter    Cursor   // Cursor to restore on redo
+	hasCursor      bool     // Whether undo and redo restore the cursor and selection
 }
 
 // actionJSON is used for serializing actions to changelog files
@@ -305,25 +457,16 @@ type actionJSON struct {
 	SelectionStart [2]int   `json:"ss,omitempty"`
 	SelectionEnd   [2]int   `json:"se,omitempty"`
 	HasSelection   bool     `json:"hs,omitempty"`
+	CursorBefore   [2]int   `json:"cb,omitempty"`
+	CursorAfter    [2]int   `json:"ca,omitempty"`
+	HasCursor      bool     `json:"hc,omitempty"`
 }
 
-type Cursor struct {
-	Row int
-	Col int
-}
+// Cursor is a rune position in the buffer
+type Cursor = core.Position
 
-type TextEdit struct {
-	Valid          bool
-	StartByte      int
-	OldEndByte     int
-	NewEndByte     int
-	StartRow       int
-	StartColBytes  int
-	OldEndRow      int
-	OldEndColBytes int
-	NewEndRow      int
-	NewEndColBytes int
-}
+// TextEdit is a buffer change in the form tree-sitter takes (see ByteEdit)
+type TextEdit = core.TextEdit
 
 type HighlightSpan struct {
 	StartCol int
@@ -336,7 +479,8 @@ type keymapSet struct {
 	insert map[string]string
 }
 
-// NodeRange represents a syntax node's position range
+// NodeRange represents a syntax node's position range.
+// Columns are byte offsets, as reported by tree-sitter.
 type NodeRange struct {
 	StartRow int
 	StartCol int
@@ -344,10 +488,47 @@ type NodeRange struct {
 	EndCol   int
 }
 
-// NodeStackFunc is a callback to get syntax node stack at a position
+// NodeStackFunc is a callback to get syntax node stack at a position (byte column)
 type NodeStackFunc func(path string, row, col int) []NodeRange
 
-// LSPLocation represents a location returned by LSP
+// BracketPair is an opening bracket and its closing bracket, paired by the
+// syntax tree. Columns are byte offsets.
+type BracketPair struct {
+	OpenRow  int
+	OpenCol  int
+	CloseRow int
+	CloseCol int
+}
+
+// BracketPairFunc is a callback to get the bracket pair whose bracket is
+// at a position (byte column), or else the innermost pair around it
+type BracketPairFunc func(path string, row, col int) (BracketPair, bool)
+
+// ScopeWord is an identifier of the file and the depth of the innermost
+// syntax node around a position that contains it (0 is the nearest)
+type ScopeWord struct {
+	Name  string
+	Depth int
+}
+
+// ScopeWordsFunc is a callback to get the identifiers of a file ranked by
+// scope from a position (byte column)
+type ScopeWordsFunc func(path string, row, col int) []ScopeWord
+
+// TaskMarker is the box of a markdown task list item, found by the syntax
+// tree: the row and byte column of its [ and whether it is checked
+type TaskMarker struct {
+	Row     int
+	Col     int
+	Checked bool
+}
+
+// TaskMarkersFunc is a callback to get the task list markers of a file on
+// rows startRow to endRow, false when the file has no syntax tree
+type TaskMarkersFunc func(path string, startRow, endRow int) ([]TaskMarker, bool)
+
+// LSPLocation represents a location returned by LSP.
+// Columns are UTF-16 offsets, as defined by the LSP specification.
 type LSPLocation struct {
 	Path      string
 	StartLine int
@@ -356,8 +537,8 @@ type LSPLocation struct {
 	EndCol    int
 }
 
-// LSPGotoFunc is a callback to perform LSP goto operations
-type LSPGotoFunc func(method, path string, line, col int) ([]LSPLocation, error)
+// LSPGotoFunc is a callback to perform LSP goto operations (UTF-16 column)
+type LSPGotoFunc func(ctx context.Context, method, path string, line, col int) ([]LSPLocation, error)
 
 // HighlightRangeFunc is a callback to get syntax highlights for a range
 type HighlightRangeFunc func(path string, startLine, endLine int) map[int][]HighlightSpan
@@ -370,19 +551,36 @@ type Editor struct {
 	mode                         Mode
 	filename                     string
 	dirty                        bool
+	readOnly                     bool      // refuse to overwrite the opened file
+	fileReadOnly                 bool      // the opened file isn't writable by the user; edits are refused
+	bom                          bool      // the file starts with a byte order mark, written back on save
+	dropBOM                      bool      // drop-bom: files are saved without their byte order mark
+	symlinkConfirmed             string    // symlink :w! wrote through, so saves no longer ask
+	disk                         diskState // the open file on disk when last read or written
+	terminal                     config.TerminalFeatures
+	pasting                      bool // inside a bracketed paste
 	keymap                       keymapSet
 	cmd                          []rune
 	cmdCursor                    int      // cursor position within cmd
 	cmdHistory                   []string // command history
 	cmdHistoryIndex              int      // current position in history (-1 = not browsing)
 	cmdHistoryPrefix             string   // prefix for filtered history search
+	cmdHistorySearch             bool     // Ctrl+R reverse search through the history is active
+	cmdHistoryQuery              []rune   // text the reverse search looks for
+	cmdHistorySaved              []rune   // command line before the reverse search, restored on Esc
 	statusMessage                string
 	undo                         []action
 	redo                         []action
-	savePoint                    int
-	tabWidth                     int
+	savedRevision                uint64 // revision of the text on disk
+	baseRevision                 uint64 // revision with nothing left to undo
+	tabWidth                     int    // columns a tab is drawn as in this buffer (:tabwidth)
+	defaultTabWidth              int    // tab-width from the config, for buffers without :tabwidth
+	indentWidth                  int    // columns per indent level when indenting with spaces
+	expandTab                    bool   // Tab and > indent with spaces
 	viewHeight                   int
 	viewWidth                    int
+	editorX                      int        // screen column where the gutter starts, right of the sidebar
+	folds                        []lineFold // quick folds, sorted by row
 	styleMain                    tcell.Style
 	styleStatus                  tcell.Style
 	styleCommand                 tcell.Style
@@ -414,7 +612,12 @@ type Editor struct {
 	styleAutoCompleteHotkey      tcell.Style
 	styleAutoCompleteDescription tcell.Style
 	styleAutoCompleteGroup       tcell.Style
+	styleDiagnosticError         tcell.Style
+	styleDiagnosticWarning       tcell.Style
+	styleDiagnosticInfo          tcell.Style
+	styleDiagnosticHint          tcell.Style
 	lineNumberMode               LineNumberMode
+	columnMode                   ColumnMode
 	layoutName                   string
 	gitBranch                    string
 	gitMainBranch                string // detected main branch (main/master)
@@ -426,14 +629,48 @@ type Editor struct {
 	highlightStart               int
 	highlightEnd                 int
 	changeTick                   uint64
-	lastEdit                     TextEdit
-	branchPickerActive           bool
+	popups                       []popup // open overlays, focused one last
 	branchPickerItems            []string
 	branchPickerIndex            int
 	branchPickerRequested        bool
 	branchPickerSelection        string
 	sidebar                      *Sidebar
 	sidebarStyles                SidebarStyles
+	sidebarFiles                 *SidebarFilesContent
+	sidebarProblems              *SidebarProblemsContent
+	problems                     []validate.Problem // TOML/YAML validation problems from the last save
+	projectRoot                  string             // directory opened as project (file tree root)
+	openFileRequest              string
+	openFileLocation             *LSPLocation    // where to put the cursor in openFileRequest
+	closedBuffers                []bufferView    // files closed with :bd, most recent last
+	closedBufferPath             string          // file closed since the app last asked
+	buffers                      []bufferEntry   // files opened this session, in bufferline order
+	bufferline                   bool            // draw the buffers as tabs above the text
+	subword                      bool            // word motions stop at camelCase and snake_case parts
+	bufferlineVisible            bool            // the bufferline was drawn in the last Render
+	bufferTabs                   []bufferTab     // bufferline tab spans from the last Render
+	pickerWidths                 map[string]int  // picker widths chosen this session, by picker type
+	pickerDrag                   string          // picker whose split is being dragged with the mouse
+	tasks                        *tasks.Registry // long-running jobs; nil runs them synchronously
+	tabs                         []tabPage       // tab pages; the active one is saved only when switching away
+	tabIndex                     int             // active tab page
+	reopening                    *bufferView     // :bundo or buffer switch waiting for OpenFile
+	preview                      bool            // buffer holds a summary of a binary or huge file
+	ansiColors                   bool            // show ANSI colors for files with escape codes
+	ansiView                     bool            // escape codes are rendered as colors, not literal text
+	ansiStates                   []ansiStyle
+	ansiStatesTick               uint64
+	jsonPath                     string // statusline JSON path cache
+	jsonPathTick                 uint64
+	jsonPathCursor               Cursor
+	jsonPathValid                bool
+	todoProgressText             string // statusline task list progress cache
+	todoProgressTick             uint64
+	todoProgressRow              int
+	todoProgressValid            bool
+	fileTreeShowHidden           bool
+	fileTreeShowIgnored          bool
+	ignorePatterns               []string
 	lineUndoRow                  int
 	lineUndoContent              []rune
 	lineUndoValid                bool
@@ -441,27 +678,67 @@ type Editor struct {
 	freeScroll                   bool
 	lastScrollTime               time.Time
 	undoGroup                    uint64
+	undoGroupDepth               int // nesting of BeginUndoGroup calls
+	undoGroupEdits               int // edits recorded in the open public undo group
+	changeListeners              []changeSubscription
+	nextChangeListenerID         uint64
+	pendingChanges               []TextChange // changes waiting for the change tick
 
 	// Helix-style state
-	clipboard                  [][]rune // yanked text (lines)
-	pendingAction              string   // pending action waiting for char input (f/F/t/T/r)
-	selectMode                 bool     // whether in visual/select mode
-	lastFindChar               rune     // last char used in f/F/t/T
-	lastFindForward            bool     // direction of last find
-	lastFindTill               bool     // whether last find was till (t/T)
-	gotoMode                   bool     // whether in goto mode (g prefix)
-	matchMode                  bool     // whether in match mode (m prefix)
-	viewMode                   bool     // whether in view mode (z prefix)
-	windowMode                 bool     // whether in window mode (space-w prefix)
-	pendingKeys                string   // keys typed so far in a sequence (e.g., "g" waiting for second key)
-	lastCommand                string   // last executed command for display (e.g., "gg", "ge", "fw")
-	spaceMenuActive            bool     // whether space menu is open
-	keybindingsHelpActive      bool     // whether keybindings help popup is open
-	keybindingsHelpScroll      int      // scroll position in keybindings help
-	keybindingsHelpFilterKey   []rune   // filter for Key column
-	keybindingsHelpFilterAct   []rune   // filter for Action column
-	keybindingsHelpFilterDesc  []rune   // filter for Description column
-	keybindingsHelpFilterFocus int      // 0=Key, 1=Action, 2=Description
+	clipboard                  [][]rune         // yanked text (lines)
+	selectMode                 bool             // whether in visual/select mode
+	lastFindChar               rune             // last char used in f/F/t/T
+	lastFindForward            bool             // direction of last find
+	lastFindTill               bool             // whether last find was till (t/T)
+	findLines                  bool             // f/t continue onto other lines for every char, not just brackets
+	count                      int              // count typed before a normal-mode key (3w, 2f,), 0 if none
+	sequence                   keySequence      // unfinished multi-key sequence (g, m, z, Space, f...)
+	keyTimeout                 time.Duration    // how long a sequence waits for its next key; 0 waits forever
+	whichKeyDelay              time.Duration    // how long a sequence waits before its menu is shown
+	lastCommand                string           // last executed command for display (e.g., "gg", "ge", "fw")
+	keybindingsHelpScroll      int              // scroll position in keybindings help
+	menuScroll                 int              // first row shown of a key menu too tall for the screen
+	menuPage                   int              // rows of the key menu shown, for PgUp/PgDn
+	menuRows                   int              // rows of the key menu in all
+	completion                 configCompletion // open completion list
+	theme                      config.Theme     // colors from the config, for the theme preview
+	themePreview               themePreview     // :theme-edit preview pane
+	collab                     *collab.Session  // shared editing session, nil when not sharing
+	collabStop                 func()           // ends the subscription sending edits to collab
+	collabApplying             bool             // edits come from the other side; don't send them back
+	wake                       func()           // wakes the event loop up from another goroutine
+	keybindingsHelpFilterKey   []rune           // filter for Key column
+	keybindingsHelpFilterAct   []rune           // filter for Action column
+	keybindingsHelpFilterDesc  []rune           // filter for Description column
+	keybindingsHelpFilterFocus int              // 0=Key, 1=Action, 2=Description
+
+	// Autocorrect in prose files
+	autocorrect   bool              // expand abbreviations at word boundaries
+	abbreviations map[string]string // typed word -> replacement
+
+	langmap langmap // layout characters -> QWERTY keys for normal mode
+
+	// Macros
+	macroRecording bool        // Q is recording keys
+	macroKeys      []string    // keys recorded so far, in <> notation
+	macroMark      int         // macroKeys before the key being handled
+	lastMacro      string      // the macro q replays
+	macroDepth     int         // macros being replayed, nested
+	macroQuit      bool        // a replayed macro quit the editor
+	macroPicker    macroPicker // :macro list
+	filePicker     filePicker  // Space f
+
+	// Conceal layer
+	conceal       bool   // hide markup on lines other than the cursor's (:conceal)
+	concealFenced []bool // rows in code blocks, as of concealTick
+	concealTick   uint64
+
+	// Plugin additions
+	statusSegments []statusSegment                    // statusline segments
+	gutterSigns    map[string]map[string][]GutterSign // file -> group -> signs
+	plugins        []*plugin.Host                     // running plugin programs
+	signRows       map[int]GutterSign                 // winning sign per row of signRowsFile
+	signRowsFile   string
 
 	// Search state
 	searchQuery         []rune        // current search query
@@ -471,6 +748,7 @@ type Editor struct {
 	searchForward       bool          // search direction
 	searchFuzzy         bool          // true = fuzzy search (cmd+f), false = exact (/)
 	searchRegex         bool          // true = regex search (cmd+e)
+	searchScan          *searchScan   // search still scanning the buffer, nil when done
 	lastSearchQuery     string        // last search query for n/N
 	searchHistory       []string      // search history (prefixed with /: F: or E:)
 	searchHistoryIndex  int           // current position in search history (-1 = not browsing)
@@ -486,14 +764,17 @@ type Editor struct {
 	copiedMessageTime time.Time // when "copied" was shown
 
 	// Selection scope (expand/shrink)
-	nodeStackFunc       NodeStackFunc // callback to get syntax node stack
-	selectionScopeStack []NodeRange   // stack of selection scopes for shrinking
-	selectionScopeIndex int           // current index in scope stack
+	nodeStackFunc       NodeStackFunc   // callback to get syntax node stack
+	bracketPairFunc     BracketPairFunc // callback to pair brackets from the syntax tree
+	scopeWordsFunc      ScopeWordsFunc  // callback to rank identifiers by scope for completion
+	taskMarkersFunc     TaskMarkersFunc // callback to find markdown task boxes in the syntax tree
+	gutter              gutterMouse     // mouse press in the line number gutter
+	selectionScopeStack []NodeRange     // stack of selection scopes for shrinking
+	selectionScopeIndex int             // current index in scope stack
 
 	// LSP integration
 	lspGotoFunc          LSPGotoFunc                        // callback for LSP goto operations
 	highlightRangeFunc   HighlightRangeFunc                 // callback to get highlights for a range
-	refsPickerActive     bool                               // whether references picker is shown
 	refsPickerItems      []LSPLocation                      // list of references
 	refsPickerIndex      int                                // selected reference index
 	refsPickerTitle      string                             // picker title (e.g., "References", "Implementations")
@@ -502,6 +783,25 @@ type Editor struct {
 
 	// Session persistence
 	sessionManager *session.Manager
+	noState        bool // --no-state: don't read or write history, undo and session files
+	aborted        bool // quit with :cq
+
+	// Undo history writes
+	historyAutosave time.Duration     // undo-history-autosave, 0 writes it only on save
+	historyTick     uint64            // changeTick when the history was last written
+	historySavedAt  time.Time         // when the history was last written
+	historyWriting  bool              // a write runs in a task
+	historyPending  *undoHistoryWrite // the write to run after it
+	recovery        *string           // unsaved text the loaded history kept, for :recover
+
+	// Saving files the user can't write
+	suspend         func(func() error) error   // runs a command with the terminal handed back
+	privilegedWrite func(string, []byte) error // replaces sudo tee in tests
+	sudoTarget      string                     // file :wsudo asks to write
+
+	// Multiple selections
+	selections     []selection // selections beside the primary one, in text order
+	selectionsTick uint64      // changeTick the selections fit
 
 	// Test hook for keymap coverage.
 	actionHook func(action string)
@@ -544,91 +844,36 @@ func New(cfg config.Config) *Editor {
 	if tabWidth < 1 {
 		tabWidth = 1
 	}
-
-	// Build color palette for reference resolution
-	colors := make(map[string]tcell.Color)
-	resolve := func(value string, fallback tcell.Color) tcell.Color {
-		if value == "" {
-			return fallback
-		}
-		if c, ok := colors[value]; ok {
-			return c
-		}
-		return parseColor(value, fallback)
+	indentWidth := cfg.Editor.IndentWidth
+	if indentWidth < 1 {
+		indentWidth = tabWidth
 	}
 
-	colors["foreground"] = parseColor(cfg.Theme.Foreground, tcell.ColorWhite)
-	colors["background"] = parseColor(cfg.Theme.Background, tcell.ColorBlack)
-	colors["statusline-foreground"] = resolve(cfg.Theme.StatuslineForeground, tcell.ColorBlack)
-	colors["statusline-background"] = resolve(cfg.Theme.StatuslineBackground, tcell.ColorGray)
-	colors["commandline-foreground"] = resolve(cfg.Theme.CommandlineForeground, colors["statusline-foreground"])
-	colors["commandline-background"] = resolve(cfg.Theme.CommandlineBackground, colors["statusline-background"])
-	colors["line-number-foreground"] = resolve(cfg.Theme.LineNumberForeground, tcell.ColorGray)
-	colors["line-number-active-foreground"] = resolve(cfg.Theme.LineNumberActiveForeground, colors["foreground"])
-	colors["selection-foreground"] = resolve(cfg.Theme.SelectionForeground, colors["foreground"])
-	colors["selection-background"] = resolve(cfg.Theme.SelectionBackground, colors["background"])
-	colors["search-foreground"] = resolve(cfg.Theme.SearchMatchForeground, tcell.ColorBlack)
-	colors["search-background"] = resolve(cfg.Theme.SearchMatchBackground, tcell.ColorYellow)
-	colors["syntax-keyword"] = resolve(cfg.Theme.SyntaxKeyword, colors["foreground"])
-	colors["syntax-string"] = resolve(cfg.Theme.SyntaxString, colors["foreground"])
-	colors["syntax-comment"] = resolve(cfg.Theme.SyntaxComment, colors["foreground"])
-	colors["syntax-type"] = resolve(cfg.Theme.SyntaxType, colors["foreground"])
-	colors["syntax-function"] = resolve(cfg.Theme.SyntaxFunction, colors["foreground"])
-	colors["syntax-number"] = resolve(cfg.Theme.SyntaxNumber, colors["foreground"])
-	colors["syntax-constant"] = resolve(cfg.Theme.SyntaxConstant, colors["foreground"])
-	colors["syntax-operator"] = resolve(cfg.Theme.SyntaxOperator, colors["foreground"])
-	colors["syntax-punctuation"] = resolve(cfg.Theme.SyntaxPunctuation, colors["foreground"])
-	colors["syntax-field"] = resolve(cfg.Theme.SyntaxField, colors["foreground"])
-	colors["syntax-builtin"] = resolve(cfg.Theme.SyntaxBuiltin, colors["foreground"])
-	colors["syntax-unknown"] = resolve(cfg.Theme.SyntaxUnknown, tcell.ColorRed)
-	colors["syntax-variable"] = resolve(cfg.Theme.SyntaxVariable, colors["foreground"])
-	colors["syntax-parameter"] = resolve(cfg.Theme.SyntaxParameter, colors["foreground"])
-	colors["branch-foreground"] = resolve(cfg.Theme.BranchForeground, colors["statusline-foreground"])
-	colors["branch-background"] = resolve(cfg.Theme.BranchBackground, colors["statusline-background"])
-	// Main branch has distinct default color (light green) to stand out
-	mainBranchDefaultFg := tcell.NewRGBColor(144, 238, 144) // #90EE90 light green
-	colors["main-branch-foreground"] = resolve(cfg.Theme.MainBranchForeground, mainBranchDefaultFg)
-	colors["main-branch-background"] = resolve(cfg.Theme.MainBranchBackground, colors["statusline-background"])
-
-	// Keyboard layout colors
-	layoutUSFg := tcell.NewRGBColor(144, 238, 144) // #90EE90 light green
-	layoutRUFg := tcell.NewRGBColor(135, 206, 250) // #87CEFA light sky blue
-	colors["layout-us-foreground"] = layoutUSFg
-	colors["layout-ru-foreground"] = layoutRUFg
-	colors["layout-other-foreground"] = colors["statusline-foreground"]
-
-	// Autocomplete colors
-	colors["autocomplete-background"] = resolve(cfg.Theme.AutocompleteBackground, colors["commandline-background"])
-	colors["autocomplete-hotkey"] = resolve(cfg.Theme.AutocompleteHotkey, tcell.ColorWhite)
-	colors["autocomplete-description"] = resolve(cfg.Theme.AutocompleteDescription, colors["commandline-foreground"])
-	colors["autocomplete-group"] = resolve(cfg.Theme.AutocompleteGroup, tcell.ColorGray)
-
-	// Sidebar colors
-	colors["sidebar-foreground"] = resolve(cfg.Theme.SidebarForeground, colors["foreground"])
-	colors["sidebar-background"] = resolve(cfg.Theme.SidebarBackground, colors["background"])
-	colors["sidebar-dir-foreground"] = resolve(cfg.Theme.SidebarDirForeground, tcell.ColorBlue)
-	colors["sidebar-selected-foreground"] = resolve(cfg.Theme.SidebarSelectedForeground, colors["background"])
-	colors["sidebar-selected-background"] = resolve(cfg.Theme.SidebarSelectedBackground, tcell.ColorYellow)
-	colors["sidebar-header-foreground"] = resolve(cfg.Theme.SidebarHeaderForeground, colors["foreground"])
-	colors["sidebar-header-background"] = resolve(cfg.Theme.SidebarHeaderBackground, colors["statusline-background"])
-	colors["sidebar-border-foreground"] = resolve(cfg.Theme.SidebarBorderForeground, colors["line-number-foreground"])
-	colors["sidebar-hidden-foreground"] = resolve(cfg.Theme.SidebarHiddenForeground, colors["line-number-foreground"])
-	colors["sidebar-ignored-foreground"] = resolve(cfg.Theme.SidebarIgnoredForeground, colors["line-number-foreground"])
-	colors["sidebar-indicator-foreground"] = resolve(cfg.Theme.SidebarIndicatorForeground, tcell.ColorYellow)
-	colors["sidebar-hotkey-foreground"] = resolve(cfg.Theme.SidebarHotkeyForeground, tcell.ColorBlue)
-	colors["sidebar-unavailable-foreground"] = resolve(cfg.Theme.SidebarUnavailableForeground, colors["line-number-foreground"])
+	colors := themeColors(cfg.Theme)
 
 	lineNumberMode := parseLineNumberMode(cfg.Editor.LineNumbers)
+	columnMode, _ := parseColumnMode(cfg.Editor.StatusColumn)
+	keyTimeout := time.Duration(cfg.Editor.KeyTimeout) * time.Millisecond
+	// app.Run refuses a langmap that doesn't parse (CheckLangmap)
+	langmap, _ := parseLangmap(cfg.Editor.Langmap)
 	gitBranchSymbol := strings.TrimSpace(cfg.Editor.GitBranchSymbol)
 
 	// Initialize session manager (ignore error, session persistence is optional)
 	sessionMgr, _ := session.NewManager()
 
-	return &Editor{
+	e := &Editor{
 		lines:                        [][]rune{[]rune{}},
 		mode:                         ModeNormal,
 		keymap:                       keymapSet{normal: normal, insert: insert},
+		keyTimeout:                   keyTimeout,
+		theme:                        cfg.Theme,
+		whichKeyDelay:                time.Duration(cfg.Editor.WhichKeyDelay) * time.Millisecond,
+		historyAutosave:              time.Duration(cfg.Editor.UndoHistoryAutosave) * time.Second,
+		dropBOM:                      cfg.Editor.DropBOM,
 		tabWidth:                     tabWidth,
+		defaultTabWidth:              tabWidth,
+		indentWidth:                  indentWidth,
+		expandTab:                    cfg.Editor.ExpandTab,
 		styleMain:                    tcell.StyleDefault.Foreground(colors["foreground"]).Background(colors["background"]),
 		styleStatus:                  tcell.StyleDefault.Foreground(colors["statusline-foreground"]).Background(colors["statusline-background"]),
 		styleCommand:                 tcell.StyleDefault.Foreground(colors["commandline-foreground"]).Background(colors["commandline-background"]),
@@ -660,7 +905,12 @@ func New(cfg config.Config) *Editor {
 		styleAutoCompleteHotkey:      tcell.StyleDefault.Foreground(colors["autocomplete-hotkey"]).Background(colors["autocomplete-background"]),
 		styleAutoCompleteDescription: tcell.StyleDefault.Foreground(colors["autocomplete-description"]).Background(colors["autocomplete-background"]),
 		styleAutoCompleteGroup:       tcell.StyleDefault.Foreground(colors["autocomplete-group"]).Background(colors["autocomplete-background"]),
+		styleDiagnosticError:         tcell.StyleDefault.Foreground(colors["diagnostic-error-foreground"]).Background(colors["background"]),
+		styleDiagnosticWarning:       tcell.StyleDefault.Foreground(colors["diagnostic-warning-foreground"]).Background(colors["background"]),
+		styleDiagnosticInfo:          tcell.StyleDefault.Foreground(colors["diagnostic-info-foreground"]).Background(colors["background"]),
+		styleDiagnosticHint:          tcell.StyleDefault.Foreground(colors["diagnostic-hint-foreground"]).Background(colors["background"]),
 		lineNumberMode:               lineNumberMode,
+		columnMode:                   columnMode,
 		gitBranchSymbol:              gitBranchSymbol,
 		highlightStart:               -1,
 		highlightEnd:                 -1,
@@ -671,6 +921,17 @@ func New(cfg config.Config) *Editor {
 			cfg.Editor.SidebarMaxWidth,
 			cfg.Editor.SidebarCloseOnSelect,
 		),
+		fileTreeShowHidden:  cfg.Editor.FileTreeShowHidden,
+		fileTreeShowIgnored: cfg.Editor.FileTreeShowIgnored,
+		ignorePatterns:      cfg.Editor.Ignore,
+		ansiColors:          cfg.Editor.AnsiColors,
+		bufferline:          cfg.Editor.Bufferline,
+		subword:             cfg.Editor.Subword,
+		findLines:           cfg.Editor.FindLines,
+		autocorrect:         cfg.Editor.Autocorrect,
+		abbreviations:       cfg.Abbreviations,
+		langmap:             langmap,
+		terminal:            config.GenericTerminal(),
 		sidebarStyles: SidebarStyles{
 			Base:        tcell.StyleDefault.Foreground(colors["sidebar-foreground"]).Background(colors["sidebar-background"]),
 			Dir:         tcell.StyleDefault.Foreground(colors["sidebar-dir-foreground"]).Background(colors["sidebar-background"]),
@@ -685,40 +946,189 @@ func New(cfg config.Config) *Editor {
 			Current:     tcell.StyleDefault.Foreground(colors["sidebar-indicator-foreground"]).Background(colors["sidebar-background"]),
 		},
 	}
+	// Folds move with the text they hide
+	e.OnChange(e.shiftFolds)
+	// Search matches point into the text as it was
+	e.OnChange(e.dropSearchMatches)
+	return e
+}
+
+// themeColors resolves the colors of theme by key. A value can name
+// another key, as in line-number-foreground = "foreground".
+func themeColors(theme config.Theme) map[string]tcell.Color {
+	colors := make(map[string]tcell.Color)
+	resolve := func(value string, fallback tcell.Color) tcell.Color {
+		if value == "" {
+			return fallback
+		}
+		if c, ok := colors[value]; ok {
+			return c
+		}
+		return parseColor(value, fallback)
+	}
+
+	colors["foreground"] = parseColor(theme.Foreground, tcell.ColorWhite)
+	colors["background"] = parseColor(theme.Background, tcell.ColorBlack)
+	colors["statusline-foreground"] = resolve(theme.StatuslineForeground, tcell.ColorBlack)
+	colors["statusline-background"] = resolve(theme.StatuslineBackground, tcell.ColorGray)
+	colors["commandline-foreground"] = resolve(theme.CommandlineForeground, colors["statusline-foreground"])
+	colors["commandline-background"] = resolve(theme.CommandlineBackground, colors["statusline-background"])
+	colors["line-number-foreground"] = resolve(theme.LineNumberForeground, tcell.ColorGray)
+	colors["line-number-active-foreground"] = resolve(theme.LineNumberActiveForeground, colors["foreground"])
+	colors["selection-foreground"] = resolve(theme.SelectionForeground, colors["foreground"])
+	colors["selection-background"] = resolve(theme.SelectionBackground, colors["background"])
+	colors["search-foreground"] = resolve(theme.SearchMatchForeground, tcell.ColorBlack)
+	colors["search-background"] = resolve(theme.SearchMatchBackground, tcell.ColorYellow)
+	colors["syntax-keyword"] = resolve(theme.SyntaxKeyword, colors["foreground"])
+	colors["syntax-string"] = resolve(theme.SyntaxString, colors["foreground"])
+	colors["syntax-comment"] = resolve(theme.SyntaxComment, colors["foreground"])
+	colors["syntax-type"] = resolve(theme.SyntaxType, colors["foreground"])
+	colors["syntax-function"] = resolve(theme.SyntaxFunction, colors["foreground"])
+	colors["syntax-number"] = resolve(theme.SyntaxNumber, colors["foreground"])
+	colors["syntax-constant"] = resolve(theme.SyntaxConstant, colors["foreground"])
+	colors["syntax-operator"] = resolve(theme.SyntaxOperator, colors["foreground"])
+	colors["syntax-punctuation"] = resolve(theme.SyntaxPunctuation, colors["foreground"])
+	colors["syntax-field"] = resolve(theme.SyntaxField, colors["foreground"])
+	colors["syntax-builtin"] = resolve(theme.SyntaxBuiltin, colors["foreground"])
+	colors["syntax-unknown"] = resolve(theme.SyntaxUnknown, tcell.ColorRed)
+	colors["syntax-variable"] = resolve(theme.SyntaxVariable, colors["foreground"])
+	colors["syntax-parameter"] = resolve(theme.SyntaxParameter, colors["foreground"])
+	colors["branch-foreground"] = resolve(theme.BranchForeground, colors["statusline-foreground"])
+	colors["branch-background"] = resolve(theme.BranchBackground, colors["statusline-background"])
+	// Main branch has distinct default color (light green) to stand out
+	mainBranchDefaultFg := tcell.NewRGBColor(144, 238, 144) // #90EE90 light green
+	colors["main-branch-foreground"] = resolve(theme.MainBranchForeground, mainBranchDefaultFg)
+	colors["main-branch-background"] = resolve(theme.MainBranchBackground, colors["statusline-background"])
+
+	// Keyboard layout colors
+	layoutUSFg := tcell.NewRGBColor(144, 238, 144) // #90EE90 light green
+	layoutRUFg := tcell.NewRGBColor(135, 206, 250) // #87CEFA light sky blue
+	colors["layout-us-foreground"] = layoutUSFg
+	colors["layout-ru-foreground"] = layoutRUFg
+	colors["layout-other-foreground"] = colors["statusline-foreground"]
+
+	// Autocomplete colors
+	colors["autocomplete-background"] = resolve(theme.AutocompleteBackground, colors["commandline-background"])
+	colors["autocomplete-hotkey"] = resolve(theme.AutocompleteHotkey, tcell.ColorWhite)
+	colors["autocomplete-description"] = resolve(theme.AutocompleteDescription, colors["commandline-foreground"])
+	colors["autocomplete-group"] = resolve(theme.AutocompleteGroup, tcell.ColorGray)
+
+	// Sidebar colors
+	colors["sidebar-foreground"] = resolve(theme.SidebarForeground, colors["foreground"])
+	colors["sidebar-background"] = resolve(theme.SidebarBackground, colors["background"])
+	colors["sidebar-dir-foreground"] = resolve(theme.SidebarDirForeground, tcell.ColorBlue)
+	colors["sidebar-selected-foreground"] = resolve(theme.SidebarSelectedForeground, colors["background"])
+	colors["sidebar-selected-background"] = resolve(theme.SidebarSelectedBackground, tcell.ColorYellow)
+	colors["sidebar-header-foreground"] = resolve(theme.SidebarHeaderForeground, colors["foreground"])
+	colors["sidebar-header-background"] = resolve(theme.SidebarHeaderBackground, colors["statusline-background"])
+	colors["sidebar-border-foreground"] = resolve(theme.SidebarBorderForeground, colors["line-number-foreground"])
+	colors["sidebar-hidden-foreground"] = resolve(theme.SidebarHiddenForeground, colors["line-number-foreground"])
+	colors["sidebar-ignored-foreground"] = resolve(theme.SidebarIgnoredForeground, colors["line-number-foreground"])
+	colors["sidebar-indicator-foreground"] = resolve(theme.SidebarIndicatorForeground, tcell.ColorYellow)
+	colors["sidebar-hotkey-foreground"] = resolve(theme.SidebarHotkeyForeground, tcell.ColorBlue)
+	colors["sidebar-unavailable-foreground"] = resolve(theme.SidebarUnavailableForeground, colors["line-number-foreground"])
+	colors["diagnostic-error-foreground"] = resolve(theme.DiagnosticErrorForeground, tcell.ColorRed)
+	colors["diagnostic-warning-foreground"] = resolve(theme.DiagnosticWarningForeground, tcell.ColorYellow)
+	colors["diagnostic-info-foreground"] = resolve(theme.DiagnosticInfoForeground, tcell.ColorBlue)
+	colors["diagnostic-hint-foreground"] = resolve(theme.DiagnosticHintForeground, colors["line-number-foreground"])
+	return colors
 }
 
 func (e *Editor) OpenFile(path string) error {
-	data, err := os.ReadFile(path)
+	previewLines, reason, err := filePreview(path)
 	if err != nil {
 		return err
 	}
-	e.lines = splitLines(data)
+	var data, text []byte
+	var bom, latin1 bool
+	if previewLines == nil {
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+		text, bom = cutBOM(data)
+	}
+	// Remember where we were in the previous file
+	e.saveSessionState()
+	e.rememberBufferView()
+	e.cancelBufferTasks()
+	e.stopCollab()
+	e.preview = previewLines != nil
+	if e.preview {
+		e.lines = previewLines
+	} else {
+		var decoded string
+		decoded, latin1 = decodeText(text)
+		e.lines = core.SplitLines(decoded)
+	}
 	if len(e.lines) == 0 {
 		e.lines = [][]rune{[]rune{}}
 	}
+	e.resetBufferState()
+	e.filename = path
+	e.trackBuffer()
+	e.applyBufferSettings()
+	e.ansiView = !e.preview && e.ansiColors && hasANSIEscapes(e.lines)
+	if e.preview {
+		e.setStatus(reason + ": read-only preview")
+		return nil
+	}
+	e.rememberDisk(data)
+	_ = e.LoadUndoHistory()
+	e.fileReadOnly = !fileWritable(path)
+	e.bom = bom && !e.dropBOM
+
+	// Restore session state
+	e.restoreSessionState()
+	e.restoreBufferView()
+	if loc := e.openFileLocation; loc != nil {
+		e.openFileLocation = nil
+		e.moveToLocation(*loc)
+	}
+
+	if !e.ansiView && hasANSIEscapes(e.lines) {
+		e.setStatus("ANSI escape codes found (:ansi to show colors)")
+	}
+	if latin1 {
+		e.setStatus("not valid UTF-8: bytes read as Latin-1, saving writes UTF-8")
+	}
+	e.notifyPlugins(plugin.EventOpen, path)
+	return nil
+}
+
+// resetBufferState clears everything tied to the buffer's text: cursor,
+// scroll, undo, highlights and problems. e.lines is left as is.
+func (e *Editor) resetBufferState() {
 	e.cursor = Cursor{}
 	e.scroll = 0
 	e.scrollX = 0
 	e.mode = ModeNormal
-	e.filename = path
 	e.cmd = e.cmd[:0]
 	e.statusMessage = ""
-	e.undo = nil
-	e.redo = nil
-	e.savePoint = 0
+	e.resetHistory()
 	e.changeTick = 0
-	e.lastEdit.Valid = false
+	e.historyTick = 0
+	e.recovery = nil
 	e.highlights = nil
 	e.highlightStart = -1
 	e.highlightEnd = -1
 	e.selectionActive = false
+	e.selections = nil
+	e.searchScan = nil
+	e.folds = nil
+	e.ansiStates = nil
+	e.jsonPathValid = false
+	e.todoProgressValid = false
+	e.clearProblems()
+	e.disk = diskState{}
+	e.fileReadOnly = false
+	e.bom = false
 	e.updateDirty()
-	_ = e.LoadUndoHistory()
-
-	// Restore session state
-	e.restoreSessionState()
+}
 
-	return nil
+// IsPreview reports whether the buffer shows a summary of a binary or huge
+// file instead of its contents
+func (e *Editor) IsPreview() bool {
+	return e.preview
 }
 
 func (e *Editor) restoreSessionState() {
@@ -820,21 +1230,47 @@ func (e *Editor) saveSessionState() {
 	e.sessionManager.SetFileState(absPath, state)
 }
 
-// Shutdown saves session state and stops background tasks
+// Shutdown cancels running tasks, saves session state and stops
+// background tasks
 func (e *Editor) Shutdown() {
-	e.saveSessionState()
+	e.stopCollab()
+	e.stopPlugins()
+	if e.tasks != nil {
+		e.tasks.Shutdown(shutdownTimeout)
+	}
+	e.flushUndoHistory()
+	e.SyncSession()
 	if e.sessionManager != nil {
 		e.sessionManager.Stop()
 	}
 }
 
 func (e *Editor) HandleKey(ev *tcell.EventKey) bool {
+	if key, ok := e.splitMetaKey(ev); ok {
+		if e.HandleKey(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone)) {
+			return true
+		}
+		ev = key
+	}
+	defer e.revealCursor()
 	e.freeScroll = false
 	if e.mode != ModeCommand && e.mode != ModeSearch && e.statusMessage != "" {
 		e.statusMessage = ""
 	}
 	// Track last key combination for display
 	e.lastKeyCombo = keyStringDisplay(ev)
+	e.recordMacroKey(ev)
+
+	if e.pasting && (e.mode == ModeInsert || e.mode == ModeNormal) {
+		e.handlePastedKey(ev)
+		return false
+	}
+	ev = e.translateAltToCmd(ev)
+
+	// The focused popup (branch picker, help, references) gets keys first
+	if e.handlePopupKey(ev) {
+		return e.takeMacroQuit()
+	}
 
 	// Handle sidebar if focused
 	if e.sidebar != nil && e.sidebar.Visible && e.sidebar.Focused {
@@ -846,8 +1282,6 @@ func (e *Editor) HandleKey(ev *tcell.EventKey) bool {
 		return e.handleInsert(ev)
 	case ModeCommand:
 		return e.handleCommand(ev)
-	case ModeBranchPicker:
-		return e.handleBranchPicker(ev)
 	case ModeSearch:
 		return e.handleSearch(ev)
 	default:
@@ -855,9 +1289,82 @@ func (e *Editor) HandleKey(ev *tcell.EventKey) bool {
 	}
 }
 
+// SetTerminalFeatures applies terminal workarounds resolved from [terminal] config
+func (e *Editor) SetTerminalFeatures(features config.TerminalFeatures) {
+	e.terminal = features
+}
+
+// HandlePaste marks the start or end of a bracketed paste
+func (e *Editor) HandlePaste(start bool) {
+	e.pasting = start
+}
+
+// handlePastedKey inserts pasted text literally instead of running keybindings
+func (e *Editor) handlePastedKey(ev *tcell.EventKey) {
+	if e.mode != ModeInsert {
+		e.setStatus("paste ignored (enter insert mode first)")
+		return
+	}
+	switch ev.Key() {
+	case tcell.KeyRune:
+		e.insertRune(ev.Rune())
+	case tcell.KeyEnter:
+		e.insertNewline()
+	case tcell.KeyTab:
+		e.insertRune('\t')
+	}
+}
+
+// splitMetaKey returns alt+key as key on a terminal whose Alt key doesn't
+// send Esc: the Alt came from an Esc typed just before the key, which the
+// caller handles first
+func (e *Editor) splitMetaKey(ev *tcell.EventKey) (*tcell.EventKey, bool) {
+	if e.terminal.MetaSendsEscape || ev.Modifiers()&tcell.ModAlt == 0 {
+		return ev, false
+	}
+	return tcell.NewEventKey(ev.Key(), ev.Rune(), ev.Modifiers()&^tcell.ModAlt), true
+}
+
+// translateAltToCmd rewrites alt+key as cmd+key on terminals that cannot report
+// the Cmd modifier, unless the alt combination has its own binding.
+func (e *Editor) translateAltToCmd(ev *tcell.EventKey) *tcell.EventKey {
+	mods := ev.Modifiers()
+	if !e.terminal.AltAsCmd || mods&tcell.ModAlt == 0 || mods&tcell.ModMeta != 0 {
+		return ev
+	}
+	keymap := e.keymap.normal
+	if e.mode == ModeInsert {
+		keymap = e.keymap.insert
+	}
+	altKey := keyString(ev)
+	if ev.Key() == tcell.KeyRune {
+		altKey = "alt+" + altKey
+	}
+	if _, ok := keymap[altKey]; ok {
+		return ev
+	}
+	translated := tcell.NewEventKey(ev.Key(), ev.Rune(), mods&^tcell.ModAlt|tcell.ModMeta)
+	if _, ok := keymap[keyStringForMap(translated, keymap)]; !ok {
+		return ev
+	}
+	return translated
+}
+
 func (e *Editor) HandleMouse(ev *tcell.EventMouse) {
+	defer e.revealCursor()
+	// The bufferline takes row 0; the rest of the screen is shifted down
+	if e.bufferlineVisible {
+		x, y := ev.Position()
+		if y == 0 {
+			if ev.Buttons() == tcell.Button1 && !e.modalPopupOpen() {
+				e.clickBufferline(x)
+			}
+			return
+		}
+		ev = tcell.NewEventMouse(x, y-1, ev.Buttons(), ev.Modifiers())
+	}
 	// Intercept mouse events when modal is open
-	if e.keybindingsHelpActive {
+	if e.popupOpen(keybindingsHelpPopup{}) {
 		if ev.Buttons() == tcell.WheelUp {
 			if e.keybindingsHelpScroll > 0 {
 				e.keybindingsHelpScroll--
@@ -868,6 +1375,13 @@ func (e *Editor) HandleMouse(ev *tcell.EventMouse) {
 		return
 	}
 
+	if e.handlePickerDrag(ev) {
+		return
+	}
+	if e.handleGutterMouse(ev) {
+		return
+	}
+
 	if ev.Buttons() == tcell.WheelUp {
 		e.scrollUp(1)
 		e.freeScroll = true
@@ -928,7 +1442,7 @@ func (e *Editor) maxVisibleLineWidth() int {
 	}
 	maxWidth := 0
 	for i := startLine; i < endLine; i++ {
-		w := visualCol(e.lines[i], len(e.lines[i]), e.tabWidth)
+		w := e.displayCol(e.lines[i], len(e.lines[i]))
 		if w > maxWidth {
 			maxWidth = w
 		}
@@ -940,7 +1454,7 @@ func (e *Editor) handleMouseClick(ev *tcell.EventMouse) {
 	x, y := ev.Position()
 
 	// Convert screen Y to line number
-	row := y + e.scroll
+	row := e.rowAtVisibleIndex(e.visibleIndex(e.scroll) + y)
 	if row < 0 {
 		row = 0
 	}
@@ -959,7 +1473,7 @@ func (e *Editor) handleMouseClick(ev *tcell.EventMouse) {
 	}
 
 	// Convert visual column to logical column
-	col := visualToLogicalCol(e.lines[row], visualX, e.tabWidth)
+	col := e.rowLogicalColAt(row, visualX)
 
 	// Set cursor position
 	e.cursor.Row = row
@@ -968,11 +1482,12 @@ func (e *Editor) handleMouseClick(ev *tcell.EventMouse) {
 
 	// Clear selection and free scroll mode
 	e.selectionActive = false
+	e.selections = nil
 	e.freeScroll = false
 }
 
 func (e *Editor) scrollUp(lines int) {
-	e.scroll -= lines
+	e.scroll = e.rowAtVisibleIndex(e.visibleIndex(e.scroll) - lines)
 	if e.scroll < 0 {
 		e.scroll = 0
 	}
@@ -981,14 +1496,11 @@ func (e *Editor) scrollUp(lines int) {
 func (e *Editor) scrollDown(lines int) {
 	// Keep last line at least 5 lines above status line
 	viewHeight := e.viewHeightCached()
-	maxScroll := len(e.lines) - viewHeight + 5
+	maxScroll := e.visibleIndex(len(e.lines)) - viewHeight + 5
 	if maxScroll < 0 {
 		maxScroll = 0
 	}
-	e.scroll += lines
-	if e.scroll > maxScroll {
-		e.scroll = maxScroll
-	}
+	e.scroll = e.rowAtVisibleIndex(min(e.visibleIndex(e.scroll)+lines, maxScroll))
 }
 
 // scrollViewUp scrolls the view up (shows earlier lines), keeping cursor visible
@@ -1031,6 +1543,12 @@ func (e *Editor) Render(s tcell.Screen) {
 	if w <= 0 || h <= 0 {
 		return
 	}
+	e.bufferlineVisible = e.bufferlineShown(h)
+	if e.bufferlineVisible {
+		e.renderBufferline(s, w)
+		s = &bufferlineScreen{Screen: s, style: e.styleMain}
+		h--
+	}
 
 	statusY := h - 2
 	cmdY := h - 1
@@ -1049,17 +1567,12 @@ func (e *Editor) Render(s tcell.Screen) {
 	sidebarWidth := 0
 	if e.sidebar != nil && e.sidebar.Visible {
 		sidebarWidth = e.sidebar.CalculateWidth(w)
-	} else if e.refsPickerActive && len(e.refsPickerItems) > 0 {
-		sidebarWidth = w / 4
-		if sidebarWidth < 20 {
-			sidebarWidth = 20
-		}
-		if sidebarWidth > w/2 {
-			sidebarWidth = w / 2
-		}
+	} else if e.refsSplitShown() {
+		sidebarWidth = e.refsPickerWidth(w)
 	}
 	editorX := sidebarWidth
 	editorWidth := w - sidebarWidth
+	e.editorX = editorX
 
 	gutterWidth := e.gutterWidth()
 	if !e.freeScroll {
@@ -1070,20 +1583,21 @@ func (e *Editor) Render(s tcell.Screen) {
 	s.SetStyle(e.styleMain)
 	s.Clear()
 
-	// Draw editor content (offset by sidebar)
+	// Draw editor content (offset by sidebar); folded lines are skipped
+	lineIdx := e.rowAtVisibleIndex(e.visibleIndex(e.scroll))
 	for y := 0; y < viewHeight; y++ {
-		lineIdx := e.scroll + y
 		if lineIdx >= len(e.lines) {
 			clearLineAt(s, editorX, y, editorWidth, e.styleMain)
 			continue
 		}
 		e.drawLineWithGutterAt(s, editorX, y, editorWidth, gutterWidth, lineIdx)
+		lineIdx = e.nextVisibleRow(lineIdx)
 	}
 
 	// Draw sidebar (new sidebar takes priority over refs picker)
 	if e.sidebar != nil && e.sidebar.Visible && sidebarWidth > 0 {
 		e.sidebar.Render(s, e.sidebarStyles, 0, 0, sidebarWidth, viewHeight)
-	} else if e.refsPickerActive && sidebarWidth > 0 {
+	} else if e.popupOpen(refsPickerPopup{}) && sidebarWidth > 0 {
 		e.renderRefsSidebar(s, sidebarWidth, viewHeight)
 	}
 
@@ -1106,12 +1620,12 @@ func (e *Editor) Render(s tcell.Screen) {
 	}
 	cursorVisible := true
 	if e.mode != ModeCommand && e.mode != ModeSearch && e.mode != ModeBranchPicker {
-		cy = e.cursor.Row - e.scroll
+		cy = e.visibleIndex(e.cursor.Row) - e.visibleIndex(e.scroll)
 		if cy < 0 || cy >= viewHeight {
 			cursorVisible = false
 		}
 		if e.cursor.Row >= 0 && e.cursor.Row < len(e.lines) {
-			cx = editorX + gutterWidth + visualCol(e.lines[e.cursor.Row], e.cursor.Col, e.tabWidth) - e.scrollX
+			cx = editorX + gutterWidth + e.displayCol(e.lines[e.cursor.Row], e.cursor.Col) - e.scrollX
 		}
 		if cx < editorX+gutterWidth {
 			cx = editorX + gutterWidth
@@ -1121,29 +1635,29 @@ func (e *Editor) Render(s tcell.Screen) {
 		}
 	}
 
-	if e.branchPickerActive {
-		e.renderBranchPicker(s, w, viewHeight)
-	}
-	if e.spaceMenuActive {
-		e.renderSpaceMenu(s, w, viewHeight)
-	}
-	if e.gotoMode {
-		e.renderMenu(s, w, viewHeight, "Goto", GotoMenuItems)
-	}
-	if e.matchMode {
-		e.renderMenu(s, w, viewHeight, "Match", MatchMenuItems)
-	}
-	if e.viewMode {
-		e.renderMenu(s, w, viewHeight, "View", ViewMenuItems)
-	}
-	if e.windowMode {
-		e.renderMenu(s, w, viewHeight, "Window", WindowMenuItems)
-	}
-	if e.keybindingsHelpActive {
-		e.renderKeybindingsHelp(s, w, viewHeight)
-	}
+	e.drawPeerCursor(s, w, viewHeight)
+	e.drawSelectionCursors(s, w, viewHeight)
+	if e.menuShown() {
+		switch e.sequence.kind {
+		case seqSpace:
+			e.renderMenu(s, w, viewHeight, "Space", SpaceMenuItems)
+		case seqGoto:
+			e.renderMenu(s, w, viewHeight, "Goto", GotoMenuItems)
+		case seqMatch:
+			e.renderMenu(s, w, viewHeight, "Match", MatchMenuItems)
+		case seqView:
+			e.renderMenu(s, w, viewHeight, "View", ViewMenuItems)
+		case seqWindow:
+			e.renderMenu(s, w, viewHeight, "Window", WindowMenuItems)
+		case seqNext:
+			e.renderMenu(s, w, viewHeight, "Next", NextMenuItems)
+		case seqPrev:
+			e.renderMenu(s, w, viewHeight, "Previous", PrevMenuItems)
+		}
+	}
+	e.renderPopups(s, w, viewHeight)
 	sidebarFocused := e.sidebar != nil && e.sidebar.Visible && e.sidebar.Focused
-	if e.mode == ModeBranchPicker || e.spaceMenuActive || e.keybindingsHelpActive || sidebarFocused || !cursorVisible {
+	if e.modalPopupOpen() || (e.inSequence(seqSpace) && e.menuShown()) || sidebarFocused || !cursorVisible {
 		s.HideCursor()
 		s.Show()
 		return
@@ -1175,104 +1689,31 @@ func (e *Editor) handleNormal(ev *tcell.EventKey) bool {
 		return false
 	}
 
-	// Handle space menu
-	if e.spaceMenuActive {
-		return e.handleSpaceMenu(ev)
-	}
-
-	// Handle refs picker - only intercept navigation keys, let others fall through
-	if e.refsPickerActive {
-		if handled := e.handleRefsPicker(ev); handled {
-			return false
-		}
-		// Key not handled by refs picker, continue to normal handling
-	}
-
-	// Handle keybindings help popup
-	if e.keybindingsHelpActive {
-		return e.handleKeybindingsHelp(ev)
-	}
-
-	// Handle goto mode (g prefix)
-	if e.gotoMode {
-		e.gotoMode = false
-		e.pendingKeys = ""
-		if ev.Key() == tcell.KeyEscape {
-			return false
-		}
-		if ev.Key() == tcell.KeyRune {
-			return e.handleGotoKey(ev.Rune())
-		}
-		return false
-	}
-
-	// Handle match mode (m prefix)
-	if e.matchMode {
-		e.matchMode = false
-		e.pendingKeys = ""
-		if ev.Key() == tcell.KeyEscape {
-			return false
-		}
-		if ev.Key() == tcell.KeyRune {
-			return e.handleMatchKey(ev.Rune())
-		}
-		return false
-	}
-
-	// Handle view mode (z prefix)
-	if e.viewMode {
-		e.viewMode = false
-		e.pendingKeys = ""
-		if ev.Key() == tcell.KeyEscape {
-			return false
-		}
-		if ev.Key() == tcell.KeyRune {
-			return e.handleViewKey(ev.Rune())
-		}
-		return false
-	}
-
-	// Handle window mode (space-w prefix)
-	if e.windowMode {
-		e.windowMode = false
-		e.pendingKeys = ""
-		if ev.Key() == tcell.KeyEscape {
-			return false
-		}
-		if ev.Key() == tcell.KeyRune {
-			return e.handleWindowKey(ev.Rune())
-		}
-		return false
-	}
-
-	// Handle pending char input (f/F/t/T/r)
-	if e.pendingAction != "" {
-		pendingKey := e.pendingKeys
-		e.pendingKeys = ""
-		if ev.Key() == tcell.KeyEscape {
-			e.pendingAction = ""
-			return false
-		}
-		if ev.Key() == tcell.KeyRune {
-			e.handlePendingChar(ev.Rune())
-			e.lastCommand = pendingKey + string(ev.Rune())
-			return false
-		}
-		// Ignore other keys while waiting for char
-		return false
+	// Finish a pending key sequence (g, m, z, Space, f/F/t/T/r)
+	if e.sequence.kind != seqNone {
+		return e.handleSequenceKey(ev)
 	}
 
+	ev = e.langmapKey(ev)
 	if e.handleSelectionMove(ev) {
 		return false
 	}
 	key := keyStringForMap(ev, e.keymap.normal)
-	if key == "" {
-		return false
-	}
 	action, ok := e.keymap.normal[key]
 	if !ok {
+		if !e.addCountDigit(ev) {
+			e.count = 0
+		}
 		return false
 	}
+	// f/t and Alt+. take the count themselves; other repeatable actions
+	// run count times, the selection covering the last one as in Helix
+	defer func() { e.count = 0 }()
+	if a := lookupAction(action); a != nil && a.repeatable {
+		for i := 1; i < e.count; i++ {
+			e.execAction(action)
+		}
+	}
 
 	// Helix-style: w, b, e, f, F, t, T - anchor moves to old cursor, cursor moves to target
 	// Selection covers what was "jumped over"
@@ -1324,7 +1765,11 @@ func (e *Editor) handleGotoKey(ch rune) bool {
 		return e.lspGoto("implementation")
 	case 't':
 		e.lastCommand = "gt"
-		e.scrollCursorToTop()
+		e.cycleTab(1)
+		return false
+	case 'T':
+		e.lastCommand = "gT"
+		e.cycleTab(-1)
 		return false
 	case 'c':
 		e.lastCommand = "gc"
@@ -1334,6 +1779,18 @@ func (e *Editor) handleGotoKey(ch rune) bool {
 		e.lastCommand = "gb"
 		e.scrollCursorToBottom()
 		return false
+	case 'f':
+		e.lastCommand = "gf"
+		e.gotoFileUnderCursor()
+		return false
+	case 'n':
+		e.lastCommand = "gn"
+		e.cycleBuffer(1)
+		return false
+	case 'p':
+		e.lastCommand = "gp"
+		e.cycleBuffer(-1)
+		return false
 	}
 
 	var action string
@@ -1345,7 +1802,7 @@ func (e *Editor) handleGotoKey(ch rune) bool {
 	case 'h':
 		action = actionLineStart
 	case 'l':
-		action = actionLineEnd
+		action = actionLineEndNonBlank
 	case 's':
 		action = actionFileStart // same as gg
 	default:
@@ -1368,6 +1825,73 @@ func (e *Editor) handleGotoKey(ch rune) bool {
 	return e.execAction(action)
 }
 
+// gotoFileUnderCursor opens the file whose path is under the cursor (gf).
+// Relative paths are resolved against the current file, then the project root.
+func (e *Editor) gotoFileUnderCursor() {
+	name := e.pathAtCursor()
+	if name == "" {
+		e.setStatus("no file path under cursor")
+		return
+	}
+	var candidates []string
+	if filepath.IsAbs(name) {
+		candidates = append(candidates, name)
+	} else {
+		if e.filename != "" {
+			candidates = append(candidates, filepath.Join(filepath.Dir(e.filename), name))
+		}
+		if e.projectRoot != "" {
+			candidates = append(candidates, filepath.Join(e.projectRoot, name))
+		}
+		candidates = append(candidates, name)
+	}
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			e.openSidebarFiles(path)
+			return
+		}
+		if e.dirty {
+			e.setStatus("unsaved changes (use :w first)")
+			return
+		}
+		e.openFileRequest = path
+		return
+	}
+	e.setStatus("file not found: " + name)
+}
+
+// pathAtCursor returns the file path under the cursor, or the selection if any
+func (e *Editor) pathAtCursor() string {
+	if start, end, ok := e.selectionRange(); ok && start.Row == end.Row {
+		line := e.lines[start.Row]
+		return strings.TrimSpace(string(line[clampRange(start.Col, 0, len(line)):clampRange(end.Col, 0, len(line))]))
+	}
+	line := e.lineAt(e.cursor.Row)
+	if e.cursor.Col >= len(line) {
+		return ""
+	}
+	isPathRune := func(r rune) bool {
+		return !unicode.IsSpace(r) && !strings.ContainsRune("\"'`()[]{}<>,;|", r)
+	}
+	start, end := e.cursor.Col, e.cursor.Col
+	for start > 0 && isPathRune(line[start-1]) {
+		start--
+	}
+	for end < len(line) && isPathRune(line[end]) {
+		end++
+	}
+	// Drop trailing punctuation and a :line[:col] suffix
+	name := strings.TrimRight(string(line[start:end]), ".:")
+	if i := strings.Index(name, ":"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
 // lspGoto performs an LSP goto operation
 func (e *Editor) lspGoto(method string) bool {
 	if e.lspGotoFunc == nil {
@@ -1379,14 +1903,31 @@ func (e *Editor) lspGoto(method string) bool {
 		return false
 	}
 
-	locations, err := e.lspGotoFunc(method, e.filename, e.cursor.Row, e.cursor.Col)
+	// The request runs as a task of the buffer; it is canceled if the
+	// file is closed, and its result dropped if another file was opened
+	col := textpos.RuneToUTF16(e.lineAt(e.cursor.Row), e.cursor.Col)
+	path, row, gotoFunc := e.filename, e.cursor.Row, e.lspGotoFunc
+	e.runBufferTask("LSP "+method, func(ctx context.Context) func() {
+		locations, err := gotoFunc(ctx, method, path, row, col)
+		return func() {
+			if e.filename == path {
+				e.showLSPLocations(method, locations, err)
+			}
+		}
+	})
+	return false
+}
+
+// showLSPLocations jumps to the single location of an LSP goto or lists
+// several in the references picker
+func (e *Editor) showLSPLocations(method string, locations []LSPLocation, err error) {
 	if err != nil {
 		e.setStatus("LSP: " + err.Error())
-		return false
+		return
 	}
 	if len(locations) == 0 {
 		e.setStatus("LSP: no " + method + " found")
-		return false
+		return
 	}
 
 	// For references/implementations or multiple results, show picker
@@ -1402,7 +1943,7 @@ func (e *Editor) lspGoto(method string) bool {
 			title = "Type Definitions"
 		}
 		e.showRefsPicker(title, locations)
-		return false
+		return
 	}
 
 	// Single result: jump directly
@@ -1410,14 +1951,13 @@ func (e *Editor) lspGoto(method string) bool {
 	currentAbs, _ := filepath.Abs(e.filename)
 	if loc.Path != currentAbs && loc.Path != e.filename {
 		e.setStatus("LSP: " + loc.Path + ":" + strconv.Itoa(loc.StartLine+1) + " (cross-file)")
-		return false
+		return
 	}
 
-	e.cursor.Row = loc.StartLine
-	e.cursor.Col = loc.StartCol
+	// The buffer may have been edited while the request ran
+	e.cursor = core.ClampPos(e, Cursor{Row: loc.StartLine, Col: textpos.UTF16ToRune(e.lineAt(loc.StartLine), loc.StartCol)})
 	e.ensureCursorVisible(e.viewHeightCached())
 	e.setStatus(method + " → line " + strconv.Itoa(loc.StartLine+1))
-	return false
 }
 
 // handleMatchKey handles the second key after 'm' prefix
@@ -1434,7 +1974,7 @@ func (e *Editor) handleMatchKey(ch rune) bool {
 	case 's':
 		e.setStatus("surround add (not implemented)")
 	case 'r':
-		e.setStatus("surround replace (not implemented)")
+		e.beginCharSequence(actionReplacePair, "mr")
 	case 'd':
 		e.setStatus("surround delete (not implemented)")
 	default:
@@ -1486,14 +2026,6 @@ func (e *Editor) handleKeybindingsHelp(ev *tcell.EventKey) bool {
 	}
 
 	switch ev.Key() {
-	case tcell.KeyEscape:
-		e.keybindingsHelpActive = false
-		e.keybindingsHelpFilterKey = nil
-		e.keybindingsHelpFilterAct = nil
-		e.keybindingsHelpFilterDesc = nil
-		e.keybindingsHelpScroll = 0
-		e.keybindingsHelpFilterFocus = 0
-		return false
 	case tcell.KeyEnter:
 		// Clear all filters on Enter
 		if len(e.keybindingsHelpFilterKey) > 0 || len(e.keybindingsHelpFilterAct) > 0 || len(e.keybindingsHelpFilterDesc) > 0 {
@@ -1502,7 +2034,7 @@ func (e *Editor) handleKeybindingsHelp(ev *tcell.EventKey) bool {
 			e.keybindingsHelpFilterDesc = nil
 			e.keybindingsHelpScroll = 0
 		} else {
-			e.keybindingsHelpActive = false
+			e.closePopup(keybindingsHelpPopup{})
 		}
 		return false
 	case tcell.KeyTab:
@@ -1543,52 +2075,133 @@ func (e *Editor) handleKeybindingsHelp(ev *tcell.EventKey) bool {
 	return false
 }
 
-// goToMatchingBracket jumps to the matching bracket or quote
+// goToMatchingBracket jumps to the matching bracket or quote. With a
+// syntax tree, brackets are paired from the tree, so brackets inside
+// strings and comments don't count, and from inside a pair it jumps to the
+// pair's closing bracket. Otherwise the text is scanned.
 func (e *Editor) goToMatchingBracket() {
-	if e.cursor.Row < 0 || e.cursor.Row >= len(e.lines) {
-		return
+	if pos, ok := e.matchingBracket(true); ok {
+		e.cursor = pos
 	}
-	line := e.lines[e.cursor.Row]
-	if e.cursor.Col < 0 || e.cursor.Col >= len(line) {
-		return
+}
+
+// matchingBracket returns the position of the bracket or quote matching the
+// one under the cursor. With enclosing, off a bracket it returns the
+// closing bracket of the pair around the cursor. When there is no match it
+// says why in the statusline.
+func (e *Editor) matchingBracket(enclosing bool) (Cursor, bool) {
+	if e.cursor.Row < 0 || e.cursor.Row >= len(e.lines) {
+		return Cursor{}, false
+	}
+	line := e.lines[e.cursor.Row]
+	if e.cursor.Col < 0 || e.cursor.Col > len(line) {
+		return Cursor{}, false
+	}
+	var ch rune
+	if e.cursor.Col < len(line) {
+		ch = line[e.cursor.Col]
+	}
+	if !enclosing && !isBracketOrQuote(ch) {
+		e.setStatus("no bracket or quote under cursor")
+		return Cursor{}, false
 	}
 
-	ch := line[e.cursor.Col]
+	if pos, ok := e.treeMatchingBracket(ch); ok {
+		return pos, true
+	}
 
 	// Handle quotes/backticks (same char for open/close)
 	if ch == '"' || ch == '\'' || ch == '`' {
-		e.goToMatchingQuote(ch)
-		return
+		return e.matchingQuote(ch)
 	}
 
 	// Handle brackets (different chars for open/close)
-	var match rune
-	var forward bool
+	match, forward, ok := bracketPartner(ch)
+	if !ok {
+		if pos, ok := e.enclosingCloseBracket(); ok {
+			return pos, true
+		}
+		e.setStatus("no bracket or quote under cursor")
+		return Cursor{}, false
+	}
 
+	if pos, ok := e.scanBracket(e.cursor, ch, match, forward); ok {
+		return pos, true
+	}
+	e.setStatus("no matching bracket found")
+	return Cursor{}, false
+}
+
+// bracketPartner returns the other bracket of the pair ch belongs to and
+// whether it comes after ch
+func bracketPartner(ch rune) (rune, bool, bool) {
 	switch ch {
 	case '(':
-		match, forward = ')', true
+		return ')', true, true
 	case ')':
-		match, forward = '(', false
+		return '(', false, true
 	case '[':
-		match, forward = ']', true
+		return ']', true, true
 	case ']':
-		match, forward = '[', false
+		return '[', false, true
 	case '{':
-		match, forward = '}', true
+		return '}', true, true
 	case '}':
-		match, forward = '{', false
+		return '{', false, true
 	case '<':
-		match, forward = '>', true
+		return '>', true, true
 	case '>':
-		match, forward = '<', false
-	default:
-		e.setStatus("no bracket or quote under cursor")
-		return
+		return '<', false, true
+	}
+	return 0, false, false
+}
+
+// replaceMatchingPair replaces the bracket or quote under the cursor with
+// ch and its match with ch's partner, as one undo step (mr). Typing an
+// opening or a closing bracket gives the same pair.
+func (e *Editor) replaceMatchingPair(ch rune) bool {
+	if e.refuseEdit() {
+		return false
+	}
+	if !isBracketOrQuote(ch) {
+		e.setStatus("mr takes a bracket or quote")
+		return false
+	}
+	match, ok := e.matchingBracket(false)
+	if !ok {
+		return false
 	}
+	open, closing := e.cursor, match
+	if closing.Before(open) {
+		open, closing = closing, open
+	}
+	openCh, closeCh := ch, ch
+	if partner, forward, ok := bracketPartner(ch); ok {
+		if forward {
+			closeCh = partner
+		} else {
+			openCh = partner
+		}
+	}
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	for _, d := range []struct {
+		pos Cursor
+		ch  rune
+	}{{open, openCh}, {closing, closeCh}} {
+		if _, err := e.ReplaceRange(d.pos, Cursor{Row: d.pos.Row, Col: d.pos.Col + 1}, string(d.ch)); err != nil {
+			e.setStatus(err.Error())
+			return false
+		}
+	}
+	return true
+}
 
+// scanBracket scans the text from the bracket ch at from for its match,
+// counting nested pairs of ch and match
+func (e *Editor) scanBracket(from Cursor, ch, match rune, forward bool) (Cursor, bool) {
 	depth := 1
-	row, col := e.cursor.Row, e.cursor.Col
+	row, col := from.Row, from.Col
 
 	if forward {
 		col++
@@ -1600,9 +2213,7 @@ func (e *Editor) goToMatchingBracket() {
 				} else if line[col] == match {
 					depth--
 					if depth == 0 {
-						e.cursor.Row = row
-						e.cursor.Col = col
-						return
+						return Cursor{Row: row, Col: col}, true
 					}
 				}
 				col++
@@ -1627,9 +2238,7 @@ func (e *Editor) goToMatchingBracket() {
 				} else if line[col] == match {
 					depth--
 					if depth == 0 {
-						e.cursor.Row = row
-						e.cursor.Col = col
-						return
+						return Cursor{Row: row, Col: col}, true
 					}
 				}
 				col--
@@ -1640,12 +2249,12 @@ func (e *Editor) goToMatchingBracket() {
 			}
 		}
 	}
-	e.setStatus("no matching bracket found")
+	return Cursor{}, false
 }
 
-// goToMatchingQuote jumps to the matching quote character
+// matchingQuote returns the position of the matching quote character
 // For quotes, we determine if it's opening or closing by counting quotes before cursor
-func (e *Editor) goToMatchingQuote(quoteChar rune) {
+func (e *Editor) matchingQuote(quoteChar rune) (Cursor, bool) {
 	row, col := e.cursor.Row, e.cursor.Col
 
 	// Count quotes of this type before cursor position to determine if opening/closing
@@ -1671,15 +2280,14 @@ func (e *Editor) goToMatchingQuote(quoteChar rune) {
 
 	if count%2 == 0 {
 		// Opening quote - search forward for closing
-		e.findMatchingQuoteForward(quoteChar)
-	} else {
-		// Closing quote - search backward for opening
-		e.findMatchingQuoteBackward(quoteChar)
+		return e.findMatchingQuoteForward(quoteChar)
 	}
+	// Closing quote - search backward for opening
+	return e.findMatchingQuoteBackward(quoteChar)
 }
 
 // findMatchingQuoteForward finds the closing quote
-func (e *Editor) findMatchingQuoteForward(quoteChar rune) {
+func (e *Editor) findMatchingQuoteForward(quoteChar rune) (Cursor, bool) {
 	row, col := e.cursor.Row, e.cursor.Col+1
 
 	for row < len(e.lines) {
@@ -1697,9 +2305,7 @@ func (e *Editor) findMatchingQuoteForward(quoteChar rune) {
 					escaped = bs%2 == 1
 				}
 				if !escaped {
-					e.cursor.Row = row
-					e.cursor.Col = col
-					return
+					return Cursor{Row: row, Col: col}, true
 				}
 			}
 			col++
@@ -1708,10 +2314,11 @@ func (e *Editor) findMatchingQuoteForward(quoteChar rune) {
 		col = 0
 	}
 	e.setStatus("no matching quote found")
+	return Cursor{}, false
 }
 
 // findMatchingQuoteBackward finds the opening quote
-func (e *Editor) findMatchingQuoteBackward(quoteChar rune) {
+func (e *Editor) findMatchingQuoteBackward(quoteChar rune) (Cursor, bool) {
 	row, col := e.cursor.Row, e.cursor.Col-1
 
 	for row >= 0 {
@@ -1736,9 +2343,7 @@ func (e *Editor) findMatchingQuoteBackward(quoteChar rune) {
 					escaped = bs%2 == 1
 				}
 				if !escaped {
-					e.cursor.Row = row
-					e.cursor.Col = col
-					return
+					return Cursor{Row: row, Col: col}, true
 				}
 			}
 			col--
@@ -1749,6 +2354,7 @@ func (e *Editor) findMatchingQuoteBackward(quoteChar rune) {
 		}
 	}
 	e.setStatus("no matching quote found")
+	return Cursor{}, false
 }
 
 // centerCursorLine scrolls to center cursor line on screen
@@ -1776,24 +2382,7 @@ func (e *Editor) scrollCursorToBottom() {
 
 // toggleLineComment toggles comment on current line or selection
 func (e *Editor) toggleLineComment() {
-	// Detect comment prefix based on file extension
-	ext := filepath.Ext(e.filename)
-	var prefix, suffix string
-	switch ext {
-	case ".go", ".c", ".cpp", ".h", ".java", ".js", ".ts", ".rs", ".swift":
-		prefix = "//"
-	case ".py", ".sh", ".bash", ".zsh", ".yaml", ".yml", ".toml", ".rb":
-		prefix = "#"
-	case ".lua", ".sql":
-		prefix = "--"
-	case ".vim":
-		prefix = "\""
-	case ".html", ".xml":
-		prefix = "<!--"
-		suffix = " -->"
-	default:
-		prefix = "//"
-	}
+	prefix, suffix := lineCommentTokens(e.filename)
 
 	start, end := e.cursor.Row, e.cursor.Row
 	if s, en, ok := e.selectionRange(); ok {
@@ -1859,116 +2448,117 @@ func (e *Editor) toggleLineComment() {
 		}
 	}
 
-	e.startUndoGroup()
-
+	// Each line is rewritten as an edit, so the change is undone in one
+	// step and reaches the change listeners
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	prefixRunes, suffixRunes := []rune(prefix), []rune(suffix)
 	for row := start; row <= end; row++ {
 		line := e.lines[row]
-		lineStr := string(line)
 
 		// Skip empty lines
-		if len(lineStr) == 0 {
+		if len(line) == 0 {
 			continue
 		}
 
 		if allCommented {
 			// Remove comment - find the prefix only after minIndent position
-			searchStart := minIndent
-			if searchStart > len(lineStr) {
-				searchStart = len(lineStr)
+			idx := min(minIndent, len(line))
+			for idx < len(line) && (line[idx] == ' ' || line[idx] == '\t') {
+				idx++
 			}
-			rest := lineStr[searchStart:]
-			// Find prefix in the rest of the line (should be at start after whitespace)
-			trimmedRest := strings.TrimLeft(rest, " \t")
-			prefixOffset := len(rest) - len(trimmedRest)
-			if strings.HasPrefix(trimmedRest, prefix) {
-				idx := searchStart + prefixOffset
-				// Remove prefix and one space if present
-				removeLen := len(prefix)
-				if idx+removeLen < len(lineStr) && lineStr[idx+removeLen] == ' ' {
-					removeLen++
-				}
-				newLine := lineStr[:idx] + lineStr[idx+removeLen:]
-				// Also remove suffix if present (for HTML/XML)
-				if suffix != "" && strings.HasSuffix(newLine, suffix) {
-					newLine = newLine[:len(newLine)-len(suffix)]
-				}
-				e.lines[row] = []rune(newLine)
+			if !runesHavePrefix(line[idx:], prefixRunes) {
+				continue
+			}
+			// Remove prefix and one space if present
+			removeLen := len(prefixRunes)
+			if idx+removeLen < len(line) && line[idx+removeLen] == ' ' {
+				removeLen++
+			}
+			_, _ = e.DeleteRange(Cursor{Row: row, Col: idx}, Cursor{Row: row, Col: idx + removeLen})
+			// Also remove suffix if present (for HTML/XML)
+			if line = e.lines[row]; suffix != "" && strings.HasSuffix(string(line), suffix) {
+				_, _ = e.DeleteRange(Cursor{Row: row, Col: len(line) - len(suffixRunes)}, Cursor{Row: row, Col: len(line)})
 			}
 		} else {
 			// Add comment at minIndent position
-			insertAt := minIndent
-			if insertAt > len(lineStr) {
-				insertAt = len(lineStr)
+			if suffix != "" {
+				_, _ = e.InsertAt(Cursor{Row: row, Col: len(line)}, suffix)
 			}
-			newLine := lineStr[:insertAt] + prefix + " " + lineStr[insertAt:] + suffix
-			e.lines[row] = []rune(newLine)
+			_, _ = e.InsertAt(Cursor{Row: row, Col: min(minIndent, len(line))}, prefix+" ")
 		}
 	}
-
-	e.finishUndoGroup()
-	e.dirty = true
-	e.lastEdit.Valid = false
 }
 
-// handleSpaceMenu handles key input when space menu is active
-func (e *Editor) handleSpaceMenu(ev *tcell.EventKey) bool {
-	if ev.Key() == tcell.KeyEscape {
-		e.spaceMenuActive = false
-		e.pendingKeys = ""
+// runesHavePrefix reports whether s starts with prefix
+func runesHavePrefix(s, prefix []rune) bool {
+	if len(s) < len(prefix) {
 		return false
 	}
-
-	if ev.Key() == tcell.KeyRune {
-		ch := ev.Rune()
-		for _, item := range SpaceMenuItems {
-			if item.Key == ch {
-				e.spaceMenuActive = false
-				e.pendingKeys = ""
-				e.lastCommand = "SPC " + string(ch)
-				return e.executeSpaceAction(item)
-			}
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
 		}
 	}
+	return true
+}
+
+// lineCommentTokens returns the line comment prefix (and suffix, for
+// languages without line comments) for a file, by its extension
+func lineCommentTokens(filename string) (prefix, suffix string) {
+	switch filepath.Ext(filename) {
+	case ".go", ".c", ".cpp", ".h", ".java", ".js", ".ts", ".rs", ".swift":
+		prefix = "//"
+	case ".py", ".sh", ".bash", ".zsh", ".yaml", ".yml", ".toml", ".rb":
+		prefix = "#"
+	case ".lua", ".sql":
+		prefix = "--"
+	case ".vim":
+		prefix = "\""
+	case ".html", ".xml":
+		prefix = "<!--"
+		suffix = " -->"
+	default:
+		prefix = "//"
+	}
+	return prefix, suffix
+}
 
-	// Unknown key - close menu
-	e.spaceMenuActive = false
-	e.pendingKeys = ""
+// handleSpaceKey runs the space menu item for ch; other keys close the menu
+func (e *Editor) handleSpaceKey(ch rune) bool {
+	for _, item := range SpaceMenuItems {
+		if item.Key == ch {
+			e.lastCommand = "SPC " + string(ch)
+			return e.executeSpaceAction(item)
+		}
+	}
 	return false
 }
 
 // executeSpaceAction executes the action from space menu
 func (e *Editor) executeSpaceAction(item SpaceMenuItem) bool {
-	if !item.Implemented {
+	if !item.Implemented || lookupAction(item.Action) == nil {
 		e.setStatus(item.Label + " (not implemented)")
 		return false
 	}
+	return e.execAction(item.Action)
+}
 
-	switch item.Action {
-	case "yank_clipboard":
-		e.yankToSystemClipboard()
-	case "yank_main_clipboard":
-		e.yankToSystemClipboard()
-	case "paste_clipboard":
-		e.pasteFromSystemClipboard(false)
-	case "paste_clipboard_before":
-		e.pasteFromSystemClipboard(true)
-	case "window_mode":
-		e.windowMode = true
-		e.pendingKeys = "SPC w"
-		return false
-	case "toggle_comment":
-		e.toggleLineComment()
-	case "show_keybindings":
-		e.keybindingsHelpActive = true
-		e.keybindingsHelpScroll = 0
-		e.keybindingsHelpFilterKey = nil
-		e.keybindingsHelpFilterAct = nil
-		e.keybindingsHelpFilterDesc = nil
-		e.keybindingsHelpFilterFocus = 0
-	default:
-		e.setStatus(item.Label + " (not implemented)")
+// openSidebarFilesAtBuffer opens the file explorer at the current file's directory
+func (e *Editor) openSidebarFilesAtBuffer() {
+	dir := ""
+	if e.filename != "" {
+		if abs, err := filepath.Abs(e.filename); err == nil {
+			dir = filepath.Dir(abs)
+		}
 	}
-	return false
+	e.openSidebarFiles(dir)
+}
+
+// openKeybindingsHelp opens the keybindings help popup with empty filters
+func (e *Editor) openKeybindingsHelp() {
+	keybindingsHelpPopup{}.dismissed(e)
+	e.openPopup(keybindingsHelpPopup{})
 }
 
 // yankToSystemClipboard copies selection to system clipboard
@@ -1990,10 +2580,7 @@ func (e *Editor) yankToSystemClipboard() {
 		sb.WriteString(string(line))
 	}
 
-	// Try to copy to system clipboard using pbcopy on macOS
-	cmd := exec.Command("pbcopy")
-	cmd.Stdin = strings.NewReader(sb.String())
-	if err := cmd.Run(); err != nil {
+	if err := clipboard.Write(sb.String()); err != nil {
 		e.setStatus("yanked (clipboard unavailable)")
 		return
 	}
@@ -2002,15 +2589,12 @@ func (e *Editor) yankToSystemClipboard() {
 
 // pasteFromSystemClipboard pastes from system clipboard
 func (e *Editor) pasteFromSystemClipboard(before bool) {
-	// Try to get from system clipboard using pbpaste on macOS
-	cmd := exec.Command("pbpaste")
-	output, err := cmd.Output()
+	text, err := clipboard.Read()
 	if err != nil {
 		e.setStatus("clipboard unavailable")
 		return
 	}
 
-	text := string(output)
 	if text == "" {
 		e.setStatus("clipboard empty")
 		return
@@ -2034,27 +2618,15 @@ func (e *Editor) pasteFromSystemClipboard(before bool) {
 
 // isMotionAction returns true if the action is a motion that should extend selection
 func isMotionAction(action string) bool {
-	switch action {
-	case actionMoveLeft, actionMoveRight, actionMoveUp, actionMoveDown,
-		actionWordLeft, actionWordRight, actionLineStart, actionLineEnd,
-		actionFileStart, actionFileEnd, actionPageUp, actionPageDown,
-		actionWordForward, actionWordBackward, actionWordEnd,
-		actionGotoLine, actionGotoFirstLine, actionGotoFileEnd,
-		actionFindChar, actionFindCharBackward, actionTillChar, actionTillCharBackward:
-		return true
-	}
-	return false
+	a := lookupAction(action)
+	return a != nil && a.class == classMotion
 }
 
 // isHelixSelectingMotion returns true if motion should auto-start selection (Helix style)
 // These motions extend selection from current position to target
 func isHelixSelectingMotion(action string) bool {
-	switch action {
-	case actionWordForward, actionWordBackward, actionWordEnd,
-		actionFindChar, actionFindCharBackward, actionTillChar, actionTillCharBackward:
-		return true
-	}
-	return false
+	a := lookupAction(action)
+	return a != nil && a.selects
 }
 
 func (e *Editor) handleInsert(ev *tcell.EventKey) bool {
@@ -2064,18 +2636,32 @@ func (e *Editor) handleInsert(ev *tcell.EventKey) bool {
 	key := keyStringForMap(ev, e.keymap.insert)
 	if key != "" {
 		if action, ok := e.keymap.insert[key]; ok {
+			if action == actionNewline {
+				e.expandAbbreviation(0)
+			}
 			return e.execAction(action)
 		}
 	}
 	if ev.Key() == tcell.KeyRune {
-		e.clearSelection()
-		e.insertRune(ev.Rune())
+		e.eachSelection(func() {
+			e.clearSelection()
+			if !e.expandAbbreviation(ev.Rune()) {
+				e.insertRune(ev.Rune())
+			}
+		})
 	}
 	return false
 }
 
 func (e *Editor) handleCommand(ev *tcell.EventKey) bool {
+	if e.cmdHistorySearch {
+		return e.handleHistorySearch(ev)
+	}
 	switch ev.Key() {
+	case tcell.KeyCtrlR: // Ctrl+R = reverse history search (readline)
+		e.closeAutoComplete()
+		e.startHistorySearch()
+		return false
 	case tcell.KeyEscape:
 		e.closeAutoComplete()
 		e.mode = ModeNormal
@@ -2123,10 +2709,8 @@ func (e *Editor) handleCommand(ev *tcell.EventKey) bool {
 		e.closeAutoComplete()
 		cmd := strings.TrimSpace(string(e.cmd))
 		e.mode = ModeNormal
-		// Add to history if not empty and different from last
-		if cmd != "" && (len(e.cmdHistory) == 0 || e.cmdHistory[len(e.cmdHistory)-1] != cmd) {
-			e.cmdHistory = append(e.cmdHistory, cmd)
-			e.saveCmdHistory()
+		if cmd != "" {
+			e.addCmdHistory(cmd)
 		}
 		e.cmd = e.cmd[:0]
 		e.cmdCursor = 0
@@ -2143,6 +2727,14 @@ func (e *Editor) handleCommand(ev *tcell.EventKey) bool {
 		return false
 	case tcell.KeyDelete:
 		e.closeAutoComplete()
+		if ev.Modifiers()&tcell.ModShift != 0 && e.cmdHistoryIndex >= 0 {
+			// Shift+Del forgets the history entry being shown
+			e.deleteCmdHistory(string(e.cmd))
+			e.cmd = []rune(e.cmdHistoryPrefix)
+			e.cmdCursor = len(e.cmd)
+			e.cmdHistoryIndex = -1
+			return false
+		}
 		if e.cmdCursor < len(e.cmd) {
 			// Delete char at cursor
 			e.cmd = append(e.cmd[:e.cmdCursor], e.cmd[e.cmdCursor+1:]...)
@@ -2280,52 +2872,21 @@ func historyFilePath() (string, error) {
 	return filepath.Join(dir, "history"), nil
 }
 
-// LoadCmdHistory loads command history from file
-func (e *Editor) LoadCmdHistory() {
-	path, err := historyFilePath()
-	if err != nil {
-		return
-	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return // File doesn't exist yet, that's ok
-	}
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		if line != "" {
-			e.cmdHistory = append(e.cmdHistory, line)
-		}
-	}
-}
-
-// saveCmdHistory saves command history to file
-func (e *Editor) saveCmdHistory() {
-	path, err := historyFilePath()
-	if err != nil {
-		return
-	}
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return
-	}
-	// Keep only last 1000 commands
-	history := e.cmdHistory
-	if len(history) > 1000 {
-		history = history[len(history)-1000:]
-	}
-	data := strings.Join(history, "\n")
-	_ = os.WriteFile(path, []byte(data), 0644)
-}
-
 // filterCommands returns commands matching the given prefix
 func filterCommands(prefix string) []CommandInfo {
+	commands := AvailableCommands
+	if strings.HasPrefix(strings.TrimLeft(prefix, " "), "action ") {
+		commands = actionCommands()
+	}
+	if name, arg, ok := strings.Cut(strings.TrimLeft(prefix, " "), " "); ok && pathCommands[name] {
+		return completePath(name, strings.TrimLeft(arg, " "))
+	}
 	prefix = strings.TrimSpace(prefix)
 	if prefix == "" {
-		return AvailableCommands
+		return commands
 	}
 	var result []CommandInfo
-	for _, cmd := range AvailableCommands {
+	for _, cmd := range commands {
 		if strings.HasPrefix(cmd.Name, prefix) {
 			result = append(result, cmd)
 		}
@@ -2502,6 +3063,9 @@ func searchHistoryFilePath() (string, error) {
 
 // LoadSearchHistory loads search history from file
 func (e *Editor) LoadSearchHistory() {
+	if e.noState {
+		return
+	}
 	path, err := searchHistoryFilePath()
 	if err != nil {
 		return
@@ -2519,6 +3083,9 @@ func (e *Editor) LoadSearchHistory() {
 
 // saveSearchHistory saves search history to file
 func (e *Editor) saveSearchHistory() {
+	if e.noState {
+		return
+	}
 	path, err := searchHistoryFilePath()
 	if err != nil {
 		return
@@ -2661,6 +3228,7 @@ func (e *Editor) handleSearch(ev *tcell.EventKey) bool {
 		e.searchQuery = e.searchQuery[:0]
 		e.searchCursor = 0
 		e.searchMatches = nil
+		e.searchScan = nil
 		e.searchHistoryIndex = -1
 		return false
 	case tcell.KeyCtrlC:
@@ -2668,6 +3236,7 @@ func (e *Editor) handleSearch(ev *tcell.EventKey) bool {
 		e.searchQuery = e.searchQuery[:0]
 		e.searchCursor = 0
 		e.searchMatches = nil
+		e.searchScan = nil
 		e.searchHistoryIndex = -1
 		return false
 	case tcell.KeyEnter:
@@ -2677,6 +3246,7 @@ func (e *Editor) handleSearch(ev *tcell.EventKey) bool {
 			e.addSearchToHistory(query)
 			e.lastSearchQuery = query
 		}
+		e.finishSearch()
 		if len(e.searchMatches) > 0 {
 			match := e.searchMatches[e.searchMatchIndex]
 			e.cursor.Row = match.Row
@@ -2752,15 +3322,19 @@ func (e *Editor) handleSearch(ev *tcell.EventKey) bool {
 	return false
 }
 
-// updateSearchMatches performs fuzzy search and updates matches
+// updateSearchMatches searches the buffer for the query again. It scans
+// for at most searchBudget; ContinueSearch scans the rest between
+// keystrokes and the next keystroke starts over.
 func (e *Editor) updateSearchMatches() {
 	e.searchMatches = nil
 	e.searchMatchIndex = 0
+	e.searchScan = nil
 
 	query := string(e.searchQuery)
 	if query == "" {
 		return
 	}
+	scan := &searchScan{origin: e.cursor.Row}
 
 	// Regex search mode
 	if e.searchRegex {
@@ -2770,92 +3344,87 @@ func (e *Editor) updateSearchMatches() {
 			e.setStatus("regex error: " + err.Error())
 			return
 		}
-		for row, line := range e.lines {
-			lineStr := string(line)
-			matches := re.FindAllStringIndex(lineStr, -1)
-			for _, m := range matches {
-				// Convert byte positions to rune positions
-				col := utf8.RuneCountInString(lineStr[:m[0]])
-				length := utf8.RuneCountInString(lineStr[m[0]:m[1]])
-				e.searchMatches = append(e.searchMatches, SearchMatch{
-					Row:    row,
-					Col:    col,
-					Length: length,
-					Score:  1000,
-				})
-			}
+		scan.re = re
+	}
+	e.searchScan = scan
+	e.scanSearch(time.Now().Add(searchBudget))
+}
+
+// searchLine adds the matches of the query in line row
+func (e *Editor) searchLine(scan *searchScan, row int) {
+	line := e.lines[row]
+	query := string(e.searchQuery)
+	from := len(e.searchMatches)
+
+	if scan.re != nil {
+		lineStr := string(line)
+		matches := scan.re.FindAllStringIndex(lineStr, -1)
+		for _, m := range matches {
+			// Convert byte positions to rune positions
+			col := utf8.RuneCountInString(lineStr[:m[0]])
+			length := utf8.RuneCountInString(lineStr[m[0]:m[1]])
+			e.searchMatches = append(e.searchMatches, SearchMatch{
+				Row:    row,
+				Col:    col,
+				Length: length,
+				Score:  1000,
+			})
 		}
-	} else {
-		queryLower := strings.ToLower(query)
-
-		// Search through all lines
-		for row, line := range e.lines {
-			lineStr := string(line)
-			lineLower := strings.ToLower(lineStr)
-
-			// Find all exact substring matches in this line first
-			offset := 0
-			for {
-				col := strings.Index(lineLower[offset:], queryLower)
-				if col < 0 {
-					break
-				}
-				e.searchMatches = append(e.searchMatches, SearchMatch{
-					Row:    row,
-					Col:    offset + col,
-					Length: len(query),
-					Score:  1000, // Exact match gets high score
-				})
-				offset += col + 1
-				if offset >= len(lineLower) {
-					break
-				}
-			}
+		return
+	}
 
-			// In fuzzy mode, find words containing all query letters
-			if e.searchFuzzy {
-				words := extractWords(line)
-				for _, w := range words {
-					// Skip if this word position is already covered by an exact match
-					alreadyMatched := false
-					for _, m := range e.searchMatches {
-						if m.Row == row && w.start >= m.Col && w.start < m.Col+m.Length {
-							alreadyMatched = true
-							break
-						}
-					}
-					if alreadyMatched {
-						continue
-					}
+	queryLower := strings.ToLower(query)
+	lineStr := string(line)
+	lineLower := strings.ToLower(lineStr)
 
-					// Check fuzzy match (sequential or chunk-based)
-					if matchedPositions := fuzzyMatchWord(w.word, query); matchedPositions != nil {
-						e.searchMatches = append(e.searchMatches, SearchMatch{
-							Row:         row,
-							Col:         w.start,
-							Length:      len([]rune(w.word)),
-							Score:       500, // Fuzzy match score
-							MatchedCols: matchedPositions,
-						})
-					}
-				}
-			}
+	// Find all exact substring matches in this line first
+	offset := 0
+	for {
+		col := strings.Index(lineLower[offset:], queryLower)
+		if col < 0 {
+			break
+		}
+		e.searchMatches = append(e.searchMatches, SearchMatch{
+			Row:    row,
+			Col:    offset + col,
+			Length: len(query),
+			Score:  1000, // Exact match gets high score
+		})
+		offset += col + 1
+		if offset >= len(lineLower) {
+			break
 		}
 	}
 
-	// Sort by row, then by column
-	sortSearchMatches(e.searchMatches)
+	// In fuzzy mode, find words containing all query letters
+	if e.searchFuzzy {
+		words := extractWords(line)
+		for _, w := range words {
+			// Skip if this word position is already covered by an exact match
+			alreadyMatched := false
+			for _, m := range e.searchMatches[from:] {
+				if w.start >= m.Col && w.start < m.Col+m.Length {
+					alreadyMatched = true
+					break
+				}
+			}
+			if alreadyMatched {
+				continue
+			}
 
-	// Find match closest to cursor
-	if len(e.searchMatches) > 0 {
-		e.searchMatchIndex = 0
-		for i, match := range e.searchMatches {
-			if match.Row >= e.cursor.Row {
-				e.searchMatchIndex = i
-				break
+			// Check fuzzy match (sequential or chunk-based)
+			if matchedPositions := fuzzyMatchWord(w.word, query); matchedPositions != nil {
+				e.searchMatches = append(e.searchMatches, SearchMatch{
+					Row:         row,
+					Col:         w.start,
+					Length:      len([]rune(w.word)),
+					Score:       500, // Fuzzy match score
+					MatchedCols: matchedPositions,
+				})
 			}
 		}
-		e.jumpToCurrentMatch()
+		// Sort by column; rows come in order
+		sortSearchMatches(e.searchMatches[from:])
 	}
 }
 
@@ -3040,17 +3609,11 @@ func (e *Editor) enterSearchMode(forward bool, fuzzy bool, regex bool) {
 	e.searchQuery = e.searchQuery[:0]
 	e.searchCursor = 0
 	e.searchMatches = nil
+	e.searchScan = nil
 	e.searchMatchIndex = 0
 	e.searchForward = forward
 	e.searchFuzzy = fuzzy
 	e.searchRegex = regex
-	if regex {
-		e.pendingKeys = "E"
-	} else if fuzzy {
-		e.pendingKeys = "F"
-	} else {
-		e.pendingKeys = "/"
-	}
 }
 
 // searchNext goes to next match
@@ -3065,6 +3628,7 @@ func (e *Editor) searchNext() {
 		e.searchQuery = []rune(e.lastSearchQuery)
 		e.updateSearchMatches()
 	}
+	e.finishSearch()
 
 	if len(e.searchMatches) == 0 {
 		e.setStatus("no matches")
@@ -3100,6 +3664,7 @@ func (e *Editor) searchPrev() {
 		e.searchQuery = []rune(e.lastSearchQuery)
 		e.updateSearchMatches()
 	}
+	e.finishSearch()
 
 	if len(e.searchMatches) == 0 {
 		e.setStatus("no matches")
@@ -3178,8 +3743,11 @@ func (e *Editor) handleSelectionMove(ev *tcell.EventKey) bool {
 }
 
 func (e *Editor) handleBranchPicker(ev *tcell.EventKey) bool {
+	if e.resizePickerKey(pickerBranches, ev, e.branchPickerBoxWidth(e.viewWidth)) {
+		return false
+	}
 	switch keyString(ev) {
-	case "esc", "ctrl+c":
+	case "ctrl+c":
 		e.closeBranchPicker("")
 		return false
 	case "enter":
@@ -3218,8 +3786,11 @@ func (e *Editor) handleBranchPicker(ev *tcell.EventKey) bool {
 }
 
 func (e *Editor) handleRefsPicker(ev *tcell.EventKey) bool {
+	if e.refsSplitShown() && e.resizePickerKey(pickerReferences, ev, e.refsPickerWidth(e.viewWidth)) {
+		return true
+	}
 	switch keyString(ev) {
-	case "esc", "ctrl+c", "q":
+	case "ctrl+c", "q":
 		e.closeRefsPicker(false)
 		return true
 	case "enter":
@@ -3264,7 +3835,7 @@ func (e *Editor) jumpToSelectedRef() {
 	currentAbs, _ := filepath.Abs(e.filename)
 	if loc.Path == currentAbs || loc.Path == e.filename {
 		e.cursor.Row = loc.StartLine
-		e.cursor.Col = loc.StartCol
+		e.cursor.Col = textpos.UTF16ToRune(e.lineAt(loc.StartLine), loc.StartCol)
 		e.ensureCursorVisible(e.viewHeightCached())
 	}
 }
@@ -3317,11 +3888,30 @@ func (e *Editor) handleSidebarKey(ev *tcell.EventKey) bool {
 		}
 		return false
 
+	case SidebarActionGoto:
+		logger.Debug("sidebar action: goto", "line", action.Line, "col", action.Col)
+		e.gotoProblem(action.Line, action.Col)
+		if e.sidebar.CloseOnSelect {
+			e.closeSidebar()
+		} else {
+			e.sidebar.Focused = false
+		}
+		return false
+
 	case SidebarActionOpenFile:
 		logger.Debug("sidebar action: open file", "path", action.Path)
 		if action.Path != "" {
-			// Signal that we want to open this file (future)
-			e.setStatus("FileTree: not implemented yet")
+			if e.dirty {
+				e.setStatus("unsaved changes (use :w first)")
+				return false
+			}
+			// Signal that we want to open this file - app will call OpenFile
+			e.openFileRequest = action.Path
+			if e.sidebar.CloseOnSelect {
+				e.closeSidebar()
+			} else {
+				e.sidebar.Focused = false
+			}
 		}
 		return false
 	}
@@ -3343,7 +3933,7 @@ func (e *Editor) switchSidebarMode(mode SidebarMode) {
 		// App will call ShowSidebarBranches with the branches
 
 	case SidebarModeFileTree:
-		e.setStatus("FileTree: not implemented yet")
+		e.openSidebarFiles("")
 
 	case SidebarModeRecentHistory:
 		e.setStatus("Recent History: not implemented yet")
@@ -3353,6 +3943,9 @@ func (e *Editor) switchSidebarMode(mode SidebarMode) {
 
 	case SidebarModeWorktrees:
 		e.setStatus("Worktrees: not implemented yet")
+
+	case SidebarModeProblems:
+		e.openSidebarProblems()
 	}
 }
 
@@ -3365,7 +3958,7 @@ func (e *Editor) openSidebar() {
 	}
 
 	// Close refs picker if open (mutual exclusion)
-	if e.refsPickerActive {
+	if e.popupOpen(refsPickerPopup{}) {
 		logger.Debug("openSidebar: closing refs picker")
 		e.closeRefsPicker(false)
 	}
@@ -3391,7 +3984,7 @@ func (e *Editor) openSidebarBranches() {
 	}
 
 	// Close refs picker if open (mutual exclusion)
-	if e.refsPickerActive {
+	if e.popupOpen(refsPickerPopup{}) {
 		logger.Debug("openSidebarBranches: closing refs picker")
 		e.closeRefsPicker(false)
 	}
@@ -3408,34 +4001,97 @@ func (e *Editor) openSidebarBranches() {
 	logger.Debug("openSidebarBranches: branch request set")
 }
 
-// closeSidebar closes the sidebar
-func (e *Editor) closeSidebar() {
-	logger.Debug("closeSidebar called")
+// openSidebarFiles shows the file tree, rooted at the project root or working directory.
+// A non-empty dir selects the directory to list (e.g. the buffer's directory).
+func (e *Editor) openSidebarFiles(dir string) {
 	if e.sidebar == nil {
 		return
 	}
-	e.sidebar.Close()
-}
-
-// toggleSidebar toggles the sidebar visibility
-func (e *Editor) toggleSidebar() {
-	logger.Debug("toggleSidebar called", "visible", e.sidebar != nil && e.sidebar.Visible, "focused", e.sidebar != nil && e.sidebar.Focused)
-	if e.sidebar == nil {
-		logger.Warn("toggleSidebar: sidebar is nil")
-		return
+	if e.popupOpen(refsPickerPopup{}) {
+		e.closeRefsPicker(false)
 	}
-	if e.sidebar.Visible {
-		if e.sidebar.Focused {
-			e.closeSidebar()
-		} else {
-			e.sidebar.Focused = true
+	if e.sidebar.MenuContent == nil {
+		e.sidebar.MenuContent = NewSidebarMenuContent(e.isGitRepo())
+	}
+	if e.sidebarFiles == nil {
+		root := e.projectRoot
+		if root == "" {
+			var err error
+			if root, err = os.Getwd(); err != nil {
+				e.setStatus(err.Error())
+				return
+			}
 		}
-	} else {
-		e.openSidebar()
+		e.sidebarFiles = NewSidebarFilesContent(root, e.fileTreeShowHidden, e.fileTreeShowIgnored, e.ignorePatterns)
 	}
+	if dir != "" {
+		e.sidebarFiles.SetDir(dir)
+	} else if err := e.sidebarFiles.Refresh(); err != nil {
+		e.setStatus(err.Error())
+	}
+	e.sidebar.SetContent(e.sidebarFiles)
+	e.sidebar.Visible = true
+	e.sidebar.Focused = true
 }
 
-// ShowSidebarBranches shows branches in the sidebar
+// OpenProject sets dir as the project root and shows the file tree at it
+func (e *Editor) OpenProject(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	e.projectRoot = abs
+	e.sidebarFiles = nil
+	e.openSidebarFiles("")
+	e.setStatus("project " + abs)
+	return nil
+}
+
+// SetReadOnly prevents writing the buffer back to its own file
+func (e *Editor) SetReadOnly(readOnly bool) {
+	e.readOnly = readOnly
+}
+
+// ProjectRoot returns the directory opened as project, if any
+func (e *Editor) ProjectRoot() string {
+	return e.projectRoot
+}
+
+// closeSidebar closes the sidebar
+func (e *Editor) closeSidebar() {
+	logger.Debug("closeSidebar called")
+	if e.sidebar == nil {
+		return
+	}
+	e.sidebar.Close()
+}
+
+// toggleSidebar toggles the sidebar visibility
+func (e *Editor) toggleSidebar() {
+	logger.Debug("toggleSidebar called", "visible", e.sidebar != nil && e.sidebar.Visible, "focused", e.sidebar != nil && e.sidebar.Focused)
+	if e.sidebar == nil {
+		logger.Warn("toggleSidebar: sidebar is nil")
+		return
+	}
+	if e.sidebar.Visible {
+		if e.sidebar.Focused {
+			e.closeSidebar()
+		} else {
+			e.sidebar.Focused = true
+		}
+	} else {
+		e.openSidebar()
+	}
+}
+
+// ShowSidebarBranches shows branches in the sidebar
 func (e *Editor) ShowSidebarBranches(branches []string, current string) {
 	logger.Debug("ShowSidebarBranches called", "count", len(branches), "current", current)
 	if e.sidebar == nil {
@@ -3459,6 +4115,38 @@ func (e *Editor) IsSidebarBranchRequest() bool {
 	return e.sidebar != nil && e.sidebar.Visible && e.branchPickerRequested
 }
 
+// PollSidebarFiles refreshes the visible file tree when its directory changed
+// outside qedit. Returns true if the tree was refreshed.
+func (e *Editor) PollSidebarFiles(now time.Time) bool {
+	if e.sidebar == nil || !e.sidebar.Visible || e.sidebarFiles == nil || e.sidebar.Content != e.sidebarFiles {
+		return false
+	}
+	return e.sidebarFiles.Poll(now)
+}
+
+// RequestOpen opens path as a buffer through the open-file request, for a
+// file another qedit handed over. Unsaved changes have to be written first.
+func (e *Editor) RequestOpen(path string) {
+	if e.dirty {
+		e.setStatus(filepath.Base(path) + " handed over: unsaved changes (use :w first)")
+		return
+	}
+	if bufferKey(path) == bufferKey(e.filename) {
+		return
+	}
+	e.openFileRequest = path
+}
+
+// ConsumeOpenFileRequest consumes the file to open (sidebar file tree or gf)
+func (e *Editor) ConsumeOpenFileRequest() string {
+	if e.openFileRequest == "" {
+		return ""
+	}
+	path := e.openFileRequest
+	e.openFileRequest = ""
+	return path
+}
+
 // ConsumeSidebarBranchSelection consumes the branch selection from sidebar
 func (e *Editor) ConsumeSidebarBranchSelection() string {
 	if e.branchPickerSelection == "" {
@@ -3469,252 +4157,41 @@ func (e *Editor) ConsumeSidebarBranchSelection() string {
 	return selection
 }
 
+// execAction runs the registered action with the given name
 func (e *Editor) execAction(action string) bool {
 	if e.actionHook != nil {
 		e.actionHook(action)
 	}
-	switch action {
-	case actionMoveLeft:
-		e.moveLeft()
-	case actionMoveRight:
-		e.moveRight()
-	case actionMoveUp:
-		e.moveUp()
-	case actionMoveDown:
-		e.moveDown()
-	case actionWordLeft:
-		e.moveWordLeft()
-	case actionWordRight:
-		e.moveWordRight()
-	case actionLineStart:
-		e.moveLineStart()
-	case actionLineEnd:
-		e.moveLineEnd()
-	case actionFileStart:
-		e.moveFileStart()
-	case actionFileEnd:
-		e.moveFileEnd()
-	case actionPageUp:
-		e.pageUp()
-	case actionPageDown:
-		e.pageDown()
-	case actionMoveLineUp:
-		e.moveLineUp()
-	case actionMoveLineDown:
-		e.moveLineDown()
-	case actionToggleLineNumbers:
-		e.toggleLineNumbers()
-	case actionBranchPicker:
-		e.openSidebarBranches()
-	case actionToggleSidebar:
-		e.toggleSidebar()
-	case actionEnterInsert:
-		e.mode = ModeInsert
-		e.saveLineState()
-	case actionEnterNormal:
-		e.mode = ModeNormal
-	case actionEnterCommand:
-		e.mode = ModeCommand
-		e.cmd = e.cmd[:0]
-		e.cmdCursor = 0
-		e.cmdHistoryIndex = -1
-	case actionQuit:
-		return true
-	case actionBackspace:
-		e.backspace()
-	case actionNewline:
-		e.insertNewline()
-	case actionInsertTab:
-		e.insertTab()
-	case actionUndo:
-		e.Undo()
-		return false // Don't clear selection - undo may restore it
-	case actionRedo:
-		e.Redo()
-		return false // Don't clear selection
-	case actionDeleteLine:
-		e.deleteLine()
-	case actionDeleteChar:
-		e.deleteChar()
-	case actionDeleteWordLeft:
-		e.deleteWordLeft()
-	case actionDeleteWordRight:
-		e.deleteWordRight()
-	case actionInsertLineBelow:
-		e.insertLineBelow()
-	case actionUndoLine:
-		e.undoLine()
-	case actionScrollUp:
-		e.scrollViewUp()
-	case actionScrollDown:
-		e.scrollViewDown()
-	case actionIndent:
-		e.indentSelection()
-		return false // Don't clear selection
-	case actionUnindent:
-		e.unindentSelection()
-		return false // Don't clear selection
-	case actionSelectAll:
-		e.selectAll()
-		return false // Don't clear selection
-
-	// Helix-style motions
-	case actionWordForward:
-		e.wordForward()
-	case actionWordBackward:
-		e.wordBackward()
-	case actionWordEnd:
-		e.wordEnd()
-	case actionGotoMode:
-		e.gotoMode = true
-		e.pendingKeys = "g"
-		return false // Don't clear selection, wait for next key
-	case actionGotoLine:
-		e.gotoLastLine()
-	case actionGotoLinePrompt:
-		e.mode = ModeCommand
-		e.cmd = []rune{}
-		e.cmdCursor = 0
-		e.setStatus("goto line:")
-	case actionGotoFirstLine:
-		e.gotoFirstLine()
-	case actionGotoFileEnd:
-		e.gotoFileEnd()
-	case actionFindChar:
-		e.setPendingFindChar(action)
-		e.pendingKeys = "f"
-		return false // Don't clear selection, wait for char input
-	case actionFindCharBackward:
-		e.setPendingFindChar(action)
-		e.pendingKeys = "F"
-		return false
-	case actionTillChar:
-		e.setPendingFindChar(action)
-		e.pendingKeys = "t"
-		return false
-	case actionTillCharBackward:
-		e.setPendingFindChar(action)
-		e.pendingKeys = "T"
-		return false
-
-	// Helix-style editing
-	case actionDelete:
-		e.helixDelete()
-	case actionChange:
-		e.helixChange()
-		return false // Don't clear selection (entering insert mode)
-	case actionYank:
-		e.yankSelection()
-		return false // Don't clear selection yet (yank preserves for visual feedback)
-	case actionPaste:
-		e.pasteAfter()
-	case actionPasteBefore:
-		e.pasteBefore()
-	case actionOpenBelow:
-		e.openBelow()
-		return false // Entering insert mode
-	case actionOpenAbove:
-		e.openAbove()
-		return false // Entering insert mode
-	case actionAppend:
-		e.appendMode()
-		return false // Entering insert mode
-	case actionAppendLineEnd:
-		e.appendLineEnd()
-		return false // Entering insert mode
-	case actionInsertLineStart:
-		e.insertLineStart()
-		return false // Entering insert mode
-	case actionReplaceChar:
-		e.setPendingFindChar(action)
-		e.pendingKeys = "r"
-		return false // Wait for char input
-	case actionJoinLines:
-		e.joinLinesCmd()
-
-	// Helix-style selection
-	case actionToggleSelect:
-		e.toggleSelectMode()
-		return false // Don't clear selection
-	case actionExtendLine:
-		e.extendLine()
-		return false // Don't clear selection
-	case actionCollapseSelection:
-		e.collapseSelection()
-	case actionFlipSelection:
-		e.flipSelection()
-		return false // Don't clear selection
-
-	// Space mode
-	case actionSpaceMode:
-		e.spaceMenuActive = true
-		e.pendingKeys = "SPC"
-		return false
-
-	// Match mode
-	case actionMatchMode:
-		e.matchMode = true
-		e.pendingKeys = "m"
-		return false
-
-	// View mode
-	case actionViewMode:
-		e.viewMode = true
-		e.pendingKeys = "z"
-		return false
-
-	// Search
-	case actionSearchForward:
-		e.enterSearchMode(true, false, false) // exact search
-		return false
-	case actionSearchBackward:
-		e.enterSearchMode(false, false, false) // exact search
-		return false
-	case actionSearchFuzzy:
-		e.enterSearchMode(true, true, false) // fuzzy search
-		return false
-	case actionSearchRegex:
-		e.enterSearchMode(true, false, true) // regex search
-		return false
-	case actionSearchNext:
-		e.searchNext()
-	case actionSearchPrev:
-		e.searchPrev()
-
-	// Special
-	case actionInsertLineAbove:
-		e.insertLineAboveCursor()
-
-	// Terminal zoom
-	case actionTerminalZoomIn:
-		if e.zoomPendingRestore {
-			return false // already zoomed, ignore
-		}
-		// Save current scroll positions for restore
-		e.zoomSavedScroll = e.scroll
-		e.zoomSavedScrollX = e.scrollX
-		e.zoomWithAnimation(true, 20) // zoom in with scroll animation
-		e.zoomPendingRestore = true
-		return false
-
-	// Selection scope
-	case actionExpandSelection:
-		e.expandSelection()
+	if name, ok := strings.CutPrefix(action, macroActionPrefix); ok {
+		e.runNamedMacro(name)
+		return e.takeMacroQuit()
+	}
+	a := lookupAction(action)
+	if a == nil {
+		e.setStatus("unknown action: " + action)
 		return false
-	case actionShrinkSelection:
-		e.shrinkSelection()
+	}
+	if e.fileReadOnly && a.editsText() {
+		e.setStatus(e.readOnlyHint())
 		return false
-
-	// File operations
-	case actionSave:
-		if err := e.Save(""); err != nil {
-			e.setStatus(err.Error())
-		} else {
-			e.setStatus("saved " + e.filename)
+	}
+	if a.everySelection {
+		e.eachSelection(func() {
+			a.run(e)
+			if !a.keepSelection && !e.selectMode {
+				e.clearSelection()
+			}
+		})
+	} else {
+		a.run(e)
+		if !a.keepSelections {
+			e.selections = nil
 		}
-		return false
 	}
-	if !e.selectMode {
+	if a.quit || e.takeMacroQuit() {
+		return true
+	}
+	if !a.keepSelection && !e.selectMode {
 		e.clearSelection()
 	}
 	return false
@@ -3724,7 +4201,17 @@ func (e *Editor) execCommand(cmd string) bool {
 	if cmd == "" {
 		return false
 	}
-	fields := strings.Fields(cmd)
+	if e.execRawCommand(cmd) {
+		return false
+	}
+	fields, err := splitCommand(cmd)
+	if err != nil {
+		e.setStatus(err.Error())
+		return false
+	}
+	if len(fields) == 0 {
+		return false
+	}
 	name := fields[0]
 	args := fields[1:]
 
@@ -3738,7 +4225,18 @@ func (e *Editor) execCommand(cmd string) bool {
 			e.setStatus(err.Error())
 			return false
 		}
-		e.setStatus("written")
+		e.setStatus(e.problemsStatus("written"))
+		return false
+	case "w!", "wlink":
+		link := symlinkTarget
+		if name == "wlink" {
+			link = symlinkReplace
+		}
+		if err := e.save(strings.Join(args, " "), link); err != nil {
+			e.setStatus(err.Error())
+			return false
+		}
+		e.setStatus(e.problemsStatus("written"))
 		return false
 	case "q":
 		if e.dirty {
@@ -3748,6 +4246,9 @@ func (e *Editor) execCommand(cmd string) bool {
 		return true
 	case "q!":
 		return true
+	case "cq":
+		e.aborted = true
+		return true
 	case "wq", "x":
 		path := ""
 		if len(args) > 0 {
@@ -3758,6 +4259,160 @@ func (e *Editor) execCommand(cmd string) bool {
 			return false
 		}
 		return true
+	case "bd", "bd!":
+		e.closeBuffer(name == "bd!")
+		return false
+	case "bundo":
+		e.reopenBuffer()
+		return false
+	case "resume":
+		e.execResumeCommand()
+		return false
+	case "bn":
+		e.cycleBuffer(1)
+		return false
+	case "bp":
+		e.cycleBuffer(-1)
+		return false
+	case "bpin":
+		e.togglePinBuffer()
+		return false
+	case "bmove":
+		if len(args) != 1 || (args[0] != "left" && args[0] != "right") {
+			e.setStatus("usage: :bmove left|right")
+			return false
+		}
+		if args[0] == "left" {
+			e.moveBuffer(-1)
+		} else {
+			e.moveBuffer(1)
+		}
+		return false
+	case "tabnew":
+		e.newTab(strings.Join(args, " "))
+		return false
+	case "tabclose", "tabc":
+		e.closeTab()
+		return false
+	case "tabn", "tabnext":
+		e.cycleTab(1)
+		return false
+	case "tabp", "tabprevious":
+		e.cycleTab(-1)
+		return false
+	case "bufferline":
+		on := e.bufferline
+		if e.boolOption(args, &on, "bufferline") {
+			e.setBufferline(on)
+		}
+		return false
+	case "subword":
+		on := e.subword
+		if e.boolOption(args, &on, "subword") {
+			e.setSubword(on)
+		}
+		return false
+	case "tabwidth":
+		e.execTabWidthCommand(args)
+		return false
+	case "retab":
+		e.execRetabCommand(args)
+		return false
+	case "theme-edit":
+		e.execThemeEditCommand(args)
+		return false
+	case "readonly":
+		e.execReadOnlyCommand(args)
+		return false
+	case "wsudo":
+		e.execWsudoCommand(args)
+		return false
+	case "recover":
+		e.execRecoverCommand()
+		return false
+	case "bom":
+		e.execBOMCommand(args)
+		return false
+	case "merge":
+		e.execMergeCommand()
+		return false
+	case "export", "export!":
+		e.execExportCommand(args, name == "export!")
+		return false
+	case "grep":
+		e.execGrepCommand(args)
+		return false
+	case "hardcopy", "hardcopy!":
+		e.execHardcopyCommand(args, name == "hardcopy!")
+		return false
+	case "collab":
+		e.execCollabCommand(args)
+		return false
+	case "renumber":
+		e.renumberLists()
+		return false
+	case "todo":
+		switch {
+		case len(args) == 0 || args[0] == "toggle":
+			e.toggleTodo()
+		case args[0] == "up":
+			e.moveTodo(-1)
+		case args[0] == "down":
+			e.moveTodo(1)
+		default:
+			e.setStatus("usage: :todo [toggle|up|down]")
+		}
+		return false
+	case "autocorrect":
+		on := e.autocorrect
+		if e.boolOption(args, &on, "autocorrect") {
+			e.setAutocorrect(on)
+		}
+		return false
+	case "macro":
+		e.execMacroCommand(args)
+		return e.takeMacroQuit()
+	case "pastetable":
+		if len(args) > 1 {
+			e.setStatus("usage: :pastetable [separator]")
+			return false
+		}
+		e.pasteTableFromClipboard(strings.Join(args, ""))
+		return false
+	case "conceal":
+		on := e.conceal
+		if e.boolOption(args, &on, "conceal") {
+			e.setConceal(on)
+		}
+		return false
+	case "findlines":
+		on := e.findLines
+		if e.boolOption(args, &on, "findlines") {
+			e.setFindLines(on)
+		}
+		return false
+	case "char":
+		e.setStatus(e.describeCharUnderCursor())
+		return false
+	case "col":
+		if len(args) == 0 {
+			e.setStatus("column: " + e.columnMode.String())
+			return false
+		}
+		mode, ok := parseColumnMode(args[0])
+		if !ok {
+			e.setStatus("usage: :col visual|char|byte|all")
+			return false
+		}
+		e.columnMode = mode
+		e.setStatus("column: " + mode.String())
+		return false
+	case "ansi":
+		on := e.ansiView
+		if e.boolOption(args, &on, "ansi") {
+			e.setANSIView(on)
+		}
+		return false
 	case "ln":
 		if len(args) == 0 {
 			e.toggleLineNumbers()
@@ -3778,12 +4433,30 @@ func (e *Editor) execCommand(cmd string) bool {
 		}
 		return false
 	case "fmt":
+		if isGoFile(e.filename) {
+			e.formatGoTask()
+			return false
+		}
 		if err := e.FormatCurrent(); err != nil {
 			e.setStatus(err.Error())
 			return false
 		}
 		e.setStatus("formatted")
 		return false
+	case "json":
+		e.execJSONCommand(args)
+		return false
+	case "encode", "decode":
+		e.execCodecCommand(name, args)
+		return false
+	case "uuid", "random":
+		e.execGenerateCommand(name, args)
+		return false
+	case "action":
+		return e.execActionCommand(args)
+	case "tasks":
+		e.execTasksCommand(args)
+		return false
 	case "sidebar":
 		e.toggleSidebar()
 		return false
@@ -3826,28 +4499,50 @@ func (e *Editor) gotoLineNumber(lineNum int) {
 	e.setStatus(fmt.Sprintf("line %d", lineNum))
 }
 
+// Save writes the buffer to path, or to the open file when path is "".
+// Saving through a symlink fails until :w! or :wlink decides how.
 func (e *Editor) Save(path string) error {
-	if path == "" {
-		if e.filename == "" {
-			return errors.New("no file name")
-		}
-		path = e.filename
-	}
-	data := []byte(joinLines(e.lines))
-	if err := os.WriteFile(path, data, 0o644); err != nil {
+	return e.save(path, symlinkAsk)
+}
+
+func (e *Editor) FormatGo() error {
+	src := e.Content()
+	formatted, err := gofmt(context.Background(), src)
+	if err != nil {
 		return err
 	}
-	e.filename = path
-	e.savePoint = len(e.undo)
-	e.updateDirty()
-	_ = e.SaveUndoHistory()
-	e.saveSessionState()
+	if formatted == src {
+		return nil
+	}
+	e.replaceBuffer(formatted, true)
 	return nil
 }
 
-func (e *Editor) FormatGo() error {
-	src := e.Content()
-	cmd := exec.Command("gofmt")
+// formatGoTask runs gofmt as a task (:fmt). The result is dropped if the
+// buffer changed while gofmt ran.
+func (e *Editor) formatGoTask() {
+	src, path, tick := e.Content(), e.filename, e.changeTick
+	e.runBufferTask("gofmt", func(ctx context.Context) func() {
+		formatted, err := gofmt(ctx, src)
+		return func() {
+			switch {
+			case err != nil:
+				e.setStatus(err.Error())
+			case e.filename != path || e.changeTick != tick:
+				e.setStatus("buffer changed while formatting (run :fmt again)")
+			default:
+				if formatted != src {
+					e.replaceBuffer(formatted, true)
+				}
+				e.setStatus("formatted")
+			}
+		}
+	})
+}
+
+// gofmt formats Go source with the gofmt command
+func gofmt(ctx context.Context, src string) (string, error) {
+	cmd := exec.CommandContext(ctx, "gofmt")
 	cmd.Stdin = strings.NewReader(src)
 	var out bytes.Buffer
 	var stderr bytes.Buffer
@@ -3856,16 +4551,11 @@ func (e *Editor) FormatGo() error {
 	if err := cmd.Run(); err != nil {
 		msg := strings.TrimSpace(stderr.String())
 		if msg != "" {
-			return errors.New(msg)
+			return "", errors.New(msg)
 		}
-		return err
-	}
-	formatted := out.String()
-	if formatted == src {
-		return nil
+		return "", err
 	}
-	e.replaceBuffer(formatted, true)
-	return nil
+	return out.String(), nil
 }
 
 func (e *Editor) FormatCurrent() error {
@@ -3875,6 +4565,9 @@ func (e *Editor) FormatCurrent() error {
 	if isGoFile(e.filename) {
 		return e.FormatGo()
 	}
+	if isJSONFile(e.filename) {
+		return e.FormatJSON(false)
+	}
 	return errors.New("format not supported")
 }
 
@@ -4227,11 +4920,21 @@ func runeLen(s string) int {
 	return len([]rune(s))
 }
 
+// replaceBuffer sets the buffer's text. With markDirty it is an edit (a
+// formatter run): only the lines that differ are replaced, as one undo
+// step, so the cursor stays put and history and save point are kept.
+// Without it the text is taken as the file's saved contents and the
+// history starts over.
 func (e *Editor) replaceBuffer(text string, markDirty bool) {
 	lines := splitLines([]byte(text))
 	if len(lines) == 0 {
 		lines = [][]rune{[]rune{}}
 	}
+	if markDirty {
+		e.replaceLines(lines)
+		return
+	}
+	e.queueBufferReplace(joinLines(lines))
 	e.lines = lines
 	if e.cursor.Row >= len(e.lines) {
 		e.cursor.Row = len(e.lines) - 1
@@ -4246,16 +4949,46 @@ func (e *Editor) replaceBuffer(text string, markDirty bool) {
 			e.scroll = 0
 		}
 	}
-	e.undo = nil
-	e.redo = nil
-	if markDirty {
-		e.savePoint = -1
-	} else {
-		e.savePoint = 0
-	}
-	e.lastEdit.Valid = false
+	e.resetHistory()
 	e.changeTick++
 	e.updateDirty()
+	e.flushChanges()
+}
+
+// replaceLines turns the buffer into lines with one edit per differing
+// hunk, applied back to front so earlier positions stay valid
+func (e *Editor) replaceLines(lines [][]rune) {
+	hunks := core.DiffLines(e.lines, lines)
+	if len(hunks) == 0 {
+		return
+	}
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	for i := len(hunks) - 1; i >= 0; i-- {
+		h := hunks[i]
+		text := joinLines(lines[h.B0:h.B1])
+		var start, end Cursor
+		switch {
+		case h.A1 < len(e.lines):
+			// Replace whole lines up to the start of the next one
+			start, end = Cursor{Row: h.A0}, Cursor{Row: h.A1}
+			if h.B1 > h.B0 {
+				text += "\n"
+			}
+		case h.A0 > 0:
+			// The hunk runs to the end: replace from the end of the line before
+			last := len(e.lines) - 1
+			start = Cursor{Row: h.A0 - 1, Col: len(e.lines[h.A0-1])}
+			end = Cursor{Row: last, Col: len(e.lines[last])}
+			if h.B1 > h.B0 {
+				text = "\n" + text
+			}
+		default:
+			last := len(e.lines) - 1
+			end = Cursor{Row: last, Col: len(e.lines[last])}
+		}
+		_, _ = e.ReplaceRange(start, end, text)
+	}
 }
 
 func (e *Editor) Undo() {
@@ -4278,12 +5011,18 @@ func (e *Editor) Undo() {
 			return
 		}
 		inv.group = act.group
+		inv.cursorBefore, inv.cursorAfter, inv.hasCursor = act.cursorBefore, act.cursorAfter, act.hasCursor
 		e.redo = append(e.redo, inv)
+		e.queueChange(inv)
+		// The group's first action, undone last, has the state before it
+		e.restoreEditState(act, true)
 	}
+	// Actions don't all move the cursor; keep it inside the restored text
+	e.cursor.Row = clampRange(e.cursor.Row, 0, len(e.lines)-1)
+	e.clampCursorCol()
 	e.changeTick++
 	e.updateDirty()
-	// Invalidate lastEdit to force full reparse for syntax highlighting
-	e.lastEdit.Valid = false
+	e.flushChanges()
 }
 
 func (e *Editor) Redo() {
@@ -4306,12 +5045,17 @@ func (e *Editor) Redo() {
 			return
 		}
 		inv.group = act.group
+		inv.cursorBefore, inv.cursorAfter, inv.hasCursor = act.cursorBefore, act.cursorAfter, act.hasCursor
 		e.undo = append(e.undo, inv)
+		e.queueChange(inv)
+		e.restoreEditState(act, false)
 	}
+	// Actions don't all move the cursor; keep it inside the restored text
+	e.cursor.Row = clampRange(e.cursor.Row, 0, len(e.lines)-1)
+	e.clampCursorCol()
 	e.changeTick++
 	e.updateDirty()
-	// Invalidate lastEdit to force full reparse for syntax highlighting
-	e.lastEdit.Valid = false
+	e.flushChanges()
 }
 
 func (e *Editor) applyAction(act action) (action, bool) {
@@ -4388,10 +5132,14 @@ func (e *Editor) applyAction(act action) (action, bool) {
 func (e *Editor) recordUndo(act action) {
 	e.undoGroup++
 	act.group = e.undoGroup
+	e.noteEditState(&act)
 	e.undo = append(e.undo, act)
+	e.queueChange(act)
 	e.redo = e.redo[:0]
+	e.stampCursorAfter()
 	e.changeTick++
 	e.updateDirty()
+	e.flushChanges()
 }
 
 // startUndoGroup starts a new undo group. All subsequent appendUndo calls will use this group.
@@ -4404,18 +5152,101 @@ func (e *Editor) startUndoGroup() {
 // Use this when recording multiple actions as part of a single logical operation.
 func (e *Editor) appendUndo(act action) {
 	act.group = e.undoGroup
+	e.noteEditState(&act)
 	e.undo = append(e.undo, act)
+	e.queueChange(act)
 }
 
 // finishUndoGroup clears redo and updates state after a group of undo actions.
 func (e *Editor) finishUndoGroup() {
 	e.redo = e.redo[:0]
+	e.stampCursorAfter()
 	e.changeTick++
 	e.updateDirty()
+	e.flushChanges()
+}
+
+// revision identifies the buffer's text within its undo history: the
+// undo group of the last edit still applied, or baseRevision when there
+// is nothing to undo. Groups only ever grow, so a revision is never
+// reused, however history was undone, redone or replaced.
+func (e *Editor) revision() uint64 {
+	if len(e.undo) == 0 {
+		return e.baseRevision
+	}
+	return e.undo[len(e.undo)-1].group
+}
+
+// unsavedRevision is savedRevision when the text on disk is none in the
+// history, after an edit that isn't in it
+const unsavedRevision = ^uint64(0)
+
+// markSaved records the current text as the one on disk
+func (e *Editor) markSaved() {
+	e.savedRevision = e.revision()
+	e.updateDirty()
+}
+
+// resetHistory drops undo and redo. The text becomes a new base revision
+// and is taken to be the one on disk.
+func (e *Editor) resetHistory() {
+	e.undo = nil
+	e.redo = nil
+	e.undoGroup++
+	e.baseRevision = e.undoGroup
+	e.markSaved()
+}
+
+// noteEditState records on a text action the cursor and selection from
+// before the edit, for undo to restore. A cursor or selection the caller
+// already recorded, having moved the cursor first, is kept.
+func (e *Editor) noteEditState(act *action) {
+	if act.kind != actionInsertText && act.kind != actionDeleteText {
+		return
+	}
+	if !act.hasCursor {
+		act.hasCursor = true
+		act.cursorBefore = e.cursor
+	}
+	if !act.hasSelection && e.selectionActive && e.selectionStart != e.selectionEnd {
+		act.hasSelection = true
+		act.selectionStart = e.selectionStart
+		act.selectionEnd = e.selectionEnd
+	}
+}
+
+// stampCursorAfter records the cursor as where the text actions of the
+// newest undo group leave it, for redo to restore. Edits that move the
+// cursor after their group is finished call it again.
+func (e *Editor) stampCursorAfter() {
+	for i := len(e.undo) - 1; i >= 0 && e.undo[i].group == e.undoGroup; i-- {
+		e.undo[i].cursorAfter = e.cursor
+	}
+}
+
+// restoreEditState puts back the cursor and selection act recorded: the
+// ones from before the edit on undo, the cursor after it on redo
+func (e *Editor) restoreEditState(act action, undo bool) {
+	if !act.hasCursor {
+		return
+	}
+	if !undo {
+		e.cursor = act.cursorAfter
+		e.clearSelection()
+		return
+	}
+	e.cursor = act.cursorBefore
+	if act.hasSelection {
+		e.selectionActive = true
+		e.selectionStart = act.selectionStart
+		e.selectionEnd = act.selectionEnd
+	} else {
+		e.clearSelection()
+	}
 }
 
 func (e *Editor) updateDirty() {
-	e.dirty = len(e.undo) != e.savePoint
+	e.dirty = e.revision() != e.savedRevision
 }
 
 // changelogFilePath returns the path for the changelog file for the given file path.
@@ -4468,6 +5299,9 @@ func actionToJSON(a action) actionJSON {
 		SelectionStart: [2]int{a.selectionStart.Row, a.selectionStart.Col},
 		SelectionEnd:   [2]int{a.selectionEnd.Row, a.selectionEnd.Col},
 		HasSelection:   a.hasSelection,
+		CursorBefore:   [2]int{a.cursorBefore.Row, a.cursorBefore.Col},
+		CursorAfter:    [2]int{a.cursorAfter.Row, a.cursorAfter.Col},
+		HasCursor:      a.hasCursor,
 	}
 }
 
@@ -4492,18 +5326,41 @@ func jsonToAction(j actionJSON) action {
 		selectionStart: Cursor{Row: j.SelectionStart[0], Col: j.SelectionStart[1]},
 		selectionEnd:   Cursor{Row: j.SelectionEnd[0], Col: j.SelectionEnd[1]},
 		hasSelection:   j.HasSelection,
+		cursorBefore:   Cursor{Row: j.CursorBefore[0], Col: j.CursorBefore[1]},
+		cursorAfter:    Cursor{Row: j.CursorAfter[0], Col: j.CursorAfter[1]},
+		hasCursor:      j.HasCursor,
 	}
 }
 
 // undoHistoryHeader stores metadata for undo history validation
 type undoHistoryHeader struct {
-	Version int   `json:"v"`
-	Mtime   int64 `json:"mtime"`
-}
-
-// SaveUndoHistory saves the undo history to the changelog file
+	Version int    `json:"v"`
+	Mtime   int64  `json:"mtime"`
+	Saved   uint64 `json:"saved,omitempty"` // v2: group of the text on disk, 0 before the first action
+	// v2: the buffer's text when it had edits that never reached the file,
+	// for :recover
+	Unsaved *string `json:"unsaved,omitempty"`
+}
+
+// slowHistoryWrite is how long a changelog write takes before its end is
+// worth a statusline notice
+const slowHistoryWrite = 500 * time.Millisecond
+
+// undoHistoryWrite is a changelog copied from the editor, to be written
+// off the UI goroutine
+type undoHistoryWrite struct {
+	path    string
+	header  undoHistoryHeader
+	actions []actionJSON
+}
+
+// SaveUndoHistory writes the undo history to the changelog file. The
+// history is copied here and written in a task, so a long one doesn't hold
+// up the editor; a write asked for while another runs follows it. Nothing
+// is written when the text on disk is no longer in the history, such as
+// after undoing past a save and editing.
 func (e *Editor) SaveUndoHistory() error {
-	if e.filename == "" {
+	if e.noState || e.filename == "" {
 		return nil // No file path, nothing to save
 	}
 
@@ -4511,48 +5368,153 @@ func (e *Editor) SaveUndoHistory() error {
 	if logPath == "" {
 		return nil
 	}
-
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(logPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+	e.historyTick = e.changeTick
+	e.historySavedAt = time.Now()
+	saved, ok := e.savedGroup()
+	if !ok {
+		return nil
 	}
 
-	// Get file mtime for validation
+	// Stamp the mtime of the text the history leads to, so a file changed
+	// on disk since then drops the history on load
 	var mtime int64
-	if info, err := os.Stat(e.filename); err == nil {
+	if e.disk.valid {
+		mtime = e.disk.mtime.UnixNano()
+	} else if info, err := os.Stat(e.filename); err == nil {
 		mtime = info.ModTime().UnixNano()
 	}
 
-	// Open file for writing
-	f, err := os.Create(logPath)
+	w := undoHistoryWrite{
+		path:    logPath,
+		header:  undoHistoryHeader{Version: 2, Mtime: mtime, Saved: saved},
+		actions: make([]actionJSON, len(e.undo)),
+	}
+	for i, a := range e.undo {
+		w.actions[i] = actionToJSON(a)
+	}
+	if e.dirty {
+		text := joinLines(e.lines)
+		w.header.Unsaved = &text
+	}
+	e.writeUndoHistory(w)
+	return nil
+}
+
+// savedGroup returns the undo group of the text on disk, 0 when it is the
+// text before the first action, and whether the undo history leads to it
+func (e *Editor) savedGroup() (uint64, bool) {
+	if e.savedRevision == e.baseRevision {
+		return 0, true
+	}
+	for _, a := range e.undo {
+		if a.group == e.savedRevision {
+			return a.group, true
+		}
+	}
+	return 0, false
+}
+
+// writeUndoHistory writes w in a task, or after the write that is running
+func (e *Editor) writeUndoHistory(w undoHistoryWrite) {
+	if e.historyWriting {
+		e.historyPending = &w
+		return
+	}
+	e.historyWriting = true
+	e.RunTask("undo history", func(ctx context.Context) func() {
+		start := time.Now()
+		err := w.write()
+		elapsed := time.Since(start)
+		return func() {
+			e.historyWriting = false
+			switch {
+			case err != nil:
+				e.setStatus("undo history: " + err.Error())
+			case elapsed >= slowHistoryWrite:
+				e.setStatus(fmt.Sprintf("undo history saved (%d changes)", len(w.actions)))
+			}
+			if next := e.historyPending; next != nil {
+				e.historyPending = nil
+				e.writeUndoHistory(*next)
+			}
+		}
+	})
+}
+
+// write writes the changelog to a temporary file renamed over the old one,
+// so a write cut short never leaves half a history
+func (w undoHistoryWrite) write() error {
+	// Create directory if it doesn't exist
+	dir := filepath.Dir(w.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(w.path)+".*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer os.Remove(f.Name())
 
 	writer := bufio.NewWriter(f)
 	encoder := json.NewEncoder(writer)
-
-	// Write header with mtime for validation
-	header := undoHistoryHeader{Version: 1, Mtime: mtime}
-	if err := encoder.Encode(header); err != nil {
+	err = encoder.Encode(w.header)
+	// Write each action as a JSON line
+	for i := 0; err == nil && i < len(w.actions); i++ {
+		err = encoder.Encode(w.actions[i])
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
 		return err
 	}
+	return os.Rename(f.Name(), w.path)
+}
 
-	// Write each action as a JSON line
-	for _, a := range e.undo {
-		if err := encoder.Encode(actionToJSON(a)); err != nil {
-			return err
-		}
+// AutosaveUndoHistory writes the undo history when it changed and
+// undo-history-autosave seconds have passed since it was last written.
+// The edits not saved yet go with it as text, so after a crash :recover
+// gets them back. The app calls it from its loop.
+func (e *Editor) AutosaveUndoHistory(now time.Time) {
+	if e.historyAutosave <= 0 || e.changeTick == e.historyTick || now.Sub(e.historySavedAt) < e.historyAutosave {
+		return
 	}
+	_ = e.SaveUndoHistory()
+}
+
+// execRecoverCommand puts back the unsaved text an autosaved undo history
+// kept (:recover), as one undo step on top of the saved text
+func (e *Editor) execRecoverCommand() {
+	if e.recovery == nil {
+		e.setStatus("nothing to recover")
+		return
+	}
+	if e.refuseEdit() {
+		return
+	}
+	text := *e.recovery
+	e.recovery = nil
+	e.replaceBuffer(text, true)
+	e.setStatus("recovered unsaved edits (:w writes them, u undoes)")
+}
 
-	return writer.Flush()
+// flushUndoHistory writes a changelog still waiting for the running write,
+// before the editor exits
+func (e *Editor) flushUndoHistory() {
+	if next := e.historyPending; next != nil {
+		e.historyPending = nil
+		if err := next.write(); err != nil {
+			logger.Error("failed to write undo history", "error", err)
+		}
+	}
 }
 
 // LoadUndoHistory loads the undo history from the changelog file
 func (e *Editor) LoadUndoHistory() error {
-	if e.filename == "" {
+	if e.noState || e.filename == "" {
 		return nil
 	}
 
@@ -4582,8 +5544,8 @@ func (e *Editor) LoadUndoHistory() error {
 	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
 
 	// Read and validate header first
+	var header undoHistoryHeader
 	if scanner.Scan() {
-		var header undoHistoryHeader
 		if err := json.Unmarshal(scanner.Bytes(), &header); err == nil {
 			if header.Version > 0 {
 				// New format with header - validate mtime
@@ -4608,10 +5570,37 @@ func (e *Editor) LoadUndoHistory() error {
 		}
 		e.undo = append(e.undo, jsonToAction(j))
 	}
+	if header.Version >= 2 {
+		// Edits after the text on disk were never saved: drop them from
+		// the history, and offer their text
+		if header.Unsaved != nil && *header.Unsaved != joinLines(e.lines) {
+			e.recovery = header.Unsaved
+			e.setStatus("unsaved edits from an earlier session: :recover restores them")
+		}
+		kept := 0
+		for kept < len(e.undo) && e.undo[kept].group <= header.Saved {
+			kept++
+		}
+		e.undo = e.undo[:kept]
+	}
+	e.adoptLoadedHistory()
 
 	return scanner.Err()
 }
 
+// adoptLoadedHistory makes undo history read from the changelog fit this
+// session: new groups are numbered after the loaded ones, and the file on
+// disk is the text at the end of the history
+func (e *Editor) adoptLoadedHistory() {
+	for _, a := range e.undo {
+		e.undoGroup = max(e.undoGroup, a.group)
+	}
+	// The base must not collide with a loaded group
+	e.undoGroup++
+	e.baseRevision = e.undoGroup
+	e.markSaved()
+}
+
 // ClearUndoHistory removes the changelog file for the current file
 func (e *Editor) ClearUndoHistory() error {
 	if e.filename == "" {
@@ -4634,16 +5623,6 @@ func (e *Editor) setStatus(msg string) {
 	e.statusMessage = msg
 }
 
-// sendTerminalZoomStep sends a single zoom command to the terminal via AppleScript.
-func (e *Editor) sendTerminalZoomStep(zoomIn bool) {
-	key := "+"
-	if !zoomIn {
-		key = "-"
-	}
-	script := fmt.Sprintf(`tell application "System Events" to keystroke "%s" using command down`, key)
-	_ = exec.Command("osascript", "-e", script).Run()
-}
-
 // zoomWithAnimation performs zoom with synchronized scroll animation.
 // For zoom in: scrolls to center cursor. For zoom out: scrolls back to saved position.
 func (e *Editor) zoomWithAnimation(zoomIn bool, steps int) {
@@ -4687,7 +5666,7 @@ func (e *Editor) zoomWithAnimation(zoomIn bool, steps int) {
 	// Perform animated zoom + scroll
 	for i := 1; i <= steps; i++ {
 		// Send one zoom step
-		e.sendTerminalZoomStep(zoomIn)
+		zoom.Step(zoomIn)
 
 		// Interpolate scroll position (linear)
 		progress := float64(i) / float64(steps)
@@ -4701,180 +5680,75 @@ func (e *Editor) zoomWithAnimation(zoomIn bool, steps int) {
 	// Ensure final position is exact
 	e.scroll = targetScroll
 	e.scrollX = targetScrollX
-}
-
-func (e *Editor) insertRune(r rune) {
-	pos := e.cursor
-	line := e.lines[pos.Row]
-	if pos.Col > len(line) {
-		pos.Col = len(line)
-	}
-	if !e.insertRuneAt(pos, r) {
-		return
-	}
-	e.recordUndo(action{kind: actionDeleteRune, pos: pos, r: r})
-}
-
-func (e *Editor) insertTab() {
-	e.insertRune('\t')
-}
-
-func (e *Editor) insertRuneAt(pos Cursor, r rune) bool {
-	if pos.Row < 0 || pos.Row >= len(e.lines) {
-		return false
-	}
-	line := e.lines[pos.Row]
-	if pos.Col < 0 {
-		pos.Col = 0
-	}
-	if pos.Col > len(line) {
-		pos.Col = len(line)
-	}
-	e.recordTextEdit(pos, pos, Cursor{Row: pos.Row, Col: pos.Col + 1}, runeByteLen(r))
-	line = append(line, 0)
-	copy(line[pos.Col+1:], line[pos.Col:])
-	line[pos.Col] = r
-	e.lines[pos.Row] = line
-	e.cursor = Cursor{Row: pos.Row, Col: pos.Col + 1}
-	return true
-}
-
-// insertTextAt inserts multiple lines at the given position and returns the end position.
-// This is a bulk operation for efficiency with large text blocks.
-func (e *Editor) insertTextAt(pos Cursor, text [][]rune) Cursor {
-	if len(text) == 0 || pos.Row < 0 || pos.Row >= len(e.lines) {
-		return pos
-	}
-
-	if len(text) == 1 {
-		// Single line: just insert the runes into the current line
-		line := e.lines[pos.Row]
-		if pos.Col < 0 {
-			pos.Col = 0
-		}
-		if pos.Col > len(line) {
-			pos.Col = len(line)
-		}
-		newLine := make([]rune, 0, len(line)+len(text[0]))
-		newLine = append(newLine, line[:pos.Col]...)
-		newLine = append(newLine, text[0]...)
-		newLine = append(newLine, line[pos.Col:]...)
-		e.lines[pos.Row] = newLine
-		return Cursor{Row: pos.Row, Col: pos.Col + len(text[0])}
-	}
-
-	// Multi-line insertion
-	line := e.lines[pos.Row]
-	if pos.Col < 0 {
-		pos.Col = 0
-	}
-	if pos.Col > len(line) {
-		pos.Col = len(line)
-	}
-
-	// First line: prefix from original + first inserted line
-	firstLine := make([]rune, 0, pos.Col+len(text[0]))
-	firstLine = append(firstLine, line[:pos.Col]...)
-	firstLine = append(firstLine, text[0]...)
-
-	// Last line: last inserted line + suffix from original
-	suffix := line[pos.Col:]
-	lastLine := make([]rune, 0, len(text[len(text)-1])+len(suffix))
-	lastLine = append(lastLine, text[len(text)-1]...)
-	lastLine = append(lastLine, suffix...)
-
-	// Build new lines slice
-	newLines := make([][]rune, 0, len(e.lines)+len(text)-1)
-	newLines = append(newLines, e.lines[:pos.Row]...)
-	newLines = append(newLines, firstLine)
-	for i := 1; i < len(text)-1; i++ {
-		newLines = append(newLines, text[i])
-	}
-	newLines = append(newLines, lastLine)
-	newLines = append(newLines, e.lines[pos.Row+1:]...)
-	e.lines = newLines
-
-	return Cursor{Row: pos.Row + len(text) - 1, Col: len(text[len(text)-1])}
-}
-
-// deleteTextRange deletes text from start to end position and returns the deleted text.
-// This is a bulk operation for efficiency with large text blocks.
-func (e *Editor) deleteTextRange(start, end Cursor) [][]rune {
-	if start.Row < 0 || end.Row >= len(e.lines) || start.Row > end.Row {
-		return nil
-	}
-	if start.Row == end.Row && start.Col >= end.Col {
-		return nil
-	}
-
-	if start.Row == end.Row {
-		// Single line deletion
-		line := e.lines[start.Row]
-		if start.Col < 0 {
-			start.Col = 0
-		}
-		if end.Col > len(line) {
-			end.Col = len(line)
-		}
-		deleted := make([]rune, end.Col-start.Col)
-		copy(deleted, line[start.Col:end.Col])
-		newLine := make([]rune, 0, len(line)-(end.Col-start.Col))
-		newLine = append(newLine, line[:start.Col]...)
-		newLine = append(newLine, line[end.Col:]...)
-		e.lines[start.Row] = newLine
-		e.cursor = start
-		return [][]rune{deleted}
-	}
-
-	// Multi-line deletion
-	// Collect deleted text
-	deleted := make([][]rune, end.Row-start.Row+1)
+}
 
-	// First line partial
-	firstLine := e.lines[start.Row]
-	if start.Col < 0 {
-		start.Col = 0
+func (e *Editor) insertRune(r rune) {
+	pos := e.cursor
+	line := e.lines[pos.Row]
+	if pos.Col > len(line) {
+		pos.Col = len(line)
 	}
-	if start.Col > len(firstLine) {
-		start.Col = len(firstLine)
+	if !e.insertRuneAt(pos, r) {
+		return
 	}
-	deleted[0] = make([]rune, len(firstLine)-start.Col)
-	copy(deleted[0], firstLine[start.Col:])
+	e.recordUndo(action{kind: actionDeleteRune, pos: pos, r: r})
+}
 
-	// Middle lines (complete)
-	for i := start.Row + 1; i < end.Row; i++ {
-		deleted[i-start.Row] = make([]rune, len(e.lines[i]))
-		copy(deleted[i-start.Row], e.lines[i])
+func (e *Editor) insertTab() {
+	if !e.expandTab {
+		e.insertRune('\t')
+		return
 	}
+	// Spaces up to the next indent stop
+	col := visualCol(e.lines[e.cursor.Row], e.cursor.Col, e.tabWidth)
+	for n := e.indentWidth - col%e.indentWidth; n > 0; n-- {
+		e.insertRune(' ')
+	}
+}
 
-	// Last line partial
-	lastLine := e.lines[end.Row]
-	if end.Col < 0 {
-		end.Col = 0
+func (e *Editor) insertRuneAt(pos Cursor, r rune) bool {
+	if pos.Row < 0 || pos.Row >= len(e.lines) {
+		return false
 	}
-	if end.Col > len(lastLine) {
-		end.Col = len(lastLine)
+	line := e.lines[pos.Row]
+	if pos.Col < 0 {
+		pos.Col = 0
 	}
-	deleted[len(deleted)-1] = make([]rune, end.Col)
-	copy(deleted[len(deleted)-1], lastLine[:end.Col])
-
-	// Merge first and last lines
-	mergedLine := make([]rune, 0, start.Col+len(lastLine)-end.Col)
-	mergedLine = append(mergedLine, firstLine[:start.Col]...)
-	mergedLine = append(mergedLine, lastLine[end.Col:]...)
+	if pos.Col > len(line) {
+		pos.Col = len(line)
+	}
+	line = append(line, 0)
+	copy(line[pos.Col+1:], line[pos.Col:])
+	line[pos.Col] = r
+	e.lines[pos.Row] = line
+	e.cursor = Cursor{Row: pos.Row, Col: pos.Col + 1}
+	return true
+}
 
-	// Build new lines slice
-	newLines := make([][]rune, 0, len(e.lines)-(end.Row-start.Row))
-	newLines = append(newLines, e.lines[:start.Row]...)
-	newLines = append(newLines, mergedLine)
-	newLines = append(newLines, e.lines[end.Row+1:]...)
-	e.lines = newLines
+// insertTextAt inserts multiple lines at the given position and returns the end position.
+// This is a bulk operation for efficiency with large text blocks.
+func (e *Editor) insertTextAt(pos Cursor, text [][]rune) Cursor {
+	var end Cursor
+	e.lines, end = core.InsertText(e.lines, pos, text)
+	return end
+}
 
+// deleteTextRange deletes text from start to end position and returns the deleted text.
+// This is a bulk operation for efficiency with large text blocks.
+func (e *Editor) deleteTextRange(start, end Cursor) [][]rune {
+	lines, deleted, start := core.DeleteText(e.lines, start, end)
+	if deleted == nil {
+		return nil
+	}
+	e.lines = lines
 	e.cursor = start
 	return deleted
 }
 
 func (e *Editor) insertNewline() {
+	if e.mode == ModeInsert && e.continueList() {
+		return
+	}
 	pos := e.cursor
 	line := e.lines[pos.Row]
 	if pos.Col > len(line) {
@@ -4897,7 +5771,6 @@ func (e *Editor) splitLineAt(pos Cursor) bool {
 	if pos.Col > len(line) {
 		pos.Col = len(line)
 	}
-	e.recordTextEdit(pos, pos, Cursor{Row: pos.Row + 1, Col: 0}, 1)
 	left := append([]rune(nil), line[:pos.Col]...)
 	right := append([]rune(nil), line[pos.Col:]...)
 
@@ -4990,6 +5863,7 @@ func (e *Editor) deleteLine() {
 	}
 	e.cursor.Col = 0
 	e.clampCursorCol()
+	e.stampCursorAfter()
 }
 
 func (e *Editor) deleteChar() {
@@ -5028,39 +5902,21 @@ func (e *Editor) deleteSelection(start, end Cursor, restoreSelectionOnUndo bool)
 		return
 	}
 
-	// Calculate byte offsets BEFORE making changes
-	startByte, startColBytes := e.byteOffset(start)
-	oldEndByte, oldEndColBytes := e.byteOffset(end)
-
 	// Collect deleted content for undo
 	// Use bulk operation for efficiency with large selections
 	deleted := e.collectDeletedText(start, end)
 
 	e.startUndoGroup()
-	// Record as a single bulk insert action for undo
+	// Record as a single bulk insert action for undo. appendUndo records
+	// an active selection as it is, keeping its direction.
 	e.appendUndo(action{
 		kind:           actionInsertText,
 		pos:            start,
 		text:           deleted,
 		selectionStart: start,
 		selectionEnd:   end,
-		hasSelection:   restoreSelectionOnUndo,
+		hasSelection:   restoreSelectionOnUndo && !e.selectionActive,
 	})
-	e.finishUndoGroup()
-
-	// Record text edit for tree-sitter
-	e.lastEdit = TextEdit{
-		Valid:          true,
-		StartByte:      startByte,
-		OldEndByte:     oldEndByte,
-		NewEndByte:     startByte, // Nothing inserted
-		StartRow:       start.Row,
-		StartColBytes:  startColBytes,
-		OldEndRow:      end.Row,
-		OldEndColBytes: oldEndColBytes,
-		NewEndRow:      start.Row,
-		NewEndColBytes: startColBytes,
-	}
 
 	// Actually delete the selection
 	if start.Row == end.Row {
@@ -5085,8 +5941,7 @@ func (e *Editor) deleteSelection(start, end Cursor, restoreSelectionOnUndo bool)
 
 	e.cursor = start
 	e.clearSelection()
-	e.changeTick++
-	e.updateDirty()
+	e.finishUndoGroup()
 }
 
 // collectDeletedText collects text from start to end position without modifying the buffer.
@@ -5129,24 +5984,7 @@ func (e *Editor) deleteWordLeft() {
 	if e.cursor.Col == 0 {
 		// At start of line - join with previous line
 		if e.cursor.Row > 0 {
-			// Calculate byte offset BEFORE change
 			pos := Cursor{Row: e.cursor.Row - 1, Col: len(e.lines[e.cursor.Row-1])}
-			startByte, startColBytes := e.byteOffset(pos)
-			oldEndByte := startByte + 1 // +1 for newline
-
-			e.lastEdit = TextEdit{
-				Valid:          true,
-				StartByte:      startByte,
-				OldEndByte:     oldEndByte,
-				NewEndByte:     startByte,
-				StartRow:       pos.Row,
-				StartColBytes:  startColBytes,
-				OldEndRow:      e.cursor.Row,
-				OldEndColBytes: 0,
-				NewEndRow:      pos.Row,
-				NewEndColBytes: startColBytes,
-			}
-
 			if e.joinLineAt(pos) {
 				e.recordUndo(action{kind: actionSplitLine, pos: pos})
 			}
@@ -5170,15 +6008,9 @@ func (e *Editor) deleteWordLeft() {
 		idx--
 	}
 
-	// Then skip word characters or non-word/non-space characters
-	if idx >= 0 && isWordRune(line[idx]) {
-		for idx > 0 && isWordRune(line[idx-1]) {
-			idx--
-		}
-	} else if idx >= 0 && !isSpaceRune(line[idx]) {
-		for idx > 0 && !isSpaceRune(line[idx-1]) && !isWordRune(line[idx-1]) {
-			idx--
-		}
+	// Then skip the word or punctuation run
+	if !isSpaceRune(line[idx]) {
+		idx = e.wordRuns(line).start(idx)
 	}
 
 	startCol := idx
@@ -5186,24 +6018,6 @@ func (e *Editor) deleteWordLeft() {
 		return
 	}
 
-	// Calculate byte offsets BEFORE making changes
-	startByte, startColBytes := e.byteOffset(Cursor{Row: e.cursor.Row, Col: startCol})
-	oldEndByte, oldEndColBytes := e.byteOffset(Cursor{Row: e.cursor.Row, Col: endCol})
-
-	// Record text edit for tree-sitter
-	e.lastEdit = TextEdit{
-		Valid:          true,
-		StartByte:      startByte,
-		OldEndByte:     oldEndByte,
-		NewEndByte:     startByte,
-		StartRow:       e.cursor.Row,
-		StartColBytes:  startColBytes,
-		OldEndRow:      e.cursor.Row,
-		OldEndColBytes: oldEndColBytes,
-		NewEndRow:      e.cursor.Row,
-		NewEndColBytes: startColBytes,
-	}
-
 	// Record undo for each character (backwards) as a group
 	e.startUndoGroup()
 	for col := endCol - 1; col >= startCol; col-- {
@@ -5211,12 +6025,12 @@ func (e *Editor) deleteWordLeft() {
 			e.appendUndo(action{kind: actionInsertRune, pos: Cursor{Row: e.cursor.Row, Col: col}, r: line[col]})
 		}
 	}
-	e.finishUndoGroup()
 
 	// Actually delete the range
 	newLine := append([]rune(nil), line[:startCol]...)
 	newLine = append(newLine, line[endCol:]...)
 	e.lines[e.cursor.Row] = newLine
+	e.finishUndoGroup()
 
 	e.cursor.Col = startCol
 }
@@ -5232,22 +6046,6 @@ func (e *Editor) deleteWordRight() {
 	if e.cursor.Col >= lineLen {
 		// At end of line - join with next line
 		if e.cursor.Row < len(e.lines)-1 {
-			// Calculate byte offset BEFORE change
-			startByte, startColBytes := e.byteOffset(e.cursor)
-			oldEndByte := startByte + 1 // +1 for newline
-
-			e.lastEdit = TextEdit{
-				Valid:          true,
-				StartByte:      startByte,
-				OldEndByte:     oldEndByte,
-				NewEndByte:     startByte,
-				StartRow:       e.cursor.Row,
-				StartColBytes:  startColBytes,
-				OldEndRow:      e.cursor.Row + 1,
-				OldEndColBytes: 0,
-				NewEndRow:      e.cursor.Row,
-				NewEndColBytes: startColBytes,
-			}
 
 			if e.joinLineAt(e.cursor) {
 				e.recordUndo(action{kind: actionSplitLine, pos: e.cursor})
@@ -5259,16 +6057,9 @@ func (e *Editor) deleteWordRight() {
 	startCol := e.cursor.Col
 	idx := startCol
 
-	// Skip word characters first
-	if idx < lineLen && isWordRune(line[idx]) {
-		for idx < lineLen && isWordRune(line[idx]) {
-			idx++
-		}
-	} else if idx < lineLen && !isSpaceRune(line[idx]) {
-		// Skip non-word/non-space characters
-		for idx < lineLen && !isSpaceRune(line[idx]) && !isWordRune(line[idx]) {
-			idx++
-		}
+	// Skip the word or punctuation run first
+	if idx < lineLen && !isSpaceRune(line[idx]) {
+		idx = e.wordRuns(line).end(idx)
 	}
 
 	// Then skip trailing spaces
@@ -5281,24 +6072,6 @@ func (e *Editor) deleteWordRight() {
 		return
 	}
 
-	// Calculate byte offsets BEFORE making changes
-	startByte, startColBytes := e.byteOffset(Cursor{Row: e.cursor.Row, Col: startCol})
-	oldEndByte, oldEndColBytes := e.byteOffset(Cursor{Row: e.cursor.Row, Col: endCol})
-
-	// Record text edit for tree-sitter
-	e.lastEdit = TextEdit{
-		Valid:          true,
-		StartByte:      startByte,
-		OldEndByte:     oldEndByte,
-		NewEndByte:     startByte,
-		StartRow:       e.cursor.Row,
-		StartColBytes:  startColBytes,
-		OldEndRow:      e.cursor.Row,
-		OldEndColBytes: oldEndColBytes,
-		NewEndRow:      e.cursor.Row,
-		NewEndColBytes: startColBytes,
-	}
-
 	// Record undo for each character (backwards) as a group
 	e.startUndoGroup()
 	for col := endCol - 1; col >= startCol; col-- {
@@ -5306,12 +6079,12 @@ func (e *Editor) deleteWordRight() {
 			e.appendUndo(action{kind: actionInsertRune, pos: Cursor{Row: e.cursor.Row, Col: col}, r: line[col]})
 		}
 	}
-	e.finishUndoGroup()
 
 	// Actually delete the range
 	newLine := append([]rune(nil), line[:startCol]...)
 	newLine = append(newLine, line[endCol:]...)
 	e.lines[e.cursor.Row] = newLine
+	e.finishUndoGroup()
 }
 
 func (e *Editor) insertLineBelow() {
@@ -5352,6 +6125,9 @@ func (e *Editor) insertLineBelow() {
 }
 
 func (e *Editor) indentSelection() {
+	if e.shiftHeadings(1) {
+		return
+	}
 	start, end, ok := e.selectionRange()
 	if !ok {
 		// No selection - behavior depends on mode
@@ -5372,51 +6148,52 @@ func (e *Editor) indentSelection() {
 	}
 
 	// Indent all lines in selection as a group
+	unit := e.indentUnit()
 	e.startUndoGroup()
 	for row := start.Row; row <= endRow; row++ {
 		if row < 0 || row >= len(e.lines) {
 			continue
 		}
-		// Insert tab at beginning of line
-		line := e.lines[row]
-		newLine := make([]rune, len(line)+1)
-		newLine[0] = '\t'
-		copy(newLine[1:], line)
-		e.lines[row] = newLine
-		e.appendUndo(action{kind: actionDeleteRune, pos: Cursor{Row: row, Col: 0}, r: '\t'})
+		// Insert the indent at beginning of line
+		e.lines[row] = append(append([]rune(nil), unit...), e.lines[row]...)
+		for _, r := range unit {
+			e.appendUndo(action{kind: actionDeleteRune, pos: Cursor{Row: row, Col: 0}, r: r})
+		}
 	}
 	e.finishUndoGroup()
-	e.lastEdit.Valid = false
 
-	// Adjust cursor and selection columns - they shift by 1 for affected lines
+	// Adjust cursor and selection columns - they shift by the indent for affected lines
 	if e.cursor.Row >= start.Row && e.cursor.Row <= endRow {
-		e.cursor.Col++
+		e.cursor.Col += len(unit)
 	}
 	if e.selectionStart.Row >= start.Row && e.selectionStart.Row <= endRow {
-		e.selectionStart.Col++
+		e.selectionStart.Col += len(unit)
 	}
 	if e.selectionEnd.Row >= start.Row && e.selectionEnd.Row <= endRow && end.Col > 0 {
-		e.selectionEnd.Col++
+		e.selectionEnd.Col += len(unit)
 	}
 }
 
-// indentCurrentLine adds a tab at the beginning of the current line (for Normal mode)
+// indentCurrentLine adds one indent at the beginning of the current line (for Normal mode)
 func (e *Editor) indentCurrentLine() {
 	row := e.cursor.Row
 	if row < 0 || row >= len(e.lines) {
 		return
 	}
-	line := e.lines[row]
-	newLine := make([]rune, len(line)+1)
-	newLine[0] = '\t'
-	copy(newLine[1:], line)
-	e.lines[row] = newLine
-	e.recordUndo(action{kind: actionDeleteRune, pos: Cursor{Row: row, Col: 0}, r: '\t'})
-	e.cursor.Col++
-	e.lastEdit.Valid = false
+	unit := e.indentUnit()
+	e.startUndoGroup()
+	e.lines[row] = append(append([]rune(nil), unit...), e.lines[row]...)
+	for _, r := range unit {
+		e.appendUndo(action{kind: actionDeleteRune, pos: Cursor{Row: row, Col: 0}, r: r})
+	}
+	e.finishUndoGroup()
+	e.cursor.Col += len(unit)
 }
 
 func (e *Editor) unindentSelection() {
+	if e.shiftHeadings(-1) {
+		return
+	}
 	start, end, hasSelection := e.selectionRange()
 	if !hasSelection {
 		// No selection - unindent current line only
@@ -5447,14 +6224,14 @@ func (e *Editor) unindentSelection() {
 		}
 
 		removed := 0
-		// Remove leading tab or spaces (up to tabWidth)
+		// Remove leading tab or spaces (up to indentWidth)
 		if line[0] == '\t' {
 			e.appendUndo(action{kind: actionInsertRune, pos: Cursor{Row: row, Col: 0}, r: '\t'})
 			e.lines[row] = line[1:]
 			removed = 1
 		} else if line[0] == ' ' {
-			// Count spaces to remove (up to tabWidth)
-			for i := 0; i < e.tabWidth && i < len(line) && line[i] == ' '; i++ {
+			// Count spaces to remove (up to indentWidth)
+			for i := 0; i < e.indentWidth && i < len(line) && line[i] == ' '; i++ {
 				removed++
 			}
 			// Record undo for each space (backwards)
@@ -5475,7 +6252,6 @@ func (e *Editor) unindentSelection() {
 		}
 	}
 	e.finishUndoGroup()
-	e.lastEdit.Valid = false
 
 	// Adjust cursor column
 	if cursorLineRemoved > 0 {
@@ -5578,7 +6354,6 @@ func (e *Editor) deleteRuneAt(pos Cursor) bool {
 	if pos.Col < 0 || pos.Col >= len(line) {
 		return false
 	}
-	e.recordTextEdit(pos, Cursor{Row: pos.Row, Col: pos.Col + 1}, pos, 0)
 	copy(line[pos.Col:], line[pos.Col+1:])
 	line = line[:len(line)-1]
 	e.lines[pos.Row] = line
@@ -5598,7 +6373,6 @@ func (e *Editor) joinLineAt(pos Cursor) bool {
 	if pos.Col > len(left) {
 		pos.Col = len(left)
 	}
-	e.recordTextEdit(pos, Cursor{Row: pos.Row + 1, Col: 0}, pos, 0)
 	merged := append(left, right...)
 
 	newLines := make([][]rune, 0, len(e.lines)-1)
@@ -5640,7 +6414,7 @@ func (e *Editor) moveUp() {
 	if e.cursor.Row == 0 {
 		return
 	}
-	e.cursor.Row--
+	e.cursor.Row = max(e.prevVisibleRow(e.cursor.Row), 0)
 	e.clampCursorCol()
 	if e.mode == ModeInsert {
 		e.saveLineState()
@@ -5648,10 +6422,11 @@ func (e *Editor) moveUp() {
 }
 
 func (e *Editor) moveDown() {
-	if e.cursor.Row >= len(e.lines)-1 {
+	next := e.nextVisibleRow(e.cursor.Row)
+	if next >= len(e.lines) {
 		return
 	}
-	e.cursor.Row++
+	e.cursor.Row = next
 	e.clampCursorCol()
 	if e.mode == ModeInsert {
 		e.saveLineState()
@@ -5682,17 +6457,7 @@ func (e *Editor) moveWordLeft() {
 		e.cursor.Col = 0
 		return
 	}
-	if isWordRune(line[idx]) {
-		for idx > 0 && isWordRune(line[idx-1]) {
-			idx--
-		}
-		e.cursor.Col = idx
-		return
-	}
-	for idx > 0 && !isSpaceRune(line[idx-1]) && !isWordRune(line[idx-1]) {
-		idx--
-	}
-	e.cursor.Col = idx
+	e.cursor.Col = e.wordRuns(line).start(idx)
 }
 
 func (e *Editor) moveWordRight() {
@@ -5723,15 +6488,7 @@ func (e *Editor) moveWordRight() {
 		e.cursor.Col = idx
 		return
 	}
-	if isWordRune(line[idx]) {
-		for idx < len(line) && isWordRune(line[idx]) {
-			idx++
-		}
-	} else {
-		for idx < len(line) && !isSpaceRune(line[idx]) && !isWordRune(line[idx]) {
-			idx++
-		}
-	}
+	idx = e.wordRuns(line).end(idx)
 	for idx < len(line) && isSpaceRune(line[idx]) {
 		idx++
 	}
@@ -5750,6 +6507,55 @@ func (e *Editor) moveLineEnd() {
 	e.cursor.Col = len(e.lines[e.cursor.Row])
 }
 
+// moveLineEndNonBlank moves after the last character that isn't a space
+// or tab (gl), so trailing whitespace is left alone
+func (e *Editor) moveLineEndNonBlank() {
+	if e.cursor.Row < 0 || e.cursor.Row >= len(e.lines) {
+		e.cursor.Col = 0
+		return
+	}
+	e.cursor.Col = lastNonBlankEnd(e.lines[e.cursor.Row])
+}
+
+// smartHome moves to the first non-blank of the line (Home), or to the
+// line start when already there
+func (e *Editor) smartHome() {
+	if e.cursor.Row < 0 || e.cursor.Row >= len(e.lines) {
+		e.cursor.Col = 0
+		return
+	}
+	col := firstNonBlank(e.lines[e.cursor.Row])
+	if e.cursor.Col == col {
+		col = 0
+	}
+	e.cursor.Col = col
+}
+
+// smartEnd moves after the last non-blank of the line (End), or to the
+// line end when already there
+func (e *Editor) smartEnd() {
+	if e.cursor.Row < 0 || e.cursor.Row >= len(e.lines) {
+		e.cursor.Col = 0
+		return
+	}
+	line := e.lines[e.cursor.Row]
+	col := lastNonBlankEnd(line)
+	if e.cursor.Col == col {
+		col = len(line)
+	}
+	e.cursor.Col = col
+}
+
+// lastNonBlankEnd returns the column after the last character that isn't
+// a space or tab, 0 for a blank line
+func lastNonBlankEnd(line []rune) int {
+	col := len(line)
+	for col > 0 && (line[col-1] == ' ' || line[col-1] == '\t') {
+		col--
+	}
+	return col
+}
+
 func (e *Editor) moveFileStart() {
 	prevRow := e.cursor.Row
 	e.cursor.Row = 0
@@ -5864,19 +6670,16 @@ func (e *Editor) wordForward() {
 	}
 
 	// Remember if we started on a word (not punctuation)
-	startedOnWord := isWordRune(line[idx])
+	runs := e.wordRuns(line)
+	startedOnWord := runs.kind(idx) == runWord
 	wordEndIdx := idx
 
 	// Skip current word or punctuation
-	if isWordRune(line[idx]) {
-		for idx < len(line) && isWordRune(line[idx]) {
-			idx++
-		}
+	if startedOnWord {
+		idx = runs.end(idx)
 		wordEndIdx = idx - 1 // Last char of word
-	} else if !isSpaceRune(line[idx]) {
-		for idx < len(line) && !isSpaceRune(line[idx]) && !isWordRune(line[idx]) {
-			idx++
-		}
+	} else if runs.kind(idx) != runSpace {
+		idx = runs.end(idx)
 	}
 
 	// Check if there's whitespace before next word
@@ -5884,7 +6687,7 @@ func (e *Editor) wordForward() {
 
 	// Edge case: started on word, no whitespace, next char is punctuation
 	// In this case, behave like 'e' - stop at end of current word
-	if startedOnWord && !hasWhitespace && idx < len(line) && !isWordRune(line[idx]) {
+	if startedOnWord && !hasWhitespace && idx < len(line) && runs.kind(idx) == runPunct {
 		e.cursor.Col = wordEndIdx
 		return
 	}
@@ -5951,17 +6754,7 @@ func (e *Editor) wordBackward() {
 	}
 
 	// Find start of current word
-	if isWordRune(line[idx]) {
-		for idx > 0 && isWordRune(line[idx-1]) {
-			idx--
-		}
-	} else {
-		for idx > 0 && !isSpaceRune(line[idx-1]) && !isWordRune(line[idx-1]) {
-			idx--
-		}
-	}
-
-	e.cursor.Col = idx
+	e.cursor.Col = e.wordRuns(line).start(idx)
 }
 
 // Helix-style word end (e) - move to end of word
@@ -5996,16 +6789,8 @@ func (e *Editor) wordEnd() {
 	}
 
 	// Find end of word
-	if idx < len(line) {
-		if isWordRune(line[idx]) {
-			for idx < len(line)-1 && isWordRune(line[idx+1]) {
-				idx++
-			}
-		} else if !isSpaceRune(line[idx]) {
-			for idx < len(line)-1 && !isSpaceRune(line[idx+1]) && !isWordRune(line[idx+1]) {
-				idx++
-			}
-		}
+	if idx < len(line) && !isSpaceRune(line[idx]) {
+		idx = e.wordRuns(line).end(idx) - 1
 	}
 
 	e.cursor.Col = idx
@@ -6052,8 +6837,8 @@ func (e *Editor) findCharForward(ch rune, till bool) bool {
 		return false
 	}
 
-	// For brackets/quotes, search across lines
-	if isBracketOrQuote(ch) {
+	// For brackets/quotes, or any char with :findlines, search across lines
+	if e.findLines || isBracketOrQuote(ch) {
 		startRow := e.cursor.Row
 		startCol := e.cursor.Col + 1
 
@@ -6121,8 +6906,8 @@ func (e *Editor) findCharBackward(ch rune, till bool) bool {
 		return false
 	}
 
-	// For brackets/quotes, search across lines backwards
-	if isBracketOrQuote(ch) {
+	// For brackets/quotes, or any char with :findlines, search across lines backwards
+	if e.findLines || isBracketOrQuote(ch) {
 		startRow := e.cursor.Row
 		startCol := e.cursor.Col - 1
 
@@ -6184,60 +6969,86 @@ func (e *Editor) findCharBackward(ch rune, till bool) bool {
 	return false
 }
 
-// setPendingFindChar sets up pending char find (f/F/t/T)
-func (e *Editor) setPendingFindChar(action string) {
-	e.pendingAction = action
-}
-
-// handlePendingChar processes char input for pending action
-func (e *Editor) handlePendingChar(ch rune) bool {
-	action := e.pendingAction
-	e.pendingAction = ""
-
-	// For f, F, t, T - Helix style: anchor moves to old cursor, selection covers jump
-	isSelectingAction := action == actionFindChar || action == actionFindCharBackward ||
-		action == actionTillChar || action == actionTillCharBackward
-
-	anchor := e.cursor
-
-	var result bool
+// handlePendingChar completes a character action (f/F/t/T/r/mr) with ch.
+// Finds jump count times.
+func (e *Editor) handlePendingChar(action string, ch rune, count int) bool {
 	switch action {
 	case actionFindChar:
-		e.lastFindChar = ch
-		e.lastFindForward = true
-		e.lastFindTill = false
-		result = e.findCharForward(ch, false)
+		return e.findChar(ch, true, false, count)
 	case actionFindCharBackward:
-		e.lastFindChar = ch
-		e.lastFindForward = false
-		e.lastFindTill = false
-		result = e.findCharBackward(ch, false)
+		return e.findChar(ch, false, false, count)
 	case actionTillChar:
-		e.lastFindChar = ch
-		e.lastFindForward = true
-		e.lastFindTill = true
-		result = e.findCharForward(ch, true)
+		return e.findChar(ch, true, true, count)
 	case actionTillCharBackward:
-		e.lastFindChar = ch
-		e.lastFindForward = false
-		e.lastFindTill = true
-		result = e.findCharBackward(ch, true)
+		return e.findChar(ch, false, true, count)
 	case actionReplaceChar:
 		return e.replaceCharAtCursor(ch)
-	default:
+	case actionReplacePair:
+		return e.replaceMatchingPair(ch)
+	}
+	return false
+}
+
+// findChar runs an f/F/t/T search count times and remembers it for
+// repeat_find_char. Helix style: the anchor moves to the old cursor and
+// the selection covers the jump.
+func (e *Editor) findChar(ch rune, forward, till bool, count int) bool {
+	e.lastFindChar = ch
+	e.lastFindForward = forward
+	e.lastFindTill = till
+	return e.jumpToChar(ch, forward, till, count)
+}
+
+// repeatFindChar repeats the last f/F/t/T search count times, the other
+// way with reverse (Alt+. and Alt+,)
+func (e *Editor) repeatFindChar(reverse bool, count int) bool {
+	if e.lastFindChar == 0 {
+		e.setStatus("no previous find")
 		return false
 	}
+	return e.jumpToChar(e.lastFindChar, e.lastFindForward != reverse, e.lastFindTill, count)
+}
+
+// toggleFindLines lets f/t search past the current line for every char,
+// not just brackets and quotes (:findlines)
+func (e *Editor) toggleFindLines() {
+	e.setFindLines(!e.findLines)
+}
+
+func (e *Editor) setFindLines(on bool) {
+	e.findLines = on
+	if on {
+		e.setStatus("findlines on")
+	} else {
+		e.setStatus("findlines off")
+	}
+}
+
+func (e *Editor) jumpToChar(ch rune, forward, till bool, count int) bool {
+	anchor := e.cursor
+	found := false
+	for i := 0; i < max(count, 1); i++ {
+		var ok bool
+		if forward {
+			ok = e.findCharForward(ch, till)
+		} else {
+			ok = e.findCharBackward(ch, till)
+		}
+		if !ok {
+			break
+		}
+		found = true
+	}
 
 	// Set selection from anchor to new cursor position (inclusive of cursor char)
-	if isSelectingAction && anchor != e.cursor {
+	if anchor != e.cursor {
 		e.selectionActive = true
 		e.selectionStart = anchor
 		// Selection end is exclusive, so add 1 to include the character at cursor
 		e.selectionEnd = Cursor{Row: e.cursor.Row, Col: e.cursor.Col + 1}
 		e.selectMode = true
 	}
-
-	return result
+	return found
 }
 
 // Helix-style delete (d) - delete selection or char
@@ -6263,7 +7074,7 @@ func (e *Editor) helixChange() {
 	e.saveLineState()
 }
 
-// copyToSystemClipboard copies text to macOS clipboard using pbcopy
+// copyToSystemClipboard copies the yank register to the system clipboard
 func (e *Editor) copyToSystemClipboard() {
 	if len(e.clipboard) == 0 {
 		return
@@ -6275,9 +7086,7 @@ func (e *Editor) copyToSystemClipboard() {
 	}
 	text := strings.Join(lines, "\n")
 
-	cmd := exec.Command("pbcopy")
-	cmd.Stdin = strings.NewReader(text)
-	_ = cmd.Run()
+	_ = clipboard.Write(text)
 }
 
 // Helix-style yank (y) - copy selection to clipboard
@@ -6335,38 +7144,24 @@ func (e *Editor) pasteAfter() {
 
 	if len(e.clipboard) == 1 {
 		// Single line - paste inline after cursor
-		line := e.clipboard[0]
 		pos := Cursor{Row: e.cursor.Row, Col: e.cursor.Col + 1}
 		if pos.Col > len(e.lines[e.cursor.Row]) {
 			pos.Col = len(e.lines[e.cursor.Row])
 		}
-		for _, r := range line {
-			if e.insertRuneAt(pos, r) {
-				e.appendUndo(action{kind: actionDeleteRune, pos: pos, r: r})
-				pos.Col++
-			}
+		end := pos
+		if len(e.clipboard[0]) > 0 {
+			end = e.pasteText(pos, e.clipboard)
 		}
-		e.cursor.Col = pos.Col - 1
+		e.cursor.Col = end.Col - 1
 		if e.cursor.Col < 0 {
 			e.cursor.Col = 0
 		}
 	} else {
 		// Multi-line - paste lines below
-		for i, line := range e.clipboard {
-			newRow := e.cursor.Row + 1 + i
-			// Insert new line
-			if newRow > len(e.lines) {
-				newRow = len(e.lines)
-			}
-			newLines := make([][]rune, len(e.lines)+1)
-			copy(newLines, e.lines[:newRow])
-			newLines[newRow] = append([]rune(nil), line...)
-			copy(newLines[newRow+1:], e.lines[newRow:])
-			e.lines = newLines
-		}
+		pos := Cursor{Row: e.cursor.Row, Col: len(e.lines[e.cursor.Row])}
+		e.pasteText(pos, append([][]rune{nil}, e.clipboard...))
 		e.cursor.Row++
 		e.cursor.Col = 0
-		e.lastEdit.Valid = false
 	}
 }
 
@@ -6381,29 +7176,28 @@ func (e *Editor) pasteBefore() {
 
 	if len(e.clipboard) == 1 {
 		// Single line - paste inline at cursor
-		line := e.clipboard[0]
-		pos := e.cursor
-		for _, r := range line {
-			if e.insertRuneAt(pos, r) {
-				e.appendUndo(action{kind: actionDeleteRune, pos: pos, r: r})
-				pos.Col++
-			}
+		if len(e.clipboard[0]) > 0 {
+			e.cursor = e.pasteText(e.cursor, e.clipboard)
 		}
 	} else {
 		// Multi-line - paste lines above
-		for i, line := range e.clipboard {
-			newRow := e.cursor.Row + i
-			newLines := make([][]rune, len(e.lines)+1)
-			copy(newLines, e.lines[:newRow])
-			newLines[newRow] = append([]rune(nil), line...)
-			copy(newLines[newRow+1:], e.lines[newRow:])
-			e.lines = newLines
-		}
+		e.pasteText(Cursor{Row: e.cursor.Row}, append(e.clipboard[:len(e.clipboard):len(e.clipboard)], nil))
 		e.cursor.Col = 0
-		e.lastEdit.Valid = false
 	}
 }
 
+// pasteText inserts a copy of text at pos as one action of the current undo
+// group and records it as the last edit
+func (e *Editor) pasteText(pos Cursor, text [][]rune) Cursor {
+	lines := make([][]rune, len(text))
+	for i, line := range text {
+		lines[i] = append([]rune(nil), line...)
+	}
+	end := e.insertTextAt(pos, lines)
+	e.appendUndo(action{kind: actionDeleteText, pos: pos, endPos: end, text: lines})
+	return end
+}
+
 // Helix-style open below (o) - open line below and enter insert
 func (e *Editor) openBelow() {
 	e.insertLineBelow()
@@ -6428,17 +7222,23 @@ func (e *Editor) openAbove() {
 		}
 	}
 
-	// Insert new line above
-	newLines := make([][]rune, len(e.lines)+1)
-	copy(newLines, e.lines[:e.cursor.Row])
-	newLines[e.cursor.Row] = append([]rune(nil), indent...)
-	copy(newLines[e.cursor.Row+1:], e.lines[e.cursor.Row:])
-	e.lines = newLines
-
-	e.cursor.Col = len(indent)
+	// Split before the line and indent the new one above, as a group
+	e.startUndoGroup()
+	pos := Cursor{Row: e.cursor.Row}
+	if !e.splitLineAt(pos) {
+		return
+	}
+	e.appendUndo(action{kind: actionJoinLine, pos: pos})
+	e.cursor = pos
+	for _, r := range indent {
+		insertPos := e.cursor
+		if e.insertRuneAt(insertPos, r) {
+			e.appendUndo(action{kind: actionDeleteRune, pos: insertPos, r: r})
+		}
+	}
+	e.finishUndoGroup()
 	e.mode = ModeInsert
 	e.saveLineState()
-	e.lastEdit.Valid = false
 }
 
 // insertLineAboveCursor inserts an empty line at cursor position,
@@ -6461,7 +7261,7 @@ func (e *Editor) insertLineAboveCursor() {
 	// Build indentation: fill with tabs, then spaces for remainder
 	indent := make([]rune, 0)
 	col := 0
-	for col+tabWidth <= visualX {
+	for !e.expandTab && col+tabWidth <= visualX {
 		indent = append(indent, '\t')
 		col += tabWidth
 	}
@@ -6484,7 +7284,6 @@ func (e *Editor) insertLineAboveCursor() {
 
 	// Cursor stays at same row (now on the new indented line)
 	e.cursor.Col = len(indent)
-	e.lastEdit.Valid = false
 }
 
 // Helix-style append (a) - move right and enter insert
@@ -6506,13 +7305,7 @@ func (e *Editor) insertLineStart() {
 	if e.cursor.Row < 0 || e.cursor.Row >= len(e.lines) {
 		return
 	}
-	line := e.lines[e.cursor.Row]
-	// Find first non-whitespace
-	col := 0
-	for col < len(line) && (line[col] == ' ' || line[col] == '\t') {
-		col++
-	}
-	e.cursor.Col = col
+	e.cursor.Col = firstNonBlank(e.lines[e.cursor.Row])
 	e.mode = ModeInsert
 	e.saveLineState()
 }
@@ -6646,25 +7439,30 @@ func (e *Editor) ensureCursorVisible(viewHeight int) {
 	}
 	const margin = 5 // scroll when cursor is within 5 lines of edge
 
+	// Rows are counted in screen lines, so folded lines take no space
+	cursor := e.visibleIndex(e.cursor.Row)
+	scroll := e.visibleIndex(e.scroll)
+	defer func() { e.scroll = e.rowAtVisibleIndex(scroll) }()
+
 	// If cursor is far outside visible area, center it
-	if e.cursor.Row < e.scroll-1 || e.cursor.Row >= e.scroll+viewHeight+1 {
-		e.scroll = e.cursor.Row - viewHeight/2
-		if e.scroll < 0 {
-			e.scroll = 0
+	if cursor < scroll-1 || cursor >= scroll+viewHeight+1 {
+		scroll = cursor - viewHeight/2
+		if scroll < 0 {
+			scroll = 0
 		}
 		return
 	}
 	// Scroll when cursor approaches top edge (within margin)
-	if e.cursor.Row < e.scroll+margin {
-		e.scroll = e.cursor.Row - margin
-		if e.scroll < 0 {
-			e.scroll = 0
+	if cursor < scroll+margin {
+		scroll = cursor - margin
+		if scroll < 0 {
+			scroll = 0
 		}
 		return
 	}
 	// Scroll when cursor approaches bottom edge (within margin)
-	if e.cursor.Row >= e.scroll+viewHeight-margin {
-		e.scroll = e.cursor.Row - viewHeight + margin + 1
+	if cursor >= scroll+viewHeight-margin {
+		scroll = cursor - viewHeight + margin + 1
 	}
 }
 
@@ -6683,7 +7481,7 @@ func (e *Editor) ensureCursorVisibleHorizontal(viewWidth, gutterWidth int) {
 
 	var visualCursorCol int
 	if e.cursor.Row >= 0 && e.cursor.Row < len(e.lines) {
-		visualCursorCol = visualCol(e.lines[e.cursor.Row], e.cursor.Col, e.tabWidth)
+		visualCursorCol = e.displayCol(e.lines[e.cursor.Row], e.cursor.Col)
 	}
 
 	// Cursor position relative to scrollX
@@ -6734,19 +7532,47 @@ func (e *Editor) renderStatusline(s tcell.Screen, w, y int) {
 	if e.dirty {
 		dirty = "[*]"
 	}
+	if e.readOnly || e.fileReadOnly {
+		dirty += "[RO]"
+	}
+	if e.bom {
+		dirty += "[BOM]"
+	}
+	if e.preview {
+		dirty += "[preview]"
+	}
+	if e.ansiView {
+		dirty += "[ansi]"
+	}
+	if e.macroRecording {
+		dirty += "[rec]"
+	}
 
 	status := fmt.Sprintf(" %s | %s %s", mode, name, dirty)
 	if e.statusMessage != "" {
 		status = fmt.Sprintf(" %s | %s %s | %s ", mode, name, dirty, e.statusMessage)
 	}
 	row := e.cursor.Row + 1
-	col := 1
-	if e.cursor.Row >= 0 && e.cursor.Row < len(e.lines) {
-		col = visualCol(e.lines[e.cursor.Row], e.cursor.Col, e.tabWidth) + 1
-	}
+	col := e.statusColumn()
 
 	// Build right part, tracking branch position for styling
-	rightParts := []string{fmt.Sprintf(" Ln %d, Col %d", row, col)}
+	rightParts := []string{fmt.Sprintf(" Ln %d, %s", row, col)}
+	if path := e.jsonStatusPath(); path != "" {
+		rightParts = []string{" " + path, fmt.Sprintf("Ln %d, %s", row, col)}
+	}
+	if n := len(e.extraSelections()); n > 0 {
+		rightParts[0] = strings.TrimPrefix(rightParts[0], " ")
+		rightParts = append([]string{fmt.Sprintf(" %d sels", n+1)}, rightParts...)
+	}
+	if progress := e.todoProgress(); progress != "" {
+		rightParts[0] = strings.TrimPrefix(rightParts[0], " ")
+		rightParts = append([]string{" " + progress}, rightParts...)
+	}
+	segments := e.statusSegmentTexts()
+	segFirst := len(rightParts)
+	for _, seg := range segments {
+		rightParts = append(rightParts, seg.text)
+	}
 	branchText := ""
 	if e.gitBranch != "" {
 		branchText = formatGitBranch(e.gitBranchSymbol, e.gitBranch)
@@ -6757,11 +7583,29 @@ func (e *Editor) renderStatusline(s tcell.Screen, w, y int) {
 		layoutText = e.layoutName + " "
 		rightParts = append(rightParts, layoutText)
 	}
+	if taskText := e.taskStatus(time.Now()); taskText != "" {
+		rightParts[0] = strings.TrimPrefix(rightParts[0], " ")
+		rightParts = append([]string{" " + taskText}, rightParts...)
+		segFirst++
+	}
 	right := strings.Join(rightParts, " | ")
 
 	line := composeStatusLine(status, right, w)
 	lineStr := string(line)
 
+	// Plugin segments follow the position (and the task) in the right part
+	segStart := make([]int, len(segments))
+	if len(segments) > 0 {
+		x := len(line) - utf8.RuneCountInString(right)
+		for _, part := range rightParts[:segFirst] {
+			x += utf8.RuneCountInString(part) + len(" | ")
+		}
+		for i, seg := range segments {
+			segStart[i] = x
+			x += utf8.RuneCountInString(seg.text) + len(" | ")
+		}
+	}
+
 	// Find branch position in the composed line (using rune indices)
 	branchStart := -1
 	branchEnd := -1
@@ -6805,6 +7649,11 @@ func (e *Editor) renderStatusline(s tcell.Screen, w, y int) {
 			break
 		}
 		style := e.styleStatus
+		for i, seg := range segments {
+			if x >= segStart[i] && x < segStart[i]+utf8.RuneCountInString(seg.text) {
+				style = seg.style
+			}
+		}
 		if branchStart >= 0 && x >= branchStart && x < branchEnd {
 			style = branchStyle
 		} else if layoutStart >= 0 && x >= layoutStart && x < layoutEnd {
@@ -6827,11 +7676,9 @@ func (e *Editor) renderCommandline(s tcell.Screen, w, y int) int {
 		cmdRunes = append([]rune{prefix}, e.searchQuery...)
 
 		// Show match count on the right
-		if len(e.searchMatches) > 0 {
-			rightText = fmt.Sprintf(" [%d/%d] ", e.searchMatchIndex+1, len(e.searchMatches))
-		} else if len(e.searchQuery) > 0 {
-			rightText = " [no matches] "
-		}
+		rightText = e.searchCountText()
+	} else if e.mode == ModeCommand && e.cmdHistorySearch {
+		cmdRunes = e.historySearchLine()
 	} else if e.mode == ModeCommand {
 		cmdRunes = append([]rune{':'}, e.cmd...)
 	} else {
@@ -6845,9 +7692,9 @@ func (e *Editor) renderCommandline(s tcell.Screen, w, y int) int {
 	checkmarkPos := -1 // position of ✓ in rightRunes for green coloring
 
 	if rightText == "" {
-		if e.pendingKeys != "" {
+		if keys := e.PendingKeys(); keys != "" {
 			// Show pending keys while waiting for next key (e.g., "g", "f")
-			rightText = " " + e.pendingKeys + "_ "
+			rightText = " " + keys + "_ "
 		} else if showCopiedMessage {
 			// Show "copied [✓] | y"
 			rightText = " copied [✓] | y "
@@ -6880,7 +7727,9 @@ func (e *Editor) renderCommandline(s tcell.Screen, w, y int) int {
 
 	// Calculate cursor position
 	var cursorX int
-	if e.mode == ModeCommand {
+	if e.mode == ModeCommand && e.cmdHistorySearch {
+		cursorX = e.historySearchCursor()
+	} else if e.mode == ModeCommand {
 		cursorX = e.cmdCursor + 1 // +1 for ':' prefix
 	} else if e.mode == ModeSearch {
 		cursorX = e.searchCursor + 1 // +1 for '/' or '?' prefix
@@ -7254,27 +8103,11 @@ func (e *Editor) viewHeightCached() int {
 }
 
 func splitLines(data []byte) [][]rune {
-	text := strings.ReplaceAll(string(data), "\r\n", "\n")
-	parts := strings.Split(text, "\n")
-	lines := make([][]rune, len(parts))
-	for i, p := range parts {
-		lines[i] = []rune(p)
-	}
-	return lines
+	return core.SplitLines(string(data))
 }
-
-func joinLines(lines [][]rune) string {
-	if len(lines) == 0 {
-		return ""
-	}
-	var b strings.Builder
-	for i, line := range lines {
-		if i > 0 {
-			b.WriteByte('\n')
-		}
-		b.WriteString(string(line))
-	}
-	return b.String()
+
+func joinLines(lines [][]rune) string {
+	return core.JoinLines(lines)
 }
 
 func (e *Editor) Content() string {
@@ -7310,10 +8143,38 @@ func (e *Editor) GetSessionManager() *session.Manager {
 	return e.sessionManager
 }
 
+// DisableState turns off reading and writing of command/search history,
+// undo changelogs and the session file, so runs don't depend on or leave
+// behind state from other runs
+func (e *Editor) DisableState() {
+	e.noState = true
+	if e.sessionManager != nil {
+		e.sessionManager.Discard()
+		e.sessionManager = nil
+	}
+}
+
+// Aborted reports whether the editor was quit with :cq
+func (e *Editor) Aborted() bool {
+	return e.aborted
+}
+
 func (e *Editor) SetNodeStackFunc(fn NodeStackFunc) {
 	e.nodeStackFunc = fn
 }
 
+func (e *Editor) SetBracketPairFunc(fn BracketPairFunc) {
+	e.bracketPairFunc = fn
+}
+
+func (e *Editor) SetScopeWordsFunc(fn ScopeWordsFunc) {
+	e.scopeWordsFunc = fn
+}
+
+func (e *Editor) SetTaskMarkersFunc(fn TaskMarkersFunc) {
+	e.taskMarkersFunc = fn
+}
+
 func (e *Editor) SetLSPGotoFunc(fn LSPGotoFunc) {
 	e.lspGotoFunc = fn
 }
@@ -7337,19 +8198,16 @@ func (e *Editor) UpdateScroll() {
 	e.ensureCursorVisible(e.viewHeightCached())
 }
 
-func (e *Editor) ConsumeLastEdit() (TextEdit, bool) {
-	if !e.lastEdit.Valid {
-		return TextEdit{}, false
-	}
-	edit := e.lastEdit
-	e.lastEdit.Valid = false
-	return edit, true
-}
-
 func (e *Editor) LineCount() int {
 	return len(e.lines)
 }
 
+// Line returns the runes of row; with LineCount it makes the editor a
+// core.Text. The slice must not be modified.
+func (e *Editor) Line(row int) []rune {
+	return e.lines[row]
+}
+
 func (e *Editor) VisibleRange() (int, int) {
 	if len(e.lines) == 0 {
 		return 0, 0
@@ -7408,7 +8266,8 @@ func (e *Editor) expandSelection() {
 	}
 
 	// Get node stack at cursor position
-	stack := e.nodeStackFunc(e.filename, e.cursor.Row, e.cursor.Col)
+	col := textpos.RuneToByte(e.lineAt(e.cursor.Row), e.cursor.Col)
+	stack := e.nodeStackFunc(e.filename, e.cursor.Row, col)
 	if len(stack) == 0 {
 		e.setStatus("no syntax node at cursor")
 		return
@@ -7416,6 +8275,11 @@ func (e *Editor) expandSelection() {
 
 	// If no selection or selection changed, rebuild scope stack
 	if !e.selectionActive || len(e.selectionScopeStack) == 0 {
+		// Selection works in rune columns
+		for i, nr := range stack {
+			stack[i].StartCol = textpos.ByteToRune(e.lineAt(nr.StartRow), nr.StartCol)
+			stack[i].EndCol = textpos.ByteToRune(e.lineAt(nr.EndRow), nr.EndCol)
+		}
 		e.selectionScopeStack = stack
 		e.selectionScopeIndex = 0
 	}
@@ -7492,6 +8356,16 @@ func (e *Editor) selectionRangeForLine(lineIdx int) (int, int, bool) {
 	if !ok {
 		return 0, 0, false
 	}
+	return e.spanOnLine(start, end, lineIdx)
+}
+
+// colSpan is a range of columns on a line, end exclusive
+type colSpan struct {
+	start, end int
+}
+
+// spanOnLine returns the columns of line lineIdx inside start..end
+func (e *Editor) spanOnLine(start, end Cursor, lineIdx int) (int, int, bool) {
 	if lineIdx < start.Row || lineIdx > end.Row {
 		return 0, 0, false
 	}
@@ -7637,8 +8511,8 @@ func (e *Editor) ShowBranchPicker(branches []string, current string) {
 			}
 		}
 	}
-	e.branchPickerActive = true
 	e.mode = ModeBranchPicker
+	e.openPopup(branchPickerPopup{})
 }
 
 func (e *Editor) ConsumeBranchSelection() (string, bool) {
@@ -7659,11 +8533,8 @@ func (e *Editor) branchPickerPageSize() int {
 }
 
 func (e *Editor) closeBranchPicker(selection string) {
-	e.branchPickerActive = false
-	e.branchPickerItems = nil
-	e.branchPickerIndex = 0
-	e.mode = ModeNormal
 	e.branchPickerSelection = selection
+	e.closePopup(branchPickerPopup{})
 }
 
 // showRefsPicker shows the references/implementations picker
@@ -7671,13 +8542,13 @@ func (e *Editor) showRefsPicker(title string, items []LSPLocation) {
 	if len(items) == 0 {
 		return
 	}
-	e.refsPickerActive = true
 	e.refsPickerTitle = title
 	e.refsPickerItems = items
 	e.refsPickerIndex = 0
 	e.refsPickerFileCache = make(map[string][][]rune)
 	e.refsPickerHighlights = make(map[string]map[int][]HighlightSpan)
 	e.mode = ModeNormal
+	e.openPopup(refsPickerPopup{})
 }
 
 // closeRefsPicker closes the picker and optionally jumps to selected location
@@ -7690,14 +8561,10 @@ func (e *Editor) closeRefsPicker(jump bool) {
 			e.cursor.Col = loc.StartCol
 			e.ensureCursorVisible(e.viewHeightCached())
 		} else {
-			e.setStatus("cross-file: " + loc.Path + ":" + strconv.Itoa(loc.StartLine+1))
+			e.openFileAt(loc.Path, &loc)
 		}
 	}
-	e.refsPickerActive = false
-	e.refsPickerItems = nil
-	e.refsPickerIndex = 0
-	e.refsPickerFileCache = nil
-	e.refsPickerHighlights = nil
+	e.closePopup(refsPickerPopup{})
 }
 
 // refsPickerPageSize returns the number of items per page
@@ -7714,74 +8581,19 @@ func (e *Editor) swapLines(a, b int) bool {
 		return false
 	}
 	e.lines[a], e.lines[b] = e.lines[b], e.lines[a]
-	e.lastEdit.Valid = false
 	return true
 }
 
-func runeByteLen(r rune) int {
-	n := utf8.RuneLen(r)
-	if n < 1 {
-		return 1
-	}
-	return n
-}
-
-func runeSliceByteLen(rs []rune) int {
-	n := 0
-	for _, r := range rs {
-		n += runeByteLen(r)
+// lineAt returns the runes of row, or nil if row is outside the buffer
+func (e *Editor) lineAt(row int) []rune {
+	if row < 0 || row >= len(e.lines) {
+		return nil
 	}
-	return n
+	return e.lines[row]
 }
 
 func (e *Editor) byteOffset(pos Cursor) (int, int) {
-	row := pos.Row
-	if row < 0 {
-		row = 0
-	}
-	if row > len(e.lines) {
-		row = len(e.lines)
-	}
-	offset := 0
-	for i := 0; i < row && i < len(e.lines); i++ {
-		offset += runeSliceByteLen(e.lines[i]) + 1
-	}
-	if row >= len(e.lines) {
-		return offset, 0
-	}
-	line := e.lines[row]
-	col := pos.Col
-	if col < 0 {
-		col = 0
-	}
-	if col > len(line) {
-		col = len(line)
-	}
-	colBytes := runeSliceByteLen(line[:col])
-	offset += colBytes
-	return offset, colBytes
-}
-
-func (e *Editor) recordTextEdit(start, oldEnd, newEnd Cursor, insertedBytes int) {
-	startByte, startColBytes := e.byteOffset(start)
-	oldEndByte, oldEndColBytes := e.byteOffset(oldEnd)
-	newEndByte := startByte + insertedBytes
-	newEndColBytes := 0
-	if newEnd.Row == start.Row {
-		newEndColBytes = startColBytes + insertedBytes
-	}
-	e.lastEdit = TextEdit{
-		Valid:          true,
-		StartByte:      startByte,
-		OldEndByte:     oldEndByte,
-		NewEndByte:     newEndByte,
-		StartRow:       start.Row,
-		StartColBytes:  startColBytes,
-		OldEndRow:      oldEnd.Row,
-		OldEndColBytes: oldEndColBytes,
-		NewEndRow:      newEnd.Row,
-		NewEndColBytes: newEndColBytes,
-	}
+	return core.ByteOffset(e, pos)
 }
 
 func isWordRune(r rune) bool {
@@ -7792,17 +8604,42 @@ func isSpaceRune(r rune) bool {
 	return unicode.IsSpace(r)
 }
 
-func (e *Editor) drawLine(s tcell.Screen, y, w, startX int, line []rune, tabWidth int, selStart, selEnd int, spans []HighlightSpan, highlightActive bool, searchMatches []SearchMatch, lineIdx int, currentMatchIdx int, scrollX int) {
-	col := 0 // visual column (accounting for tabs)
-	if tabWidth < 1 {
-		tabWidth = 1
+// syntaxStyle returns the highlighting style of rune r at column idx of a
+// line with spans. In a highlighted file, words no span covers get the
+// unknown color.
+func (e *Editor) syntaxStyle(spans []HighlightSpan, highlightActive bool, idx int, r rune) tcell.Style {
+	if kind, ok := highlightKindAt(spans, idx); ok {
+		if style, ok := e.styleForHighlight(kind); ok {
+			return style
+		}
+	} else if highlightActive && !isWordRune(r) {
+		return e.styleMain
 	}
-	fallbackStyle := e.styleMain
 	if highlightActive {
-		fallbackStyle = e.styleSyntaxUnknown
+		return e.styleSyntaxUnknown
 	}
+	return e.styleMain
+}
 
+func (e *Editor) drawLine(s tcell.Screen, y, w, startX int, line []rune, tabWidth int, sel []colSpan, spans []HighlightSpan, highlightActive bool, searchMatches []SearchMatch, lineIdx int, currentMatchIdx int, scrollX int, ansi []ansiCell, conceal []concealCell) {
+	col := 0 // visual column (accounting for tabs)
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
 	for idx, r := range line {
+		// Escape sequences take no space in ANSI view
+		if ansi != nil && ansi[idx].hidden {
+			continue
+		}
+		// So do concealed markup characters
+		if conceal != nil {
+			if conceal[idx].hidden {
+				continue
+			}
+			if conceal[idx].repl != 0 {
+				r = conceal[idx].repl
+			}
+		}
 		// Calculate screen x from visual column and scrollX
 		x := startX + col - scrollX
 		if x >= w {
@@ -7810,13 +8647,9 @@ func (e *Editor) drawLine(s tcell.Screen, y, w, startX int, line []rune, tabWidt
 		}
 
 		// First determine the syntax-highlighted style
-		activeStyle := fallbackStyle
-		if kind, ok := highlightKindAt(spans, idx); ok {
-			if style, ok := e.styleForHighlight(kind); ok {
-				activeStyle = style
-			}
-		} else if highlightActive && !isWordRune(r) {
-			activeStyle = e.styleMain
+		activeStyle := e.syntaxStyle(spans, highlightActive, idx, r)
+		if ansi != nil {
+			activeStyle = ansi[idx].style.apply(e.styleMain)
 		}
 
 		// Check for search match highlight
@@ -7859,7 +8692,7 @@ func (e *Editor) drawLine(s tcell.Screen, y, w, startX int, line []rune, tabWidt
 			_, selBg, _ := e.styleSelection.Decompose()
 			fg, _, _ := activeStyle.Decompose()
 			activeStyle = activeStyle.Foreground(fg).Background(selBg)
-		} else if selStart >= 0 && selEnd > selStart && idx >= selStart && idx < selEnd {
+		} else if inColSpans(sel, idx) {
 			// Selection: only change background, keep syntax foreground
 			_, selBg, _ := e.styleSelection.Decompose()
 			fg, _, _ := activeStyle.Decompose()
@@ -7882,11 +8715,25 @@ func (e *Editor) drawLine(s tcell.Screen, y, w, startX int, line []rune, tabWidt
 		col++
 	}
 	// Clear rest of line
+	restStyle := e.styleMain
+	if highlightActive {
+		restStyle = e.styleSyntaxUnknown
+	}
 	for x := startX + col - scrollX; x < w; x++ {
 		if x >= startX {
-			s.SetContent(x, y, ' ', nil, fallbackStyle)
+			s.SetContent(x, y, ' ', nil, restStyle)
+		}
+	}
+}
+
+// inColSpans reports whether col is inside one of spans
+func inColSpans(spans []colSpan, col int) bool {
+	for _, sp := range spans {
+		if col >= sp.start && col < sp.end {
+			return true
 		}
 	}
+	return false
 }
 
 func clearLineAt(s tcell.Screen, x0, y, w int, style tcell.Style) {
@@ -8068,9 +8915,15 @@ func (e *Editor) drawLineWithGutterAt(s tcell.Screen, x0, y, w, gutterWidth, lin
 		if lineIdx == e.cursor.Row {
 			style = e.styleLineNumberActive
 		}
-		// Draw leading space
+		// Draw leading space, or a marker for lines with validation problems
 		if w > 0 {
-			s.SetContent(x0, y, ' ', nil, e.styleMain)
+			if p, ok := e.problemAt(lineIdx); ok {
+				s.SetContent(x0, y, '●', nil, e.diagnosticStyle(p.Severity))
+			} else if sign, ok := e.gutterSignAt(lineIdx); ok {
+				s.SetContent(x0, y, sign.Text, nil, e.styleMain.Foreground(sign.Color))
+			} else {
+				s.SetContent(x0, y, ' ', nil, e.styleMain)
+			}
 		}
 		// Draw number (right-aligned with leading spaces)
 		for i, r := range numStr {
@@ -8088,21 +8941,21 @@ func (e *Editor) drawLineWithGutterAt(s tcell.Screen, x0, y, w, gutterWidth, lin
 	if gutterWidth >= w {
 		return
 	}
-	selStart, selEnd, ok := e.selectionRangeForLine(lineIdx)
-	if !ok {
-		selStart = -1
-		selEnd = -1
-	}
+	sel := e.lineSelectionSpans(lineIdx)
 	highlightActive := e.highlightStart >= 0 && lineIdx >= e.highlightStart && lineIdx <= e.highlightEnd
 	var spans []HighlightSpan
 	if highlightActive {
 		spans = e.highlights[lineIdx]
 	}
-	e.drawLine(s, y, x0+w, x0+gutterWidth, e.lines[lineIdx], e.tabWidth, selStart, selEnd, spans, highlightActive, e.searchMatches, lineIdx, e.searchMatchIndex, e.scrollX)
+	e.drawLine(s, y, x0+w, x0+gutterWidth, e.lines[lineIdx], e.tabWidth, sel, spans, highlightActive, e.searchMatches, lineIdx, e.searchMatchIndex, e.scrollX, e.ansiCells(lineIdx), e.concealCells(lineIdx))
+	e.drawProblemUnderlines(s, y, x0+w, x0+gutterWidth, lineIdx)
+	if !e.drawFoldMarker(s, y, x0+w, x0+gutterWidth, lineIdx) {
+		e.drawInlineDiagnostic(s, y, x0+w, x0+gutterWidth, lineIdx)
+	}
 }
 
 func (e *Editor) renderBranchPicker(s tcell.Screen, w, viewHeight int) {
-	if !e.branchPickerActive || len(e.branchPickerItems) == 0 {
+	if len(e.branchPickerItems) == 0 {
 		return
 	}
 	if w < 6 || viewHeight < 3 {
@@ -8110,25 +8963,7 @@ func (e *Editor) renderBranchPicker(s tcell.Screen, w, viewHeight int) {
 	}
 	title := "Select git branch"
 	titleRunes := []rune(title)
-	titleWidth := len(titleRunes) + 2
-	maxItem := titleWidth
-	for _, name := range e.branchPickerItems {
-		l := len([]rune(name)) + 2 // "* " or "  " prefix for all branches
-		if l > maxItem {
-			maxItem = l
-		}
-	}
-	boxWidth := maxItem + 4
-	if boxWidth > w-2 {
-		boxWidth = w - 2
-	}
-	if boxWidth < 8 {
-		if w < 8 {
-			boxWidth = w
-		} else {
-			boxWidth = 8
-		}
-	}
+	boxWidth := e.branchPickerBoxWidth(w)
 	listHeight := viewHeight - 2
 	if listHeight < 1 {
 		return
@@ -8267,7 +9102,7 @@ func (e *Editor) renderBranchPicker(s tcell.Screen, w, viewHeight int) {
 }
 
 func (e *Editor) renderRefsSidebar(s tcell.Screen, sidebarWidth, viewHeight int) {
-	if !e.refsPickerActive || len(e.refsPickerItems) == 0 {
+	if !e.popupOpen(refsPickerPopup{}) || len(e.refsPickerItems) == 0 {
 		return
 	}
 
@@ -8365,261 +9200,6 @@ func (e *Editor) renderRefsSidebar(s tcell.Screen, sidebarWidth, viewHeight int)
 	}
 }
 
-func (e *Editor) renderSpaceMenu(s tcell.Screen, w, viewHeight int) {
-	if !e.spaceMenuActive {
-		return
-	}
-	if w < 20 || viewHeight < 5 {
-		return
-	}
-
-	// Find the maximum label width
-	maxLabelWidth := 0
-	for _, item := range SpaceMenuItems {
-		labelWidth := len(item.Label) + 6 // "x   Label"
-		if labelWidth > maxLabelWidth {
-			maxLabelWidth = labelWidth
-		}
-	}
-
-	// Box dimensions
-	boxWidth := maxLabelWidth + 4
-	if boxWidth > w-4 {
-		boxWidth = w - 4
-	}
-	innerWidth := boxWidth - 2
-
-	listHeight := len(SpaceMenuItems)
-	if listHeight > viewHeight-3 {
-		listHeight = viewHeight - 3
-	}
-	boxHeight := listHeight + 2
-
-	// Position at bottom right, above status line
-	x0 := w - boxWidth - 1
-	if x0 < 0 {
-		x0 = 0
-	}
-	y0 := viewHeight - boxHeight
-	if y0 < 0 {
-		y0 = 0
-	}
-
-	borderStyle := e.styleStatus
-	itemStyle := e.styleCommand
-	dimStyle := e.styleLineNumber // for unimplemented items
-
-	// Draw border
-	topLeft := '┌'
-	topRight := '┐'
-	bottomLeft := '└'
-	bottomRight := '┘'
-	hLine := '─'
-	vLine := '│'
-
-	// Top border with title
-	title := "Space"
-	titleRunes := []rune(title)
-
-	for x := 0; x < boxWidth; x++ {
-		ch := hLine
-		if x == 0 {
-			ch = topLeft
-		} else if x == boxWidth-1 {
-			ch = topRight
-		}
-		s.SetContent(x0+x, y0, ch, nil, borderStyle)
-	}
-
-	// Embed title in top border
-	if len(titleRunes)+2 <= boxWidth-2 {
-		for i, r := range titleRunes {
-			s.SetContent(x0+1+i, y0, r, nil, borderStyle)
-		}
-	}
-
-	// Bottom border
-	for x := 0; x < boxWidth; x++ {
-		ch := hLine
-		if x == 0 {
-			ch = bottomLeft
-		} else if x == boxWidth-1 {
-			ch = bottomRight
-		}
-		s.SetContent(x0+x, y0+boxHeight-1, ch, nil, borderStyle)
-	}
-
-	// Side borders and content
-	for y := 1; y < boxHeight-1; y++ {
-		s.SetContent(x0, y0+y, vLine, nil, borderStyle)
-		s.SetContent(x0+boxWidth-1, y0+y, vLine, nil, borderStyle)
-
-		// Clear interior
-		for x := 1; x < boxWidth-1; x++ {
-			s.SetContent(x0+x, y0+y, ' ', nil, itemStyle)
-		}
-	}
-
-	// Draw menu items
-	for i := 0; i < listHeight; i++ {
-		if i >= len(SpaceMenuItems) {
-			break
-		}
-		item := SpaceMenuItems[i]
-		lineY := y0 + 1 + i
-
-		// Choose style based on whether item is implemented
-		style := itemStyle
-		if !item.Implemented {
-			style = dimStyle
-		}
-
-		// Clear line
-		for x := 1; x < boxWidth-1; x++ {
-			s.SetContent(x0+x, lineY, ' ', nil, style)
-		}
-
-		// Format: " k   Label text"
-		keyStr := string(item.Key)
-		label := " " + keyStr + "   " + item.Label
-
-		runes := []rune(label)
-		if len(runes) > innerWidth {
-			runes = runes[:innerWidth]
-		}
-
-		for j, r := range runes {
-			s.SetContent(x0+1+j, lineY, r, nil, style)
-		}
-	}
-}
-
-// renderMenu renders a generic mode menu popup
-func (e *Editor) renderMenu(s tcell.Screen, w, viewHeight int, title string, items []SpaceMenuItem) {
-	if w < 20 || viewHeight < 5 {
-		return
-	}
-
-	// Find the maximum label width
-	maxLabelWidth := 0
-	for _, item := range items {
-		labelWidth := len(item.Label) + 6 // "x   Label"
-		if labelWidth > maxLabelWidth {
-			maxLabelWidth = labelWidth
-		}
-	}
-
-	// Box dimensions
-	boxWidth := maxLabelWidth + 4
-	if boxWidth > w-4 {
-		boxWidth = w - 4
-	}
-	innerWidth := boxWidth - 2
-
-	listHeight := len(items)
-	if listHeight > viewHeight-3 {
-		listHeight = viewHeight - 3
-	}
-	boxHeight := listHeight + 2
-
-	// Position at bottom right, above status line
-	x0 := w - boxWidth - 1
-	if x0 < 0 {
-		x0 = 0
-	}
-	y0 := viewHeight - boxHeight
-	if y0 < 0 {
-		y0 = 0
-	}
-
-	borderStyle := e.styleStatus
-	itemStyle := e.styleCommand
-	dimStyle := e.styleLineNumber
-
-	// Draw border
-	topLeft := '┌'
-	topRight := '┐'
-	bottomLeft := '└'
-	bottomRight := '┘'
-	hLine := '─'
-	vLine := '│'
-
-	// Top border with title
-	titleRunes := []rune(title)
-
-	for x := 0; x < boxWidth; x++ {
-		ch := hLine
-		if x == 0 {
-			ch = topLeft
-		} else if x == boxWidth-1 {
-			ch = topRight
-		}
-		s.SetContent(x0+x, y0, ch, nil, borderStyle)
-	}
-
-	// Embed title in top border
-	if len(titleRunes)+2 <= boxWidth-2 {
-		for i, r := range titleRunes {
-			s.SetContent(x0+1+i, y0, r, nil, borderStyle)
-		}
-	}
-
-	// Bottom border
-	for x := 0; x < boxWidth; x++ {
-		ch := hLine
-		if x == 0 {
-			ch = bottomLeft
-		} else if x == boxWidth-1 {
-			ch = bottomRight
-		}
-		s.SetContent(x0+x, y0+boxHeight-1, ch, nil, borderStyle)
-	}
-
-	// Side borders and content
-	for y := 1; y < boxHeight-1; y++ {
-		s.SetContent(x0, y0+y, vLine, nil, borderStyle)
-		s.SetContent(x0+boxWidth-1, y0+y, vLine, nil, borderStyle)
-
-		// Clear interior
-		for x := 1; x < boxWidth-1; x++ {
-			s.SetContent(x0+x, y0+y, ' ', nil, itemStyle)
-		}
-	}
-
-	// Draw menu items
-	for i := 0; i < listHeight; i++ {
-		if i >= len(items) {
-			break
-		}
-		item := items[i]
-		lineY := y0 + 1 + i
-
-		// Choose style based on whether item is implemented
-		style := itemStyle
-		if !item.Implemented {
-			style = dimStyle
-		}
-
-		// Clear line
-		for x := 1; x < boxWidth-1; x++ {
-			s.SetContent(x0+x, lineY, ' ', nil, style)
-		}
-
-		// Format: " k   Label text"
-		keyStr := string(item.Key)
-		label := " " + keyStr + "   " + item.Label
-
-		runes := []rune(label)
-		if len(runes) > innerWidth {
-			runes = runes[:innerWidth]
-		}
-
-		for j, r := range runes {
-			s.SetContent(x0+1+j, lineY, r, nil, style)
-		}
-	}
-}
-
 // renderKeybindingsHelp renders a help popup showing all keybindings
 func (e *Editor) renderKeybindingsHelp(s tcell.Screen, w, viewHeight int) {
 	if w < 40 || viewHeight < 10 {
@@ -8634,72 +9214,12 @@ func (e *Editor) renderKeybindingsHelp(s tcell.Screen, w, viewHeight int) {
 		group  string
 	}
 
-	// Action to group mapping
-	actionGroups := map[string]string{
-		// Navigation
-		"move_left": "Navigation", "move_right": "Navigation", "move_up": "Navigation", "move_down": "Navigation",
-		"word_left": "Navigation", "word_right": "Navigation", "word_forward": "Navigation", "word_backward": "Navigation", "word_end": "Navigation",
-		"line_start": "Navigation", "line_end": "Navigation", "file_start": "Navigation", "file_end": "Navigation",
-		"page_up": "Navigation", "page_down": "Navigation", "scroll_up": "Navigation", "scroll_down": "Navigation",
-		// Editing
-		"delete": "Editing", "change": "Editing", "yank": "Editing", "paste": "Editing", "paste_before": "Editing",
-		"open_below": "Editing", "open_above": "Editing", "append": "Editing", "append_line_end": "Editing",
-		"insert_line_start": "Editing", "join_lines": "Editing", "replace_char": "Editing", "delete_line": "Editing",
-		"indent": "Editing", "unindent": "Editing", "insert_line_above": "Editing",
-		// Selection
-		"toggle_select": "Selection", "extend_line": "Selection", "collapse_selection": "Selection", "select_all": "Selection",
-		// Search
-		"search_forward": "Search", "search_backward": "Search", "search_next": "Search", "search_prev": "Search",
-		"find_char": "Search", "find_char_backward": "Search", "till_char": "Search", "till_char_backward": "Search",
-		// Modes
-		"enter_insert": "Modes", "enter_command": "Modes", "goto_mode": "Modes", "match_mode": "Modes",
-		"view_mode": "Modes", "space_mode": "Modes",
-		// History
-		"undo": "History", "redo": "History",
-		// Other
-		"quit": "Other", "branch_picker": "Other", "toggle_line_numbers": "Other",
-	}
-
-	// Action descriptions
-	bindingDescs := map[string]string{
-		"move_left": "Move cursor left", "move_right": "Move cursor right",
-		"move_up": "Move cursor up", "move_down": "Move cursor down",
-		"word_left": "Move to previous word", "word_right": "Move to next word",
-		"word_forward": "Move to next word", "word_backward": "Move to previous word", "word_end": "Move to word end",
-		"line_start": "Move to line start", "line_end": "Move to line end",
-		"file_start": "Move to file start", "file_end": "Move to file end",
-		"page_up": "Page up", "page_down": "Page down",
-		"scroll_up": "Scroll up", "scroll_down": "Scroll down",
-		"enter_insert": "Enter insert mode", "enter_command": "Enter command mode",
-		"quit": "Quit editor", "undo": "Undo", "redo": "Redo",
-		"delete": "Delete selection", "change": "Change (delete + insert)",
-		"yank": "Yank (copy)", "paste": "Paste after", "paste_before": "Paste before",
-		"open_below": "Open line below", "open_above": "Open line above",
-		"append": "Append after cursor", "append_line_end": "Append at line end",
-		"insert_line_start": "Insert at line start", "join_lines": "Join lines",
-		"toggle_select": "Toggle select mode", "extend_line": "Extend to full line",
-		"collapse_selection": "Collapse selection", "select_all": "Select all",
-		"indent": "Indent", "unindent": "Unindent",
-		"goto_mode": "Goto mode (g)", "match_mode": "Match mode (m)", "view_mode": "View mode (z)", "space_mode": "Space menu",
-		"find_char": "Find char (f)", "find_char_backward": "Find char back (F)",
-		"till_char": "Till char (t)", "till_char_backward": "Till char back (T)",
-		"search_forward": "Search /", "search_backward": "Search ?",
-		"search_next": "Next match (n)", "search_prev": "Prev match (N)",
-		"replace_char": "Replace char (r)", "delete_line": "Delete line",
-		"branch_picker": "Branch picker", "insert_line_above": "Insert line above",
-		"toggle_line_numbers": "Toggle line numbers",
-	}
-
 	// Build bindings list grouped
 	var allBindings []keybinding
 	for key, action := range e.keymap.normal {
-		desc := bindingDescs[action]
-		if desc == "" {
-			desc = action
-		}
-		group := actionGroups[action]
-		if group == "" {
-			group = "Other"
+		desc, group := action, "Other"
+		if a := lookupAction(action); a != nil {
+			desc, group = a.desc, a.group
 		}
 		allBindings = append(allBindings, keybinding{key, action, desc, group})
 	}
@@ -9007,6 +9527,20 @@ func keyString(ev *tcell.EventKey) string {
 			return "ctrl+home"
 		case tcell.KeyEnd:
 			return "ctrl+end"
+		case tcell.KeyLeft:
+			return "ctrl+left"
+		case tcell.KeyRight:
+			return "ctrl+right"
+		case tcell.KeyUp:
+			return "ctrl+up"
+		case tcell.KeyDown:
+			return "ctrl+down"
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			return "ctrl+backspace"
+		case tcell.KeyDelete:
+			return "ctrl+del"
+		case tcell.KeyEnter:
+			return "ctrl+enter"
 		}
 	}
 	if ev.Modifiers()&tcell.ModMeta != 0 {
@@ -9236,6 +9770,12 @@ func keyStringDisplay(ev *tcell.EventKey) string {
 }
 
 func keyStringForMap(ev *tcell.EventKey, keymap map[string]string) string {
+	// Alt+character is only told apart from the plain key when bound
+	if ev.Modifiers()&tcell.ModAlt != 0 && ev.Key() == tcell.KeyRune {
+		if alt := "alt+" + string(ev.Rune()); keymap[alt] != "" {
+			return alt
+		}
+	}
 	if ev.Modifiers()&tcell.ModMeta != 0 {
 		switch ev.Key() {
 		case tcell.KeyHome:
@@ -167,6 +167,43 @@ func TestExecCommandWriteAndSave(t *testing.T) {
 	}
 }
 
+func TestExecCommandWriteReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ro.txt")
+	if err := os.WriteFile(path, []byte("orig"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	e := newTestEditor("")
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	e.SetReadOnly(true)
+	e.insertRune('x')
+	e.execCommand("w")
+	if data, _ := os.ReadFile(path); string(data) != "orig" {
+		t.Fatalf("read-only file was overwritten: %q", string(data))
+	}
+	if !e.dirty {
+		t.Fatalf("dirty = false, want true after refused write")
+	}
+	// Nor through another spelling of its path or a link to it
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, alias := range []string{dir + "/./ro.txt", link} {
+		e.execCommand("w! " + alias)
+		if data, _ := os.ReadFile(path); string(data) != "orig" {
+			t.Fatalf("read-only file was overwritten through %s", alias)
+		}
+	}
+	other := filepath.Join(dir, "copy.txt")
+	e.execCommand("w " + other)
+	if data, _ := os.ReadFile(other); string(data) != "xorig" {
+		t.Fatalf("copy contents = %q, want %q", string(data), "xorig")
+	}
+}
+
 func TestExecCommandQuitWithDirty(t *testing.T) {
 	e := newTestEditor("a")
 	e.insertRune('b')
@@ -0,0 +1,228 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kobzarvs/qedit/internal/platform/clipboard"
+)
+
+// exportRun is a piece of an exported line drawn in one style
+type exportRun struct {
+	style tcell.Style
+	text  string
+}
+
+// execExportCommand renders the selection, or the whole buffer, with the
+// theme's syntax colors as HTML or ANSI text (:export html|ansi [FILE]).
+// The result goes to FILE, or to the clipboard without one. An existing
+// FILE is only replaced with :export!.
+func (e *Editor) execExportCommand(args []string, force bool) {
+	if len(args) == 0 || len(args) > 2 || (args[0] != "html" && args[0] != "ansi") {
+		e.setStatus("usage: :export[!] html|ansi [FILE]")
+		return
+	}
+	_, lines := e.exportLines()
+	var text string
+	if args[0] == "html" {
+		text = e.exportHTML(lines)
+	} else {
+		text = exportANSI(lines)
+	}
+	count := strconv.Itoa(len(lines)) + " lines as " + args[0]
+	if len(args) == 2 {
+		if err := writeNewFile(args[1], []byte(text), force, "export!"); err != nil {
+			e.setStatus("export: " + err.Error())
+			return
+		}
+		e.setStatus("exported " + count + " to " + args[1])
+		return
+	}
+	if err := clipboard.Write(text); err != nil {
+		e.setStatus("export: clipboard unavailable")
+		return
+	}
+	e.setStatus("exported " + count + " to clipboard")
+}
+
+// writeNewFile writes data to path, refusing to replace an existing file
+// unless force is set; bang is the command that forces it
+func writeNewFile(path string, data []byte, force bool, bang string) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s exists (:%s overwrites it)", path, bang)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportLines splits the selection, or the buffer, into runs of the same
+// syntax style and returns them with the row of the first line. Tabs are
+// expanded to the buffer's tab width.
+func (e *Editor) exportLines() (int, [][]exportRun) {
+	from, to := Cursor{}, Cursor{Row: len(e.lines) - 1}
+	if to.Row >= 0 {
+		to.Col = len(e.lines[to.Row])
+	}
+	if start, end, ok := e.selectionRange(); ok {
+		from, to = start, end
+		if to.Col == 0 && to.Row > from.Row {
+			to.Row--
+			to.Col = len(e.lines[to.Row])
+		}
+	}
+	tabWidth := max(e.tabWidth, 1)
+	var out [][]exportRun
+	for row := from.Row; row <= to.Row; row++ {
+		line := e.lines[row]
+		startCol, endCol := 0, len(line)
+		if row == from.Row {
+			startCol = min(from.Col, len(line))
+		}
+		if row == to.Row {
+			endCol = min(to.Col, len(line))
+		}
+		active := e.highlightStart >= 0 && row >= e.highlightStart && row <= e.highlightEnd
+		spans := e.highlights[row]
+		var runs []exportRun
+		var sb strings.Builder
+		var style tcell.Style
+		col := 0
+		for idx := startCol; idx < endCol; idx++ {
+			r := line[idx]
+			st := e.syntaxStyle(spans, active, idx, r)
+			if idx > startCol && st != style {
+				runs = append(runs, exportRun{style, sb.String()})
+				sb.Reset()
+			}
+			style = st
+			if r == '\t' {
+				n := tabWidth - col%tabWidth
+				sb.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			sb.WriteRune(r)
+			col++
+		}
+		if sb.Len() > 0 {
+			runs = append(runs, exportRun{style, sb.String()})
+		}
+		out = append(out, runs)
+	}
+	return from.Row, out
+}
+
+// exportHTML renders lines as a <pre> block in the theme's colors, with
+// inline styles so it pastes anywhere
+func (e *Editor) exportHTML(lines [][]exportRun) string {
+	fg, bg, _ := e.styleMain.Decompose()
+	var sb strings.Builder
+	sb.WriteString("<pre style=\"")
+	if hex, ok := cssColor(fg); ok {
+		sb.WriteString("color:" + hex + ";")
+	}
+	if hex, ok := cssColor(bg); ok {
+		sb.WriteString("background-color:" + hex + ";")
+	}
+	sb.WriteString("\">")
+	for i, runs := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		for _, run := range runs {
+			text := html.EscapeString(run.text)
+			css := runCSS(run.style, fg)
+			if css == "" {
+				sb.WriteString(text)
+				continue
+			}
+			sb.WriteString("<span style=\"" + css + "\">" + text + "</span>")
+		}
+	}
+	sb.WriteString("</pre>\n")
+	return sb.String()
+}
+
+// runCSS returns the inline style of a run, empty when it looks like the
+// surrounding text in color main
+func runCSS(style tcell.Style, main tcell.Color) string {
+	fg, _, attr := style.Decompose()
+	var css []string
+	if hex, ok := cssColor(fg); ok && fg != main {
+		css = append(css, "color:"+hex)
+	}
+	if attr&tcell.AttrBold != 0 {
+		css = append(css, "font-weight:bold")
+	}
+	if attr&tcell.AttrItalic != 0 {
+		css = append(css, "font-style:italic")
+	}
+	if attr&tcell.AttrUnderline != 0 {
+		css = append(css, "text-decoration:underline")
+	}
+	return strings.Join(css, ";")
+}
+
+// cssColor formats c as #rrggbb, reporting false for the terminal's
+// default color
+func cssColor(c tcell.Color) (string, bool) {
+	hex := c.Hex()
+	if hex < 0 {
+		return "", false
+	}
+	return fmt.Sprintf("#%06x", hex), true
+}
+
+// exportANSI renders lines with 24-bit color escape sequences, each line
+// ending with a reset so it can be pasted line by line
+func exportANSI(lines [][]exportRun) string {
+	var sb strings.Builder
+	for _, runs := range lines {
+		for _, run := range runs {
+			sb.WriteString(ansiSGR(run.style))
+			sb.WriteString(run.text)
+		}
+		if len(runs) > 0 {
+			sb.WriteString("\x1b[0m")
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// ansiSGR returns the escape sequence that switches to style, starting
+// from a reset so attributes of the previous run don't carry over
+func ansiSGR(style tcell.Style) string {
+	fg, _, attr := style.Decompose()
+	codes := []string{"0"}
+	if attr&tcell.AttrBold != 0 {
+		codes = append(codes, "1")
+	}
+	if attr&tcell.AttrItalic != 0 {
+		codes = append(codes, "3")
+	}
+	if attr&tcell.AttrUnderline != 0 {
+		codes = append(codes, "4")
+	}
+	if r, g, b := fg.RGB(); r >= 0 {
+		codes = append(codes, fmt.Sprintf("38;2;%d;%d;%d", r, g, b))
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
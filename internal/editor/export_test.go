@@ -0,0 +1,85 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestExportHTML(t *testing.T) {
+	e := newTestEditor("func a() {", "\treturn \"<b>\"", "}")
+	e.styleMain = tcell.StyleDefault.Foreground(tcell.NewHexColor(0xdddddd)).Background(tcell.NewHexColor(0x101010))
+	e.styleSyntaxKeyword = e.styleMain.Foreground(tcell.NewHexColor(0xff0000)).Bold(true)
+	e.styleSyntaxString = e.styleMain.Foreground(tcell.NewHexColor(0x00ff00))
+	e.styleSyntaxUnknown = e.styleMain
+	e.tabWidth = 4
+	e.SetHighlights(0, 2, map[int][]HighlightSpan{
+		0: {{StartCol: 0, EndCol: 4, Kind: "keyword"}},
+		1: {{StartCol: 1, EndCol: 7, Kind: "keyword"}, {StartCol: 8, EndCol: 13, Kind: "string"}},
+	})
+
+	path := filepath.Join(t.TempDir(), "out.html")
+	e.execCommand("export html " + path)
+	if e.statusMessage != "exported 3 lines as html to "+path {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<pre style="color:#dddddd;background-color:#101010;">` +
+		`<span style="color:#ff0000;font-weight:bold">func</span> a() {` + "\n" +
+		`    <span style="color:#ff0000;font-weight:bold">return</span> <span style="color:#00ff00">&#34;&lt;b&gt;&#34;</span>` + "\n" +
+		"}</pre>\n"
+	if string(data) != want {
+		t.Fatalf("html =\n%s\nwant\n%s", data, want)
+	}
+	// An existing file is only replaced with :export!
+	e.execCommand("export ansi " + path)
+	if e.statusMessage != "export: "+path+" exists (:export! overwrites it)" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.execCommand("export! ansi " + path)
+	if data, _ := os.ReadFile(path); strings.HasPrefix(string(data), "<pre") {
+		t.Fatal(":export! kept the old file")
+	}
+}
+
+func TestExportANSISelection(t *testing.T) {
+	e := newTestEditor("var x = 1", "var y = 2")
+	e.styleMain = tcell.StyleDefault
+	e.styleSyntaxUnknown = tcell.StyleDefault
+	e.styleSyntaxKeyword = tcell.StyleDefault.Foreground(tcell.NewHexColor(0x0000ff))
+	e.SetHighlights(0, 1, map[int][]HighlightSpan{
+		0: {{StartCol: 0, EndCol: 3, Kind: "keyword"}},
+		1: {{StartCol: 0, EndCol: 3, Kind: "keyword"}},
+	})
+	// Select "var x" only
+	e.selectionActive = true
+	e.selectionStart = Cursor{Row: 0, Col: 0}
+	e.selectionEnd = Cursor{Row: 0, Col: 5}
+
+	path := filepath.Join(t.TempDir(), "out.ansi")
+	e.execCommand("export ansi " + path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\x1b[0;38;2;0;0;255mvar\x1b[0m x\x1b[0m\n"
+	if string(data) != want {
+		t.Fatalf("ansi = %q, want %q", data, want)
+	}
+}
+
+func TestExportUsage(t *testing.T) {
+	e := newTestEditor("x")
+	for _, cmd := range []string{"export", "export pdf", "export html a b"} {
+		e.execCommand(cmd)
+		if !strings.HasPrefix(e.statusMessage, "usage: :export") {
+			t.Errorf("%s: status = %q", cmd, e.statusMessage)
+		}
+	}
+}
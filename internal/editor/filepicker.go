@@ -0,0 +1,180 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// filePicker is the state of the file picker (Space f): the files of the
+// project and those the typed query matches
+type filePicker struct {
+	root    string
+	files   []string
+	query   []rune
+	matches []string
+	index   int
+}
+
+// projectDir returns the project root, or the working directory when no
+// project was opened
+func (e *Editor) projectDir() (string, error) {
+	if e.projectRoot != "" {
+		return e.projectRoot, nil
+	}
+	return os.Getwd()
+}
+
+// fileListFlags returns whether file lists show dotfiles and ignored
+// files: as the file tree shows them once it was opened, else as configured
+func (e *Editor) fileListFlags() (hidden, ignored bool) {
+	if e.sidebarFiles != nil {
+		return e.sidebarFiles.showHidden, e.sidebarFiles.showIgnored
+	}
+	return e.fileTreeShowHidden, e.fileTreeShowIgnored
+}
+
+// openFilePicker lists the files of the project, or of the working
+// directory with cwd, leaving out ignored ones like the file tree does
+func (e *Editor) openFilePicker(cwd bool) {
+	root, err := e.projectDir()
+	if cwd {
+		root, err = os.Getwd()
+	}
+	if err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	hidden, ignored := e.fileListFlags()
+	files := projectFiles(root, hidden, ignored, e.ignorePatterns)
+	if len(files) == 0 {
+		e.setStatus("no files in " + root)
+		return
+	}
+	e.filePicker = filePicker{root: root, files: files, matches: files}
+	e.openPopup(filePickerPopup{})
+}
+
+// filter keeps the files whose path has the query's characters in order,
+// ignoring case
+func (p *filePicker) filter() {
+	p.matches = p.matches[:0:0]
+	for _, file := range p.files {
+		if matchesInOrder(p.query, file) {
+			p.matches = append(p.matches, file)
+		}
+	}
+	p.index = 0
+}
+
+// matchesInOrder reports whether the runes of query appear in text in
+// order, ignoring case
+func matchesInOrder(query []rune, text string) bool {
+	i := 0
+	for _, r := range text {
+		if i == len(query) {
+			break
+		}
+		if unicode.ToLower(r) == unicode.ToLower(query[i]) {
+			i++
+		}
+	}
+	return i == len(query)
+}
+
+// openFileAt opens path through the open-file request and moves the
+// cursor to loc once it is loaded; loc columns are UTF-16 like LSP ones.
+// Unsaved changes have to be written first.
+func (e *Editor) openFileAt(path string, loc *LSPLocation) {
+	if bufferKey(path) == bufferKey(e.filename) {
+		if loc != nil {
+			e.moveToLocation(*loc)
+		}
+		return
+	}
+	if e.dirty {
+		e.setStatus("unsaved changes (use :w first)")
+		return
+	}
+	e.openFileRequest = path
+	e.openFileLocation = loc
+}
+
+// moveToLocation puts the cursor at the start of loc in the open file
+func (e *Editor) moveToLocation(loc LSPLocation) {
+	e.cursor.Row = clampRange(loc.StartLine, 0, len(e.lines)-1)
+	e.cursor.Col = textpos.UTF16ToRune(e.lineAt(e.cursor.Row), loc.StartCol)
+	e.ensureCursorVisible(e.viewHeightCached())
+}
+
+// filePickerPopup is the file picker. Typed text narrows the list,
+// Up/Down (Ctrl-P/Ctrl-N) select and Enter opens the file.
+type filePickerPopup struct{}
+
+func (filePickerPopup) handleKey(e *Editor, ev *tcell.EventKey) bool {
+	p := &e.filePicker
+	switch ev.Key() {
+	case tcell.KeyDown, tcell.KeyCtrlN:
+		if len(p.matches) > 0 {
+			p.index = (p.index + 1) % len(p.matches)
+		}
+	case tcell.KeyUp, tcell.KeyCtrlP:
+		if len(p.matches) > 0 {
+			p.index = (p.index + len(p.matches) - 1) % len(p.matches)
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.filter()
+		}
+	case tcell.KeyEnter:
+		if len(p.matches) == 0 {
+			return true
+		}
+		path := filepath.Join(p.root, filepath.FromSlash(p.matches[p.index]))
+		e.closePopup(filePickerPopup{})
+		e.openFileAt(path, nil)
+	case tcell.KeyRune:
+		p.query = append(p.query, ev.Rune())
+		p.filter()
+	}
+	return true
+}
+
+func (filePickerPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	p := e.filePicker
+	if w < 6 {
+		return
+	}
+	width := min(maxFloatWidth, w-4)
+	// The query line is as wide as the box can get, so it doesn't jump while typing
+	query := truncateRunes([]rune("> "+string(p.query)), width)
+	query = append(query, []rune(strings.Repeat(" ", width-len(query)))...)
+	lines := [][]rune{query}
+	styles := []tcell.Style{e.styleAutoComplete}
+	if len(p.matches) == 0 {
+		lines = append(lines, []rune("no matching files"))
+		styles = append(styles, e.styleAutoCompleteDescription)
+	}
+	first := max(0, min(p.index-maxCompletionRows/2, len(p.matches)-maxCompletionRows))
+	last := min(first+maxCompletionRows, len(p.matches))
+	for i := first; i < last; i++ {
+		lines = append(lines, truncateRunes([]rune(p.matches[i]), width))
+		style := e.styleAutoComplete
+		if i == p.index {
+			style = e.styleSelection
+		}
+		styles = append(styles, style)
+	}
+	e.drawCursorFloat(s, w, viewHeight, lines, styles)
+}
+
+func (filePickerPopup) dismissed(e *Editor) {
+	e.filePicker = filePicker{}
+}
+
+func (filePickerPopup) modal() bool { return true }
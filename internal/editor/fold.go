@@ -0,0 +1,265 @@
+package editor
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Quick folds hide a block of comment lines or a multi-line string
+// literal (a license header, embedded SQL) behind its first line. They
+// don't need a syntax tree: blocks are found from the comment and string
+// delimiters of the file type. Moving the cursor into a fold opens it,
+// and edits move the folds with the text, dropping those whose lines
+// they split or join.
+
+// lineFold hides lines start+1..end behind line start
+type lineFold struct {
+	start, end int
+}
+
+// toggleQuickFold folds the comment block or string around the cursor,
+// or opens the fold the cursor is on
+func (e *Editor) toggleQuickFold() {
+	e.checkFolds()
+	row := e.cursor.Row
+	for i, f := range e.folds {
+		if f.start == row {
+			e.folds = append(e.folds[:i], e.folds[i+1:]...)
+			e.setStatus("unfolded " + strconv.Itoa(f.end-f.start+1) + " lines")
+			return
+		}
+	}
+	f, ok := e.quickFoldAt(row)
+	if !ok {
+		e.setStatus("no comment block or string to fold")
+		return
+	}
+	// A new fold replaces the folds inside it
+	kept := e.folds[:0]
+	for _, old := range e.folds {
+		if old.end < f.start || old.start > f.end {
+			kept = append(kept, old)
+		}
+	}
+	at := 0
+	for at < len(kept) && kept[at].start < f.start {
+		at++
+	}
+	e.folds = append(kept[:at], append([]lineFold{f}, kept[at:]...)...)
+	e.clearSelection()
+	e.cursor = Cursor{Row: f.start, Col: firstNonBlank(e.lines[f.start])}
+	e.setStatus("folded " + strconv.Itoa(f.end-f.start+1) + " lines")
+}
+
+// quickFoldAt finds the block to fold around row: a run of line comments,
+// or else the innermost block comment or string spanning several lines
+func (e *Editor) quickFoldAt(row int) (lineFold, bool) {
+	prefix, _ := lineCommentTokens(e.filename)
+	isComment := func(r int) bool {
+		return strings.HasPrefix(strings.TrimLeft(string(e.lines[r]), " \t"), prefix)
+	}
+	if isComment(row) {
+		f := lineFold{start: row, end: row}
+		for f.start > 0 && isComment(f.start-1) {
+			f.start--
+		}
+		for f.end < len(e.lines)-1 && isComment(f.end+1) {
+			f.end++
+		}
+		if f.end > f.start {
+			return f, true
+		}
+	}
+	best, found := lineFold{}, false
+	for _, f := range e.delimitedBlocks(foldDelimiters(e.filename)) {
+		if f.start <= row && row <= f.end && (!found || f.end-f.start < best.end-best.start) {
+			best, found = f, true
+		}
+	}
+	return best, found
+}
+
+// foldDelimiters returns the open/close pairs of block comments and
+// multi-line strings for a file, by its extension
+func foldDelimiters(filename string) [][2]string {
+	switch filepath.Ext(filename) {
+	case ".go", ".js", ".ts":
+		return [][2]string{{"/*", "*/"}, {"`", "`"}}
+	case ".py", ".toml":
+		return [][2]string{{`"""`, `"""`}, {"'''", "'''"}}
+	case ".html", ".xml":
+		return [][2]string{{"<!--", "-->"}}
+	case ".lua":
+		return [][2]string{{"--[[", "]]"}, {"[[", "]]"}}
+	case ".sh", ".bash", ".zsh", ".yaml", ".yml", ".rb", ".vim":
+		return nil
+	}
+	return [][2]string{{"/*", "*/"}}
+}
+
+// delimitedBlocks scans the buffer for the delimiter pairs and returns
+// the blocks that span more than one line. Delimiters are matched as
+// text, without looking at escapes or at what encloses them.
+func (e *Editor) delimitedBlocks(pairs [][2]string) []lineFold {
+	var blocks []lineFold
+	open, startRow := -1, 0
+	for row, line := range e.lines {
+		text := string(line)
+		for pos := 0; pos < len(text); {
+			if open >= 0 {
+				i := strings.Index(text[pos:], pairs[open][1])
+				if i < 0 {
+					break
+				}
+				if row > startRow {
+					blocks = append(blocks, lineFold{start: startRow, end: row})
+				}
+				pos += i + len(pairs[open][1])
+				open = -1
+				continue
+			}
+			first, at := -1, len(text)
+			for p, pair := range pairs {
+				if i := strings.Index(text[pos:], pair[0]); i >= 0 && pos+i < at {
+					first, at = p, pos+i
+				}
+			}
+			if first < 0 {
+				break
+			}
+			open, startRow = first, row
+			pos = at + len(pairs[first][0])
+		}
+	}
+	return blocks
+}
+
+// shiftFolds moves the folds through a buffer edit. A fold after the edit
+// moves by the lines it added or removed; one the edit reaches into stays
+// only while its line breaks are untouched.
+func (e *Editor) shiftFolds(c TextChange) {
+	if len(e.folds) == 0 {
+		return
+	}
+	start, end := c.Range.Start, c.Range.End
+	newEnd := textEnd(start, splitLines([]byte(c.NewText)))
+	delta := newEnd.Row - end.Row
+	kept := e.folds[:0]
+	for _, f := range e.folds {
+		switch {
+		case start.Row > f.end:
+		case !cursorLess(Cursor{Row: f.start}, end):
+			f.start += delta
+			f.end += delta
+		case start.Row != end.Row || newEnd.Row != start.Row:
+			continue
+		}
+		kept = append(kept, f)
+	}
+	e.folds = kept
+}
+
+// checkFolds drops folds past the end of the buffer, in case its text was
+// replaced without reporting the change
+func (e *Editor) checkFolds() {
+	for len(e.folds) > 0 && e.folds[len(e.folds)-1].end >= len(e.lines) {
+		e.folds = e.folds[:len(e.folds)-1]
+	}
+}
+
+// foldHiding returns the index of the fold hiding row, or -1
+func (e *Editor) foldHiding(row int) int {
+	e.checkFolds()
+	for i, f := range e.folds {
+		if f.start < row && row <= f.end {
+			return i
+		}
+	}
+	return -1
+}
+
+// foldAt returns the fold whose first line is row
+func (e *Editor) foldAt(row int) (lineFold, bool) {
+	e.checkFolds()
+	for _, f := range e.folds {
+		if f.start == row {
+			return f, true
+		}
+	}
+	return lineFold{}, false
+}
+
+// revealCursor opens the fold hiding the cursor line, after a jump, a
+// search or an edit put the cursor there
+func (e *Editor) revealCursor() {
+	if i := e.foldHiding(e.cursor.Row); i >= 0 {
+		e.folds = append(e.folds[:i], e.folds[i+1:]...)
+	}
+}
+
+// nextVisibleRow and prevVisibleRow step over folded lines. nextVisibleRow
+// returns len(e.lines) past the last line.
+func (e *Editor) nextVisibleRow(row int) int {
+	if f, ok := e.foldAt(row); ok {
+		return f.end + 1
+	}
+	return row + 1
+}
+
+func (e *Editor) prevVisibleRow(row int) int {
+	if i := e.foldHiding(row - 1); i >= 0 {
+		return e.folds[i].start
+	}
+	return row - 1
+}
+
+// visibleIndex returns the screen line of row counted from the top of
+// the buffer, with folded lines taking no space. A folded row counts as
+// its fold's first line.
+func (e *Editor) visibleIndex(row int) int {
+	e.checkFolds()
+	index := row
+	for _, f := range e.folds {
+		switch {
+		case f.end < row:
+			index -= f.end - f.start
+		case f.start < row:
+			index -= row - f.start
+		}
+	}
+	return index
+}
+
+// rowAtVisibleIndex is the inverse of visibleIndex
+func (e *Editor) rowAtVisibleIndex(index int) int {
+	e.checkFolds()
+	row := index
+	for _, f := range e.folds {
+		if f.start >= row {
+			break
+		}
+		row += f.end - f.start
+	}
+	return row
+}
+
+// drawFoldMarker draws the count of folded lines after the first line of
+// a fold and reports whether it did
+func (e *Editor) drawFoldMarker(s tcell.Screen, y, w, startX, row int) bool {
+	f, ok := e.foldAt(row)
+	if !ok {
+		return false
+	}
+	line := e.lines[row]
+	x := max(startX+visualCol(line, len(line), e.tabWidth)-e.scrollX, startX) + 1
+	marker := []rune("⋯ " + strconv.Itoa(f.end-f.start) + " more lines")
+	style := e.styleLineNumber.Dim(true)
+	for _, r := range truncateRunes(marker, w-x) {
+		s.SetContent(x, y, r, nil, style)
+		x++
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func newFoldTestEditor() *Editor {
+	e := newTestEditor(
+		"// Copyright 2024 The Authors.",
+		"// Licensed under the MIT license.",
+		"// See LICENSE for details.",
+		"package main",
+		"",
+		"const q = `",
+		"SELECT id",
+		"FROM users",
+		"`",
+		"func main() {}",
+	)
+	e.filename = "main.go"
+	return e
+}
+
+func TestQuickFoldCommentBlock(t *testing.T) {
+	e := newFoldTestEditor()
+	e.cursor = Cursor{Row: 1, Col: 3}
+	e.HandleKey(eventForKeyString(t, "alt+z"))
+	if len(e.folds) != 1 || e.folds[0] != (lineFold{start: 0, end: 2}) {
+		t.Fatalf("folds = %v, want lines 0-2", e.folds)
+	}
+	if e.cursor.Row != 0 {
+		t.Fatalf("cursor row = %d, want the fold's first line", e.cursor.Row)
+	}
+	_, rows := renderRows(t, e, 60, 6)
+	if !strings.Contains(rows[0], "⋯ 2 more lines") {
+		t.Fatalf("fold line = %q, want a count of hidden lines", rows[0])
+	}
+	if !strings.Contains(rows[1], "package main") {
+		t.Fatalf("line after fold = %q, want package main", rows[1])
+	}
+
+	e.HandleKey(keyRune('j'))
+	if e.cursor.Row != 3 {
+		t.Fatalf("j from fold: row = %d, want 3", e.cursor.Row)
+	}
+	e.HandleKey(keyRune('k'))
+	if e.cursor.Row != 0 {
+		t.Fatalf("k onto fold: row = %d, want 0", e.cursor.Row)
+	}
+
+	e.HandleKey(eventForKeyString(t, "alt+z"))
+	if len(e.folds) != 0 {
+		t.Fatalf("folds after toggle = %v, want none", e.folds)
+	}
+}
+
+func TestQuickFoldString(t *testing.T) {
+	e := newFoldTestEditor()
+	e.cursor = Cursor{Row: 6}
+	e.HandleKey(eventForKeyString(t, "alt+z"))
+	if len(e.folds) != 1 || e.folds[0] != (lineFold{start: 5, end: 8}) {
+		t.Fatalf("folds = %v, want lines 5-8", e.folds)
+	}
+	if got := e.visibleIndex(9); got != 6 {
+		t.Fatalf("visibleIndex(9) = %d, want 6", got)
+	}
+	if got := e.rowAtVisibleIndex(6); got != 9 {
+		t.Fatalf("rowAtVisibleIndex(6) = %d, want 9", got)
+	}
+}
+
+func TestQuickFoldNothing(t *testing.T) {
+	e := newFoldTestEditor()
+	e.cursor = Cursor{Row: 9}
+	e.HandleKey(eventForKeyString(t, "alt+z"))
+	if len(e.folds) != 0 {
+		t.Fatalf("folds = %v, want none", e.folds)
+	}
+	if want := "no comment block or string to fold"; e.statusMessage != want {
+		t.Fatalf("status = %q, want %q", e.statusMessage, want)
+	}
+}
+
+func TestQuickFoldRevealedBySearch(t *testing.T) {
+	e := newFoldTestEditor()
+	e.cursor = Cursor{Row: 0}
+	e.HandleKey(eventForKeyString(t, "alt+z"))
+	e.HandleKey(keyRune('/'))
+	for _, r := range "details" {
+		e.HandleKey(keyRune(r))
+	}
+	e.HandleKey(eventForKeyString(t, "enter"))
+	if e.cursor.Row != 2 {
+		t.Fatalf("search: row = %d, want 2", e.cursor.Row)
+	}
+	if len(e.folds) != 0 {
+		t.Fatalf("folds = %v, want the fold opened", e.folds)
+	}
+}
+
+func TestQuickFoldFollowsEdits(t *testing.T) {
+	e := newFoldTestEditor()
+	e.cursor = Cursor{Row: 6}
+	e.HandleKey(eventForKeyString(t, "alt+z"))
+
+	// Lines added after or before the fold leave it folded where it was
+	e.cursor = Cursor{Row: 9}
+	e.HandleKey(keyRune('o'))
+	e.HandleKey(keyEsc())
+	if len(e.folds) != 1 || e.folds[0] != (lineFold{start: 5, end: 8}) {
+		t.Fatalf("folds after o below = %v, want lines 5-8", e.folds)
+	}
+	e.cursor = Cursor{Row: 3}
+	e.HandleKey(keyRune('O'))
+	e.HandleKey(keyEsc())
+	if len(e.folds) != 1 || e.folds[0] != (lineFold{start: 6, end: 9}) {
+		t.Fatalf("folds after O above = %v, want lines 6-9", e.folds)
+	}
+	if got := e.visibleIndex(10); got != 7 {
+		t.Fatalf("visibleIndex(10) = %d, want 7", got)
+	}
+
+	// Typing on the fold's line keeps it; joining its lines drops it
+	e.cursor = Cursor{Row: 6}
+	e.HandleKey(keyRune('I'))
+	typeKeys(e, "var")
+	e.HandleKey(keyEsc())
+	if len(e.folds) != 1 {
+		t.Fatalf("folds after typing on the fold line = %v", e.folds)
+	}
+	e.HandleKey(keyRune('J'))
+	if len(e.folds) != 0 {
+		t.Fatalf("folds after J = %v, want none", e.folds)
+	}
+}
@@ -0,0 +1,229 @@
+package editor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// fuzzTexts are the pieces inserted and pasted by the fuzz targets: ASCII,
+// multi-byte runes, tabs and line breaks in different places
+var fuzzTexts = []string{"a", "xy", "\n", "ж", "😀", "a\nb", "\n\n", "\t", "é\nü\n", ""}
+
+// fuzzOps reads operation bytes, returning 0 once they run out
+type fuzzOps struct {
+	data []byte
+}
+
+func (o *fuzzOps) next() int {
+	if len(o.data) == 0 {
+		return 0
+	}
+	b := o.data[0]
+	o.data = o.data[1:]
+	return int(b)
+}
+
+func (o *fuzzOps) text() string {
+	return fuzzTexts[o.next()%len(fuzzTexts)]
+}
+
+// pos returns a position that is usually inside the buffer and sometimes
+// one past its last row or column
+func (o *fuzzOps) pos(e *Editor) Cursor {
+	row := o.next() % (len(e.lines) + 1)
+	col := 0
+	if row < len(e.lines) {
+		col = o.next() % (len(e.lines[row]) + 2)
+	}
+	return Cursor{Row: row, Col: col}
+}
+
+func newFuzzEditor(content string) *Editor {
+	e := newTestEditor(strings.Split(content, "\n")...)
+	e.DisableState()
+	return e
+}
+
+// checkBuffer fails on states no edit may leave behind
+func checkBuffer(t *testing.T, e *Editor, step string) {
+	t.Helper()
+	if len(e.lines) == 0 {
+		t.Fatalf("%s: buffer has no lines", step)
+	}
+	if e.cursor.Row < 0 || e.cursor.Row >= len(e.lines) || e.cursor.Col < 0 || e.cursor.Col > len(e.lines[e.cursor.Row]) {
+		t.Fatalf("%s: cursor %+v outside the buffer (%d lines)", step, e.cursor, len(e.lines))
+	}
+	content := e.Content()
+	for row := range e.lines {
+		for _, col := range []int{0, len(e.lines[row]) / 2, len(e.lines[row])} {
+			pos := Cursor{Row: row, Col: col}
+			offset, colBytes := e.byteOffset(pos)
+			prefix := string([]rune(content)[:e.runeOffset(pos)])
+			if offset != len(prefix) {
+				t.Fatalf("%s: byteOffset(%+v) = %d, want %d", step, pos, offset, len(prefix))
+			}
+			if colBytes != len(string(e.lines[row][:col])) {
+				t.Fatalf("%s: column bytes of %+v = %d, want %d", step, pos, colBytes, len(string(e.lines[row][:col])))
+			}
+		}
+	}
+}
+
+// checkByteEdit verifies that the byte edit of a step's only change turns
+// the old content into the new one, as the incremental parser relies on
+func checkByteEdit(t *testing.T, e *Editor, before string, changes []TextChange, step string) {
+	t.Helper()
+	if len(changes) != 1 {
+		return
+	}
+	edit := e.ByteEdit(changes[0])
+	after := e.Content()
+	if edit.StartByte > edit.OldEndByte || edit.OldEndByte > len(before) || edit.NewEndByte > len(after) || edit.StartByte > edit.NewEndByte {
+		t.Fatalf("%s: edit %+v out of range (old %d, new %d bytes)", step, edit, len(before), len(after))
+	}
+	if got := before[:edit.StartByte] + after[edit.StartByte:edit.NewEndByte] + before[edit.OldEndByte:]; got != after {
+		t.Fatalf("%s: edit %+v applied to %q gives %q, want %q", step, edit, before, got, after)
+	}
+}
+
+// FuzzEditUndo applies a sequence of inserts, deletes, replaces, pastes,
+// undos and redos, then checks that undoing everything restores the
+// original text and the save point and that redo replays it
+func FuzzEditUndo(f *testing.F) {
+	f.Add("hello\nworld", []byte{0, 1, 3, 2, 4, 0, 0, 1, 1, 5, 2, 1, 0, 1, 0})
+	f.Add("", []byte{0, 0, 0, 8, 3, 0, 0, 1, 2, 4, 4, 5})
+	f.Add("жж\n😀x\n\n", []byte{1, 0, 1, 2, 1, 3, 2, 0, 0, 1, 3, 1, 1, 5, 0})
+	f.Add("a\nb\nc", []byte{3, 1, 1, 0, 6, 3, 0, 2, 0, 4, 1, 4, 5, 5})
+	f.Fuzz(func(t *testing.T, content string, data []byte) {
+		if !utf8.ValidString(content) || len(content) > 256 || len(data) > 256 {
+			return
+		}
+		e := newFuzzEditor(content)
+		initial := e.Content()
+		var changes []TextChange
+		e.OnChange(func(c TextChange) {
+			changes = append(changes, c)
+		})
+		ops := &fuzzOps{data: data}
+		for step := 0; len(ops.data) > 0; step++ {
+			before := e.Content()
+			changes = changes[:0]
+			name := ""
+			switch ops.next() % 6 {
+			case 0:
+				name = "insert"
+				pos, text := ops.pos(e), ops.text()
+				if _, err := e.InsertAt(pos, text); err != nil && e.validPos(pos) {
+					t.Fatalf("InsertAt(%+v): %v", pos, err)
+				}
+			case 1:
+				name = "delete"
+				start, end := ops.pos(e), ops.pos(e)
+				_, _ = e.DeleteRange(start, end)
+			case 2:
+				name = "replace"
+				start, end, text := ops.pos(e), ops.pos(e), ops.text()
+				_, _ = e.ReplaceRange(start, end, text)
+			case 3:
+				name = "paste"
+				if pos := ops.pos(e); e.validPos(pos) {
+					e.cursor = pos
+				}
+				clip := ""
+				for n := ops.next()%3 + 1; n > 0; n-- {
+					clip += ops.text()
+				}
+				e.clipboard = splitLines([]byte(clip))
+				if ops.next()%2 == 0 {
+					e.pasteAfter()
+				} else {
+					e.pasteBefore()
+				}
+			case 4:
+				name = "undo"
+				e.Undo()
+			case 5:
+				name = "redo"
+				e.Redo()
+			}
+			where := fmt.Sprintf("%s (op %d)", name, step)
+			checkBuffer(t, e, where)
+			checkByteEdit(t, e, before, changes, where)
+		}
+
+		for len(e.redo) > 0 {
+			e.Redo()
+		}
+		tip := e.Content()
+		for len(e.undo) > 0 {
+			e.Undo()
+		}
+		if got := e.Content(); got != initial {
+			t.Fatalf("after undoing everything content = %q, want %q", got, initial)
+		}
+		if e.dirty {
+			t.Fatalf("buffer is dirty at the save point")
+		}
+		checkBuffer(t, e, "undo all")
+		for len(e.redo) > 0 {
+			e.Redo()
+		}
+		if got := e.Content(); got != tip {
+			t.Fatalf("after redoing everything content = %q, want %q", got, tip)
+		}
+		checkBuffer(t, e, "redo all")
+	})
+}
+
+// FuzzInsertDeleteText calls the low-level insertTextAt and deleteTextRange
+// with positions anywhere, including outside the buffer, and checks that
+// deleting what was inserted (and inserting what was deleted) round-trips
+func FuzzInsertDeleteText(f *testing.F) {
+	f.Add("hello\nworld", "a\nb", int8(0), int8(2), int8(1), int8(3))
+	f.Add("", "ж😀", int8(0), int8(5), int8(0), int8(9))
+	f.Add("abc", "\n", int8(-1), int8(-4), int8(0), int8(7))
+	f.Add("a\n\nb", "x", int8(1), int8(0), int8(2), int8(1))
+	f.Fuzz(func(t *testing.T, content, text string, row, col, endRow, endCol int8) {
+		if !utf8.ValidString(content) || !utf8.ValidString(text) || len(content) > 256 || len(text) > 64 {
+			return
+		}
+		e := newFuzzEditor(content)
+		initial := e.Content()
+		pos := Cursor{Row: int(row), Col: int(col)}
+		lines := splitLines([]byte(text))
+
+		snapshot := slices.Clone(e.lines)
+		end := e.insertTextAt(pos, lines)
+		if pos.Row >= 0 && pos.Row < len(snapshot) {
+			start := Cursor{Row: pos.Row, Col: clampRange(pos.Col, 0, len(snapshot[pos.Row]))}
+			deleted := e.deleteTextRange(start, end)
+			if joinLines(deleted) != joinLines(lines) {
+				t.Fatalf("deleted %q, want the inserted %q", joinLines(deleted), joinLines(lines))
+			}
+		}
+		if got := e.Content(); got != initial {
+			t.Fatalf("content after insert and delete = %q, want %q", got, initial)
+		}
+
+		start := Cursor{Row: int(row), Col: int(col)}
+		stop := Cursor{Row: int(endRow), Col: int(endCol)}
+		deleted := e.deleteTextRange(start, stop)
+		if len(e.lines) == 0 {
+			t.Fatalf("deleteTextRange(%+v, %+v) removed every line", start, stop)
+		}
+		if deleted == nil {
+			if got := e.Content(); got != initial {
+				t.Fatalf("deleteTextRange(%+v, %+v) returned nothing but changed %q to %q", start, stop, initial, got)
+			}
+			return
+		}
+		start.Col = clampRange(start.Col, 0, len(snapshot[start.Row]))
+		e.insertTextAt(start, deleted)
+		if got := e.Content(); got != initial {
+			t.Fatalf("reinserting %q at %+v gives %q, want %q", joinLines(deleted), start, got, initial)
+		}
+	})
+}
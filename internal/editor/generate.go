@@ -0,0 +1,88 @@
+package editor
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	defaultRandomLength = 16
+	maxRandomLength     = 4096
+	randomAlphabet      = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// randomString returns n random alphanumeric characters
+func randomString(n int) (string, error) {
+	out := make([]byte, n)
+	limit := big.NewInt(int64(len(randomAlphabet)))
+	for i := range out {
+		k, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		out[i] = randomAlphabet[k.Int64()]
+	}
+	return string(out), nil
+}
+
+// execGenerateCommand runs :uuid and :random [n]. The text replaces the
+// selection if there is one, otherwise it is inserted at the cursor. With
+// several selections each one gets a text of its own.
+func (e *Editor) execGenerateCommand(name string, args []string) {
+	generate := newUUID
+	if name == "random" {
+		n := defaultRandomLength
+		if len(args) > 0 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil || n < 1 || n > maxRandomLength {
+				e.setStatus(fmt.Sprintf("usage: :random [1-%d]", maxRandomLength))
+				return
+			}
+		}
+		generate = func() (string, error) { return randomString(n) }
+	}
+	if e.refuseEdit() {
+		return
+	}
+	var texts []string
+	var err error
+	e.eachSelection(func() {
+		if err != nil {
+			return
+		}
+		var text string
+		if text, err = generate(); err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+		if start, end, ok := e.selectionRange(); ok {
+			_, err = e.ReplaceRange(start, end, text)
+			e.clearSelection()
+		} else {
+			_, err = e.InsertAt(e.cursor, text)
+		}
+		texts = append(texts, text)
+	})
+	switch {
+	case err != nil:
+		e.setStatus(err.Error())
+	case len(texts) == 1:
+		e.setStatus("inserted " + texts[0])
+	default:
+		e.setStatus(fmt.Sprintf("inserted %d %s", len(texts), plural(len(texts), "value", "values")))
+	}
+}
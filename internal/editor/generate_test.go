@@ -0,0 +1,97 @@
+package editor
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestUUIDCommandInsertsAtCursor(t *testing.T) {
+	e := newTestEditor(`id = ""`)
+	e.cursor = Cursor{Row: 0, Col: 6}
+	e.execCommand("uuid")
+	re := regexp.MustCompile(`^id = "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}"$`)
+	if got := e.Content(); !re.MatchString(got) {
+		t.Fatalf("content = %q, want a UUIDv4 inside quotes", got)
+	}
+	if e.cursor.Col != 42 {
+		t.Fatalf("cursor col = %d, want after the UUID", e.cursor.Col)
+	}
+	e.Undo()
+	if got := e.Content(); got != `id = ""` {
+		t.Fatalf("undo = %q", got)
+	}
+}
+
+func TestRandomCommand(t *testing.T) {
+	e := newTestEditor("key: XXXX")
+	e.selectionActive = true
+	e.selectionStart = Cursor{Row: 0, Col: 5}
+	e.selectionEnd = Cursor{Row: 0, Col: 9}
+	e.execCommand("random 8")
+	if got := e.Content(); !regexp.MustCompile(`^key: [A-Za-z0-9]{8}$`).MatchString(got) {
+		t.Fatalf("content = %q, want selection replaced by 8 random characters", got)
+	}
+
+	e = newTestEditor("")
+	e.execCommand("random")
+	if got := len(e.Content()); got != defaultRandomLength {
+		t.Fatalf("default length = %d, want %d", got, defaultRandomLength)
+	}
+	e.execCommand("random 0")
+	if e.statusMessage != "usage: :random [1-4096]" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestGenerateAtEverySelection(t *testing.T) {
+	e := newTestEditor("a: ID", "b: ID", "c: ID")
+	e.execCommand("select ID")
+	e.HandleKey(keyRune(':'))
+	typeKeys(e, "uuid")
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	re := regexp.MustCompile(`^[a-c]: ([0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12})$`)
+	seen := map[string]bool{}
+	for row, line := range e.lines {
+		m := re.FindStringSubmatch(string(line))
+		if m == nil || seen[m[1]] {
+			t.Fatalf("line %d = %q, want a UUID of its own", row, string(line))
+		}
+		seen[m[1]] = true
+	}
+	if e.statusMessage != "inserted 3 values" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.Undo()
+	if got := e.Content(); got != "a: ID\nb: ID\nc: ID" {
+		t.Fatalf("undo = %q", got)
+	}
+
+	// Cursors with nothing selected get a string each too
+	e = newTestEditor("x", "x")
+	e.setSelections([]selection{{cursor: Cursor{Row: 0, Col: 1}}, {cursor: Cursor{Row: 1, Col: 1}}}, 0)
+	e.execCommand("random 12")
+	if a, b := string(e.lines[0]), string(e.lines[1]); len(a) != 13 || len(b) != 13 || a == b {
+		t.Fatalf("lines = %q, %q, want distinct random strings", a, b)
+	}
+}
+
+func TestGenerateUnderReadOnly(t *testing.T) {
+	// --readonly only refuses the write
+	e := newTestEditor("")
+	e.SetReadOnly(true)
+	e.execCommand("random 4")
+	if got := e.Content(); len(got) != 4 {
+		t.Fatalf("content = %q, want 4 random characters", got)
+	}
+
+	// An unwritable file refuses the edit
+	e = newTestEditor("")
+	e.filename = "ro.txt"
+	e.fileReadOnly = true
+	e.execCommand("uuid")
+	if got := e.Content(); got != "" || e.statusMessage != e.readOnlyHint() {
+		t.Fatalf("content = %q, status = %q", got, e.statusMessage)
+	}
+}
@@ -0,0 +1,215 @@
+package editor
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles are read from every listed directory, in order
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// maxProjectFiles bounds the files the file picker and :grep look at
+const maxProjectFiles = 20000
+
+// gitignorePattern is a single gitignore-style rule
+type gitignorePattern struct {
+	Pattern  string
+	Base     string // directory the pattern is relative to
+	IsDir    bool   // pattern ends with /
+	Negation bool   // pattern starts with !
+	Anchored bool   // pattern contains a slash, match against the relative path
+}
+
+// ignoreMatcher collects ignore rules from .gitignore/.ignore files and config
+type ignoreMatcher struct {
+	root     string
+	patterns []gitignorePattern
+	loaded   map[string]bool // directories whose ignore files were read
+}
+
+// newIgnoreMatcher creates a matcher rooted at root (git root or project dir).
+// Extra patterns (from config) are relative to root and have the lowest priority.
+func newIgnoreMatcher(root string, extra []string) *ignoreMatcher {
+	m := &ignoreMatcher{
+		root:   root,
+		loaded: make(map[string]bool),
+	}
+	m.patterns = append(m.patterns, parseIgnorePatterns(extra, root)...)
+	return m
+}
+
+// projectFiles lists the files below root as slash-separated paths
+// relative to it, sorted. .git is left out, and so are dotfiles unless
+// showHidden and the files .gitignore, .ignore and extra exclude unless
+// showIgnored; hidden and ignored directories aren't entered.
+func projectFiles(root string, showHidden, showIgnored bool, extra []string) []string {
+	ignoreRoot := findGitRoot(root)
+	if ignoreRoot == "" {
+		ignoreRoot = root
+	}
+	m := newIgnoreMatcher(ignoreRoot, extra)
+	var files []string
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			m.loadDir(p)
+			return nil
+		}
+		name := d.Name()
+		if (name == ".git" || !showHidden && strings.HasPrefix(name, ".")) || (!showIgnored && m.Match(p, d.IsDir())) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			m.loadDir(p)
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		files = append(files, filepath.ToSlash(rel))
+		if len(files) == maxProjectFiles {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return files
+}
+
+// findGitRoot walks up from dir to find a directory containing .git
+func findGitRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// parseIgnorePatterns parses gitignore lines relative to base
+func parseIgnorePatterns(lines []string, base string) []gitignorePattern {
+	var patterns []gitignorePattern
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " ")
+		p := gitignorePattern{Base: base}
+		if strings.HasPrefix(line, "!") {
+			p.Negation = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.IsDir = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.Anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.Pattern = line
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// loadDir reads ignore files of dir and all its parents up to root
+func (m *ignoreMatcher) loadDir(dir string) {
+	if m.root == "" {
+		return
+	}
+	rel, err := filepath.Rel(m.root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return
+	}
+	// Parents first so that deeper rules take precedence
+	var chain []string
+	for d := dir; ; d = filepath.Dir(d) {
+		chain = append(chain, d)
+		if d == m.root || filepath.Dir(d) == d {
+			break
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		d := chain[i]
+		if m.loaded[d] {
+			continue
+		}
+		m.loaded[d] = true
+		for _, name := range ignoreFiles {
+			data, err := os.ReadFile(filepath.Join(d, name))
+			if err != nil {
+				continue
+			}
+			m.patterns = append(m.patterns, parseIgnorePatterns(strings.Split(string(data), "\n"), d)...)
+		}
+	}
+}
+
+// Match reports whether path is ignored. Later rules override earlier ones.
+func (m *ignoreMatcher) Match(p string, isDir bool) bool {
+	ignored := false
+	for _, pat := range m.patterns {
+		if pat.IsDir && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(pat.Base, p)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		var matched bool
+		if pat.Anchored {
+			matched = globMatch(pat.Pattern, rel)
+		} else {
+			matched, _ = path.Match(pat.Pattern, path.Base(rel))
+		}
+		if matched {
+			ignored = !pat.Negation
+		}
+	}
+	return ignored
+}
+
+// globMatch matches a slash-separated pattern supporting ** segments
+func globMatch(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,278 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func writeTestFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestIgnoreMatcherPatterns(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".gitignore"), `
+# comment
+*.log
+!keep.log
+build/
+/vendor
+docs/**/*.tmp
+`)
+	writeTestFile(t, filepath.Join(root, "sub", ".ignore"), "secret.txt\n")
+
+	m := newIgnoreMatcher(root, []string{"*.bak"})
+	m.loadDir(filepath.Join(root, "sub"))
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"keep.log", false, false},
+		{"sub/app.log", false, true},
+		{"build", true, true},
+		{"build", false, false},
+		{"vendor", true, true},
+		{"sub/vendor", true, false},
+		{"docs/a/b/x.tmp", false, true},
+		{"docs/x.tmp", false, true},
+		{"x.tmp", false, false},
+		{"main.go", false, false},
+		{"old.bak", false, true},
+		{"sub/secret.txt", false, true},
+		{"secret.txt", false, false},
+	}
+	for _, tt := range tests {
+		got := m.Match(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir)
+		if got != tt.want {
+			t.Fatalf("Match(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreMatcherNegationOverridesConfig(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "!important.bak\n")
+	m := newIgnoreMatcher(root, []string{"*.bak"})
+	m.loadDir(root)
+	if m.Match(filepath.Join(root, "important.bak"), false) {
+		t.Fatalf("important.bak ignored, want negated by .gitignore")
+	}
+	if !m.Match(filepath.Join(root, "other.bak"), false) {
+		t.Fatalf("other.bak not ignored, want ignored by config pattern")
+	}
+}
+
+func sidebarLabels(content SidebarContent) []string {
+	var labels []string
+	for _, item := range content.Items() {
+		labels = append(labels, item.Label)
+	}
+	return labels
+}
+
+func TestSidebarFilesHiddenAndIgnoredToggle(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "out/\n*.log\n")
+	writeTestFile(t, filepath.Join(root, ".env"), "")
+	writeTestFile(t, filepath.Join(root, "b.go"), "")
+	writeTestFile(t, filepath.Join(root, "A.md"), "")
+	writeTestFile(t, filepath.Join(root, "debug.log"), "")
+	writeTestFile(t, filepath.Join(root, "out", "bin"), "")
+	writeTestFile(t, filepath.Join(root, "src", "main.go"), "")
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	f := NewSidebarFilesContent(root, false, false, nil)
+	got := sidebarLabels(f)
+	want := []string{"..", "src/", "A.md", "b.go"}
+	if len(got) != len(want) {
+		t.Fatalf("items = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("items = %v, want %v", got, want)
+		}
+	}
+
+	f.HandleKey(tcell.NewEventKey(tcell.KeyRune, '.', 0))
+	f.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'i', 0))
+	items := f.Items()
+	got = sidebarLabels(f)
+	want = []string{"..", "out/", "src/", ".env", ".gitignore", "A.md", "b.go", "debug.log"}
+	if len(got) != len(want) {
+		t.Fatalf("items = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("items = %v, want %v", got, want)
+		}
+	}
+	if !items[1].IsIgnored || !items[7].IsIgnored {
+		t.Fatalf("out/ and debug.log should be marked ignored")
+	}
+	if !items[3].IsHidden || items[5].IsHidden {
+		t.Fatalf(".env should be hidden, A.md should not")
+	}
+
+	// Entries inside an ignored directory are ignored too
+	f.SetIndex(1)
+	f.OnEnter()
+	items = f.Items()
+	if len(items) != 2 || items[1].Label != "bin" || !items[1].IsIgnored {
+		t.Fatalf("out/ items = %v, want [.. bin(ignored)]", sidebarLabels(f))
+	}
+}
+
+func TestSidebarFilesOpenFile(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "src", "main.go"), "package main\n")
+
+	e := newTestEditor("")
+	e.sidebarFiles = NewSidebarFilesContent(root, false, false, nil)
+	e.openSidebarFiles("")
+	e.sidebarFiles.SetIndex(1)
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0)) // enter src/
+	if e.sidebarFiles.Dir() != filepath.Join(root, "src") {
+		t.Fatalf("dir = %q, want %q", e.sidebarFiles.Dir(), filepath.Join(root, "src"))
+	}
+	e.sidebarFiles.SetIndex(1)
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if e.sidebar.Focused {
+		t.Fatalf("sidebar focused after open, want editor focus")
+	}
+	if got := e.ConsumeOpenFileRequest(); got != filepath.Join(root, "src", "main.go") {
+		t.Fatalf("selection = %q, want main.go", got)
+	}
+	if e.ConsumeOpenFileRequest() != "" {
+		t.Fatalf("selection should be consumed")
+	}
+
+	e.sidebar.Focused = true
+	e.HandleKey(tcell.NewEventKey(tcell.KeyBackspace2, 0, 0))
+	if e.sidebarFiles.Dir() != root {
+		t.Fatalf("dir after backspace = %q, want %q", e.sidebarFiles.Dir(), root)
+	}
+	if item := e.sidebarFiles.Items()[e.sidebarFiles.Index()]; item.Label != "src/" {
+		t.Fatalf("selected after backspace = %q, want src/", item.Label)
+	}
+}
+
+func TestSidebarFilesOpenFileDirty(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.txt"), "a\n")
+
+	e := newTestEditor("")
+	e.dirty = true
+	e.sidebarFiles = NewSidebarFilesContent(root, false, false, nil)
+	e.openSidebarFiles("")
+	e.sidebarFiles.SetIndex(1)
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if got := e.ConsumeOpenFileRequest(); got != "" {
+		t.Fatalf("selection = %q, want empty for dirty buffer", got)
+	}
+	if e.statusMessage != "unsaved changes (use :w first)" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestSidebarFilesPollRefreshesAfterDebounce(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a.txt"), "")
+	f := NewSidebarFilesContent(root, false, false, nil)
+
+	now := time.Now()
+	if f.Poll(now) {
+		t.Fatalf("Poll refreshed without changes")
+	}
+
+	writeTestFile(t, filepath.Join(root, "b.txt"), "")
+	// Make sure the directory mtime differs even on coarse-grained filesystems
+	later := now.Add(2 * time.Second)
+	if err := os.Chtimes(root, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if f.Poll(now) {
+		t.Fatalf("Poll refreshed before debounce")
+	}
+	if !f.Poll(now.Add(fileTreeWatchDebounce)) {
+		t.Fatalf("Poll did not refresh after debounce")
+	}
+	got := sidebarLabels(f)
+	if len(got) != 3 || got[2] != "b.txt" {
+		t.Fatalf("items = %v, want [.. a.txt b.txt]", got)
+	}
+
+	// Removing the listed directory falls back to its parent
+	sub := filepath.Join(root, "sub")
+	writeTestFile(t, filepath.Join(sub, "c.txt"), "")
+	f.SetDir(sub)
+	if err := os.RemoveAll(sub); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	f.Poll(now)
+	if !f.Poll(now.Add(fileTreeWatchDebounce)) {
+		t.Fatalf("Poll did not refresh removed dir")
+	}
+	if f.Dir() != root {
+		t.Fatalf("dir = %q, want %q", f.Dir(), root)
+	}
+}
+
+func TestFilePickerAndGrepSkipIgnored(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "build/\n*.log\n")
+	writeTestFile(t, filepath.Join(root, "README.md"), "say hello\n")
+	writeTestFile(t, filepath.Join(root, "src", "main.go"), "package main\n\nfunc main() { Hello() }\n")
+	writeTestFile(t, filepath.Join(root, "build", "out.go"), "Hello\n")
+	writeTestFile(t, filepath.Join(root, "debug.log"), "Hello\n")
+	writeTestFile(t, filepath.Join(root, ".env"), "Hello\n")
+
+	e := newTestEditor("")
+	e.projectRoot = root
+	e.openFilePicker(false)
+	if got := strings.Join(e.filePicker.files, " "); got != "README.md src/main.go" {
+		t.Fatalf("picker files = %q", got)
+	}
+	for _, r := range "mAi" {
+		e.HandleKey(keyRune(r))
+	}
+	if len(e.filePicker.matches) != 1 {
+		t.Fatalf("matches = %q", e.filePicker.matches)
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if got := e.ConsumeOpenFileRequest(); got != filepath.Join(root, "src", "main.go") {
+		t.Fatalf("opened %q", got)
+	}
+
+	e.execCommand("grep hello")
+	if len(e.refsPickerItems) != 2 {
+		t.Fatalf("grep found %+v", e.refsPickerItems)
+	}
+	e.HandleKey(keyRune('j'))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	path := e.ConsumeOpenFileRequest()
+	if path != filepath.Join(root, "src", "main.go") {
+		t.Fatalf("grep opened %q", path)
+	}
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if e.cursor != (Cursor{Row: 2, Col: 14}) {
+		t.Fatalf("cursor = %+v, want on Hello", e.cursor)
+	}
+}
@@ -0,0 +1,113 @@
+package editor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// maxGrepMatches bounds the lines :grep lists
+const maxGrepMatches = 1000
+
+// execGrepCommand searches the files of the project for the text after
+// :grep and lists the matching lines in the references picker, where
+// Enter opens one. Files the file tree leaves out (ignored, hidden) are
+// skipped, and so are binary and huge ones. The search ignores case unless
+// the text has upper case letters.
+func (e *Editor) execGrepCommand(args []string) {
+	if len(args) == 0 {
+		e.setStatus("usage: :grep TEXT")
+		return
+	}
+	text := strings.Join(args, " ")
+	root, err := e.projectDir()
+	if err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	hidden, ignored := e.fileListFlags()
+	extra := e.ignorePatterns
+	e.setStatus("grep: searching " + root)
+	e.RunTask("grep", func(ctx context.Context) func() {
+		locs := grepFiles(ctx, root, projectFiles(root, hidden, ignored, extra), text)
+		return func() {
+			if len(locs) == 0 {
+				e.setStatus("grep: no matches for " + text)
+				return
+			}
+			e.showRefsPicker("grep "+text, locs)
+		}
+	})
+}
+
+// grepFiles returns the first match of text on each line of files, which
+// are relative to root
+func grepFiles(ctx context.Context, root string, files []string, text string) []LSPLocation {
+	needle := []rune(text)
+	fold := !strings.ContainsFunc(text, unicode.IsUpper)
+	if fold {
+		needle = lowerRunes(needle)
+	}
+	var locs []LSPLocation
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return nil
+		}
+		path := filepath.Join(root, filepath.FromSlash(file))
+		info, err := os.Stat(path)
+		if err != nil || info.Size() > maxOpenFileBytes {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || isBinary(data[:min(len(data), binarySniffBytes)]) {
+			continue
+		}
+		content, _ := decodeText(data)
+		for row, line := range strings.Split(content, "\n") {
+			runes := []rune(line)
+			hay := runes
+			if fold {
+				hay = lowerRunes(runes)
+			}
+			col := indexRunes(hay, needle)
+			if col < 0 {
+				continue
+			}
+			locs = append(locs, LSPLocation{
+				Path:      path,
+				StartLine: row,
+				StartCol:  textpos.RuneToUTF16(runes, col),
+				EndLine:   row,
+				EndCol:    textpos.RuneToUTF16(runes, col+len(needle)),
+			})
+			if len(locs) == maxGrepMatches {
+				return locs
+			}
+		}
+	}
+	return locs
+}
+
+// lowerRunes returns runes in lower case, rune for rune
+func lowerRunes(runes []rune) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+// indexRunes returns the index of the first needle in hay, or -1
+func indexRunes(hay, needle []rune) int {
+	for i := 0; i+len(needle) <= len(hay); i++ {
+		if slices.Equal(hay[i:i+len(needle)], needle) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+package editor
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// doubleClickTime is how soon a second click on the same gutter line
+// counts as a double click
+const doubleClickTime = 400 * time.Millisecond
+
+// gutterMouse is the state of a mouse press that started in the line
+// number gutter
+type gutterMouse struct {
+	pressed   bool // the button is held
+	anchor    int  // line the press started on
+	dragged   bool
+	lastRow   int // line and time of the last click, for double clicks
+	lastClick time.Time
+}
+
+// handleGutterMouse handles a left button event in the gutter, or any
+// left button event while a press that started there is held. Clicking a
+// line number moves to the line's first non-blank, dragging selects whole
+// lines and double clicking selects the enclosing syntax block.
+func (e *Editor) handleGutterMouse(ev *tcell.EventMouse) bool {
+	x, y := ev.Position()
+	if ev.Buttons()&tcell.Button1 == 0 {
+		pressed := e.gutter.pressed
+		e.gutter.pressed = false
+		return pressed
+	}
+	if !e.gutter.pressed && x >= e.gutterWidth() {
+		return false
+	}
+	row := min(max(e.rowAtVisibleIndex(e.visibleIndex(e.scroll)+y), 0), len(e.lines)-1)
+	if row < 0 {
+		return true // empty file
+	}
+	e.freeScroll = false
+
+	if e.gutter.pressed {
+		// Held button: a drag over other lines selects them
+		if row != e.gutter.anchor || e.gutter.dragged {
+			e.gutter.dragged = true
+			e.selectLines(e.gutter.anchor, row)
+		}
+		return true
+	}
+
+	now := time.Now()
+	double := row == e.gutter.lastRow && now.Sub(e.gutter.lastClick) < doubleClickTime
+	e.gutter = gutterMouse{pressed: true, anchor: row, lastRow: row, lastClick: now}
+	if double {
+		e.gutter.lastClick = time.Time{} // a third click starts over
+		e.selectBlockAt(row)
+		return true
+	}
+	e.clearSelection()
+	e.cursor = Cursor{Row: row, Col: firstNonBlank(e.lines[row])}
+	return true
+}
+
+// selectLines selects lines from..to whole, the cursor on the to side
+func (e *Editor) selectLines(from, to int) {
+	start := Cursor{Row: from}
+	end := Cursor{Row: to, Col: len(e.lines[to])}
+	if to < from {
+		start = Cursor{Row: from, Col: len(e.lines[from])}
+		end = Cursor{Row: to}
+	}
+	e.selectionStart, e.selectionEnd = start, end
+	e.selectionActive = true
+	e.cursor = end
+}
+
+// selectBlockAt selects the lines of the smallest multi-line syntax node
+// around row, or just the line without a syntax tree
+func (e *Editor) selectBlockAt(row int) {
+	from, to := row, row
+	if e.nodeStackFunc != nil && e.filename != "" {
+		line := e.lines[row]
+		col := textpos.RuneToByte(line, firstNonBlank(line))
+		for _, nr := range e.nodeStackFunc(e.filename, row, col) {
+			end := nr.EndRow
+			if nr.EndCol == 0 {
+				end-- // the node ends with the line break before EndRow
+			}
+			if end > nr.StartRow {
+				from, to = nr.StartRow, min(end, len(e.lines)-1)
+				break
+			}
+		}
+	}
+	e.selectLines(from, to)
+}
+
+// firstNonBlank returns the column of the first character that isn't a
+// space or tab
+func firstNonBlank(line []rune) int {
+	col := 0
+	for col < len(line) && (line[col] == ' ' || line[col] == '\t') {
+		col++
+	}
+	return col
+}
@@ -0,0 +1,71 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func mouse(x, y int, buttons tcell.ButtonMask) *tcell.EventMouse {
+	return tcell.NewEventMouse(x, y, buttons, 0)
+}
+
+func TestGutterClickAndDrag(t *testing.T) {
+	e := newTestEditor("func f() {", "    a := 1", "    b := 2", "}")
+
+	// A click on the number goes to the first non-blank
+	e.HandleMouse(mouse(1, 2, tcell.Button1))
+	e.HandleMouse(mouse(1, 2, tcell.ButtonNone))
+	if e.cursor != (Cursor{Row: 2, Col: 4}) || e.selectionActive {
+		t.Fatalf("click: cursor %+v selection %v", e.cursor, e.selectionActive)
+	}
+
+	// Dragging selects whole lines, upwards too
+	e.HandleMouse(mouse(0, 1, tcell.Button1))
+	e.HandleMouse(mouse(0, 3, tcell.Button1))
+	if start, end, ok := e.selectionRange(); !ok || e.textInRange(start, end) != "    a := 1\n    b := 2\n}" {
+		t.Fatalf("drag down selected %v..%v", start, end)
+	}
+	e.HandleMouse(mouse(8, 0, tcell.Button1)) // past the gutter, still dragging
+	if start, end, ok := e.selectionRange(); !ok || e.textInRange(start, end) != "func f() {\n    a := 1" || e.cursor.Row != 0 {
+		t.Fatalf("drag up selected %v..%v cursor %+v", start, end, e.cursor)
+	}
+	e.HandleMouse(mouse(8, 0, tcell.ButtonNone))
+
+	// After the release clicks in the text go back to placing the cursor
+	e.HandleMouse(mouse(e.gutterWidth()+2, 3, tcell.Button1))
+	e.HandleMouse(mouse(e.gutterWidth()+2, 3, tcell.ButtonNone))
+	if e.cursor != (Cursor{Row: 3, Col: 1}) || e.selectionActive {
+		t.Fatalf("text click: cursor %+v selection %v", e.cursor, e.selectionActive)
+	}
+}
+
+func TestGutterDoubleClickSelectsBlock(t *testing.T) {
+	e := newTestEditor("x", "func f() {", "    a := 1", "}", "y")
+	e.filename = "f.go"
+	e.nodeStackFunc = func(path string, row, col int) []NodeRange {
+		return []NodeRange{
+			{StartRow: 2, StartCol: 4, EndRow: 2, EndCol: 10}, // statement
+			{StartRow: 1, StartCol: 9, EndRow: 3, EndCol: 1},  // body
+			{StartRow: 0, StartCol: 0, EndRow: 5, EndCol: 0},  // file
+		}
+	}
+	for i := 0; i < 2; i++ {
+		e.HandleMouse(mouse(0, 2, tcell.Button1))
+		e.HandleMouse(mouse(0, 2, tcell.ButtonNone))
+	}
+	if start, end, ok := e.selectionRange(); !ok || e.textInRange(start, end) != "func f() {\n    a := 1\n}" {
+		t.Fatalf("double click selected %v..%v", start, end)
+	}
+
+	// Without a syntax tree the line is selected
+	e.nodeStackFunc = nil
+	e.gutter = gutterMouse{}
+	for i := 0; i < 2; i++ {
+		e.HandleMouse(mouse(0, 4, tcell.Button1))
+		e.HandleMouse(mouse(0, 4, tcell.ButtonNone))
+	}
+	if start, end, ok := e.selectionRange(); !ok || e.textInRange(start, end) != "y" {
+		t.Fatalf("double click without a tree selected %v..%v", start, end)
+	}
+}
@@ -0,0 +1,268 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Page layout of :hardcopy, in PDF points: A4 with a Courier font
+const (
+	hardcopyPageWidth  = 595
+	hardcopyPageHeight = 842
+	hardcopyMargin     = 36
+	hardcopyFontSize   = 9
+	hardcopyLineHeight = 11
+	hardcopyColumns    = 96 // characters across the text area, Courier being 0.6em wide
+)
+
+// hardcopyLine is one printed line: the buffer line number, zero on the
+// continuation of a wrapped line, and its runs
+type hardcopyLine struct {
+	number int
+	runs   []exportRun
+}
+
+// execHardcopyCommand prints the selection, or the buffer, with line
+// numbers and the theme's syntax colors to a PDF file (:hardcopy [FILE]).
+// Without FILE it goes next to the buffer's file, with a .pdf extension.
+// An existing file is only replaced with :hardcopy!. Characters the PDF
+// fonts lack are named in the status line.
+func (e *Editor) execHardcopyCommand(args []string, force bool) {
+	if len(args) > 1 {
+		e.setStatus("usage: :hardcopy[!] [FILE]")
+		return
+	}
+	path := "untitled.pdf"
+	if e.filename != "" {
+		path = strings.TrimSuffix(e.filename, filepath.Ext(e.filename)) + ".pdf"
+	}
+	if len(args) == 1 {
+		path = args[0]
+	}
+	first, lines := e.exportLines()
+	data, pages := e.hardcopyPDF(first, lines)
+	if err := writeNewFile(path, data, force, "hardcopy!"); err != nil {
+		e.setStatus("hardcopy: " + err.Error())
+		return
+	}
+	status := "printed " + strconv.Itoa(pages) + " pages to " + path
+	if missing := unprintable(lines); missing != "" {
+		status += " (" + missing + " printed as ?)"
+	}
+	e.setStatus(status)
+}
+
+// unprintable lists the characters of lines the PDF fonts lack, the first
+// few of them
+func unprintable(lines [][]exportRun) string {
+	const shown = 5
+	var chars []rune
+	seen := map[rune]bool{}
+	for _, runs := range lines {
+		for _, run := range runs {
+			for _, r := range run.text {
+				if _, ok := winAnsi(r); ok || seen[r] {
+					continue
+				}
+				seen[r] = true
+				chars = append(chars, r)
+			}
+		}
+	}
+	if len(chars) > shown {
+		return string(chars[:shown]) + " and " + strconv.Itoa(len(chars)-shown) + " more"
+	}
+	return string(chars)
+}
+
+// hardcopyPDF lays lines out on pages and returns the PDF document and
+// its page count. Lines longer than the page wrap; the page is filled
+// with the theme's background.
+func (e *Editor) hardcopyPDF(first int, lines [][]exportRun) ([]byte, int) {
+	numberWidth := len(strconv.Itoa(first + len(lines)))
+	textWidth := hardcopyColumns - numberWidth - 1
+	var printed []hardcopyLine
+	for i, runs := range lines {
+		for j, part := range wrapRuns(runs, textWidth) {
+			number := 0
+			if j == 0 {
+				number = first + i + 1
+			}
+			printed = append(printed, hardcopyLine{number, part})
+		}
+	}
+
+	perPage := (hardcopyPageHeight-2*hardcopyMargin)/hardcopyLineHeight - 2 // header and a blank line
+	pages := make([]string, max(ceilDiv(len(printed), perPage), 1))
+	for i := range pages {
+		part := printed[min(i*perPage, len(printed)):min((i+1)*perPage, len(printed))]
+		pages[i] = e.hardcopyPage(part, numberWidth, i+1, len(pages))
+	}
+	return pdfDocument(pages), len(pages)
+}
+
+// hardcopyPage returns the content stream of one page: a header with the
+// file name and page number, then the lines
+func (e *Editor) hardcopyPage(lines []hardcopyLine, numberWidth, page, pages int) string {
+	mainFg, bg, _ := e.styleMain.Decompose()
+	numberFg, _, _ := e.styleLineNumber.Decompose()
+	var sb strings.Builder
+	if r, g, b := bg.RGB(); r >= 0 {
+		fmt.Fprintf(&sb, "%s 0 0 %d %d re f\n", pdfColor(r, g, b), hardcopyPageWidth, hardcopyPageHeight)
+	}
+	y := hardcopyPageHeight - hardcopyMargin - hardcopyFontSize
+	name := e.filename
+	if name == "" {
+		name = "[No Name]"
+	}
+	header := exportRun{e.styleLineNumber, name + "  page " + strconv.Itoa(page) + "/" + strconv.Itoa(pages)}
+	writePDFLine(&sb, y, []exportRun{header}, mainFg)
+	y -= hardcopyLineHeight // a blank line under the header
+	for _, line := range lines {
+		y -= hardcopyLineHeight
+		number := strings.Repeat(" ", numberWidth+1)
+		if line.number > 0 {
+			number = fmt.Sprintf("%*d ", numberWidth, line.number)
+		}
+		runs := append([]exportRun{{tcell.StyleDefault.Foreground(numberFg), number}}, line.runs...)
+		writePDFLine(&sb, y, runs, mainFg)
+	}
+	return sb.String()
+}
+
+// writePDFLine writes a text object drawing runs at height y, in the
+// runs' colors and Courier faces. Runs in the default color use main.
+func writePDFLine(sb *strings.Builder, y int, runs []exportRun, main tcell.Color) {
+	fmt.Fprintf(sb, "BT %d %d Td\n", hardcopyMargin, y)
+	for _, run := range runs {
+		fg, _, attr := run.style.Decompose()
+		r, g, b := fg.RGB()
+		if r < 0 {
+			r, g, b = main.RGB()
+		}
+		if r < 0 {
+			r, g, b = 0, 0, 0
+		}
+		font := 1
+		if attr&tcell.AttrBold != 0 {
+			font++
+		}
+		if attr&tcell.AttrItalic != 0 {
+			font += 2
+		}
+		fmt.Fprintf(sb, "/F%d %d Tf %s (%s) Tj\n", font, hardcopyFontSize, pdfColor(r, g, b), pdfString(run.text))
+	}
+	sb.WriteString("ET\n")
+}
+
+// wrapRuns splits runs into lines of at most width characters, keeping
+// each piece's style. An empty line stays one empty line.
+func wrapRuns(runs []exportRun, width int) [][]exportRun {
+	lines := [][]exportRun{nil}
+	used := 0
+	for _, run := range runs {
+		text := []rune(run.text)
+		for len(text) > 0 {
+			if used == width {
+				lines = append(lines, nil)
+				used = 0
+			}
+			n := min(len(text), width-used)
+			last := len(lines) - 1
+			lines[last] = append(lines[last], exportRun{run.style, string(text[:n])})
+			text = text[n:]
+			used += n
+		}
+	}
+	return lines
+}
+
+// pdfColor returns the operator setting the fill color, which is what
+// text is drawn with
+func pdfColor(r, g, b int32) string {
+	return fmt.Sprintf("%.3f %.3f %.3f rg", float64(r)/255, float64(g)/255, float64(b)/255)
+}
+
+// pdfString escapes text for a PDF string literal. The fonts use
+// WinAnsiEncoding, so runes it lacks print as '?'.
+func pdfString(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		b, ok := winAnsi(r)
+		switch {
+		case !ok:
+			sb.WriteByte('?')
+		case b == '(' || b == ')' || b == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case b < 0x7f:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "\\%03o", b)
+		}
+	}
+	return sb.String()
+}
+
+// winAnsiExtra are the characters WinAnsiEncoding puts at 0x80-0x9f
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// winAnsi returns the WinAnsiEncoding byte of r
+func winAnsi(r rune) (byte, bool) {
+	switch {
+	case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+		return byte(r), true
+	}
+	b, ok := winAnsiExtra[r]
+	return b, ok
+}
+
+// pdfDocument wraps page content streams into a PDF file with the four
+// standard Courier faces as /F1 (regular) to /F4 (bold oblique)
+func pdfDocument(pages []string) []byte {
+	fonts := []string{"Courier", "Courier-Bold", "Courier-Oblique", "Courier-BoldOblique"}
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	firstPage := 3 + len(fonts)
+	for i := range pages {
+		kids[i] = strconv.Itoa(firstPage+2*i) + " 0 R"
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	var fontRefs []string
+	for i, font := range fonts {
+		objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /"+font+" /Encoding /WinAnsiEncoding >>")
+		fontRefs = append(fontRefs, fmt.Sprintf("/F%d %d 0 R", i+1, 3+i))
+	}
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+				hardcopyPageWidth, hardcopyPageHeight, strings.Join(fontRefs, " "), firstPage+2*i+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestHardcopyPDF(t *testing.T) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = "x := " + strconv.Itoa(i)
+	}
+	lines[0] = "func (a) { return \"é✓\" } " + strings.Repeat("y", 100)
+	e := newTestEditor(lines...)
+	e.filename = "main.go"
+	e.styleSyntaxUnknown = e.styleMain
+	e.styleSyntaxKeyword = tcell.StyleDefault.Foreground(tcell.NewHexColor(0xff0000))
+	e.SetHighlights(0, 0, map[int][]HighlightSpan{0: {{StartCol: 0, EndCol: 4, Kind: "keyword"}}})
+
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	e.execCommand("hardcopy")
+	if e.statusMessage != "printed 2 pages to main.pdf (✓ printed as ?)" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "main.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := string(data)
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("not a PDF file:\n%s", pdf)
+	}
+	// startxref points at the cross-reference table
+	var xref int
+	tail := pdf[strings.LastIndex(pdf, "startxref\n"):]
+	if _, err := fmt.Sscanf(tail, "startxref\n%d", &xref); err != nil || !strings.HasPrefix(pdf[xref:], "xref\n") {
+		t.Fatalf("bad startxref %d (%v)", xref, err)
+	}
+	for _, want := range []string{
+		"/Count 2",
+		"(main.go  page 1/2) Tj",
+		"1.000 0.000 0.000 rg (func) Tj", // keyword color
+		`( \(a\) { return "\351?" } `,    // escaped, Latin-1 kept, the rest replaced
+		"(    ) Tj",                      // blank number on the wrapped part
+		"(100 ) Tj",                      // last line number, on page 2
+	} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("PDF lacks %q", want)
+		}
+	}
+
+	// The PDF is only replaced with :hardcopy!
+	e.execCommand("hardcopy")
+	if !strings.Contains(e.statusMessage, "main.pdf exists") {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.execCommand("hardcopy!")
+	if !strings.HasPrefix(e.statusMessage, "printed 2 pages") {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestHardcopyNextToFile(t *testing.T) {
+	dir := t.TempDir()
+	e := newTestEditor("“quoted” — €")
+	e.filename = filepath.Join(dir, "notes.txt")
+	e.execCommand("hardcopy")
+	if e.statusMessage != "printed 1 pages to "+filepath.Join(dir, "notes.pdf") {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "notes.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `(\223quoted\224 \227 \200) Tj`) {
+		t.Fatal("WinAnsi punctuation not encoded")
+	}
+}
@@ -1,9 +1,12 @@
 package editor
 
 import (
+	"context"
 	"testing"
 
 	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/config"
 )
 
 func TestSearchEntryHotkeys(t *testing.T) {
@@ -360,6 +363,50 @@ func TestExpandShrinkSelectionHotkeys(t *testing.T) {
 	}
 }
 
+func TestExpandSelectionConvertsByteColumns(t *testing.T) {
+	e := newTestEditor("x := \"中😀\" + y")
+	e.filename = "test.go"
+	e.cursor = Cursor{Row: 0, Col: 12}
+	var gotCol int
+	e.nodeStackFunc = func(path string, row, col int) []NodeRange {
+		gotCol = col
+		// "y" is at byte 17; the string literal spans bytes 5..14
+		return []NodeRange{
+			{StartRow: 0, StartCol: 17, EndRow: 0, EndCol: 18},
+			{StartRow: 0, StartCol: 5, EndRow: 0, EndCol: 14},
+		}
+	}
+	e.HandleKey(eventForKeyString(t, "alt+shift+up"))
+	if gotCol != 17 {
+		t.Fatalf("node stack col = %d, want byte col 17", gotCol)
+	}
+	if e.selectionStart.Col != 12 || e.selectionEnd.Col != 13 {
+		t.Fatalf("selection = %d..%d, want 12..13", e.selectionStart.Col, e.selectionEnd.Col)
+	}
+	e.HandleKey(eventForKeyString(t, "alt+shift+up"))
+	if e.selectionStart.Col != 5 || e.selectionEnd.Col != 9 {
+		t.Fatalf("selection = %d..%d, want 5..9", e.selectionStart.Col, e.selectionEnd.Col)
+	}
+}
+
+func TestLSPGotoConvertsUTF16Columns(t *testing.T) {
+	e := newTestEditor("s := \"😀\"; s2 := s")
+	e.filename = "test.go"
+	e.cursor = Cursor{Row: 0, Col: 15}
+	var gotCol int
+	e.lspGotoFunc = func(_ context.Context, method, path string, line, col int) ([]LSPLocation, error) {
+		gotCol = col
+		return []LSPLocation{{Path: "test.go", StartLine: 0, StartCol: 11, EndLine: 0, EndCol: 13}}, nil
+	}
+	e.lspGoto("definition")
+	if gotCol != 16 {
+		t.Fatalf("LSP col = %d, want UTF-16 col 16", gotCol)
+	}
+	if e.cursor.Col != 10 {
+		t.Fatalf("cursor col = %d, want rune col 10", e.cursor.Col)
+	}
+}
+
 func TestSaveHotkeyNoFilename(t *testing.T) {
 	e := newTestEditor("one")
 	e.HandleKey(eventForKeyString(t, "cmd+s"))
@@ -367,3 +414,101 @@ func TestSaveHotkeyNoFilename(t *testing.T) {
 		t.Fatalf("status = %q, want %q", e.statusMessage, "no file name")
 	}
 }
+
+func TestAltAsCmdTranslation(t *testing.T) {
+	e := newTestEditor("one two")
+	var got []string
+	e.actionHook = func(action string) {
+		got = append(got, action)
+	}
+	e.SetTerminalFeatures(config.TerminalFeatures{AltAsCmd: true, MetaSendsEscape: true})
+
+	// alt+a -> cmd+a (select_all)
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModAlt))
+	if len(got) != 1 || got[0] != actionSelectAll {
+		t.Fatalf("alt+a actions = %v, want [%s]", got, actionSelectAll)
+	}
+
+	// alt+shift+up has its own binding and must not be translated
+	got = nil
+	e.HandleKey(tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModAlt|tcell.ModShift))
+	if len(got) != 1 || got[0] != "expand_selection" {
+		t.Fatalf("alt+shift+up actions = %v, want [expand_selection]", got)
+	}
+
+	// Without the feature alt+a behaves like a plain 'a'
+	got = nil
+	e.SetTerminalFeatures(config.TerminalFeatures{MetaSendsEscape: true})
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModAlt))
+	if len(got) != 1 || got[0] != actionAppend {
+		t.Fatalf("alt+a actions = %v, want [%s]", got, actionAppend)
+	}
+}
+
+func TestMetaWithoutEscape(t *testing.T) {
+	e := newTestEditor("one", "two")
+	e.SetTerminalFeatures(config.TerminalFeatures{MetaSendsEscape: false})
+	e.mode = ModeInsert
+
+	// Esc and j typed quickly arrive as alt+j: leave insert mode, move down
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModAlt))
+	if e.mode != ModeNormal || e.cursor.Row != 1 || e.Content() != "one\ntwo" {
+		t.Fatalf("mode %v, cursor %+v, content %q", e.mode, e.cursor, e.Content())
+	}
+}
+
+func TestBracketedPasteInsertsLiterally(t *testing.T) {
+	e := newTestEditor("")
+	e.mode = ModeInsert
+	e.HandlePaste(true)
+	for _, r := range "i:" {
+		e.HandleKey(tcell.NewEventKey(tcell.KeyRune, r, 0))
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEscape, 0, 0))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'x', 0))
+	e.HandlePaste(false)
+	if e.Content() != "i:\nx" {
+		t.Fatalf("content = %q, want %q", e.Content(), "i:\nx")
+	}
+	if e.mode != ModeInsert {
+		t.Fatalf("mode = %v, want insert", e.mode)
+	}
+
+	e.mode = ModeNormal
+	e.HandlePaste(true)
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'd', 0))
+	e.HandlePaste(false)
+	if e.Content() != "i:\nx" {
+		t.Fatalf("normal-mode paste changed content: %q", e.Content())
+	}
+}
+
+func TestSmartHomeEndHotkeys(t *testing.T) {
+	e := newTestEditor("  code  ")
+	e.cursor.Col = 4
+	steps := []struct {
+		key  string
+		want int
+	}{
+		{"home", 2},
+		{"home", 0},
+		{"home", 2},
+		{"end", 6},
+		{"end", 8},
+		{"end", 6},
+	}
+	for _, step := range steps {
+		e.HandleKey(eventForKeyString(t, step.key))
+		if e.cursor.Col != step.want {
+			t.Fatalf("%s: col = %d, want %d", step.key, e.cursor.Col, step.want)
+		}
+	}
+
+	e.cursor.Col = 0
+	e.HandleKey(keyRune('g'))
+	e.HandleKey(keyRune('l'))
+	if e.cursor.Col != 6 {
+		t.Fatalf("gl: col = %d, want 6 (before trailing spaces)", e.cursor.Col)
+	}
+}
@@ -292,7 +292,6 @@ func TestMatchModeHotkeys(t *testing.T) {
 		{'a', "select around (not implemented)"},
 		{'i', "select inside (not implemented)"},
 		{'s', "surround add (not implemented)"},
-		{'r', "surround replace (not implemented)"},
 		{'d', "surround delete (not implemented)"},
 	}
 	for _, tt := range cases {
@@ -343,15 +342,15 @@ func TestSpaceMenuHotkeys(t *testing.T) {
 			e := newTestEditor("line")
 			e.filename = "test.go"
 			e.HandleKey(keyRune(' '))
-			if !e.spaceMenuActive {
-				t.Fatalf("spaceMenuActive = false, want true")
+			if !e.inSequence(seqSpace) {
+				t.Fatalf("space menu not open")
 			}
 			e.HandleKey(keyRune(item.Key))
-			if e.spaceMenuActive {
-				t.Fatalf("spaceMenuActive = true, want false")
+			if e.inSequence(seqSpace) {
+				t.Fatalf("space menu still open")
 			}
-			if item.Action != "window_mode" && e.pendingKeys != "" {
-				t.Fatalf("pendingKeys = %q, want empty", e.pendingKeys)
+			if item.Action != "window_mode" && e.PendingKeys() != "" {
+				t.Fatalf("PendingKeys() = %q, want empty", e.PendingKeys())
 			}
 			wantLast := "SPC " + string(item.Key)
 			if item.Action == "yank_clipboard" || item.Action == "yank_main_clipboard" {
@@ -367,13 +366,13 @@ func TestSpaceMenuHotkeys(t *testing.T) {
 				}
 			}
 			if item.Action == "window_mode" {
-				if !e.windowMode || e.pendingKeys != "SPC w" {
-					t.Fatalf("windowMode=%v pendingKeys=%q, want true/\"SPC w\"", e.windowMode, e.pendingKeys)
+				if !e.inSequence(seqWindow) || e.PendingKeys() != "SPC w" {
+					t.Fatalf("window mode=%v PendingKeys()=%q, want true/\"SPC w\"", e.inSequence(seqWindow), e.PendingKeys())
 				}
 			}
 			if item.Action == "show_keybindings" {
-				if !e.keybindingsHelpActive {
-					t.Fatalf("keybindingsHelpActive = false, want true")
+				if !e.popupOpen(keybindingsHelpPopup{}) {
+					t.Fatalf("keybindings help not open")
 				}
 			}
 			if item.Action == "toggle_comment" {
@@ -389,12 +388,12 @@ func TestWindowModeHotkeys(t *testing.T) {
 	e := newTestEditor("one")
 	e.HandleKey(keyRune(' '))
 	e.HandleKey(keyRune('w'))
-	if !e.windowMode {
-		t.Fatalf("windowMode = false, want true")
+	if !e.inSequence(seqWindow) {
+		t.Fatalf("window mode not pending")
 	}
 	e.HandleKey(keyRune('v'))
-	if e.windowMode {
-		t.Fatalf("windowMode = true, want false")
+	if e.inSequence(seqWindow) {
+		t.Fatalf("window mode still pending")
 	}
 	if e.statusMessage != "window mode (not implemented)" {
 		t.Fatalf("status = %q, want %q", e.statusMessage, "window mode (not implemented)")
@@ -408,8 +407,8 @@ func TestKeybindingsHelpHotkeys(t *testing.T) {
 	e := newTestEditor("one")
 	e.HandleKey(keyRune(' '))
 	e.HandleKey(keyRune('?'))
-	if !e.keybindingsHelpActive {
-		t.Fatalf("keybindingsHelpActive = false, want true")
+	if !e.popupOpen(keybindingsHelpPopup{}) {
+		t.Fatalf("keybindings help not open")
 	}
 	e.HandleKey(tcell.NewEventKey(tcell.KeyDown, 0, 0))
 	if e.keybindingsHelpScroll != 1 {
@@ -430,7 +429,7 @@ func TestKeybindingsHelpHotkeys(t *testing.T) {
 
 	// Close with Escape
 	e.HandleKey(tcell.NewEventKey(tcell.KeyEscape, 0, 0))
-	if e.keybindingsHelpActive {
+	if e.popupOpen(keybindingsHelpPopup{}) {
 		t.Fatalf("esc close = true, want false")
 	}
 
@@ -438,7 +437,7 @@ func TestKeybindingsHelpHotkeys(t *testing.T) {
 	e.HandleKey(keyRune(' '))
 	e.HandleKey(keyRune('?'))
 	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
-	if e.keybindingsHelpActive {
+	if e.popupOpen(keybindingsHelpPopup{}) {
 		t.Fatalf("enter close = true, want false")
 	}
 }
@@ -532,3 +531,79 @@ func TestGotoLinePromptHotkey(t *testing.T) {
 		t.Fatalf("cmd=%q cursor=%d, want empty/0", string(e.cmd), e.cmdCursor)
 	}
 }
+
+func TestMatchBracketFromTree(t *testing.T) {
+	e := newTestEditor(`g("(", h(1))`)
+	e.filename = "main.go"
+	e.bracketPairFunc = func(path string, row, col int) (BracketPair, bool) {
+		if col >= 8 && col <= 10 {
+			return BracketPair{OpenCol: 8, CloseCol: 10}, true
+		}
+		return BracketPair{OpenCol: 1, CloseCol: 11}, true
+	}
+	mm := func(col int) int {
+		e.cursor = Cursor{Row: 0, Col: col}
+		e.HandleKey(keyRune('m'))
+		e.HandleKey(keyRune('m'))
+		return e.cursor.Col
+	}
+	if got := mm(1); got != 11 {
+		t.Fatalf("mm on ( = col %d, want 11", got)
+	}
+	if got := mm(11); got != 1 {
+		t.Fatalf("mm on ) = col %d, want 1", got)
+	}
+	if got := mm(9); got != 10 {
+		t.Fatalf("mm inside h(...) = col %d, want 10", got)
+	}
+	// The tree doesn't pair the bracket in the string, the text scan does
+	if got := mm(3); got != 11 {
+		t.Fatalf("mm on ( in string = col %d, want 11", got)
+	}
+}
+
+func TestMatchBracketFromInsideWithoutTree(t *testing.T) {
+	e := newTestEditor("f(a, [b], c)")
+	e.cursor = Cursor{Row: 0, Col: 10}
+	e.HandleKey(keyRune('m'))
+	e.HandleKey(keyRune('m'))
+	if e.cursor.Col != 11 {
+		t.Fatalf("mm inside () = col %d, want 11", e.cursor.Col)
+	}
+	e.cursor = Cursor{Row: 0, Col: 6}
+	e.HandleKey(keyRune('m'))
+	e.HandleKey(keyRune('m'))
+	if e.cursor.Col != 7 {
+		t.Fatalf("mm inside [] = col %d, want 7", e.cursor.Col)
+	}
+}
+
+func TestReplaceMatchingPair(t *testing.T) {
+	e := newTestEditor("f(a, [b])", `s := "x"`)
+	mr := func(row, col int, ch rune) {
+		e.cursor = Cursor{Row: row, Col: col}
+		for _, r := range []rune{'m', 'r', ch} {
+			e.HandleKey(keyRune(r))
+		}
+	}
+	mr(0, 8, '{')
+	if got := e.Content(); got != "f{a, [b]}\ns := \"x\"" {
+		t.Fatalf("mr{ on ) = %q", got)
+	}
+	mr(0, 5, ')')
+	if got := e.Content(); got != "f{a, (b)}\ns := \"x\"" {
+		t.Fatalf("mr) on [ = %q", got)
+	}
+	mr(1, 7, '`')
+	if got := e.Content(); got != "f{a, (b)}\ns := `x`" {
+		t.Fatalf("mr` on \" = %q", got)
+	}
+	e.Undo()
+	if got := e.Content(); got != "f{a, (b)}\ns := \"x\"" {
+		t.Fatalf("undo = %q", got)
+	}
+	mr(0, 2, '(')
+	if e.statusMessage != "no bracket or quote under cursor" {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+}
@@ -0,0 +1,80 @@
+package editor
+
+import (
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Input methods (CJK, compose keys) build text outside the key stream and
+// terminals don't report the text still being composed, only the result:
+// the terminal gets it in one write and tcell queues its runes together.
+// The event loop reads such a burst with ReadComposedBurst and hands it to
+// CommitComposition, so it goes in as one undo step and skips autocorrect
+// and the other per-key insert hooks.
+
+// AcceptsComposition reports whether keys would type into the buffer: insert
+// mode outside a bracketed paste, with no popup or sidebar taking the keys
+func (e *Editor) AcceptsComposition() bool {
+	if e.mode != ModeInsert || e.pasting || e.modalPopupOpen() {
+		return false
+	}
+	return e.sidebar == nil || !e.sidebar.Visible || !e.sidebar.Focused
+}
+
+// CommitComposition inserts text at the cursor as a single undo step
+func (e *Editor) CommitComposition(text string) {
+	if text == "" || !e.AcceptsComposition() {
+		return
+	}
+	e.clearSelection()
+	if end, err := e.InsertAt(e.cursor, text); err == nil {
+		e.cursor = end
+	}
+}
+
+// isComposedBurst reports whether runes that arrived together are text
+// committed by an input method rather than keys typed fast: more than one
+// rune and none of them ASCII, which the keyboard types itself
+func isComposedBurst(runes []rune) bool {
+	if len(runes) < 2 {
+		return false
+	}
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadComposedBurst collects the plain rune keys already queued behind ev,
+// without waiting for more. It returns the text when they are input method
+// output, "" when ev and the keys should be handled one by one, and the
+// first other event read, to be handled after them. Keys typed by hand
+// that queue up while the editor is busy are ASCII or are a layout's
+// letters; the latter only lose their per-key undo steps.
+func ReadComposedBurst(s tcell.Screen, ev *tcell.EventKey) ([]*tcell.EventKey, string, tcell.Event) {
+	keys := []*tcell.EventKey{ev}
+	if ev.Key() != tcell.KeyRune || ev.Modifiers() != 0 {
+		return keys, "", nil
+	}
+	var next tcell.Event
+	for s.HasPendingEvent() {
+		ev := s.PollEvent()
+		key, ok := ev.(*tcell.EventKey)
+		if !ok || key.Key() != tcell.KeyRune || key.Modifiers() != 0 {
+			next = ev
+			break
+		}
+		keys = append(keys, key)
+	}
+	runes := make([]rune, len(keys))
+	for i, key := range keys {
+		runes[i] = key.Rune()
+	}
+	if !isComposedBurst(runes) {
+		return keys, "", next
+	}
+	return nil, string(runes), next
+}
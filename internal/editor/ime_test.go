@@ -0,0 +1,72 @@
+package editor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestCommitCompositionIsOneUndoStep(t *testing.T) {
+	e := newTestEditor("ab")
+	e.mode = ModeInsert
+	e.cursor = Cursor{Row: 0, Col: 1}
+	e.CommitComposition("你好")
+	if got := e.Content(); got != "a你好b" {
+		t.Fatalf("content %q", got)
+	}
+	if e.cursor != (Cursor{Row: 0, Col: 3}) {
+		t.Fatalf("cursor %+v", e.cursor)
+	}
+	e.Undo()
+	if got := e.Content(); got != "ab" {
+		t.Fatalf("after undo: %q", got)
+	}
+
+	// Outside insert mode nothing is composed
+	e.mode = ModeNormal
+	e.CommitComposition("你好")
+	if got := e.Content(); got != "ab" {
+		t.Fatalf("normal mode: %q", got)
+	}
+}
+
+func TestReadComposedBurst(t *testing.T) {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init screen: %v", err)
+	}
+	t.Cleanup(s.Fini)
+	for _, r := range "好世" {
+		s.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+	s.InjectKey(tcell.KeyEnter, 0, tcell.ModNone)
+	waitPending(t, s)
+	keys, text, next := ReadComposedBurst(s, keyRune('你'))
+	if keys != nil || text != "你好世" {
+		t.Fatalf("keys %d, text %q", len(keys), text)
+	}
+	if key, ok := next.(*tcell.EventKey); !ok || key.Key() != tcell.KeyEnter {
+		t.Fatalf("next %T", next)
+	}
+
+	// ASCII typed fast is still handled key by key
+	s.InjectKey(tcell.KeyRune, 'b', tcell.ModNone)
+	waitPending(t, s)
+	keys, text, next = ReadComposedBurst(s, keyRune('a'))
+	if len(keys) != 2 || text != "" || next != nil {
+		t.Fatalf("keys %d, text %q, next %v", len(keys), text, next)
+	}
+}
+
+// waitPending waits until the injected events reach the screen's queue
+func waitPending(t *testing.T, s tcell.Screen) {
+	t.Helper()
+	for range 1000 {
+		if s.HasPendingEvent() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("no event queued")
+}
@@ -0,0 +1,109 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// indentUnit is what Tab in normal mode and > insert for one level
+func (e *Editor) indentUnit() []rune {
+	if e.expandTab {
+		return []rune(strings.Repeat(" ", e.indentWidth))
+	}
+	return []rune{'\t'}
+}
+
+// applyBufferSettings sets the settings kept per buffer for the open
+// file: its :tabwidth, or the configured width, and :conceal
+func (e *Editor) applyBufferSettings() {
+	e.tabWidth = e.defaultTabWidth
+	e.conceal = false
+	if i := e.currentBuffer(); i >= 0 {
+		if e.buffers[i].tabWidth > 0 {
+			e.tabWidth = e.buffers[i].tabWidth
+		}
+		e.conceal = e.buffers[i].conceal
+	}
+}
+
+// execTabWidthCommand shows or sets how wide tabs are drawn in this
+// buffer (:tabwidth [N]). The indent width used with spaces stays as it is.
+func (e *Editor) execTabWidthCommand(args []string) {
+	if len(args) == 0 {
+		e.setStatus("tabwidth " + strconv.Itoa(e.tabWidth))
+		return
+	}
+	n, err := strconv.Atoi(args[0])
+	if len(args) > 1 || err != nil || n < 1 || n > 16 {
+		e.setStatus("usage: :tabwidth [1-16]")
+		return
+	}
+	e.tabWidth = n
+	if i := e.currentBuffer(); i >= 0 {
+		e.buffers[i].tabWidth = n
+	}
+	e.setStatus("tabwidth " + strconv.Itoa(n))
+}
+
+// execRetabCommand converts the indentation of the buffer, or of the
+// lines of the selection, to tabs or spaces (:retab [tabs|spaces]).
+// Without an argument it follows expand-tab. Columns are kept as they are
+// drawn with the buffer's tab width; spaces left over when converting to
+// tabs stay spaces. The change is one undo step.
+func (e *Editor) execRetabCommand(args []string) {
+	toSpaces := e.expandTab
+	if len(args) > 0 {
+		switch args[0] {
+		case "spaces":
+			toSpaces = true
+		case "tabs":
+			toSpaces = false
+		default:
+			e.setStatus("usage: :retab [tabs|spaces]")
+			return
+		}
+	}
+
+	from, to := 0, len(e.lines)-1
+	if start, end, ok := e.selectionRange(); ok {
+		from, to = start.Row, end.Row
+		if end.Col == 0 && end.Row > start.Row {
+			to--
+		}
+	}
+
+	lines := append([][]rune(nil), e.lines...)
+	changed := 0
+	for row := from; row <= to; row++ {
+		if retabbed, ok := e.retabLine(e.lines[row], toSpaces); ok {
+			lines[row] = retabbed
+			changed++
+		}
+	}
+	if changed == 0 {
+		e.setStatus("retab: nothing to change")
+		return
+	}
+	cursor := e.cursor
+	e.replaceLines(lines)
+	e.cursor = cursor
+	e.clampCursorCol()
+	e.setStatus("retab: " + strconv.Itoa(changed) + " lines")
+}
+
+// retabLine rebuilds the leading whitespace of line with tabs or spaces
+// and reports whether that changed it
+func (e *Editor) retabLine(line []rune, toSpaces bool) ([]rune, bool) {
+	n := firstNonBlank(line)
+	width := visualCol(line, n, e.tabWidth)
+	var indent []rune
+	if toSpaces {
+		indent = []rune(strings.Repeat(" ", width))
+	} else {
+		indent = []rune(strings.Repeat("\t", width/e.tabWidth) + strings.Repeat(" ", width%e.tabWidth))
+	}
+	if string(indent) == string(line[:n]) {
+		return nil, false
+	}
+	return append(indent, line[n:]...), true
+}
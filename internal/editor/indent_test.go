@@ -0,0 +1,108 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetab(t *testing.T) {
+	e := newTestEditor("\tif x {", "\t\treturn", "  \tdone", "   odd", "}")
+	e.execCommand("retab spaces")
+	want := "    if x {\n        return\n    done\n   odd\n}"
+	if got := e.Content(); got != want {
+		t.Fatalf("retab spaces = %q, want %q", got, want)
+	}
+	if e.statusMessage != "retab: 3 lines" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+
+	// One undo step brings the tabs back
+	e.Undo()
+	if got := e.Content(); got != "\tif x {\n\t\treturn\n  \tdone\n   odd\n}" {
+		t.Fatalf("undo = %q", got)
+	}
+
+	// Only the selected lines; leftover columns stay spaces
+	e.execCommand("retab spaces")
+	e.selectionActive = true
+	e.selectionStart, e.selectionEnd = Cursor{Row: 1}, Cursor{Row: 4}
+	e.execCommand("retab tabs")
+	want = "    if x {\n\t\treturn\n\tdone\n   odd\n}"
+	if got := e.Content(); got != want {
+		t.Fatalf("retab tabs = %q, want %q", got, want)
+	}
+	e.execCommand("retab tabs")
+	if e.statusMessage != "retab: nothing to change" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.execCommand("retab both")
+	if e.statusMessage != "usage: :retab [tabs|spaces]" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestTabWidthAndExpandTab(t *testing.T) {
+	e := newTestEditor("\tx")
+	e.execCommand("tabwidth 8")
+	if e.tabWidth != 8 || e.indentWidth != 4 || e.statusMessage != "tabwidth 8" {
+		t.Fatalf("tabWidth %d indentWidth %d status %q", e.tabWidth, e.indentWidth, e.statusMessage)
+	}
+	e.execCommand("retab spaces")
+	if got := e.Content(); got != "        x" {
+		t.Fatalf("retab with tabwidth 8 = %q", got)
+	}
+	e.execCommand("tabwidth 0")
+	if e.statusMessage != "usage: :tabwidth [1-16]" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+
+	// With expand-tab indenting inserts indent-width spaces
+	e.expandTab = true
+	e.indentCurrentLine()
+	if got := e.Content(); got != "            x" || e.cursor.Col != 4 {
+		t.Fatalf("indent = %q cursor %d", got, e.cursor.Col)
+	}
+	e.Undo()
+	if got := e.Content(); got != "        x" {
+		t.Fatalf("undo indent = %q", got)
+	}
+	e.unindentSelection()
+	if got := e.Content(); got != "    x" {
+		t.Fatalf("unindent = %q", got)
+	}
+	e.mode = ModeInsert
+	e.cursor.Col = 5
+	e.insertTab()
+	if got := e.Content(); got != "    x   " {
+		t.Fatalf("insert tab = %q", got)
+	}
+}
+
+func TestTabWidthPerBuffer(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("\tx"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := newTestEditor()
+	e.DisableState()
+	if err := e.OpenFile(a); err != nil {
+		t.Fatal(err)
+	}
+	e.execCommand("tabwidth 2")
+	if err := e.OpenFile(b); err != nil {
+		t.Fatal(err)
+	}
+	if e.tabWidth != 4 {
+		t.Fatalf("b tabWidth = %d, want the configured 4", e.tabWidth)
+	}
+	if err := e.OpenFile(a); err != nil {
+		t.Fatal(err)
+	}
+	if e.tabWidth != 2 {
+		t.Fatalf("a tabWidth = %d, want 2", e.tabWidth)
+	}
+}
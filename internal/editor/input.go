@@ -0,0 +1,156 @@
+package editor
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// sequenceKind is what an unfinished key sequence waits for
+type sequenceKind int
+
+const (
+	seqNone   sequenceKind = iota
+	seqGoto                // g: goto menu
+	seqMatch               // m: match menu
+	seqView                // z: view menu
+	seqSpace               // Space: space menu
+	seqWindow              // Space w: window menu
+	seqNext                // ]: next menu
+	seqPrev                // [: previous menu
+	seqChar                // f/F/t/T/r/mr: any character
+)
+
+// keySequence is a multi-key sequence that has started but not finished.
+// Every prefix (g, m, z, Space, Space w, f...) goes through it, so Esc,
+// the timeout and the statusline treat them all the same way.
+type keySequence struct {
+	kind   sequenceKind
+	keys   string    // keys typed so far for display, e.g. "g" or "SPC w"
+	action string    // action the character completes (seqChar)
+	count  int       // count typed before the sequence (3f), 0 if none
+	since  time.Time // when the last key of the sequence was typed
+	start  time.Time // when the sequence began, for which-key-delay
+}
+
+// beginSequence waits for the next key of a sequence
+func (e *Editor) beginSequence(kind sequenceKind, keys string) {
+	now := time.Now()
+	e.sequence = keySequence{kind: kind, keys: keys, since: now, start: now}
+	e.menuScroll, e.menuPage, e.menuRows = 0, 0, 0
+}
+
+// beginCharSequence waits for the character that completes action
+func (e *Editor) beginCharSequence(action, keys string) {
+	count := e.count
+	if count > 0 {
+		keys = strconv.Itoa(count) + keys
+	}
+	e.beginSequence(seqChar, keys)
+	e.sequence.action = action
+	e.sequence.count = count
+}
+
+// maxCount caps a typed count so a stray run of digits can't hang the editor
+const maxCount = 9999
+
+// addCountDigit adds a digit key to the count typed before a normal-mode
+// key. 0 only counts after another digit. Digits bound in the keymap are
+// never counts.
+func (e *Editor) addCountDigit(ev *tcell.EventKey) bool {
+	if ev.Key() != tcell.KeyRune || ev.Modifiers() != 0 {
+		return false
+	}
+	ch := ev.Rune()
+	if ch < '0' || ch > '9' || (ch == '0' && e.count == 0) {
+		return false
+	}
+	e.count = min(e.count*10+int(ch-'0'), maxCount)
+	return true
+}
+
+// cancelSequence drops the pending sequence
+func (e *Editor) cancelSequence() {
+	e.sequence = keySequence{}
+}
+
+// inSequence reports whether a sequence of the given kind is pending
+func (e *Editor) inSequence(kind sequenceKind) bool {
+	return e.sequence.kind == kind
+}
+
+// PendingKeys returns the keys of the unfinished sequence or the count
+// typed so far, or ""
+func (e *Editor) PendingKeys() string {
+	if e.sequence.kind == seqNone && e.count > 0 {
+		return strconv.Itoa(e.count)
+	}
+	return e.sequence.keys
+}
+
+// ExpirePendingKeys cancels a sequence whose next key hasn't come within
+// the key timeout and reports whether it did
+func (e *Editor) ExpirePendingKeys(now time.Time) bool {
+	if e.sequence.kind == seqNone || e.keyTimeout <= 0 {
+		return false
+	}
+	if now.Sub(e.sequence.since) < e.keyTimeout {
+		return false
+	}
+	e.cancelSequence()
+	return true
+}
+
+// menuShown reports whether the menu of the pending sequence is drawn: at
+// once, or after which-key-delay without the next key
+func (e *Editor) menuShown() bool {
+	return e.whichKeyDelay <= 0 || time.Since(e.sequence.start) >= e.whichKeyDelay
+}
+
+// MenuShowsAt returns when the menu held back by which-key-delay is due,
+// or the zero time if no menu is waiting
+func (e *Editor) MenuShowsAt() time.Time {
+	if e.sequence.kind == seqNone || e.sequence.kind == seqChar || e.menuShown() {
+		return time.Time{}
+	}
+	return e.sequence.start.Add(e.whichKeyDelay)
+}
+
+// handleSequenceKey finishes the pending sequence with ev. Esc aborts any
+// sequence; keys other than characters abort it too, except the arrow and
+// page keys, which scroll a menu too tall for the screen.
+func (e *Editor) handleSequenceKey(ev *tcell.EventKey) bool {
+	seq := e.sequence
+	if seq.kind != seqChar && e.scrollMenu(ev) {
+		return false
+	}
+	e.cancelSequence()
+	if ev.Key() != tcell.KeyRune {
+		return false
+	}
+	ch := ev.Rune()
+	if seq.kind != seqChar {
+		ch = e.langmapRune(ch, nil)
+	}
+	switch seq.kind {
+	case seqGoto:
+		return e.handleGotoKey(ch)
+	case seqMatch:
+		return e.handleMatchKey(ch)
+	case seqView:
+		return e.handleViewKey(ch)
+	case seqSpace:
+		return e.handleSpaceKey(ch)
+	case seqWindow:
+		return e.handleWindowKey(ch)
+	case seqNext:
+		return e.handleNextKey(ch)
+	case seqPrev:
+		return e.handlePrevKey(ch)
+	case seqChar:
+		e.handlePendingChar(seq.action, ch, seq.count)
+		e.lastCommand = seq.keys + string(ch)
+	}
+	return false
+}
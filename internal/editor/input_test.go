@@ -0,0 +1,166 @@
+package editor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestKeySequencePrefixes(t *testing.T) {
+	tests := []struct {
+		key  rune
+		kind sequenceKind
+		keys string
+	}{
+		{'g', seqGoto, "g"},
+		{'m', seqMatch, "m"},
+		{'z', seqView, "z"},
+		{' ', seqSpace, "SPC"},
+		{'f', seqChar, "f"},
+		{'T', seqChar, "T"},
+		{'r', seqChar, "r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keys, func(t *testing.T) {
+			e := newTestEditor("one two", "three")
+			e.HandleKey(keyRune(tt.key))
+			if !e.inSequence(tt.kind) || e.PendingKeys() != tt.keys {
+				t.Fatalf("sequence = %+v, want kind %d keys %q", e.sequence, tt.kind, tt.keys)
+			}
+			// Esc aborts every kind of sequence the same way
+			e.HandleKey(keyEsc())
+			if e.PendingKeys() != "" || !e.inSequence(seqNone) {
+				t.Fatalf("sequence after Esc = %+v, want none", e.sequence)
+			}
+			if e.cursor != (Cursor{}) || e.Content() != "one two\nthree" {
+				t.Fatalf("Esc ran an action: cursor %v content %q", e.cursor, e.Content())
+			}
+		})
+	}
+}
+
+func TestKeySequenceNonRuneAborts(t *testing.T) {
+	e := newTestEditor("one", "two")
+	e.HandleKey(keyRune('g'))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyDown, 0, 0))
+	if e.PendingKeys() != "" {
+		t.Fatalf("PendingKeys() = %q, want empty", e.PendingKeys())
+	}
+	if e.cursor.Row != 0 {
+		t.Fatalf("aborting key moved the cursor to row %d", e.cursor.Row)
+	}
+}
+
+func TestKeySequenceChar(t *testing.T) {
+	e := newTestEditor("one two")
+	e.HandleKey(keyRune('f'))
+	e.HandleKey(keyRune('t'))
+	if e.cursor.Col != 4 {
+		t.Fatalf("cursor col = %d, want 4", e.cursor.Col)
+	}
+	if e.lastCommand != "ft" || e.PendingKeys() != "" {
+		t.Fatalf("lastCommand = %q PendingKeys() = %q", e.lastCommand, e.PendingKeys())
+	}
+
+	// Space w replaces the space menu with the window sequence
+	e.HandleKey(keyRune(' '))
+	e.HandleKey(keyRune('w'))
+	if !e.inSequence(seqWindow) || e.PendingKeys() != "SPC w" {
+		t.Fatalf("sequence = %+v, want window", e.sequence)
+	}
+}
+
+func TestExpirePendingKeys(t *testing.T) {
+	e := newTestEditor("one")
+	e.HandleKey(keyRune('g'))
+	if e.ExpirePendingKeys(time.Now().Add(time.Hour)) {
+		t.Fatalf("sequence expired without a key timeout")
+	}
+
+	e.keyTimeout = 500 * time.Millisecond
+	start := e.sequence.since
+	if e.ExpirePendingKeys(start.Add(400 * time.Millisecond)) {
+		t.Fatalf("sequence expired before the timeout")
+	}
+	if !e.ExpirePendingKeys(start.Add(500 * time.Millisecond)) {
+		t.Fatalf("sequence did not expire at the timeout")
+	}
+	if e.PendingKeys() != "" {
+		t.Fatalf("PendingKeys() = %q after expiry", e.PendingKeys())
+	}
+	if e.ExpirePendingKeys(start.Add(time.Hour)) {
+		t.Fatalf("expired with nothing pending")
+	}
+}
+
+func TestRepeatFindChar(t *testing.T) {
+	e := newTestEditor("a,b,c,d,e", "f,g")
+	alt := func(r rune) *tcell.EventKey { return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModAlt) }
+
+	e.HandleKey(alt('.'))
+	if e.statusMessage != "no previous find" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+
+	// A count goes to f and shows while it is typed
+	e.HandleKey(keyRune('2'))
+	if e.PendingKeys() != "2" {
+		t.Fatalf("PendingKeys() = %q, want 2", e.PendingKeys())
+	}
+	e.HandleKey(keyRune('f'))
+	if e.PendingKeys() != "2f" {
+		t.Fatalf("PendingKeys() = %q, want 2f", e.PendingKeys())
+	}
+	e.HandleKey(keyRune(','))
+	if e.cursor.Col != 3 {
+		t.Fatalf("2f, = %d, want 3", e.cursor.Col)
+	}
+	if start, end, ok := e.selectionRange(); !ok || start.Col != 0 || end.Col != 4 {
+		t.Fatalf("selection = %v %v %v, want 0..4", start, end, ok)
+	}
+
+	e.HandleKey(alt('.'))
+	if e.cursor.Col != 5 {
+		t.Fatalf("Alt+. = %d, want 5", e.cursor.Col)
+	}
+	e.HandleKey(alt(','))
+	if e.cursor.Col != 3 {
+		t.Fatalf("Alt+, = %d, want 3", e.cursor.Col)
+	}
+	e.HandleKey(keyRune('3'))
+	e.HandleKey(alt('.'))
+	if e.cursor.Col != 7 || e.count != 0 {
+		t.Fatalf("3 Alt+. = %d (count %d), want 7", e.cursor.Col, e.count)
+	}
+
+	// Other characters stay on the line unless :findlines is on
+	e.HandleKey(alt('.'))
+	if e.cursor.Row != 0 || e.cursor.Col != 7 {
+		t.Fatalf("Alt+. crossed a line: %+v", e.cursor)
+	}
+	e.execCommand("findlines on")
+	if e.statusMessage != "findlines on" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.HandleKey(alt('.'))
+	if e.cursor.Row != 1 || e.cursor.Col != 1 {
+		t.Fatalf("Alt+. with findlines = %+v, want 1:1", e.cursor)
+	}
+}
+
+func TestCountRepeatsMotion(t *testing.T) {
+	e := newTestEditor("one", "two", "three", "four")
+	e.HandleKey(keyRune('2'))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyDown, 0, 0))
+	if e.cursor.Row != 2 {
+		t.Fatalf("2 Down = row %d, want 2", e.cursor.Row)
+	}
+	// An unbound key drops the count
+	e.HandleKey(keyRune('5'))
+	e.HandleKey(keyRune('Q'))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyUp, 0, 0))
+	if e.cursor.Row != 1 || e.PendingKeys() != "" {
+		t.Fatalf("row = %d pending = %q, want 1 and none", e.cursor.Row, e.PendingKeys())
+	}
+}
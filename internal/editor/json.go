@@ -0,0 +1,329 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kobzarvs/qedit/internal/platform/clipboard"
+)
+
+// maxJSONPathWidth limits the JSON path shown in the statusline
+const maxJSONPathWidth = 40
+
+func isJSONFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".geojson":
+		return true
+	default:
+		return false
+	}
+}
+
+// execJSONCommand runs :json fmt|min|path
+func (e *Editor) execJSONCommand(args []string) {
+	sub := ""
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	switch sub {
+	case "fmt":
+		if err := e.FormatJSON(false); err != nil {
+			e.setStatus(err.Error())
+			return
+		}
+		e.setStatus("json formatted")
+	case "min":
+		if err := e.FormatJSON(true); err != nil {
+			e.setStatus(err.Error())
+			return
+		}
+		e.setStatus("json minified")
+	case "path":
+		path := e.jsonPathAtCursor()
+		e.clipboard = [][]rune{[]rune(path)}
+		if err := clipboard.Write(path); err != nil {
+			e.setStatus(path + " (clipboard unavailable)")
+			return
+		}
+		e.setStatus("copied " + path)
+	default:
+		e.setStatus("usage: :json fmt|min|path")
+	}
+}
+
+// FormatJSON pretty-prints (indented with tab-width spaces) or minifies the
+// buffer as a single undo step. Key order and string contents are kept and
+// the cursor stays on the same token.
+func (e *Editor) FormatJSON(minify bool) error {
+	// --readonly only refuses the write
+	if e.fileReadOnly || e.preview {
+		return errors.New("buffer is read-only")
+	}
+	src := e.Content()
+	var out bytes.Buffer
+	var err error
+	if minify {
+		err = json.Compact(&out, []byte(src))
+	} else {
+		err = json.Indent(&out, []byte(strings.TrimSpace(src)), "", strings.Repeat(" ", max(e.tabWidth, 1)))
+	}
+	if err != nil {
+		return jsonError(src, err)
+	}
+	if strings.HasSuffix(src, "\n") {
+		out.WriteByte('\n')
+	}
+	formatted := out.String()
+	if formatted == src {
+		return nil
+	}
+
+	srcRunes := []rune(src)
+	token := jsonTokenIndex(srcRunes, e.runeOffset(e.cursor))
+	last := len(e.lines) - 1
+	if _, err := e.ReplaceRange(Cursor{}, Cursor{Row: last, Col: len(e.lines[last])}, formatted); err != nil {
+		return err
+	}
+	e.cursor = e.cursorAtRuneOffset(jsonTokenOffset([]rune(formatted), token))
+	e.selectionActive = false
+	e.clampCursorCol()
+	return nil
+}
+
+// jsonError adds the line and column to JSON syntax errors
+func jsonError(src string, err error) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return fmt.Errorf("json: %v", err)
+	}
+	offset := clampRange(int(syntaxErr.Offset), 0, len(src))
+	before := src[:offset]
+	line := strings.Count(before, "\n") + 1
+	col := len([]rune(before[strings.LastIndex(before, "\n")+1:]))
+	return fmt.Errorf("json: %v (line %d, col %d)", err, line, max(col, 1))
+}
+
+// jsonTokenIndex counts the runes before offset that survive reformatting:
+// everything except whitespace outside of strings
+func jsonTokenIndex(src []rune, offset int) int {
+	n := 0
+	inString, escape := false, false
+	for i := 0; i < offset && i < len(src); i++ {
+		r := src[i]
+		switch {
+		case inString:
+			n++
+			if escape {
+				escape = false
+			} else if r == '\\' {
+				escape = true
+			} else if r == '"' {
+				inString = false
+			}
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+		default:
+			n++
+			inString = r == '"'
+		}
+	}
+	return n
+}
+
+// jsonTokenOffset is the inverse of jsonTokenIndex
+func jsonTokenOffset(src []rune, index int) int {
+	n := 0
+	inString, escape := false, false
+	for i, r := range src {
+		significant := inString || !(r == ' ' || r == '\t' || r == '\n' || r == '\r')
+		if significant && n == index {
+			return i
+		}
+		switch {
+		case inString:
+			if escape {
+				escape = false
+			} else if r == '\\' {
+				escape = true
+			} else if r == '"' {
+				inString = false
+			}
+		case significant:
+			inString = r == '"'
+		}
+		if significant {
+			n++
+		}
+	}
+	return len(src)
+}
+
+// runeOffset returns the rune offset of pos in Content()
+func (e *Editor) runeOffset(pos Cursor) int {
+	offset := 0
+	for i := 0; i < pos.Row && i < len(e.lines); i++ {
+		offset += len(e.lines[i]) + 1
+	}
+	if pos.Row >= 0 && pos.Row < len(e.lines) {
+		offset += clampRange(pos.Col, 0, len(e.lines[pos.Row]))
+	}
+	return offset
+}
+
+// cursorAtRuneOffset is the inverse of runeOffset
+func (e *Editor) cursorAtRuneOffset(offset int) Cursor {
+	for row, line := range e.lines {
+		if offset <= len(line) {
+			return Cursor{Row: row, Col: max(offset, 0)}
+		}
+		offset -= len(line) + 1
+	}
+	last := len(e.lines) - 1
+	return Cursor{Row: last, Col: len(e.lines[last])}
+}
+
+// jsonPathFrame is an open object or array while scanning for the JSON path
+type jsonPathFrame struct {
+	array     bool
+	index     int
+	key       string
+	hasKey    bool
+	expectKey bool
+}
+
+// jsonPathAtCursor returns the jq-style path (".items[2].name") of the value
+// under the cursor. Scans up to the cursor without validating the document.
+func (e *Editor) jsonPathAtCursor() string {
+	var stack []jsonPathFrame
+	var key []rune
+	inString, isKey, escape := false, false, false
+
+	endKey := func() {
+		raw := string(key)
+		if s, err := strconv.Unquote(`"` + raw + `"`); err == nil {
+			raw = s
+		}
+		top := &stack[len(stack)-1]
+		top.key = raw
+		top.hasKey = true
+	}
+
+	for row := 0; row <= e.cursor.Row && row < len(e.lines); row++ {
+		line := e.lines[row]
+		end := len(line)
+		if row == e.cursor.Row {
+			end = clampRange(e.cursor.Col, 0, len(line))
+		}
+		for col, r := range line {
+			if col >= end {
+				// Finish a key under the cursor so the path includes it
+				if !inString || !isKey {
+					break
+				}
+			}
+			if inString {
+				switch {
+				case escape:
+					escape = false
+				case r == '\\':
+					escape = true
+				case r == '"':
+					inString = false
+					if isKey {
+						endKey()
+					}
+					continue
+				}
+				if isKey {
+					key = append(key, r)
+				}
+				continue
+			}
+			switch r {
+			case '"':
+				inString, escape = true, false
+				isKey = len(stack) > 0 && !stack[len(stack)-1].array && stack[len(stack)-1].expectKey
+				key = key[:0]
+			case '{':
+				stack = append(stack, jsonPathFrame{expectKey: true})
+			case '[':
+				stack = append(stack, jsonPathFrame{array: true})
+			case '}', ']':
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			case ',':
+				if len(stack) > 0 {
+					top := &stack[len(stack)-1]
+					if top.array {
+						top.index++
+					} else {
+						top.expectKey = true
+						top.hasKey = false
+					}
+				}
+			case ':':
+				if len(stack) > 0 && !stack[len(stack)-1].array {
+					stack[len(stack)-1].expectKey = false
+				}
+			}
+		}
+		// Strings can't span lines; recover from broken input
+		inString = false
+	}
+	return formatJSONPath(stack)
+}
+
+func formatJSONPath(stack []jsonPathFrame) string {
+	var sb strings.Builder
+	for _, frame := range stack {
+		switch {
+		case frame.array:
+			sb.WriteString("[" + strconv.Itoa(frame.index) + "]")
+		case frame.hasKey && isJSONIdent(frame.key):
+			sb.WriteString("." + frame.key)
+		case frame.hasKey:
+			sb.WriteString("[" + strconv.Quote(frame.key) + "]")
+		}
+	}
+	if sb.Len() == 0 {
+		return "."
+	}
+	return sb.String()
+}
+
+func isJSONIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// jsonStatusPath returns the JSON path breadcrumb for the statusline,
+// cached until the buffer or the cursor changes
+func (e *Editor) jsonStatusPath() string {
+	if e.preview || !isJSONFile(e.filename) {
+		return ""
+	}
+	if !e.jsonPathValid || e.jsonPathTick != e.changeTick || e.jsonPathCursor != e.cursor {
+		e.jsonPath = e.jsonPathAtCursor()
+		e.jsonPathTick = e.changeTick
+		e.jsonPathCursor = e.cursor
+		e.jsonPathValid = true
+	}
+	path := []rune(e.jsonPath)
+	if len(path) > maxJSONPathWidth {
+		return "…" + string(path[len(path)-maxJSONPathWidth+1:])
+	}
+	return e.jsonPath
+}
@@ -0,0 +1,113 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatJSONIndentAndMinify(t *testing.T) {
+	e := newTestEditor(`{"b": [1, 2], "a": {"s": "x  y"}}`, "")
+	e.filename = "data.json"
+	e.cursor = Cursor{Row: 0, Col: 21} // on "s"
+
+	e.execCommand("json fmt")
+	want := "{\n    \"b\": [\n        1,\n        2\n    ],\n    \"a\": {\n        \"s\": \"x  y\"\n    }\n}\n"
+	if got := e.Content(); got != want {
+		t.Fatalf("fmt = %q, want %q", got, want)
+	}
+	if e.cursor != (Cursor{Row: 6, Col: 9}) {
+		t.Fatalf("cursor after fmt = %+v, want on \"s\"", e.cursor)
+	}
+
+	e.execCommand("json min")
+	if got := e.Content(); got != "{\"b\":[1,2],\"a\":{\"s\":\"x  y\"}}\n" {
+		t.Fatalf("min = %q", got)
+	}
+	if e.statusMessage != "json minified" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+
+	e.Undo()
+	if got := e.Content(); got != want {
+		t.Fatalf("undo of min = %q, want formatted text", got)
+	}
+}
+
+func TestFormatJSONUnderReadOnlyFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(path, []byte(`{"a":1}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor("")
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e.SetReadOnly(true)
+	e.execCommand("json fmt")
+	if got := e.Content(); got != "{\n    \"a\": 1\n}\n" {
+		t.Fatalf("fmt under --readonly = %q", got)
+	}
+	e.execCommand("w")
+	if data, _ := os.ReadFile(path); string(data) != `{"a":1}`+"\n" {
+		t.Fatalf("--readonly file was written: %q", data)
+	}
+}
+
+func TestFormatJSONSyntaxError(t *testing.T) {
+	e := newTestEditor("{", `  "a": 1,`, "}")
+	e.execCommand("json fmt")
+	if e.statusMessage != "json: invalid character '}' looking for beginning of object key string (line 3, col 1)" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	if e.dirty {
+		t.Fatalf("buffer changed on syntax error")
+	}
+}
+
+func TestJSONPathAtCursor(t *testing.T) {
+	e := newTestEditor(
+		`{`,
+		`  "items": [`,
+		`    {"name": "a"},`,
+		`    {"name": "b", "my key": [true, null]}`,
+		`  ]`,
+		`}`,
+	)
+	tests := []struct {
+		pos  Cursor
+		want string
+	}{
+		{Cursor{Row: 0, Col: 0}, "."},
+		{Cursor{Row: 1, Col: 4}, ".items"},
+		{Cursor{Row: 1, Col: 11}, ".items"},
+		{Cursor{Row: 2, Col: 4}, ".items[0]"},
+		{Cursor{Row: 2, Col: 15}, ".items[0].name"},
+		{Cursor{Row: 3, Col: 16}, ".items[1].name"},
+		{Cursor{Row: 3, Col: 21}, `.items[1]["my key"]`},
+		{Cursor{Row: 3, Col: 36}, `.items[1]["my key"][1]`},
+	}
+	for _, tt := range tests {
+		e.cursor = tt.pos
+		if got := e.jsonPathAtCursor(); got != tt.want {
+			t.Fatalf("path at %+v = %q, want %q", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestJSONStatusPathOnlyForJSONFiles(t *testing.T) {
+	e := newTestEditor(`{"a": {"b": 1}}`)
+	e.cursor = Cursor{Row: 0, Col: 12}
+	e.filename = "notes.txt"
+	if got := e.jsonStatusPath(); got != "" {
+		t.Fatalf("status path for txt = %q, want empty", got)
+	}
+	e.filename = "data.json"
+	if got := e.jsonStatusPath(); got != ".a.b" {
+		t.Fatalf("status path = %q, want .a.b", got)
+	}
+	e.execCommand("json path")
+	if len(e.clipboard) != 1 || string(e.clipboard[0]) != ".a.b" {
+		t.Fatalf("clipboard = %q, want .a.b", e.clipboard)
+	}
+}
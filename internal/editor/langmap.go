@@ -0,0 +1,134 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// A langmap translates the characters a non-Latin keyboard layout types
+// into the QWERTY keys in the same places, so normal-mode commands keep
+// working without switching the layout back: with Russian ЙЦУКЕН active,
+// ш moves like i and пп goes to the first line. Only the keymap lookup is
+// translated; f/t/r and insert mode still get the character typed.
+
+// builtinLangmaps are the layouts langmap can name, as layout characters
+// followed by the QWERTY keys they sit on. Keys that type ASCII in the
+// layout too are left out, so the table can stay on with QWERTY active.
+var builtinLangmaps = map[string][2]string{
+	"ru": {
+		"ёйцукенгшщзхъфывапролджэячсмитьбюЁЙЦУКЕНГШЩЗХЪФЫВАПРОЛДЖЭЯЧСМИТЬБЮ",
+		"`qwertyuiop[]asdfghjkl;'zxcvbnm,.~QWERTYUIOP{}ASDFGHJKL:\"ZXCVBNM<>",
+	},
+	"uk": {
+		"йцукенгшщзхїфівапролджєячсмитьбюґЙЦУКЕНГШЩЗХЇФІВАПРОЛДЖЄЯЧСМИТЬБЮҐ",
+		"qwertyuiop[]asdfghjkl;'zxcvbnm,.\\QWERTYUIOP{}ASDFGHJKL:\"ZXCVBNM<>|",
+	},
+}
+
+// langmap maps layout characters to QWERTY keys
+type langmap map[rune]rune
+
+// parseLangmap reads a langmap spec: comma-separated parts that are either
+// a built-in layout name ("ru", "uk"), "FROM;TO" with strings of the same
+// length, or pairs of characters ("шiщo"). A backslash escapes a comma or
+// semicolon; spaces around a part are dropped. "" and "off" turn the langmap off.
+func parseLangmap(spec string) (langmap, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return nil, nil
+	}
+	m := langmap{}
+	for _, part := range splitLangmap(spec, ',') {
+		part = []rune(strings.TrimSpace(string(part)))
+		if table, ok := builtinLangmaps[string(part)]; ok {
+			if err := m.addPairs([]rune(table[0]), []rune(table[1])); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		halves := splitLangmap(string(part), ';')
+		switch len(halves) {
+		case 1:
+			pairs := halves[0]
+			if len(pairs)%2 != 0 {
+				return nil, fmt.Errorf("langmap: %q has an odd number of characters", string(pairs))
+			}
+			for i := 0; i < len(pairs); i += 2 {
+				m[pairs[i]] = pairs[i+1]
+			}
+		case 2:
+			if err := m.addPairs(halves[0], halves[1]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("langmap: %q has more than one ;", string(part))
+		}
+	}
+	return m, nil
+}
+
+// CheckLangmap reports what is wrong with the langmap spec of the config
+func CheckLangmap(spec string) error {
+	_, err := parseLangmap(spec)
+	return err
+}
+
+// addPairs maps each character of from to the one at the same index of to
+func (m langmap) addPairs(from, to []rune) error {
+	if len(from) != len(to) {
+		return fmt.Errorf("langmap: %q and %q differ in length", string(from), string(to))
+	}
+	for i, r := range from {
+		m[r] = to[i]
+	}
+	return nil
+}
+
+// splitLangmap splits s at sep; a backslash before sep makes it a
+// character of the part, other backslashes are kept for the next level
+func splitLangmap(s string, sep rune) [][]rune {
+	var parts [][]rune
+	var cur []rune
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\\' && i+1 < len(runes) && runes[i+1] == sep:
+			i++
+			cur = append(cur, sep)
+		case r == sep:
+			parts = append(parts, cur)
+			cur = nil
+		default:
+			cur = append(cur, r)
+		}
+	}
+	return append(parts, cur)
+}
+
+// langmapRune returns the QWERTY key for ch, or ch when the langmap has
+// none for it or keymap binds ch itself
+func (e *Editor) langmapRune(ch rune, keymap map[string]string) rune {
+	to, ok := e.langmap[ch]
+	if !ok {
+		return ch
+	}
+	if keymap != nil && keymap[string(ch)] != "" {
+		return ch
+	}
+	return to
+}
+
+// langmapKey translates a normal-mode character key through the langmap,
+// keeping its modifiers
+func (e *Editor) langmapKey(ev *tcell.EventKey) *tcell.EventKey {
+	if len(e.langmap) == 0 || ev.Key() != tcell.KeyRune {
+		return ev
+	}
+	ch := e.langmapRune(ev.Rune(), e.keymap.normal)
+	if ch == ev.Rune() {
+		return ev
+	}
+	return tcell.NewEventKey(tcell.KeyRune, ch, ev.Modifiers())
+}
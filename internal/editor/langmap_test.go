@@ -0,0 +1,65 @@
+package editor
+
+import (
+	"testing"
+)
+
+func TestParseLangmap(t *testing.T) {
+	for name, table := range builtinLangmaps {
+		if a, b := []rune(table[0]), []rune(table[1]); len(a) != len(b) {
+			t.Fatalf("%s: %d layout characters for %d keys", name, len(a), len(b))
+		}
+	}
+	m, err := parseLangmap(`ru, фисв;abcd,шi\,\;`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for from, want := range map[rune]rune{'й': 'q', 'Ж': ':', 'ф': 'a', 'в': 'd', 'ш': 'i', ',': ';'} {
+		if got := m[from]; got != want {
+			t.Fatalf("%c -> %q, want %q", from, got, want)
+		}
+	}
+	if m, err := parseLangmap("off"); err != nil || m != nil {
+		t.Fatalf("off: %v %v", m, err)
+	}
+	for _, spec := range []string{"шiщ", "ab;c", "a;b;c"} {
+		if _, err := parseLangmap(spec); err == nil {
+			t.Fatalf("%q: no error", spec)
+		}
+	}
+}
+
+func TestLangmapNormalMode(t *testing.T) {
+	e := newTestEditor("one", "two", "three")
+	e.langmap, _ = parseLangmap("ru")
+	// о is j, пп is gg, ш is i
+	e.HandleKey(keyRune('о'))
+	e.HandleKey(keyRune('о'))
+	if e.cursor.Row != 2 {
+		t.Fatalf("row after оо = %d, want 2", e.cursor.Row)
+	}
+	e.HandleKey(keyRune('п'))
+	e.HandleKey(keyRune('п'))
+	if e.cursor.Row != 0 {
+		t.Fatalf("row after пп = %d, want 0", e.cursor.Row)
+	}
+	e.HandleKey(keyRune('ш'))
+	if e.mode != ModeInsert {
+		t.Fatalf("mode after ш = %v, want insert", e.mode)
+	}
+	// Insert mode types the character itself
+	e.HandleKey(keyRune('ш'))
+	if got := string(e.lines[0]); got != "шone" {
+		t.Fatalf("line = %q", got)
+	}
+}
+
+func TestLangmapFindCharIsLiteral(t *testing.T) {
+	e := newTestEditor("abc шрифт")
+	e.langmap, _ = parseLangmap("ru")
+	e.HandleKey(keyRune('f'))
+	e.HandleKey(keyRune('р'))
+	if e.cursor.Col != 5 {
+		t.Fatalf("col after fр = %d, want 5", e.cursor.Col)
+	}
+}
@@ -0,0 +1,398 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/config"
+)
+
+// Macros: Q starts and stops recording the keys typed, q replays them.
+// :macro save NAME keeps the last recording in the library (macros.toml in
+// the config dir), from where :macro run NAME, the :macro list picker and
+// keymap bindings to "macro:NAME" replay it in any session.
+
+const (
+	actionRecordMacro = "record_macro" // Q
+	actionReplayMacro = "replay_macro" // q
+
+	// macroActionPrefix binds a library macro in the keymap: x = "macro:NAME"
+	macroActionPrefix = "macro:"
+
+	// maxMacroDepth bounds macros replaying macros, so one bound to a key
+	// it types doesn't run forever
+	maxMacroDepth = 8
+)
+
+// macroKeyNames are the <> names of keys other than characters
+var macroKeyNames = map[tcell.Key]string{
+	tcell.KeyEscape:     "Esc",
+	tcell.KeyEnter:      "CR",
+	tcell.KeyTab:        "Tab",
+	tcell.KeyBackspace2: "BS",
+	tcell.KeyBackspace:  "BS",
+	tcell.KeyDelete:     "Del",
+	tcell.KeyUp:         "Up",
+	tcell.KeyDown:       "Down",
+	tcell.KeyLeft:       "Left",
+	tcell.KeyRight:      "Right",
+	tcell.KeyHome:       "Home",
+	tcell.KeyEnd:        "End",
+	tcell.KeyPgUp:       "PageUp",
+	tcell.KeyPgDn:       "PageDown",
+	tcell.KeyInsert:     "Insert",
+}
+
+// macroKeyString writes ev in <> notation: characters as themselves ("<"
+// as <lt>), other keys as <Name>, with C-, A-, S- and D- (Cmd) modifiers
+func macroKeyString(ev *tcell.EventKey) string {
+	mods := ev.Modifiers()
+	var name string
+	switch key := ev.Key(); {
+	case key == tcell.KeyRune:
+		name = string(ev.Rune())
+		if mods == tcell.ModShift {
+			mods = 0 // the shift is in the character
+		}
+	case key == tcell.KeyBacktab:
+		name = "Tab"
+		mods |= tcell.ModShift
+	case macroKeyNames[key] != "":
+		name = macroKeyNames[key]
+	case key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlZ:
+		name = string(rune('a' + key - tcell.KeyCtrlA))
+		mods |= tcell.ModCtrl
+	default:
+		return ""
+	}
+	var prefix string
+	for _, m := range []struct {
+		mask tcell.ModMask
+		name string
+	}{{tcell.ModCtrl, "C-"}, {tcell.ModAlt, "A-"}, {tcell.ModShift, "S-"}, {tcell.ModMeta, "D-"}} {
+		if mods&m.mask != 0 {
+			prefix += m.name
+		}
+	}
+	switch {
+	case prefix != "" || ev.Key() != tcell.KeyRune:
+		return "<" + prefix + name + ">"
+	case name == "<":
+		return "<lt>"
+	}
+	return name
+}
+
+// parseMacroKeys reads keys in <> notation back into key events. A "<"
+// that doesn't start a known name is the character itself.
+func parseMacroKeys(keys string) ([]*tcell.EventKey, error) {
+	var events []*tcell.EventKey
+	for len(keys) > 0 {
+		if keys[0] == '<' {
+			if end := strings.IndexByte(keys, '>'); end > 1 {
+				if ev, ok := parseMacroKeyName(keys[1:end]); ok {
+					events = append(events, ev)
+					keys = keys[end+1:]
+					continue
+				}
+				if !strings.ContainsAny(keys[1:end], "< ") {
+					return nil, fmt.Errorf("unknown key <%s>", keys[1:end])
+				}
+			}
+		}
+		r, size := utf8.DecodeRuneInString(keys)
+		events = append(events, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+		keys = keys[size:]
+	}
+	return events, nil
+}
+
+// parseMacroKeyName reads the name between < and >
+func parseMacroKeyName(name string) (*tcell.EventKey, bool) {
+	if strings.EqualFold(name, "lt") {
+		return tcell.NewEventKey(tcell.KeyRune, '<', tcell.ModNone), true
+	}
+	var mods tcell.ModMask
+	for len(name) > 2 && name[1] == '-' {
+		switch name[0] {
+		case 'C', 'c':
+			mods |= tcell.ModCtrl
+		case 'A', 'a', 'M', 'm':
+			mods |= tcell.ModAlt
+		case 'S', 's':
+			mods |= tcell.ModShift
+		case 'D', 'd':
+			mods |= tcell.ModMeta
+		default:
+			return nil, false
+		}
+		name = name[2:]
+	}
+	if strings.EqualFold(name, "Tab") && mods&tcell.ModShift != 0 {
+		return tcell.NewEventKey(tcell.KeyBacktab, 0, mods&^tcell.ModShift), true
+	}
+	for key, n := range macroKeyNames {
+		if strings.EqualFold(n, name) && key != tcell.KeyBackspace {
+			return tcell.NewEventKey(key, 0, mods), true
+		}
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	if size != len(name) {
+		return nil, false
+	}
+	if mods&tcell.ModCtrl != 0 && r >= 'a' && r <= 'z' {
+		// Terminals report Ctrl+letter as a control character
+		return tcell.NewEventKey(tcell.KeyCtrlA+tcell.Key(r-'a'), 0, mods), true
+	}
+	if mods == 0 {
+		return nil, false
+	}
+	return tcell.NewEventKey(tcell.KeyRune, r, mods), true
+}
+
+// recordMacroKey adds ev to the macro being recorded
+func (e *Editor) recordMacroKey(ev *tcell.EventKey) {
+	if !e.macroRecording || e.macroDepth > 0 {
+		return
+	}
+	e.macroMark = len(e.macroKeys)
+	if key := macroKeyString(ev); key != "" {
+		e.macroKeys = append(e.macroKeys, key)
+	}
+}
+
+// toggleMacroRecording starts recording keys, or stops and keeps them as
+// the macro q replays (Q)
+func (e *Editor) toggleMacroRecording() {
+	if e.macroDepth > 0 {
+		return
+	}
+	if !e.macroRecording {
+		e.macroRecording = true
+		e.macroKeys = nil
+		e.macroMark = 0
+		e.setStatus("recording macro (Q stops)")
+		return
+	}
+	e.macroRecording = false
+	// Drop the Q that stopped the recording
+	keys := strings.Join(e.macroKeys[:e.macroMark], "")
+	e.macroKeys = nil
+	if keys == "" {
+		e.setStatus("macro empty, kept the previous one")
+		return
+	}
+	e.lastMacro = keys
+	e.setStatus("recorded macro " + keys + " (:macro save NAME keeps it)")
+}
+
+// replayMacro replays the last recorded macro count times (q)
+func (e *Editor) replayMacro() {
+	if e.lastMacro == "" {
+		e.setStatus("no macro recorded (Q records one)")
+		return
+	}
+	for i := 0; i < max(e.count, 1); i++ {
+		if !e.runMacroKeys(e.lastMacro) {
+			return
+		}
+	}
+}
+
+// runMacroKeys feeds keys to the editor as if typed and reports whether
+// they all ran. A key that quits stops the macro and sets macroQuit for
+// the command, action or popup that ran it to return.
+func (e *Editor) runMacroKeys(keys string) bool {
+	events, err := parseMacroKeys(keys)
+	if err != nil {
+		e.setStatus("macro: " + err.Error())
+		return false
+	}
+	if e.macroDepth >= maxMacroDepth {
+		e.setStatus("macro: too deeply nested")
+		return false
+	}
+	count := e.count
+	e.count = 0
+	e.macroDepth++
+	defer func() {
+		e.macroDepth--
+		e.count = count
+	}()
+	for _, ev := range events {
+		if e.HandleKey(ev) {
+			e.macroQuit = true
+			return false
+		}
+	}
+	return true
+}
+
+// takeMacroQuit reports whether a replayed macro quit the editor since the
+// last call
+func (e *Editor) takeMacroQuit() bool {
+	quit := e.macroQuit
+	e.macroQuit = false
+	return quit
+}
+
+// runNamedMacro replays the library macro name
+func (e *Editor) runNamedMacro(name string) {
+	macros, err := config.LoadMacros()
+	if err != nil {
+		e.setStatus("macros: " + err.Error())
+		return
+	}
+	keys, ok := macros[name]
+	if !ok {
+		e.setStatus("no macro " + name)
+		return
+	}
+	e.runMacroKeys(keys)
+}
+
+// validMacroName reports whether name can be a library key: letters,
+// digits, - and _
+func validMacroName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !isWordChar(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// execMacroCommand runs :macro save|run|delete NAME and :macro list
+func (e *Editor) execMacroCommand(args []string) {
+	usage := "usage: :macro save|run|delete NAME, :macro list"
+	if len(args) == 1 && args[0] == "list" {
+		e.openMacroPicker()
+		return
+	}
+	if len(args) != 2 {
+		e.setStatus(usage)
+		return
+	}
+	name := args[1]
+	if !validMacroName(name) {
+		e.setStatus("macro names are letters, digits, - and _")
+		return
+	}
+	switch args[0] {
+	case "run":
+		e.runNamedMacro(name)
+	case "save", "delete":
+		if args[0] == "save" && e.lastMacro == "" {
+			e.setStatus("no macro recorded (Q records one)")
+			return
+		}
+		macros, err := config.LoadMacros()
+		if err != nil {
+			e.setStatus("macros: " + err.Error())
+			return
+		}
+		if args[0] == "save" {
+			macros[name] = e.lastMacro
+		} else if _, ok := macros[name]; !ok {
+			e.setStatus("no macro " + name)
+			return
+		} else {
+			delete(macros, name)
+		}
+		if err := config.SaveMacros(macros); err != nil {
+			e.setStatus("macros: " + err.Error())
+			return
+		}
+		e.setStatus(args[0] + "d macro " + name)
+	default:
+		e.setStatus(usage)
+	}
+}
+
+// macroPicker is the state of the :macro list picker
+type macroPicker struct {
+	names []string
+	keys  []string
+	index int
+}
+
+// openMacroPicker lists the library macros; Enter replays the selected one
+func (e *Editor) openMacroPicker() {
+	macros, err := config.LoadMacros()
+	if err != nil {
+		e.setStatus("macros: " + err.Error())
+		return
+	}
+	if len(macros) == 0 {
+		e.setStatus("no saved macros (Q records one, :macro save NAME keeps it)")
+		return
+	}
+	p := macroPicker{}
+	for name := range macros {
+		p.names = append(p.names, name)
+	}
+	sort.Strings(p.names)
+	for _, name := range p.names {
+		p.keys = append(p.keys, macros[name])
+	}
+	e.macroPicker = p
+	e.openPopup(macroPickerPopup{})
+}
+
+// macroPickerPopup is the :macro list picker. Up/Down (j/k) select, Enter
+// replays the macro and closes the list.
+type macroPickerPopup struct{}
+
+func (macroPickerPopup) handleKey(e *Editor, ev *tcell.EventKey) bool {
+	p := &e.macroPicker
+	switch {
+	case ev.Key() == tcell.KeyDown || ev.Key() == tcell.KeyCtrlN || (ev.Key() == tcell.KeyRune && ev.Rune() == 'j'):
+		p.index = (p.index + 1) % len(p.names)
+	case ev.Key() == tcell.KeyUp || ev.Key() == tcell.KeyCtrlP || (ev.Key() == tcell.KeyRune && ev.Rune() == 'k'):
+		p.index = (p.index + len(p.names) - 1) % len(p.names)
+	case ev.Key() == tcell.KeyEnter:
+		keys := p.keys[p.index]
+		e.closePopup(macroPickerPopup{})
+		e.runMacroKeys(keys)
+	}
+	return true
+}
+
+func (macroPickerPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	p := e.macroPicker
+	if w < 6 || len(p.names) == 0 {
+		return
+	}
+	first := max(0, min(p.index-maxCompletionRows/2, len(p.names)-maxCompletionRows))
+	last := min(first+maxCompletionRows, len(p.names))
+	nameWidth := 0
+	for _, name := range p.names[first:last] {
+		nameWidth = max(nameWidth, len([]rune(name)))
+	}
+	width := min(maxFloatWidth, w-4)
+	var lines [][]rune
+	var styles []tcell.Style
+	for i := first; i < last; i++ {
+		text := p.names[i] + strings.Repeat(" ", nameWidth-len([]rune(p.names[i]))+2) + p.keys[i]
+		lines = append(lines, truncateRunes([]rune(text), width))
+		style := e.styleAutoComplete
+		if i == p.index {
+			style = e.styleSelection
+		}
+		styles = append(styles, style)
+	}
+	e.drawCursorFloat(s, w, viewHeight, lines, styles)
+}
+
+func (macroPickerPopup) dismissed(e *Editor) {
+	e.macroPicker = macroPicker{}
+}
+
+func (macroPickerPopup) modal() bool { return true }
@@ -0,0 +1,120 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/config"
+)
+
+func TestMacroKeyNotation(t *testing.T) {
+	keys := []*tcell.EventKey{
+		keyRune('i'),
+		keyRune('<'),
+		tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyBacktab, 0, tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyCtrlR, 0, tcell.ModCtrl),
+		tcell.NewEventKey(tcell.KeyLeft, 0, tcell.ModCtrl),
+		tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt),
+		tcell.NewEventKey(tcell.KeyRune, 'T', tcell.ModMeta|tcell.ModShift),
+	}
+	var text string
+	for _, ev := range keys {
+		text += macroKeyString(ev)
+	}
+	if text != "i<lt><Esc><CR><S-Tab><C-r><C-Left><A-x><S-D-T>" {
+		t.Fatalf("notation %q", text)
+	}
+	parsed, err := parseMacroKeys(text)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(parsed) != len(keys) {
+		t.Fatalf("parsed %d keys, want %d", len(parsed), len(keys))
+	}
+	for i, ev := range parsed {
+		if ev.Key() != keys[i].Key() || ev.Rune() != keys[i].Rune() || ev.Modifiers() != keys[i].Modifiers() {
+			t.Fatalf("key %d = %v %q %v, want %v %q %v", i, ev.Key(), ev.Rune(), ev.Modifiers(), keys[i].Key(), keys[i].Rune(), keys[i].Modifiers())
+		}
+	}
+	if _, err := parseMacroKeys("<Nope>"); err == nil {
+		t.Fatal("unknown key parsed")
+	}
+}
+
+func TestRecordAndReplayMacro(t *testing.T) {
+	e := newTestEditor("a", "b", "c")
+	for _, ev := range []*tcell.EventKey{keyRune('Q'), keyRune('A'), keyRune('!'), tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone), keyRune('j'), keyRune('Q')} {
+		e.HandleKey(ev)
+	}
+	if e.lastMacro != "A!<Esc>j" {
+		t.Fatalf("recorded %q", e.lastMacro)
+	}
+	e.HandleKey(keyRune('2'))
+	e.HandleKey(keyRune('q'))
+	if got := e.Content(); got != "a!\nb!\nc!" {
+		t.Fatalf("content %q", got)
+	}
+}
+
+func TestMacroQuits(t *testing.T) {
+	t.Setenv("QEDIT_CONFIG_HOME", t.TempDir())
+	e := newTestEditor("x")
+	e.lastMacro = "A!<Esc>:q!<CR>"
+	if !e.HandleKey(keyRune('q')) {
+		t.Fatalf("replaying a macro with :q! didn't quit (status %q)", e.statusMessage)
+	}
+	e.execCommand("macro save bye")
+	if !e.execCommand("macro run bye") {
+		t.Fatalf(":macro run didn't quit")
+	}
+	e.execCommand("macro list")
+	if !e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)) {
+		t.Fatalf("running the macro from the picker didn't quit")
+	}
+}
+
+func TestMacroLibrary(t *testing.T) {
+	t.Setenv("QEDIT_CONFIG_HOME", t.TempDir())
+	e := newTestEditor("x", "y")
+	e.execCommand("macro save bang")
+	if e.statusMessage != "no macro recorded (Q records one)" {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+	e.lastMacro = "A;<Esc>"
+	e.execCommand("macro save bang")
+	macros, err := config.LoadMacros()
+	if err != nil || macros["bang"] != "A;<Esc>" {
+		t.Fatalf("library %v, %v", macros, err)
+	}
+
+	// A new session replays it by name and from a key binding
+	e = newTestEditor("x", "y")
+	e.execCommand("macro run bang")
+	e.keymap.normal["ctrl+t"] = "macro:bang"
+	e.cursor.Row = 1
+	e.HandleKey(tcell.NewEventKey(tcell.KeyCtrlT, 0, tcell.ModCtrl))
+	if got := e.Content(); got != "x;\ny;" {
+		t.Fatalf("content %q", got)
+	}
+
+	// The picker replays the selected macro
+	e.lastMacro = "A-<Esc>"
+	e.execCommand("macro save dash")
+	e.execCommand("macro list")
+	if !e.popupOpen(macroPickerPopup{}) || len(e.macroPicker.names) != 2 {
+		t.Fatalf("picker %+v", e.macroPicker)
+	}
+	e.HandleKey(keyRune('j'))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if got := e.Content(); got != "x;\ny;-" || e.popupOpen(macroPickerPopup{}) {
+		t.Fatalf("after picker %q", got)
+	}
+
+	e.execCommand("macro delete dash")
+	if macros, _ := config.LoadMacros(); len(macros) != 1 {
+		t.Fatalf("after delete %v", macros)
+	}
+}
@@ -0,0 +1,309 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+const (
+	actionHeadingNext = "heading_next" // ]] - go to the next markdown heading
+	actionHeadingPrev = "heading_prev" // [[ - go to the previous markdown heading
+)
+
+// handleNextKey handles the second key after the ']' prefix
+func (e *Editor) handleNextKey(ch rune) bool {
+	e.lastCommand = "]" + string(ch)
+	if ch == ']' {
+		return e.execMenuMotion(actionHeadingNext)
+	}
+	return false
+}
+
+// handlePrevKey handles the second key after the '[' prefix
+func (e *Editor) handlePrevKey(ch rune) bool {
+	e.lastCommand = "[" + string(ch)
+	if ch == '[' {
+		return e.execMenuMotion(actionHeadingPrev)
+	}
+	return false
+}
+
+// execMenuMotion runs a motion picked from a menu, extending the
+// selection in select mode
+func (e *Editor) execMenuMotion(action string) bool {
+	if !e.selectMode {
+		return e.execAction(action)
+	}
+	before := e.cursor
+	result := e.execAction(action)
+	if before != e.cursor {
+		e.selectionEnd = e.cursor
+	}
+	return result
+}
+
+// atxHeading returns the level of the markdown heading on line ("## x"
+// is 2), false for other lines
+func atxHeading(line []rune) (int, bool) {
+	col := firstNonBlank(line)
+	if col > 3 {
+		return 0, false
+	}
+	level := 0
+	for col+level < len(line) && line[col+level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, false
+	}
+	if end := col + level; end < len(line) && line[end] != ' ' && line[end] != '\t' {
+		return 0, false
+	}
+	return level, true
+}
+
+// gotoHeading moves to the next (dir 1) or previous (dir -1) heading of
+// a markdown file, skipping code blocks
+func (e *Editor) gotoHeading(dir int) {
+	if !isMarkdownFile(e.filename) {
+		e.setStatus("headings: not a markdown file")
+		return
+	}
+	fenced := make([]bool, len(e.lines))
+	in := false
+	for row, line := range e.lines {
+		if isFenceLine(string(line)) {
+			in = !in
+			fenced[row] = true
+			continue
+		}
+		fenced[row] = in
+	}
+	for row := e.cursor.Row + dir; row >= 0 && row < len(e.lines); row += dir {
+		if _, ok := atxHeading(e.lines[row]); ok && !fenced[row] {
+			e.cursor = Cursor{Row: row, Col: firstNonBlank(e.lines[row])}
+			return
+		}
+	}
+	if dir > 0 {
+		e.setStatus("no next heading")
+	} else {
+		e.setStatus("no previous heading")
+	}
+}
+
+// shiftHeadings changes the level of the markdown heading on the cursor
+// line, or of the selected lines when they are all headings: delta 1
+// demotes (# to ##), -1 promotes. It reports false for other lines, which
+// Tab and Shift+Tab indent as usual.
+func (e *Editor) shiftHeadings(delta int) bool {
+	if !isMarkdownFile(e.filename) || e.cursor.Row >= len(e.lines) {
+		return false
+	}
+	first, last := e.cursor.Row, e.cursor.Row
+	if start, end, ok := e.selectionRange(); ok {
+		first, last = start.Row, end.Row
+		if end.Col == 0 && end.Row > start.Row {
+			last--
+		}
+	}
+	if e.inCodeFence(first) {
+		return false
+	}
+	for row := first; row <= last; row++ {
+		level, ok := atxHeading(e.lines[row])
+		if !ok {
+			return false
+		}
+		if level+delta < 1 || level+delta > 6 {
+			e.setStatus("headings go from # to ######")
+			return true
+		}
+	}
+
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	for row := first; row <= last; row++ {
+		pos := Cursor{Row: row, Col: firstNonBlank(e.lines[row])}
+		next := Cursor{Row: row, Col: pos.Col + 1}
+		if delta > 0 {
+			_, _ = e.InsertAt(pos, "#")
+			e.selectionStart = shiftPosForEdit(e.selectionStart, pos, pos, next)
+			e.selectionEnd = shiftPosForEdit(e.selectionEnd, pos, pos, next)
+		} else {
+			_, _ = e.DeleteRange(pos, next)
+			e.selectionStart = shiftPosForEdit(e.selectionStart, pos, next, pos)
+			e.selectionEnd = shiftPosForEdit(e.selectionEnd, pos, next, pos)
+		}
+	}
+	return true
+}
+
+// orderedItem returns the indent of the ordered list item on line ("3. x")
+// and the column after its number
+func orderedItem(line []rune) (indent, numEnd int, ok bool) {
+	indent, _, ok = listItem(line)
+	if !ok || !unicode.IsDigit(line[indent]) {
+		return 0, 0, false
+	}
+	numEnd = indent
+	for unicode.IsDigit(line[numEnd]) {
+		numEnd++
+	}
+	return indent, numEnd, true
+}
+
+// renumberLists numbers the items of every ordered list in the markdown
+// file, or in the selected lines, in order from the list's first number.
+// Nested lists are numbered on their own; blank lines between items don't
+// end a list, a line less indented than the items after one does.
+func (e *Editor) renumberLists() {
+	if !isMarkdownFile(e.filename) {
+		e.setStatus("renumber: not a markdown file")
+		return
+	}
+	first, last := 0, len(e.lines)-1
+	if start, end, ok := e.selectionRange(); ok {
+		first, last = start.Row, end.Row
+		if end.Col == 0 && end.Row > start.Row {
+			last--
+		}
+	}
+	e.BeginUndoGroup()
+	changed := e.renumberRows(first, last)
+	e.EndUndoGroup()
+	switch changed {
+	case 0:
+		e.setStatus("renumber: lists already in order")
+	case 1:
+		e.setStatus("renumbered 1 item")
+	default:
+		e.setStatus(fmt.Sprintf("renumbered %d items", changed))
+	}
+}
+
+// renumberRows renumbers the ordered lists in rows first..last and returns
+// how many items changed
+func (e *Editor) renumberRows(first, last int) int {
+	type list struct{ indent, next int }
+	var lists []list // open lists, innermost last
+	inFence := e.inCodeFence(first)
+	blank := false
+	changed := 0
+	for row := first; row <= last; row++ {
+		line := e.lines[row]
+		if isFenceLine(string(line)) {
+			inFence = !inFence
+			lists = nil
+			continue
+		}
+		if inFence {
+			continue
+		}
+		indent := firstNonBlank(line)
+		if indent == len(line) {
+			blank = true
+			continue
+		}
+		_, _, item := listItem(line)
+		if _, heading := atxHeading(line); heading || (!item && blank) {
+			// A heading, or a paragraph after a blank line, closes the
+			// lists it isn't indented under
+			for len(lists) > 0 && (heading || lists[len(lists)-1].indent >= indent) {
+				lists = lists[:len(lists)-1]
+			}
+		}
+		blank = false
+		if !item {
+			continue
+		}
+		for len(lists) > 0 && lists[len(lists)-1].indent > indent {
+			lists = lists[:len(lists)-1]
+		}
+		_, numEnd, ordered := orderedItem(line)
+		if !ordered {
+			// A bullet at the same indent starts another list
+			if len(lists) > 0 && lists[len(lists)-1].indent == indent {
+				lists = lists[:len(lists)-1]
+			}
+			continue
+		}
+		if len(lists) == 0 || lists[len(lists)-1].indent < indent {
+			n, _ := strconv.Atoi(string(line[indent:numEnd]))
+			lists = append(lists, list{indent: indent, next: n})
+		}
+		cur := &lists[len(lists)-1]
+		if num := strconv.Itoa(cur.next); num != string(line[indent:numEnd]) {
+			_, _ = e.ReplaceRange(Cursor{Row: row, Col: indent}, Cursor{Row: row, Col: numEnd}, num)
+			changed++
+		}
+		cur.next++
+	}
+	return changed
+}
+
+// listEnd returns the last row of the list that row is in: blank lines
+// belong to it while a list item or an indented line follows them
+func (e *Editor) listEnd(row int) int {
+	last := row
+	for r := row + 1; r < len(e.lines); r++ {
+		line := e.lines[r]
+		if indent := firstNonBlank(line); indent == len(line) {
+			continue
+		} else if _, _, item := listItem(line); !item && (indent == 0 || isFenceLine(string(line))) {
+			break
+		}
+		last = r
+	}
+	return last
+}
+
+// continueList breaks the markdown list item on the cursor line in insert
+// mode: the new line gets the item's marker (the next number, an empty
+// box for a task) and the numbers after it move up. Enter on an item with
+// no text ends the list instead, clearing the marker. It reports false
+// outside list items, for a plain line break.
+func (e *Editor) continueList() bool {
+	row := e.cursor.Row
+	if !isMarkdownFile(e.filename) || row >= len(e.lines) {
+		return false
+	}
+	line := e.lines[row]
+	indent, text, ok := listItem(line)
+	if !ok || e.cursor.Col < text || e.inCodeFence(row) {
+		return false
+	}
+	body := text
+	if boxes, _ := e.taskBoxes(row, row); len(boxes) > 0 {
+		body = min(boxes[0].check+3, len(line)) // past "x] "
+	}
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	if firstNonBlank(line[body:]) == len(line)-body {
+		// An empty item ends the list
+		_, _ = e.DeleteRange(Cursor{Row: row}, Cursor{Row: row, Col: len(line)})
+		e.cursor = Cursor{Row: row}
+		return true
+	}
+
+	marker := string(line[indent:text])
+	if _, numEnd, ordered := orderedItem(line); ordered {
+		n, _ := strconv.Atoi(string(line[indent:numEnd]))
+		marker = strconv.Itoa(n+1) + string(line[numEnd:text])
+	}
+	if body > text {
+		marker += "[ ] "
+	}
+	prefix := string(line[:indent]) + marker
+	pos := Cursor{Row: row, Col: min(e.cursor.Col, len(line))}
+	end, err := e.InsertAt(pos, "\n"+prefix)
+	if err != nil {
+		return true
+	}
+	e.cursor = end
+	if _, _, ordered := orderedItem(e.lines[end.Row]); ordered {
+		e.renumberRows(end.Row, e.listEnd(end.Row))
+	}
+	return true
+}
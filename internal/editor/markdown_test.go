@@ -0,0 +1,150 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestHeadingNavigation(t *testing.T) {
+	e := newTestEditor("# Title", "text", "```", "# not a heading", "```", "## Section", "#hashtag", "### Sub")
+	e.filename = "doc.md"
+	e.HandleKey(keyRune(']'))
+	e.HandleKey(keyRune(']'))
+	if e.cursor.Row != 5 {
+		t.Fatalf("]] went to row %d", e.cursor.Row)
+	}
+	e.HandleKey(keyRune(']'))
+	e.HandleKey(keyRune(']'))
+	if e.cursor.Row != 7 || e.lastCommand != "]]" {
+		t.Fatalf("]] went to row %d (%q)", e.cursor.Row, e.lastCommand)
+	}
+	e.HandleKey(keyRune(']'))
+	e.HandleKey(keyRune(']'))
+	if e.cursor.Row != 7 || e.statusMessage != "no next heading" {
+		t.Fatalf("past the last heading: row %d, status %q", e.cursor.Row, e.statusMessage)
+	}
+	e.HandleKey(keyRune('['))
+	e.HandleKey(keyRune('['))
+	e.HandleKey(keyRune('['))
+	e.HandleKey(keyRune('['))
+	if e.cursor.Row != 0 {
+		t.Fatalf("[[ went to row %d", e.cursor.Row)
+	}
+}
+
+func TestHeadingPromoteDemote(t *testing.T) {
+	e := newTestEditor("## Section", "text")
+	e.filename = "doc.md"
+	e.cursor = Cursor{Row: 0, Col: 4}
+	tab := tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone)
+	backtab := tcell.NewEventKey(tcell.KeyBacktab, 0, tcell.ModShift)
+
+	e.HandleKey(tab)
+	if got := string(e.lines[0]); got != "### Section" || e.cursor.Col != 5 {
+		t.Fatalf("demoted: %q, cursor %+v", got, e.cursor)
+	}
+	e.HandleKey(backtab)
+	e.HandleKey(backtab)
+	if got := string(e.lines[0]); got != "# Section" {
+		t.Fatalf("promoted: %q", got)
+	}
+	e.HandleKey(backtab)
+	if got := string(e.lines[0]); got != "# Section" || e.statusMessage != "headings go from # to ######" {
+		t.Fatalf("promoted past #: %q, status %q", got, e.statusMessage)
+	}
+
+	// Other lines indent as before
+	e.cursor = Cursor{Row: 1}
+	e.HandleKey(tab)
+	if got := string(e.lines[1]); got != "\ttext" {
+		t.Fatalf("indented: %q", got)
+	}
+}
+
+func TestRenumberLists(t *testing.T) {
+	e := newTestEditor(
+		"1. one",
+		"1. two",
+		"   1. nested",
+		"   5. nested",
+		"",
+		"7. three",
+		"- bullet",
+		"3. new list",
+		"1. new list",
+		"",
+		"Paragraph",
+		"",
+		"4. after the paragraph",
+		"9. after the paragraph",
+	)
+	e.filename = "doc.md"
+	e.execCommand("renumber")
+	want := "1. one\n2. two\n   1. nested\n   2. nested\n\n3. three\n- bullet\n3. new list\n4. new list\n\nParagraph\n\n4. after the paragraph\n5. after the paragraph"
+	if got := e.Content(); got != want {
+		t.Fatalf("renumbered:\n%s", got)
+	}
+	if e.statusMessage != "renumbered 5 items" {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+	e.Undo()
+	if got := e.Content(); got[:12] != "1. one\n1. tw" {
+		t.Fatalf("undo as one step: %q", got)
+	}
+}
+
+func TestListContinuation(t *testing.T) {
+	enter := tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone)
+	e := newTestEditor("- [x] milk", "1. one", "2. two", "  * nested")
+	e.filename = "doc.md"
+	useTaskMarkers(e)
+	e.mode = ModeInsert
+
+	e.cursor = Cursor{Row: 0, Col: 10}
+	e.HandleKey(enter)
+	typeText(e, "eggs")
+	if got := string(e.lines[1]); got != "- [ ] eggs" {
+		t.Fatalf("task continued as %q", got)
+	}
+
+	// A numbered item gets the next number and the ones after it move up
+	e.cursor = Cursor{Row: 2, Col: 6}
+	e.HandleKey(enter)
+	typeText(e, "one and a half")
+	if got := e.Content(); got != "- [x] milk\n- [ ] eggs\n1. one\n2. one and a half\n3. two\n  * nested" {
+		t.Fatalf("numbered: %q", got)
+	}
+	e.mode = ModeNormal
+	for range len("one and a half") {
+		e.Undo()
+	}
+	e.Undo()
+	if got := e.Content(); got != "- [x] milk\n- [ ] eggs\n1. one\n2. two\n  * nested" {
+		t.Fatalf("not undone as one step: %q", got)
+	}
+	e.mode = ModeInsert
+
+	// Breaking a line in the middle carries the rest over
+	e.cursor = Cursor{Row: 4, Col: 6}
+	e.HandleKey(enter)
+	if got := string(e.lines[4]) + "|" + string(e.lines[5]); got != "  * ne|  * sted" {
+		t.Fatalf("split: %q", got)
+	}
+
+	// Enter on an empty item ends the list
+	e.cursor = Cursor{Row: 5, Col: 8}
+	e.HandleKey(enter)
+	e.HandleKey(enter)
+	if got := string(e.lines[6]); got != "" || e.cursor != (Cursor{Row: 6}) || len(e.lines) != 7 {
+		t.Fatalf("empty item left %q, cursor %+v, %d lines", got, e.cursor, len(e.lines))
+	}
+
+	// Elsewhere Enter just breaks the line
+	e.filename = "notes.txt"
+	e.cursor = Cursor{Row: 0, Col: 10}
+	e.HandleKey(enter)
+	if got := string(e.lines[1]); got != "" {
+		t.Fatalf("continued outside markdown: %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+package editor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// menuLayout is how a key menu (Space, g, m, z, Space w) is laid out in
+// the room the screen has
+type menuLayout struct {
+	columns  int // items fill the columns top to bottom, left to right
+	rows     int // rows of the grid
+	colWidth int // width of each column
+	visible  int // rows shown, fewer than rows when the menu scrolls
+	width    int // box size with its border
+	height   int
+}
+
+// layoutMenu fits items into a box of at most maxWidth×maxHeight. A list
+// taller than the box is split into columns side by side as far as the
+// width allows, and what still doesn't fit scrolls. Labels too wide for
+// the box are cut.
+func layoutMenu(items []SpaceMenuItem, maxWidth, maxHeight int) menuLayout {
+	innerWidth, innerHeight := maxWidth-2, maxHeight-2
+	colWidth := 0
+	for _, item := range items {
+		colWidth = max(colWidth, len([]rune(item.Label))+7) // " k   Label  "
+	}
+	colWidth = max(min(colWidth, innerWidth), 1)
+
+	columns := 1
+	if len(items) > innerHeight {
+		columns = max(min(ceilDiv(len(items), innerHeight), innerWidth/colWidth), 1)
+	}
+	rows := ceilDiv(len(items), columns)
+	visible := min(rows, innerHeight)
+	return menuLayout{
+		columns:  columns,
+		rows:     rows,
+		colWidth: colWidth,
+		visible:  visible,
+		width:    columns*colWidth + 2,
+		height:   visible + 2,
+	}
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// scrollMenu scrolls the menu of the pending sequence with the arrow and
+// page keys and reports whether ev was one of them. The sequence stays
+// pending. A menu that fits the screen doesn't scroll and these keys
+// abort the sequence as any other key does.
+func (e *Editor) scrollMenu(ev *tcell.EventKey) bool {
+	if e.menuRows <= e.menuPage {
+		return false
+	}
+	switch ev.Key() {
+	case tcell.KeyDown:
+		e.menuScroll++
+	case tcell.KeyUp:
+		e.menuScroll--
+	case tcell.KeyPgDn:
+		e.menuScroll += max(e.menuPage, 1)
+	case tcell.KeyPgUp:
+		e.menuScroll -= max(e.menuPage, 1)
+	default:
+		return false
+	}
+	e.menuScroll = max(e.menuScroll, 0) // the top is clamped when drawn
+	e.sequence.since = time.Now()
+	return true
+}
+
+// renderMenu renders a key menu popup at the bottom right, laid out again
+// for the current screen size on every frame
+func (e *Editor) renderMenu(s tcell.Screen, w, viewHeight int, title string, items []SpaceMenuItem) {
+	maxWidth, maxHeight := w-2, viewHeight
+	if maxWidth < 8 || maxHeight < 3 {
+		return
+	}
+	l := layoutMenu(items, maxWidth, maxHeight)
+	e.menuScroll = min(e.menuScroll, l.rows-l.visible)
+	e.menuPage, e.menuRows = l.visible, l.rows
+
+	// Position at bottom right, above status line
+	x0 := max(w-l.width-1, 0)
+	y0 := max(viewHeight-l.height, 0)
+
+	borderStyle := e.styleStatus
+	itemStyle := e.styleCommand
+	dimStyle := e.styleLineNumber // for unimplemented items
+
+	right, bottom := l.width-1, l.height-1
+	for y := 0; y <= bottom; y++ {
+		for x := 0; x <= right; x++ {
+			r, style := ' ', itemStyle
+			switch {
+			case y == 0 && x == 0:
+				r = '┌'
+			case y == 0 && x == right:
+				r = '┐'
+			case y == bottom && x == 0:
+				r = '└'
+			case y == bottom && x == right:
+				r = '┘'
+			case y == 0 || y == bottom:
+				r = '─'
+			case x == 0 || x == right:
+				r = '│'
+			}
+			if x == 0 || x == right || y == 0 || y == bottom {
+				style = borderStyle
+			}
+			s.SetContent(x0+x, y0+y, r, nil, style)
+		}
+	}
+
+	// Embed title in top border
+	if titleRunes := []rune(title); len(titleRunes)+2 <= l.width-2 {
+		for i, r := range titleRunes {
+			s.SetContent(x0+1+i, y0, r, nil, borderStyle)
+		}
+	}
+	// Scroll position in the bottom border
+	if l.visible < l.rows {
+		info := []rune(fmt.Sprintf(" %d/%d ", e.menuScroll+1, l.rows-l.visible+1))
+		if len(info)+2 <= l.width-2 {
+			for i, r := range info {
+				s.SetContent(x0+l.width-2-len(info)+i, y0+bottom, r, nil, borderStyle)
+			}
+		}
+	}
+
+	// Draw menu items, column by column
+	for i, item := range items {
+		col, row := i/l.rows, i%l.rows-e.menuScroll
+		if row < 0 || row >= l.visible {
+			continue
+		}
+		style := itemStyle
+		if !item.Implemented {
+			style = dimStyle
+		}
+		// Format: " k   Label text"
+		runes := []rune(" " + string(item.Key) + "   " + item.Label)
+		if len(runes) > l.colWidth {
+			runes = runes[:l.colWidth]
+		}
+		x := x0 + 1 + col*l.colWidth
+		for j, r := range runes {
+			s.SetContent(x+j, y0+1+row, r, nil, style)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestLayoutMenu(t *testing.T) {
+	items := make([]SpaceMenuItem, 10)
+	for i := range items {
+		items[i] = SpaceMenuItem{Key: rune('a' + i), Label: "Label"}
+	}
+	tests := []struct {
+		name                        string
+		maxWidth, maxHeight         int
+		columns, rows, visible, wid int
+	}{
+		{"one column", 40, 20, 1, 10, 10, 14},
+		{"two columns", 40, 7, 2, 5, 5, 26},
+		{"three columns", 40, 6, 3, 4, 4, 38},
+		{"scrolls", 20, 6, 1, 10, 4, 14},
+	}
+	for _, tt := range tests {
+		l := layoutMenu(items, tt.maxWidth, tt.maxHeight)
+		if l.columns != tt.columns || l.rows != tt.rows || l.visible != tt.visible || l.width != tt.wid {
+			t.Errorf("%s: layout = %+v, want %d columns, %d rows, %d visible, width %d",
+				tt.name, l, tt.columns, tt.rows, tt.visible, tt.wid)
+		}
+		if l.width > tt.maxWidth || l.height > tt.maxHeight {
+			t.Errorf("%s: box %dx%d overflows %dx%d", tt.name, l.width, l.height, tt.maxWidth, tt.maxHeight)
+		}
+	}
+}
+
+func TestSpaceMenuScrollsOnSmallScreen(t *testing.T) {
+	e := newTestEditor("text")
+	e.HandleKey(keyRune(' '))
+	_, rows := renderRows(t, e, 30, 8)
+	if !strings.Contains(strings.Join(rows, "\n"), "Space") {
+		t.Fatalf("menu not drawn on a small screen:\n%s", strings.Join(rows, "\n"))
+	}
+	first := SpaceMenuItems[0].Label
+	if !strings.Contains(strings.Join(rows, "\n"), first[:10]) {
+		t.Fatalf("first item %q not shown:\n%s", first, strings.Join(rows, "\n"))
+	}
+
+	e.HandleKey(tcell.NewEventKey(tcell.KeyPgDn, 0, tcell.ModNone))
+	if !e.inSequence(seqSpace) {
+		t.Fatalf("PgDn closed the menu")
+	}
+	_, rows = renderRows(t, e, 30, 8)
+	if e.menuScroll == 0 || strings.Contains(strings.Join(rows, "\n"), first[:10]) {
+		t.Fatalf("PgDn didn't scroll the menu (scroll %d):\n%s", e.menuScroll, strings.Join(rows, "\n"))
+	}
+
+	// A bigger screen lays the menu out again without scrolling
+	_, rows = renderRows(t, e, 120, 40)
+	if e.menuScroll != 0 || !strings.Contains(strings.Join(rows, "\n"), first[:10]) {
+		t.Fatalf("menu not laid out again after resize:\n%s", strings.Join(rows, "\n"))
+	}
+}
+
+func TestWhichKeyDelay(t *testing.T) {
+	e := newTestEditor("text", "more")
+	e.whichKeyDelay = time.Hour
+	e.HandleKey(keyRune('g'))
+	_, rows := renderRows(t, e, 80, 24)
+	if strings.Contains(strings.Join(rows, "\n"), "Goto") {
+		t.Fatalf("menu shown before which-key-delay")
+	}
+	if at := e.MenuShowsAt(); at.IsZero() {
+		t.Fatalf("MenuShowsAt() is zero with the menu held back")
+	}
+
+	e.sequence.start = time.Now().Add(-2 * time.Hour)
+	_, rows = renderRows(t, e, 80, 24)
+	if !strings.Contains(strings.Join(rows, "\n"), "Goto") {
+		t.Fatalf("menu not shown after which-key-delay")
+	}
+	if at := e.MenuShowsAt(); !at.IsZero() {
+		t.Fatalf("MenuShowsAt() = %v after the menu is shown", at)
+	}
+
+	e.HandleKey(keyRune('e'))
+	if e.cursor.Row != 1 || e.PendingKeys() != "" {
+		t.Fatalf("ge: row %d, pending %q", e.cursor.Row, e.PendingKeys())
+	}
+
+	// The sequence works the same with its menu still hidden
+	e.HandleKey(keyRune('g'))
+	e.HandleKey(keyRune('g'))
+	if e.cursor.Row != 0 || e.PendingKeys() != "" {
+		t.Fatalf("gg with hidden menu: row %d, pending %q", e.cursor.Row, e.PendingKeys())
+	}
+}
@@ -0,0 +1,58 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kobzarvs/qedit/pkg/core"
+)
+
+// :merge takes the changes something else made to the open file into the
+// buffer instead of overwriting them: a three-way merge of the buffer and
+// the file on disk against the text last read or written. Changes that
+// conflict are left between <<<<<<< and >>>>>>> markers to resolve in the
+// buffer; the merge is one undo step, and :w then writes the result.
+
+// mergeLabels name the sides in conflict markers
+var mergeLabels = core.ConflictLabels{Mine: "buffer", Base: "opened", Theirs: "disk"}
+
+// execMergeCommand runs :merge
+func (e *Editor) execMergeCommand() {
+	if e.filename == "" {
+		e.setStatus("no file name")
+		return
+	}
+	if e.refuseEdit() {
+		return
+	}
+	name := filepath.Base(e.filename)
+	if !e.changedOnDisk() {
+		e.setStatus(name + " didn't change on disk")
+		return
+	}
+	data, err := os.ReadFile(e.filename)
+	if err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	base, _ := cutBOM(e.disk.data)
+	theirs, _ := cutBOM(data)
+	merged, conflicts := core.Merge3(splitLines(base), e.lines, splitLines(theirs), mergeLabels)
+	e.replaceLines(merged)
+	// The file on disk is now the base, so :w writes over it
+	e.rememberDisk(data)
+	e.clampCursorCol()
+	if conflicts == 0 {
+		e.setStatus("merged the changes to " + name + " (:w writes the result)")
+		return
+	}
+	for row, line := range e.lines {
+		if strings.HasPrefix(string(line), "<<<<<<< "+mergeLabels.Mine) {
+			e.cursor = Cursor{Row: row}
+			break
+		}
+	}
+	e.setStatus(fmt.Sprintf("merged the changes to %s: %d %s between <<<<<<< and >>>>>>>", name, conflicts, plural(conflicts, "conflict", "conflicts")))
+}
@@ -0,0 +1,58 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeChangesFromDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\nfour\nfive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor("")
+	e.DisableState()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	e.execCommand("merge")
+	if e.statusMessage != "f.txt didn't change on disk" {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+
+	e.insertRune('1')
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\nfour\n5"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Save(""); err == nil || !strings.Contains(err.Error(), ":merge") {
+		t.Fatalf("Save = %v", err)
+	}
+	e.execCommand("merge")
+	if got := e.Content(); got != "1one\ntwo\nthree\nfour\n5" {
+		t.Fatalf("merged %q", got)
+	}
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save after merge: %v", err)
+	}
+
+	// Both sides changed the same line
+	e.cursor = Cursor{Row: 2}
+	e.insertRune('3')
+	if err := os.WriteFile(path, []byte("1one\ntwo\nTHREE\nfour\n5"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e.execCommand("merge")
+	want := "1one\ntwo\n<<<<<<< buffer\n3three\n||||||| opened\nthree\n=======\nTHREE\n>>>>>>> disk\nfour\n5"
+	if got := e.Content(); got != want || e.cursor.Row != 2 {
+		t.Fatalf("merged %q, cursor %+v", got, e.cursor)
+	}
+	if !strings.Contains(e.statusMessage, "1 conflict ") {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+	e.Undo()
+	if got := e.Content(); got != "1one\ntwo\n3three\nfour\n5" {
+		t.Fatalf("after undo %q", got)
+	}
+}
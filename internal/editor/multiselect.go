@@ -0,0 +1,399 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Multiple selections: s selects the matches of a regex inside the
+// selections, K and Alt+K keep or remove the selections matching one,
+// Alt+s splits them into one selection per line, and , drops all but the
+// primary selection. ( and ) make the previous or next selection the
+// primary one and Alt+, drops it; the view follows the primary selection. The primary selection is the editor's own cursor and
+// selection; the others are kept in e.selections. d, c, i, a, I, A and
+// typing in insert mode work at every selection as one undo step. Other
+// actions work on the primary selection and drop the rest, and so does an
+// edit made any other way, since the other selections no longer fit the
+// text.
+
+// selection is one of several selections: start and end as selectionStart
+// and selectionEnd, nothing selected when they are equal, and its cursor
+type selection struct {
+	start, end, cursor Cursor
+}
+
+// span returns the selected text in order, or an empty range at the cursor
+func (s selection) span() (Cursor, Cursor) {
+	if s.start == s.end {
+		return s.cursor, s.cursor
+	}
+	if cursorLess(s.end, s.start) {
+		return s.end, s.start
+	}
+	return s.start, s.end
+}
+
+// shift moves s through an edit that replaced [start, oldEnd) with text
+// ending at newEnd
+func (s selection) shift(start, oldEnd, newEnd Cursor) selection {
+	return selection{
+		start:  shiftPosForEdit(s.start, start, oldEnd, newEnd),
+		end:    shiftPosForEdit(s.end, start, oldEnd, newEnd),
+		cursor: shiftPosForEdit(s.cursor, start, oldEnd, newEnd),
+	}
+}
+
+// extraSelections returns the selections beside the primary one, dropping
+// them when the text changed since they were made
+func (e *Editor) extraSelections() []selection {
+	if len(e.selections) > 0 && e.selectionsTick != e.changeTick {
+		e.selections = nil
+	}
+	return e.selections
+}
+
+// primarySelection returns the editor's own selection
+func (e *Editor) primarySelection() selection {
+	if !e.selectionActive {
+		return selection{start: e.cursor, end: e.cursor, cursor: e.cursor}
+	}
+	return selection{start: e.selectionStart, end: e.selectionEnd, cursor: e.cursor}
+}
+
+// setPrimarySelection makes s the editor's own selection
+func (e *Editor) setPrimarySelection(s selection) {
+	e.cursor = s.cursor
+	if s.start == s.end {
+		e.clearSelection()
+		return
+	}
+	e.selectionStart, e.selectionEnd = s.start, s.end
+	e.selectionActive = true
+}
+
+// allSelections returns every selection in text order and the index of
+// the primary one
+func (e *Editor) allSelections() ([]selection, int) {
+	primary := e.primarySelection()
+	all := append([]selection{primary}, e.extraSelections()...)
+	sortSelections(all)
+	for i, s := range all {
+		if s == primary {
+			return all, i
+		}
+	}
+	return all, 0
+}
+
+// setSelections makes all[primary] the primary selection and the rest the
+// other ones. Selections sharing a cursor become one.
+func (e *Editor) setSelections(all []selection, primary int) {
+	p := all[primary]
+	e.setPrimarySelection(p)
+	rest := make([]selection, 0, len(all)-1)
+	for i, s := range all {
+		if i != primary && s.cursor != p.cursor {
+			rest = append(rest, s)
+		}
+	}
+	sortSelections(rest)
+	e.selections = rest[:0]
+	for _, s := range rest {
+		if n := len(e.selections); n > 0 && e.selections[n-1].cursor == s.cursor {
+			continue
+		}
+		e.selections = append(e.selections, s)
+	}
+	e.selectionsTick = e.changeTick
+}
+
+func sortSelections(sels []selection) {
+	sort.SliceStable(sels, func(i, j int) bool {
+		a, _ := sels[i].span()
+		b, _ := sels[j].span()
+		if a != b {
+			return cursorLess(a, b)
+		}
+		return cursorLess(sels[i].cursor, sels[j].cursor)
+	})
+}
+
+// eachSelection runs fn with each selection in turn made the primary one.
+// The other selections move along with the edits fn makes, and the edits
+// undo as one step.
+func (e *Editor) eachSelection(fn func()) {
+	if len(e.extraSelections()) == 0 {
+		fn()
+		return
+	}
+	all, primary := e.allSelections()
+	current := 0
+	stop := e.OnChange(func(c TextChange) {
+		newEnd := textEnd(c.Range.Start, splitLines([]byte(c.NewText)))
+		for i := range all {
+			if i != current {
+				all[i] = all[i].shift(c.Range.Start, c.Range.End, newEnd)
+			}
+		}
+	})
+	undoFrom := len(e.undo)
+	for current = range all {
+		e.setPrimarySelection(all[current])
+		fn()
+		all[current] = e.primarySelection()
+	}
+	stop()
+	e.joinUndoSince(undoFrom)
+	e.setSelections(all, primary)
+}
+
+// joinUndoSince puts the undo actions recorded since e.undo had n entries
+// into one undo step
+func (e *Editor) joinUndoSince(n int) {
+	if len(e.undo) <= n+1 {
+		return
+	}
+	group := e.undo[len(e.undo)-1].group
+	for i := n; i < len(e.undo); i++ {
+		e.undo[i].group = group
+	}
+}
+
+// keepPrimarySelection drops all selections but the primary one (,)
+func (e *Editor) keepPrimarySelection() {
+	e.selections = nil
+}
+
+// removePrimarySelection drops the primary selection (Alt+,) and makes
+// the next one primary, or the last one when it was the last. With a
+// single selection Alt+, repeats the last find the other way instead.
+func (e *Editor) removePrimarySelection() {
+	all, primary := e.allSelections()
+	if len(all) < 2 {
+		e.repeatFindChar(true, e.count)
+		return
+	}
+	all = append(all[:primary], all[primary+1:]...)
+	e.setSelections(all, min(primary, len(all)-1))
+	e.showPrimarySelection()
+	e.setStatus(selectionCount(len(all)))
+}
+
+// rotateSelections makes the selection dir (1 or -1) away from the primary
+// one in text order the primary selection, wrapping around (( and ))
+func (e *Editor) rotateSelections(dir int) {
+	all, primary := e.allSelections()
+	if len(all) < 2 {
+		return
+	}
+	e.setSelections(all, (primary+dir+len(all))%len(all))
+	e.showPrimarySelection()
+}
+
+// showPrimarySelection scrolls the primary selection's cursor into view
+func (e *Editor) showPrimarySelection() {
+	e.freeScroll = false
+	e.revealCursor()
+	e.ensureCursorVisible(e.viewHeightCached())
+}
+
+// promptCommand opens the command line with cmd typed in, for a key whose
+// command still needs an argument
+func (e *Editor) promptCommand(cmd string) {
+	e.mode = ModeCommand
+	e.cmd = []rune(cmd)
+	e.cmdCursor = len(e.cmd)
+	e.cmdHistoryIndex = -1
+}
+
+// selectionRegexp compiles the pattern of :select, :keep or :remove
+func (e *Editor) selectionRegexp(name, pattern string) *regexp.Regexp {
+	if pattern == "" {
+		e.setStatus("usage: :" + name + " pattern")
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		e.setStatus("bad pattern: " + err.Error())
+		return nil
+	}
+	return re
+}
+
+// execSelectCommand runs :select pattern (s): the matches inside the
+// selections, or in the whole buffer when nothing is selected, become the
+// selections. The first match from the cursor on is the primary one.
+func (e *Editor) execSelectCommand(pattern string) {
+	re := e.selectionRegexp("select", pattern)
+	if re == nil {
+		return
+	}
+	all, _ := e.allSelections()
+	var spans [][2]Cursor
+	for _, s := range all {
+		if start, end := s.span(); start != end {
+			spans = append(spans, [2]Cursor{start, end})
+		}
+	}
+	if len(spans) == 0 {
+		last := len(e.lines) - 1
+		spans = [][2]Cursor{{{}, {Row: last, Col: len(e.lines[last])}}}
+	}
+	var matches []selection
+	primary := -1
+	for _, sp := range spans {
+		for row := sp[0].Row; row <= sp[1].Row; row++ {
+			lo, hi := 0, len(e.lines[row])
+			if row == sp[0].Row {
+				lo = sp[0].Col
+			}
+			if row == sp[1].Row {
+				hi = sp[1].Col
+			}
+			seg := string(e.lines[row][lo:hi])
+			for _, m := range re.FindAllStringIndex(seg, -1) {
+				if m[0] == m[1] {
+					continue
+				}
+				start := Cursor{Row: row, Col: lo + utf8.RuneCountInString(seg[:m[0]])}
+				end := Cursor{Row: row, Col: lo + utf8.RuneCountInString(seg[:m[1]])}
+				if primary < 0 && !cursorLess(start, e.cursor) {
+					primary = len(matches)
+				}
+				matches = append(matches, selection{start: start, end: end, cursor: end})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		e.setStatus("no matches")
+		return
+	}
+	if primary < 0 {
+		primary = 0
+	}
+	e.selectMode = false
+	e.setSelections(matches, primary)
+	e.setStatus(selectionCount(len(matches)))
+}
+
+// execKeepCommand runs :keep pattern (K), keeping the selections whose text
+// matches, and :remove pattern (Alt+K), removing them. A cursor with
+// nothing selected counts as the character under it. When the primary
+// selection goes, the next one left takes its place.
+func (e *Editor) execKeepCommand(name, pattern string, keep bool) {
+	re := e.selectionRegexp(name, pattern)
+	if re == nil {
+		return
+	}
+	all, primary := e.allSelections()
+	var kept []selection
+	newPrimary := -1
+	for i, s := range all {
+		start, end := s.span()
+		text := e.textInRange(start, end)
+		if start == end {
+			text = string(e.runeAt(start))
+		}
+		if re.MatchString(text) != keep {
+			continue
+		}
+		if newPrimary < 0 && i >= primary {
+			newPrimary = len(kept)
+		}
+		kept = append(kept, s)
+	}
+	if len(kept) == 0 {
+		e.setStatus("no selections left")
+		return
+	}
+	if newPrimary < 0 {
+		newPrimary = len(kept) - 1
+	}
+	e.setSelections(kept, newPrimary)
+	e.setStatus(selectionCount(len(kept)))
+}
+
+// splitSelectionLines splits every selection spanning lines into one
+// selection per line (Alt+s), without the line breaks. A selection ending
+// at the start of a line doesn't take that line in. The primary selection
+// becomes the part on its cursor line.
+func (e *Editor) splitSelectionLines() {
+	all, primary := e.allSelections()
+	var split []selection
+	newPrimary, changed := 0, false
+	for i, s := range all {
+		if i == primary {
+			newPrimary = len(split)
+		}
+		start, end := s.span()
+		if start.Row == end.Row {
+			split = append(split, s)
+			continue
+		}
+		changed = true
+		for row := start.Row; row <= end.Row; row++ {
+			lo, hi := 0, len(e.lines[row])
+			if row == start.Row {
+				lo = start.Col
+			}
+			if row == end.Row {
+				if end.Col == 0 {
+					break
+				}
+				hi = end.Col
+			}
+			if i == primary && row > start.Row && row <= s.cursor.Row {
+				newPrimary = len(split)
+			}
+			split = append(split, selection{start: Cursor{Row: row, Col: lo}, end: Cursor{Row: row, Col: hi}, cursor: Cursor{Row: row, Col: hi}})
+		}
+	}
+	if !changed {
+		return
+	}
+	e.selectMode = false
+	e.setSelections(split, newPrimary)
+	e.setStatus(selectionCount(len(split)))
+}
+
+func selectionCount(n int) string {
+	return fmt.Sprintf("%d %s", n, plural(n, "selection", "selections"))
+}
+
+// lineSelectionSpans returns the columns of line row covered by the
+// selections, the primary one first
+func (e *Editor) lineSelectionSpans(row int) []colSpan {
+	var spans []colSpan
+	if lo, hi, ok := e.selectionRangeForLine(row); ok {
+		spans = append(spans, colSpan{lo, hi})
+	}
+	for _, s := range e.extraSelections() {
+		start, end := s.span()
+		if lo, hi, ok := e.spanOnLine(start, end, row); ok {
+			spans = append(spans, colSpan{lo, hi})
+		}
+	}
+	return spans
+}
+
+// drawSelectionCursors draws the cursors of the selections beside the
+// primary one as blocks
+func (e *Editor) drawSelectionCursors(s tcell.Screen, w, viewHeight int) {
+	for _, sel := range e.extraSelections() {
+		row, col := sel.cursor.Row, sel.cursor.Col
+		if row >= len(e.lines) || e.foldHiding(row) >= 0 {
+			continue
+		}
+		y := e.visibleIndex(row) - e.visibleIndex(e.scroll)
+		x := e.editorX + e.gutterWidth() + e.rowDisplayCol(row, col) - e.scrollX
+		if y < 0 || y >= viewHeight || x < e.editorX+e.gutterWidth() || x >= w {
+			continue
+		}
+		r, combining, _, _ := s.GetContent(x, y)
+		s.SetContent(x, y, r, combining, e.styleMain.Reverse(true))
+	}
+}
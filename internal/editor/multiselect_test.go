@@ -0,0 +1,235 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// selectedTexts returns the text of every selection in text order
+func selectedTexts(e *Editor) []string {
+	all, _ := e.allSelections()
+	var texts []string
+	for _, s := range all {
+		start, end := s.span()
+		texts = append(texts, e.textInRange(start, end))
+	}
+	return texts
+}
+
+func typeKeys(e *Editor, keys string) {
+	for _, r := range keys {
+		e.HandleKey(keyRune(r))
+	}
+}
+
+func TestSelectKeepRemove(t *testing.T) {
+	e := newTestEditor("foo1 bar foo2", "foo3 foo")
+	e.execCommand(`select foo\d`)
+	if got := strings.Join(selectedTexts(e), ","); got != "foo1,foo2,foo3" {
+		t.Fatalf("select = %q", got)
+	}
+	if start, end, _ := e.selectionRange(); e.textInRange(start, end) != "foo1" {
+		t.Fatalf("primary = %v..%v, want the first match", start, end)
+	}
+
+	// The primary selection goes with foo1; foo2 after it takes its place
+	e.execCommand("keep [23]")
+	if got := strings.Join(selectedTexts(e), ","); got != "foo2,foo3" || e.statusMessage != "2 selections" {
+		t.Fatalf("keep = %q, status %q", got, e.statusMessage)
+	}
+	if start, end, _ := e.selectionRange(); e.textInRange(start, end) != "foo2" {
+		t.Fatalf("primary after keep = %v..%v", start, end)
+	}
+	e.execCommand("remove 3$")
+	if got := strings.Join(selectedTexts(e), ","); got != "foo2" {
+		t.Fatalf("remove = %q", got)
+	}
+	e.execCommand("remove foo")
+	if e.statusMessage != "no selections left" || len(selectedTexts(e)) != 1 {
+		t.Fatalf("removing all: status %q, selections %q", e.statusMessage, selectedTexts(e))
+	}
+	e.execCommand("select nothing")
+	if e.statusMessage != "no matches" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestSelectionKeys(t *testing.T) {
+	e := newTestEditor("a1 b2 a3 b4")
+	e.HandleKey(keyRune('s'))
+	if e.mode != ModeCommand || string(e.cmd) != "select " {
+		t.Fatalf("s opened %q in mode %v", string(e.cmd), e.mode)
+	}
+	typeKeys(e, `\w\d`)
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if n := len(selectedTexts(e)); n != 4 {
+		t.Fatalf("%d selections, want 4", n)
+	}
+	e.HandleKey(keyRune('K'))
+	typeKeys(e, "a")
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if got := strings.Join(selectedTexts(e), ","); got != "a1,a3" {
+		t.Fatalf("K = %q", got)
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'K', tcell.ModAlt))
+	if string(e.cmd) != "remove " {
+		t.Fatalf("Alt+K opened %q", string(e.cmd))
+	}
+	typeKeys(e, "3")
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if got := strings.Join(selectedTexts(e), ","); got != "a1" {
+		t.Fatalf("Alt+K = %q", got)
+	}
+
+	e.execCommand(`select \w\d`)
+	e.HandleKey(keyRune(','))
+	if got := strings.Join(selectedTexts(e), ","); got != "a1" {
+		t.Fatalf(", left %q", got)
+	}
+}
+
+// primaryText returns the text of the primary selection
+func primaryText(e *Editor) string {
+	start, end := e.primarySelection().span()
+	return e.textInRange(start, end)
+}
+
+func TestRotateSelections(t *testing.T) {
+	e := newTestEditor("a1 b2 c3")
+	e.execCommand(`select \w\d`)
+	if got := primaryText(e); got != "a1" {
+		t.Fatalf("primary = %q", got)
+	}
+	for _, step := range []struct {
+		key  rune
+		want string
+	}{{')', "b2"}, {')', "c3"}, {')', "a1"}, {'(', "c3"}, {'(', "b2"}} {
+		e.HandleKey(keyRune(step.key))
+		if got := primaryText(e); got != step.want {
+			t.Fatalf("%c: primary = %q, want %q", step.key, got, step.want)
+		}
+		if got := strings.Join(selectedTexts(e), ","); got != "a1,b2,c3" {
+			t.Fatalf("%c: selections = %q", step.key, got)
+		}
+	}
+}
+
+func TestRemovePrimarySelection(t *testing.T) {
+	altComma := tcell.NewEventKey(tcell.KeyRune, ',', tcell.ModAlt)
+	e := newTestEditor("a1 b2 c3")
+	e.execCommand(`select \w\d`)
+	e.HandleKey(keyRune(')'))
+
+	// The next selection takes the place of the removed one
+	e.HandleKey(altComma)
+	if got := strings.Join(selectedTexts(e), ","); got != "a1,c3" || primaryText(e) != "c3" {
+		t.Fatalf("selections = %q, primary %q", got, primaryText(e))
+	}
+	// and the one before when the last one goes
+	e.HandleKey(altComma)
+	if got := strings.Join(selectedTexts(e), ","); got != "a1" || primaryText(e) != "a1" {
+		t.Fatalf("selections = %q, primary %q", got, primaryText(e))
+	}
+
+	// With one selection Alt+, repeats the last find the other way
+	e = newTestEditor("a,b,c")
+	e.HandleKey(keyRune('f'))
+	e.HandleKey(keyRune(','))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, '.', tcell.ModAlt))
+	e.HandleKey(altComma)
+	if e.cursor.Col != 1 {
+		t.Fatalf("Alt+, = %d, want 1", e.cursor.Col)
+	}
+}
+
+func TestRotateSelectionsScrolls(t *testing.T) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = "x"
+	}
+	lines[0], lines[90] = "mark", "mark"
+	e := newTestEditor(lines...)
+	e.viewHeight = 20
+	e.execCommand("select mark")
+	if e.cursor.Row != 0 {
+		t.Fatalf("primary on row %d", e.cursor.Row)
+	}
+	e.HandleKey(keyRune(')'))
+	if e.cursor.Row != 90 || e.scroll > 90 || e.scroll+e.viewHeight <= 90 {
+		t.Fatalf("primary on row %d, scroll %d", e.cursor.Row, e.scroll)
+	}
+	e.HandleKey(keyRune(')'))
+	if e.cursor.Row != 0 || e.scroll != 0 {
+		t.Fatalf("primary on row %d, scroll %d", e.cursor.Row, e.scroll)
+	}
+}
+
+func TestEditAtEverySelection(t *testing.T) {
+	e := newTestEditor("x = foo(1)", "y = foo(2)", "foo")
+	e.execCommand("select foo")
+	_, lines := renderRows(t, e, 40, 6)
+	rows := strings.Join(lines, "\n")
+	if !strings.Contains(rows, "3 sels") {
+		t.Fatalf("statusline doesn't count the selections:\n%s", rows)
+	}
+
+	// c changes every selection and typing goes in at every cursor
+	e.HandleKey(keyRune('c'))
+	typeKeys(e, "bar")
+	e.HandleKey(tcell.NewEventKey(tcell.KeyBackspace2, 0, 0))
+	typeKeys(e, "z")
+	e.HandleKey(keyEsc())
+	if got := joinLines(e.lines); got != "x = baz(1)\ny = baz(2)\nbaz" {
+		t.Fatalf("after c = %q", got)
+	}
+	if len(e.selections) != 2 {
+		t.Fatalf("%d other cursors left, want 2", len(e.selections))
+	}
+
+	// One u takes back the keystroke at every cursor
+	e.HandleKey(keyRune('u'))
+	if got := joinLines(e.lines); got != "x = ba(1)\ny = ba(2)\nba" {
+		t.Fatalf("after u = %q", got)
+	}
+	if len(e.selections) != 0 {
+		t.Fatal("undo kept the other selections")
+	}
+
+	// Another edit drops the other selections
+	e = newTestEditor("ab ab")
+	e.execCommand("select b")
+	e.HandleKey(keyRune('j'))
+	if len(e.extraSelections()) != 0 {
+		t.Fatal("a motion kept the other selections")
+	}
+	e.execCommand("select a")
+	e.insertRune('!')
+	if len(e.extraSelections()) != 0 {
+		t.Fatal("an edit at the primary cursor kept the other selections")
+	}
+}
+
+func TestSplitSelectionLines(t *testing.T) {
+	e := selectText(newTestEditor("one", "", "two", "three"), Cursor{Row: 0, Col: 1}, Cursor{Row: 3, Col: 0})
+	e.HandleKey(tcell.NewEventKey(tcell.KeyRune, 's', tcell.ModAlt))
+	if got := strings.Join(selectedTexts(e), ","); got != "ne,,two" {
+		t.Fatalf("Alt+s = %q", got)
+	}
+
+	// A prefix typed at every line start
+	e.HandleKey(keyRune('I'))
+	typeKeys(e, "- ")
+	e.HandleKey(keyEsc())
+	if got := joinLines(e.lines); got != "- one\n- \n- two\nthree" {
+		t.Fatalf("after I = %q", got)
+	}
+
+	// A selection on one line stays as it is
+	e = selectText(newTestEditor("one two"), Cursor{Row: 0, Col: 0}, Cursor{Row: 0, Col: 3})
+	e.splitSelectionLines()
+	if got := strings.Join(selectedTexts(e), ","); got != "one" {
+		t.Fatalf("one-line selection split into %q", got)
+	}
+}
@@ -0,0 +1,116 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kobzarvs/qedit/internal/platform/clipboard"
+)
+
+const actionPasteTable = "paste_clipboard_table" // :pastetable - paste tab-separated clipboard data as aligned columns
+
+// parseTable splits tab-separated text (as copied from a spreadsheet) into
+// rows of cells. A trailing line break doesn't make an empty row.
+func parseTable(text string) [][]string {
+	text = strings.TrimSuffix(text, "\n")
+	var rows [][]string
+	for _, line := range strings.Split(text, "\n") {
+		rows = append(rows, strings.Split(strings.TrimSuffix(line, "\r"), "\t"))
+	}
+	return rows
+}
+
+// alignTable joins the cells of each row with sep between columns, padding
+// the cells so the columns line up. The last cell of a row isn't padded.
+func alignTable(rows [][]string, sep string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+	out := make([]string, len(rows))
+	for r, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-len([]rune(cell))))
+			}
+		}
+		out[r] = strings.TrimRight(b.String(), " ")
+	}
+	return out
+}
+
+// pasteTableFromClipboard pastes the system clipboard with pasteTable
+func (e *Editor) pasteTableFromClipboard(sep string) {
+	text, err := clipboard.Read()
+	if err != nil {
+		e.setStatus("clipboard unavailable")
+		return
+	}
+	e.pasteTable(text, sep)
+}
+
+// pasteTable pastes tab-separated text as a block at the cursor: row i of
+// the table goes into line cursor+i at the cursor's screen column, with
+// its cells aligned into columns separated by sep ("" for two spaces, or
+// a character like "|" with a space around it). Short lines are padded to
+// the column, text after the block is pushed right past it, and lines are
+// added past the end of the buffer. The paste is one undo step.
+func (e *Editor) pasteTable(text, sep string) {
+	if strings.TrimSpace(text) == "" {
+		e.setStatus("clipboard empty")
+		return
+	}
+	if sep == "" {
+		sep = "  "
+	} else {
+		sep = " " + sep + " "
+	}
+	rows := parseTable(text)
+	lines := alignTable(rows, sep)
+
+	start := e.cursor
+	x := e.displayCol(e.lines[start.Row], start.Col)
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	width := 0
+	for _, cells := range lines {
+		width = max(width, len([]rune(cells)))
+	}
+	for i, cells := range lines {
+		row := start.Row + i
+		if row == len(e.lines) {
+			last := len(e.lines) - 1
+			_, _ = e.InsertAt(Cursor{Row: last, Col: len(e.lines[last])}, "\n")
+		}
+		line := e.lines[row]
+		col := e.logicalColAt(line, x)
+		if col < len(line) {
+			// Text after the block stays lined up past it
+			cells += strings.Repeat(" ", width-len([]rune(cells)))
+		} else if w := e.displayCol(line, len(line)); w < x {
+			if cells == "" {
+				continue
+			}
+			cells = strings.Repeat(" ", x-w) + cells
+		}
+		_, _ = e.InsertAt(Cursor{Row: row, Col: col}, cells)
+	}
+	e.cursor = start
+	e.clampCursorCol()
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	e.setStatus(fmt.Sprintf("pasted %d rows x %d columns", len(rows), columns))
+}
@@ -0,0 +1,28 @@
+package editor
+
+import "testing"
+
+func TestPasteTable(t *testing.T) {
+	e := newTestEditor("config:", "  a")
+	e.cursor = Cursor{Row: 1, Col: 2}
+	e.pasteTable("name\tport\thost\nweb\t8080\tlocalhost\r\ndatabase\t5432\n", "")
+	want := "config:\n  name      port  host     a\n  web       8080  localhost\n  database  5432"
+	if got := e.Content(); got != want {
+		t.Fatalf("pasted:\n%s", got)
+	}
+	if e.cursor != (Cursor{Row: 1, Col: 2}) || e.statusMessage != "pasted 3 rows x 3 columns" {
+		t.Fatalf("cursor %+v, status %q", e.cursor, e.statusMessage)
+	}
+	e.Undo()
+	if got := e.Content(); got != "config:\n  a" {
+		t.Fatalf("not undone as one step: %q", got)
+	}
+
+	// With a separator, into a block of short lines
+	e = newTestEditor("| x", "|", "")
+	e.cursor = Cursor{Row: 0, Col: 2}
+	e.pasteTable("a\tbb\nccc\td", "|")
+	if got := e.Content(); got != "| a   | bbx\n| ccc | d\n" {
+		t.Fatalf("pasted: %q", got)
+	}
+}
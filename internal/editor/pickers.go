@@ -0,0 +1,118 @@
+package editor
+
+import (
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/session"
+)
+
+// Picker types whose size is remembered across sessions
+const (
+	pickerReferences = "references" // width of the references list left of the preview
+	pickerBranches   = "branches"   // width of the branch picker box
+)
+
+// pickerResizeStep is how many columns Ctrl+Left/Right resizes a picker by
+const pickerResizeStep = 2
+
+// pickerWidth returns the width chosen for a picker type, or 0 for its
+// default size
+func (e *Editor) pickerWidth(picker string) int {
+	if w, ok := e.pickerWidths[picker]; ok {
+		return w
+	}
+	if e.sessionManager != nil {
+		if g, ok := e.sessionManager.GetPickerGeometry(picker); ok {
+			return g.Width
+		}
+	}
+	return 0
+}
+
+// setPickerWidth records the width chosen for a picker type and saves it
+// with the session
+func (e *Editor) setPickerWidth(picker string, width int) {
+	if e.pickerWidths == nil {
+		e.pickerWidths = map[string]int{}
+	}
+	e.pickerWidths[picker] = width
+	if e.sessionManager != nil {
+		e.sessionManager.SetPickerGeometry(picker, session.PickerGeometry{Width: width})
+	}
+}
+
+// refsPickerWidth returns the width of the references list on a w-column
+// screen. The rest of the screen is the preview, which keeps at least 20
+// columns.
+func (e *Editor) refsPickerWidth(w int) int {
+	width := e.pickerWidth(pickerReferences)
+	if width == 0 {
+		return min(max(w/4, 20), w/2)
+	}
+	return max(min(width, w-20), min(10, w))
+}
+
+// branchPickerBoxWidth returns the width of the branch picker box on a
+// w-column screen: wide enough for the longest branch unless resized
+func (e *Editor) branchPickerBoxWidth(w int) int {
+	boxWidth := e.pickerWidth(pickerBranches)
+	if boxWidth == 0 {
+		maxItem := len([]rune("Select git branch")) + 2
+		for _, name := range e.branchPickerItems {
+			maxItem = max(maxItem, len([]rune(name))+2) // "* " or "  " prefix for all branches
+		}
+		boxWidth = maxItem + 4
+	}
+	if boxWidth > w-2 {
+		boxWidth = w - 2
+	}
+	if boxWidth < 8 {
+		if w < 8 {
+			boxWidth = w
+		} else {
+			boxWidth = 8
+		}
+	}
+	return boxWidth
+}
+
+// resizePickerKey resizes a picker with Ctrl+Left/Right and reports
+// whether ev was one of them. width is the picker's current width.
+func (e *Editor) resizePickerKey(picker string, ev *tcell.EventKey, width int) bool {
+	switch keyString(ev) {
+	case "ctrl+left":
+		e.setPickerWidth(picker, max(width-pickerResizeStep, 1))
+	case "ctrl+right":
+		e.setPickerWidth(picker, width+pickerResizeStep)
+	default:
+		return false
+	}
+	return true
+}
+
+// handlePickerDrag resizes the references list by dragging the separator
+// between it and the preview, and reports whether ev was part of a drag
+func (e *Editor) handlePickerDrag(ev *tcell.EventMouse) bool {
+	x, _ := ev.Position()
+	if ev.Buttons()&tcell.Button1 == 0 {
+		dragging := e.pickerDrag != ""
+		e.pickerDrag = ""
+		return dragging
+	}
+	if e.pickerDrag == "" {
+		if !e.refsSplitShown() || x != e.refsPickerWidth(e.viewWidth)-1 {
+			return false
+		}
+		e.pickerDrag = pickerReferences
+		return true
+	}
+	e.setPickerWidth(e.pickerDrag, max(x+1, 1))
+	return true
+}
+
+// refsSplitShown reports whether the references list is drawn next to
+// the preview (the file sidebar takes its place when open)
+func (e *Editor) refsSplitShown() bool {
+	sidebarOpen := e.sidebar != nil && e.sidebar.Visible
+	return !sidebarOpen && e.popupOpen(refsPickerPopup{}) && len(e.refsPickerItems) > 0
+}
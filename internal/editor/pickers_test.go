@@ -0,0 +1,99 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/config"
+)
+
+func keyCtrl(k tcell.Key) *tcell.EventKey {
+	return tcell.NewEventKey(k, 0, tcell.ModCtrl)
+}
+
+func TestRefsPickerResizeKeys(t *testing.T) {
+	e := newTestEditor("a", "b")
+	e.DisableState()
+	e.viewWidth = 80
+	e.showRefsPicker("References", []LSPLocation{{Path: "a.go"}, {Path: "b.go", StartLine: 1}})
+	if got := e.refsPickerWidth(80); got != 20 {
+		t.Fatalf("default width = %d, want 20", got)
+	}
+
+	e.HandleKey(keyCtrl(tcell.KeyRight))
+	if got := e.refsPickerWidth(80); got != 22 {
+		t.Fatalf("width after ctrl+right = %d, want 22", got)
+	}
+	e.HandleKey(keyCtrl(tcell.KeyLeft))
+	e.HandleKey(keyCtrl(tcell.KeyLeft))
+	if got := e.refsPickerWidth(80); got != 18 {
+		t.Fatalf("width after ctrl+left = %d, want 18", got)
+	}
+
+	// The preview always keeps 20 columns
+	e.setPickerWidth(pickerReferences, 100)
+	if got := e.refsPickerWidth(80); got != 60 {
+		t.Fatalf("clamped width = %d, want 60", got)
+	}
+}
+
+func TestRefsPickerDragSeparator(t *testing.T) {
+	e := newTestEditor("a", "b")
+	e.DisableState()
+	e.viewWidth = 80
+	e.showRefsPicker("References", []LSPLocation{{Path: "a.go"}})
+
+	e.HandleMouse(tcell.NewEventMouse(19, 0, tcell.Button1, tcell.ModNone))
+	e.HandleMouse(tcell.NewEventMouse(29, 3, tcell.Button1, tcell.ModNone))
+	e.HandleMouse(tcell.NewEventMouse(29, 3, tcell.ButtonNone, tcell.ModNone))
+	if got := e.refsPickerWidth(80); got != 30 {
+		t.Fatalf("width after drag = %d, want 30", got)
+	}
+	if e.cursor != (Cursor{}) {
+		t.Fatalf("drag moved the cursor to %v", e.cursor)
+	}
+
+	// A click away from the separator doesn't start a drag
+	e.HandleMouse(tcell.NewEventMouse(40, 1, tcell.Button1, tcell.ModNone))
+	e.HandleMouse(tcell.NewEventMouse(50, 1, tcell.Button1, tcell.ModNone))
+	if got := e.refsPickerWidth(80); got != 30 {
+		t.Fatalf("width after click = %d, want 30", got)
+	}
+}
+
+func TestBranchPickerResizeKeys(t *testing.T) {
+	e := newTestEditor("a")
+	e.DisableState()
+	e.viewWidth = 80
+	e.ShowBranchPicker([]string{"main", "feature"}, "main")
+	def := e.branchPickerBoxWidth(80)
+
+	e.HandleKey(keyCtrl(tcell.KeyRight))
+	if got := e.branchPickerBoxWidth(80); got != def+2 {
+		t.Fatalf("box width = %d, want %d", got, def+2)
+	}
+	if !e.popupOpen(branchPickerPopup{}) {
+		t.Fatalf("resizing closed the picker")
+	}
+	e.setPickerWidth(pickerBranches, 200)
+	if got := e.branchPickerBoxWidth(80); got != 78 {
+		t.Fatalf("clamped box width = %d, want 78", got)
+	}
+}
+
+func TestPickerGeometryPersists(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	e := New(config.Default())
+	e.setPickerWidth(pickerReferences, 33)
+	e.Shutdown()
+
+	e = New(config.Default())
+	defer e.DisableState()
+	if got := e.pickerWidth(pickerReferences); got != 33 {
+		t.Fatalf("saved width = %d, want 33", got)
+	}
+	if got := e.pickerWidth(pickerBranches); got != 0 {
+		t.Fatalf("branches width = %d, want 0", got)
+	}
+}
@@ -0,0 +1,233 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kobzarvs/qedit/pkg/plugin"
+)
+
+// Plugins are programs of their own (see pkg/plugin). They add statusline
+// segments and gutter signs, and the editor draws them.
+
+// StartPlugins runs the plugins of the [plugins] config table, name ->
+// command. A plugin that can't be started is reported in the status line.
+func (e *Editor) StartPlugins(plugins map[string][]string) {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h, err := plugin.Start(name, plugins[name], e.wake)
+		if err != nil {
+			e.setStatus(fmt.Sprintf("plugin %s: %v", name, err))
+			continue
+		}
+		e.plugins = append(e.plugins, h)
+		if e.filename != "" {
+			e.notifyPlugin(h, plugin.EventOpen, e.filename)
+		}
+	}
+}
+
+// PollPlugins applies what the plugins sent since the last call and
+// reports whether the screen needs a redraw
+func (e *Editor) PollPlugins() bool {
+	changed := false
+	running := e.plugins[:0]
+	for _, h := range e.plugins {
+		msgs, exited, err := h.Poll()
+		for _, m := range msgs {
+			e.applyPluginMessage(h.Name(), m)
+			changed = true
+		}
+		if exited {
+			if err != nil {
+				e.setStatus(fmt.Sprintf("plugin %s: %v", h.Name(), err))
+				changed = true
+			}
+			continue
+		}
+		running = append(running, h)
+	}
+	e.plugins = running
+	return changed
+}
+
+// applyPluginMessage shows a message of the plugin name. Its segments and
+// signs are named after it so they don't clash with other plugins'.
+func (e *Editor) applyPluginMessage(name string, m plugin.Message) {
+	if seg := m.Segment; seg != nil {
+		key := name
+		if seg.Name != "" {
+			key += "/" + seg.Name
+		}
+		if seg.Text == "" {
+			e.RemoveStatusSegment(key)
+		} else {
+			text := seg.Text
+			e.AddStatusSegment(key, parseColor(seg.Color, tcell.ColorDefault), func() string { return text })
+		}
+	}
+	if m.Signs != nil && m.Signs.File != "" {
+		var signs []GutterSign
+		for _, s := range m.Signs.Signs {
+			text := []rune(s.Text)
+			if s.Line < 1 || len(text) == 0 {
+				continue
+			}
+			signs = append(signs, GutterSign{
+				Row:      s.Line - 1,
+				Text:     text[0],
+				Color:    parseColor(s.Color, tcell.ColorDefault),
+				Priority: s.Priority,
+			})
+		}
+		e.SetGutterSigns(name, m.Signs.File, signs)
+	}
+}
+
+// notifyPlugins tells the plugins that path was opened or saved
+func (e *Editor) notifyPlugins(event, path string) {
+	for _, h := range e.plugins {
+		e.notifyPlugin(h, event, path)
+	}
+}
+
+func (e *Editor) notifyPlugin(h *plugin.Host, event, path string) {
+	_ = h.Notify(plugin.Event{Event: event, File: bufferKey(path)})
+}
+
+// stopPlugins stops the plugins on exit
+func (e *Editor) stopPlugins() {
+	for _, h := range e.plugins {
+		h.Close()
+	}
+	e.plugins = nil
+}
+
+// StatusSegmentFunc returns the text of a statusline segment. It runs on
+// every redraw, so it should be quick; "" hides the segment.
+type StatusSegmentFunc func() string
+
+// statusSegment is a statusline segment added by a plugin
+type statusSegment struct {
+	name  string
+	color tcell.Color
+	fn    StatusSegmentFunc
+}
+
+// AddStatusSegment adds a segment named name to the right of the
+// statusline, before the git branch, drawn in color on the statusline's
+// background (tcell.ColorDefault keeps the statusline's color). Adding a
+// name again replaces its segment in place.
+func (e *Editor) AddStatusSegment(name string, color tcell.Color, fn StatusSegmentFunc) {
+	seg := statusSegment{name: name, color: color, fn: fn}
+	for i := range e.statusSegments {
+		if e.statusSegments[i].name == name {
+			e.statusSegments[i] = seg
+			return
+		}
+	}
+	e.statusSegments = append(e.statusSegments, seg)
+}
+
+// RemoveStatusSegment removes the segment named name
+func (e *Editor) RemoveStatusSegment(name string) {
+	for i := range e.statusSegments {
+		if e.statusSegments[i].name == name {
+			e.statusSegments = append(e.statusSegments[:i], e.statusSegments[i+1:]...)
+			return
+		}
+	}
+}
+
+// statusSegmentText is a segment's text for one redraw
+type statusSegmentText struct {
+	text  string
+	style tcell.Style
+}
+
+// statusSegmentTexts returns the plugin segments to show
+func (e *Editor) statusSegmentTexts() []statusSegmentText {
+	var texts []statusSegmentText
+	for _, seg := range e.statusSegments {
+		text := seg.fn()
+		if text == "" {
+			continue
+		}
+		style := e.styleStatus
+		if seg.color != tcell.ColorDefault {
+			style = style.Foreground(seg.color)
+		}
+		texts = append(texts, statusSegmentText{text: text, style: style})
+	}
+	return texts
+}
+
+// GutterSign marks a line in the leading column of the gutter
+type GutterSign struct {
+	Row      int
+	Text     rune
+	Color    tcell.Color
+	Priority int // the highest one shows when signs share a line
+}
+
+// SetGutterSigns replaces the signs of group in the file at path; nil
+// removes them. Signs stay on their rows: a plugin that tracks edits
+// moves them itself. Validation problem markers win over any sign.
+func (e *Editor) SetGutterSigns(group, path string, signs []GutterSign) {
+	key := bufferKey(path)
+	groups := e.gutterSigns[key]
+	if len(signs) == 0 {
+		delete(groups, group)
+		if len(groups) == 0 {
+			delete(e.gutterSigns, key)
+		}
+	} else {
+		if groups == nil {
+			if e.gutterSigns == nil {
+				e.gutterSigns = map[string]map[string][]GutterSign{}
+			}
+			groups = map[string][]GutterSign{}
+			e.gutterSigns[key] = groups
+		}
+		groups[group] = append([]GutterSign(nil), signs...)
+	}
+	e.signRows, e.signRowsFile = nil, ""
+}
+
+// gutterSignAt returns the sign to show on row of the current buffer
+func (e *Editor) gutterSignAt(row int) (GutterSign, bool) {
+	if e.filename == "" || len(e.gutterSigns) == 0 {
+		return GutterSign{}, false
+	}
+	if e.signRows == nil || e.signRowsFile != e.filename {
+		e.signRows, e.signRowsFile = e.collectSigns(), e.filename
+	}
+	sign, ok := e.signRows[row]
+	return sign, ok
+}
+
+// collectSigns returns the winning sign of each row of the current buffer.
+// Groups are visited by name so equal priorities resolve the same way on
+// every redraw.
+func (e *Editor) collectSigns() map[int]GutterSign {
+	groups := e.gutterSigns[bufferKey(e.filename)]
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := map[int]GutterSign{}
+	for _, name := range names {
+		for _, sign := range groups[name] {
+			if cur, ok := rows[sign.Row]; !ok || sign.Priority > cur.Priority {
+				rows[sign.Row] = sign
+			}
+		}
+	}
+	return rows
+}
@@ -0,0 +1,94 @@
+package editor
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestStatusSegments(t *testing.T) {
+	e := newTestEditor("x")
+	e.gitBranch = "main"
+	ci := "CI ok"
+	e.AddStatusSegment("clock", tcell.ColorDefault, func() string { return "12:00" })
+	e.AddStatusSegment("ci", tcell.ColorGreen, func() string { return ci })
+
+	s, rows := renderRows(t, e, 60, 5)
+	y := len(rows) - 2
+	if !strings.Contains(rows[y], "Ln 1, Col 1 | 12:00 | CI ok | git:main") {
+		t.Fatalf("statusline %q", rows[y])
+	}
+	x := len([]rune(rows[y][:strings.Index(rows[y], "CI ok")]))
+	_, _, style, _ := s.GetContent(x, y)
+	if fg, _, _ := style.Decompose(); fg != tcell.ColorGreen {
+		t.Fatalf("segment drawn in %v", fg)
+	}
+	if _, _, style, _ := s.GetContent(x-2, y); style != e.styleStatus {
+		t.Fatal("separator not in the statusline style")
+	}
+
+	// An empty segment is hidden, a removed one is gone
+	ci = ""
+	e.RemoveStatusSegment("clock")
+	if _, rows := renderRows(t, e, 60, 5); strings.Contains(rows[y], "12:00") || strings.Contains(rows[y], "| |") {
+		t.Fatalf("statusline %q", rows[y])
+	}
+}
+
+func TestGutterSigns(t *testing.T) {
+	e := newTestEditor("a", "b", "c")
+	e.filename = "main.go"
+	e.SetGutterSigns("git", "main.go", []GutterSign{
+		{Row: 0, Text: '+', Color: tcell.ColorGreen},
+		{Row: 1, Text: '~', Color: tcell.ColorYellow},
+	})
+	e.SetGutterSigns("bookmarks", "main.go", []GutterSign{{Row: 1, Text: '*', Color: tcell.ColorBlue, Priority: 1}})
+	e.SetGutterSigns("git", "other.go", []GutterSign{{Row: 2, Text: '+'}})
+
+	s, rows := renderRows(t, e, 20, 5)
+	if rows[0][0] != '+' || rows[1][0] != '*' || rows[2][0] != ' ' {
+		t.Fatalf("gutter %q %q %q", rows[0], rows[1], rows[2])
+	}
+	_, _, style, _ := s.GetContent(0, 0)
+	if fg, _, _ := style.Decompose(); fg != tcell.ColorGreen {
+		t.Fatalf("sign drawn in %v", fg)
+	}
+
+	e.SetGutterSigns("bookmarks", "main.go", nil)
+	if _, rows := renderRows(t, e, 20, 5); rows[1][0] != '~' {
+		t.Fatalf("after clearing: %q", rows[1])
+	}
+}
+
+func TestPluginProgram(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// Marks line 2 of each file it is told about and shows a segment
+	script := `while read ev; do
+		f=$(echo "$ev" | sed 's/.*"file":"\([^"]*\)".*/\1/')
+		echo '{"segment":{"text":"lint ok","color":"green"}}'
+		echo '{"signs":{"file":"'"$f"'","signs":[{"line":2,"text":"!","color":"red"}]}}'
+	done`
+	e := newTestEditor("a", "b", "c")
+	e.filename = filepath.Join(t.TempDir(), "main.go")
+	e.StartPlugins(map[string][]string{"lint": {"sh", "-c", script}})
+	defer e.stopPlugins()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for e.gutterSigns[e.filename] == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("no signs from the plugin")
+		}
+		time.Sleep(10 * time.Millisecond)
+		e.PollPlugins()
+	}
+	_, rows := renderRows(t, e, 40, 5)
+	if rows[1][0] != '!' || !strings.Contains(rows[len(rows)-2], "lint ok") {
+		t.Fatalf("gutter %q, statusline %q", rows[1], rows[len(rows)-2])
+	}
+}
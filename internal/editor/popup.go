@@ -0,0 +1,160 @@
+package editor
+
+import "github.com/gdamore/tcell/v2"
+
+// popup is an overlay on top of the editor. Open popups form a stack: the
+// top one gets keys before the mode handlers, Esc always closes it, and
+// they are drawn bottom to top so the focused one is in front.
+type popup interface {
+	// handleKey handles a key other than Esc and reports whether it did
+	handleKey(e *Editor, ev *tcell.EventKey) bool
+	// render draws the popup over the editor
+	render(e *Editor, s tcell.Screen, w, viewHeight int)
+	// dismissed resets the popup's state after it leaves the stack
+	dismissed(e *Editor)
+	// modal popups swallow keys they don't handle and hide the text cursor;
+	// keys fall through a non-modal popup to the editor
+	modal() bool
+}
+
+// openPopup puts p on top of the stack, moving it there if already open
+func (e *Editor) openPopup(p popup) {
+	e.removePopup(p)
+	e.popups = append(e.popups, p)
+}
+
+// closePopup removes p from the stack and resets its state
+func (e *Editor) closePopup(p popup) {
+	if e.removePopup(p) {
+		p.dismissed(e)
+	}
+}
+
+func (e *Editor) removePopup(p popup) bool {
+	for i, open := range e.popups {
+		if open == p {
+			e.popups = append(e.popups[:i], e.popups[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// popupOpen reports whether p is on the stack
+func (e *Editor) popupOpen(p popup) bool {
+	for _, open := range e.popups {
+		if open == p {
+			return true
+		}
+	}
+	return false
+}
+
+// topPopup returns the focused popup, or nil
+func (e *Editor) topPopup() popup {
+	if len(e.popups) == 0 {
+		return nil
+	}
+	return e.popups[len(e.popups)-1]
+}
+
+// handlePopupKey routes ev to the top popup and reports whether the key was
+// used up. Esc closes the top popup.
+func (e *Editor) handlePopupKey(ev *tcell.EventKey) bool {
+	p := e.topPopup()
+	if p == nil {
+		return false
+	}
+	// A non-modal popup only sees keys in normal mode with no sequence
+	// pending, so it never takes typed text or the key after g
+	if !p.modal() && (e.mode != ModeNormal || e.sequence.kind != seqNone) {
+		return false
+	}
+	if ev.Key() == tcell.KeyEscape {
+		e.closePopup(p)
+		return true
+	}
+	if p.handleKey(e, ev) {
+		return true
+	}
+	return p.modal()
+}
+
+// renderPopups draws the open popups, topmost last
+func (e *Editor) renderPopups(s tcell.Screen, w, viewHeight int) {
+	for _, p := range e.popups {
+		p.render(e, s, w, viewHeight)
+	}
+}
+
+// modalPopupOpen reports whether a modal popup is open
+func (e *Editor) modalPopupOpen() bool {
+	for _, p := range e.popups {
+		if p.modal() {
+			return true
+		}
+	}
+	return false
+}
+
+// branchPickerPopup is the git branch list (ModeBranchPicker)
+type branchPickerPopup struct{}
+
+func (branchPickerPopup) handleKey(e *Editor, ev *tcell.EventKey) bool {
+	e.handleBranchPicker(ev)
+	return true
+}
+
+func (branchPickerPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	e.renderBranchPicker(s, w, viewHeight)
+}
+
+func (branchPickerPopup) dismissed(e *Editor) {
+	e.branchPickerItems = nil
+	e.branchPickerIndex = 0
+	e.mode = ModeNormal
+}
+
+func (branchPickerPopup) modal() bool { return true }
+
+// keybindingsHelpPopup is the filterable keybindings list (Space ?)
+type keybindingsHelpPopup struct{}
+
+func (keybindingsHelpPopup) handleKey(e *Editor, ev *tcell.EventKey) bool {
+	e.handleKeybindingsHelp(ev)
+	return true
+}
+
+func (keybindingsHelpPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	e.renderKeybindingsHelp(s, w, viewHeight)
+}
+
+func (keybindingsHelpPopup) dismissed(e *Editor) {
+	e.keybindingsHelpFilterKey = nil
+	e.keybindingsHelpFilterAct = nil
+	e.keybindingsHelpFilterDesc = nil
+	e.keybindingsHelpScroll = 0
+	e.keybindingsHelpFilterFocus = 0
+}
+
+func (keybindingsHelpPopup) modal() bool { return true }
+
+// refsPickerPopup is the LSP references list. It is laid out as a sidebar
+// by Render, so it draws nothing here, and keys it doesn't use still move
+// the editor.
+type refsPickerPopup struct{}
+
+func (refsPickerPopup) handleKey(e *Editor, ev *tcell.EventKey) bool {
+	return e.handleRefsPicker(ev)
+}
+
+func (refsPickerPopup) render(*Editor, tcell.Screen, int, int) {}
+
+func (refsPickerPopup) dismissed(e *Editor) {
+	e.refsPickerItems = nil
+	e.refsPickerIndex = 0
+	e.refsPickerFileCache = nil
+	e.refsPickerHighlights = nil
+}
+
+func (refsPickerPopup) modal() bool { return false }
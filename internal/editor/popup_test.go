@@ -0,0 +1,103 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPopupStackOrder(t *testing.T) {
+	e := newTestEditor("one")
+	e.openPopup(refsPickerPopup{})
+	e.openPopup(keybindingsHelpPopup{})
+	if e.topPopup() != (keybindingsHelpPopup{}) {
+		t.Fatalf("top = %T, want help", e.topPopup())
+	}
+	// Reopening moves a popup to the top instead of stacking it twice
+	e.openPopup(refsPickerPopup{})
+	if len(e.popups) != 2 || e.topPopup() != (refsPickerPopup{}) {
+		t.Fatalf("popups = %v, want help below refs", e.popups)
+	}
+	e.closePopup(keybindingsHelpPopup{})
+	if e.popupOpen(keybindingsHelpPopup{}) || !e.popupOpen(refsPickerPopup{}) {
+		t.Fatalf("popups = %v after closing help", e.popups)
+	}
+}
+
+func TestPopupEscClosesTopOnly(t *testing.T) {
+	e := newTestEditor("one", "two")
+	e.showRefsPicker("References", []LSPLocation{{Path: "a.go"}, {Path: "b.go"}})
+	e.openKeybindingsHelp()
+	e.keybindingsHelpFilterKey = []rune("x")
+
+	e.HandleKey(keyEsc())
+	if e.popupOpen(keybindingsHelpPopup{}) {
+		t.Fatalf("help still open after Esc")
+	}
+	if e.keybindingsHelpFilterKey != nil {
+		t.Fatalf("help filter not reset on close")
+	}
+	if !e.popupOpen(refsPickerPopup{}) {
+		t.Fatalf("Esc closed the popup below the top one")
+	}
+
+	e.HandleKey(keyEsc())
+	if len(e.popups) != 0 || e.refsPickerItems != nil {
+		t.Fatalf("popups = %v items = %v after second Esc", e.popups, e.refsPickerItems)
+	}
+}
+
+func TestModalPopupSwallowsKeys(t *testing.T) {
+	e := newTestEditor("one", "two")
+	e.openKeybindingsHelp()
+	// Typed keys go into the help filter instead of moving the cursor
+	e.HandleKey(keyRune('j'))
+	if e.cursor.Row != 0 || string(e.keybindingsHelpFilterKey) != "j" {
+		t.Fatalf("cursor row = %d filter = %q", e.cursor.Row, string(e.keybindingsHelpFilterKey))
+	}
+	if !e.modalPopupOpen() {
+		t.Fatalf("help is not modal")
+	}
+}
+
+func TestNonModalPopupFallsThrough(t *testing.T) {
+	e := newTestEditor("one", "two", "three")
+	e.showRefsPicker("References", []LSPLocation{{Path: "other.go"}, {Path: "other.go", StartLine: 2}})
+
+	// j is the picker's, l is not and moves the cursor
+	e.HandleKey(keyRune('j'))
+	if e.refsPickerIndex != 1 {
+		t.Fatalf("refs index = %d, want 1", e.refsPickerIndex)
+	}
+	e.HandleKey(keyRune('l'))
+	if e.cursor.Col != 1 {
+		t.Fatalf("cursor col = %d, want 1", e.cursor.Col)
+	}
+
+	// In insert mode typed text and Esc belong to the buffer
+	e.HandleKey(keyRune('i'))
+	e.HandleKey(keyRune('k'))
+	e.HandleKey(keyEsc())
+	if got := string(e.lines[0]); got != "okne" {
+		t.Fatalf("line = %q, want %q", got, "okne")
+	}
+	if e.mode != ModeNormal || !e.popupOpen(refsPickerPopup{}) {
+		t.Fatalf("mode = %v refs open = %v", e.mode, e.popupOpen(refsPickerPopup{}))
+	}
+}
+
+func TestBranchPickerPopup(t *testing.T) {
+	e := newTestEditor("one")
+	e.ShowBranchPicker([]string{"main", "dev"}, "main")
+	if e.mode != ModeBranchPicker || e.topPopup() != (branchPickerPopup{}) {
+		t.Fatalf("mode = %v top = %T", e.mode, e.topPopup())
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyDown, 0, 0))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if got, ok := e.ConsumeBranchSelection(); !ok || got != "dev" {
+		t.Fatalf("selection = %q, %v", got, ok)
+	}
+	if e.mode != ModeNormal || len(e.popups) != 0 {
+		t.Fatalf("mode = %v popups = %v after selecting", e.mode, e.popups)
+	}
+}
@@ -0,0 +1,154 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxOpenFileBytes is the largest file loaded into the buffer; bigger files get a preview
+	maxOpenFileBytes = 32 << 20
+	// binarySniffBytes is how much of a file is inspected to detect binary content
+	binarySniffBytes = 8000
+	// previewDumpBytes is how many leading bytes the preview shows as hex
+	previewDumpBytes = 256
+)
+
+// filePreview returns a read-only summary (metadata and hex dump of the first
+// bytes) for binary or huge files, and the reason. Text files return no lines.
+func filePreview(path string) ([][]rune, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	sample := make([]byte, binarySniffBytes)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, "", err
+	}
+	sample = sample[:n]
+
+	reason := ""
+	switch {
+	case isBinary(sample):
+		reason = "binary file"
+	case info.Size() > maxOpenFileBytes:
+		reason = "file too large"
+	default:
+		return nil, "", nil
+	}
+
+	contentType := http.DetectContentType(sample)
+	summary := []string{
+		path,
+		"",
+		fmt.Sprintf("%-9s %s (%d bytes)", "size:", formatSize(info.Size()), info.Size()),
+		fmt.Sprintf("%-9s %s", "type:", contentType),
+		fmt.Sprintf("%-9s %s", "modified:", info.ModTime().Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("%-9s %s", "mode:", info.Mode()),
+		"",
+		fmt.Sprintf("%s - not loaded. First %d bytes:", reason, min(len(sample), previewDumpBytes)),
+		"",
+	}
+	summary = append(summary, hexDump(sample[:min(len(sample), previewDumpBytes)])...)
+
+	lines := make([][]rune, len(summary))
+	for i, line := range summary {
+		lines[i] = []rune(line)
+	}
+	return lines, reason, nil
+}
+
+// maxControlRatio is the share of control bytes above which a sample is
+// taken for binary data
+const maxControlRatio = 0.1
+
+// isBinary reports whether sample looks like binary data: it contains NUL
+// bytes, or more than maxControlRatio of its bytes are control characters
+// text doesn't use. Text in another encoding than UTF-8 is still text.
+func isBinary(sample []byte) bool {
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	control := 0
+	for _, b := range sample {
+		switch {
+		case b == '\t' || b == '\n' || b == '\r' || b == '\f' || b == '\v' || b == 0x1b:
+			// whitespace, and escapes of ANSI-colored logs
+		case b < 0x20 || b == 0x7f:
+			control++
+		}
+	}
+	return float64(control) > maxControlRatio*float64(len(sample))
+}
+
+// decodeText returns data as a string, reading the bytes that aren't valid
+// UTF-8 as Latin-1, and whether there were any
+func decodeText(data []byte) (string, bool) {
+	if utf8.Valid(data) {
+		return string(data), false
+	}
+	var sb strings.Builder
+	sb.Grow(len(data) + len(data)/8)
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			r = rune(data[0])
+		}
+		sb.WriteRune(r)
+		data = data[size:]
+	}
+	return sb.String(), true
+}
+
+// hexDump formats data like `hexdump -C`: offset, 16 hex bytes, ASCII column
+func hexDump(data []byte) []string {
+	var lines []string
+	for off := 0; off < len(data); off += 16 {
+		row := data[off:min(off+16, len(data))]
+		var hex, ascii strings.Builder
+		for i := 0; i < 16; i++ {
+			if i == 8 {
+				hex.WriteByte(' ')
+			}
+			if i < len(row) {
+				fmt.Fprintf(&hex, "%02x ", row[i])
+			} else {
+				hex.WriteString("   ")
+			}
+		}
+		for _, b := range row {
+			if b >= 0x20 && b < 0x7f {
+				ascii.WriteByte(b)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%08x  %s |%s|", off, hex.String(), ascii.String()))
+	}
+	return lines
+}
+
+// formatSize formats n bytes with a binary unit (KiB, MiB, ...)
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,104 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenFileBinaryShowsPreview(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "image.png")
+	data := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), make([]byte, 300)...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	e := newTestEditor("")
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if !e.IsPreview() {
+		t.Fatalf("binary file should open as preview")
+	}
+	content := e.Content()
+	for _, want := range []string{"size:     316 B (316 bytes)", "type:     image/png", "binary file - not loaded. First 256 bytes:",
+		"00000000  89 50 4e 47 0d 0a 1a 0a  00 00 00 0d 49 48 44 52  |.PNG........IHDR|"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("preview missing %q:\n%s", want, content)
+		}
+	}
+	if e.statusMessage != "binary file: read-only preview" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	if err := e.Save(""); err == nil {
+		t.Fatalf("Save of preview should fail")
+	}
+	if got, _ := os.ReadFile(path); len(got) != len(data) {
+		t.Fatalf("binary file was modified")
+	}
+
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("héllo\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := e.OpenFile(text); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if e.IsPreview() || e.Content() != "héllo\n" {
+		t.Fatalf("text file should load normally, preview=%v content=%q", e.IsPreview(), e.Content())
+	}
+
+	// Text in a legacy encoding is text too
+	if err := os.WriteFile(text, []byte("caf\xe9 \xe4\xb8\xad\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := e.OpenFile(text); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if e.IsPreview() || e.Content() != "café 中\n" || !strings.Contains(e.statusMessage, "Latin-1") {
+		t.Fatalf("latin-1 file: preview=%v content=%q status=%q", e.IsPreview(), e.Content(), e.statusMessage)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want bool
+	}{
+		{[]byte("plain text\n"), false},
+		{[]byte("utf-8 中文 😀"), false},
+		{[]byte("cut in the middle \xe4\xb8"), false},
+		{[]byte("nul\x00byte"), true},
+		{[]byte("latin-1 \xe9t\xe9"), false},
+		{[]byte("ansi \x1b[31mred\x1b[0m\r\n"), false},
+		{[]byte("\x01\x02\x03 header \x04\x05"), true},
+	}
+	for _, tt := range tests {
+		if got := isBinary(tt.data); got != tt.want {
+			t.Fatalf("isBinary(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestGotoFileUnderCursor(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "docs", "guide.md"), "# Guide\n")
+	main := filepath.Join(dir, "main.go")
+	writeTestFile(t, main, "")
+
+	e := newTestEditor("see docs/guide.md:12 for details")
+	e.filename = main
+	e.cursor = Cursor{Row: 0, Col: 8}
+	e.handleGotoKey('f')
+	if got := e.ConsumeOpenFileRequest(); got != filepath.Join(dir, "docs", "guide.md") {
+		t.Fatalf("open request = %q", got)
+	}
+
+	e.cursor = Cursor{Row: 0, Col: 0}
+	e.handleGotoKey('f')
+	if e.ConsumeOpenFileRequest() != "" || e.statusMessage != "file not found: see" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
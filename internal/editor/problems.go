@@ -0,0 +1,114 @@
+package editor
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/validate"
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// validateSaved checks TOML/YAML files after a save. Problems stay until the
+// next save or until another file is opened.
+func (e *Editor) validateSaved(data []byte) {
+	e.problems = validate.File(e.filename, data)
+	if e.sidebarProblems != nil {
+		e.sidebarProblems.SetProblems(e.problems)
+	}
+}
+
+// clearProblems drops the problems of the previous buffer
+func (e *Editor) clearProblems() {
+	e.problems = nil
+	if e.sidebarProblems != nil {
+		e.sidebarProblems.SetProblems(nil)
+	}
+}
+
+// problemsStatus appends the validation summary to a save message
+func (e *Editor) problemsStatus(msg string) string {
+	switch len(e.problems) {
+	case 0:
+		return msg
+	case 1:
+		p := e.problems[0]
+		return fmt.Sprintf("%s, 1 problem: line %d: %s", msg, p.Line, p.Message)
+	default:
+		p := e.problems[0]
+		return fmt.Sprintf("%s, %d problems (Space d): line %d: %s", msg, len(e.problems), p.Line, p.Message)
+	}
+}
+
+// hasProblemAt reports whether row has a validation problem
+func (e *Editor) hasProblemAt(row int) bool {
+	_, ok := e.problemAt(row)
+	return ok
+}
+
+// problemAt returns the first validation problem on row, for the gutter
+// marker and the inline diagnostic
+func (e *Editor) problemAt(row int) (validate.Problem, bool) {
+	for _, p := range e.problems {
+		if p.Line-1 == row {
+			return p, true
+		}
+	}
+	return validate.Problem{}, false
+}
+
+// problemsAt returns all validation problems on row
+func (e *Editor) problemsAt(row int) []validate.Problem {
+	var found []validate.Problem
+	for _, p := range e.problems {
+		if p.Line-1 == row {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// diagnosticStyle returns the theme style of a problem's severity
+func (e *Editor) diagnosticStyle(sev validate.Severity) tcell.Style {
+	switch sev {
+	case validate.SeverityWarning:
+		return e.styleDiagnosticWarning
+	case validate.SeverityInfo:
+		return e.styleDiagnosticInfo
+	case validate.SeverityHint:
+		return e.styleDiagnosticHint
+	}
+	return e.styleDiagnosticError
+}
+
+// openSidebarProblems shows the location list of validation problems
+func (e *Editor) openSidebarProblems() {
+	if e.sidebar == nil {
+		return
+	}
+	if len(e.problems) == 0 {
+		e.setStatus("no problems")
+		return
+	}
+	if e.sidebar.MenuContent == nil {
+		e.sidebar.MenuContent = NewSidebarMenuContent(e.isGitRepo())
+	}
+	if e.sidebarProblems == nil {
+		e.sidebarProblems = NewSidebarProblemsContent(e.problems)
+	}
+	e.sidebar.SetContent(e.sidebarProblems)
+	e.sidebar.Visible = true
+	e.sidebar.Focused = true
+}
+
+// gotoProblem moves the cursor to a 0-based line and byte column
+func (e *Editor) gotoProblem(line, col int) {
+	if len(e.lines) == 0 {
+		return
+	}
+	e.clearSelection()
+	e.cursor.Row = clampRange(line, 0, len(e.lines)-1)
+	e.cursor.Col = textpos.ByteToRune(e.lines[e.cursor.Row], col)
+	e.clampCursorCol()
+	e.ensureCursorVisible(e.viewHeightCached())
+}
@@ -0,0 +1,68 @@
+package editor
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestSaveValidatesYAMLAndJumpsToProblem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.yml")
+	e := newTestEditor("name: ci", "jobs:", "  build: 1", "  build: 2", "")
+	e.filename = path
+
+	e.execCommand("w")
+	if len(e.problems) != 1 {
+		t.Fatalf("problems = %v, want 1", e.problems)
+	}
+	want := `written, 1 problem: line 4: duplicate key "build" (first defined on line 3)`
+	if e.statusMessage != want {
+		t.Fatalf("status = %q, want %q", e.statusMessage, want)
+	}
+	if !e.hasProblemAt(3) || e.hasProblemAt(2) {
+		t.Fatalf("gutter marker should be on line 4 only")
+	}
+
+	e.executeSpaceAction(SpaceMenuItem{Key: 'd', Action: "diagnostic_picker", Implemented: true})
+	if !e.sidebar.Visible || e.sidebar.Content != e.sidebarProblems {
+		t.Fatalf("Space d should open the problems list")
+	}
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if e.cursor != (Cursor{Row: 3, Col: 2}) {
+		t.Fatalf("cursor = %+v, want {3 2}", e.cursor)
+	}
+	if e.sidebar.Focused {
+		t.Fatalf("focus should return to the editor")
+	}
+
+	e.lines[3] = []rune("  test: 2")
+	e.execCommand("w")
+	if len(e.problems) != 0 || e.statusMessage != "written" {
+		t.Fatalf("after fix: problems = %v, status = %q", e.problems, e.statusMessage)
+	}
+	if len(e.sidebarProblems.Items()) != 0 {
+		t.Fatalf("problems list should be cleared")
+	}
+}
+
+func TestSaveValidatesTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	e := newTestEditor("a = 1", "a = 2", "")
+	e.filename = path
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if len(e.problems) != 1 || e.problems[0].Line != 2 {
+		t.Fatalf("problems = %v, want duplicate key on line 2", e.problems)
+	}
+
+	e.filename = filepath.Join(t.TempDir(), "notes.txt")
+	e.executeSpaceAction(SpaceMenuItem{Key: 'd', Action: "diagnostic_picker", Implemented: true})
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if e.problems != nil {
+		t.Fatalf("problems for txt = %v, want none", e.problems)
+	}
+}
@@ -0,0 +1,187 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// A file the user can't write opens read-only ([RO]): edits are refused
+// with a hint until :readonly off, and :wsudo writes it as root through
+// sudo tee once confirmed. --readonly is separate and stays on for the
+// whole session.
+
+// fileWritable reports whether the user can write path. It is opened for
+// writing without truncating, so nothing changes; a file that doesn't
+// exist or isn't a regular file counts as writable.
+func fileWritable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return true
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return !errors.Is(err, fs.ErrPermission)
+	}
+	f.Close()
+	return true
+}
+
+// readOnlyHint tells why the buffer refuses edits and what to do about it
+func (e *Editor) readOnlyHint() string {
+	return filepath.Base(e.filename) + " isn't writable (:readonly off to edit, :wsudo saves with sudo)"
+}
+
+// refuseEdit reports whether the buffer can't be changed, and says why.
+// --readonly doesn't refuse edits, only writing the file.
+func (e *Editor) refuseEdit() bool {
+	if !e.fileReadOnly {
+		return false
+	}
+	e.setStatus(e.readOnlyHint())
+	return true
+}
+
+// editsText reports whether a changes the text or starts changing it
+func (a *actionDef) editsText() bool {
+	switch a.name {
+	case actionEnterInsert, actionAppend, actionAppendLineEnd, actionInsertLineStart, actionReplaceChar:
+		return true
+	}
+	return a.class == classEdit
+}
+
+// execReadOnlyCommand runs :readonly [on|off]
+func (e *Editor) execReadOnlyCommand(args []string) {
+	on := e.fileReadOnly
+	if !e.boolOption(args, &on, "readonly") {
+		return
+	}
+	e.fileReadOnly = on
+	switch {
+	case on:
+		e.setStatus("readonly on")
+	case !fileWritable(e.filename):
+		e.setStatus("readonly off (:wsudo saves with sudo)")
+	default:
+		e.setStatus("readonly off")
+	}
+}
+
+// execWsudoCommand asks whether to write the open file as root (:wsudo).
+// It only writes the open file, never a path typed after it.
+func (e *Editor) execWsudoCommand(args []string) {
+	switch {
+	case len(args) > 0:
+		e.setStatus("usage: :wsudo (writes the open file)")
+	case e.filename == "":
+		e.setStatus("no file name")
+	case e.readOnly:
+		e.setStatus("read-only (write to another path with :w <path>)")
+	case e.preview:
+		e.setStatus("preview of binary or huge file can't be written")
+	default:
+		e.sudoTarget = resolvePath(e.filename)
+		e.openPopup(sudoConfirmPopup{})
+	}
+}
+
+// saveWithSudo writes the buffer to path, the open file, through sudo
+func (e *Editor) saveWithSudo(path string) {
+	text := joinLines(e.lines)
+	data := e.fileBytes(text)
+	if err := e.writePrivileged(path, data); err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	e.wroteFile(text, data)
+	e.setStatus(e.problemsStatus("written with sudo"))
+}
+
+// sudoConfirmPopup asks before :wsudo writes as root: y writes, any other
+// key cancels
+type sudoConfirmPopup struct{}
+
+func (sudoConfirmPopup) handleKey(e *Editor, ev *tcell.EventKey) bool {
+	path := e.sudoTarget
+	e.closePopup(sudoConfirmPopup{})
+	if ev.Key() == tcell.KeyRune && ev.Rune() == 'y' {
+		e.saveWithSudo(path)
+	} else {
+		e.setStatus("not written")
+	}
+	return true
+}
+
+func (sudoConfirmPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	if w < 6 {
+		return
+	}
+	text := []rune("write " + e.sudoTarget + " as root with sudo? (y/n)")
+	e.drawCursorFloat(s, w, viewHeight, [][]rune{truncateRunes(text, min(maxFloatWidth, w-4))}, []tcell.Style{e.styleAutoComplete})
+}
+
+func (sudoConfirmPopup) dismissed(e *Editor) {
+	e.sudoTarget = ""
+}
+
+func (sudoConfirmPopup) modal() bool { return true }
+
+// SetSuspend sets the function that runs fn with the terminal handed back
+// to the shell, so a command run by the editor can prompt on it
+func (e *Editor) SetSuspend(fn func(fn func() error) error) {
+	e.suspend = fn
+}
+
+// writePrivileged writes data to path with sudo. With the terminal
+// suspended sudo can ask for the password; without it sudo only runs
+// when it needn't ask.
+func (e *Editor) writePrivileged(path string, data []byte) error {
+	if e.privilegedWrite != nil {
+		return e.privilegedWrite(path, data)
+	}
+	if e.suspend == nil {
+		return sudoWrite(path, data, false)
+	}
+	return e.suspend(func() error { return sudoWrite(path, data, true) })
+}
+
+// sudoWrite writes data to path through sudo tee. The password prompt, if
+// any, goes to the terminal when interactive.
+func sudoWrite(path string, data []byte, interactive bool) error {
+	args := []string{"tee", "--", path}
+	if !interactive {
+		args = append([]string{"-n"}, args...)
+	}
+	cmd := exec.Command("sudo", args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = io.Discard
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if interactive {
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("sudo: %s", lastLine(msg))
+		}
+		return fmt.Errorf("sudo: %v", err)
+	}
+	return nil
+}
+
+// lastLine returns the last line of s
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
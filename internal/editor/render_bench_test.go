@@ -0,0 +1,147 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Baselines for the render path. Run with
+//
+//	go test ./internal/editor -run '^$' -bench . -benchmem
+
+// benchSource is a Go-looking line pattern so highlight spans and search
+// matches land on realistic columns
+var benchSource = []string{
+	"func handle%d(ctx context.Context, req *Request) (*Response, error) {",
+	"\tif err := validate(req); err != nil {",
+	"\t\treturn nil, fmt.Errorf(\"handle %d: %%w\", err)",
+	"\t}",
+	"\tresult := make([]string, 0, len(req.Items)) // preallocate for %d items",
+	"\tfor i, item := range req.Items {",
+	"\t\tresult = append(result, strings.TrimSpace(item.Name))",
+	"\t}",
+	"\treturn &Response{Items: result, Count: %d}, nil",
+	"}",
+	"",
+}
+
+func newBenchEditor(b *testing.B, lines int) *Editor {
+	b.Helper()
+	e := newTestEditor()
+	e.DisableState()
+	e.lines = make([][]rune, lines)
+	for i := range e.lines {
+		pattern := benchSource[i%len(benchSource)]
+		if strings.Contains(pattern, "%d") {
+			pattern = fmt.Sprintf(pattern, i)
+		}
+		e.lines[i] = []rune(pattern)
+	}
+	// Render the middle of the buffer, as when scrolled into a large file
+	e.cursor = Cursor{Row: lines / 2}
+	e.scroll = lines/2 - 10
+	return e
+}
+
+// benchHighlights returns tree-sitter-like spans for rows [start, end]
+func benchHighlights(e *Editor, start, end int) map[int][]HighlightSpan {
+	kinds := []string{"keyword", "function", "type", "string", "comment", "variable"}
+	spans := make(map[int][]HighlightSpan, end-start+1)
+	for row := start; row <= end && row < len(e.lines); row++ {
+		line := e.lines[row]
+		var lineSpans []HighlightSpan
+		for col, n := 0, 0; col < len(line); n++ {
+			width := 3 + n%5
+			lineSpans = append(lineSpans, HighlightSpan{StartCol: col, EndCol: min(col+width, len(line)), Kind: kinds[n%len(kinds)]})
+			col += width + 1
+		}
+		spans[row] = lineSpans
+	}
+	return spans
+}
+
+func newBenchScreen(b *testing.B) tcell.SimulationScreen {
+	b.Helper()
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		b.Fatalf("init screen: %v", err)
+	}
+	s.SetSize(160, 50)
+	b.Cleanup(s.Fini)
+	return s
+}
+
+func BenchmarkRender(b *testing.B) {
+	cases := []struct {
+		name      string
+		highlight bool
+		search    bool
+		selection bool
+	}{
+		{name: "plain"},
+		{name: "highlight", highlight: true},
+		{name: "search", search: true},
+		{name: "selection", selection: true},
+		{name: "all", highlight: true, search: true, selection: true},
+	}
+	for _, lines := range []int{10_000, 100_000} {
+		for _, tc := range cases {
+			b.Run(fmt.Sprintf("lines=%dk/%s", lines/1000, tc.name), func(b *testing.B) {
+				e := newBenchEditor(b, lines)
+				s := newBenchScreen(b)
+				if tc.highlight {
+					start, end := e.scroll, e.scroll+60
+					e.SetHighlights(start, end, benchHighlights(e, start, end))
+				}
+				if tc.search {
+					// A common word: matches on most lines of the buffer
+					e.searchQuery = []rune("item")
+					e.updateSearchMatches()
+				}
+				if tc.selection {
+					e.selectionActive = true
+					e.selectionStart = Cursor{Row: e.scroll + 5, Col: 3}
+					e.selectionEnd = Cursor{Row: e.scroll + 30, Col: 12}
+				}
+				e.Render(s)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					e.Render(s)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkRenderScroll renders while moving down one line per frame, so
+// per-frame caches keyed on the viewport are exercised
+func BenchmarkRenderScroll(b *testing.B) {
+	e := newBenchEditor(b, 100_000)
+	s := newBenchScreen(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.cursor.Row = (e.cursor.Row + 1) % len(e.lines)
+		e.Render(s)
+	}
+}
+
+// BenchmarkSearchMatches measures recomputing matches over the whole buffer,
+// which happens on every keystroke of an incremental search
+func BenchmarkSearchMatches(b *testing.B) {
+	for _, lines := range []int{10_000, 100_000} {
+		b.Run(fmt.Sprintf("lines=%dk", lines/1000), func(b *testing.B) {
+			e := newBenchEditor(b, lines)
+			e.searchQuery = []rune("result")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.updateSearchMatches()
+			}
+		})
+	}
+}
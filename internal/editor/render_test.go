@@ -1,6 +1,7 @@
 package editor
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/gdamore/tcell/v2"
@@ -138,3 +139,94 @@ func TestRenderSyntaxHighlightStyle(t *testing.T) {
 		t.Fatalf("highlight foreground not applied")
 	}
 }
+
+// screenRow returns row y of s with trailing spaces trimmed
+func screenRow(s tcell.SimulationScreen, y int) string {
+	cells, w, _ := s.GetContents()
+	row := make([]rune, w)
+	for x := 0; x < w; x++ {
+		row[x] = ' '
+		if r := cells[y*w+x].Runes; len(r) > 0 {
+			row[x] = r[0]
+		}
+	}
+	return strings.TrimRight(string(row), " ")
+}
+
+func TestRenderBufferline(t *testing.T) {
+	e := testBuffers()
+	e.bufferline = true
+	e.buffers[0].pinned = true
+	e.dirty = true
+
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(10, 3)
+
+	// Tabs before the current one are dropped until it fits
+	e.renderBufferline(s, 10)
+	s.Show()
+	if got := screenRow(s, 0); got != " b  c ●  d" {
+		t.Fatalf("row 0 = %q", got)
+	}
+	e.clickBufferline(9)
+	if e.openFileRequest != "" {
+		t.Fatalf("click switched away from unsaved buffer to %q", e.openFileRequest)
+	}
+
+	e.dirty = false
+	s.SetSize(40, 3)
+	e.renderBufferline(s, 40)
+	s.Show()
+	if got := screenRow(s, 0); got != " ⚑ a  b  c  d" {
+		t.Fatalf("row 0 = %q", got)
+	}
+	e.clickBufferline(6)
+	if want := bufferKey("b"); e.openFileRequest != want {
+		t.Fatalf("click request = %q, want %q", e.openFileRequest, want)
+	}
+}
+
+func TestRenderWithBufferline(t *testing.T) {
+	cfg := config.Default()
+	cfg.Editor.LineNumbers = "off"
+	cfg.Editor.Bufferline = true
+	e := New(cfg)
+	e.lines = [][]rune{[]rune("abc"), []rune("def")}
+	e.filename = "a.txt"
+	e.trackBuffer()
+	e.cursor = Cursor{Row: 1, Col: 1}
+
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatalf("init screen: %v", err)
+	}
+	defer s.Fini()
+	s.SetSize(20, 6)
+
+	e.Render(s)
+	if got := screenRow(s, 0); got != " a.txt" {
+		t.Fatalf("row 0 = %q", got)
+	}
+	if got := screenRow(s, 1); got != "abc" {
+		t.Fatalf("row 1 = %q", got)
+	}
+	if x, y, _ := s.GetCursor(); x != 1 || y != 2 {
+		t.Fatalf("cursor = %d,%d, want 1,2", x, y)
+	}
+
+	// Clicks below the bufferline land on the text under the pointer
+	e.HandleMouse(tcell.NewEventMouse(2, 1, tcell.Button1, tcell.ModNone))
+	if e.cursor != (Cursor{Row: 0, Col: 2}) {
+		t.Fatalf("cursor after click = %v", e.cursor)
+	}
+
+	e.execCommand("bufferline off")
+	e.Render(s)
+	if got := screenRow(s, 0); got != "abc" {
+		t.Fatalf("row 0 without bufferline = %q", got)
+	}
+}
@@ -0,0 +1,106 @@
+package editor
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/kobzarvs/qedit/internal/session"
+)
+
+// Resuming reopens the buffers of the last session at the positions they
+// were left, with qedit --resume or :resume. The session file is kept up
+// to date while editing, so it works after the terminal was closed under
+// a running editor too. Each editor keeps its own buffer list there, so
+// the one resumed is that of an editor that isn't running anymore.
+
+// SyncSession writes the open file's position and the buffer list to the
+// session, which autosaves them. The app calls it now and then, so a run
+// that never gets to Shutdown still leaves them behind.
+func (e *Editor) SyncSession() {
+	if e.sessionManager == nil {
+		return
+	}
+	e.saveSessionState()
+	paths := make([]string, len(e.buffers))
+	for i, b := range e.buffers {
+		paths[i] = b.path
+	}
+	e.sessionManager.SetBuffers(paths)
+}
+
+// OfferResume shows how to resume the last session on an empty start
+// screen, when the previous run didn't exit cleanly
+func (e *Editor) OfferResume() {
+	if e.sessionManager == nil {
+		return
+	}
+	run, _ := e.sessionManager.LastRun()
+	if n := len(sessionBuffers(run)); n > 0 {
+		e.setStatus("Resume last session: :resume (" + strconv.Itoa(n) + " buffers)")
+	}
+}
+
+// ResumeSession adds the last session's buffers to the buffer list and
+// returns the file that was active, for the caller to open. OpenFile
+// restores its position from the session.
+func (e *Editor) ResumeSession() (string, error) {
+	var saved []string
+	var run session.Run
+	if e.sessionManager != nil {
+		run, _ = e.sessionManager.LastRun()
+		saved = sessionBuffers(run)
+	}
+	if len(saved) == 0 {
+		return "", errors.New("no session to resume")
+	}
+	e.sessionManager.TakeLastRun()
+	active := saved[len(saved)-1]
+	for _, path := range saved {
+		if path == run.ActiveFile {
+			active = path
+		}
+		if e.bufferIndex(path) >= 0 {
+			continue
+		}
+		view := bufferView{path: path}
+		if state, ok := e.sessionManager.GetFileState(path); ok {
+			view.cursor = Cursor{Row: state.CursorRow, Col: state.CursorCol}
+			view.scroll = state.ScrollY
+			view.scrollX = state.ScrollX
+		}
+		e.buffers = append(e.buffers, bufferEntry{bufferView: view})
+	}
+	return active, nil
+}
+
+// execResumeCommand resumes the last session from a running editor
+// (:resume), opening its active file through the open-file request
+func (e *Editor) execResumeCommand() {
+	if e.dirty {
+		e.setStatus("unsaved changes (use :w first)")
+		return
+	}
+	path, err := e.ResumeSession()
+	if err != nil {
+		e.setStatus(err.Error())
+		return
+	}
+	if path == bufferKey(e.filename) {
+		e.setStatus("resumed " + strconv.Itoa(len(e.buffers)) + " buffers")
+		return
+	}
+	e.rememberBufferView()
+	e.openFileRequest = path
+}
+
+// sessionBuffers returns the buffers of run whose files still exist
+func sessionBuffers(run session.Run) []string {
+	var paths []string
+	for _, path := range run.Buffers {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,80 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kobzarvs/qedit/internal/config"
+)
+
+func TestResumeAfterUncleanExit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := New(config.Default())
+	if e.GetSessionManager().MarkRunning() {
+		t.Fatalf("fresh session reported an unclean exit")
+	}
+	if err := e.OpenFile(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.OpenFile(a); err != nil {
+		t.Fatal(err)
+	}
+	e.cursor = Cursor{Row: 2, Col: 1}
+	e.SyncSession()
+	// The terminal goes away: the autosave wrote the session, Stop never ran
+	_ = e.GetSessionManager().ForceSave()
+	e.DisableState()
+
+	e = New(config.Default())
+	defer e.DisableState()
+	if !e.GetSessionManager().MarkRunning() {
+		t.Fatalf("unclean exit was not detected")
+	}
+	e.OfferResume()
+	if want := "Resume last session: :resume (2 buffers)"; e.statusMessage != want {
+		t.Fatalf("status = %q, want %q", e.statusMessage, want)
+	}
+	path, err := e.ResumeSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != a {
+		t.Fatalf("active = %q, want %q", path, a)
+	}
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if e.cursor != (Cursor{Row: 2, Col: 1}) {
+		t.Fatalf("cursor = %+v, want row 2 col 1", e.cursor)
+	}
+	if len(e.buffers) != 2 || e.buffers[0].path != b || e.buffers[1].path != a {
+		t.Fatalf("buffers = %+v, want b then a", e.buffers)
+	}
+}
+
+func TestResumeAfterCleanExit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	e := New(config.Default())
+	e.GetSessionManager().MarkRunning()
+	e.Shutdown()
+
+	e = New(config.Default())
+	defer e.DisableState()
+	if e.GetSessionManager().MarkRunning() {
+		t.Fatalf("clean exit reported as unclean")
+	}
+	e.execResumeCommand()
+	if want := "no session to resume"; e.statusMessage != want {
+		t.Fatalf("status = %q, want %q", e.statusMessage, want)
+	}
+}
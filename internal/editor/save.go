@@ -0,0 +1,149 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/kobzarvs/qedit/pkg/plugin"
+)
+
+// symlinkSave is how a save treats a path that is a symbolic link
+type symlinkSave int
+
+const (
+	symlinkAsk     symlinkSave = iota // refuse and name the choices (:w)
+	symlinkTarget                     // write to the file the link points to (:w!)
+	symlinkReplace                    // replace the link with a regular file (:wlink)
+)
+
+// save writes the buffer to path, or to the open file when path is "".
+// A symlink is only written through once the user chose what to do with
+// it; writing to its target is remembered for the rest of the buffer.
+// The open file isn't overwritten when something else changed it since it
+// was read, unless forced with :w!. A file the user can't write is left
+// to :wsudo.
+func (e *Editor) save(path string, link symlinkSave) error {
+	if path == "" {
+		if e.filename == "" {
+			return errors.New("no file name")
+		}
+		path = e.filename
+	}
+	if e.preview && path == e.filename {
+		return errors.New("preview of binary or huge file can't be written")
+	}
+	if e.readOnly && sameFile(path, e.filename) {
+		return errors.New("read-only (write to another path with :w <path>)")
+	}
+	if path == e.filename && e.fileReadOnly {
+		return fmt.Errorf("%s isn't writable (:wsudo saves it with sudo)", path)
+	}
+	if link == symlinkAsk && path == e.filename && e.changedOnDisk() {
+		return fmt.Errorf("%s changed on disk since it was read (:merge takes its changes, :w! overwrites it)", path)
+	}
+	if link == symlinkAsk && e.symlinkConfirmed == bufferKey(path) {
+		link = symlinkTarget
+	}
+	text := joinLines(e.lines)
+	data := e.fileBytes(text)
+	if err := writeFile(path, data, link); err != nil {
+		if path == e.filename && !fileWritable(path) {
+			return fmt.Errorf("%v (:wsudo saves it with sudo)", err)
+		}
+		return err
+	}
+	if link == symlinkTarget {
+		e.symlinkConfirmed = bufferKey(path)
+	}
+	e.filename = path
+	e.wroteFile(text, data)
+	return nil
+}
+
+// wroteFile records that the buffer, text, was written to the open file
+// as data
+func (e *Editor) wroteFile(text string, data []byte) {
+	e.preview = false
+	e.rememberDisk(data)
+	e.markSaved()
+	e.validateSaved([]byte(text))
+	_ = e.SaveUndoHistory()
+	e.saveSessionState()
+	e.notifyPlugins(plugin.EventSave, e.filename)
+}
+
+// sameFile reports whether paths a and b name the same file: the same
+// cleaned absolute path, or, when both exist, the same file reached through
+// a link
+func sameFile(a, b string) bool {
+	if bufferKey(a) == bufferKey(b) {
+		return true
+	}
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
+
+// writeFile writes data to path in place rather than through a temporary
+// file and a rename, so hard links to the file stay linked and its mode
+// and owner are kept
+func writeFile(path string, data []byte, link symlinkSave) error {
+	info, err := os.Lstat(path)
+	if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		target, terr := filepath.EvalSymlinks(path)
+		if terr != nil {
+			target, _ = os.Readlink(path)
+		}
+		switch link {
+		case symlinkAsk:
+			return fmt.Errorf("%s is a symlink to %s (:w! writes to the target, :wlink replaces the link)", path, target)
+		case symlinkReplace:
+			mode := fs.FileMode(0o644)
+			if ti, serr := os.Stat(path); serr == nil {
+				mode = ti.Mode().Perm()
+			}
+			if err := os.Remove(path); err != nil {
+				return writeError(path, err)
+			}
+			return writeError(path, os.WriteFile(path, data, mode))
+		}
+	}
+	return writeError(path, os.WriteFile(path, data, 0o644))
+}
+
+// writeError turns a permission error into one naming the resolved path
+// and why it can't be written. Other errors are returned as they are.
+func writeError(path string, err error) error {
+	if err == nil || !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	resolved := resolvePath(path)
+	if info, serr := os.Stat(resolved); serr == nil {
+		return fmt.Errorf("permission denied: can't write %s (mode %s)", resolved, info.Mode().Perm())
+	}
+	return fmt.Errorf("permission denied: can't create %s (directory %s not writable)", resolved, filepath.Dir(resolved))
+}
+
+// resolvePath returns path made absolute with symlinks resolved. A file
+// that doesn't exist yet is resolved through its directory.
+func resolvePath(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		if abs, err := filepath.Abs(real); err == nil {
+			return abs
+		}
+		return real
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		return filepath.Join(dir, filepath.Base(path))
+	}
+	return bufferKey(path)
+}
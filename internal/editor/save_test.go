@@ -0,0 +1,222 @@
+package editor
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openLinked writes target.txt and a link.txt symlink to it, and opens
+// the link in a test editor with "x" typed at the start
+func openLinked(t *testing.T) (e *Editor, link, target string) {
+	t.Helper()
+	dir := t.TempDir()
+	target = filepath.Join(dir, "target.txt")
+	link = filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("orig"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	e = newTestEditor("")
+	e.DisableState()
+	if err := e.OpenFile(link); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	e.insertRune('x')
+	return e, link, target
+}
+
+func TestSaveSymlinkAsks(t *testing.T) {
+	e, link, target := openLinked(t)
+	e.execCommand("w")
+	if !strings.Contains(e.statusMessage, "is a symlink to "+target) || !strings.Contains(e.statusMessage, ":w!") {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "orig" {
+		t.Fatalf("target written without confirmation: %q", data)
+	}
+	if !e.dirty {
+		t.Fatal("dirty = false after refused write")
+	}
+
+	e.execCommand("w!")
+	if data, _ := os.ReadFile(target); string(data) != "xorig" {
+		t.Fatalf("target = %q after :w!", data)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("link replaced by :w!")
+	}
+
+	// The choice holds for later saves of the buffer
+	e.insertRune('y')
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save after :w! = %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "xyorig" {
+		t.Fatalf("target = %q after second save", data)
+	}
+}
+
+func TestSaveSymlinkReplace(t *testing.T) {
+	e, link, target := openLinked(t)
+	e.execCommand("wlink")
+	info, err := os.Lstat(link)
+	if err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("link not replaced by a file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode = %v, want the target's 0600", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(link); string(data) != "xorig" {
+		t.Fatalf("new file = %q", data)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "orig" {
+		t.Fatalf("target changed by :wlink: %q", data)
+	}
+}
+
+func TestSaveKeepsHardLinks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	other := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(path, []byte("orig"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(path, other); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+	e := newTestEditor("")
+	e.DisableState()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	e.insertRune('x')
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "xorig" {
+		t.Fatalf("hard link = %q, want the saved text", data)
+	}
+}
+
+func TestWriteErrorNamesResolvedPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ro.txt")
+	if err := os.WriteFile(path, []byte("x"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	denied := &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission}
+	err := writeError(path, denied)
+	want := "permission denied: can't write " + resolvePath(path) + " (mode -r--r--r--)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("err = %v, want %q", err, want)
+	}
+
+	missing := filepath.Join(dir, "new.txt")
+	err = writeError(missing, &fs.PathError{Op: "open", Path: missing, Err: fs.ErrPermission})
+	if err == nil || !strings.Contains(err.Error(), "can't create "+resolvePath(missing)) {
+		t.Fatalf("err = %v", err)
+	}
+	if other := os.ErrNotExist; writeError(path, other) != other {
+		t.Fatal("non-permission error was rewritten")
+	}
+}
+
+func TestSaveRefusesFileChangedOnDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.txt")
+	if err := os.WriteFile(path, []byte("orig"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor("")
+	e.DisableState()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	// A touch that leaves the content alone is no change
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	e.CheckDisk()
+	if e.statusMessage != "" {
+		t.Fatalf("status after touch %q", e.statusMessage)
+	}
+
+	if err := os.WriteFile(path, []byte("theirs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e.CheckDisk()
+	if e.statusMessage != "f.txt changed on disk (:merge takes its changes, :w! overwrites it)" {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+	e.insertRune('x')
+	if err := e.Save(""); err == nil || !strings.Contains(err.Error(), "changed on disk") {
+		t.Fatalf("Save = %v", err)
+	}
+	e.execCommand("w!")
+	if data, _ := os.ReadFile(path); string(data) != "xorig" {
+		t.Fatalf("after :w! %q", data)
+	}
+	if err := e.Save(""); err != nil {
+		t.Fatalf("Save after :w! = %v", err)
+	}
+}
+
+func TestReadOnlyFileWrittenWithSudo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ro.txt")
+	if err := os.WriteFile(path, []byte("orig"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor("")
+	e.DisableState()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if os.Geteuid() != 0 && !e.fileReadOnly {
+		t.Fatal("file without write permission opened writable")
+	}
+	// root can write anything, so mark it as the check would for a user
+	e.fileReadOnly = true
+	e.HandleKey(keyRune('i'))
+	if e.mode != ModeNormal || e.statusMessage != "ro.txt isn't writable (:readonly off to edit, :wsudo saves with sudo)" {
+		t.Fatalf("mode %v, status %q", e.mode, e.statusMessage)
+	}
+
+	var written []string
+	e.privilegedWrite = func(p string, data []byte) error {
+		written = append(written, p+"="+string(data))
+		return nil
+	}
+	e.execCommand("readonly off")
+	e.HandleKey(keyRune('i'))
+	e.insertRune('x')
+	e.fileReadOnly = true // the file stays unwritable, whoever runs the test
+	// Neither :w nor :w! go through sudo
+	for _, cmd := range []string{"w", "w!"} {
+		e.execCommand(cmd)
+		if len(written) > 0 || !strings.Contains(e.statusMessage, ":wsudo saves it with sudo") {
+			t.Fatalf(":%s wrote %v, status %q", cmd, written, e.statusMessage)
+		}
+	}
+
+	// :wsudo asks first; only y writes
+	e.execCommand("wsudo")
+	e.HandleKey(keyRune('n'))
+	if len(written) > 0 || e.statusMessage != "not written" {
+		t.Fatalf("written %v after n, status %q", written, e.statusMessage)
+	}
+	e.execCommand("wsudo")
+	if !e.popupOpen(sudoConfirmPopup{}) {
+		t.Fatal("no confirmation")
+	}
+	e.HandleKey(keyRune('y'))
+	if len(written) != 1 || written[0] != resolvePath(path)+"=xorig" || e.dirty {
+		t.Fatalf("sudo wrote %v, dirty %v, status %q", written, e.dirty, e.statusMessage)
+	}
+}
@@ -95,7 +95,7 @@ func TestSearchPrevWithRefsPicker(t *testing.T) {
 	ed.updateSearchMatches()
 
 	// Activate refs picker
-	ed.refsPickerActive = true
+	ed.openPopup(refsPickerPopup{})
 	ed.refsPickerItems = []LSPLocation{
 		{Path: "test.go", StartLine: 0, StartCol: 0},
 		{Path: "test.go", StartLine: 1, StartCol: 0},
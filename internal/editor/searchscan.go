@@ -0,0 +1,107 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// searchBudget bounds how long one search update scans the buffer, so
+// typing stays responsive in a huge file or with a slow pattern. Matches
+// found so far are shown and marked partial until the scan completes.
+const searchBudget = 20 * time.Millisecond
+
+// searchScan is a search still scanning the buffer for e.searchQuery
+type searchScan struct {
+	re     *regexp.Regexp // compiled pattern in regex mode
+	next   int            // next row to scan
+	origin int            // cursor row when the search started
+	jumped bool           // the cursor went to a match at or after origin
+}
+
+// SearchPending reports whether a search was cut short by its time budget
+// and ContinueSearch has rows left to scan
+func (e *Editor) SearchPending() bool {
+	return e.searchScan != nil && e.mode == ModeSearch
+}
+
+// ContinueSearch scans the buffer for another searchBudget and reports
+// whether rows are still left. The app calls it between events, so keys
+// typed meanwhile are handled first and restart the search.
+func (e *Editor) ContinueSearch() bool {
+	if !e.SearchPending() {
+		return false
+	}
+	e.scanSearch(time.Now().Add(searchBudget))
+	return e.searchScan != nil
+}
+
+// finishSearch scans the rest of the buffer without a budget, for n and N
+// after a search confirmed before it was complete
+func (e *Editor) finishSearch() {
+	if e.searchScan != nil {
+		e.scanSearch(time.Time{})
+	}
+}
+
+// dropSearchMatches forgets the matches of the last search, which an edit
+// may have moved or broken; n and N search again
+func (e *Editor) dropSearchMatches(TextChange) {
+	e.searchMatches = nil
+	e.searchMatchIndex = 0
+	e.searchScan = nil
+}
+
+// scanSearch scans rows until deadline (the whole buffer for a zero
+// deadline), then moves to the first match at or after the row the search
+// started from. Matches before it only count once the scan is done, so the
+// cursor doesn't jump back to the top and then forward again.
+func (e *Editor) scanSearch(deadline time.Time) {
+	scan := e.searchScan
+	row := scan.next
+	for ; row < len(e.lines); row++ {
+		if row > scan.next && !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		e.searchLine(scan, row)
+	}
+	scan.next = row
+	if row >= len(e.lines) {
+		e.searchScan = nil
+	}
+
+	if scan.jumped || len(e.searchMatches) == 0 {
+		return
+	}
+	for i, match := range e.searchMatches {
+		if match.Row >= scan.origin {
+			e.searchMatchIndex = i
+			scan.jumped = true
+			e.jumpToCurrentMatch()
+			return
+		}
+	}
+	if e.searchScan == nil {
+		// Wrap around
+		e.searchMatchIndex = 0
+		e.jumpToCurrentMatch()
+	}
+}
+
+// searchCountText is the match count at the right of the search line.
+// A + marks a count that is still growing.
+func (e *Editor) searchCountText() string {
+	more := ""
+	if e.searchScan != nil {
+		more = "+"
+	}
+	switch {
+	case len(e.searchMatches) > 0:
+		return fmt.Sprintf(" [%d/%d%s] ", e.searchMatchIndex+1, len(e.searchMatches), more)
+	case e.searchScan != nil:
+		return " [searching] "
+	case len(e.searchQuery) > 0:
+		return " [no matches] "
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package editor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// startPartialSearch starts a search for query that stops after one row,
+// as if the budget ran out
+func startPartialSearch(e *Editor, query string) {
+	e.enterSearchMode(true, false, false)
+	e.searchQuery = []rune(query)
+	e.searchCursor = len(e.searchQuery)
+	e.searchScan = &searchScan{origin: e.cursor.Row}
+	e.scanSearch(time.Now())
+}
+
+func TestSearchPartialResults(t *testing.T) {
+	e := newTestEditor("ab", "x", "x", "ab", "ab")
+	e.cursor = Cursor{Row: 2}
+	startPartialSearch(e, "ab")
+	if !e.SearchPending() {
+		t.Fatalf("search finished within an expired budget")
+	}
+	if got := e.searchCountText(); got != " [1/1+] " {
+		t.Fatalf("count = %q, want partial [1/1+]", got)
+	}
+	if e.cursor.Row != 2 {
+		t.Fatalf("cursor jumped to row %d before a match after it was found", e.cursor.Row)
+	}
+
+	for e.ContinueSearch() {
+	}
+	if got := e.searchCountText(); got != " [2/3] " {
+		t.Fatalf("count = %q, want [2/3]", got)
+	}
+	if e.cursor.Row != 3 {
+		t.Fatalf("cursor row = %d, want the first match after the start (3)", e.cursor.Row)
+	}
+}
+
+func TestSearchRestartsOnKeystroke(t *testing.T) {
+	e := newTestEditor("abc", "abd", "abc")
+	startPartialSearch(e, "ab")
+	e.HandleKey(keyRune('c'))
+	if e.SearchPending() {
+		t.Fatalf("short buffer not scanned within the budget")
+	}
+	if len(e.searchMatches) != 2 || e.searchMatches[1].Row != 2 {
+		t.Fatalf("matches = %+v, want rows 0 and 2 for abc", e.searchMatches)
+	}
+}
+
+func TestSearchNextFinishesScan(t *testing.T) {
+	e := newTestEditor("ab", "x", "ab")
+	startPartialSearch(e, "ab")
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if e.SearchPending() || e.searchScan != nil {
+		t.Fatalf("scan left unfinished after Enter")
+	}
+	e.lastSearchQuery = "ab"
+	e.searchNext()
+	if e.cursor.Row != 2 {
+		t.Fatalf("n went to row %d, want 2", e.cursor.Row)
+	}
+}
+
+func TestSearchMatchesDroppedByEdit(t *testing.T) {
+	e := newTestEditor("ab", "x", "ab")
+	e.lastSearchQuery = "ab"
+	e.searchNext()
+	if len(e.searchMatches) != 2 {
+		t.Fatalf("matches = %+v, want 2", e.searchMatches)
+	}
+	if _, err := e.InsertAt(Cursor{Row: 1, Col: 0}, "\n"); err != nil {
+		t.Fatalf("InsertAt: %v", err)
+	}
+	if e.searchMatches != nil {
+		t.Fatalf("matches %+v kept after an edit moved them", e.searchMatches)
+	}
+	e.searchNext()
+	if len(e.searchMatches) != 2 || e.searchMatches[1].Row != 3 {
+		t.Fatalf("matches after n = %+v, want rows 0 and 3", e.searchMatches)
+	}
+}
@@ -0,0 +1,153 @@
+package editor
+
+import "unicode"
+
+// Selection snapping: trim (_), extend and shrink to line bounds (X,
+// Alt+x) and to word bounds. They normalize a rough mouse or motion
+// selection before an operator runs on it. Without a selection they work
+// on the cursor position.
+
+// snapRange returns the selection, or an empty range at the cursor, and
+// whether the cursor is at its start (a backward selection)
+func (e *Editor) snapRange() (start, end Cursor, backward bool) {
+	if start, end, ok := e.selectionRange(); ok {
+		return start, end, cursorLess(e.selectionEnd, e.selectionStart)
+	}
+	return e.cursor, e.cursor, false
+}
+
+// setSnapped selects start..end keeping the direction of the old
+// selection; the cursor goes to the head. An empty range drops the
+// selection.
+func (e *Editor) setSnapped(start, end Cursor, backward bool) {
+	if !cursorLess(start, end) {
+		e.clearSelection()
+		return
+	}
+	if backward {
+		start, end = end, start
+	}
+	e.selectionStart, e.selectionEnd = start, end
+	e.selectionActive = true
+	e.cursor = end
+}
+
+// runeAt returns the character at c, '\n' at the end of a line
+func (e *Editor) runeAt(c Cursor) rune {
+	line := e.lines[c.Row]
+	if c.Col >= len(line) {
+		return '\n'
+	}
+	return line[c.Col]
+}
+
+// nextPos and prevPos step over one character, line breaks included
+func (e *Editor) nextPos(c Cursor) Cursor {
+	if c.Col < len(e.lines[c.Row]) {
+		return Cursor{Row: c.Row, Col: c.Col + 1}
+	}
+	return Cursor{Row: c.Row + 1}
+}
+
+func (e *Editor) prevPos(c Cursor) Cursor {
+	if c.Col > 0 {
+		return Cursor{Row: c.Row, Col: c.Col - 1}
+	}
+	return Cursor{Row: c.Row - 1, Col: len(e.lines[c.Row-1])}
+}
+
+// trimSelection drops whitespace and line breaks from both ends of the
+// selection (_). A selection of only whitespace is removed.
+func (e *Editor) trimSelection() {
+	start, end, ok := e.selectionRange()
+	if !ok {
+		return
+	}
+	backward := cursorLess(e.selectionEnd, e.selectionStart)
+	for cursorLess(start, end) && unicode.IsSpace(e.runeAt(start)) {
+		start = e.nextPos(start)
+	}
+	for cursorLess(start, end) && unicode.IsSpace(e.runeAt(e.prevPos(end))) {
+		end = e.prevPos(end)
+	}
+	e.setSnapped(start, end, backward)
+}
+
+// extendToLineBounds grows the selection to whole lines (X)
+func (e *Editor) extendToLineBounds() {
+	start, end, backward := e.snapRange()
+	start.Col = 0
+	// A selection ending at the start of a line already ends on a bound
+	if end.Col > 0 || end.Row == start.Row {
+		end.Col = len(e.lines[end.Row])
+	}
+	e.setSnapped(start, end, backward)
+}
+
+// shrinkToLineBounds shrinks the selection to the whole lines inside it
+// (Alt+x). A selection within one line is left as it is.
+func (e *Editor) shrinkToLineBounds() {
+	start, end, ok := e.selectionRange()
+	if !ok {
+		return
+	}
+	backward := cursorLess(e.selectionEnd, e.selectionStart)
+	from, to := start, end
+	if from.Col > 0 {
+		from = Cursor{Row: from.Row + 1}
+	}
+	if to.Col < len(e.lines[to.Row]) {
+		if to.Row == 0 {
+			return
+		}
+		to = Cursor{Row: to.Row - 1, Col: len(e.lines[to.Row-1])}
+	}
+	if from.Row > to.Row {
+		return
+	}
+	e.setSnapped(from, to, backward)
+}
+
+// extendToWordBounds grows the selection so it doesn't start or end
+// inside a word; without a selection it selects the word at the cursor
+func (e *Editor) extendToWordBounds() {
+	start, end, backward := e.snapRange()
+	if line := e.lines[start.Row]; start.Col < len(line) {
+		runs := e.wordRuns(line)
+		if runs.kind(start.Col) == runWord {
+			start.Col = runs.start(start.Col)
+		}
+	}
+	if line := e.lines[end.Row]; end == start && end.Col < len(line) {
+		end.Col = e.wordRuns(line).end(end.Col)
+	} else if end.Col > 0 && end.Col < len(line) {
+		runs := e.wordRuns(line)
+		if runs.kind(end.Col-1) == runWord && !runs.starts[end.Col] {
+			end.Col = runs.end(end.Col - 1)
+		}
+	}
+	e.setSnapped(start, end, backward)
+}
+
+// shrinkToWordBounds shrinks the selection to the whole words inside it,
+// dropping partly selected words at either end
+func (e *Editor) shrinkToWordBounds() {
+	start, end, ok := e.selectionRange()
+	if !ok {
+		return
+	}
+	backward := cursorLess(e.selectionEnd, e.selectionStart)
+	if line := e.lines[start.Row]; start.Col > 0 && start.Col < len(line) {
+		runs := e.wordRuns(line)
+		if runs.kind(start.Col) == runWord && !runs.starts[start.Col] {
+			start.Col = runs.end(start.Col)
+		}
+	}
+	if line := e.lines[end.Row]; end.Col > 0 && end.Col < len(line) {
+		runs := e.wordRuns(line)
+		if runs.kind(end.Col-1) == runWord && !runs.starts[end.Col] {
+			end.Col = runs.start(end.Col - 1)
+		}
+	}
+	e.setSnapped(start, end, backward)
+}
@@ -0,0 +1,63 @@
+package editor
+
+import "testing"
+
+// selectText selects from..to (to is the head) and returns e
+func selectText(e *Editor, from, to Cursor) *Editor {
+	e.selectionActive = true
+	e.selectionStart, e.selectionEnd = from, to
+	e.cursor = to
+	return e
+}
+
+func TestSelectionSnapping(t *testing.T) {
+	lines := []string{"  foo bar  ", "helloWorld baz", "last"}
+	cases := []struct {
+		name     string
+		from, to Cursor
+		run      func(*Editor)
+		want     string // selected text, "" for none
+	}{
+		{"trim", Cursor{Row: 0, Col: 0}, Cursor{Row: 1, Col: 0}, (*Editor).trimSelection, "foo bar"},
+		{"trim backward", Cursor{Row: 1, Col: 0}, Cursor{Row: 0, Col: 1}, (*Editor).trimSelection, "foo bar"},
+		{"trim only spaces", Cursor{Row: 0, Col: 0}, Cursor{Row: 0, Col: 2}, (*Editor).trimSelection, ""},
+		{"extend lines", Cursor{Row: 0, Col: 3}, Cursor{Row: 1, Col: 2}, (*Editor).extendToLineBounds, "  foo bar  \nhelloWorld baz"},
+		{"extend lines at bound", Cursor{Row: 0, Col: 3}, Cursor{Row: 1, Col: 0}, (*Editor).extendToLineBounds, "  foo bar  \n"},
+		{"shrink lines", Cursor{Row: 0, Col: 3}, Cursor{Row: 2, Col: 2}, (*Editor).shrinkToLineBounds, "helloWorld baz"},
+		{"shrink lines in one line", Cursor{Row: 0, Col: 3}, Cursor{Row: 0, Col: 5}, (*Editor).shrinkToLineBounds, "oo"},
+		{"extend words", Cursor{Row: 0, Col: 3}, Cursor{Row: 0, Col: 7}, (*Editor).extendToWordBounds, "foo bar"},
+		{"shrink words", Cursor{Row: 0, Col: 3}, Cursor{Row: 1, Col: 12}, (*Editor).shrinkToWordBounds, " bar  \nhelloWorld "},
+	}
+	for _, c := range cases {
+		e := selectText(newTestEditor(lines...), c.from, c.to)
+		c.run(e)
+		got := ""
+		if start, end, ok := e.selectionRange(); ok {
+			got = e.textInRange(start, end)
+		}
+		if got != c.want {
+			t.Errorf("%s: selected %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSnapKeepsDirection(t *testing.T) {
+	e := selectText(newTestEditor("  foo  "), Cursor{Row: 0, Col: 7}, Cursor{Row: 0, Col: 0})
+	e.trimSelection()
+	if e.selectionStart != (Cursor{Row: 0, Col: 5}) || e.selectionEnd != (Cursor{Row: 0, Col: 2}) || e.cursor != (Cursor{Row: 0, Col: 2}) {
+		t.Fatalf("selection %v..%v cursor %v", e.selectionStart, e.selectionEnd, e.cursor)
+	}
+
+	// Without a selection the word or line at the cursor is selected
+	e = newTestEditor("one helloWorld")
+	e.cursor = Cursor{Row: 0, Col: 7}
+	e.extendToWordBounds()
+	if start, end, ok := e.selectionRange(); !ok || e.textInRange(start, end) != "helloWorld" {
+		t.Fatalf("word at cursor = %v %v %v", start, end, ok)
+	}
+	e.clearSelection()
+	e.extendToLineBounds()
+	if start, end, ok := e.selectionRange(); !ok || e.textInRange(start, end) != "one helloWorld" {
+		t.Fatalf("line at cursor = %v %v %v", start, end, ok)
+	}
+}
@@ -19,6 +19,7 @@ const (
 	SidebarModeRecentHistory // line-by-line history (future)
 	SidebarModeLocalChanges  // local changes history (future)
 	SidebarModeWorktrees     // git worktrees (future)
+	SidebarModeProblems      // validation problems of the current buffer
 )
 
 // SidebarAction represents actions returned from sidebar content
@@ -33,6 +34,7 @@ const (
 	SidebarActionRefresh        // refresh current mode
 	SidebarActionFocusEditor    // return focus to editor
 	SidebarActionSwitchMode     // switch to different mode
+	SidebarActionGoto           // move cursor (Line, Col in Data)
 )
 
 // SidebarActionData contains action and associated data
@@ -41,6 +43,8 @@ type SidebarActionData struct {
 	Path   string      // for OpenFile
 	Branch string      // for CheckoutBranch
 	Mode   SidebarMode // for SwitchMode
+	Line   int         // for Goto, 0-based
+	Col    int         // for Goto, 0-based byte column
 }
 
 // SidebarItem represents an item in the sidebar list
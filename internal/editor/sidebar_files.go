@@ -0,0 +1,336 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// fileTreeWatchDebounce is how long the listed directory must stay unchanged
+// before the tree is refreshed (e.g. during a git checkout or a build)
+const fileTreeWatchDebounce = 300 * time.Millisecond
+
+// fileTreeEntry is a single directory entry in the file tree
+type fileTreeEntry struct {
+	name    string
+	path    string
+	isDir   bool
+	hidden  bool
+	ignored bool
+}
+
+// SidebarFilesContent implements SidebarContent for the file tree
+type SidebarFilesContent struct {
+	root        string
+	dir         string
+	entries     []fileTreeEntry
+	index       int
+	showHidden  bool
+	showIgnored bool
+	ignore      *ignoreMatcher
+
+	// Watch state: directory mtime at last refresh and pending change
+	dirModTime    time.Time
+	seenModTime   time.Time
+	changePending time.Time
+}
+
+// NewSidebarFilesContent creates a file tree rooted at root
+func NewSidebarFilesContent(root string, showHidden, showIgnored bool, extraIgnore []string) *SidebarFilesContent {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	f := &SidebarFilesContent{
+		root:        root,
+		dir:         root,
+		showHidden:  showHidden,
+		showIgnored: showIgnored,
+	}
+	ignoreRoot := findGitRoot(root)
+	if ignoreRoot == "" {
+		ignoreRoot = root
+	}
+	f.ignore = newIgnoreMatcher(ignoreRoot, extraIgnore)
+	_ = f.Refresh()
+	return f
+}
+
+// Mode returns the mode identifier
+func (f *SidebarFilesContent) Mode() SidebarMode {
+	return SidebarModeFileTree
+}
+
+// Title returns header text
+func (f *SidebarFilesContent) Title() string {
+	title := "Files"
+	if rel, err := filepath.Rel(f.root, f.dir); err == nil && rel != "." {
+		title += ": " + rel
+	}
+	var flags []string
+	if f.showHidden {
+		flags = append(flags, "hidden")
+	}
+	if f.showIgnored {
+		flags = append(flags, "ignored")
+	}
+	if len(flags) > 0 {
+		title += " [+" + strings.Join(flags, ",") + "]"
+	}
+	return title
+}
+
+// Items returns the list to display
+func (f *SidebarFilesContent) Items() []SidebarItem {
+	result := make([]SidebarItem, len(f.entries))
+	for i, entry := range f.entries {
+		label := entry.name
+		if entry.isDir && entry.name != ".." {
+			label += "/"
+		}
+		result[i] = SidebarItem{
+			Label:     label,
+			Path:      entry.path,
+			IsDir:     entry.isDir,
+			IsHidden:  entry.hidden,
+			IsIgnored: entry.ignored,
+			Available: true,
+		}
+	}
+	return result
+}
+
+// Index returns current selection index
+func (f *SidebarFilesContent) Index() int {
+	return f.index
+}
+
+// SetIndex sets the selection index
+func (f *SidebarFilesContent) SetIndex(i int) {
+	if i >= 0 && i < len(f.entries) {
+		f.index = i
+	}
+}
+
+// HandleKey processes file tree keys: '.' toggles hidden, 'i' toggles ignored,
+// l/right enters a directory, backspace goes to the parent directory
+func (f *SidebarFilesContent) HandleKey(ev *tcell.EventKey) (bool, SidebarActionData) {
+	switch {
+	case ev.Key() == tcell.KeyRight || (ev.Key() == tcell.KeyRune && ev.Rune() == 'l'):
+		if f.index >= 0 && f.index < len(f.entries) && f.entries[f.index].isDir {
+			f.setDir(f.entries[f.index].path)
+		}
+		return true, SidebarActionData{Action: SidebarActionNone}
+	case ev.Key() == tcell.KeyRune && ev.Rune() == '.':
+		f.ToggleHidden()
+		return true, SidebarActionData{Action: SidebarActionNone}
+	case ev.Key() == tcell.KeyRune && ev.Rune() == 'i':
+		f.ToggleIgnored()
+		return true, SidebarActionData{Action: SidebarActionNone}
+	case ev.Key() == tcell.KeyBackspace || ev.Key() == tcell.KeyBackspace2:
+		f.GoUp()
+		return true, SidebarActionData{Action: SidebarActionNone}
+	}
+	return false, SidebarActionData{Action: SidebarActionNone}
+}
+
+// OnEnter enters a directory or opens a file
+func (f *SidebarFilesContent) OnEnter() SidebarActionData {
+	if f.index < 0 || f.index >= len(f.entries) {
+		return SidebarActionData{Action: SidebarActionNone}
+	}
+	entry := f.entries[f.index]
+	if entry.isDir {
+		f.setDir(entry.path)
+		return SidebarActionData{Action: SidebarActionNone}
+	}
+	return SidebarActionData{
+		Action: SidebarActionOpenFile,
+		Path:   entry.path,
+	}
+}
+
+// Available returns true (the file tree is always available)
+func (f *SidebarFilesContent) Available() bool {
+	return true
+}
+
+// Refresh re-reads the current directory, keeping the selection if possible
+func (f *SidebarFilesContent) Refresh() error {
+	selected := ""
+	if f.index >= 0 && f.index < len(f.entries) {
+		selected = f.entries[f.index].path
+	}
+	entries, err := f.readDir(f.dir)
+	for err != nil && os.IsNotExist(err) && filepath.Dir(f.dir) != f.dir {
+		// Directory was removed outside qedit - fall back to the nearest parent
+		f.dir = filepath.Dir(f.dir)
+		entries, err = f.readDir(f.dir)
+	}
+	if err != nil {
+		return err
+	}
+	f.entries = entries
+	if info, err := os.Stat(f.dir); err == nil {
+		f.dirModTime = info.ModTime()
+		f.seenModTime = f.dirModTime
+	}
+	f.changePending = time.Time{}
+	f.index = 0
+	for i, entry := range entries {
+		if entry.path == selected {
+			f.index = i
+			break
+		}
+	}
+	return nil
+}
+
+// Poll checks whether the listed directory changed on disk and refreshes it
+// once the change has settled for fileTreeWatchDebounce. Returns true if refreshed.
+func (f *SidebarFilesContent) Poll(now time.Time) bool {
+	modTime := f.dirModTime
+	if info, err := os.Stat(f.dir); err == nil {
+		modTime = info.ModTime()
+	} else if os.IsNotExist(err) {
+		modTime = time.Time{}
+	}
+	if modTime.Equal(f.dirModTime) && f.changePending.IsZero() {
+		return false
+	}
+	if !modTime.Equal(f.seenModTime) || f.changePending.IsZero() {
+		// New change - restart debounce
+		f.seenModTime = modTime
+		f.changePending = now
+		return false
+	}
+	if now.Sub(f.changePending) < fileTreeWatchDebounce {
+		return false
+	}
+	_ = f.Refresh()
+	return true
+}
+
+// Dir returns the directory currently listed
+func (f *SidebarFilesContent) Dir() string {
+	return f.dir
+}
+
+// GoUp lists the parent directory and selects the directory we came from
+func (f *SidebarFilesContent) GoUp() {
+	parent := filepath.Dir(f.dir)
+	if parent == f.dir {
+		return
+	}
+	prev := f.dir
+	f.setDir(parent)
+	for i, entry := range f.entries {
+		if entry.path == prev {
+			f.index = i
+			break
+		}
+	}
+}
+
+// ToggleHidden toggles visibility of dotfiles
+func (f *SidebarFilesContent) ToggleHidden() {
+	f.showHidden = !f.showHidden
+	_ = f.Refresh()
+}
+
+// ToggleIgnored toggles visibility of ignored files
+func (f *SidebarFilesContent) ToggleIgnored() {
+	f.showIgnored = !f.showIgnored
+	_ = f.Refresh()
+}
+
+// SetDir lists dir, selecting its first entry
+func (f *SidebarFilesContent) SetDir(dir string) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	f.setDir(dir)
+}
+
+func (f *SidebarFilesContent) setDir(dir string) {
+	f.dir = dir
+	f.index = 0
+	_ = f.Refresh()
+}
+
+// readDir lists dir: ".." first, then directories, then files (case-insensitive)
+func (f *SidebarFilesContent) readDir(dir string) ([]fileTreeEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	f.ignore.loadDir(dir)
+	dirIgnored := f.isIgnoredDir(dir)
+
+	var dirs, files []fileTreeEntry
+	for _, de := range dirEntries {
+		name := de.Name()
+		if name == ".git" {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		isDir := de.IsDir()
+		if de.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(p); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		entry := fileTreeEntry{
+			name:    name,
+			path:    p,
+			isDir:   isDir,
+			hidden:  strings.HasPrefix(name, "."),
+			ignored: dirIgnored || f.ignore.Match(p, isDir),
+		}
+		if entry.hidden && !f.showHidden {
+			continue
+		}
+		if entry.ignored && !f.showIgnored {
+			continue
+		}
+		if isDir {
+			dirs = append(dirs, entry)
+		} else {
+			files = append(files, entry)
+		}
+	}
+	byName := func(list []fileTreeEntry) func(i, j int) bool {
+		return func(i, j int) bool {
+			return strings.ToLower(list[i].name) < strings.ToLower(list[j].name)
+		}
+	}
+	sort.Slice(dirs, byName(dirs))
+	sort.Slice(files, byName(files))
+
+	var result []fileTreeEntry
+	if parent := filepath.Dir(dir); parent != dir {
+		result = append(result, fileTreeEntry{name: "..", path: parent, isDir: true})
+	}
+	result = append(result, dirs...)
+	result = append(result, files...)
+	return result, nil
+}
+
+// isIgnoredDir reports whether dir or any of its parents below the ignore root is ignored
+func (f *SidebarFilesContent) isIgnoredDir(dir string) bool {
+	root := f.ignore.root
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	for d := dir; d != root && filepath.Dir(d) != d; d = filepath.Dir(d) {
+		f.ignore.loadDir(filepath.Dir(d))
+		if f.ignore.Match(d, true) {
+			return true
+		}
+	}
+	return false
+}
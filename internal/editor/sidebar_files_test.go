@@ -0,0 +1,33 @@
+package editor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenProjectShowsFileTree(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "main.go"), "package main\n")
+
+	e := newTestEditor("")
+	if err := e.OpenProject(root); err != nil {
+		t.Fatalf("OpenProject error: %v", err)
+	}
+	if e.ProjectRoot() != root {
+		t.Fatalf("ProjectRoot = %q, want %q", e.ProjectRoot(), root)
+	}
+	if !e.sidebar.Visible || !e.sidebar.Focused {
+		t.Fatalf("sidebar visible=%v focused=%v, want true/true", e.sidebar.Visible, e.sidebar.Focused)
+	}
+	if e.sidebar.Content != e.sidebarFiles || e.sidebarFiles.Dir() != root {
+		t.Fatalf("sidebar should list project root")
+	}
+	got := sidebarLabels(e.sidebarFiles)
+	if len(got) != 2 || got[1] != "main.go" {
+		t.Fatalf("items = %v, want [.. main.go]", got)
+	}
+
+	if err := e.OpenProject(filepath.Join(root, "main.go")); err == nil {
+		t.Fatalf("OpenProject on a file should fail")
+	}
+}
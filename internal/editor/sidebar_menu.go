@@ -35,6 +35,7 @@ func (m *SidebarMenuContent) buildItems() {
 		{Label: "Recent History", Mode: SidebarModeRecentHistory, Hotkey: "", Available: false},
 		{Label: "Local Changes", Mode: SidebarModeLocalChanges, Hotkey: "", Available: false},
 		{Label: "Worktrees", Mode: SidebarModeWorktrees, Hotkey: "", Available: m.gitAvail},
+		{Label: "Problems", Mode: SidebarModeProblems, Hotkey: "Space d", Available: true},
 	}
 }
 
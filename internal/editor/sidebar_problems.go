@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kobzarvs/qedit/internal/validate"
+)
+
+// SidebarProblemsContent implements SidebarContent for the location list of
+// validation problems in the current buffer
+type SidebarProblemsContent struct {
+	problems []validate.Problem
+	index    int
+}
+
+// NewSidebarProblemsContent creates a new problems content
+func NewSidebarProblemsContent(problems []validate.Problem) *SidebarProblemsContent {
+	return &SidebarProblemsContent{problems: problems}
+}
+
+// Mode returns the mode identifier
+func (p *SidebarProblemsContent) Mode() SidebarMode {
+	return SidebarModeProblems
+}
+
+// Title returns header text
+func (p *SidebarProblemsContent) Title() string {
+	return fmt.Sprintf("Problems (%d)", len(p.problems))
+}
+
+// Items returns the list to display
+func (p *SidebarProblemsContent) Items() []SidebarItem {
+	result := make([]SidebarItem, len(p.problems))
+	for i, problem := range p.problems {
+		result[i] = SidebarItem{
+			Label:     problem.String(),
+			Available: true,
+		}
+	}
+	return result
+}
+
+// Index returns current selection index
+func (p *SidebarProblemsContent) Index() int {
+	return p.index
+}
+
+// SetIndex sets the selection index
+func (p *SidebarProblemsContent) SetIndex(i int) {
+	if i >= 0 && i < len(p.problems) {
+		p.index = i
+	}
+}
+
+// HandleKey processes mode-specific keys
+func (p *SidebarProblemsContent) HandleKey(ev *tcell.EventKey) (bool, SidebarActionData) {
+	return false, SidebarActionData{Action: SidebarActionNone}
+}
+
+// OnEnter called when Enter pressed - jump to the selected problem
+func (p *SidebarProblemsContent) OnEnter() SidebarActionData {
+	if p.index < 0 || p.index >= len(p.problems) {
+		return SidebarActionData{Action: SidebarActionNone}
+	}
+	problem := p.problems[p.index]
+	return SidebarActionData{
+		Action: SidebarActionGoto,
+		Line:   problem.Line - 1,
+		Col:    problem.Col - 1,
+	}
+}
+
+// Available returns true if there are problems to show
+func (p *SidebarProblemsContent) Available() bool {
+	return len(p.problems) > 0
+}
+
+// Refresh reloads content (noop - problems are set on save)
+func (p *SidebarProblemsContent) Refresh() error {
+	return nil
+}
+
+// SetProblems replaces the list, keeping the selection in range
+func (p *SidebarProblemsContent) SetProblems(problems []validate.Problem) {
+	p.problems = problems
+	if p.index >= len(problems) {
+		p.index = 0
+	}
+}
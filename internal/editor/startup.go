@@ -0,0 +1,33 @@
+package editor
+
+import "strings"
+
+// RunStartupCommand runs a "+cmd" command-line argument once the file is
+// loaded, following vi: "+" goes to the last line, "+N" to line N, "+/pat"
+// searches forward and anything else runs as an ex command ("+set wrap").
+// Returns true if the command quits the editor.
+func (e *Editor) RunStartupCommand(cmd string) bool {
+	cmd = strings.TrimSpace(cmd)
+	switch {
+	case cmd == "":
+		e.gotoLineNumber(len(e.lines))
+		return false
+	case strings.HasPrefix(cmd, "/"):
+		pattern := cmd[1:]
+		if pattern == "" {
+			return false
+		}
+		e.searchForward = true
+		e.searchFuzzy = false
+		e.searchRegex = false
+		e.lastSearchQuery = pattern
+		e.searchQuery = []rune(pattern)
+		e.updateSearchMatches()
+		e.finishSearch()
+		if len(e.searchMatches) == 0 {
+			e.setStatus("no matches: " + pattern)
+		}
+		return false
+	}
+	return e.execCommand(strings.TrimPrefix(cmd, ":"))
+}
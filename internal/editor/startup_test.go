@@ -0,0 +1,31 @@
+package editor
+
+import "testing"
+
+func TestRunStartupCommand(t *testing.T) {
+	e := newTestEditor("one", "two", "three", "two again")
+
+	if e.RunStartupCommand("3") || e.cursor.Row != 2 {
+		t.Fatalf("+3: cursor row = %d, want 2", e.cursor.Row)
+	}
+	e.RunStartupCommand("")
+	if e.cursor.Row != 3 {
+		t.Fatalf("+: cursor row = %d, want last line", e.cursor.Row)
+	}
+	e.cursor = Cursor{}
+	e.RunStartupCommand("/two")
+	if start, _, ok := e.selectionRange(); !ok || start != (Cursor{Row: 1, Col: 0}) {
+		t.Fatalf("+/two: selection start = %+v, want the match on line 2", start)
+	}
+	e.RunStartupCommand("ln off")
+	if e.lineNumberMode != LineNumberOff {
+		t.Fatalf("+ln off did not run")
+	}
+	e.RunStartupCommand("set wrap")
+	if e.statusMessage != "unknown command: set" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	if !e.RunStartupCommand("q") {
+		t.Fatalf("+q should quit")
+	}
+}
@@ -0,0 +1,219 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kobzarvs/qedit/internal/tasks"
+)
+
+func TestDisableStateSkipsHistoryFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+	t.Setenv("XDG_STATE_HOME", dir)
+	if err := os.WriteFile(filepath.Join(dir, "history"), []byte("w\nq\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := newTestEditor("one")
+	e.DisableState()
+	if e.GetSessionManager() != nil {
+		t.Fatalf("session manager still active")
+	}
+	e.LoadCmdHistory()
+	if len(e.cmdHistory) != 0 {
+		t.Fatalf("history loaded: %q", e.cmdHistory)
+	}
+	e.cmdHistory = []string{"ln off"}
+	e.searchHistory = []string{"two"}
+	e.saveCmdHistory()
+	e.saveSearchHistory()
+	if data, _ := os.ReadFile(filepath.Join(dir, "history")); string(data) != "w\nq\n" {
+		t.Fatalf("history file rewritten: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "search_history")); !os.IsNotExist(err) {
+		t.Fatalf("search history written: %v", err)
+	}
+}
+
+func TestQuitWithErrorCode(t *testing.T) {
+	e := newTestEditor("one")
+	if !e.execCommand("q!") || e.Aborted() {
+		t.Fatalf(":q! should quit without aborting")
+	}
+	if !e.execCommand("cq") || !e.Aborted() {
+		t.Fatalf(":cq should quit and abort")
+	}
+}
+
+func TestDirtyFollowsSavedRevision(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	e := newTestEditor("one")
+	e.DisableState()
+	e.insertRune('x')
+	if err := e.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name  string
+		run   func()
+		dirty bool
+	}{
+		{"undo past save", e.Undo, true},
+		{"redo to save", e.Redo, false},
+		{"undo again", e.Undo, true},
+		{"new edit after undo", func() { e.insertRune('y') }, true},
+		{"undo new edit", e.Undo, true},
+		{"redo new edit", e.Redo, true},
+	}
+	for _, step := range steps {
+		step.run()
+		if e.dirty != step.dirty {
+			t.Fatalf("%s: dirty = %v, want %v", step.name, e.dirty, step.dirty)
+		}
+	}
+	e.replaceBuffer("reloaded", false)
+	if e.dirty {
+		t.Fatal("dirty after reload")
+	}
+}
+
+func TestLoadedUndoHistoryIsSaved(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+	t.Setenv("XDG_STATE_HOME", dir)
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e.insertRune('x')
+	e.insertRune('y')
+	if err := e.Save(""); err != nil {
+		t.Fatal(err)
+	}
+
+	e2 := newTestEditor()
+	if err := e2.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(e2.undo) == 0 {
+		t.Fatal("undo history not loaded")
+	}
+	if e2.dirty {
+		t.Fatal("dirty right after loading undo history")
+	}
+	loaded := e2.revision()
+	e2.insertRune('z')
+	if e2.revision() <= loaded {
+		t.Fatalf("new edit reused revision %d (loaded %d)", e2.revision(), loaded)
+	}
+	e2.Undo()
+	if e2.dirty {
+		t.Fatal("dirty after undoing back to the saved text")
+	}
+	e2.Undo()
+	if !e2.dirty {
+		t.Fatal("not dirty after undoing past the saved text")
+	}
+}
+
+func TestUndoHistoryWrittenInTask(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+	t.Setenv("XDG_STATE_HOME", dir)
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{}, 8)
+	e.SetTaskRegistry(tasks.New(func() { done <- struct{}{} }))
+	e.insertRune('x')
+	if err := e.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	waitTask(t, done)
+	e.PollTasks()
+
+	e2 := newTestEditor()
+	if err := e2.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e2.Undo()
+	if got := e2.Content(); got != "one" {
+		t.Fatalf("after undo in a new session %q", got)
+	}
+}
+
+func TestUndoHistoryAutosaveKeepsSavedText(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+	t.Setenv("XDG_STATE_HOME", dir)
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEditor()
+	if err := e.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e.historyAutosave = time.Second
+	e.insertRune('x')
+	if err := e.Save(""); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unsaved edit is written once the interval has passed
+	e.insertRune('y')
+	e.AutosaveUndoHistory(time.Now())
+	if e.historyTick == e.changeTick {
+		t.Fatal("history written before the interval passed")
+	}
+	e.AutosaveUndoHistory(time.Now().Add(2 * time.Second))
+	if e.historyTick != e.changeTick {
+		t.Fatal("history not autosaved")
+	}
+
+	// The edit never reached the file, so loading leaves it out of the
+	// history, and undo starts from the saved text
+	e2 := newTestEditor()
+	if err := e2.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := e2.Content(); got != "xone" || !strings.Contains(e2.statusMessage, ":recover") {
+		t.Fatalf("content %q, status %q", got, e2.statusMessage)
+	}
+	e2.Undo()
+	if got := e2.Content(); got != "one" {
+		t.Fatalf("after undo %q", got)
+	}
+
+	// :recover puts it back as one undo step
+	e3 := newTestEditor()
+	if err := e3.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e3.execCommand("recover")
+	if got := e3.Content(); got != "xyone" || !e3.dirty {
+		t.Fatalf("recovered %q, dirty %v", got, e3.dirty)
+	}
+	e3.Undo()
+	e3.Undo()
+	if got := e3.Content(); got != "one" {
+		t.Fatalf("after undo %q", got)
+	}
+	e3.execCommand("recover")
+	if e3.statusMessage != "nothing to recover" {
+		t.Fatalf("status %q", e3.statusMessage)
+	}
+}
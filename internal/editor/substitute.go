@@ -0,0 +1,236 @@
+package editor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// :count, :s, :select, :keep and :remove take a regular expression (Go
+// syntax); all but :keep and :remove work line by line. They read the raw
+// command line: splitCommand would eat the backslashes of the pattern.
+
+// substitution is a parsed :s/pattern/replacement/flags
+type substitution struct {
+	re       *regexp.Regexp
+	repl     string
+	all      bool // g: every match on a line, not just the first
+	strict   bool // s: only the selected characters, not the whole lines
+	wholeBuf bool // :%s
+}
+
+// execRawCommand runs the commands that take a pattern and reports whether
+// cmd was one of them
+func (e *Editor) execRawCommand(cmd string) bool {
+	switch {
+	case cmd == "count" || strings.HasPrefix(cmd, "count "):
+		e.execCountCommand(strings.TrimSpace(strings.TrimPrefix(cmd, "count")))
+		return true
+	case cmd == "select" || strings.HasPrefix(cmd, "select "):
+		e.execSelectCommand(strings.TrimSpace(strings.TrimPrefix(cmd, "select")))
+		return true
+	case cmd == "keep" || strings.HasPrefix(cmd, "keep "):
+		e.execKeepCommand("keep", strings.TrimSpace(strings.TrimPrefix(cmd, "keep")), true)
+		return true
+	case cmd == "remove" || strings.HasPrefix(cmd, "remove "):
+		e.execKeepCommand("remove", strings.TrimSpace(strings.TrimPrefix(cmd, "remove")), false)
+		return true
+	case strings.HasPrefix(cmd, "s/") || strings.HasPrefix(cmd, "%s/"):
+		sub, err := parseSubstitution(cmd)
+		if err != nil {
+			e.setStatus(err.Error())
+			return true
+		}
+		e.substitute(sub)
+		return true
+	}
+	return false
+}
+
+// parseSubstitution parses [%]s/pattern/replacement/[gis]. \/ is a slash
+// in the pattern or replacement; $1 or ${name} in the replacement is a
+// group of the match.
+func parseSubstitution(cmd string) (substitution, error) {
+	var sub substitution
+	if strings.HasPrefix(cmd, "%") {
+		sub.wholeBuf = true
+		cmd = cmd[1:]
+	}
+	parts := splitUnescaped(strings.TrimPrefix(cmd, "s/"), '/')
+	if len(parts) < 2 || len(parts) > 3 {
+		return sub, fmt.Errorf("usage: :s/pattern/replacement/[gis]")
+	}
+	pattern := parts[0]
+	sub.repl = parts[1]
+	if len(parts) == 3 {
+		for _, f := range parts[2] {
+			switch f {
+			case 'g':
+				sub.all = true
+			case 'i':
+				pattern = "(?i)" + pattern
+			case 's':
+				sub.strict = true
+			default:
+				return sub, fmt.Errorf("unknown :s flag %q (use g, i, s)", f)
+			}
+		}
+	}
+	if parts[0] == "" {
+		return sub, fmt.Errorf("empty pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return sub, fmt.Errorf("bad pattern: %v", err)
+	}
+	sub.re = re
+	return sub, nil
+}
+
+// splitUnescaped splits s at sep, turning \sep into sep and keeping other
+// escapes for the regexp
+func splitUnescaped(s string, sep rune) []string {
+	var parts []string
+	var cur strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\\' && i+1 < len(runes) && runes[i+1] == sep:
+			i++
+			cur.WriteRune(sep)
+		case r == sep:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+// lineSpans returns the column range [lo, hi) of each row a command on the
+// selection covers: the selected characters when strict, otherwise whole
+// lines. Without a selection it is the whole buffer, or just the cursor
+// line when cursorLine is set.
+func (e *Editor) lineSpans(strict, cursorLine bool) (first, last int, span func(row int) (int, int)) {
+	whole := func(row int) (int, int) { return 0, len(e.lines[row]) }
+	start, end, ok := e.selectionRange()
+	switch {
+	case ok && strict:
+		return start.Row, end.Row, func(row int) (int, int) {
+			lo, hi, _ := e.selectionRangeForLine(row)
+			return lo, hi
+		}
+	case ok:
+		return start.Row, end.Row, whole
+	case cursorLine:
+		return e.cursor.Row, e.cursor.Row, whole
+	}
+	return 0, len(e.lines) - 1, whole
+}
+
+// execCountCommand reports how often pattern occurs in the selection, or in
+// the buffer without one
+func (e *Editor) execCountCommand(pattern string) {
+	if pattern == "" {
+		e.setStatus("usage: :count pattern")
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		e.setStatus("bad pattern: " + err.Error())
+		return
+	}
+	first, last, span := e.lineSpans(true, false)
+	count := 0
+	for row := first; row <= last; row++ {
+		lo, hi := span(row)
+		count += len(re.FindAllStringIndex(string(e.lines[row][lo:hi]), -1))
+	}
+	where := "in buffer"
+	if _, _, ok := e.selectionRange(); ok {
+		where = "in selection"
+	}
+	e.setStatus(fmt.Sprintf("%d %s %s", count, plural(count, "match", "matches"), where))
+}
+
+// substitute runs sub over the selected lines (or the selected characters
+// with the s flag), the whole buffer for :%s, or the cursor line, as one
+// undo step
+func (e *Editor) substitute(sub substitution) {
+	if e.refuseEdit() {
+		return
+	}
+	if sub.strict {
+		if _, _, ok := e.selectionRange(); !ok {
+			e.setStatus("no selection")
+			return
+		}
+	}
+	first, last, span := e.lineSpans(sub.strict, !sub.wholeBuf)
+	if sub.wholeBuf && !sub.strict {
+		first, last = 0, len(e.lines)-1
+	}
+	selStart, selEnd, hasSel := e.selectionRange()
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	replaced, lines := 0, 0
+	for row := first; row <= last; row++ {
+		lo, hi := span(row)
+		seg := string(e.lines[row][lo:hi])
+		out, n := replaceMatches(sub.re, seg, sub.repl, sub.all)
+		if n == 0 {
+			continue
+		}
+		end, err := e.ReplaceRange(Cursor{Row: row, Col: lo}, Cursor{Row: row, Col: hi}, out)
+		if err != nil {
+			e.setStatus(err.Error())
+			return
+		}
+		if sub.strict && row == selEnd.Row {
+			selEnd.Col = end.Col
+		}
+		replaced += n
+		lines++
+	}
+	if replaced == 0 {
+		e.setStatus("pattern not found: " + sub.re.String())
+		return
+	}
+	if hasSel {
+		selEnd.Col = min(selEnd.Col, len(e.lines[selEnd.Row]))
+		e.selectionStart, e.selectionEnd = selStart, selEnd
+		e.cursor = selEnd
+	}
+	e.clampCursorCol()
+	e.setStatus(fmt.Sprintf("%d %s on %d %s", replaced, plural(replaced, "substitution", "substitutions"), lines, plural(lines, "line", "lines")))
+}
+
+// replaceMatches replaces the first match of re in s, or all of them, and
+// returns the result and the number of matches replaced
+func replaceMatches(re *regexp.Regexp, s, repl string, all bool) (string, int) {
+	limit := 1
+	if all {
+		limit = -1
+	}
+	matches := re.FindAllStringSubmatchIndex(s, limit)
+	if len(matches) == 0 {
+		return s, 0
+	}
+	var out []byte
+	prev := 0
+	for _, m := range matches {
+		out = append(out, s[prev:m[0]]...)
+		out = re.ExpandString(out, repl, s, m)
+		prev = m[1]
+	}
+	return string(append(out, s[prev:]...)), len(matches)
+}
+
+// plural returns one when n is 1 and many otherwise
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
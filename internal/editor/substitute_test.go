@@ -0,0 +1,58 @@
+package editor
+
+import "testing"
+
+func TestCountInSelection(t *testing.T) {
+	e := newTestEditor("foo bar foo", "foo")
+	e.execCommand(`count fo+`)
+	if e.statusMessage != "3 matches in buffer" {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+	e.selectionActive = true
+	e.selectionStart = Cursor{Row: 0, Col: 4}
+	e.selectionEnd = Cursor{Row: 1, Col: 2}
+	e.execCommand(`count \bfo`)
+	if e.statusMessage != "2 matches in selection" {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	e := newTestEditor("a-a-a", "a-a")
+	e.execCommand("s/a/b/")
+	if got := e.Content(); got != "b-a-a\na-a" {
+		t.Fatalf("cursor line: %q", got)
+	}
+	e.execCommand(`%s/(\w)-/${1}_/g`)
+	if got := e.Content(); got != "b_a_a\na_a" {
+		t.Fatalf("whole buffer: %q", got)
+	}
+	e.Undo()
+	if got := e.Content(); got != "b-a-a\na-a" {
+		t.Fatalf("after undo: %q", got)
+	}
+	e.execCommand("s/x/y/q")
+	if e.statusMessage != `unknown :s flag 'q' (use g, i, s)` {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+}
+
+func TestSubstituteInsideSelectionOnly(t *testing.T) {
+	e := newTestEditor("aaa aaa aaa", "aaa")
+	e.selectionActive = true
+	e.selectionStart = Cursor{Row: 0, Col: 4}
+	e.selectionEnd = Cursor{Row: 0, Col: 7}
+	e.execCommand("s/A/bb/gis")
+	if got := e.Content(); got != "aaa bbbbbb aaa\naaa" {
+		t.Fatalf("content %q", got)
+	}
+	if e.selectionStart != (Cursor{Row: 0, Col: 4}) || e.selectionEnd != (Cursor{Row: 0, Col: 10}) {
+		t.Fatalf("selection %+v-%+v", e.selectionStart, e.selectionEnd)
+	}
+
+	// Without s the whole selected lines are substituted
+	e.execCommand("s/a/c/")
+	if got := e.Content(); got != "caa bbbbbb aaa\naaa" {
+		t.Fatalf("content %q", got)
+	}
+}
@@ -0,0 +1,137 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// tabPage is a working set: its own buffer list, the file open in it and
+// the sidebar next to it. The active page lives in the editor fields;
+// its entry in e.tabs is only filled in when switching away.
+type tabPage struct {
+	buffers []bufferEntry
+	view    bufferView     // open file ("" for an unnamed buffer) and where it was left
+	sidebar SidebarContent // sidebar content, nil when the sidebar was closed
+}
+
+// tabCount returns the number of tab pages; there is always at least one
+func (e *Editor) tabCount() int {
+	return max(len(e.tabs), 1)
+}
+
+// saveTab stores the editor state in the active tab page
+func (e *Editor) saveTab() {
+	if len(e.tabs) == 0 {
+		e.tabs = []tabPage{{}}
+	}
+	e.rememberBufferView()
+	t := &e.tabs[e.tabIndex]
+	t.buffers = e.buffers
+	t.view = bufferView{path: e.filename, cursor: e.cursor, scroll: e.scroll, scrollX: e.scrollX}
+	t.sidebar = nil
+	if e.sidebar != nil && e.sidebar.Visible {
+		t.sidebar = e.sidebar.Content
+	}
+}
+
+// loadTab makes tab page i active. Its file is opened through the
+// open-file request like a buffer switch; a page without a file gets an
+// empty buffer.
+func (e *Editor) loadTab(i int) {
+	e.tabIndex = i
+	t := e.tabs[i]
+	e.buffers = t.buffers
+	if e.sidebar != nil {
+		e.sidebar.Focused = false
+		e.sidebar.Visible = t.sidebar != nil
+		if t.sidebar != nil {
+			e.sidebar.Content = t.sidebar
+		}
+	}
+	view := t.view
+	switch {
+	case view.path == "":
+		if e.filename != "" {
+			e.saveSessionState()
+			e.closedBufferPath = e.filename
+		}
+		e.clearBuffer()
+	case bufferKey(view.path) == bufferKey(e.filename):
+		e.reopening = &view
+		e.reopening.path = e.filename
+		e.restoreBufferView()
+	default:
+		e.reopening = &view
+		e.openFileRequest = view.path
+	}
+	e.setStatus(fmt.Sprintf("tab %d/%d", i+1, e.tabCount()))
+}
+
+// newTab opens an empty tab page after the active one (:tabnew). With a
+// path, the file is opened in it.
+func (e *Editor) newTab(path string) {
+	if e.dirty {
+		e.setStatus("unsaved changes (use :w first)")
+		return
+	}
+	e.saveTab()
+	at := e.tabIndex + 1
+	e.tabs = append(e.tabs[:at], append([]tabPage{{}}, e.tabs[at:]...)...)
+	e.loadTab(at)
+	if path != "" {
+		e.openFileRequest = path
+	}
+}
+
+// closeTab closes the active tab page (:tabclose) and moves to the one
+// that takes its place
+func (e *Editor) closeTab() {
+	if e.tabCount() == 1 {
+		e.setStatus("only one tab page")
+		return
+	}
+	if e.dirty {
+		e.setStatus("unsaved changes (use :w first)")
+		return
+	}
+	e.saveSessionState()
+	e.tabs = append(e.tabs[:e.tabIndex], e.tabs[e.tabIndex+1:]...)
+	e.loadTab(min(e.tabIndex, len(e.tabs)-1))
+}
+
+// switchTab makes tab page i active
+func (e *Editor) switchTab(i int) {
+	if i < 0 || i >= len(e.tabs) || i == e.tabIndex {
+		return
+	}
+	if e.dirty {
+		e.setStatus("unsaved changes (use :w first)")
+		return
+	}
+	e.saveTab()
+	e.loadTab(i)
+}
+
+// cycleTab switches to the next (delta 1) or previous (delta -1) tab page,
+// wrapping around (gt, gT)
+func (e *Editor) cycleTab(delta int) {
+	n := e.tabCount()
+	if n == 1 {
+		e.setStatus("only one tab page")
+		return
+	}
+	e.switchTab(((e.tabIndex+delta)%n + n) % n)
+}
+
+// tabLabel is the bufferline label of tab page i: its number and file
+func (e *Editor) tabLabel(i int) string {
+	path := e.filename
+	if i != e.tabIndex {
+		path = e.tabs[i].view.path
+	}
+	name := "[No Name]"
+	if path != "" {
+		name = filepath.Base(path)
+	}
+	return fmt.Sprintf(" %d:%s ", i+1, name)
+}
@@ -0,0 +1,83 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTabPages(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := newTestEditor()
+	e.DisableState()
+	e.execCommand("tabn")
+	if e.statusMessage != "only one tab page" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	if err := e.OpenFile(a); err != nil {
+		t.Fatal(err)
+	}
+	e.cursor = Cursor{Row: 2, Col: 1}
+
+	e.execCommand("tabnew")
+	if e.filename != "" || len(e.buffers) != 0 || e.statusMessage != "tab 2/2" {
+		t.Fatalf("new tab: filename %q buffers %d status %q", e.filename, len(e.buffers), e.statusMessage)
+	}
+	if got := e.ConsumeClosedBuffer(); got != a {
+		t.Fatalf("ConsumeClosedBuffer() = %q, want %q", got, a)
+	}
+	if err := e.OpenFile(b); err != nil {
+		t.Fatal(err)
+	}
+	e.cursor = Cursor{Row: 1, Col: 0}
+
+	// Back to the first page: its file comes back where it was left
+	e.handleGotoKey('T')
+	if err := e.OpenFile(e.ConsumeOpenFileRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if e.filename != a || e.cursor != (Cursor{Row: 2, Col: 1}) || e.tabIndex != 0 {
+		t.Fatalf("tab 1: filename %q cursor %v tab %d", e.filename, e.cursor, e.tabIndex)
+	}
+	if got := bufferOrder(e); got != "a.txt" {
+		t.Fatalf("tab 1 buffers = %q", got)
+	}
+
+	e.insertRune('x')
+	e.handleGotoKey('t')
+	if e.tabIndex != 0 || e.statusMessage != "unsaved changes (use :w first)" {
+		t.Fatalf("dirty switch: tab %d status %q", e.tabIndex, e.statusMessage)
+	}
+	e.Undo()
+	e.dirty = false
+
+	e.execCommand("tabclose")
+	if err := e.OpenFile(e.ConsumeOpenFileRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if e.filename != b || e.cursor != (Cursor{Row: 1, Col: 0}) || e.tabCount() != 1 {
+		t.Fatalf("after close: filename %q cursor %v tabs %d", e.filename, e.cursor, e.tabCount())
+	}
+	e.execCommand("tabclose")
+	if e.statusMessage != "only one tab page" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestTabNewWithFile(t *testing.T) {
+	e := testBuffers()
+	e.execCommand("tabnew notes.md")
+	if e.tabCount() != 2 || e.tabIndex != 1 || e.openFileRequest != "notes.md" {
+		t.Fatalf("tabs %d index %d request %q", e.tabCount(), e.tabIndex, e.openFileRequest)
+	}
+	if got := e.tabLabel(0); got != " 1:c " {
+		t.Fatalf("tab 1 label = %q", got)
+	}
+}
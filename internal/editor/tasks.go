@@ -0,0 +1,134 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kobzarvs/qedit/internal/tasks"
+)
+
+// shutdownTimeout bounds how long Shutdown waits for canceled tasks to
+// return, so a stuck one can't hold up quitting
+const shutdownTimeout = 2 * time.Second
+
+// SetTaskRegistry sets the registry long-running jobs run in. Without one
+// they run synchronously, which is what tests rely on.
+func (e *Editor) SetTaskRegistry(r *tasks.Registry) {
+	e.tasks = r
+}
+
+// RunTask runs work as a task named name. work runs off the UI goroutine
+// and returns a function that applies its result to the editor; that
+// function runs in PollTasks, unless the task was canceled.
+func (e *Editor) RunTask(name string, work func(ctx context.Context) func()) {
+	if e.tasks == nil {
+		if apply := work(context.Background()); apply != nil {
+			apply()
+		}
+		return
+	}
+	e.tasks.Go(name, work)
+}
+
+// runBufferTask is RunTask for work on the open file. The task belongs to
+// the buffer and is canceled when the buffer is closed or replaced.
+func (e *Editor) runBufferTask(name string, work func(ctx context.Context) func()) {
+	if e.tasks == nil || e.filename == "" {
+		e.RunTask(name, work)
+		return
+	}
+	e.tasks.GoFor(bufferKey(e.filename), name, work)
+}
+
+// cancelBufferTasks cancels the tasks of the open file, before it leaves
+// the editor
+func (e *Editor) cancelBufferTasks() {
+	if e.tasks != nil && e.filename != "" {
+		e.tasks.CancelOwner(bufferKey(e.filename))
+	}
+}
+
+// PollTasks applies the results of finished tasks and reports whether
+// there were any
+func (e *Editor) PollTasks() bool {
+	if e.tasks == nil {
+		return false
+	}
+	done := e.tasks.Drain()
+	for _, apply := range done {
+		apply()
+	}
+	return len(done) > 0
+}
+
+// taskStatus is the statusline segment for running tasks: a spinner and
+// the newest task, or "" when nothing runs
+func (e *Editor) taskStatus(now time.Time) string {
+	if e.tasks == nil {
+		return ""
+	}
+	running := e.tasks.Running()
+	if len(running) == 0 {
+		return ""
+	}
+	status := fmt.Sprintf("%c %s", tasks.Spinner(now), running[len(running)-1].Name)
+	if len(running) > 1 {
+		status += fmt.Sprintf(" +%d", len(running)-1)
+	}
+	return status
+}
+
+// execTasksCommand runs :tasks, which lists the running tasks, and
+// :tasks cancel [ID], which cancels one (the newest without an ID)
+func (e *Editor) execTasksCommand(args []string) {
+	var running []tasks.Info
+	if e.tasks != nil {
+		running = e.tasks.Running()
+	}
+	if len(args) == 0 {
+		if len(running) == 0 {
+			e.setStatus("no running tasks")
+			return
+		}
+		parts := make([]string, len(running))
+		for i, t := range running {
+			parts[i] = fmt.Sprintf("%d %s (%s)", t.ID, t.Name, time.Since(t.Started).Truncate(time.Second))
+		}
+		e.setStatus("tasks: " + strings.Join(parts, ", "))
+		return
+	}
+	if args[0] != "cancel" || len(args) > 2 {
+		e.setStatus("usage: :tasks [cancel [ID]]")
+		return
+	}
+	if len(running) == 0 {
+		e.setStatus("no running tasks")
+		return
+	}
+	target := running[len(running)-1]
+	if len(args) == 2 {
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			e.setStatus("invalid task ID: " + args[1])
+			return
+		}
+		found := false
+		for _, t := range running {
+			if t.ID == id {
+				target, found = t, true
+			}
+		}
+		if !found {
+			e.setStatus(fmt.Sprintf("no task %d", id))
+			return
+		}
+	}
+	if e.tasks.Cancel(target.ID) {
+		e.setStatus(fmt.Sprintf("canceled %d %s", target.ID, target.Name))
+	} else {
+		e.setStatus(fmt.Sprintf("task %d already finished", target.ID))
+	}
+}
@@ -0,0 +1,149 @@
+package editor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kobzarvs/qedit/internal/tasks"
+)
+
+// newTaskEditor returns an editor running tasks in a registry and a channel
+// that receives a value whenever a task finishes
+func newTaskEditor(lines ...string) (*Editor, chan struct{}) {
+	done := make(chan struct{}, 8)
+	e := newTestEditor(lines...)
+	e.SetTaskRegistry(tasks.New(func() { done <- struct{}{} }))
+	return e, done
+}
+
+func waitTask(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not finish")
+	}
+}
+
+func TestLSPGotoRunsAsTask(t *testing.T) {
+	e, done := newTaskEditor("one", "two", "three")
+	e.filename = "test.go"
+	release := make(chan struct{})
+	e.lspGotoFunc = func(_ context.Context, method, path string, line, col int) ([]LSPLocation, error) {
+		<-release
+		return []LSPLocation{{Path: "test.go", StartLine: 2}}, nil
+	}
+	e.lspGoto("definition")
+	if !strings.HasSuffix(e.taskStatus(time.Now()), " LSP definition") {
+		t.Fatalf("task status = %q", e.taskStatus(time.Now()))
+	}
+	e.execCommand("tasks")
+	if !strings.HasPrefix(e.statusMessage, "tasks: 1 LSP definition (") {
+		t.Fatalf(":tasks status = %q", e.statusMessage)
+	}
+
+	close(release)
+	waitTask(t, done)
+	if e.cursor.Row != 0 {
+		t.Fatalf("result applied before PollTasks")
+	}
+	if !e.PollTasks() || e.cursor.Row != 2 {
+		t.Fatalf("cursor row = %d after PollTasks, want 2", e.cursor.Row)
+	}
+	if e.taskStatus(time.Now()) != "" {
+		t.Fatalf("task status after finish = %q", e.taskStatus(time.Now()))
+	}
+}
+
+func TestLSPGotoDroppedAfterFileChange(t *testing.T) {
+	e, done := newTaskEditor("one", "two", "three")
+	e.filename = "a.go"
+	release := make(chan struct{})
+	e.lspGotoFunc = func(_ context.Context, method, path string, line, col int) ([]LSPLocation, error) {
+		<-release
+		return []LSPLocation{{Path: "a.go", StartLine: 2}}, nil
+	}
+	e.lspGoto("definition")
+	e.filename = "b.go"
+	close(release)
+	waitTask(t, done)
+	e.PollTasks()
+	if e.cursor.Row != 0 {
+		t.Fatalf("stale result moved the cursor to row %d", e.cursor.Row)
+	}
+}
+
+func TestTasksCancel(t *testing.T) {
+	e, done := newTaskEditor("x")
+	e.execCommand("tasks cancel")
+	if e.statusMessage != "no running tasks" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.RunTask("slow", func(ctx context.Context) func() {
+		<-ctx.Done()
+		return func() { e.setStatus("applied") }
+	})
+	e.RunTask("other", func(ctx context.Context) func() {
+		<-ctx.Done()
+		return nil
+	})
+	if got := e.taskStatus(time.Now()); !strings.HasSuffix(got, " other +1") {
+		t.Fatalf("task status = %q", got)
+	}
+
+	e.execCommand("tasks cancel 9")
+	if e.statusMessage != "no task 9" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.execCommand("tasks cancel 1")
+	if e.statusMessage != "canceled 1 slow" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	e.execCommand("tasks cancel")
+	if e.statusMessage != "canceled 2 other" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+	waitTask(t, done)
+	waitTask(t, done)
+	e.PollTasks()
+	if e.statusMessage == "applied" {
+		t.Fatalf("canceled task result applied")
+	}
+	e.execCommand("tasks stop")
+	if e.statusMessage != "usage: :tasks [cancel [ID]]" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestBufferTasksCanceledOnClose(t *testing.T) {
+	e, done := newTaskEditor("one", "two", "three")
+	e.DisableState()
+	e.filename = "a.go"
+	canceled := make(chan struct{})
+	e.lspGotoFunc = func(ctx context.Context, method, path string, line, col int) ([]LSPLocation, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+	e.lspGoto("references")
+	e.RunTask("git checkout main", func(ctx context.Context) func() {
+		<-ctx.Done()
+		return nil
+	})
+	e.execCommand("bd")
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("LSP request not canceled when its buffer closed")
+	}
+	waitTask(t, done)
+	if running := e.tasks.Running(); len(running) != 1 || running[0].Name != "git checkout main" {
+		t.Fatalf("running after :bd = %+v", running)
+	}
+	e.Shutdown()
+	if running := e.tasks.Running(); len(running) != 0 {
+		t.Fatalf("running after Shutdown = %+v", running)
+	}
+}
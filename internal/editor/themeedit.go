@@ -0,0 +1,210 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/config"
+)
+
+// maxThemePreviewWidth bounds the width of the theme preview pane
+const maxThemePreviewWidth = 48
+
+// themePreview is the state of the :theme-edit preview pane
+type themePreview struct {
+	path   string // theme file the pane previews
+	tick   uint64 // change tick colors were computed at
+	colors map[string]tcell.Color
+	err    error // parse error of the buffer, the pane keeps the last good colors
+}
+
+// previewToken is a run of sample text drawn with the color of kind
+type previewToken struct {
+	text string
+	kind string // theme key of the foreground, "" for the plain foreground
+}
+
+// themePreviewSample is the code shown in the preview pane
+var themePreviewSample = [][]previewToken{
+	{{"// A sample of the theme", "syntax-comment"}},
+	{{"package", "syntax-keyword"}, {" main", ""}},
+	{},
+	{{"type", "syntax-keyword"}, {" ", ""}, {"Point", "syntax-type"}, {" ", ""}, {"struct", "syntax-keyword"}, {" {", "syntax-punctuation"}},
+	{{"    X, Y ", "syntax-field"}, {"int", "syntax-builtin"}},
+	{{"}", "syntax-punctuation"}},
+	{},
+	{{"const", "syntax-keyword"}, {" limit ", "syntax-constant"}, {"=", "syntax-operator"}, {" 42", "syntax-number"}},
+	{},
+	{{"func", "syntax-keyword"}, {" ", ""}, {"scale", "syntax-function"}, {"(", "syntax-punctuation"}, {"p", "syntax-parameter"}, {" Point", "syntax-type"}, {")", "syntax-punctuation"}, {" {", "syntax-punctuation"}},
+	{{"    msg ", "syntax-variable"}, {":=", "syntax-operator"}, {" ", ""}, {`"selected text"`, "syntax-string"}},
+	{{"    ", ""}, {"fmt", "syntax-variable"}, {".", "syntax-punctuation"}, {"Println", "syntax-function"}, {"(msg, p.X ", ""}, {"*", "syntax-operator"}, {" limit)", ""}},
+	{{"}", "syntax-punctuation"}},
+}
+
+// Rows of the sample drawn with the selection, the search match and the
+// cursor line number
+const (
+	previewSelectionRow = 10
+	previewSearchRow    = 11
+	previewCursorRow    = 11
+)
+
+// execThemeEditCommand opens a theme file with a pane previewing it
+// (:theme-edit [NAME], the active theme by default). The pane follows the
+// buffer's text, so changed colors show up before the file is saved.
+func (e *Editor) execThemeEditCommand(args []string) {
+	name := e.theme.Theme
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		e.setStatus(`no theme set: :theme-edit NAME, or theme = "NAME" in config.toml`)
+		return
+	}
+	path, err := config.ThemePath(name)
+	if err != nil {
+		e.setStatus("theme-edit: " + err.Error())
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		e.setStatus("theme-edit: no theme file " + path)
+		return
+	}
+	if !e.isThemePreviewBuffer(path) {
+		if e.dirty {
+			e.setStatus("unsaved changes (use :w first)")
+			return
+		}
+		e.openFileRequest = path
+	}
+	e.themePreview = themePreview{path: path}
+	e.openPopup(themePreviewPopup{})
+}
+
+// isThemePreviewBuffer reports whether the open buffer is the theme file
+// at path
+func (e *Editor) isThemePreviewBuffer(path string) bool {
+	if e.filename == "" {
+		return false
+	}
+	abs, err := filepath.Abs(e.filename)
+	return err == nil && abs == path
+}
+
+// themePreviewColors returns the colors of the theme in the buffer, over
+// the active theme for keys it doesn't set
+func (e *Editor) themePreviewColors() map[string]tcell.Color {
+	p := &e.themePreview
+	if p.colors != nil && p.tick == e.changeTick {
+		return p.colors
+	}
+	p.tick = e.changeTick
+	theme, err := config.ParseTheme(e.Content())
+	p.err = err
+	if err == nil || p.colors == nil {
+		merged := e.theme
+		config.MergeTheme(&merged, theme)
+		p.colors = themeColors(merged)
+	}
+	return p.colors
+}
+
+// themePreviewPopup is the preview pane of :theme-edit, drawn at the right
+// like a split while the theme file is the open buffer. Keys go to the
+// editor; Esc in normal mode closes it.
+type themePreviewPopup struct{}
+
+func (themePreviewPopup) handleKey(*Editor, *tcell.EventKey) bool { return false }
+
+func (themePreviewPopup) render(e *Editor, s tcell.Screen, w, viewHeight int) {
+	if e.isThemePreviewBuffer(e.themePreview.path) {
+		e.renderThemePreview(s, w, viewHeight)
+	}
+}
+
+func (themePreviewPopup) dismissed(e *Editor) {
+	e.themePreview = themePreview{}
+}
+
+func (themePreviewPopup) modal() bool { return false }
+
+// renderThemePreview draws the sample code, a diagnostic, a statusline and
+// a command line in the colors of the theme being edited
+func (e *Editor) renderThemePreview(s tcell.Screen, w, viewHeight int) {
+	width := min(maxThemePreviewWidth, w/2)
+	if width < 20 || viewHeight < 4 {
+		return
+	}
+	colors := e.themePreviewColors()
+	x0 := w - width
+	main := tcell.StyleDefault.Foreground(colors["foreground"]).Background(colors["background"])
+	fg := func(key string) tcell.Style {
+		if key == "" {
+			return main
+		}
+		return main.Foreground(colors[key])
+	}
+	fill := func(y int, style tcell.Style) {
+		for x := x0 + 1; x < w; x++ {
+			s.SetContent(x, y, ' ', nil, style)
+		}
+	}
+	put := func(x, y int, text string, style tcell.Style) int {
+		for _, r := range text {
+			if x < w {
+				s.SetContent(x, y, r, nil, style)
+			}
+			x++
+		}
+		return x
+	}
+
+	for y := 0; y < viewHeight; y++ {
+		s.SetContent(x0, y, '│', nil, e.styleLineNumber)
+		fill(y, main)
+	}
+	title := " Preview "
+	if e.themePreview.err != nil {
+		title = " Preview (parse error, last good colors) "
+	}
+	put(x0+1, 0, title, fg("diagnostic-error-foreground").Bold(e.themePreview.err != nil))
+
+	y := 1
+	for i, tokens := range themePreviewSample {
+		if y >= viewHeight-2 {
+			break
+		}
+		num := fg("line-number-foreground")
+		if i == previewCursorRow {
+			num = fg("line-number-active-foreground")
+		}
+		x := put(x0+1, y, fmt.Sprintf("%3d ", i+1), num)
+		for _, tok := range tokens {
+			style := fg(tok.kind)
+			switch {
+			case i == previewSelectionRow && tok.kind == "syntax-string":
+				style = main.Foreground(colors["selection-foreground"]).Background(colors["selection-background"])
+			case i == previewSearchRow && tok.text == "Println":
+				style = main.Foreground(colors["search-foreground"]).Background(colors["search-background"])
+			}
+			x = put(x, y, tok.text, style)
+		}
+		y++
+	}
+	if y < viewHeight-2 {
+		x := put(x0+1, y, "    ● error", fg("diagnostic-error-foreground"))
+		x = put(x+1, y, "warning", fg("diagnostic-warning-foreground"))
+		x = put(x+1, y, "info", fg("diagnostic-info-foreground"))
+		put(x+1, y, "hint", fg("diagnostic-hint-foreground"))
+	}
+
+	status := tcell.StyleDefault.Foreground(colors["statusline-foreground"]).Background(colors["statusline-background"])
+	fill(viewHeight-2, status)
+	put(x0+2, viewHeight-2, "NORMAL | sample.go", status)
+	command := tcell.StyleDefault.Foreground(colors["commandline-foreground"]).Background(colors["commandline-background"])
+	fill(viewHeight-1, command)
+	put(x0+1, viewHeight-1, ":theme-edit", command)
+}
@@ -0,0 +1,77 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestThemeEditPreview(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("QEDIT_CONFIG_HOME", dir)
+	path := filepath.Join(dir, "theme", "dark.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("syntax-keyword = \"#112233\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := newTestEditor("")
+	e.theme.Theme = "dark"
+	e.execCommand("theme-edit")
+	if got := e.ConsumeOpenFileRequest(); got != path {
+		t.Fatalf("open request = %q, want %q (status %q)", got, path, e.statusMessage)
+	}
+	if err := e.OpenFile(path); err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	// "package" on the second sample row is a keyword
+	keywordColor := func() tcell.Color {
+		s, rows := renderRows(t, e, 100, 24)
+		x := strings.Index(rows[2], "package")
+		if x < 0 {
+			t.Fatalf("preview not drawn:\n%s", strings.Join(rows, "\n"))
+		}
+		_, _, style, _ := s.GetContent(len([]rune(rows[2][:x])), 2)
+		fg, _, _ := style.Decompose()
+		return fg
+	}
+	if got := keywordColor(); got != tcell.NewHexColor(0x112233) {
+		t.Fatalf("keyword color = %v, want #112233", got)
+	}
+
+	// An unsaved edit shows up at once
+	e.lines[0] = []rune(`syntax-keyword = "#445566"`)
+	e.changeTick++
+	if got := keywordColor(); got != tcell.NewHexColor(0x445566) {
+		t.Fatalf("keyword color after edit = %v, want #445566", got)
+	}
+
+	// A broken file keeps the last good colors
+	e.lines[0] = []rune(`syntax-keyword = "#7788`)
+	e.changeTick++
+	if got := keywordColor(); got != tcell.NewHexColor(0x445566) {
+		t.Fatalf("keyword color with parse error = %v, want the last good #445566", got)
+	}
+	if e.themePreview.err == nil {
+		t.Fatalf("parse error not recorded")
+	}
+}
+
+func TestThemeEditNoTheme(t *testing.T) {
+	t.Setenv("QEDIT_CONFIG_HOME", t.TempDir())
+	e := newTestEditor("")
+	e.execCommand("theme-edit")
+	if !strings.HasPrefix(e.statusMessage, "no theme set") || e.popupOpen(themePreviewPopup{}) {
+		t.Fatalf("status = %q, popup open %v", e.statusMessage, e.popupOpen(themePreviewPopup{}))
+	}
+	e.execCommand("theme-edit missing")
+	if !strings.HasPrefix(e.statusMessage, "theme-edit: no theme file") {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
@@ -0,0 +1,269 @@
+package editor
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+const (
+	actionTodoToggle   = "todo_toggle"    // :todo toggle - check/uncheck the markdown task
+	actionTodoMoveUp   = "todo_move_up"   // :todo up - move the task above its previous sibling
+	actionTodoMoveDown = "todo_move_down" // :todo down - move the task below its next sibling
+)
+
+// listItem parses a markdown list item: the columns of its indent and of
+// the text after the marker
+func listItem(line []rune) (indent, text int, ok bool) {
+	indent = firstNonBlank(line)
+	col := indent
+	switch {
+	case col < len(line) && (line[col] == '-' || line[col] == '*' || line[col] == '+'):
+		col++
+	default:
+		for col < len(line) && unicode.IsDigit(line[col]) {
+			col++
+		}
+		if col == indent || col-indent > 9 || col >= len(line) || (line[col] != '.' && line[col] != ')') {
+			return 0, 0, false
+		}
+		col++
+	}
+	if col == len(line) {
+		return indent, col, true
+	}
+	if line[col] != ' ' && line[col] != '\t' {
+		return 0, 0, false
+	}
+	for col < len(line) && (line[col] == ' ' || line[col] == '\t') {
+		col++
+	}
+	return indent, col, true
+}
+
+// taskBox is the box of a task list item: its row, the column of its
+// check character and whether it is checked
+type taskBox struct {
+	row     int
+	check   int
+	checked bool
+}
+
+// taskBoxes returns the task boxes on rows first to last from the syntax
+// tree's task list markers, false when the file has no tree
+func (e *Editor) taskBoxes(first, last int) ([]taskBox, bool) {
+	if e.taskMarkersFunc == nil || e.filename == "" {
+		return nil, false
+	}
+	markers, ok := e.taskMarkersFunc(e.filename, first, last)
+	if !ok {
+		return nil, false
+	}
+	var boxes []taskBox
+	for _, m := range markers {
+		line := e.lineAt(m.Row)
+		col := textpos.ByteToRune(line, m.Col)
+		// A tree a step behind the text may point off a box
+		if col+2 >= len(line) || line[col] != '[' || line[col+2] != ']' {
+			continue
+		}
+		switch line[col+1] {
+		case ' ', 'x', 'X':
+			boxes = append(boxes, taskBox{row: m.Row, check: col + 1, checked: line[col+1] != ' '})
+		}
+	}
+	return boxes, true
+}
+
+// inCodeFence reports whether row is inside a fenced code block
+func (e *Editor) inCodeFence(row int) bool {
+	in := false
+	for i := 0; i < row && i < len(e.lines); i++ {
+		if isFenceLine(string(e.lines[i])) {
+			in = !in
+		}
+	}
+	return in
+}
+
+// toggleTodo checks or unchecks the task on the cursor line, or the tasks
+// of the selected lines: all of them get checked unless they all are. A
+// list item without a box gets an unchecked one.
+func (e *Editor) toggleTodo() {
+	if !isMarkdownFile(e.filename) {
+		e.setStatus("todo: not a markdown file")
+		return
+	}
+	first, last := e.cursor.Row, e.cursor.Row
+	if start, end, ok := e.selectionRange(); ok {
+		first, last = start.Row, end.Row
+		if end.Col == 0 && end.Row > start.Row {
+			last--
+		}
+	}
+	boxes, ok := e.taskBoxes(first, last)
+	if !ok {
+		e.setStatus("todo: the file isn't parsed")
+		return
+	}
+	check := false
+	boxed := make(map[int]bool, len(boxes))
+	for _, box := range boxes {
+		boxed[box.row] = true
+		check = check || !box.checked
+	}
+	var items []int
+	if !e.inCodeFence(first) {
+		for row := first; row <= last; row++ {
+			if _, _, ok := listItem(e.lines[row]); ok && !boxed[row] {
+				items = append(items, row)
+			}
+		}
+	}
+	if len(boxes) == 0 && len(items) == 0 {
+		e.setStatus("todo: not a task list item")
+		return
+	}
+
+	e.BeginUndoGroup()
+	defer e.EndUndoGroup()
+	if len(boxes) == 0 {
+		// Plain list items become tasks
+		for _, row := range items {
+			_, col, _ := listItem(e.lines[row])
+			_, _ = e.InsertAt(Cursor{Row: row, Col: col}, "[ ] ")
+		}
+		return
+	}
+	cursor := e.cursor
+	mark := " "
+	if check {
+		mark = "x"
+	}
+	for _, box := range boxes {
+		_, _ = e.ReplaceRange(Cursor{Row: box.row, Col: box.check}, Cursor{Row: box.row, Col: box.check + 1}, mark)
+	}
+	e.cursor = cursor
+}
+
+// todoBlock returns the last row of the list item on row: the item with
+// its nested items and continuation lines
+func (e *Editor) todoBlock(row int) int {
+	indent, _, _ := listItem(e.lines[row])
+	end := row
+	for end+1 < len(e.lines) {
+		next := e.lines[end+1]
+		if firstNonBlank(next) == len(next) || firstNonBlank(next) <= indent {
+			break
+		}
+		end++
+	}
+	return end
+}
+
+// todoSibling returns the first row of the list item next to the one on
+// row in direction dir (-1 or 1) at the same indent, false at the edge of
+// the list
+func (e *Editor) todoSibling(row, dir int) (int, bool) {
+	indent, _, _ := listItem(e.lines[row])
+	r := row
+	if dir > 0 {
+		r = e.todoBlock(row)
+	}
+	for {
+		r += dir
+		if r < 0 || r >= len(e.lines) {
+			return 0, false
+		}
+		line := e.lines[r]
+		if firstNonBlank(line) == len(line) {
+			return 0, false // a blank line ends the list
+		}
+		switch in, _, ok := listItem(line); {
+		case ok && in == indent:
+			return r, true
+		case firstNonBlank(line) <= indent:
+			return 0, false
+		}
+	}
+}
+
+// moveTodo moves the list item on the cursor line, with its nested items,
+// past its previous (dir -1) or next (dir 1) sibling
+func (e *Editor) moveTodo(dir int) {
+	if !isMarkdownFile(e.filename) {
+		e.setStatus("todo: not a markdown file")
+		return
+	}
+	row := e.cursor.Row
+	if _, _, ok := listItem(e.lines[row]); !ok || e.inCodeFence(row) {
+		e.setStatus("todo: not a task list item")
+		return
+	}
+	other, ok := e.todoSibling(row, dir)
+	if !ok {
+		return
+	}
+	first, second := row, other
+	if dir < 0 {
+		first, second = other, row
+	}
+	firstEnd, secondEnd := second-1, e.todoBlock(second)
+	a := e.textInRange(Cursor{Row: first}, Cursor{Row: firstEnd, Col: len(e.lines[firstEnd])})
+	b := e.textInRange(Cursor{Row: second}, Cursor{Row: secondEnd, Col: len(e.lines[secondEnd])})
+	col := e.cursor.Col
+	end := Cursor{Row: secondEnd, Col: len(e.lines[secondEnd])}
+	if _, err := e.ReplaceRange(Cursor{Row: first}, end, b+"\n"+a); err != nil {
+		return
+	}
+	// The cursor moves with its item
+	if dir < 0 {
+		row = other
+	} else {
+		row += secondEnd - second + 1
+	}
+	e.cursor = Cursor{Row: row, Col: col}
+	e.clampCursorCol()
+}
+
+// todoProgress returns the statusline's count of checked tasks in the
+// list around the cursor ("2/5 done"), "" outside task lists
+func (e *Editor) todoProgress() string {
+	if e.preview || !isMarkdownFile(e.filename) {
+		return ""
+	}
+	if !e.todoProgressValid || e.todoProgressTick != e.changeTick || e.todoProgressRow != e.cursor.Row {
+		e.todoProgressText = e.todoProgressAtCursor()
+		e.todoProgressTick = e.changeTick
+		e.todoProgressRow = e.cursor.Row
+		e.todoProgressValid = true
+	}
+	return e.todoProgressText
+}
+
+func (e *Editor) todoProgressAtCursor() string {
+	row := e.cursor.Row
+	if row < 0 || row >= len(e.lines) || firstNonBlank(e.lines[row]) == len(e.lines[row]) {
+		return ""
+	}
+	// The list is the paragraph of lines around the cursor
+	first, last := row, row
+	for first > 0 && firstNonBlank(e.lines[first-1]) < len(e.lines[first-1]) && !isFenceLine(string(e.lines[first-1])) {
+		first--
+	}
+	for last+1 < len(e.lines) && firstNonBlank(e.lines[last+1]) < len(e.lines[last+1]) && !isFenceLine(string(e.lines[last+1])) {
+		last++
+	}
+	boxes, _ := e.taskBoxes(first, last)
+	done, total := 0, len(boxes)
+	for _, box := range boxes {
+		if box.checked {
+			done++
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d done", done, total)
+}
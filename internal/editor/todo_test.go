@@ -0,0 +1,143 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kobzarvs/qedit/internal/config"
+	"github.com/kobzarvs/qedit/internal/treesitter"
+)
+
+// useTaskMarkers finds e's task boxes with a tree-sitter parse of its
+// current text
+func useTaskMarkers(e *Editor) {
+	ts := treesitter.New(config.Languages{
+		Languages: []config.Language{{Name: "markdown", FileTypes: []string{"md"}}},
+	})
+	e.taskMarkersFunc = func(path string, startRow, endRow int) ([]TaskMarker, bool) {
+		if !ts.ParseSync(path, "markdown", e.Content()) {
+			return nil, false
+		}
+		markers, ok := ts.TaskMarkers(path, startRow, endRow)
+		result := make([]TaskMarker, len(markers))
+		for i, m := range markers {
+			result[i] = TaskMarker(m)
+		}
+		return result, ok
+	}
+}
+
+func TestTodoToggle(t *testing.T) {
+	e := newTestEditor("- [ ] milk", "- [x] eggs", "* bread", "text")
+	e.filename = "todo.md"
+	useTaskMarkers(e)
+	e.cursor = Cursor{Row: 0, Col: 7}
+	e.execCommand("todo")
+	if got := string(e.lines[0]); got != "- [x] milk" || e.cursor.Col != 7 {
+		t.Fatalf("line %q, cursor %+v", got, e.cursor)
+	}
+	e.execCommand("todo toggle")
+	if got := string(e.lines[0]); got != "- [ ] milk" {
+		t.Fatalf("unchecked: %q", got)
+	}
+
+	// A plain item gets a box
+	e.cursor = Cursor{Row: 2, Col: 3}
+	e.execCommand("todo")
+	if got := string(e.lines[2]); got != "* [ ] bread" || e.cursor.Col != 7 {
+		t.Fatalf("line %q, cursor %+v", got, e.cursor)
+	}
+
+	// A selection is checked as a whole unless it all is, in one undo step
+	e.selectLines(0, 2)
+	e.execCommand("todo")
+	if got := e.Content(); got != "- [x] milk\n- [x] eggs\n* [x] bread\ntext" {
+		t.Fatalf("selection: %q", got)
+	}
+	e.Undo()
+	if got := e.Content(); got != "- [ ] milk\n- [x] eggs\n* [ ] bread\ntext" {
+		t.Fatalf("after undo: %q", got)
+	}
+
+	e.cursor = Cursor{Row: 3}
+	e.clearSelection()
+	e.execCommand("todo")
+	if !strings.Contains(e.statusMessage, "not a task") {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+
+	// A box in a code block isn't a task
+	e = newTestEditor("```", "- [ ] code", "```")
+	e.filename = "todo.md"
+	useTaskMarkers(e)
+	e.cursor = Cursor{Row: 1}
+	e.execCommand("todo")
+	if got := string(e.lines[1]); got != "- [ ] code" || !strings.Contains(e.statusMessage, "not a task") {
+		t.Fatalf("line %q, status %q", got, e.statusMessage)
+	}
+
+	// Without a syntax tree there are no boxes to go by
+	e.taskMarkersFunc = nil
+	e.execCommand("todo")
+	if !strings.Contains(e.statusMessage, "isn't parsed") {
+		t.Fatalf("status %q", e.statusMessage)
+	}
+}
+
+func TestTodoMove(t *testing.T) {
+	e := newTestEditor(
+		"- [ ] a",
+		"  - [ ] a1",
+		"- [ ] b",
+		"  more about b",
+		"- [ ] c",
+		"",
+		"- [ ] other list",
+	)
+	e.filename = "todo.md"
+	e.cursor = Cursor{Row: 2, Col: 4}
+	e.execCommand("todo up")
+	want := "- [ ] b\n  more about b\n- [ ] a\n  - [ ] a1\n- [ ] c\n\n- [ ] other list"
+	if got := e.Content(); got != want || e.cursor != (Cursor{Row: 0, Col: 4}) {
+		t.Fatalf("up: %q, cursor %+v", got, e.cursor)
+	}
+	e.execCommand("todo up")
+	if got := e.Content(); got != want {
+		t.Fatalf("moved past the top: %q", got)
+	}
+
+	e.execCommand("todo down")
+	e.execCommand("todo down")
+	want = "- [ ] a\n  - [ ] a1\n- [ ] c\n- [ ] b\n  more about b\n\n- [ ] other list"
+	if got := e.Content(); got != want || e.cursor.Row != 3 {
+		t.Fatalf("down: %q, cursor %+v", got, e.cursor)
+	}
+	e.execCommand("todo down")
+	if got := e.Content(); got != want {
+		t.Fatalf("moved into the next list: %q", got)
+	}
+
+	// A nested item stays among its siblings
+	e.cursor = Cursor{Row: 1, Col: 4}
+	e.execCommand("todo up")
+	if got := e.Content(); got != want {
+		t.Fatalf("nested item moved out: %q", got)
+	}
+}
+
+func TestTodoProgress(t *testing.T) {
+	e := newTestEditor("# Groceries", "- [x] milk", "- [ ] eggs", "  - [x] brown", "", "```", "- [x] code", "```")
+	e.filename = "todo.md"
+	useTaskMarkers(e)
+	e.cursor = Cursor{Row: 2}
+	_, rows := renderRows(t, e, 80, 12)
+	if status := rows[len(rows)-2]; !strings.Contains(status, "2/3 done | Ln 3") {
+		t.Fatalf("statusline %q", status)
+	}
+	for _, row := range []int{4, 6} {
+		e.cursor = Cursor{Row: row}
+		if p := e.todoProgress(); p != "" {
+			t.Fatalf("row %d: progress %q", row, p)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package editor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// Word completion (Ctrl+X in insert mode outside the config file) lists
+// the words of the buffer. With a syntax tree, the identifiers of the
+// innermost scope around the cursor come first, so the parameters and
+// locals of the enclosing function rank above the file's globals; then
+// words nearer the cursor come before farther ones.
+
+// rankedWord is a buffer word and what ranks it for completion
+type rankedWord struct {
+	name     string
+	depth    int // scope depth from the syntax tree, lower is nearer
+	distance int // lines from the cursor to the nearest occurrence
+}
+
+// bufferWords returns the words of the buffer, best candidates first. The
+// word being typed at the cursor isn't counted as an occurrence.
+func (e *Editor) bufferWords() []string {
+	words := map[string]*rankedWord{}
+	for row, line := range e.lines {
+		for col := 0; col < len(line); {
+			if !isWordChar(line[col]) {
+				col++
+				continue
+			}
+			start := col
+			for col < len(line) && isWordChar(line[col]) {
+				col++
+			}
+			if row == e.cursor.Row && start <= e.cursor.Col && e.cursor.Col <= col {
+				continue
+			}
+			if unicode.IsDigit(line[start]) {
+				continue
+			}
+			name := string(line[start:col])
+			distance := row - e.cursor.Row
+			if distance < 0 {
+				distance = -distance
+			}
+			if w, ok := words[name]; ok {
+				w.distance = min(w.distance, distance)
+			} else {
+				words[name] = &rankedWord{name: name, distance: distance}
+			}
+		}
+	}
+
+	// Words the tree doesn't list as identifiers, such as those in comments
+	// and strings, come after every scope
+	var scopes []ScopeWord
+	if e.scopeWordsFunc != nil && e.filename != "" {
+		col := textpos.RuneToByte(e.lineAt(e.cursor.Row), e.cursor.Col)
+		scopes = e.scopeWordsFunc(e.filename, e.cursor.Row, col)
+	}
+	outside := 0
+	for _, s := range scopes {
+		outside = max(outside, s.Depth+1)
+	}
+	for _, w := range words {
+		w.depth = outside
+	}
+	for _, s := range scopes {
+		if w, ok := words[s.Name]; ok {
+			w.depth = s.Depth
+		}
+	}
+
+	ranked := make([]*rankedWord, 0, len(words))
+	for _, w := range words {
+		ranked = append(ranked, w)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.depth != b.depth {
+			return a.depth < b.depth
+		}
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		return a.name < b.name
+	})
+	names := make([]string, len(ranked))
+	for i, w := range ranked {
+		names[i] = w.name
+	}
+	return names
+}
+
+// wordCompletions returns the words that complete the word before the
+// cursor and the column it starts at
+func (e *Editor) wordCompletions(words []string) (int, []completionItem) {
+	line := e.lines[e.cursor.Row]
+	col := min(e.cursor.Col, len(line))
+	start := wordStart(line, col, isWordChar)
+	prefix := string(line[start:col])
+	var items []completionItem
+	for _, w := range words {
+		if w != prefix && strings.HasPrefix(w, prefix) {
+			items = append(items, completionItem{label: w, insert: w})
+		}
+	}
+	return start, items
+}
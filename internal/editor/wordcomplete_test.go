@@ -0,0 +1,47 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestWordCompletionByDistance(t *testing.T) {
+	e := newTestEditor("value_far", "", "", "value_near", "va")
+	e.cursor = Cursor{Row: 4, Col: 2}
+	e.mode = ModeInsert
+	e.HandleKey(eventForKeyString(t, "ctrl+x"))
+	if !e.popupOpen(completionPopup{}) {
+		t.Fatalf("completion not open, status %q", e.statusMessage)
+	}
+	if got := e.completion.items; len(got) != 2 || got[0].label != "value_near" || got[1].label != "value_far" {
+		t.Fatalf("items %+v", got)
+	}
+	e.HandleKey(keyRune('l'))
+	e.HandleKey(keyRune('u'))
+	e.HandleKey(keyRune('e'))
+	e.HandleKey(keyRune('_'))
+	e.HandleKey(keyRune('f'))
+	e.HandleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	if got := string(e.lines[4]); got != "value_far" {
+		t.Fatalf("line %q", got)
+	}
+}
+
+func TestWordCompletionRanksScopeFirst(t *testing.T) {
+	// counter is nearer in lines, count is the parameter in scope
+	e := newTestEditor("func f(count int) {", "\tco", "}", "var counter = 1")
+	e.filename = "main.go"
+	e.cursor = Cursor{Row: 1, Col: 3}
+	e.mode = ModeInsert
+	e.scopeWordsFunc = func(path string, row, col int) []ScopeWord {
+		if row != 1 || col != 3 {
+			t.Fatalf("scope asked at %d:%d", row, col)
+		}
+		return []ScopeWord{{Name: "count", Depth: 1}, {Name: "counter", Depth: 3}}
+	}
+	e.HandleKey(eventForKeyString(t, "ctrl+x"))
+	if got := e.completion.items; len(got) != 2 || got[0].label != "count" || got[1].label != "counter" {
+		t.Fatalf("items %+v", got)
+	}
+}
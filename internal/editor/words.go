@@ -0,0 +1,121 @@
+package editor
+
+import (
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+// runKind is what a run of a line consists of, for word motions
+type runKind int
+
+const (
+	runSpace runKind = iota
+	runWord
+	runPunct
+)
+
+// wordRuns splits a line into the runs word motions stop at. Words follow
+// Unicode word segmentation (UAX #29), so ideographs are words of their
+// own and combining marks stay with their letter; punctuation and spaces
+// form runs as before. In subword mode words also split at camelCase
+// humps and after underscores.
+type wordRuns struct {
+	kinds  []runKind
+	starts []bool // starts[i]: a run starts at rune i; len(line)+1 entries
+}
+
+func (e *Editor) wordRuns(line []rune) wordRuns {
+	n := len(line)
+	r := wordRuns{kinds: make([]runKind, n), starts: make([]bool, n+1)}
+	for i, ch := range line {
+		switch {
+		case i > 0 && (unicode.In(ch, unicode.Mn, unicode.Me, unicode.Mc) || ch == '\u200d' || unicode.Is(unicode.Variation_Selector, ch)):
+			// Marks and joiners extend the run before them
+			r.kinds[i] = r.kinds[i-1]
+		case unicode.IsSpace(ch):
+			r.kinds[i] = runSpace
+		case isWordRune(ch):
+			r.kinds[i] = runWord
+		default:
+			r.kinds[i] = runPunct
+		}
+	}
+
+	// Word boundaries from Unicode segmentation
+	segment := make([]bool, n+1)
+	rest, state, at := string(line), -1, 0
+	for rest != "" {
+		var word string
+		word, rest, state = uniseg.FirstWordInString(rest, state)
+		at += len([]rune(word))
+		if at <= n {
+			segment[at] = true
+		}
+	}
+
+	r.starts[0], r.starts[n] = true, true
+	for i := 1; i < n; i++ {
+		switch {
+		case r.kinds[i] != r.kinds[i-1]:
+			r.starts[i] = true
+		case r.kinds[i] == runWord:
+			r.starts[i] = segment[i] || (e.subword && subwordStart(line, i))
+		}
+	}
+	return r
+}
+
+// subwordStart reports whether a subword starts at line[i] inside a word:
+// at a hump (camelCase, HTTPServer's S) or after underscores (snake_case)
+func subwordStart(line []rune, i int) bool {
+	prev, cur := line[i-1], line[i]
+	switch {
+	case prev == '_':
+		return cur != '_'
+	case unicode.IsUpper(cur):
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+			return true
+		}
+		// The last capital of an acronym starts the next word
+		return unicode.IsUpper(prev) && i+1 < len(line) && unicode.IsLower(line[i+1])
+	}
+	return false
+}
+
+// kind returns the kind of the run at rune i
+func (r wordRuns) kind(i int) runKind {
+	return r.kinds[i]
+}
+
+// end returns the index after the run that contains rune i
+func (r wordRuns) end(i int) int {
+	i++
+	for i < len(r.kinds) && !r.starts[i] {
+		i++
+	}
+	return i
+}
+
+// start returns the index of the first rune of the run that contains rune i
+func (r wordRuns) start(i int) int {
+	for i > 0 && !r.starts[i] {
+		i--
+	}
+	return i
+}
+
+// toggleSubword switches w/b/e and word deletion between whole words and
+// camelCase/snake_case parts (:subword)
+func (e *Editor) toggleSubword() {
+	e.setSubword(!e.subword)
+}
+
+func (e *Editor) setSubword(on bool) {
+	e.subword = on
+	if on {
+		e.setStatus("subword on")
+	} else {
+		e.setStatus("subword off")
+	}
+}
@@ -0,0 +1,106 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+)
+
+// runStarts lists the indexes where runs of line start
+func runStarts(e *Editor, line string) []int {
+	runs := e.wordRuns([]rune(line))
+	var starts []int
+	for i := 0; i < len(runs.kinds); i++ {
+		if runs.starts[i] {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+func TestWordRuns(t *testing.T) {
+	cases := []struct {
+		line    string
+		subword bool
+		want    []int
+	}{
+		{"foo.bar baz", false, []int{0, 3, 4, 7, 8}},
+		{"a->b", false, []int{0, 1, 3}},
+		{"snake_case", false, []int{0}},
+		{"nai\u0308ve word", false, []int{0, 6, 7}}, // combining diaeresis stays in the word
+		{"日本語", false, []int{0, 1, 2}},              // ideographs are words of their own
+		{"abc日本", false, []int{0, 3, 4}},
+		{"привет мир", false, []int{0, 6, 7}},
+		{"camelCase", true, []int{0, 5}},
+		{"HTTPServer", true, []int{0, 4}},
+		{"snake_case_x", true, []int{0, 6, 11}},
+		{"utf8Decode", true, []int{0, 4}},
+		{"__init__", true, []int{0, 2}},
+		{"camelCase", false, []int{0}},
+	}
+	for _, c := range cases {
+		e := newTestEditor()
+		e.subword = c.subword
+		if got := runStarts(e, c.line); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("runs(%q, subword=%v) = %v, want %v", c.line, c.subword, got, c.want)
+		}
+	}
+}
+
+func TestSubwordMotions(t *testing.T) {
+	e := newTestEditor("parseHTTPHeader snake_case")
+	e.execCommand("subword on")
+	if e.statusMessage != "subword on" || !e.subword {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+
+	var stops []int
+	for i := 0; i < 5; i++ {
+		e.wordForward()
+		stops = append(stops, e.cursor.Col)
+	}
+	if want := []int{5, 9, 16, 22, 26}; !reflect.DeepEqual(stops, want) {
+		t.Fatalf("w stops = %v, want %v", stops, want)
+	}
+
+	e.cursor.Col = 25
+	e.wordBackward()
+	if e.cursor.Col != 22 {
+		t.Fatalf("b from the end = %d, want 22", e.cursor.Col)
+	}
+	e.cursor.Col = 0
+	e.wordEnd()
+	if e.cursor.Col != 4 {
+		t.Fatalf("e = %d, want 4", e.cursor.Col)
+	}
+
+	// Word deletion uses the same parts
+	e.cursor.Col = 0
+	e.deleteWordRight()
+	if got := e.Content(); got != "HTTPHeader snake_case" {
+		t.Fatalf("delete word right = %q", got)
+	}
+
+	e.execCommand("subword off")
+	e.cursor.Col = 0
+	e.deleteWordRight()
+	if got := e.Content(); got != "snake_case" {
+		t.Fatalf("delete whole word = %q", got)
+	}
+	e.execCommand("subword maybe")
+	if e.statusMessage != "usage: :subword [on|off]" {
+		t.Fatalf("status = %q", e.statusMessage)
+	}
+}
+
+func TestWordMotionsUnicode(t *testing.T) {
+	e := newTestEditor("日本語 cafe\u0301 x")
+	e.wordForward()
+	if e.cursor.Col != 1 {
+		t.Fatalf("w over ideographs = %d, want 1", e.cursor.Col)
+	}
+	e.cursor.Col = 4
+	e.wordEnd()
+	if e.cursor.Col != 8 {
+		t.Fatalf("e over a combining accent = %d, want 8", e.cursor.Col)
+	}
+}
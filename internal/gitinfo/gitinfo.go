@@ -2,6 +2,7 @@ package gitinfo
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"os"
 	"os/exec"
@@ -9,6 +10,17 @@ import (
 	"strings"
 )
 
+// safeConfig keeps git from running programs the repository names in its
+// own config: the fsmonitor daemon and the hooks (post-checkout and the
+// like). A project unpacked from an archive brings its .git/config along.
+var safeConfig = []string{"-c", "core.fsmonitor=", "-c", "core.hooksPath=" + os.DevNull}
+
+// gitCommand returns git running args in the repository at root
+func gitCommand(ctx context.Context, root string, args ...string) *exec.Cmd {
+	full := append([]string{"-C", root}, safeConfig...)
+	return exec.CommandContext(ctx, "git", append(full, args...)...)
+}
+
 func Branch(path string) string {
 	gitDir, err := findGitDir(path)
 	if err != nil || gitDir == "" {
@@ -34,7 +46,7 @@ func ListBranches(path string) ([]string, string, error) {
 	if root == "" {
 		return nil, "", errors.New("not a git repository")
 	}
-	out, err := exec.Command("git", "-C", root, "branch", "--format=%(refname:short)").CombinedOutput()
+	out, err := gitCommand(context.Background(), root, "branch", "--format=%(refname:short)").CombinedOutput()
 	if err != nil {
 		return nil, "", errors.New(strings.TrimSpace(string(out)))
 	}
@@ -51,11 +63,16 @@ func ListBranches(path string) ([]string, string, error) {
 }
 
 func Checkout(path, branch string) error {
+	return CheckoutContext(context.Background(), path, branch)
+}
+
+// CheckoutContext is Checkout that kills git when ctx is canceled
+func CheckoutContext(ctx context.Context, path, branch string) error {
 	root := Root(path)
 	if root == "" {
 		return errors.New("not a git repository")
 	}
-	out, err := exec.Command("git", "-C", root, "checkout", branch).CombinedOutput()
+	out, err := gitCommand(ctx, root, "checkout", branch).CombinedOutput()
 	if err != nil {
 		msg := strings.TrimSpace(string(out))
 		if msg == "" {
@@ -74,7 +91,7 @@ func MainBranch(path string) string {
 	}
 
 	// Try to get the default branch from remote origin
-	out, err := exec.Command("git", "-C", root, "symbolic-ref", "refs/remotes/origin/HEAD").CombinedOutput()
+	out, err := gitCommand(context.Background(), root, "symbolic-ref", "refs/remotes/origin/HEAD").CombinedOutput()
 	if err == nil {
 		// Output is like "refs/remotes/origin/main"
 		ref := strings.TrimSpace(string(out))
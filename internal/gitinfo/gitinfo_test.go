@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -83,6 +84,43 @@ func TestListBranchesAndCheckout(t *testing.T) {
 	}
 }
 
+func TestRepositoryProgramsDontRun(t *testing.T) {
+	if !gitAvailable() || runtime.GOOS == "windows" {
+		t.Skip("git or sh not available")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	runGit(t, dir, "config", "commit.gpgsign", "false")
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hi"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	runGit(t, dir, "add", "file.txt")
+	runGit(t, dir, "commit", "-m", "init")
+	runGit(t, dir, "branch", "dev")
+
+	// The repository's config asks for programs of its own
+	marker := filepath.Join(t.TempDir(), "ran")
+	script := "#!/bin/sh\necho ran >> " + marker + "\n"
+	for _, name := range []string{filepath.Join(dir, ".git", "hooks", "post-checkout"), filepath.Join(dir, "monitor.sh")} {
+		if err := os.WriteFile(name, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, dir, "config", "core.fsmonitor", filepath.Join(dir, "monitor.sh"))
+
+	if _, _, err := ListBranches(dir); err != nil {
+		t.Fatalf("ListBranches error: %v", err)
+	}
+	if err := Checkout(dir, "dev"); err != nil {
+		t.Fatalf("Checkout error: %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("git ran a hook or fsmonitor from the repository")
+	}
+}
+
 func TestListBranchesNotRepo(t *testing.T) {
 	dir := t.TempDir()
 	_, _, err := ListBranches(dir)
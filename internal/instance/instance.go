@@ -0,0 +1,123 @@
+// Package instance lets `qedit file` hand the file to a qedit already
+// running in the same project instead of starting a second editor, which
+// is what terminal file managers and git tools want. The running editor
+// listens on a Unix socket named after the project, in the user's private
+// directory; a new one sends it the absolute path of the file on a line
+// and waits for "ok".
+package instance
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kobzarvs/qedit/internal/platform/rundir"
+)
+
+// sendTimeout bounds how long a handoff waits for the running editor
+const sendTimeout = 2 * time.Second
+
+// Addr returns the socket of the editor running in project: one per
+// project directory, in the user's private directory, where other users
+// can't put a socket of their own
+func Addr(project string) string {
+	h := fnv.New64a()
+	h.Write([]byte(project))
+	return filepath.Join(rundir.Path(), fmt.Sprintf("instance-%x.sock", h.Sum64()))
+}
+
+// Send hands path to the editor listening on addr and reports whether it
+// took it. A socket of another user is refused: its editor would say "ok"
+// and drop the file.
+func Send(addr, path string) error {
+	if err := rundir.CheckOwner(addr); err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("unix", addr, sendTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(sendTimeout))
+	if _, err := fmt.Fprintln(conn, path); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if reply = strings.TrimSpace(reply); reply != "ok" {
+		return errors.New(reply)
+	}
+	return nil
+}
+
+// Listener takes the files other qedits hand over
+type Listener struct {
+	ln   net.Listener
+	addr string
+}
+
+// Listen listens on addr and calls open, on a goroutine of its own, with
+// each path handed over. A socket left behind by an editor that is gone
+// is replaced.
+func Listen(addr string, open func(path string)) (*Listener, error) {
+	if filepath.Dir(addr) == rundir.Path() {
+		if _, err := rundir.Dir(); err != nil {
+			return nil, err
+		}
+	}
+	removeStale(addr)
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	l := &Listener{ln: ln, addr: addr}
+	go l.accept(open)
+	return l, nil
+}
+
+func (l *Listener) accept(open func(path string)) {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(sendTimeout))
+			path, err := bufio.NewReader(conn).ReadString('\n')
+			if path = strings.TrimSpace(path); err != nil || !filepath.IsAbs(path) {
+				fmt.Fprintln(conn, "bad request")
+				return
+			}
+			open(path)
+			fmt.Fprintln(conn, "ok")
+		}()
+	}
+}
+
+// Close stops listening and removes the socket
+func (l *Listener) Close() error {
+	err := l.ln.Close()
+	_ = os.Remove(l.addr)
+	return err
+}
+
+// removeStale removes the socket at addr unless an editor answers on it
+func removeStale(addr string) {
+	if _, err := os.Stat(addr); err != nil {
+		return
+	}
+	if conn, err := net.Dial("unix", addr); err == nil {
+		_ = conn.Close()
+		return
+	}
+	_ = os.Remove(addr)
+}
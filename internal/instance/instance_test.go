@@ -0,0 +1,78 @@
+package instance
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandoff(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "q.sock")
+	if err := Send(addr, "/tmp/a.txt"); err == nil {
+		t.Fatal("Send without a listener succeeded")
+	}
+	got := make(chan string, 1)
+	l, err := Listen(addr, func(path string) { got <- path })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := Send(addr, "/tmp/a.txt"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if path := <-got; path != "/tmp/a.txt" {
+		t.Fatalf("open(%q)", path)
+	}
+	if err := Send(addr, "relative.txt"); err == nil || err.Error() != "bad request" {
+		t.Fatalf("relative path: %v", err)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "q.sock")
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave the socket file behind, as an editor that crashed does
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := os.Stat(addr); err != nil {
+		t.Fatalf("socket gone: %v", err)
+	}
+	l, err := Listen(addr, func(string) {})
+	if err != nil {
+		t.Fatalf("Listen over stale socket: %v", err)
+	}
+	l.Close()
+	if _, err := os.Stat(addr); !os.IsNotExist(err) {
+		t.Fatalf("socket left after Close: %v", err)
+	}
+}
+
+func TestAddrPerProject(t *testing.T) {
+	if Addr("/src/a") == Addr("/src/b") || Addr("/src/a") != Addr("/src/a") {
+		t.Fatal("Addr not one per project")
+	}
+}
+
+func TestAddrInPrivateDir(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", base)
+	addr := Addr("/src/project")
+	if filepath.Dir(addr) != filepath.Join(base, "qedit") {
+		t.Fatalf("Addr = %s", addr)
+	}
+	l, err := Listen(addr, func(string) {})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	if info, err := os.Stat(filepath.Dir(addr)); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("directory %v, %v", info, err)
+	}
+	if err := Send(addr, "/tmp/a.txt"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -183,7 +184,7 @@ func (m *Manager) getServerForFile(path string) (*server, error) {
 }
 
 // GotoDefinition returns locations of definitions for the symbol at the given position
-func (m *Manager) GotoDefinition(path string, line, col int) ([]Location, error) {
+func (m *Manager) GotoDefinition(ctx context.Context, path string, line, col int) ([]Location, error) {
 	srv, err := m.getServerForFile(path)
 	if err != nil {
 		return nil, err
@@ -192,11 +193,11 @@ func (m *Manager) GotoDefinition(path string, line, col int) ([]Location, error)
 		TextDocument: TextDocumentIdentifier{URI: fileURI(path)},
 		Position:     Position{Line: line, Character: col},
 	}
-	return srv.requestLocations("textDocument/definition", params)
+	return srv.requestLocations(ctx, "textDocument/definition", params)
 }
 
 // GotoDeclaration returns locations of declarations for the symbol at the given position
-func (m *Manager) GotoDeclaration(path string, line, col int) ([]Location, error) {
+func (m *Manager) GotoDeclaration(ctx context.Context, path string, line, col int) ([]Location, error) {
 	srv, err := m.getServerForFile(path)
 	if err != nil {
 		return nil, err
@@ -205,11 +206,11 @@ func (m *Manager) GotoDeclaration(path string, line, col int) ([]Location, error
 		TextDocument: TextDocumentIdentifier{URI: fileURI(path)},
 		Position:     Position{Line: line, Character: col},
 	}
-	return srv.requestLocations("textDocument/declaration", params)
+	return srv.requestLocations(ctx, "textDocument/declaration", params)
 }
 
 // GotoTypeDefinition returns locations of type definitions for the symbol at the given position
-func (m *Manager) GotoTypeDefinition(path string, line, col int) ([]Location, error) {
+func (m *Manager) GotoTypeDefinition(ctx context.Context, path string, line, col int) ([]Location, error) {
 	srv, err := m.getServerForFile(path)
 	if err != nil {
 		return nil, err
@@ -218,11 +219,11 @@ func (m *Manager) GotoTypeDefinition(path string, line, col int) ([]Location, er
 		TextDocument: TextDocumentIdentifier{URI: fileURI(path)},
 		Position:     Position{Line: line, Character: col},
 	}
-	return srv.requestLocations("textDocument/typeDefinition", params)
+	return srv.requestLocations(ctx, "textDocument/typeDefinition", params)
 }
 
 // FindReferences returns all references to the symbol at the given position
-func (m *Manager) FindReferences(path string, line, col int) ([]Location, error) {
+func (m *Manager) FindReferences(ctx context.Context, path string, line, col int) ([]Location, error) {
 	srv, err := m.getServerForFile(path)
 	if err != nil {
 		return nil, err
@@ -232,11 +233,11 @@ func (m *Manager) FindReferences(path string, line, col int) ([]Location, error)
 		Position:     Position{Line: line, Character: col},
 		Context:      ReferenceContext{IncludeDeclaration: true},
 	}
-	return srv.requestLocations("textDocument/references", params)
+	return srv.requestLocations(ctx, "textDocument/references", params)
 }
 
 // GotoImplementation returns locations of implementations for the symbol at the given position
-func (m *Manager) GotoImplementation(path string, line, col int) ([]Location, error) {
+func (m *Manager) GotoImplementation(ctx context.Context, path string, line, col int) ([]Location, error) {
 	srv, err := m.getServerForFile(path)
 	if err != nil {
 		return nil, err
@@ -245,7 +246,7 @@ func (m *Manager) GotoImplementation(path string, line, col int) ([]Location, er
 		TextDocument: TextDocumentIdentifier{URI: fileURI(path)},
 		Position:     Position{Line: line, Character: col},
 	}
-	return srv.requestLocations("textDocument/implementation", params)
+	return srv.requestLocations(ctx, "textDocument/implementation", params)
 }
 
 // Position represents a position in a text document (LSP spec)
@@ -561,8 +562,9 @@ func (s *server) send(v any) error {
 	return err
 }
 
-// request sends a JSON-RPC request and waits for the response
-func (s *server) request(method string, params any) (json.RawMessage, error) {
+// request sends a JSON-RPC request and waits for the response, until the
+// timeout or until ctx is canceled
+func (s *server) request(ctx context.Context, method string, params any) (json.RawMessage, error) {
 	s.mu.Lock()
 	if !s.initialized {
 		s.mu.Unlock()
@@ -591,6 +593,11 @@ func (s *server) request(method string, params any) (json.RawMessage, error) {
 	select {
 	case result := <-ch:
 		return result, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.handlers, id)
+		s.mu.Unlock()
+		return nil, ctx.Err()
 	case <-time.After(10 * time.Second):
 		s.mu.Lock()
 		delete(s.handlers, id)
@@ -606,8 +613,8 @@ type LSPError struct {
 }
 
 // requestLocations sends a request and parses the response as Location or []Location
-func (s *server) requestLocations(method string, params any) ([]Location, error) {
-	result, err := s.request(method, params)
+func (s *server) requestLocations(ctx context.Context, method string, params any) ([]Location, error) {
+	result, err := s.request(ctx, method, params)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", method, err)
 	}
@@ -0,0 +1,46 @@
+// Package clipboard reads and writes the system clipboard using the
+// platform's command line tools.
+package clipboard
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// ErrUnavailable is returned when no clipboard tool is installed.
+var ErrUnavailable = errors.New("clipboard unavailable")
+
+// Write copies text to the system clipboard.
+func Write(text string) error {
+	args := copyCommand()
+	if args == nil {
+		return ErrUnavailable
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// Read returns the system clipboard contents with line endings normalized to \n.
+func Read() (string, error) {
+	args := pasteCommand()
+	if args == nil {
+		return "", ErrUnavailable
+	}
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(string(out), "\r\n", "\n"), nil
+}
+
+// firstAvailable returns the first command whose binary is in PATH.
+func firstAvailable(commands ...[]string) []string {
+	for _, args := range commands {
+		if _, err := exec.LookPath(args[0]); err == nil {
+			return args
+		}
+	}
+	return nil
+}
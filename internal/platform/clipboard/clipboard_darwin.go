@@ -0,0 +1,11 @@
+//go:build darwin
+
+package clipboard
+
+func copyCommand() []string {
+	return []string{"pbcopy"}
+}
+
+func pasteCommand() []string {
+	return []string{"pbpaste"}
+}
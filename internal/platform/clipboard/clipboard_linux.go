@@ -0,0 +1,33 @@
+//go:build linux
+
+package clipboard
+
+import "os"
+
+// Wayland tools are preferred when a Wayland session is running, then X11
+// tools, then clip.exe/powershell.exe for WSL.
+func copyCommand() []string {
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-copy"})
+	}
+	commands = append(commands,
+		[]string{"xclip", "-selection", "clipboard", "-in"},
+		[]string{"xsel", "--clipboard", "--input"},
+		[]string{"clip.exe"},
+	)
+	return firstAvailable(commands...)
+}
+
+func pasteCommand() []string {
+	var commands [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-paste", "--no-newline"})
+	}
+	commands = append(commands,
+		[]string{"xclip", "-selection", "clipboard", "-out"},
+		[]string{"xsel", "--clipboard", "--output"},
+		[]string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+	)
+	return firstAvailable(commands...)
+}
@@ -0,0 +1,17 @@
+//go:build !darwin && !linux && !windows
+
+package clipboard
+
+func copyCommand() []string {
+	return firstAvailable(
+		[]string{"xclip", "-selection", "clipboard", "-in"},
+		[]string{"xsel", "--clipboard", "--input"},
+	)
+}
+
+func pasteCommand() []string {
+	return firstAvailable(
+		[]string{"xclip", "-selection", "clipboard", "-out"},
+		[]string{"xsel", "--clipboard", "--output"},
+	)
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package clipboard
+
+func copyCommand() []string {
+	return []string{"clip.exe"}
+}
+
+func pasteCommand() []string {
+	return []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"}
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package rundir
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// Owned reports whether the file of info belongs to the current user
+func Owned(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
@@ -0,0 +1,53 @@
+// Package rundir is qedit's private directory for sockets and the tokens
+// that go with them. Other users can't create files in it or connect to
+// what is in it, so a socket found there was made by one of our qedits.
+package rundir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Path returns the directory: qedit in $XDG_RUNTIME_DIR, or qedit-<uid> in
+// the temporary directory where there is none. Dir creates it.
+func Path() string {
+	if base := os.Getenv("XDG_RUNTIME_DIR"); base != "" {
+		return filepath.Join(base, "qedit")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("qedit-%d", os.Getuid()))
+}
+
+// Dir returns the directory, creating it 0700. One that is a symlink,
+// belongs to another user or is open to others is refused: someone else
+// made it to plant sockets in.
+func Dir() (string, error) {
+	dir := Path()
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || !Owned(info) || info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("%s is not a private directory of this user", dir)
+	}
+	return dir, nil
+}
+
+// CheckOwner returns an error unless the file at path, not following a
+// symlink, belongs to the current user. Clients check a socket with it
+// before connecting, so they don't talk to one another user put there.
+func CheckOwner(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !Owned(info) {
+		return fmt.Errorf("%s belongs to another user", path)
+	}
+	return nil
+}
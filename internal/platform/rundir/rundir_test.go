@@ -0,0 +1,30 @@
+package rundir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", base)
+	dir, err := Dir()
+	if err != nil || dir != filepath.Join(base, "qedit") {
+		t.Fatalf("Dir = %q, %v", dir, err)
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0o700 {
+		t.Fatalf("mode %v", info.Mode().Perm())
+	}
+	if err := CheckOwner(dir); err != nil {
+		t.Fatalf("CheckOwner: %v", err)
+	}
+
+	// A directory others can get into is refused
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Dir(); err == nil {
+		t.Fatal("open directory accepted")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+
+// Package zoom changes the terminal font size where the platform allows it.
+package zoom
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Supported reports whether terminal zoom is available on this platform.
+const Supported = true
+
+// Step sends a single zoom keystroke (Cmd+= / Cmd+-) to the terminal via AppleScript.
+func Step(zoomIn bool) {
+	key := "+"
+	if !zoomIn {
+		key = "-"
+	}
+	script := fmt.Sprintf(`tell application "System Events" to keystroke "%s" using command down`, key)
+	_ = exec.Command("osascript", "-e", script).Run()
+}
@@ -0,0 +1,10 @@
+//go:build !darwin
+
+// Package zoom changes the terminal font size where the platform allows it.
+package zoom
+
+// Supported reports whether terminal zoom is available on this platform.
+const Supported = false
+
+// Step does nothing: there is no portable way to zoom the terminal.
+func Step(zoomIn bool) {}
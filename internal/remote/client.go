@@ -0,0 +1,212 @@
+package remote
+
+import (
+	"encoding/gob"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// detachKey detaches the client, leaving the editor running
+const detachKey = tcell.KeyCtrlBackslash
+
+// redialInterval is how often a client that lost its server tries to
+// attach again
+const redialInterval = time.Second
+
+// serverExited is the goodbye of a server whose editor quit
+const serverExited = "qedit exited"
+
+// netEvent is what the client's network goroutines post to its event
+// loop: a message or the end of conn, or a new connection
+type netEvent struct {
+	conn   net.Conn
+	msg    *message
+	err    error
+	dialed bool
+}
+
+// client is the terminal side of an attached editor
+type client struct {
+	s     tcell.Screen
+	addr  string
+	token string
+	conn  net.Conn // nil while reattaching
+	enc   *gob.Encoder
+	stop  chan struct{}
+}
+
+// Attach shows the editor served on addr in the terminal, or on s when it
+// isn't nil (the caller initializes it; Attach finalizes it). The token is
+// the server's; "" reads the one a server of the user left for addr. It
+// returns when the editor quits or Ctrl-\ detaches. When the connection
+// drops it keeps trying to reattach.
+func Attach(addr, token string, s tcell.Screen) error {
+	if token == "" {
+		var err error
+		if token, err = ReadToken(addr); err != nil {
+			return err
+		}
+	}
+	conn, err := dial(addr, token)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		if s, err = tcell.NewScreen(); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		if err := s.Init(); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+	s.EnableMouse()
+	s.EnablePaste()
+	defer s.Fini()
+
+	c := &client{s: s, addr: addr, token: token, stop: make(chan struct{})}
+	defer close(c.stop)
+	c.start(conn)
+	for {
+		ev := s.PollEvent()
+		switch ev := ev.(type) {
+		case nil:
+			return nil // the screen was finalized
+		case *tcell.EventKey:
+			if ev.Key() == detachKey {
+				c.drop()
+				return nil
+			}
+		case *tcell.EventResize:
+			s.Sync()
+		case *tcell.EventInterrupt:
+			if nev, ok := ev.Data().(netEvent); ok {
+				if err := c.handle(nev); err != nil || c.done(nev) {
+					return err
+				}
+			}
+			continue
+		}
+		if in, ok := fromEvent(ev); ok && c.conn != nil {
+			_ = c.enc.Encode(message{Input: &in}) // a broken connection shows up in read
+		}
+	}
+}
+
+// start attaches over conn: it tells the server the terminal's size, which
+// makes it send the whole screen
+func (c *client) start(conn net.Conn) {
+	c.conn, c.enc = conn, gob.NewEncoder(conn)
+	w, h := c.s.Size()
+	_ = c.enc.Encode(message{Input: &input{Kind: inputResize, X: w, Y: h}})
+	go c.read(conn)
+}
+
+// drop closes the connection
+func (c *client) drop() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn, c.enc = nil, nil
+	}
+}
+
+// done reports whether the server said goodbye for good
+func (c *client) done(nev netEvent) bool {
+	return nev.msg != nil && nev.msg.Bye != ""
+}
+
+// handle applies a network event and returns an error when the server
+// detached the client for another reason than quitting
+func (c *client) handle(nev netEvent) error {
+	switch {
+	case nev.dialed:
+		c.start(nev.conn)
+	case nev.conn != c.conn:
+		// left over from a dropped connection
+	case nev.err != nil:
+		c.drop()
+		c.showLost()
+		go c.redial()
+	case nev.msg.Bye != "":
+		c.drop()
+		if nev.msg.Bye != serverExited {
+			return errors.New(nev.msg.Bye)
+		}
+	case nev.msg.Frame != nil:
+		c.draw(nev.msg.Frame)
+	}
+	return nil
+}
+
+// read posts the messages arriving on conn to the event loop
+func (c *client) read(conn net.Conn) {
+	dec := gob.NewDecoder(conn)
+	for {
+		var msg message
+		err := dec.Decode(&msg)
+		if err != nil {
+			_ = c.s.PostEvent(tcell.NewEventInterrupt(netEvent{conn: conn, err: err}))
+			return
+		}
+		_ = c.s.PostEvent(tcell.NewEventInterrupt(netEvent{conn: conn, msg: &msg}))
+	}
+}
+
+// redial tries to reach the server again until it answers or the client
+// quits
+func (c *client) redial() {
+	ticker := time.NewTicker(redialInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		if conn, err := dial(c.addr, c.token); err == nil {
+			_ = c.s.PostEvent(tcell.NewEventInterrupt(netEvent{conn: conn, dialed: true}))
+			return
+		}
+	}
+}
+
+// draw puts a frame from the server on the screen
+func (c *client) draw(f *frame) {
+	if f.Full {
+		c.s.Clear()
+	}
+	for _, cl := range f.Cells {
+		runes := []rune(cl.Text)
+		if len(runes) == 0 {
+			continue // the right half of a wide character
+		}
+		c.s.SetContent(cl.X, cl.Y, runes[0], runes[1:], cl.style())
+	}
+	if f.CursorShown && f.CursorX >= 0 && f.CursorY >= 0 {
+		c.s.SetCursorStyle(f.CursorStyle)
+		c.s.ShowCursor(f.CursorX, f.CursorY)
+	} else {
+		c.s.HideCursor()
+	}
+	c.s.Show()
+}
+
+// showLost tells on the last line that the server is gone
+func (c *client) showLost() {
+	w, h := c.s.Size()
+	msg := []rune(" connection lost, reattaching... (Ctrl-\\ quits) ")
+	style := tcell.StyleDefault.Reverse(true)
+	for x := 0; x < w; x++ {
+		r := ' '
+		if x < len(msg) {
+			r = msg[x]
+		}
+		c.s.SetContent(x, h-1, r, nil, style)
+	}
+	c.s.HideCursor()
+	c.s.Show()
+}
@@ -0,0 +1,194 @@
+// Package remote runs qedit headless and lets a thin client attach to it
+// from another terminal or machine, tmux-style. The server runs the whole
+// editor (buffers, undo, tree-sitter, LSP) on a screen of its own; the
+// client forwards its terminal's input and draws the cells the server
+// sends. The editor keeps running when the client goes away, and a client
+// attaching later picks up where the last one left off.
+//
+// The protocol is a gob stream of messages each way: input events from
+// the client, screen updates from the server. Before it the client sends
+// the server's token, which the server writes to a file only its user can
+// read; a connection without it is dropped before anything is decoded.
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/platform/rundir"
+)
+
+// tokenLen is the length of a token: 32 random bytes in hex
+const tokenLen = 64
+
+// Kinds of input events
+const (
+	inputKey    = "key"
+	inputMouse  = "mouse"
+	inputPaste  = "paste"
+	inputResize = "resize"
+)
+
+// input is a terminal event of the client
+type input struct {
+	Kind    string
+	Key     tcell.Key
+	Rune    rune
+	Mod     tcell.ModMask
+	X, Y    int // mouse position, or screen size for a resize
+	Buttons tcell.ButtonMask
+	Start   bool // a paste starts
+}
+
+// cell is a screen cell that changed
+type cell struct {
+	X, Y   int
+	Text   string
+	Fg, Bg tcell.Color
+	Attr   tcell.AttrMask
+}
+
+// style returns the style of c
+func (c cell) style() tcell.Style {
+	return tcell.StyleDefault.Foreground(c.Fg).Background(c.Bg).Attributes(c.Attr)
+}
+
+// frame is what changed on the server's screen since the last frame
+type frame struct {
+	Width, Height int
+	Full          bool // Cells cover the whole screen
+	Cells         []cell
+	CursorX       int
+	CursorY       int
+	CursorShown   bool
+	CursorStyle   tcell.CursorStyle
+}
+
+// message is one message of the stream, in either direction
+type message struct {
+	Input *input
+	Frame *frame
+	Bye   string // the server detaches the client, saying why
+}
+
+// DefaultAddr is the socket the server listens on and the client
+// attaches to without --socket: one per user, in its private directory
+func DefaultAddr() string {
+	return filepath.Join(rundir.Path(), "remote.sock")
+}
+
+// newToken returns a random token
+func newToken() (string, error) {
+	b := make([]byte, tokenLen/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// tokenPath returns the file that holds the token of the server on addr
+func tokenPath(addr string) string {
+	h := fnv.New64a()
+	h.Write([]byte(addr))
+	return filepath.Join(rundir.Path(), fmt.Sprintf("remote-%x.token", h.Sum64()))
+}
+
+// ReadToken returns the token of the server this user runs on addr
+func ReadToken(addr string) (string, error) {
+	data, err := os.ReadFile(tokenPath(addr))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("no token for %s (pass the one --server printed with --token)", addr)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// loopback reports whether the TCP address addr only takes connections
+// from this machine. An empty host listens on every interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// network returns the network of addr: TCP for host:port, else a Unix
+// socket path
+func network(addr string) string {
+	if strings.Contains(addr, ":") && !strings.ContainsRune(addr, filepath.Separator) {
+		return "tcp"
+	}
+	return "unix"
+}
+
+// toEvent turns an input back into the tcell event it came from
+func (in *input) toEvent() tcell.Event {
+	switch in.Kind {
+	case inputKey:
+		return tcell.NewEventKey(in.Key, in.Rune, in.Mod)
+	case inputMouse:
+		return tcell.NewEventMouse(in.X, in.Y, in.Buttons, in.Mod)
+	case inputPaste:
+		return tcell.NewEventPaste(in.Start)
+	case inputResize:
+		return tcell.NewEventResize(in.X, in.Y)
+	}
+	return nil
+}
+
+// fromEvent returns the input for a terminal event, false for events
+// the server has no use for
+func fromEvent(ev tcell.Event) (input, bool) {
+	switch ev := ev.(type) {
+	case *tcell.EventKey:
+		return input{Kind: inputKey, Key: ev.Key(), Rune: ev.Rune(), Mod: ev.Modifiers()}, true
+	case *tcell.EventMouse:
+		x, y := ev.Position()
+		return input{Kind: inputMouse, X: x, Y: y, Buttons: ev.Buttons(), Mod: ev.Modifiers()}, true
+	case *tcell.EventPaste:
+		return input{Kind: inputPaste, Start: ev.Start()}, true
+	case *tcell.EventResize:
+		w, h := ev.Size()
+		return input{Kind: inputResize, X: w, Y: h}, true
+	}
+	return input{}, false
+}
+
+// dial connects to addr and sends token. A Unix socket is only used when
+// it belongs to the user: another user's could be listening for the keys.
+func dial(addr, token string) (net.Conn, error) {
+	if len(token) != tokenLen {
+		return nil, errors.New("bad token")
+	}
+	if network(addr) == "unix" {
+		if err := rundir.CheckOwner(addr); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := net.Dial(network(addr), addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte(token)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
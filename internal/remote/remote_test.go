@@ -0,0 +1,179 @@
+package remote
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// clientScreen is a client's simulation screen that can be read while
+// the client draws on it
+type clientScreen struct {
+	tcell.SimulationScreen
+	mu sync.Mutex
+}
+
+func (s *clientScreen) Show() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SimulationScreen.Show()
+}
+
+// text returns the text of row y
+func (s *clientScreen) text(y int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells, w, h := s.GetContents()
+	if y >= h {
+		return ""
+	}
+	var sb strings.Builder
+	for _, c := range cells[y*w : (y+1)*w] {
+		if len(c.Runes) == 0 {
+			sb.WriteByte(' ')
+			continue
+		}
+		sb.WriteString(string(c.Runes))
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
+
+// echo is an editor stand-in: it shows the screen size and the keys
+// typed, and quits on q
+func echo(s tcell.Screen) error {
+	defer s.Fini()
+	typed := ""
+	for {
+		s.Clear()
+		w, h := s.Size()
+		line := []rune("size " + strings.Repeat("#", w/10) + " " + typed)
+		for x, r := range line {
+			s.SetContent(x, 0, r, nil, tcell.StyleDefault.Bold(true))
+		}
+		s.ShowCursor(len(line), min(1, h-1))
+		s.Show()
+		switch ev := s.PollEvent().(type) {
+		case *tcell.EventKey:
+			if ev.Rune() == 'q' {
+				return nil
+			}
+			typed += string(ev.Rune())
+		case *tcell.EventResize:
+			s.Sync()
+		}
+	}
+}
+
+func TestAttach(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	srv, err := Listen(filepath.Join(t.TempDir(), "qedit.sock"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(echo) }()
+
+	// attach starts a client on a 40x5 screen
+	attach := func() (*clientScreen, chan error) {
+		s := &clientScreen{SimulationScreen: tcell.NewSimulationScreen("UTF-8")}
+		if err := s.Init(); err != nil {
+			t.Fatal(err)
+		}
+		s.SetSize(40, 5)
+		done := make(chan error, 1)
+		go func() { done <- Attach(srv.Addr(), "", s) }()
+		return s, done
+	}
+
+	first, firstDone := attach()
+	waitFor(t, "the first frame", func() bool { return first.text(0) == "size ####" })
+	first.InjectKey(tcell.KeyRune, 'a', tcell.ModNone)
+	waitFor(t, "the typed key", func() bool { return first.text(0) == "size #### a" })
+	first.mu.Lock()
+	cells, _, _ := first.GetContents()
+	_, _, attr := cells[0].Style.Decompose()
+	first.mu.Unlock()
+	if attr&tcell.AttrBold == 0 {
+		t.Fatal("style not sent")
+	}
+	if x, y, shown := first.GetCursor(); !shown || x != 11 || y != 1 {
+		t.Fatalf("cursor at %d,%d shown %v", x, y, shown)
+	}
+
+	// Detaching leaves the editor running for the next client
+	first.InjectKey(detachKey, 0, tcell.ModNone)
+	if err := <-firstDone; err != nil {
+		t.Fatalf("detach: %v", err)
+	}
+	second, secondDone := attach()
+	waitFor(t, "the reattached screen", func() bool { return second.text(0) == "size #### a" })
+
+	// A third client takes over from the second
+	third, thirdDone := attach()
+	if err := <-secondDone; err == nil || !strings.Contains(err.Error(), "another terminal") {
+		t.Fatalf("second client ended with %v", err)
+	}
+	waitFor(t, "the third screen", func() bool { return third.text(0) == "size #### a" })
+
+	third.InjectKey(tcell.KeyRune, 'q', tcell.ModNone)
+	if err := <-thirdDone; err != nil {
+		t.Fatalf("client after quit: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+}
+
+func TestAttachNeedsToken(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if _, err := Listen(":0", false); err == nil || !strings.Contains(err.Error(), "--public") {
+		t.Fatalf("Listen on every interface = %v", err)
+	}
+	srv, err := Listen("127.0.0.1:0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(echo) }()
+
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	wrong := strings.Repeat("0", tokenLen)
+	if err := Attach(srv.Addr(), wrong, s); err == nil || err.Error() != "bad token" {
+		t.Fatalf("Attach with a wrong token = %v", err)
+	}
+
+	// The server keeps running for a client with the right token
+	good := &clientScreen{SimulationScreen: tcell.NewSimulationScreen("UTF-8")}
+	if err := good.Init(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- Attach(srv.Addr(), srv.Token(), good) }()
+	waitFor(t, "the first frame", func() bool { return strings.HasPrefix(good.text(0), "size") })
+	good.InjectKey(tcell.KeyRune, 'q', tcell.ModNone)
+	if err := <-done; err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+}
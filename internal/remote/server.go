@@ -0,0 +1,239 @@
+package remote
+
+import (
+	"crypto/subtle"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/kobzarvs/qedit/internal/platform/rundir"
+)
+
+// writeTimeout bounds how long the server waits on a client that doesn't
+// read, before it drops the client so the editor doesn't hang
+const writeTimeout = 5 * time.Second
+
+// handshakeTimeout bounds how long a connection may take to send the token
+const handshakeTimeout = 5 * time.Second
+
+// Server runs the editor on a screen of its own and shows it to the
+// client attached last
+type Server struct {
+	ln        net.Listener
+	screen    *screen
+	token     string
+	tokenFile string // where the token is kept for the user's clients, "" if nowhere
+
+	mu          sync.Mutex
+	conn        net.Conn // attached client, nil without one
+	enc         *gob.Encoder
+	shown       []cell // screen as the client has it
+	cursorStyle tcell.CursorStyle
+}
+
+// screen is the server's screen: a simulation screen that sends what it
+// shows to the attached client
+type screen struct {
+	tcell.SimulationScreen
+	srv *Server
+}
+
+func (s *screen) Show() {
+	s.SimulationScreen.Show()
+	s.srv.push()
+}
+
+func (s *screen) Sync() {
+	s.SimulationScreen.Sync()
+	s.srv.push()
+}
+
+func (s *screen) SetCursorStyle(cs tcell.CursorStyle, colors ...tcell.Color) {
+	s.srv.mu.Lock()
+	s.srv.cursorStyle = cs
+	s.srv.mu.Unlock()
+	s.SimulationScreen.SetCursorStyle(cs, colors...)
+}
+
+// Listen starts a server on addr with an 80x24 screen, until a client
+// tells its size. A socket left behind by a server that is gone is
+// replaced. A TCP address must be a loopback one unless public is set:
+// whoever attaches edits files as the user.
+func Listen(addr string, public bool) (*Server, error) {
+	if network(addr) == "tcp" && !public && !loopback(addr) {
+		return nil, fmt.Errorf("%s takes connections from other machines (--public allows it)", addr)
+	}
+	if network(addr) == "unix" {
+		if filepath.Dir(addr) == rundir.Path() {
+			if _, err := rundir.Dir(); err != nil {
+				return nil, err
+			}
+		}
+		removeStale(addr)
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen(network(addr), addr)
+	if err != nil {
+		return nil, err
+	}
+	sim := tcell.NewSimulationScreen("UTF-8")
+	if err := sim.Init(); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	sim.SetSize(80, 24)
+	srv := &Server{ln: ln, token: token}
+	srv.screen = &screen{SimulationScreen: sim, srv: srv}
+	if _, err := rundir.Dir(); err == nil {
+		path := tokenPath(addr)
+		if os.WriteFile(path, []byte(token+"\n"), 0o600) == nil {
+			srv.tokenFile = path
+		}
+	}
+	return srv, nil
+}
+
+// removeStale removes the Unix socket at addr unless a server answers on it
+func removeStale(addr string) {
+	if _, err := os.Stat(addr); err != nil {
+		return
+	}
+	if conn, err := net.Dial("unix", addr); err == nil {
+		_ = conn.Close()
+		return
+	}
+	_ = os.Remove(addr)
+}
+
+// Token returns the token clients attach with
+func (srv *Server) Token() string {
+	return srv.token
+}
+
+// Addr returns the address the server listens on
+func (srv *Server) Addr() string {
+	return srv.ln.Addr().String()
+}
+
+// Serve runs the editor with run on the server's screen, letting clients
+// attach meanwhile, and returns run's error. run finalizes the screen.
+func (srv *Server) Serve(run func(tcell.Screen) error) error {
+	go srv.accept()
+	err := run(srv.screen)
+	_ = srv.ln.Close()
+	if srv.tokenFile != "" {
+		_ = os.Remove(srv.tokenFile)
+	}
+	srv.mu.Lock()
+	srv.detach("qedit exited")
+	srv.mu.Unlock()
+	return err
+}
+
+func (srv *Server) accept() {
+	for {
+		conn, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		go srv.attach(conn)
+	}
+}
+
+// attach makes conn the attached client once it sent the token, and drops
+// it otherwise
+func (srv *Server) attach(conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	got := make([]byte, tokenLen)
+	if _, err := io.ReadFull(conn, got); err != nil || subtle.ConstantTimeCompare(got, []byte(srv.token)) != 1 {
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = gob.NewEncoder(conn).Encode(message{Bye: "bad token"})
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	srv.mu.Lock()
+	srv.detach("attached from another terminal")
+	srv.conn, srv.enc, srv.shown = conn, gob.NewEncoder(conn), nil
+	srv.mu.Unlock()
+	srv.read(conn)
+}
+
+// detach says goodbye to the attached client and drops it. srv.mu is held.
+func (srv *Server) detach(reason string) {
+	if srv.conn == nil {
+		return
+	}
+	_ = srv.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = srv.enc.Encode(message{Bye: reason})
+	_ = srv.conn.Close()
+	srv.conn, srv.enc = nil, nil
+}
+
+// read posts the input of the client on conn to the editor. The client's
+// first message is its size, and the redraw for it sends the screen.
+func (srv *Server) read(conn net.Conn) {
+	dec := gob.NewDecoder(conn)
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			srv.mu.Lock()
+			if srv.conn == conn {
+				_ = conn.Close()
+				srv.conn, srv.enc = nil, nil
+			}
+			srv.mu.Unlock()
+			return
+		}
+		if msg.Input == nil {
+			continue
+		}
+		if msg.Input.Kind == inputResize {
+			srv.screen.SetSize(msg.Input.X, msg.Input.Y)
+		}
+		if ev := msg.Input.toEvent(); ev != nil {
+			_ = srv.screen.PostEvent(ev)
+		}
+	}
+}
+
+// push sends the client the cells that changed since the last frame, or
+// all of them when it has none yet or the size changed
+func (srv *Server) push() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.conn == nil {
+		return
+	}
+	cells, w, h := srv.screen.GetContents()
+	f := frame{Width: w, Height: h, CursorStyle: srv.cursorStyle}
+	f.CursorX, f.CursorY, f.CursorShown = srv.screen.GetCursor()
+	if len(srv.shown) != len(cells) {
+		srv.shown = make([]cell, len(cells))
+		f.Full = true
+	}
+	for i, sc := range cells {
+		fg, bg, attr := sc.Style.Decompose()
+		c := cell{X: i % w, Y: i / w, Text: string(sc.Runes), Fg: fg, Bg: bg, Attr: attr}
+		if !f.Full && srv.shown[i] == c {
+			continue
+		}
+		srv.shown[i] = c
+		f.Cells = append(f.Cells, c)
+	}
+	_ = srv.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := srv.enc.Encode(message{Frame: &f}); err != nil {
+		_ = srv.conn.Close()
+		srv.conn, srv.enc = nil, nil
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package session
+
+import "os"
+
+// processAlive reports whether a process with the ID pid exists. Outside
+// Unix, finding the process opens it, which fails once it has exited.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -2,6 +2,7 @@ package session
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -16,11 +17,11 @@ type FileState struct {
 	ScrollX   int    `json:"scroll_x"`
 	Mode      string `json:"mode"` // "normal", "insert"
 	// Selection state
-	SelectionActive bool `json:"selection_active,omitempty"`
-	SelectionStartRow int `json:"selection_start_row,omitempty"`
-	SelectionStartCol int `json:"selection_start_col,omitempty"`
-	SelectionEndRow   int `json:"selection_end_row,omitempty"`
-	SelectionEndCol   int `json:"selection_end_col,omitempty"`
+	SelectionActive   bool `json:"selection_active,omitempty"`
+	SelectionStartRow int  `json:"selection_start_row,omitempty"`
+	SelectionStartCol int  `json:"selection_start_col,omitempty"`
+	SelectionEndRow   int  `json:"selection_end_row,omitempty"`
+	SelectionEndCol   int  `json:"selection_end_col,omitempty"`
 }
 
 // RepoInfo stores repository-specific information
@@ -28,15 +29,32 @@ type RepoInfo struct {
 	MainBranch string `json:"main_branch,omitempty"`
 }
 
-// Session stores the complete editor session state
+// PickerGeometry stores the size chosen for a picker
+type PickerGeometry struct {
+	Width int `json:"width"` // columns
+}
+
+// Run is what one editor has open, kept so it can be resumed after the
+// editor went away without exiting cleanly
+type Run struct {
+	PID        int       `json:"pid"`
+	Buffers    []string  `json:"buffers,omitempty"` // bufferline, in order
+	ActiveFile string    `json:"active_file,omitempty"`
+	Updated    time.Time `json:"updated"`
+}
+
+// Session stores the complete editor session state. Several editors share
+// it: each one writes back only what it changed, and keeps its own Run.
 type Session struct {
-	Files       map[string]FileState `json:"files"`
-	Repos       map[string]RepoInfo  `json:"repos,omitempty"` // keyed by repo root path
-	ActiveFile  string               `json:"active_file,omitempty"`
+	Files   map[string]FileState      `json:"files"`
+	Repos   map[string]RepoInfo       `json:"repos,omitempty"`   // keyed by repo root path
+	Pickers map[string]PickerGeometry `json:"pickers,omitempty"` // keyed by picker type
+	Runs    map[string]Run            `json:"runs,omitempty"`    // editors running or gone without a clean exit, by run ID
+	Last    *Run                      `json:"last,omitempty"`    // the last editor that exited cleanly
 	// Future: Tabs, Windows, Panels
 	// Tabs        []TabState           `json:"tabs,omitempty"`
 	// Windows     []WindowState        `json:"windows,omitempty"`
-	LastSaved   time.Time            `json:"last_saved"`
+	LastSaved time.Time `json:"last_saved"`
 }
 
 // Manager handles session persistence
@@ -46,6 +64,19 @@ type Manager struct {
 	path     string
 	dirty    bool
 	stopChan chan struct{}
+
+	// This editor's run, in Runs from MarkRunning until Stop
+	runID   string
+	run     Run
+	running bool
+	stopped bool
+
+	// What this editor changed, written over the file on disk on save
+	files     map[string]bool
+	repos     map[string]bool
+	pickers   map[string]bool
+	dropped   map[string]bool // runs resumed or given up on
+	takenLast *Run            // Last, once resumed
 }
 
 // NewManager creates a new session manager
@@ -61,6 +92,12 @@ func NewManager() (*Manager, error) {
 		},
 		path:     path,
 		stopChan: make(chan struct{}),
+		runID:    fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano()),
+		run:      Run{PID: os.Getpid()},
+		files:    make(map[string]bool),
+		repos:    make(map[string]bool),
+		pickers:  make(map[string]bool),
+		dropped:  make(map[string]bool),
 	}
 
 	// Load existing session
@@ -90,13 +127,15 @@ func sessionPath() (string, error) {
 }
 
 func (m *Manager) load() {
-	data, err := os.ReadFile(m.path)
-	if err != nil {
-		return // No existing session, start fresh
-	}
+	m.session = readSession(m.path)
+}
+
+// readSession reads the session file at path; a missing or broken file
+// gives an empty session
+func readSession(path string) Session {
 	var session Session
-	if err := json.Unmarshal(data, &session); err != nil {
-		return
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &session)
 	}
 	if session.Files == nil {
 		session.Files = make(map[string]FileState)
@@ -104,10 +143,18 @@ func (m *Manager) load() {
 	if session.Repos == nil {
 		session.Repos = make(map[string]RepoInfo)
 	}
-	m.session = session
+	if session.Pickers == nil {
+		session.Pickers = make(map[string]PickerGeometry)
+	}
+	if session.Runs == nil {
+		session.Runs = make(map[string]Run)
+	}
+	return session
 }
 
-// Save persists the session to disk
+// Save persists the session to disk. The file is read again first and only
+// what this editor changed is written over it, so editors running side by
+// side keep each other's changes.
 func (m *Manager) Save() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -116,20 +163,57 @@ func (m *Manager) Save() error {
 		return nil
 	}
 
-	m.session.LastSaved = time.Now()
-	data, err := json.MarshalIndent(m.session, "", "  ")
+	session := m.merged(readSession(m.path))
+	session.LastSaved = time.Now()
+	data, err := json.MarshalIndent(session, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(m.path, data, 0o644); err != nil {
+	// Written aside and renamed, so another editor never reads half a file
+	tmp := m.path + "." + m.runID
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		os.Remove(tmp)
 		return err
 	}
 
+	m.session = session
 	m.dirty = false
 	return nil
 }
 
+// merged returns disk with this editor's changes applied
+func (m *Manager) merged(disk Session) Session {
+	for path := range m.files {
+		disk.Files[path] = m.session.Files[path]
+	}
+	for root := range m.repos {
+		disk.Repos[root] = m.session.Repos[root]
+	}
+	for picker := range m.pickers {
+		disk.Pickers[picker] = m.session.Pickers[picker]
+	}
+	for id := range m.dropped {
+		delete(disk.Runs, id)
+	}
+	if m.takenLast != nil && disk.Last != nil && disk.Last.Updated.Equal(m.takenLast.Updated) {
+		disk.Last = nil
+	}
+	m.run.Updated = time.Now()
+	switch {
+	case m.stopped:
+		delete(disk.Runs, m.runID)
+		last := m.run
+		disk.Last = &last
+	case m.running:
+		disk.Runs[m.runID] = m.run
+	}
+	return disk
+}
+
 // ForceSave saves even if not dirty
 func (m *Manager) ForceSave() error {
 	m.mu.Lock()
@@ -151,7 +235,8 @@ func (m *Manager) SetFileState(absPath string, state FileState) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.session.Files[absPath] = state
-	m.session.ActiveFile = absPath
+	m.files[absPath] = true
+	m.run.ActiveFile = absPath
 	m.dirty = true
 }
 
@@ -159,15 +244,91 @@ func (m *Manager) SetFileState(absPath string, state FileState) {
 func (m *Manager) SetActiveFile(absPath string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.session.ActiveFile = absPath
+	m.run.ActiveFile = absPath
+	m.dirty = true
+}
+
+// SetBuffers records the files open in the bufferline
+func (m *Manager) SetBuffers(paths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.run.Buffers = paths
+	m.dirty = true
+}
+
+// MarkRunning records on disk that this editor is running, until Stop, and
+// reports whether an earlier editor went away without stopping, e.g.
+// because its terminal was closed. Only the latest such run is kept.
+func (m *Manager) MarkRunning() (unclean bool) {
+	m.mu.Lock()
+	var latest string
+	for id, run := range m.session.Runs {
+		if id == m.runID || alive(run) {
+			continue
+		}
+		if latest != "" {
+			if run.Updated.Before(m.session.Runs[latest].Updated) {
+				m.dropped[id] = true
+				continue
+			}
+			m.dropped[latest] = true
+		}
+		latest = id
+	}
+	m.running = true
 	m.dirty = true
+	m.mu.Unlock()
+	_ = m.Save()
+	return latest != ""
 }
 
-// GetActiveFile returns the last active file
-func (m *Manager) GetActiveFile() string {
+// LastRun returns the last run of an editor that isn't running anymore:
+// one gone without a clean exit, or else the last one that exited
+func (m *Manager) LastRun() (Run, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.session.ActiveFile
+	_, run, ok := m.lastRun()
+	return run, ok
+}
+
+// TakeLastRun returns the run LastRun does and forgets it, once it is
+// resumed; its buffers become this editor's
+func (m *Manager) TakeLastRun() (Run, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, run, ok := m.lastRun()
+	if !ok {
+		return run, false
+	}
+	if id == "" {
+		m.takenLast = m.session.Last
+		m.session.Last = nil
+	} else {
+		m.dropped[id] = true
+		delete(m.session.Runs, id)
+	}
+	m.dirty = true
+	return run, true
+}
+
+// lastRun finds the run for LastRun and its ID, "" for Last
+func (m *Manager) lastRun() (string, Run, bool) {
+	for id, run := range m.session.Runs {
+		if id != m.runID && !m.dropped[id] && !alive(run) {
+			return id, run, true
+		}
+	}
+	if m.session.Last != nil {
+		return "", *m.session.Last, true
+	}
+	return "", Run{}, false
+}
+
+// alive reports whether the editor of run is still running. A run with
+// this process's ID is an earlier one whose ID was reused, or an earlier
+// editor of this same process.
+func alive(run Run) bool {
+	return run.PID != os.Getpid() && processAlive(run.PID)
 }
 
 // GetRepoInfo returns saved info for a repository
@@ -188,6 +349,27 @@ func (m *Manager) SetRepoMainBranch(repoRoot, mainBranch string) {
 	info := m.session.Repos[repoRoot]
 	info.MainBranch = mainBranch
 	m.session.Repos[repoRoot] = info
+	m.repos[repoRoot] = true
+	m.dirty = true
+}
+
+// GetPickerGeometry returns the saved size of a picker type
+func (m *Manager) GetPickerGeometry(picker string) (PickerGeometry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	g, ok := m.session.Pickers[picker]
+	return g, ok
+}
+
+// SetPickerGeometry saves the size of a picker type
+func (m *Manager) SetPickerGeometry(picker string, g PickerGeometry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.session.Pickers == nil {
+		m.session.Pickers = make(map[string]PickerGeometry)
+	}
+	m.session.Pickers[picker] = g
+	m.pickers[picker] = true
 	m.dirty = true
 }
 
@@ -208,5 +390,14 @@ func (m *Manager) autosaveLoop() {
 // Stop stops the autosave loop and saves final state
 func (m *Manager) Stop() {
 	close(m.stopChan)
+	m.mu.Lock()
+	m.stopped = true
+	m.mu.Unlock()
 	_ = m.ForceSave()
 }
+
+// Discard stops autosaving without writing the session, leaving the file on
+// disk as it was
+func (m *Manager) Discard() {
+	close(m.stopChan)
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, path string) Session {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestEditorsSideBySide(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path, err := sessionPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another editor is running: the test binary's parent stands in for it
+	other := Session{
+		Files: map[string]FileState{"/x": {CursorRow: 1}},
+		Runs:  map[string]Run{"other": {PID: os.Getppid(), Buffers: []string{"/x"}, Updated: time.Now()}},
+	}
+	data, _ := json.Marshal(other)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MarkRunning() {
+		t.Fatal("a running editor counted as an unclean exit")
+	}
+	if _, ok := m.LastRun(); ok {
+		t.Fatal("a running editor's buffers are offered for resuming")
+	}
+
+	// A third editor saves in between; m writes over neither
+	m2, err := NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2.SetFileState("/z", FileState{CursorRow: 3})
+	_ = m2.ForceSave()
+	m2.Discard()
+
+	m.SetFileState("/y", FileState{CursorRow: 2})
+	m.SetBuffers([]string{"/y"})
+	_ = m.ForceSave()
+	s := readFile(t, path)
+	for file, row := range map[string]int{"/x": 1, "/y": 2, "/z": 3} {
+		if s.Files[file].CursorRow != row {
+			t.Fatalf("%s: row %d, want %d", file, s.Files[file].CursorRow, row)
+		}
+	}
+	if len(s.Runs) != 2 || len(s.Runs["other"].Buffers) != 1 || len(s.Runs[m.runID].Buffers) != 1 {
+		t.Fatalf("runs = %+v", s.Runs)
+	}
+
+	m.Stop()
+	s = readFile(t, path)
+	if _, ok := s.Runs["other"]; !ok || len(s.Runs) != 1 {
+		t.Fatalf("runs after Stop = %+v", s.Runs)
+	}
+	if s.Last == nil || s.Last.ActiveFile != "/y" {
+		t.Fatalf("last = %+v", s.Last)
+	}
+}
@@ -0,0 +1,164 @@
+// Package tasks tracks long-running jobs (LSP requests, git commands,
+// external formatters) that run off the UI goroutine, so the statusline
+// can show them and the user can cancel them.
+package tasks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Info describes a running task
+type Info struct {
+	ID      int
+	Name    string
+	Owner   string // what the task works for, e.g. a buffer's path; "" for none
+	Started time.Time
+}
+
+// Registry runs tasks in goroutines and hands their results back to the
+// UI goroutine. A task's work returns a function that applies its result;
+// Drain returns those functions for the UI to call, so editor state is
+// only touched from one goroutine. Results of canceled tasks are dropped.
+type Registry struct {
+	mu      sync.Mutex
+	next    int
+	running map[int]*task
+	done    []func()
+	notify  func()
+	wg      sync.WaitGroup
+}
+
+type task struct {
+	Info
+	cancel context.CancelFunc
+}
+
+// New returns an empty registry. notify, if not nil, is called from the
+// task's goroutine whenever a task finishes, to wake up the UI loop.
+func New(notify func()) *Registry {
+	return &Registry{running: map[int]*task{}, notify: notify}
+}
+
+// Go starts work as a task named name and returns its ID. The context
+// is canceled when the task is canceled.
+func (r *Registry) Go(name string, work func(ctx context.Context) func()) int {
+	return r.GoFor("", name, work)
+}
+
+// GoFor is Go for a task that belongs to owner, so CancelOwner can stop
+// it along with the owner's other tasks
+func (r *Registry) GoFor(owner, name string, work func(ctx context.Context) func()) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.next++
+	id := r.next
+	r.running[id] = &task{Info: Info{ID: id, Name: name, Owner: owner, Started: time.Now()}, cancel: cancel}
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.wg.Done()
+		apply := work(ctx)
+		r.mu.Lock()
+		_, live := r.running[id]
+		delete(r.running, id)
+		if live && apply != nil && ctx.Err() == nil {
+			r.done = append(r.done, apply)
+		}
+		r.mu.Unlock()
+		cancel()
+		if r.notify != nil {
+			r.notify()
+		}
+	}()
+	return id
+}
+
+// Cancel cancels task id and reports whether it was running. Its result,
+// if it still produces one, is discarded.
+func (r *Registry) Cancel(id int) bool {
+	r.mu.Lock()
+	t, ok := r.running[id]
+	delete(r.running, id)
+	r.mu.Unlock()
+	if ok {
+		t.cancel()
+	}
+	return ok
+}
+
+// CancelOwner cancels the running tasks of owner and returns how many
+// there were
+func (r *Registry) CancelOwner(owner string) int {
+	r.mu.Lock()
+	var canceled []*task
+	for id, t := range r.running {
+		if t.Owner == owner {
+			canceled = append(canceled, t)
+			delete(r.running, id)
+		}
+	}
+	r.mu.Unlock()
+	for _, t := range canceled {
+		t.cancel()
+	}
+	return len(canceled)
+}
+
+// Shutdown cancels every running task and waits up to timeout for their
+// goroutines to return, so the child processes they started are killed
+// and reaped before the program exits. It reports whether they all did.
+func (r *Registry) Shutdown(timeout time.Duration) bool {
+	r.mu.Lock()
+	running := r.running
+	r.running = map[int]*task{}
+	r.done = nil
+	r.mu.Unlock()
+	for _, t := range running {
+		t.cancel()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Running returns the running tasks, oldest first
+func (r *Registry) Running() []Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Info, 0, len(r.running))
+	for _, t := range r.running {
+		list = append(list, t.Info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Drain returns the result functions of tasks finished since the last call
+func (r *Registry) Drain() []func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	done := r.done
+	r.done = nil
+	return done
+}
+
+// spinnerFrames are the frames of the statusline spinner
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// Spinner returns the spinner frame to show at now
+func Spinner(now time.Time) rune {
+	return spinnerFrames[int(now.UnixMilli()/100)%len(spinnerFrames)]
+}
@@ -0,0 +1,123 @@
+package tasks
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// wait waits for a notification from a finished task
+func wait(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not finish")
+	}
+}
+
+func TestRegistryRunsAndDrains(t *testing.T) {
+	done := make(chan struct{}, 1)
+	r := New(func() { done <- struct{}{} })
+	release := make(chan struct{})
+	applied := 0
+	id := r.Go("work", func(ctx context.Context) func() {
+		<-release
+		return func() { applied++ }
+	})
+	if list := r.Running(); len(list) != 1 || list[0].ID != id || list[0].Name != "work" {
+		t.Fatalf("running = %+v", list)
+	}
+	close(release)
+	wait(t, done)
+	if n := len(r.Running()); n != 0 {
+		t.Fatalf("running after finish = %d", n)
+	}
+	for _, apply := range r.Drain() {
+		apply()
+	}
+	if applied != 1 {
+		t.Fatalf("applied = %d, want 1", applied)
+	}
+	if n := len(r.Drain()); n != 0 {
+		t.Fatalf("second drain = %d results", n)
+	}
+}
+
+func TestRegistryCancel(t *testing.T) {
+	done := make(chan struct{}, 1)
+	r := New(func() { done <- struct{}{} })
+	id := r.Go("slow", func(ctx context.Context) func() {
+		<-ctx.Done()
+		return func() { t.Error("canceled task result applied") }
+	})
+	if !r.Cancel(id) {
+		t.Fatal("Cancel returned false for a running task")
+	}
+	wait(t, done)
+	for _, apply := range r.Drain() {
+		apply()
+	}
+	if r.Cancel(id) {
+		t.Fatal("Cancel returned true for a finished task")
+	}
+}
+
+func TestRegistryCancelOwner(t *testing.T) {
+	r := New(nil)
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(ctx context.Context) func() {
+		select {
+		case <-ctx.Done():
+		case <-release:
+		}
+		return nil
+	}
+	r.GoFor("a.go", "LSP definition", slow)
+	r.GoFor("a.go", "gofmt", slow)
+	other := r.GoFor("b.go", "gofmt", slow)
+	if n := r.CancelOwner("a.go"); n != 2 {
+		t.Fatalf("CancelOwner = %d, want 2", n)
+	}
+	if list := r.Running(); len(list) != 1 || list[0].ID != other || list[0].Owner != "b.go" {
+		t.Fatalf("running = %+v", list)
+	}
+	if n := r.CancelOwner("a.go"); n != 0 {
+		t.Fatalf("second CancelOwner = %d, want 0", n)
+	}
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	r := New(nil)
+	stopped := make(chan struct{})
+	r.Go("slow", func(ctx context.Context) func() {
+		<-ctx.Done()
+		close(stopped)
+		return func() { t.Error("result applied after shutdown") }
+	})
+	if !r.Shutdown(5 * time.Second) {
+		t.Fatal("Shutdown timed out")
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("Shutdown returned before the task did")
+	}
+	if n := len(r.Running()); n != 0 {
+		t.Fatalf("running after shutdown = %d", n)
+	}
+	for _, apply := range r.Drain() {
+		apply()
+	}
+}
+
+func TestSpinner(t *testing.T) {
+	now := time.UnixMilli(0)
+	if Spinner(now) == Spinner(now.Add(100*time.Millisecond)) {
+		t.Fatal("spinner did not advance")
+	}
+	if Spinner(now) != Spinner(now.Add(time.Duration(len(spinnerFrames))*100*time.Millisecond)) {
+		t.Fatal("spinner does not cycle")
+	}
+}
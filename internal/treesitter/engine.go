@@ -30,6 +30,7 @@ type Engine struct {
 	queries       map[string]*sitter.Query
 	sources       map[string][]byte
 	mdInlineQuery *sitter.Query
+	mdTaskQuery   *sitter.Query
 	reqCh         chan parseRequest
 	events        chan Event
 	stopCh        chan struct{}
@@ -49,6 +50,9 @@ type parseRequest struct {
 }
 
 func New(langs config.Languages) *Engine {
+	// Task boxes are looked up with ParseSync trees too, so the query
+	// can't wait for Start
+	taskQuery, _ := sitter.NewQuery([]byte(markdownTaskQuery), tree_sitter_markdown.GetLanguage())
 	return &Engine{
 		langs:   langs,
 		parsers: make(map[string]*sitter.Parser),
@@ -58,6 +62,8 @@ func New(langs config.Languages) *Engine {
 		reqCh:   make(chan parseRequest, 8),
 		events:  make(chan Event, 16),
 		stopCh:  make(chan struct{}),
+
+		mdTaskQuery: taskQuery,
 	}
 }
 
@@ -888,6 +894,197 @@ func (e *Engine) GetNodeStackAt(path string, row, col int) []NodeRange {
 	return stack
 }
 
+// BracketPair is an opening bracket and its closing bracket
+type BracketPair struct {
+	OpenRow  int
+	OpenCol  int
+	CloseRow int
+	CloseCol int
+}
+
+// closingBrackets maps the bracket tokens paired by BracketPairAt
+var closingBrackets = map[string]string{"(": ")", "[": "]", "{": "}"}
+
+// BracketPairAt returns the pair of bracket tokens whose opening or
+// closing bracket is at the given position, or else the innermost pair
+// around it. Brackets are paired as siblings in the tree, so brackets in
+// strings and comments are never matched.
+func (e *Engine) BracketPairAt(path string, row, col int) (BracketPair, bool) {
+	e.mu.RLock()
+	tree := e.trees[path]
+	e.mu.RUnlock()
+
+	if tree == nil {
+		return BracketPair{}, false
+	}
+	root := tree.RootNode()
+	if root == nil {
+		return BracketPair{}, false
+	}
+
+	point := sitter.Point{Row: uint32(row), Column: uint32(col)}
+	for node := root.NamedDescendantForPointRange(point, point); node != nil; node = node.Parent() {
+		if pair, ok := bracketPairIn(node, point); ok {
+			return pair, true
+		}
+	}
+	return BracketPair{}, false
+}
+
+// bracketPairIn pairs the bracket tokens among the children of node and
+// returns the first pair to close that starts at or before point and
+// closes at or after it, which is the innermost one
+func bracketPairIn(node *sitter.Node, point sitter.Point) (BracketPair, bool) {
+	var open []*sitter.Node
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		typ := child.Type()
+		if _, ok := closingBrackets[typ]; ok {
+			open = append(open, child)
+			continue
+		}
+		if len(open) == 0 || closingBrackets[open[len(open)-1].Type()] != typ {
+			continue
+		}
+		opening := open[len(open)-1]
+		open = open[:len(open)-1]
+		start, end := opening.StartPoint(), child.StartPoint()
+		if !pointBefore(point, start) && !pointBefore(end, point) {
+			return BracketPair{
+				OpenRow:  int(start.Row),
+				OpenCol:  int(start.Column),
+				CloseRow: int(end.Row),
+				CloseCol: int(end.Column),
+			}, true
+		}
+	}
+	return BracketPair{}, false
+}
+
+func pointBefore(a, b sitter.Point) bool {
+	return a.Row < b.Row || (a.Row == b.Row && a.Column < b.Column)
+}
+
+// TaskMarker is the box of a markdown task list item: the row and byte
+// column of its [ and whether it is checked
+type TaskMarker struct {
+	Row     int
+	Col     int
+	Checked bool
+}
+
+const markdownTaskQuery = `
+(task_list_marker_checked) @checked
+(task_list_marker_unchecked) @unchecked
+`
+
+// TaskMarkers returns the task list markers of a markdown file on rows
+// startRow to endRow, in order; false when the file has no syntax tree.
+// Boxes in code blocks are not task list markers, so they never show up.
+func (e *Engine) TaskMarkers(path string, startRow, endRow int) ([]TaskMarker, bool) {
+	if lang := e.langs.Match(path); lang == nil || lang.Name != "markdown" {
+		return nil, false
+	}
+	e.mu.RLock()
+	tree := e.trees[path]
+	query := e.mdTaskQuery
+	e.mu.RUnlock()
+	if tree == nil || query == nil {
+		return nil, false
+	}
+
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.SetPointRange(
+		sitter.Point{Row: uint32(startRow), Column: 0},
+		sitter.Point{Row: uint32(endRow + 1), Column: 0},
+	)
+	cursor.Exec(query, tree.RootNode())
+	var markers []TaskMarker
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+		for _, capture := range match.Captures {
+			start := capture.Node.StartPoint()
+			if int(start.Row) < startRow || int(start.Row) > endRow {
+				continue
+			}
+			markers = append(markers, TaskMarker{
+				Row:     int(start.Row),
+				Col:     int(start.Column),
+				Checked: query.CaptureNameForId(capture.Index) == "checked",
+			})
+		}
+	}
+	return markers, true
+}
+
+// ScopeWord is an identifier of a file and how far from a position its
+// nearest occurrence is, counted in nodes: 0 is the innermost node around
+// the position, and a word used only elsewhere in the file gets the depth
+// of the root
+type ScopeWord struct {
+	Name  string
+	Depth int
+}
+
+// ScopeWordsAt returns the identifiers of the file, nearest scope first.
+// Going out from the position one enclosing node at a time, each node adds
+// the identifiers in it that no inner node had, so the parameters and
+// locals of the enclosing function come before the file's globals.
+func (e *Engine) ScopeWordsAt(path string, row, col int) []ScopeWord {
+	e.mu.RLock()
+	tree := e.trees[path]
+	source := e.sources[path]
+	e.mu.RUnlock()
+
+	if tree == nil {
+		return nil
+	}
+	root := tree.RootNode()
+	if root == nil {
+		return nil
+	}
+
+	point := sitter.Point{Row: uint32(row), Column: uint32(col)}
+	seen := map[string]bool{}
+	var words []ScopeWord
+	var inner *sitter.Node
+	depth := 0
+	for node := root.NamedDescendantForPointRange(point, point); node != nil; node = node.Parent() {
+		stack := []*sitter.Node{node}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if inner != nil && sameNode(n, inner) {
+				continue
+			}
+			if n.ChildCount() == 0 && strings.HasSuffix(n.Type(), "identifier") {
+				if name := extractNodeText(n, source); name != "" && !seen[name] {
+					seen[name] = true
+					words = append(words, ScopeWord{Name: name, Depth: depth})
+				}
+				continue
+			}
+			for i := int(n.NamedChildCount()) - 1; i >= 0; i-- {
+				if child := n.NamedChild(i); child != nil {
+					stack = append(stack, child)
+				}
+			}
+		}
+		inner = node
+		depth++
+	}
+	return words
+}
+
+// sameNode reports whether a and b are the same node of a tree
+func sameNode(a, b *sitter.Node) bool {
+	return a.StartByte() == b.StartByte() && a.EndByte() == b.EndByte() && a.Type() == b.Type()
+}
+
 const goHighlightQuery = `
 ((comment) @comment)
 ((interpreted_string_literal) @string)
@@ -52,3 +52,97 @@ func TestEngineOpenFileUnknown(t *testing.T) {
 	case <-time.After(150 * time.Millisecond):
 	}
 }
+
+func TestBracketPairAt(t *testing.T) {
+	langs := config.Languages{
+		Languages: []config.Language{
+			{Name: "go", FileTypes: []string{"go"}},
+		},
+	}
+	e := New(langs)
+	src := "package main\nfunc f() {\n\tg(\"(\", h(1))\n}\n"
+	if !e.ParseSync("main.go", "go", src) {
+		t.Fatalf("parse failed")
+	}
+	tests := []struct {
+		name     string
+		row, col int
+		want     BracketPair
+	}{
+		{"opening", 2, 2, BracketPair{OpenRow: 2, OpenCol: 2, CloseRow: 2, CloseCol: 12}},
+		{"closing", 2, 12, BracketPair{OpenRow: 2, OpenCol: 2, CloseRow: 2, CloseCol: 12}},
+		{"bracket in string", 2, 4, BracketPair{OpenRow: 2, OpenCol: 2, CloseRow: 2, CloseCol: 12}},
+		{"inside", 2, 10, BracketPair{OpenRow: 2, OpenCol: 9, CloseRow: 2, CloseCol: 11}},
+		{"block", 1, 9, BracketPair{OpenRow: 1, OpenCol: 9, CloseRow: 3, CloseCol: 0}},
+	}
+	for _, tt := range tests {
+		got, ok := e.BracketPairAt("main.go", tt.row, tt.col)
+		if !ok || got != tt.want {
+			t.Errorf("%s: BracketPairAt(%d, %d) = %+v, %v, want %+v", tt.name, tt.row, tt.col, got, ok, tt.want)
+		}
+	}
+	if _, ok := e.BracketPairAt("main.go", 0, 3); ok {
+		t.Errorf("BracketPairAt outside any pair found a pair")
+	}
+}
+
+func TestScopeWordsAt(t *testing.T) {
+	langs := config.Languages{
+		Languages: []config.Language{
+			{Name: "go", FileTypes: []string{"go"}},
+		},
+	}
+	e := New(langs)
+	src := "package main\n\nvar global = 1\n\nfunc f(param int) {\n\tlocal := param\n\t_ = local\n}\n"
+	if !e.ParseSync("main.go", "go", src) {
+		t.Fatalf("parse failed")
+	}
+	depth := map[string]int{}
+	for _, w := range e.ScopeWordsAt("main.go", 6, 1) {
+		depth[w.Name] = w.Depth
+	}
+	for _, name := range []string{"global", "param", "local", "f"} {
+		if _, ok := depth[name]; !ok {
+			t.Fatalf("%s missing from %v", name, depth)
+		}
+	}
+	if depth["local"] >= depth["global"] || depth["param"] >= depth["global"] {
+		t.Fatalf("locals not nearer than globals: %v", depth)
+	}
+	if depth["local"] > depth["param"] {
+		t.Fatalf("body local farther than parameter: %v", depth)
+	}
+}
+
+func TestTaskMarkers(t *testing.T) {
+	langs := config.Languages{
+		Languages: []config.Language{
+			{Name: "markdown", FileTypes: []string{"md"}},
+		},
+	}
+	e := New(langs)
+	src := "# Todo\n\n- [ ] one\n- [x] two\n  - [X] nested\n\n```\n- [ ] code\n```\n"
+	if !e.ParseSync("todo.md", "markdown", src) {
+		t.Fatalf("parse failed")
+	}
+	got, ok := e.TaskMarkers("todo.md", 0, 8)
+	want := []TaskMarker{
+		{Row: 2, Col: 2},
+		{Row: 3, Col: 2, Checked: true},
+		{Row: 4, Col: 4, Checked: true},
+	}
+	if !ok || len(got) != len(want) {
+		t.Fatalf("TaskMarkers = %+v, %v, want %+v", got, ok, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("TaskMarkers = %+v, want %+v", got, want)
+		}
+	}
+	if got, _ := e.TaskMarkers("todo.md", 3, 3); len(got) != 1 || got[0].Row != 3 {
+		t.Fatalf("TaskMarkers(3, 3) = %+v, want the row 3 box", got)
+	}
+	if _, ok := e.TaskMarkers("other.md", 0, 8); ok {
+		t.Fatalf("TaskMarkers found markers in an unparsed file")
+	}
+}
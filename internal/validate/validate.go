@@ -0,0 +1,103 @@
+// Package validate runs a lightweight structural check of config files
+// (TOML and YAML) without a language server: parse errors and duplicate
+// keys. It is not a schema validator and stops at the first TOML error.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Problem is a validation error at a 1-based line and byte column
+type Problem struct {
+	Line     int
+	Col      int
+	Message  string
+	Severity Severity
+}
+
+// Severity is how serious a problem is; the zero value is an error
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+	SeverityHint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	}
+	return "error"
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d %s", p.Line, p.Col, p.Message)
+}
+
+// Supported reports whether files with this name can be validated
+func Supported(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".toml", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// File validates data by the extension of name. Unsupported files have no problems.
+func File(name string, data []byte) []Problem {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".toml":
+		return TOML(data)
+	case ".yaml", ".yml":
+		return YAML(data)
+	default:
+		return nil
+	}
+}
+
+// TOML parses data and reports the first syntax error or duplicate key
+func TOML(data []byte) []Problem {
+	var v map[string]any
+	_, err := toml.Decode(string(data), &v)
+	if err == nil {
+		return nil
+	}
+	var perr toml.ParseError
+	if errors.As(err, &perr) {
+		line := max(perr.Position.Line, 1)
+		col := max(perr.Position.Col, 1)
+		// The parser may point past the end of the line for errors found at its end
+		if lines := strings.Split(string(data), "\n"); line <= len(lines) {
+			col = min(col, len(strings.TrimRight(lines[line-1], "\r"))+1)
+		}
+		return []Problem{{Line: line, Col: col, Message: tomlMessage(perr)}}
+	}
+	return []Problem{{Line: 1, Col: 1, Message: err.Error()}}
+}
+
+func tomlMessage(perr toml.ParseError) string {
+	msg := perr.Message
+	if msg == "" {
+		msg = perr.Error()
+	}
+	msg = strings.TrimSuffix(msg, ".")
+	// Make the library's wording consistent with the YAML checker
+	if strings.HasPrefix(msg, "Key '") && strings.HasSuffix(msg, "' has already been defined") {
+		key := strings.TrimSuffix(strings.TrimPrefix(msg, "Key '"), "' has already been defined")
+		return fmt.Sprintf("duplicate key %q", key)
+	}
+	return msg
+}
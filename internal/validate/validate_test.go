@@ -0,0 +1,88 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTOMLProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Problem
+	}{
+		{"valid", "a = 1\n[t]\nb = \"x\"\n", nil},
+		{"duplicate key", "a = 1\nb = 2\na = 3\n", []Problem{{Line: 3, Col: 6, Message: `duplicate key "a"`}}},
+		{"duplicate table", "[t]\nx = 1\n[t]\ny = 2\n", []Problem{{Line: 3, Col: 2, Message: `duplicate key "t"`}}},
+	}
+	for _, tt := range tests {
+		if got := TOML([]byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: TOML() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := TOML([]byte("a = \n")); len(got) != 1 || got[0].Line != 1 {
+		t.Fatalf("syntax error: TOML() = %v, want one problem on line 1", got)
+	}
+}
+
+func TestYAMLProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Problem
+	}{
+		{"valid", `
+name: app # comment
+on:
+  push:
+    branches: [main, "release/*"]
+jobs:
+  build:
+    steps:
+      - name: checkout
+        uses: actions/checkout@v4
+      - name: test
+        run: |
+          go test ./...
+          name: not a key
+      - name: "quoted: value"
+        env: {A: 1,
+          B: 2}
+url: http://example.com
+merge:
+  <<: *base
+  <<: *other
+---
+name: second document
+`, nil},
+		{"duplicate keys", "a: 1\nb:\n  c: 1\n  c: 2\n'a': 3\n", []Problem{
+			{Line: 4, Col: 3, Message: `duplicate key "c" (first defined on line 3)`},
+			{Line: 5, Col: 1, Message: `duplicate key "a" (first defined on line 1)`},
+		}},
+		{"same key in list items", "- name: a\n  x: 1\n- name: b\n  name: c\n", []Problem{
+			{Line: 4, Col: 3, Message: `duplicate key "name" (first defined on line 3)`},
+		}},
+		{"tab indentation", "a:\n\tb: 1\n", []Problem{{Line: 2, Col: 1, Message: "tab character in indentation"}}},
+		{"bad indentation", "a: 1\n  b: 2\n", []Problem{{Line: 2, Col: 3, Message: "bad indentation of a mapping entry"}}},
+		{"nested mapping value", "cmd: echo a: b\n", []Problem{{Line: 1, Col: 12, Message: "mapping values are not allowed here"}}},
+		{"unterminated quote", "a: \"open\nb: 1\n", []Problem{{Line: 1, Col: 4, Message: "unterminated quoted string"}}},
+		{"unclosed flow", "a: [1, 2\n", []Problem{{Line: 1, Col: 4, Message: "unclosed flow collection"}}},
+	}
+	for _, tt := range tests {
+		if got := YAML([]byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: YAML() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileByExtension(t *testing.T) {
+	if !Supported("a.YML") || !Supported("b.toml") || Supported("c.json") {
+		t.Fatalf("Supported() mismatch")
+	}
+	if got := File("c.json", []byte("a: 1\na: 2\n")); got != nil {
+		t.Fatalf("File(json) = %v, want nil", got)
+	}
+	if got := File("c.yaml", []byte("a: 1\na: 2\n")); len(got) != 1 {
+		t.Fatalf("File(yaml) = %v, want one problem", got)
+	}
+}
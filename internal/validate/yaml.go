@@ -0,0 +1,249 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// yamlFrame is an open block mapping and the keys seen in it so far
+type yamlFrame struct {
+	indent int
+	keys   map[string]int // key -> line
+}
+
+// yamlScan tracks quoted scalars and flow collections that span lines
+type yamlScan struct {
+	quote     byte // open quote character or 0
+	flowDepth int
+	startLine int
+	startCol  int
+}
+
+// YAML checks block structure line by line: tabs in indentation, duplicate
+// keys in a mapping, bad mapping indentation, "key: a: b" plain values and
+// unterminated quoted scalars or flow collections. It does not build the
+// document, so anything valid YAML allows beyond common block style is
+// skipped rather than reported.
+func YAML(data []byte) []Problem {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var problems []Problem
+	var frames []yamlFrame
+	var scan yamlScan
+	blockIndent := -1  // inside a block scalar: lines indented deeper belong to it
+	scalarIndent := -1 // indent of the last key that had an inline plain value
+	for i, raw := range lines {
+		lineNo := i + 1
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		content := raw[indent:]
+
+		if blockIndent >= 0 {
+			if strings.TrimSpace(raw) == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if scan.quote != 0 || scan.flowDepth > 0 {
+			scan.feed(raw, 0)
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			problems = append(problems, Problem{Line: lineNo, Col: indent + 1, Message: "tab character in indentation"})
+			continue
+		}
+		content = stripYAMLComment(content)
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		if indent == 0 && (strings.HasPrefix(content, "---") || strings.HasPrefix(content, "...") || strings.HasPrefix(content, "%")) {
+			// New document or directive
+			frames = frames[:0]
+			scalarIndent = -1
+			continue
+		}
+
+		keyIndent := indent
+		item := false
+		for content == "-" || strings.HasPrefix(content, "- ") {
+			// A sequence item starts a new node: close mappings nested in the previous item
+			frames = popYAMLFrames(frames, keyIndent)
+			rest := strings.TrimLeft(content[1:], " ")
+			keyIndent += len(content) - len(rest)
+			content = rest
+			item = true
+		}
+		if content == "" {
+			scalarIndent = -1
+			continue
+		}
+
+		key, value, valueCol, ok := splitYAMLKey(content)
+		if !ok {
+			if content[0] == '|' || content[0] == '>' {
+				blockIndent = indent
+			}
+			scan.start(content, lineNo, keyIndent)
+			continue
+		}
+		if scalarIndent >= 0 && keyIndent > scalarIndent && !item {
+			problems = append(problems, Problem{Line: lineNo, Col: keyIndent + 1, Message: "bad indentation of a mapping entry"})
+		}
+		frames = popYAMLFrames(frames, keyIndent)
+		if len(frames) == 0 || frames[len(frames)-1].indent < keyIndent {
+			frames = append(frames, yamlFrame{indent: keyIndent, keys: map[string]int{}})
+		}
+		top := frames[len(frames)-1]
+		if first, dup := top.keys[key]; dup && key != "<<" {
+			problems = append(problems, Problem{Line: lineNo, Col: keyIndent + 1, Message: fmt.Sprintf("duplicate key %q (first defined on line %d)", key, first)})
+		} else {
+			top.keys[key] = lineNo
+		}
+
+		scalarIndent = -1
+		valueCol += keyIndent
+		switch {
+		case value == "":
+		case value[0] == '|' || value[0] == '>':
+			blockIndent = keyIndent
+		case value[0] == '"' || value[0] == '\'' || value[0] == '[' || value[0] == '{':
+			scan.start(value, lineNo, valueCol)
+		default:
+			scalarIndent = keyIndent
+			if at := strings.Index(value, ": "); at >= 0 || strings.HasSuffix(value, ":") {
+				if at < 0 {
+					at = len(value) - 1
+				}
+				problems = append(problems, Problem{Line: lineNo, Col: valueCol + at + 1, Message: "mapping values are not allowed here"})
+			}
+		}
+	}
+	switch {
+	case scan.quote != 0:
+		problems = append(problems, Problem{Line: scan.startLine, Col: scan.startCol + 1, Message: "unterminated quoted string"})
+	case scan.flowDepth > 0:
+		problems = append(problems, Problem{Line: scan.startLine, Col: scan.startCol + 1, Message: "unclosed flow collection"})
+	}
+	return problems
+}
+
+// popYAMLFrames closes mappings indented deeper than indent
+func popYAMLFrames(frames []yamlFrame, indent int) []yamlFrame {
+	for len(frames) > 0 && frames[len(frames)-1].indent > indent {
+		frames = frames[:len(frames)-1]
+	}
+	return frames
+}
+
+// splitYAMLKey splits "key: value" and returns the unquoted key, the value
+// and the value column within s
+func splitYAMLKey(s string) (key, value string, valueCol int, ok bool) {
+	var end int
+	switch s[0] {
+	case '"', '\'':
+		closing := yamlQuoteEnd(s, 1, s[0])
+		if closing < 0 {
+			return "", "", 0, false
+		}
+		key = s[1:closing]
+		end = closing + 1
+		for end < len(s) && s[end] == ' ' {
+			end++
+		}
+		if end >= len(s) || s[end] != ':' {
+			return "", "", 0, false
+		}
+	case '[', '{', '|', '>', '?', '&', '*', '!', '@', '`', '#':
+		return "", "", 0, false
+	default:
+		end = strings.Index(s, ": ")
+		if end < 0 {
+			if !strings.HasSuffix(s, ":") {
+				return "", "", 0, false
+			}
+			end = len(s) - 1
+		}
+		key = strings.TrimRight(s[:end], " ")
+	}
+	rest := s[end+1:]
+	value = strings.TrimLeft(rest, " ")
+	if len(rest) > 0 && rest[0] != ' ' {
+		return "", "", 0, false
+	}
+	return key, strings.TrimRight(value, " "), end + 1 + len(rest) - len(value), true
+}
+
+// yamlQuoteEnd returns the index of the quote closing a scalar that starts
+// before i, or -1. Single quotes are escaped by doubling them, double quotes
+// with a backslash.
+func yamlQuoteEnd(s string, i int, quote byte) int {
+	for ; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing "# comment" outside of quotes
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if end := yamlQuoteEnd(s, i, quote); end >= 0 {
+				i = end
+				quote = 0
+			} else {
+				return s
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:", s[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return s
+}
+
+// start begins scanning a quoted or flow value at col of lineNo
+func (sc *yamlScan) start(s string, lineNo, col int) {
+	if s == "" || strings.IndexByte("\"'[{", s[0]) < 0 {
+		return
+	}
+	sc.startLine = lineNo
+	sc.startCol = col
+	sc.feed(s, 0)
+}
+
+// feed updates the open quote and flow depth with the text of one line
+func (sc *yamlScan) feed(s string, i int) {
+	for ; i < len(s); i++ {
+		c := s[i]
+		if sc.quote != 0 {
+			end := yamlQuoteEnd(s, i, sc.quote)
+			if end < 0 {
+				return
+			}
+			sc.quote = 0
+			i = end
+			continue
+		}
+		switch {
+		case c == '"' || c == '\'':
+			sc.quote = c
+		case c == '[' || c == '{':
+			sc.flowDepth++
+		case c == ']' || c == '}':
+			if sc.flowDepth > 0 {
+				sc.flowDepth--
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return
+		}
+	}
+}
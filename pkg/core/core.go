@@ -0,0 +1,146 @@
+// Package core is qedit's editing core without a terminal: text stored as
+// lines of runes, positions, selections, motions, line diffs and merges.
+// The TUI in internal/editor edits its lines with these operations and
+// keeps its own undo history, so code embedding this package edits text
+// exactly like the editor does.
+//
+// Positions count runes, not bytes or screen columns. A position may sit
+// after the last rune of a line (Col == len(line)) but not past it.
+package core
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/kobzarvs/qedit/pkg/textpos"
+)
+
+// ErrOutOfRange is returned for positions outside a text
+var ErrOutOfRange = errors.New("position out of range")
+
+// Position is a rune position in a text: a 0-based line and column
+type Position struct {
+	Row int
+	Col int
+}
+
+// Before reports whether p comes before q
+func (p Position) Before(q Position) bool {
+	return p.Row < q.Row || (p.Row == q.Row && p.Col < q.Col)
+}
+
+// Text is read access to lines of runes. Lines implements it, and so does
+// the TUI editor, so the helpers below work on either.
+type Text interface {
+	LineCount() int
+	Line(row int) []rune
+}
+
+// Lines is a text held as lines of runes, as SplitLines returns it
+type Lines [][]rune
+
+// LineCount returns the number of lines
+func (l Lines) LineCount() int {
+	return len(l)
+}
+
+// Line returns the runes of row
+func (l Lines) Line(row int) []rune {
+	return l[row]
+}
+
+// ValidPos reports whether pos is inside t (the column may equal the line length)
+func ValidPos(t Text, pos Position) bool {
+	return pos.Row >= 0 && pos.Row < t.LineCount() && pos.Col >= 0 && pos.Col <= len(t.Line(pos.Row))
+}
+
+// ClampPos moves pos to the nearest position inside t
+func ClampPos(t Text, pos Position) Position {
+	pos.Row = min(max(pos.Row, 0), t.LineCount()-1)
+	pos.Col = min(max(pos.Col, 0), len(t.Line(pos.Row)))
+	return pos
+}
+
+// Order returns a and b sorted so that the first one comes first
+func Order(a, b Position) (Position, Position) {
+	if b.Before(a) {
+		return b, a
+	}
+	return a, b
+}
+
+// TextInRange returns the text between valid positions start (inclusive)
+// and end (exclusive) with lines joined by \n
+func TextInRange(t Text, start, end Position) string {
+	if start.Row == end.Row {
+		return string(t.Line(start.Row)[start.Col:end.Col])
+	}
+	var sb strings.Builder
+	sb.WriteString(string(t.Line(start.Row)[start.Col:]))
+	for row := start.Row + 1; row < end.Row; row++ {
+		sb.WriteByte('\n')
+		sb.WriteString(string(t.Line(row)))
+	}
+	sb.WriteByte('\n')
+	sb.WriteString(string(t.Line(end.Row)[:end.Col]))
+	return sb.String()
+}
+
+// ByteOffset returns the UTF-8 byte offset of pos in the text joined with
+// \n, and the byte column of pos in its line. Columns are clamped to the
+// line; a row past the end is one byte past the text, as if it ended with \n.
+func ByteOffset(t Text, pos Position) (offset, colBytes int) {
+	row := max(pos.Row, 0)
+	count := t.LineCount()
+	for i := 0; i < row && i < count; i++ {
+		line := t.Line(i)
+		offset += textpos.RuneToByte(line, len(line)) + 1
+	}
+	if row >= count {
+		return offset, 0
+	}
+	colBytes = textpos.RuneToByte(t.Line(row), pos.Col)
+	return offset + colBytes, colBytes
+}
+
+// SplitLines splits text into lines at \n (and \r\n). The result always
+// has at least one line; a trailing newline gives an empty last line.
+func SplitLines(text string) [][]rune {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	parts := strings.Split(text, "\n")
+	lines := make([][]rune, len(parts))
+	for i, p := range parts {
+		lines[i] = []rune(p)
+	}
+	return lines
+}
+
+// JoinLines is the inverse of SplitLines
+func JoinLines(lines [][]rune) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(string(line))
+	}
+	return b.String()
+}
+
+// ShiftPos maps p through an edit that replaced [start, oldEnd) with text
+// ending at newEnd. Positions inside the replaced text move to start.
+func ShiftPos(p, start, oldEnd, newEnd Position) Position {
+	if p.Before(start) {
+		return p
+	}
+	if p.Before(oldEnd) {
+		return start
+	}
+	if p.Row == oldEnd.Row {
+		return Position{Row: newEnd.Row, Col: newEnd.Col + p.Col - oldEnd.Col}
+	}
+	return Position{Row: p.Row + newEnd.Row - oldEnd.Row, Col: p.Col}
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInsertDeleteText(t *testing.T) {
+	lines := SplitLines("hello\nworld")
+	lines, end := InsertText(lines, Position{Row: 0, Col: 2}, SplitLines("XX\nYY"))
+	if got := JoinLines(lines); got != "heXX\nYYllo\nworld" {
+		t.Fatalf("after insert = %q", got)
+	}
+	if end != (Position{Row: 1, Col: 2}) {
+		t.Fatalf("insert end = %+v", end)
+	}
+	lines, deleted, start := DeleteText(lines, Position{Row: 0, Col: 2}, end)
+	if JoinLines(lines) != "hello\nworld" || JoinLines(deleted) != "XX\nYY" || start != (Position{Row: 0, Col: 2}) {
+		t.Fatalf("after delete = %q, deleted %q at %+v", JoinLines(lines), JoinLines(deleted), start)
+	}
+
+	// Columns are clamped; empty and reversed ranges delete nothing
+	lines, deleted, _ = DeleteText(lines, Position{Row: 0, Col: 9}, Position{Row: 0, Col: 12})
+	if deleted != nil || JoinLines(lines) != "hello\nworld" {
+		t.Fatalf("delete past the line end removed %q", JoinLines(deleted))
+	}
+	if _, deleted, _ = DeleteText(lines, Position{Row: 1}, Position{Row: 0}); deleted != nil {
+		t.Fatalf("reversed range deleted %q", JoinLines(deleted))
+	}
+	lines, _ = InsertText(lines, Position{Row: 1, Col: 99}, [][]rune{[]rune("!")})
+	if got := JoinLines(lines); got != "hello\nworld!" {
+		t.Fatalf("insert past the line end = %q", got)
+	}
+}
+
+func TestInsertTextCopiesLines(t *testing.T) {
+	text := SplitLines("a\nmiddle\nb")
+	lines, _ := InsertText(SplitLines(""), Position{}, text)
+	lines[1][0] = 'M'
+	if string(text[1]) != "middle" {
+		t.Fatalf("editing the buffer changed the inserted text to %q", string(text[1]))
+	}
+}
+
+func TestByteOffset(t *testing.T) {
+	b := Lines(SplitLines("aж\n😀b"))
+	tests := []struct {
+		pos          Position
+		offset, cols int
+	}{
+		{Position{0, 0}, 0, 0},
+		{Position{0, 2}, 3, 3},
+		{Position{1, 1}, 8, 4},
+		{Position{1, 9}, 9, 5},
+		{Position{5, 0}, 10, 0},
+	}
+	for _, tt := range tests {
+		offset, cols := ByteOffset(b, tt.pos)
+		if offset != tt.offset || cols != tt.cols {
+			t.Errorf("ByteOffset(%+v) = %d, %d, want %d, %d", tt.pos, offset, cols, tt.offset, tt.cols)
+		}
+	}
+}
+
+func TestShiftPos(t *testing.T) {
+	start, oldEnd, newEnd := Position{1, 2}, Position{1, 5}, Position{3, 1}
+	tests := []struct{ in, want Position }{
+		{Position{0, 9}, Position{0, 9}},
+		{Position{1, 1}, Position{1, 1}},
+		{Position{1, 3}, Position{1, 2}},
+		{Position{1, 5}, Position{3, 1}},
+		{Position{1, 7}, Position{3, 3}},
+		{Position{2, 4}, Position{4, 4}},
+	}
+	for _, tt := range tests {
+		if got := ShiftPos(tt.in, start, oldEnd, newEnd); got != tt.want {
+			t.Errorf("ShiftPos(%+v) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSelection(t *testing.T) {
+	b := Lines(SplitLines("one two\nthree"))
+	sel := Selection{Anchor: Position{1, 3}, Head: Position{0, 4}}
+	if start, end := sel.Range(); start != (Position{0, 4}) || end != (Position{1, 3}) {
+		t.Fatalf("Range() = %+v, %+v", start, end)
+	}
+	if got := sel.Text(b); got != "two\nthr" {
+		t.Fatalf("Text() = %q", got)
+	}
+	if !Point(Position{1, 1}).Empty() || sel.Empty() {
+		t.Fatalf("Empty() is wrong")
+	}
+	shifted := sel.Shift(Position{0, 0}, Position{0, 0}, Position{1, 0})
+	if !reflect.DeepEqual(shifted, Selection{Anchor: Position{2, 3}, Head: Position{1, 4}}) {
+		t.Fatalf("Shift() = %+v", shifted)
+	}
+}
+
+func TestMotions(t *testing.T) {
+	b := Lines(SplitLines("foo.bar  baz\n\n  qux"))
+	if got := Left(b, Position{1, 0}); got != (Position{0, 12}) {
+		t.Fatalf("Left at line start = %+v", got)
+	}
+	if got := Right(b, Position{0, 12}); got != (Position{1, 0}) {
+		t.Fatalf("Right at line end = %+v", got)
+	}
+	if got := Vertical(b, Position{0, 10}, 2, 10); got != (Position{2, 5}) {
+		t.Fatalf("Vertical = %+v", got)
+	}
+	var stops []Position
+	for pos := (Position{}); ; {
+		next := NextWordStart(b, pos)
+		if next == pos {
+			break
+		}
+		stops = append(stops, next)
+		pos = next
+	}
+	want := []Position{{0, 3}, {0, 4}, {0, 9}, {2, 2}, {2, 5}}
+	if !reflect.DeepEqual(stops, want) {
+		t.Fatalf("NextWordStart stops = %+v, want %+v", stops, want)
+	}
+	if got := PrevWordStart(b, Position{2, 2}); got != (Position{0, 9}) {
+		t.Fatalf("PrevWordStart across the empty line = %+v", got)
+	}
+	if got := PrevWordStart(b, Position{0, 4}); got != (Position{0, 3}) {
+		t.Fatalf("PrevWordStart before punctuation = %+v", got)
+	}
+}
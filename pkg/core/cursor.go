@@ -0,0 +1,102 @@
+package core
+
+import "unicode"
+
+// Left returns the position one rune before pos, moving to the end of the
+// previous line at a line start
+func Left(t Text, pos Position) Position {
+	pos = ClampPos(t, pos)
+	switch {
+	case pos.Col > 0:
+		pos.Col--
+	case pos.Row > 0:
+		pos.Row--
+		pos.Col = len(t.Line(pos.Row))
+	}
+	return pos
+}
+
+// Right returns the position one rune after pos, moving to the start of
+// the next line at a line end
+func Right(t Text, pos Position) Position {
+	pos = ClampPos(t, pos)
+	switch {
+	case pos.Col < len(t.Line(pos.Row)):
+		pos.Col++
+	case pos.Row < t.LineCount()-1:
+		pos.Row++
+		pos.Col = 0
+	}
+	return pos
+}
+
+// Vertical returns the position n lines below pos (above for negative n)
+// at column goal, or at the end of the line if it is shorter
+func Vertical(t Text, pos Position, n, goal int) Position {
+	return ClampPos(t, Position{Row: pos.Row + n, Col: goal})
+}
+
+// IsWordRune reports whether r is part of a word: letters, digits and _
+func IsWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// runeClass groups runes for word motions: 0 space, 1 word, 2 punctuation
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case IsWordRune(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// NextWordStart returns the start of the next word or punctuation run
+// after pos, crossing line breaks; the end of the text if there is none
+func NextWordStart(t Text, pos Position) Position {
+	pos = ClampPos(t, pos)
+	line := t.Line(pos.Row)
+	if pos.Col < len(line) {
+		// Skip the rest of the current run
+		class := runeClass(line[pos.Col])
+		for pos.Col < len(line) && runeClass(line[pos.Col]) == class && class != 0 {
+			pos.Col++
+		}
+	}
+	for {
+		line = t.Line(pos.Row)
+		for pos.Col < len(line) && runeClass(line[pos.Col]) == 0 {
+			pos.Col++
+		}
+		if pos.Col < len(line) || pos.Row == t.LineCount()-1 {
+			return pos
+		}
+		pos = Position{Row: pos.Row + 1}
+	}
+}
+
+// PrevWordStart returns the start of the word or punctuation run before
+// pos, crossing line breaks; the start of the text if there is none
+func PrevWordStart(t Text, pos Position) Position {
+	pos = ClampPos(t, pos)
+	for {
+		line := t.Line(pos.Row)
+		for pos.Col > 0 && runeClass(line[pos.Col-1]) == 0 {
+			pos.Col--
+		}
+		if pos.Col > 0 {
+			class := runeClass(line[pos.Col-1])
+			for pos.Col > 0 && runeClass(line[pos.Col-1]) == class {
+				pos.Col--
+			}
+			return pos
+		}
+		if pos.Row == 0 {
+			return pos
+		}
+		pos.Row--
+		pos.Col = len(t.Line(pos.Row))
+	}
+}
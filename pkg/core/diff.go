@@ -0,0 +1,115 @@
+package core
+
+// Hunk is one difference between two texts: lines A0..A1 of the old text
+// (end exclusive) are replaced by lines B0..B1 of the new one
+type Hunk struct {
+	A0, A1 int
+	B0, B1 int
+}
+
+// maxDiffEdits bounds the edit distance DiffLines searches. Texts further
+// apart than that are reported as one hunk over their differing middle.
+const maxDiffEdits = 1000
+
+// DiffLines returns the hunks that turn lines a into lines b, in order.
+// Lines the texts have in common outside the hunks are left alone, so a
+// change that only touches a few lines (a formatter run) stays small.
+func DiffLines(a, b [][]rune) []Hunk {
+	// Common prefix and suffix don't need the full search
+	pre := 0
+	for pre < len(a) && pre < len(b) && equalLine(a[pre], b[pre]) {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && equalLine(a[len(a)-1-suf], b[len(b)-1-suf]) {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	hunks := myers(a, b)
+	if hunks == nil {
+		hunks = []Hunk{{A0: 0, A1: len(a), B0: 0, B1: len(b)}}
+	}
+	for i := range hunks {
+		hunks[i].A0 += pre
+		hunks[i].A1 += pre
+		hunks[i].B0 += pre
+		hunks[i].B1 += pre
+	}
+	return hunks
+}
+
+func equalLine(a, b []rune) bool {
+	return string(a) == string(b)
+}
+
+// myers runs Myers' O((N+M)D) diff and returns its hunks, or nil when the
+// texts are more than maxDiffEdits apart
+func myers(a, b [][]rune) []Hunk {
+	n, m := len(a), len(b)
+	maxD := min(n+m, maxDiffEdits)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	// trace[d] is v after step d, for k in -d..d
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down: insertion from b
+			} else {
+				x = v[offset+k-1] + 1 // right: deletion from a
+			}
+			y := x - k
+			for x < n && y < m && equalLine(a[x], b[y]) {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+				return backtrack(trace, n, m)
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+	return nil
+}
+
+// backtrack walks the Myers trace from the end and collects the hunks
+func backtrack(trace [][]int, n, m int) []Hunk {
+	x, y := n, m
+	var hunks []Hunk
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		// Common lines back to the end of the edit
+		for x > prevX && y > prevY {
+			x--
+			y--
+		}
+		// One deleted or inserted line, merged into the hunk after it
+		// when they touch
+		h := Hunk{A0: prevX, A1: x, B0: prevY, B1: y}
+		if len(hunks) > 0 && hunks[len(hunks)-1].A0 == x && hunks[len(hunks)-1].B0 == y {
+			hunks[len(hunks)-1].A0, hunks[len(hunks)-1].B0 = prevX, prevY
+		} else {
+			hunks = append(hunks, h)
+		}
+		x, y = prevX, prevY
+	}
+	// Hunks were collected back to front
+	for i, j := 0, len(hunks)-1; i < j; i, j = i+1, j-1 {
+		hunks[i], hunks[j] = hunks[j], hunks[i]
+	}
+	return hunks
+}
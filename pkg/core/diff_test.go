@@ -0,0 +1,62 @@
+package core
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyHunks applies hunks to a, back to front, taking the new lines from b
+func applyHunks(a, b [][]rune, hunks []Hunk) [][]rune {
+	out := append([][]rune(nil), a...)
+	for i := len(hunks) - 1; i >= 0; i-- {
+		h := hunks[i]
+		rest := append([][]rune(nil), out[h.A1:]...)
+		out = append(append(out[:h.A0], b[h.B0:h.B1]...), rest...)
+	}
+	return out
+}
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want []Hunk
+	}{
+		{"a\nb\nc", "a\nb\nc", nil},
+		{"a\nb\nc", "a\nx\nc", []Hunk{{A0: 1, A1: 2, B0: 1, B1: 2}}},
+		{"a\nb\nc", "a\nc", []Hunk{{A0: 1, A1: 2, B0: 1, B1: 1}}},
+		{"a\nc", "a\nb\nc", []Hunk{{A0: 1, A1: 1, B0: 1, B1: 2}}},
+		{"a\nb\nc\nd\ne", "x\nb\nc\nd\ny", []Hunk{{A0: 0, A1: 1, B0: 0, B1: 1}, {A0: 4, A1: 5, B0: 4, B1: 5}}},
+	}
+	for _, c := range cases {
+		got := DiffLines(SplitLines(c.a), SplitLines(c.b))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("DiffLines(%q, %q) = %+v, want %+v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestDiffLinesApplies(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	text := func() [][]rune {
+		lines := make([]string, 1+rng.Intn(12))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return SplitLines(strings.Join(lines, "\n"))
+	}
+	for i := 0; i < 500; i++ {
+		a, b := text(), text()
+		hunks := DiffLines(a, b)
+		if got := JoinLines(applyHunks(a, b, hunks)); got != JoinLines(b) {
+			t.Fatalf("hunks %+v turn %q into %q, want %q", hunks, JoinLines(a), got, JoinLines(b))
+		}
+		for j := 1; j < len(hunks); j++ {
+			if hunks[j].A0 <= hunks[j-1].A1 && hunks[j].B0 <= hunks[j-1].B1 {
+				t.Fatalf("hunks not separated: %+v", hunks)
+			}
+		}
+	}
+}
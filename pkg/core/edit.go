@@ -0,0 +1,106 @@
+package core
+
+// TextEdit describes one change in byte and byte-column terms, the form
+// incremental parsers (tree-sitter) take. Valid is false when the change
+// can't be described by a single edit and the text must be reparsed.
+type TextEdit struct {
+	Valid          bool
+	StartByte      int
+	OldEndByte     int
+	NewEndByte     int
+	StartRow       int
+	StartColBytes  int
+	OldEndRow      int
+	OldEndColBytes int
+	NewEndRow      int
+	NewEndColBytes int
+}
+
+// InsertText inserts text (one entry per line) at pos and returns the new
+// lines and the end of the inserted text. The column is clamped to the
+// line; a row outside lines leaves them unchanged and returns pos. The
+// inserted runes are copied, so text may be reused by the caller.
+func InsertText(lines [][]rune, pos Position, text [][]rune) ([][]rune, Position) {
+	if len(text) == 0 || pos.Row < 0 || pos.Row >= len(lines) {
+		return lines, pos
+	}
+	line := lines[pos.Row]
+	pos.Col = min(max(pos.Col, 0), len(line))
+
+	if len(text) == 1 {
+		// Single line: just insert the runes into the current line
+		newLine := make([]rune, 0, len(line)+len(text[0]))
+		newLine = append(newLine, line[:pos.Col]...)
+		newLine = append(newLine, text[0]...)
+		newLine = append(newLine, line[pos.Col:]...)
+		lines[pos.Row] = newLine
+		return lines, Position{Row: pos.Row, Col: pos.Col + len(text[0])}
+	}
+
+	// First line: prefix from original + first inserted line
+	firstLine := make([]rune, 0, pos.Col+len(text[0]))
+	firstLine = append(firstLine, line[:pos.Col]...)
+	firstLine = append(firstLine, text[0]...)
+
+	// Last line: last inserted line + suffix from original
+	suffix := line[pos.Col:]
+	lastLine := make([]rune, 0, len(text[len(text)-1])+len(suffix))
+	lastLine = append(lastLine, text[len(text)-1]...)
+	lastLine = append(lastLine, suffix...)
+
+	newLines := make([][]rune, 0, len(lines)+len(text)-1)
+	newLines = append(newLines, lines[:pos.Row]...)
+	newLines = append(newLines, firstLine)
+	for i := 1; i < len(text)-1; i++ {
+		// Copy so later edits of the line don't change the caller's text
+		newLines = append(newLines, append([]rune(nil), text[i]...))
+	}
+	newLines = append(newLines, lastLine)
+	newLines = append(newLines, lines[pos.Row+1:]...)
+	return newLines, Position{Row: pos.Row + len(text) - 1, Col: len(text[len(text)-1])}
+}
+
+// DeleteText deletes the text between start (inclusive) and end (exclusive)
+// and returns the new lines, the deleted text and start clamped to its line.
+// Columns are clamped; an empty or reversed range or rows outside lines
+// delete nothing and return nil.
+func DeleteText(lines [][]rune, start, end Position) ([][]rune, [][]rune, Position) {
+	if start.Row < 0 || end.Row >= len(lines) || start.Row > end.Row {
+		return lines, nil, start
+	}
+	firstLine := lines[start.Row]
+	lastLine := lines[end.Row]
+	start.Col = min(max(start.Col, 0), len(firstLine))
+	end.Col = min(max(end.Col, 0), len(lastLine))
+
+	if start.Row == end.Row {
+		if start.Col >= end.Col {
+			return lines, nil, start
+		}
+		deleted := make([]rune, end.Col-start.Col)
+		copy(deleted, firstLine[start.Col:end.Col])
+		newLine := make([]rune, 0, len(firstLine)-len(deleted))
+		newLine = append(newLine, firstLine[:start.Col]...)
+		newLine = append(newLine, firstLine[end.Col:]...)
+		lines[start.Row] = newLine
+		return lines, [][]rune{deleted}, start
+	}
+
+	deleted := make([][]rune, end.Row-start.Row+1)
+	deleted[0] = append([]rune(nil), firstLine[start.Col:]...)
+	for i := start.Row + 1; i < end.Row; i++ {
+		deleted[i-start.Row] = append([]rune(nil), lines[i]...)
+	}
+	deleted[len(deleted)-1] = append([]rune(nil), lastLine[:end.Col]...)
+
+	// Merge first and last lines
+	mergedLine := make([]rune, 0, start.Col+len(lastLine)-end.Col)
+	mergedLine = append(mergedLine, firstLine[:start.Col]...)
+	mergedLine = append(mergedLine, lastLine[end.Col:]...)
+
+	newLines := make([][]rune, 0, len(lines)-(end.Row-start.Row))
+	newLines = append(newLines, lines[:start.Row]...)
+	newLines = append(newLines, mergedLine)
+	newLines = append(newLines, lines[end.Row+1:]...)
+	return newLines, deleted, start
+}
@@ -0,0 +1,94 @@
+package core
+
+// ConflictLabels name the three texts in the markers around a conflict
+type ConflictLabels struct {
+	Mine, Base, Theirs string
+}
+
+// Merge3 merges the changes mine and theirs made to base, line by line.
+// A region only one side changed takes that side's lines; a region both
+// changed, or changed right next to each other, differently becomes a
+// conflict in diff3 style:
+//
+//	<<<<<<< Mine
+//	...
+//	||||||| Base
+//	...
+//	=======
+//	...
+//	>>>>>>> Theirs
+//
+// It returns the merged lines and the number of conflicts.
+func Merge3(base, mine, theirs [][]rune, labels ConflictLabels) ([][]rune, int) {
+	ours, their := DiffLines(base, mine), DiffLines(base, theirs)
+	var out [][]rune
+	conflicts := 0
+	pos, i, j := 0, 0, 0
+	for i < len(ours) || j < len(their) {
+		// A region starts at the first hunk left and takes in every hunk of
+		// either side that overlaps or touches it
+		var lo, hi int
+		if j >= len(their) || i < len(ours) && ours[i].A0 <= their[j].A0 {
+			lo, hi = ours[i].A0, ours[i].A1
+		} else {
+			lo, hi = their[j].A0, their[j].A1
+		}
+		i0, j0 := i, j
+		for {
+			switch {
+			case i < len(ours) && ours[i].A0 <= hi:
+				hi = max(hi, ours[i].A1)
+				i++
+				continue
+			case j < len(their) && their[j].A0 <= hi:
+				hi = max(hi, their[j].A1)
+				j++
+				continue
+			}
+			break
+		}
+		out = append(out, base[pos:lo]...)
+		pos = hi
+		mineLines := regionLines(base, mine, ours[i0:i], lo, hi)
+		theirLines := regionLines(base, theirs, their[j0:j], lo, hi)
+		switch {
+		case i == i0:
+			out = append(out, theirLines...)
+		case j == j0 || linesEqual(mineLines, theirLines):
+			out = append(out, mineLines...)
+		default:
+			conflicts++
+			out = append(out, []rune("<<<<<<< "+labels.Mine))
+			out = append(out, mineLines...)
+			out = append(out, []rune("||||||| "+labels.Base))
+			out = append(out, base[lo:hi]...)
+			out = append(out, []rune("======="))
+			out = append(out, theirLines...)
+			out = append(out, []rune(">>>>>>> "+labels.Theirs))
+		}
+	}
+	return append(out, base[pos:]...), conflicts
+}
+
+// regionLines returns the lines of text that stand for base[lo:hi], given
+// the hunks turning base into text inside that region. Outside its hunks
+// text lines up with base at a fixed offset.
+func regionLines(base, text [][]rune, hunks []Hunk, lo, hi int) [][]rune {
+	if len(hunks) == 0 {
+		return base[lo:hi]
+	}
+	first, last := hunks[0], hunks[len(hunks)-1]
+	return text[first.B0-(first.A0-lo) : last.B1+(hi-last.A1)]
+}
+
+func linesEqual(a, b [][]rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalLine(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	labels := ConflictLabels{Mine: "mine", Base: "base", Theirs: "theirs"}
+	cases := []struct {
+		base, mine, theirs string
+		want               string
+		conflicts          int
+	}{
+		{"a\nb\nc\nd\ne", "A\nb\nc\nd\ne", "a\nb\nc\nd\nE", "A\nb\nc\nd\nE", 0},
+		{"a\nb\nc", "a\nx\nc", "a\nx\nc", "a\nx\nc", 0},
+		{"a\nb\nc", "a\nc", "a\nb\nc\nd", "a\nc\nd", 0},
+		{"a\nb\nc", "a\nx\nc", "a\ny\nc", "a\n<<<<<<< mine\nx\n||||||| base\nb\n=======\ny\n>>>>>>> theirs\nc", 1},
+		// Changes to neighbouring lines conflict too
+		{"a\nb\nc\nd", "a\nB\nc\nd", "a\nb\nC\nd", "a\n<<<<<<< mine\nB\nc\n||||||| base\nb\nc\n=======\nb\nC\n>>>>>>> theirs\nd", 1},
+	}
+	for _, c := range cases {
+		got, n := Merge3(SplitLines(c.base), SplitLines(c.mine), SplitLines(c.theirs), labels)
+		if JoinLines(got) != c.want || n != c.conflicts {
+			t.Errorf("Merge3(%q, %q, %q) = %q, %d conflicts, want %q, %d", c.base, c.mine, c.theirs, JoinLines(got), n, c.want, c.conflicts)
+		}
+	}
+}
+
+func TestMerge3OneSided(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	words := []string{"a", "b", "c", "d"}
+	text := func() [][]rune {
+		lines := make([]string, 1+rng.Intn(12))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return SplitLines(strings.Join(lines, "\n"))
+	}
+	for i := 0; i < 500; i++ {
+		base, other := text(), text()
+		for _, c := range [][3][][]rune{{base, other, base}, {base, base, other}, {base, other, other}} {
+			got, n := Merge3(c[0], c[1], c[2], ConflictLabels{})
+			if JoinLines(got) != JoinLines(other) || n != 0 {
+				t.Fatalf("Merge3(%q, %q, %q) = %q, %d conflicts", JoinLines(c[0]), JoinLines(c[1]), JoinLines(c[2]), JoinLines(got), n)
+			}
+		}
+	}
+}
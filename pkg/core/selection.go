@@ -0,0 +1,38 @@
+package core
+
+// Selection is the text between an anchor and the head, the end that moves
+// with the cursor. The head may come before the anchor.
+type Selection struct {
+	Anchor Position
+	Head   Position
+}
+
+// Point returns an empty selection at pos
+func Point(pos Position) Selection {
+	return Selection{Anchor: pos, Head: pos}
+}
+
+// Range returns the selected range, start first, end exclusive
+func (s Selection) Range() (start, end Position) {
+	return Order(s.Anchor, s.Head)
+}
+
+// Empty reports whether nothing is selected
+func (s Selection) Empty() bool {
+	return s.Anchor == s.Head
+}
+
+// Text returns the selected text of t
+func (s Selection) Text(t Text) string {
+	start, end := s.Range()
+	return TextInRange(t, ClampPos(t, start), ClampPos(t, end))
+}
+
+// Shift maps the selection through an edit that replaced [start, oldEnd)
+// with text ending at newEnd (see ShiftPos)
+func (s Selection) Shift(start, oldEnd, newEnd Position) Selection {
+	return Selection{
+		Anchor: ShiftPos(s.Anchor, start, oldEnd, newEnd),
+		Head:   ShiftPos(s.Head, start, oldEnd, newEnd),
+	}
+}
@@ -0,0 +1,191 @@
+// Package plugin is the protocol of qedit plugins and the host that runs
+// them. A plugin is a program of its own, in any language: qedit starts it
+// with the command in the [plugins] table of its config, writes Events to
+// its stdin and reads Messages from its stdout, one JSON object per line.
+// A Go plugin can use Send and ReadEvents for its end.
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// Message is a line a plugin writes: a statusline segment, the gutter
+// signs of a file, or both
+type Message struct {
+	Segment *Segment `json:"segment,omitempty"`
+	Signs   *Signs   `json:"signs,omitempty"`
+}
+
+// Segment sets the text of one of the plugin's statusline segments; ""
+// hides it
+type Segment struct {
+	Name  string `json:"name,omitempty"` // segments of a plugin are told apart by name
+	Text  string `json:"text"`
+	Color string `json:"color,omitempty"` // a color name or #rrggbb; "" keeps the statusline's
+}
+
+// Signs replaces the plugin's gutter signs in File, an absolute path.
+// No signs removes them.
+type Signs struct {
+	File  string `json:"file"`
+	Signs []Sign `json:"signs"`
+}
+
+// Sign marks a line of a file in the gutter
+type Sign struct {
+	Line     int    `json:"line"` // 1-based
+	Text     string `json:"text"` // the first character is shown
+	Color    string `json:"color,omitempty"`
+	Priority int    `json:"priority,omitempty"` // the highest one shows when signs share a line
+}
+
+// Event kinds qedit sends
+const (
+	EventOpen = "open" // File was opened in the editor
+	EventSave = "save" // File was written
+)
+
+// Event is a line qedit writes to a plugin
+type Event struct {
+	Event string `json:"event"`
+	File  string `json:"file,omitempty"`
+}
+
+// Send writes m to w as one line
+func Send(w io.Writer, m Message) error {
+	return writeLine(w, m)
+}
+
+// ReadEvents calls fn for each event read from r until r ends
+func ReadEvents(r io.Reader, fn func(Event)) error {
+	return readLines(r, func(line []byte) error {
+		var ev Event
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return err
+		}
+		fn(ev)
+		return nil
+	})
+}
+
+func writeLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func readLines(r io.Reader, fn func([]byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(sc.Bytes()); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// Host runs a plugin. Its messages are read in the background and queued
+// for Poll; wake is called when there are new ones.
+type Host struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu     sync.Mutex
+	queue  []Message
+	err    error
+	exited bool
+}
+
+// Start runs the plugin name with the command argv
+func Start(name string, argv []string, wake func()) (*Host, error) {
+	if len(argv) == 0 {
+		return nil, errors.New("plugin " + name + ": no command")
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	h := &Host{name: name, cmd: cmd, stdin: stdin}
+	go func() {
+		err := readLines(stdout, func(line []byte) error {
+			var m Message
+			if err := json.Unmarshal(line, &m); err != nil {
+				return err
+			}
+			h.mu.Lock()
+			h.queue = append(h.queue, m)
+			h.mu.Unlock()
+			if wake != nil {
+				wake()
+			}
+			return nil
+		})
+		if err != nil {
+			// Don't block the plugin on a pipe no one reads
+			_, _ = io.Copy(io.Discard, stdout)
+		}
+		if werr := cmd.Wait(); err == nil {
+			err = werr
+		}
+		h.mu.Lock()
+		h.err = err
+		h.exited = true
+		h.mu.Unlock()
+		if wake != nil {
+			wake()
+		}
+	}()
+	return h, nil
+}
+
+// Name returns the plugin's name
+func (h *Host) Name() string {
+	return h.name
+}
+
+// Poll returns the messages read since the last call, whether the plugin
+// has exited and the error it exited with
+func (h *Host) Poll() ([]Message, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msgs := h.queue
+	h.queue = nil
+	return msgs, h.exited, h.err
+}
+
+// Notify sends ev to the plugin
+func (h *Host) Notify(ev Event) error {
+	return writeLine(h.stdin, ev)
+}
+
+// Close stops the plugin
+func (h *Host) Close() {
+	_ = h.stdin.Close()
+	h.mu.Lock()
+	exited := h.exited
+	h.mu.Unlock()
+	if !exited && h.cmd.Process != nil {
+		_ = h.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,34 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestProtocolLines(t *testing.T) {
+	var out bytes.Buffer
+	if err := Send(&out, Message{Segment: &Segment{Text: "12:00"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != `{"segment":{"text":"12:00"}}`+"\n" {
+		t.Fatalf("sent %q", got)
+	}
+
+	var events []Event
+	in := `{"event":"open","file":"/a"}` + "\n\n" + `{"event":"save","file":"/a"}` + "\n"
+	if err := ReadEvents(strings.NewReader(in), func(ev Event) { events = append(events, ev) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Event != EventOpen || events[1].Event != EventSave {
+		t.Fatalf("events = %+v", events)
+	}
+	if err := ReadEvents(strings.NewReader("{"), func(Event) {}); err == nil {
+		t.Fatal("a broken line was read")
+	}
+	var m Message
+	if err := json.Unmarshal([]byte(`{"signs":{"file":"/a","signs":[{"line":2,"text":"+"}]}}`), &m); err != nil || m.Signs.Signs[0].Line != 2 {
+		t.Fatalf("message = %+v, %v", m, err)
+	}
+}
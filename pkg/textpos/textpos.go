@@ -0,0 +1,91 @@
+// Package textpos converts column offsets between the encodings used across
+// qedit: the editor counts runes, tree-sitter counts bytes (UTF-8) and LSP
+// counts UTF-16 code units. All functions work on a single line and clamp
+// out-of-range columns to the line bounds. An offset that falls inside a
+// multi-unit character maps to the start of that character.
+package textpos
+
+import "unicode/utf8"
+
+// RuneByteLen returns the UTF-8 length of r; invalid runes count as one byte.
+func RuneByteLen(r rune) int {
+	n := utf8.RuneLen(r)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// RuneUTF16Len returns the number of UTF-16 code units needed for r.
+func RuneUTF16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// RuneToByte converts a rune column to a byte offset.
+func RuneToByte(line []rune, col int) int {
+	col = clamp(col, len(line))
+	n := 0
+	for _, r := range line[:col] {
+		n += RuneByteLen(r)
+	}
+	return n
+}
+
+// ByteToRune converts a byte offset to a rune column.
+func ByteToRune(line []rune, offset int) int {
+	n := 0
+	for i, r := range line {
+		n += RuneByteLen(r)
+		if n > offset {
+			return i
+		}
+	}
+	return len(line)
+}
+
+// RuneToUTF16 converts a rune column to a UTF-16 offset.
+func RuneToUTF16(line []rune, col int) int {
+	col = clamp(col, len(line))
+	n := 0
+	for _, r := range line[:col] {
+		n += RuneUTF16Len(r)
+	}
+	return n
+}
+
+// UTF16ToRune converts a UTF-16 offset to a rune column.
+func UTF16ToRune(line []rune, offset int) int {
+	n := 0
+	for i, r := range line {
+		n += RuneUTF16Len(r)
+		if n > offset {
+			return i
+		}
+	}
+	return len(line)
+}
+
+// ByteToUTF16 converts a byte offset in a UTF-8 line to a UTF-16 offset.
+func ByteToUTF16(line string, offset int) int {
+	runes := []rune(line)
+	return RuneToUTF16(runes, ByteToRune(runes, offset))
+}
+
+// UTF16ToByte converts a UTF-16 offset to a byte offset in a UTF-8 line.
+func UTF16ToByte(line string, offset int) int {
+	runes := []rune(line)
+	return RuneToByte(runes, UTF16ToRune(runes, offset))
+}
+
+func clamp(col, max int) int {
+	if col < 0 {
+		return 0
+	}
+	if col > max {
+		return max
+	}
+	return col
+}
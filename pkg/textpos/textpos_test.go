@@ -0,0 +1,89 @@
+package textpos
+
+import "testing"
+
+func TestConversionsASCII(t *testing.T) {
+	line := []rune("hello")
+	for col := 0; col <= len(line); col++ {
+		if got := RuneToByte(line, col); got != col {
+			t.Fatalf("RuneToByte(%d) = %d", col, got)
+		}
+		if got := RuneToUTF16(line, col); got != col {
+			t.Fatalf("RuneToUTF16(%d) = %d", col, got)
+		}
+		if got := ByteToRune(line, col); got != col {
+			t.Fatalf("ByteToRune(%d) = %d", col, got)
+		}
+		if got := UTF16ToRune(line, col); got != col {
+			t.Fatalf("UTF16ToRune(%d) = %d", col, got)
+		}
+	}
+}
+
+func TestConversionsEmojiAndCJK(t *testing.T) {
+	// a(1 byte,1 unit) 中(3,1) 😀(4,2) é as e+U+0301 (1+2,1+1) b(1,1)
+	line := []rune("a中😀éb")
+	tests := []struct {
+		col, bytes, utf16 int
+	}{
+		{0, 0, 0},
+		{1, 1, 1},
+		{2, 4, 2},
+		{3, 8, 4},
+		{4, 9, 5},
+		{5, 11, 6},
+		{6, 12, 7},
+	}
+	for _, tt := range tests {
+		if got := RuneToByte(line, tt.col); got != tt.bytes {
+			t.Fatalf("RuneToByte(%d) = %d, want %d", tt.col, got, tt.bytes)
+		}
+		if got := ByteToRune(line, tt.bytes); got != tt.col {
+			t.Fatalf("ByteToRune(%d) = %d, want %d", tt.bytes, got, tt.col)
+		}
+		if got := RuneToUTF16(line, tt.col); got != tt.utf16 {
+			t.Fatalf("RuneToUTF16(%d) = %d, want %d", tt.col, got, tt.utf16)
+		}
+		if got := UTF16ToRune(line, tt.utf16); got != tt.col {
+			t.Fatalf("UTF16ToRune(%d) = %d, want %d", tt.utf16, got, tt.col)
+		}
+		if got := ByteToUTF16(string(line), tt.bytes); got != tt.utf16 {
+			t.Fatalf("ByteToUTF16(%d) = %d, want %d", tt.bytes, got, tt.utf16)
+		}
+		if got := UTF16ToByte(string(line), tt.utf16); got != tt.bytes {
+			t.Fatalf("UTF16ToByte(%d) = %d, want %d", tt.utf16, got, tt.bytes)
+		}
+	}
+}
+
+func TestConversionsInsideCharacterAndOutOfRange(t *testing.T) {
+	line := []rune("中😀")
+	// Offsets inside a character map to its start
+	if got := ByteToRune(line, 2); got != 0 {
+		t.Fatalf("ByteToRune inside 中 = %d, want 0", got)
+	}
+	if got := ByteToRune(line, 5); got != 1 {
+		t.Fatalf("ByteToRune inside 😀 = %d, want 1", got)
+	}
+	if got := UTF16ToRune(line, 2); got != 1 {
+		t.Fatalf("UTF16ToRune between surrogates = %d, want 1", got)
+	}
+	// Out of range clamps to the line bounds
+	if got := RuneToByte(line, 10); got != 7 {
+		t.Fatalf("RuneToByte past end = %d, want 7", got)
+	}
+	if got := RuneToUTF16(line, -1); got != 0 {
+		t.Fatalf("RuneToUTF16(-1) = %d, want 0", got)
+	}
+	if got := UTF16ToRune(line, 100); got != 2 {
+		t.Fatalf("UTF16ToRune past end = %d, want 2", got)
+	}
+	// A ZWJ sequence is several runes: 👩‍💻 = U+1F469 U+200D U+1F4BB
+	zwj := []rune("👩‍💻")
+	if got := RuneToUTF16(zwj, len(zwj)); got != 5 {
+		t.Fatalf("RuneToUTF16(zwj) = %d, want 5", got)
+	}
+	if got := RuneToByte(zwj, len(zwj)); got != 11 {
+		t.Fatalf("RuneToByte(zwj) = %d, want 11", got)
+	}
+}